@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"golang.org/x/term"
+)
+
+// ANSI escape codes of the colors we use. All of them have the same length, so that colored
+// columns stay aligned.
+const (
+	colorRed     = "\033[31m"
+	colorGreen   = "\033[32m"
+	colorYellow  = "\033[33m"
+	colorDefault = "\033[39m"
+)
+
+// colorEnabled returns true if we color our output, i.e. if stdout is a terminal and neither
+// --no-color nor the NO_COLOR env var are set (see https://no-color.org).
+func colorEnabled() bool {
+	if rootCmdOpts.NoColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// jobColor returns the color which reflects the state of a job: green if it succeeded, red if it failed
+// and yellow while it's running
+func jobColor(job *v1.JobStatus) string {
+	switch job.Phase {
+	case v1.JobPhase_PHASE_DONE:
+		if job.GetConditions().GetSuccess() {
+			return colorGreen
+		}
+		return colorRed
+	case v1.JobPhase_PHASE_STARTING, v1.JobPhase_PHASE_RUNNING:
+		return colorYellow
+	default:
+		return colorDefault
+	}
+}
+
+// colorByPhase produces the colorByPhase template function, which prints a value in the color of a job's state,
+// e.g. {{ .Phase | colorByPhase . }}. If color is false, it prints the value as is.
+func colorByPhase(color bool) func(job *v1.JobStatus, value interface{}) string {
+	return func(job *v1.JobStatus, value interface{}) string {
+		if !color {
+			return fmt.Sprint(value)
+		}
+		return jobColor(job) + fmt.Sprint(value) + colorDefault
+	}
+}
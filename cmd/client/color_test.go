@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/prettyprint"
+)
+
+func TestJobListColor(t *testing.T) {
+	jobs := []*v1.JobStatus{
+		{Name: "done", Phase: v1.JobPhase_PHASE_DONE, Conditions: &v1.JobConditions{Success: true}},
+		{Name: "failed", Phase: v1.JobPhase_PHASE_DONE, Conditions: &v1.JobConditions{Success: false}},
+		{Name: "running", Phase: v1.JobPhase_PHASE_RUNNING, Conditions: &v1.JobConditions{}},
+		{Name: "starting", Phase: v1.JobPhase_PHASE_STARTING, Conditions: &v1.JobConditions{}},
+		{Name: "queued", Phase: v1.JobPhase_PHASE_QUEUED, Conditions: &v1.JobConditions{}},
+	}
+	for i := range jobs {
+		jobs[i].Metadata = &v1.JobMetadata{Owner: "foo", Repository: &v1.Repository{Owner: "csweichel", Repo: "werft"}}
+	}
+
+	tests := []struct {
+		Color    bool
+		Expected map[string]string
+	}{
+		{
+			Color: false,
+		},
+		{
+			Color: true,
+			Expected: map[string]string{
+				"done":     colorGreen,
+				"failed":   colorRed,
+				"running":  colorYellow,
+				"starting": colorYellow,
+				"queued":   colorDefault,
+			},
+		},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		err := (&prettyprint.Content{
+			Obj:      &v1.ListJobsResponse{Result: jobs, Total: int32(len(jobs))},
+			Format:   prettyprint.TemplateFormat,
+			Writer:   &out,
+			Template: defaultJobListTpl,
+			Funcs: map[string]interface{}{
+				"colorByPhase": colorByPhase(test.Color),
+			},
+		}).Print()
+		if err != nil {
+			t.Errorf("color=%v: cannot print job list: %v", test.Color, err)
+			continue
+		}
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) != len(jobs)+2 {
+			t.Errorf("color=%v: expected a header, %d jobs and a footer, got %q", test.Color, len(jobs), out.String())
+			continue
+		}
+		if !test.Color {
+			if strings.Contains(out.String(), "\033[") {
+				t.Errorf("color=false: output must not contain escape codes: %q", out.String())
+			}
+			continue
+		}
+		for i, line := range lines[1 : len(jobs)+1] {
+			job := jobs[i]
+			expected := test.Expected[job.Name]
+			if !strings.Contains(line, expected+job.Phase.String()) {
+				t.Errorf("color=true: expected phase of %s in %q, got %q", job.Name, expected, line)
+			}
+			if strings.Count(line, expected) < 2 {
+				t.Errorf("color=true: expected success of %s in %q, got %q", job.Name, expected, line)
+			}
+		}
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	defer func(noColor bool) { rootCmdOpts.NoColor = noColor }(rootCmdOpts.NoColor)
+	defer os.Setenv("NO_COLOR", os.Getenv("NO_COLOR"))
+
+	tests := []struct {
+		Name    string
+		NoColor bool
+		Env     string
+	}{
+		{Name: "--no-color", NoColor: true},
+		{Name: "NO_COLOR", Env: "1"},
+		// go test never writes to a terminal, hence we must not color either
+		{Name: "no terminal"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			rootCmdOpts.NoColor = test.NoColor
+			os.Setenv("NO_COLOR", test.Env)
+			if colorEnabled() {
+				t.Errorf("expected color to be disabled")
+			}
+		})
+	}
+}
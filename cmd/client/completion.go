@@ -0,0 +1,143 @@
+package cmd
+
+// Copyright © 2019 Christian Weichel
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/filterexpr"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+// completionCmd represents the completion command
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Generates shell completion scripts",
+	Long: `Generates shell completion scripts. For example, to load completions in bash run:
+  source <(werft completion bash)`,
+	Args:      cobra.ExactValidArgs(1),
+	ValidArgs: []string{"bash", "zsh", "fish", "powershell"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch args[0] {
+		case "bash":
+			return rootCmd.GenBashCompletion(os.Stdout)
+		case "zsh":
+			return rootCmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			return rootCmd.GenFishCompletion(os.Stdout, true)
+		case "powershell":
+			return rootCmd.GenPowerShellCompletion(os.Stdout)
+		default:
+			return xerrors.Errorf("unsupported shell: %s", args[0])
+		}
+	},
+}
+
+// filterOperators are the operators suggested during completion
+var filterOperators = []string{"==", "!=", "~=", "|=", "=|", "=~", ">", "<"}
+
+// filterValues are the values suggested for fields with a known set of values
+var filterValues = map[string][]string{
+	"phase":   enumValues(v1.JobPhase_name, "PHASE_"),
+	"trigger": enumValues(v1.JobTrigger_name, "TRIGGER_"),
+	"success": {"true", "false"},
+}
+
+func enumValues(names map[int32]string, prefix string) []string {
+	res := make([]string, 0, len(names))
+	for _, n := range names {
+		res = append(res, strings.ToLower(strings.TrimPrefix(n, prefix)))
+	}
+	sort.Strings(res)
+	return res
+}
+
+// completeFilterExpr completes a filter expression. Filter expressions can be OR'ed using a comma,
+// in which case we complete the last term.
+func completeFilterExpr(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var prefix string
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix, toComplete = toComplete[:i+1], toComplete[i+1:]
+	}
+
+	var (
+		res []string
+		pos = -1
+		op  string
+	)
+	for _, o := range filterOperators {
+		i := strings.Index(toComplete, o)
+		if i < 0 {
+			continue
+		}
+		if pos < 0 || i < pos || (i == pos && len(o) > len(op)) {
+			pos, op = i, o
+		}
+	}
+	if pos >= 0 {
+		// we have a field and an operator - suggest values if we know them
+		field := toComplete[:pos]
+		for _, v := range filterValues[field] {
+			res = append(res, prefix+field+op+v)
+		}
+		return res, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	for _, f := range filterexpr.Fields {
+		if f != toComplete {
+			continue
+		}
+
+		// the field is complete - suggest the operators
+		for _, op := range filterOperators {
+			res = append(res, prefix+f+op)
+		}
+		return res, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+	}
+
+	for _, f := range filterexpr.Fields {
+		res = append(res, prefix+f)
+	}
+	res = append(res, prefix+"annotation.")
+	return res, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// completeOrder completes the --order flag
+func completeOrder(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var res []string
+	for _, f := range filterexpr.Fields {
+		res = append(res, f+":asc", f+":desc")
+	}
+	return res, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeOutputFormat completes the --output flag
+func completeOutputFormat(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{"template", "wide", "json", "yaml", "string", "csv"}, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompleteFilterExpr(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Contains []string
+		Excludes []string
+	}{
+		{Input: "", Contains: []string{"name", "phase", "repo.ref", "annotation."}},
+		{Input: "phase", Contains: []string{"phase==", "phase!=", "phase=~"}, Excludes: []string{"name"}},
+		{Input: "phase==", Contains: []string{"phase==running", "phase==done"}},
+		{Input: "phase!=r", Contains: []string{"phase!=running"}},
+		{Input: "trigger==", Contains: []string{"trigger==push", "trigger==manual"}},
+		{Input: "success==", Contains: []string{"success==true", "success==false"}},
+		{Input: "phase==done,own", Contains: []string{"phase==done,owner"}},
+		{Input: "name==", Excludes: []string{"name==running"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			res, _ := completeFilterExpr(jobListCmd, nil, test.Input)
+			idx := make(map[string]struct{}, len(res))
+			for _, r := range res {
+				idx[r] = struct{}{}
+			}
+			for _, c := range test.Contains {
+				if _, ok := idx[c]; !ok {
+					t.Errorf("expected %s in %v", c, res)
+				}
+			}
+			for _, c := range test.Excludes {
+				if _, ok := idx[c]; ok {
+					t.Errorf("did not expect %s in %v", c, res)
+				}
+			}
+		})
+	}
+}
+
+func TestEnumValues(t *testing.T) {
+	res := enumValues(map[int32]string{0: "PHASE_UNKNOWN", 1: "PHASE_DONE"}, "PHASE_")
+	expected := []string{"done", "unknown"}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %v but got %v", expected, res)
+	}
+}
@@ -0,0 +1,92 @@
+package cmd
+
+// Copyright © 2019 Christian Weichel
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+import (
+	"fmt"
+	"strings"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+// jobAnnotateCmd represents the annotate command
+var jobAnnotateCmd = &cobra.Command{
+	Use:   "annotate <name> [key=value ...]",
+	Short: "Adds, changes or removes annotations of a job",
+	Long: `Adds, changes or removes annotations of a job, e.g. to mark a release build after the fact:
+  werft job annotate werft-build-main.12 release=v1.0.0
+  werft job annotate werft-build-main.12 --remove release
+
+Annotations starting with werft. are set by werft itself and cannot be changed.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		annotations, err := parseAnnotationArgs(args[1:])
+		if err != nil {
+			return err
+		}
+		remove, _ := cmd.Flags().GetStringArray("remove")
+		if len(annotations) == 0 && len(remove) == 0 {
+			return xerrors.Errorf("nothing to do - please specify key=value pairs or --remove")
+		}
+
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		client := v1.NewWerftServiceClient(conn)
+		ctx, cancel := rpcContext()
+		defer cancel()
+
+		resp, err := client.SetJobAnnotations(ctx, &v1.SetJobAnnotationsRequest{
+			Name:        args[0],
+			Annotations: annotations,
+			Remove:      remove,
+		})
+		if err != nil {
+			return err
+		}
+		for _, a := range resp.Status.Metadata.Annotations {
+			fmt.Printf("%s=%s\n", a.Key, a.Value)
+		}
+		return nil
+	},
+}
+
+// parseAnnotationArgs parses key=value arguments into annotations
+func parseAnnotationArgs(args []string) ([]*v1.Annotation, error) {
+	res := make([]*v1.Annotation, 0, len(args))
+	for _, arg := range args {
+		segs := strings.SplitN(arg, "=", 2)
+		if len(segs) != 2 || segs[0] == "" {
+			return nil, xerrors.Errorf("invalid annotation %q: must be key=value", arg)
+		}
+		res = append(res, &v1.Annotation{Key: segs[0], Value: segs[1]})
+	}
+	return res, nil
+}
+
+func init() {
+	jobCmd.AddCommand(jobAnnotateCmd)
+
+	jobAnnotateCmd.Flags().StringArrayP("remove", "r", nil, "removes the annotation with this key - can be used multiple times")
+}
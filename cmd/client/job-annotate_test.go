@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+)
+
+func TestParseAnnotationArgs(t *testing.T) {
+	tests := []struct {
+		Args        []string
+		Expectation []*v1.Annotation
+		Error       string
+	}{
+		{Args: []string{}, Expectation: []*v1.Annotation{}},
+		{Args: []string{"release=v1.0.0", "notify="}, Expectation: []*v1.Annotation{{Key: "release", Value: "v1.0.0"}, {Key: "notify", Value: ""}}},
+		{Args: []string{"query=a=b"}, Expectation: []*v1.Annotation{{Key: "query", Value: "a=b"}}},
+		{Args: []string{"release"}, Error: `invalid annotation "release": must be key=value`},
+		{Args: []string{"=v1.0.0"}, Error: `invalid annotation "=v1.0.0": must be key=value`},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.Args), func(t *testing.T) {
+			act, err := parseAnnotationArgs(test.Args)
+			if test.Error != "" {
+				if err == nil || err.Error() != test.Error {
+					t.Fatalf("expected error %q, got %v", test.Error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(act, test.Expectation) {
+				t.Errorf("unexpected annotations: %v, expected %v", act, test.Expectation)
+			}
+		})
+	}
+}
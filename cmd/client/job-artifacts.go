@@ -0,0 +1,138 @@
+package cmd
+
+// Copyright © 2019 Christian Weichel
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+import (
+	"context"
+	"io"
+	"os"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/spf13/cobra"
+)
+
+// jobArtifactsCmd represents the artifacts command
+var jobArtifactsCmd = &cobra.Command{
+	Use:   "artifacts <name> [artifact]",
+	Short: "Lists or downloads the artifacts of a job",
+	Long: `Lists the artifacts a job produced, or downloads one of them as gzipped tarball:
+  werft job artifacts werft-build-main.12
+  werft job artifacts werft-build-main.12 dist
+  werft job artifacts werft-build-main.12 dist --dest - | tar xz`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		client := v1.NewWerftServiceClient(conn)
+
+		if len(args) == 1 {
+			ctx, cancel := rpcContext()
+			defer cancel()
+			resp, err := client.GetJob(ctx, &v1.GetJobRequest{Name: args[0]})
+			if err != nil {
+				return err
+			}
+			return prettyPrint(resp.Result, jobArtifactsTpl)
+		}
+
+		dest, _ := cmd.Flags().GetString("dest")
+		if dest == "" {
+			dest = args[1] + ".tar.gz"
+		}
+		return downloadArtifact(client, args[0], args[1], dest)
+	},
+}
+
+const jobArtifactsTpl = `NAME	DESCRIPTION
+{{- range .Results }}
+{{- if eq .Type "artifact" }}
+{{ .Payload }}	{{ .Description }}
+{{- end }}
+{{- end }}
+`
+
+// downloadArtifact writes an artifact of a job to dest, or stdout if dest is "-"
+func downloadArtifact(client v1.WerftServiceClient, name, artifact, dest string) (err error) {
+	ctx, cancel := context.WithCancel(cliContext)
+	defer cancel()
+	stream, err := client.DownloadArtifact(ctx, &v1.DownloadArtifactRequest{Name: name, Artifact: artifact})
+	if err != nil {
+		return err
+	}
+	// the stream reports errors, e.g. an unknown artifact, with the first message only
+	first, err := stream.Recv()
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if dest != "-" {
+		f, err := os.Create(dest)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			cerr := f.Close()
+			if err == nil {
+				err = cerr
+			}
+		}()
+		out = f
+	}
+	if first == nil {
+		return nil
+	}
+
+	_, err = out.Write(first.Data)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, &artifactDownloadReader{stream: stream})
+	return err
+}
+
+// artifactDownloadReader reads the chunks of an artifact download
+type artifactDownloadReader struct {
+	stream v1.WerftService_DownloadArtifactClient
+	buf    []byte
+}
+
+func (r *artifactDownloadReader) Read(p []byte) (n int, err error) {
+	for len(r.buf) == 0 {
+		msg, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = msg.Data
+	}
+
+	n = copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func init() {
+	jobCmd.AddCommand(jobArtifactsCmd)
+
+	jobArtifactsCmd.Flags().String("dest", "", "file to download the artifact to, - for stdout (defaults to <artifact>.tar.gz)")
+}
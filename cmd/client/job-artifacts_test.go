@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type artifactDownloadServer struct {
+	v1.UnimplementedWerftServiceServer
+	Artifacts map[string]string
+}
+
+func (s *artifactDownloadServer) DownloadArtifact(req *v1.DownloadArtifactRequest, resp v1.WerftService_DownloadArtifactServer) error {
+	content, ok := s.Artifacts[req.Artifact]
+	if !ok {
+		return status.Errorf(codes.NotFound, "%s has no artifact %s", req.Name, req.Artifact)
+	}
+	for i := 0; i < len(content); i += 10 {
+		end := i + 10
+		if end > len(content) {
+			end = len(content)
+		}
+		err := resp.Send(&v1.DownloadArtifactResponse{Data: []byte(content[i:end])})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestDownloadArtifact(t *testing.T) {
+	const artifact = "this is not really a tarball but long enough to need several chunks"
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	v1.RegisterWerftServiceServer(srv, &artifactDownloadServer{Artifacts: map[string]string{"dist": artifact, "empty": ""}})
+	go srv.Serve(l)
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := v1.NewWerftServiceClient(conn)
+
+	base, err := ioutil.TempDir(os.TempDir(), "tdja")
+	if err != nil {
+		t.Fatalf("cannot create test folder: %v", err)
+	}
+	defer os.RemoveAll(base)
+
+	tests := []struct {
+		Name     string
+		Artifact string
+		Content  string
+		Error    bool
+	}{
+		{Name: "chunked", Artifact: "dist", Content: artifact},
+		{Name: "empty", Artifact: "empty"},
+		{Name: "unknown", Artifact: "missing", Error: true},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			fn := filepath.Join(base, test.Artifact+".tar.gz")
+			err := downloadArtifact(client, "foo", test.Artifact, fn)
+			if test.Error {
+				if status.Code(err) != codes.NotFound {
+					t.Errorf("expected not found error, got %v", err)
+				}
+				if _, serr := os.Stat(fn); !os.IsNotExist(serr) {
+					t.Errorf("failed download should not create %s", fn)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("cannot download artifact: %v", err)
+			}
+
+			act, err := ioutil.ReadFile(fn)
+			if err != nil {
+				t.Fatalf("cannot read downloaded artifact: %v", err)
+			}
+			if string(act) != test.Content {
+				t.Errorf("unexpected artifact content: %q", string(act))
+			}
+		})
+	}
+}
@@ -22,6 +22,9 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"strings"
 
 	v1 "github.com/csweichel/werft/pkg/api/v1"
 	"github.com/spf13/cobra"
@@ -31,11 +34,42 @@ import (
 var jobGetTpl = `Name:	{{ .Name }}
 Phase:	{{ .Phase }}
 Success:	{{ .Conditions.Success }}
+{{- if .Details }}
+Details:	{{ .Details }}
+{{- end }}
+Conditions:
+  Success:	{{ .Conditions.Success }}
+  Failure Count:	{{ .Conditions.FailureCount }}
+  Can Replay:	{{ .Conditions.CanReplay }}
+  Did Execute:	{{ .Conditions.DidExecute }}
+{{- if .Conditions.Skipped }}
+  Skipped:	{{ .Conditions.Skipped }}
+{{- end }}
+{{- if .Conditions.TimedOut }}
+  Timed Out:	{{ .Conditions.TimedOut }}
+{{- end }}
+{{- if .Conditions.WaitUntil }}
+  Wait Until:	{{ .Conditions.WaitUntil | toRFC3339 }}
+{{- end }}
+{{- if .Conditions.Priority }}
+  Priority:	{{ .Conditions.Priority }}
+{{- end }}
 Metadata:
   Owner:	{{ .Metadata.Owner }}
   Trigger:	{{ .Metadata.Trigger }}
-  Started:	{{ .Metadata.Created | toRFC3339 }}
-  Finished:	{{ .Metadata.Finished | toRFC3339 }}
+  Created:	{{ .Metadata.Created | toRFC3339 }}
+{{- if .Metadata.Started }}
+  Started:	{{ .Metadata.Started | toRFC3339 }}
+{{- end }}
+{{- if .Metadata.Finished }}
+  Completed:	{{ .Metadata.Finished | toRFC3339 }}
+{{- end }}
+{{- if .Metadata.Annotations }}
+Annotations:
+{{- range .Metadata.Annotations }}
+  {{ .Key }}:	{{ .Value }}
+{{- end }}
+{{- end }}
 Repository:
   Host:	{{ .Metadata.Repository.Host }}
   Owner:	{{ .Metadata.Repository.Owner }}
@@ -51,21 +85,29 @@ Results:
 {{- end }}
 `
 
-// jobGetCmd represents the list command
+var jobGetSpecTpl = `{{ with .Result }}` + jobGetTpl + `{{ end }}
+{{- if .JobSpec }}
+Job Spec:
+{{ .JobSpec }}
+{{- end }}
+`
+
+// jobGetCmd represents the get command
 var jobGetCmd = &cobra.Command{
 	Use:   "get [name]",
 	Short: "Retrieves details of a job",
 	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		conn := dial()
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
 		defer conn.Close()
 		client := v1.NewWerftServiceClient(conn)
-		ctx := context.Background()
+		ctx, cancel := rpcContext()
+		defer cancel()
 
-		var (
-			name string
-			err  error
-		)
+		var name string
 		if len(args) == 0 {
 			name, err = findJobByLocalContext(ctx, client)
 			if err != nil {
@@ -78,6 +120,19 @@ var jobGetCmd = &cobra.Command{
 			name = args[0]
 		}
 
+		wait, _ := cmd.Flags().GetBool("wait")
+		if wait {
+			job, err := waitForJob(cliContext, client, name)
+			if err != nil {
+				return err
+			}
+			err = prettyPrint(job, jobGetTpl)
+			if err != nil {
+				return err
+			}
+			os.Exit(jobExitCode(job))
+		}
+
 		resp, err := client.GetJob(ctx, &v1.GetJobRequest{
 			Name: name,
 		})
@@ -85,10 +140,69 @@ var jobGetCmd = &cobra.Command{
 			return err
 		}
 
-		return prettyPrint(resp.Result, jobGetTpl)
+		showSpec, _ := cmd.Flags().GetBool("show-spec")
+		if showSpec {
+			return printEffectiveSpec(resp.Result)
+		}
+		return prettyPrint(resp, jobGetSpecTpl)
 	},
 }
 
+// printEffectiveSpec prints the job spec a job runs with once templates, variables and defaults were applied
+func printEffectiveSpec(job *v1.JobStatus) error {
+	if job.EffectiveSpec == "" {
+		return xerrors.Errorf("%s has no effective job spec: it did not start or was started by an older version of werft", job.Name)
+	}
+	fmt.Print(job.EffectiveSpec)
+	return nil
+}
+
+const (
+	// exitCodeJobFailed is used when a job has finished unsuccessfully
+	exitCodeJobFailed = 1
+	// exitCodeJobCancelled is used when a job was stopped or never executed
+	exitCodeJobCancelled = 2
+)
+
+// waitForJob blocks until the job is done and returns its final status
+func waitForJob(ctx context.Context, client v1.WerftServiceClient, name string) (*v1.JobStatus, error) {
+	updates, err := client.Listen(ctx, &v1.ListenRequest{
+		Name:    name,
+		Updates: true,
+		Logs:    v1.ListenRequestLogs_LOGS_DISABLED,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for {
+		msg, err := updates.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if job := msg.GetUpdate(); job != nil && job.Phase == v1.JobPhase_PHASE_DONE {
+			return job, nil
+		}
+	}
+}
+
+// jobExitCode returns the exit code reflecting the outcome of a finished job
+func jobExitCode(job *v1.JobStatus) int {
+	if job.Conditions == nil {
+		return exitCodeJobFailed
+	}
+	if job.Conditions.Success {
+		return 0
+	}
+	// StopJob marks jobs with this message in their details, as does the executor for superseded jobs
+	if !job.Conditions.DidExecute || strings.HasPrefix(job.Details, "job was stopped manually") || strings.HasPrefix(job.Details, "superseded") {
+		return exitCodeJobCancelled
+	}
+	return exitCodeJobFailed
+}
+
 func init() {
 	jobCmd.AddCommand(jobGetCmd)
+
+	jobGetCmd.Flags().Bool("show-spec", false, "print the job spec the job runs with once templates, variables and defaults were applied")
+	jobGetCmd.Flags().Bool("wait", false, "wait for the job to finish. Exits with 0 if the job succeeded, 1 if it failed and 2 if it was stopped or never ran")
 }
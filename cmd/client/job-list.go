@@ -22,10 +22,20 @@ package cmd
 
 import (
 	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	v1 "github.com/csweichel/werft/pkg/api/v1"
 	"github.com/csweichel/werft/pkg/filterexpr"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"golang.org/x/xerrors"
 )
@@ -37,37 +47,126 @@ var jobListCmd = &cobra.Command{
 	Long: `Lists and searches for jobs using search expressions in the form of "<key><op><value>":
 Available keys are:
   name        name of the job
-  trigger     one of push, manual, unkown
+  trigger     one of push, tag, release, manual, deleted, schedule, unknown
   owner       owner/originator of the job
-  phase       one of unknown, preparing, starting, running, done
+  phase       one of unknown, preparing, starting, running, done, cleanup, waiting, queued
+  repo        source repository as host/owner/repo, e.g. github.com/csweichel/werft
   repo.owner  owner of the source repository
   repo.repo   name of the source repository
   repo.host   host of the source repository (e.g. github.com)
   repo.ref    source reference, i.e. branch name
-  success     one of true, false
-  created     time the job started as RFC3339 date
+  repo.revision
+              source revision, i.e. commit SHA. repo.rev works as well.
+  success     one of true, false (or 1, 0, yes, no)
+  created     time the job was created as RFC3339 date, or relative to now, e.g. -24h or -7d
+  started     time the job started running, like created. Empty while the job is waiting,
+              queued or being prepared.
+  completed   time the job was done, like created. Empty until the job is done.
+  duration    time the job ran for (or has been running for) as Go duration, e.g. 10m.
+              Supports only the > and < operators.
+  annotation.<key>
+              value of the annotation with the given key
+  result.<type>
+              payload of a result of the given type - matches if any of them does
 
 Available operators are:
   ==          checks for equality
+  !=          checks for inequality (short for !==)
   ~=          value must be contained in
-  |=		  starts with
+  |=          starts with
   =|          ends with
+  =~          matches the regular expression
+  >           greater than (exclusive), e.g. created after a point in time
+  <           less than (exclusive), e.g. created before a point in time
 
 Operators can be negated by prefixing them with !.
 
+Annotations and results can be filtered on without operator and value, i.e. annotation.<key>
+finds all jobs which have the annotation, regardless of its value. Prefixed with ! it finds all
+jobs which don't have the annotation.
+
+Multiple expressions are combined using AND. Within a single expression, several
+terms can be separated by comma, which combines them using OR. The comma binds
+tighter than the AND between expressions, i.e. "a,b c" means "(a OR b) AND c".
+
+Values can be quoted using single or double quotes, in which case they may contain
+spaces, commas and operator characters, e.g. 'name=="my build step"'.
+
 For example:
   phase==running             finds all running jobs
+  phase!=done                finds all jobs which have not finished yet
   owner!==webui              finds all jobs NOT owned by webui
   repo.repo|=werft           finds all jobs on repositories whose names begin with werft
+  name=~^build-.*-pr[0-9]+$  finds all jobs whose names match the regular expression
   phase==done success==true  finds all successfully finished jobs
+  annotation.team==payments  finds all jobs with the team annotation set to payments
+  annotation.github.release  finds all jobs started by a GitHub release
+  !annotation.team           finds all jobs without a team annotation
+  result.url|=https://preview.
+                             finds all jobs which published a preview URL
+  created>2019-01-01T00:00:00Z
+                             finds all jobs created after the beginning of 2019
+  created>-7d                finds all jobs created within the last seven days
+  created|=2019-10           finds all jobs created in October 2019 - all operators but ==, > and <
+                             match times as RFC3339 text
+  completed>-1h              finds all jobs which were done within the last hour
+  duration>10m phase!=done   finds all jobs which have been running for more than ten minutes
+  phase==running,phase==starting owner==foo
+                             finds all running or starting jobs owned by foo
+
+Using --latest-per-group only the newest matching job of each group of jobs which share the
+values of the given fields is listed, e.g. --latest-per-group repo.repo,repo.ref lists the
+latest job per branch.
+
+The output of each job can be customized using --template or --template-file. The template
+is a Go template which is rendered for each job, with the job status as its context.
+Available fields are:
+  .Name                              name of the job
+  .Phase                             phase of the job, e.g. PHASE_RUNNING
+  .Details                           details, e.g. why a job failed
+  .Conditions.Success                true if the job succeeded
+  .Conditions.FailureCount           number of failures
+  .Conditions.TimedOut               true if the job exceeded its timeout
+  .Conditions.Skipped                true if the job was skipped because its condition was false
+  .Metadata.Owner                    owner/originator of the job
+  .Metadata.Trigger                  what triggered the job, e.g. TRIGGER_PUSH
+  .Metadata.Created                  time the job was created (use with toRFC3339)
+  .Metadata.Started                  time the job started running, if it has (use with toRFC3339)
+  .Metadata.Finished                 time the job was done, if it is (use with toRFC3339)
+  .Metadata.Annotations              list of annotations with .Key and .Value
+  .Metadata.Repository.Host          host of the source repository
+  .Metadata.Repository.Owner         owner of the source repository
+  .Metadata.Repository.Repo          name of the source repository
+  .Metadata.Repository.Ref           source reference, i.e. branch name
+  .Metadata.Repository.Revision      source revision, i.e. commit
+  .Results                           list of results with .Type, .Payload and .Description
+
+Besides toRFC3339, templates can use age to print how long ago a timestamp was, e.g. 5m or 2d4h,
+and colorByPhase to print a value in the color of the job's state if the output goes to a terminal,
+e.g. {{ .Phase | colorByPhase . }}.
+
+For example:
+  --template '{{ .Name }}	{{ .Metadata.Created | toRFC3339 }}{{ range .Metadata.Annotations }}	{{ .Key }}={{ .Value }}{{ end }}'
+
+Using -o csv the jobs are printed as CSV with a header row, e.g. to export the jobs of last month:
+  werft job list 'created>2021-10-01T00:00:00Z' 'created<2021-11-01T00:00:00Z' --all -o csv > jobs.csv
+The columns are name, owner, repo, host, ref, revision, trigger, phase, success, created, started,
+finished and duration, which is the time from starting the job (or creating it, if it never started) until
+it was done in seconds. Empty values mean the job hasn't started or finished yet.
 		`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		filterterms, err := filterexpr.Parse(args)
+		filter, err := filterexpr.ParseExpressions(args)
 		if err != nil {
 			return err
 		}
-		filter := []*v1.FilterExpression{
-			{Terms: filterterms},
+
+		ignoreCase, _ := cmd.Flags().GetBool("ignore-case")
+		if ignoreCase {
+			for _, f := range filter {
+				for _, t := range f.Terms {
+					t.IgnoreCase = true
+				}
+			}
 		}
 
 		useLocalContext, _ := cmd.Flags().GetBool("local")
@@ -81,7 +180,13 @@ For example:
 		}
 
 		orderExprs, _ := cmd.Flags().GetStringArray("order")
-		order, err := parseOrder(orderExprs)
+		order, err := filterexpr.ParseOrder(orderExprs)
+		if err != nil {
+			return err
+		}
+
+		group, _ := cmd.Flags().GetStringSlice("latest-per-group")
+		err = filterexpr.ValidateGroup(group)
 		if err != nil {
 			return err
 		}
@@ -89,51 +194,299 @@ For example:
 		limit, _ := cmd.Flags().GetUint("limit")
 		offset, _ := cmd.Flags().GetUint("offset")
 		req := v1.ListJobsRequest{
-			Filter: filter,
-			Order:  order,
-			Limit:  int32(limit),
-			Start:  int32(offset),
+			Filter:         filter,
+			Order:          order,
+			LatestPerGroup: group,
+			Limit:          int32(limit),
+			Start:          int32(offset),
 		}
 
-		conn := dial()
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
 		defer conn.Close()
 		client := v1.NewWerftServiceClient(conn)
 
-		ctx := context.Background()
-		resp, err := client.ListJobs(ctx, &req)
+		tpl, err := getJobListTemplate(cmd)
 		if err != nil {
 			return err
 		}
 
-		return prettyPrint(resp, `NAME	OWNER	REPO	PHASE	SUCCESS
-{{- range .Result }}
-{{ .Name }}	{{ .Metadata.Owner }}	{{ .Metadata.Repository.Owner }}/{{ .Metadata.Repository.Repo }}	{{ .Phase }}	{{ .Conditions.Success -}}
-{{ end }}
-`)
+		countOnly, _ := cmd.Flags().GetBool("count-only")
+		if countOnly {
+			// we're only interested in the total, hence don't need more than a single job
+			req.Limit = 1
+			req.Start = 0
+			ctx, cancel := rpcContext()
+			defer cancel()
+			resp, err := client.ListJobs(ctx, &req)
+			if err != nil {
+				return err
+			}
+			fmt.Println(resp.Total)
+			return nil
+		}
+
+		watch, _ := cmd.Flags().GetBool("watch")
+		if watch && outputFormat == "csv" {
+			return xerrors.Errorf("--watch cannot be combined with -o csv")
+		}
+		if watch {
+			interval, _ := cmd.Flags().GetDuration("watch-interval")
+			return watchJobs(client, &req, tpl, interval)
+		}
+
+		var resp *v1.ListJobsResponse
+		all, _ := cmd.Flags().GetBool("all")
+		if all {
+			resp, err = listAllJobs(cliContext, client, req)
+		} else {
+			ctx, cancel := rpcContext()
+			defer cancel()
+			resp, err = client.ListJobs(ctx, &req)
+		}
+		if err != nil {
+			return err
+		}
+
+		if outputFormat == "csv" {
+			return printJobsCSV(os.Stdout, resp.Result)
+		}
+		return prettyPrint(resp, tpl)
 	},
 }
 
-func parseOrder(exprs []string) ([]*v1.OrderExpression, error) {
-	res := make([]*v1.OrderExpression, len(exprs))
-	for i, expr := range exprs {
-		segs := strings.Split(expr, ":")
-		if len(segs) != 2 {
-			return nil, xerrors.Errorf("invalid order expression: %s", expr)
+// jobCSVHeader are the columns of -o csv
+var jobCSVHeader = []string{"name", "owner", "repo", "host", "ref", "revision", "trigger", "phase", "success", "created", "started", "finished", "duration"}
+
+// printJobsCSV prints one row per job, following a header row. Values are quoted where necessary, e.g. if they
+// contain commas, quotes or newlines.
+func printJobsCSV(out io.Writer, jobs []*v1.JobStatus) error {
+	w := csv.NewWriter(out)
+	err := w.Write(jobCSVHeader)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		err = w.Write(jobCSVRow(job))
+		if err != nil {
+			return err
 		}
+	}
+	w.Flush()
+	return w.Error()
+}
 
-		res[i] = &v1.OrderExpression{
-			Field:     segs[0],
-			Ascending: segs[1] == "asc",
+// jobCSVRow produces the values of a job in the order of jobCSVHeader
+func jobCSVRow(job *v1.JobStatus) []string {
+	var (
+		md   = job.Metadata
+		repo = md.GetRepository()
+	)
+	parseTime := func(ts *timestamp.Timestamp) (time.Time, string) {
+		t, err := ptypes.Timestamp(ts)
+		if ts == nil || err != nil {
+			return time.Time{}, ""
+		}
+		return t, t.UTC().Format(time.RFC3339)
+	}
+	created, createdStr := parseTime(md.GetCreated())
+	started, startedStr := parseTime(md.GetStarted())
+	finished, finishedStr := parseTime(md.GetFinished())
+
+	// jobs which never started, e.g. because they were canceled while queued, are done from the moment they were created
+	begin, beginStr := started, startedStr
+	if beginStr == "" {
+		begin, beginStr = created, createdStr
+	}
+	var duration string
+	if beginStr != "" && finishedStr != "" {
+		duration = strconv.FormatInt(int64(finished.Sub(begin).Seconds()), 10)
+	}
+
+	return []string{
+		job.Name,
+		md.GetOwner(),
+		repo.GetOwner() + "/" + repo.GetRepo(),
+		repo.GetHost(),
+		repo.GetRef(),
+		repo.GetRevision(),
+		strings.ToLower(strings.TrimPrefix(md.GetTrigger().String(), "TRIGGER_")),
+		filterexpr.NormalizePhase(job.Phase.String()),
+		strconv.FormatBool(job.GetConditions().GetSuccess()),
+		createdStr,
+		startedStr,
+		finishedStr,
+		duration,
+	}
+}
+
+// maxListAllJobs is the maximum number of jobs --all will retrieve
+const maxListAllJobs = 10000
+
+// listAllJobs pages through all jobs matching the request, starting at req.Start and using
+// req.Limit as page size. It follows the cursor of the server if there is one, and falls back to
+// offsets for servers which don't support cursors.
+func listAllJobs(ctx context.Context, client v1.WerftServiceClient, req v1.ListJobsRequest) (*v1.ListJobsResponse, error) {
+	if req.Limit <= 0 {
+		req.Limit = 50
+	}
+
+	res := &v1.ListJobsResponse{}
+	for {
+		pctx, cancel := context.WithTimeout(ctx, rootCmdOpts.Timeout)
+		resp, err := client.ListJobs(pctx, &req)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		if req.Cursor == "" {
+			// with a cursor the total only counts the jobs after it
+			res.Total = resp.Total
+		}
+		res.Result = append(res.Result, resp.Result...)
+
+		if len(res.Result) >= maxListAllJobs {
+			log.Warnf("stopping after %d jobs", maxListAllJobs)
+			break
+		}
+		if resp.NextCursor != "" {
+			// cursors don't skip or repeat jobs when jobs are added while we page through them
+			req.Cursor, req.Start = resp.NextCursor, 0
+			continue
+		}
+		if req.Cursor != "" {
+			break
+		}
+		// A page that's not full marks the end. A page that's larger than what we asked for means the
+		// server does not support pagination and already gave us everything.
+		if len(resp.Result) != int(req.Limit) {
+			break
+		}
+		req.Start += req.Limit
+		if resp.Total > 0 && req.Start >= resp.Total {
+			break
 		}
 	}
 	return res, nil
 }
 
+// watchJobs lists the jobs and redraws the list whenever a matching job changes, or at the latest
+// after interval has passed. It returns once the user hits Ctrl-C.
+func watchJobs(client v1.WerftServiceClient, req *v1.ListJobsRequest, tpl string, interval time.Duration) error {
+	ctx, cancel := context.WithCancel(cliContext)
+	defer cancel()
+
+	updates := make(chan struct{}, 1)
+	go func() {
+		sub, err := client.Subscribe(ctx, &v1.SubscribeRequest{Filter: req.Filter})
+		if err != nil {
+			log.WithError(err).Debug("cannot subscribe to job updates - falling back to polling")
+			return
+		}
+		for {
+			_, err := sub.Recv()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.WithError(err).Debug("job update subscription failed - falling back to polling")
+				}
+				return
+			}
+
+			select {
+			case updates <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		lctx, lcancel := context.WithTimeout(ctx, rootCmdOpts.Timeout)
+		resp, err := client.ListJobs(lctx, req)
+		lcancel()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// clear the terminal and move the cursor to the top left
+		fmt.Print("\033[H\033[2J")
+		err = prettyPrint(resp, tpl)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-updates:
+		case <-ticker.C:
+		}
+	}
+}
+
+const defaultJobListTpl = `NAME	OWNER	REPO	PHASE	SUCCESS
+{{- range .Result }}
+{{ .Name }}	{{ .Metadata.Owner }}	{{ .Metadata.Repository.Owner }}/{{ .Metadata.Repository.Repo }}	{{ .Phase | colorByPhase . }}	{{ .Conditions.Success | colorByPhase . -}}
+{{ end }}
+{{ len .Result }} of {{ .Total }} jobs
+`
+
+const defaultJobListWideTpl = `NAME	OWNER	REPO	PHASE	SUCCESS	TRIGGER	REF	STARTED	AGE
+{{- range .Result }}
+{{ .Name }}	{{ .Metadata.Owner }}	{{ .Metadata.Repository.Owner }}/{{ .Metadata.Repository.Repo }}	{{ .Phase | colorByPhase . }}	{{ .Conditions.Success | colorByPhase . }}	{{ .Metadata.Trigger }}	{{ .Metadata.Repository.Ref }}	{{ with .Metadata.Started }}{{ . | toRFC3339 }}{{ else }}-{{ end }}	{{ .Metadata.Created | age -}}
+{{ end }}
+{{ len .Result }} of {{ .Total }} jobs
+`
+
+// getJobListTemplate returns the template used to print the list of jobs. If the user provided
+// a per-job template using --template or --template-file, we render that template for each job.
+func getJobListTemplate(cmd *cobra.Command) (string, error) {
+	tpl, _ := cmd.Flags().GetString("template")
+	tplFile, _ := cmd.Flags().GetString("template-file")
+	if tpl != "" && tplFile != "" {
+		return "", xerrors.Errorf("--template and --template-file are mutually exclusive")
+	}
+	if tplFile != "" {
+		fc, err := ioutil.ReadFile(tplFile)
+		if err != nil {
+			return "", xerrors.Errorf("cannot read template file: %w", err)
+		}
+		tpl = string(fc)
+	}
+	if tpl == "" && outputFormat == "wide" {
+		return defaultJobListWideTpl, nil
+	}
+	if tpl == "" {
+		return defaultJobListTpl, nil
+	}
+
+	if !strings.HasSuffix(tpl, "\n") {
+		tpl += "\n"
+	}
+	return "{{ range .Result }}" + tpl + "{{ end }}", nil
+}
+
 func init() {
 	jobCmd.AddCommand(jobListCmd)
 
 	jobListCmd.Flags().Uint("limit", 50, "limit the number of results")
 	jobListCmd.Flags().Uint("offset", 0, "return results starting later than zero")
-	jobListCmd.Flags().StringArray("order", []string{"name:desc"}, "order the result list by fields")
+	jobListCmd.Flags().StringArray("order", []string{"name:desc"}, "order the result list by fields in the form of field:asc, field:desc, +field or -field. Can be repeated, in which case the first order is the primary one and subsequent ones break ties")
 	jobListCmd.Flags().BoolP("local", "l", false, "finds jobs matching the local Git context")
+	jobListCmd.Flags().BoolP("ignore-case", "i", false, "compare text fields (e.g. name, owner or repo.repo) case-insensitively")
+	jobListCmd.RegisterFlagCompletionFunc("order", completeOrder)
+	jobListCmd.ValidArgsFunction = completeFilterExpr
+	jobListCmd.Flags().StringSlice("latest-per-group", nil, "list only the newest job per group of jobs which share the values of these fields, e.g. repo.repo,repo.ref")
+	jobListCmd.Flags().Bool("count-only", false, "print only the number of matching jobs")
+	jobListCmd.Flags().Bool("all", false, "retrieve all matching jobs, using --limit as page size")
+	jobListCmd.Flags().BoolP("watch", "w", false, "watch the jobs and update the list when they change")
+	jobListCmd.Flags().Duration("watch-interval", 2*time.Second, "interval in which the list is refreshed in watch mode")
+	jobListCmd.Flags().String("template", "", "Go template rendered for each job (see help for available fields)")
+	jobListCmd.Flags().String("template-file", "", "file containing a Go template rendered for each job")
 }
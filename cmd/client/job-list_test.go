@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/filterexpr"
+	"github.com/csweichel/werft/pkg/prettyprint"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"google.golang.org/grpc"
+)
+
+func TestPrintJobsCSV(t *testing.T) {
+	ts := func(s string) *timestamp.Timestamp {
+		t, _ := time.Parse(time.RFC3339, s)
+		res, _ := ptypes.TimestampProto(t)
+		return res
+	}
+	jobs := []*v1.JobStatus{
+		{
+			Name:  "werft-build-main.1",
+			Phase: v1.JobPhase_PHASE_DONE,
+			Metadata: &v1.JobMetadata{
+				Owner:      "csweichel",
+				Repository: &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: "refs/heads/main", Revision: "c0ffee"},
+				Trigger:    v1.JobTrigger_TRIGGER_PUSH,
+				Created:    ts("2021-10-01T10:00:00Z"),
+				Started:    ts("2021-10-01T10:00:30Z"),
+				Finished:   ts("2021-10-01T10:05:00Z"),
+			},
+			Conditions: &v1.JobConditions{Success: true},
+		},
+		{
+			Name:  "werft-build-quotes.2",
+			Phase: v1.JobPhase_PHASE_RUNNING,
+			Metadata: &v1.JobMetadata{
+				Owner:      "Weichel, Christian",
+				Repository: &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: `refs/heads/"quoted"`},
+				Trigger:    v1.JobTrigger_TRIGGER_MANUAL,
+				Created:    ts("2021-10-02T10:00:00Z"),
+				Started:    ts("2021-10-02T10:01:00Z"),
+			},
+			Conditions: &v1.JobConditions{},
+		},
+		{
+			Name:  "werft-build-newline.3",
+			Phase: v1.JobPhase_PHASE_QUEUED,
+			Metadata: &v1.JobMetadata{
+				Owner:      "first line\nsecond line",
+				Repository: &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft"},
+				Created:    ts("2021-10-03T10:00:00Z"),
+			},
+		},
+	}
+
+	var out bytes.Buffer
+	err := printJobsCSV(&out, jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedRaw := strings.Join([]string{
+		"name,owner,repo,host,ref,revision,trigger,phase,success,created,started,finished,duration",
+		"werft-build-main.1,csweichel,csweichel/werft,github.com,refs/heads/main,c0ffee,push,done,true,2021-10-01T10:00:00Z,2021-10-01T10:00:30Z,2021-10-01T10:05:00Z,270",
+		`werft-build-quotes.2,"Weichel, Christian",csweichel/werft,github.com,"refs/heads/""quoted""",,manual,running,false,2021-10-02T10:00:00Z,2021-10-02T10:01:00Z,,`,
+		"werft-build-newline.3,\"first line\nsecond line\",csweichel/werft,github.com,,,unknown,queued,false,2021-10-03T10:00:00Z,,,",
+		"",
+	}, "\n")
+	if act := out.String(); act != expectedRaw {
+		t.Errorf("unexpected CSV:\n%s\nexpected:\n%s", act, expectedRaw)
+	}
+
+	// reading the CSV must yield the original values
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("cannot read CSV: %v", err)
+	}
+	if len(rows) != len(jobs)+1 {
+		t.Fatalf("expected a header and %d rows, got %d rows", len(jobs), len(rows))
+	}
+	if !reflect.DeepEqual(rows[0], jobCSVHeader) {
+		t.Errorf("unexpected header: %v", rows[0])
+	}
+	for i, row := range rows[1:] {
+		if len(row) != len(jobCSVHeader) {
+			t.Errorf("row %d: expected %d columns, got %d", i, len(jobCSVHeader), len(row))
+			continue
+		}
+		if row[0] != jobs[i].Name || row[1] != jobs[i].Metadata.Owner || row[4] != jobs[i].Metadata.Repository.Ref {
+			t.Errorf("row %d: values did not survive the round trip: %v", i, row)
+		}
+	}
+}
+
+func TestPrintJobsCSVEmpty(t *testing.T) {
+	var out bytes.Buffer
+	err := printJobsCSV(&out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if act, expected := out.String(), strings.Join(jobCSVHeader, ",")+"\n"; act != expected {
+		t.Errorf("expected only the header %q, got %q", expected, act)
+	}
+}
+
+func TestJobListWideStarted(t *testing.T) {
+	md := func(started *timestamp.Timestamp) *v1.JobMetadata {
+		return &v1.JobMetadata{
+			Repository: &v1.Repository{Owner: "csweichel", Repo: "werft"},
+			Created:    &timestamp.Timestamp{Seconds: time.Date(2021, 10, 2, 10, 0, 0, 0, time.UTC).Unix()},
+			Started:    started,
+		}
+	}
+	jobs := []*v1.JobStatus{
+		{Name: "running", Phase: v1.JobPhase_PHASE_RUNNING, Metadata: md(&timestamp.Timestamp{Seconds: time.Date(2021, 10, 2, 10, 1, 0, 0, time.UTC).Unix()}), Conditions: &v1.JobConditions{}},
+		{Name: "queued", Phase: v1.JobPhase_PHASE_QUEUED, Metadata: md(nil), Conditions: &v1.JobConditions{}},
+	}
+
+	var out bytes.Buffer
+	err := (&prettyprint.Content{
+		Obj:      &v1.ListJobsResponse{Result: jobs, Total: int32(len(jobs))},
+		Format:   prettyprint.TemplateFormat,
+		Writer:   &out,
+		Template: defaultJobListWideTpl,
+		Funcs:    map[string]interface{}{"colorByPhase": colorByPhase(false)},
+	}).Print()
+	if err != nil {
+		t.Fatalf("cannot print job list: %v", err)
+	}
+
+	started := func(line string) string {
+		fields := strings.Fields(line)
+		return fields[len(fields)-2]
+	}
+	lines := strings.Split(out.String(), "\n")
+	if act := started(lines[1]); act != "2021-10-02T10:01:00Z" {
+		t.Errorf("running job should show when it started, got %q", act)
+	}
+	if act := started(lines[2]); act != "-" {
+		t.Errorf("queued job hasn't started yet, got %q", act)
+	}
+}
+
+type listJobsServer struct {
+	v1.UnimplementedWerftServiceServer
+	Jobs []v1.JobStatus
+	Reqs []*v1.ListJobsRequest
+	Resp *v1.ListJobsResponse
+}
+
+func (s *listJobsServer) ListJobs(ctx context.Context, req *v1.ListJobsRequest) (*v1.ListJobsResponse, error) {
+	s.Reqs = append(s.Reqs, req)
+	s.Resp = &v1.ListJobsResponse{}
+	for i := range s.Jobs {
+		if filterexpr.MatchesFilter(&s.Jobs[i], req.Filter) {
+			s.Resp.Result = append(s.Resp.Result, &s.Jobs[i])
+		}
+	}
+	s.Resp.Total = int32(len(s.Resp.Result))
+	return s.Resp, nil
+}
+
+func TestJobListNotEquals(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeServer := &listJobsServer{Jobs: []v1.JobStatus{
+		{Name: "running.1", Phase: v1.JobPhase_PHASE_RUNNING, Metadata: &v1.JobMetadata{}},
+		{Name: "waiting.1", Phase: v1.JobPhase_PHASE_WAITING, Metadata: &v1.JobMetadata{}},
+		{Name: "done.1", Phase: v1.JobPhase_PHASE_DONE, Metadata: &v1.JobMetadata{}},
+	}}
+	srv := grpc.NewServer()
+	v1.RegisterWerftServiceServer(srv, fakeServer)
+	go srv.Serve(l)
+	defer srv.Stop()
+
+	oldOpts, oldFormat := rootCmdOpts, outputFormat
+	defer func() { rootCmdOpts, outputFormat = oldOpts, oldFormat }()
+	rootCmdOpts.DialMode = dialModeHost
+	rootCmdOpts.Host = l.Addr().String()
+	outputFormat = "json"
+
+	err = jobListCmd.RunE(jobListCmd, []string{"phase!=done"})
+	if err != nil {
+		t.Fatalf("cannot list jobs: %v", err)
+	}
+
+	if len(fakeServer.Reqs) != 1 {
+		t.Fatalf("expected a single request, got %v", fakeServer.Reqs)
+	}
+	expectedFilter := []*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "phase", Value: "done", Operation: v1.FilterOp_OP_EQUALS, Negate: true}}}}
+	if act := fakeServer.Reqs[0].Filter; !proto.Equal(&v1.ListJobsRequest{Filter: act}, &v1.ListJobsRequest{Filter: expectedFilter}) {
+		t.Errorf("unexpected filter: %v, expected %v", act, expectedFilter)
+	}
+
+	var names []string
+	for _, js := range fakeServer.Resp.Result {
+		names = append(names, js.Name)
+	}
+	sort.Strings(names)
+	if expected := []string{"running.1", "waiting.1"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v but got %v", expected, names)
+	}
+}
@@ -21,31 +21,54 @@ package cmd
 // THE SOFTWARE.
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/logcutter"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"golang.org/x/xerrors"
+	"google.golang.org/grpc"
+	grpcgzip "google.golang.org/grpc/encoding/gzip"
 )
 
-// jobLogsCmd represents the list command
+// jobLogsCmd represents the logs command
 var jobLogsCmd = &cobra.Command{
 	Use:   "logs [name]",
-	Short: "Listens to the log output of a job",
+	Short: "Prints the log output of a job",
 	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		conn := dial()
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
 		defer conn.Close()
 		client := v1.NewWerftServiceClient(conn)
-		ctx := context.Background()
 
-		var (
-			name string
-			err  error
-		)
+		follow, _ := cmd.Flags().GetBool("follow")
+		tail, _ := cmd.Flags().GetInt("tail")
+		compress, _ := cmd.Flags().GetBool("compress")
+		download, _ := cmd.Flags().GetString("download")
+		section, _ := cmd.Flags().GetString("section")
+		if download != "" && (follow || tail >= 0) {
+			return xerrors.Errorf("--download cannot be combined with --follow or --tail")
+		}
+
+		var name string
 		if len(args) == 0 {
+			ctx, cancel := rpcContext()
+			defer cancel()
 			name, err = findJobByLocalContext(ctx, client)
 			if err != nil {
 				return err
@@ -54,18 +77,326 @@ var jobLogsCmd = &cobra.Command{
 				return xerrors.Errorf("no job found - please specify job name")
 			}
 
-			fmt.Printf("showing logs of \033[34m\033[1m%s\t\033\033[0m\n", name)
+			if download != "-" {
+				fmt.Printf("showing logs of \033[34m\033[1m%s\t\033\033[0m\n", name)
+			}
 		} else {
 			name = args[0]
 		}
 
-		return followJob(client, name, "")
+		if download != "" {
+			return downloadJobLogs(client, name, section, download, compress)
+		}
+		return streamJobLogs(client, name, section, follow, tail, compress)
 	},
 }
 
+const (
+	// logsCatchUpTimeout is the time after which we consider all existing log output received
+	logsCatchUpTimeout = 500 * time.Millisecond
+	// logsMaxReconnects is the number of times we try to re-establish a dropped log stream
+	logsMaxReconnects = 5
+)
+
+// streamJobLogs prints the logs of a job. If follow is true we keep streaming until the job is done,
+// otherwise we stop once we've received the existing log output. If tail is non-negative we print only
+// the last tail lines of the existing log output. If section is not empty we print only the log slices of that section,
+// including its nested sections. If compress is true we ask the server to gzip the log stream,
+// which gRPC decompresses transparently.
+//
+// Should the log stream drop while following, we reconnect and skip the part of the log we have already printed.
+// The server always replays the log from the start, but a listener which can't keep up may receive drop markers
+// in place of content. Hence we count the bytes of log we've passed through rather than slices, and skip that
+// many bytes of the replayed stream.
+func streamJobLogs(client v1.WerftServiceClient, name, section string, follow bool, tail int, compress bool) error {
+	var (
+		offset   int
+		retries  int
+		buf      []*v1.LogSliceEvent
+		caughtUp = tail < 0
+		callOpts []grpc.CallOption
+	)
+	if compress {
+		callOpts = append(callOpts, grpc.UseCompressor(grpcgzip.Name))
+	}
+	flush := func() {
+		if tail >= 0 && len(buf) > tail {
+			buf = buf[len(buf)-tail:]
+		}
+		for _, slice := range buf {
+			pringLogSlice(slice)
+		}
+		buf = nil
+		caughtUp = true
+	}
+
+	for {
+		ctx, cancel := context.WithCancel(cliContext)
+		logs, err := client.Listen(ctx, &v1.ListenRequest{
+			Name:    name,
+			Logs:    v1.ListenRequestLogs_LOGS_RAW,
+			Updates: true,
+			Section: section,
+		}, callOpts...)
+		if err != nil {
+			cancel()
+			return err
+		}
+
+		var (
+			msgs = make(chan *v1.ListenResponse)
+			errs = make(chan error, 1)
+		)
+		go func() {
+			for {
+				msg, err := logs.Recv()
+				if err != nil {
+					errs <- err
+					return
+				}
+				select {
+				case msgs <- msg:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		var (
+			skip = offset
+			idle = time.NewTimer(logsCatchUpTimeout)
+		)
+	recv:
+		for {
+			select {
+			case msg := <-msgs:
+				if update := msg.GetUpdate(); update != nil && update.Phase == v1.JobPhase_PHASE_DONE {
+					cancel()
+					flush()
+					if !follow {
+						return nil
+					}
+
+					prettyPrint(update, jobGetTpl)
+					if update.Conditions.Success {
+						os.Exit(0)
+					} else {
+						os.Exit(1)
+					}
+				}
+
+				slice := msg.GetSlice()
+				if slice == nil {
+					continue
+				}
+				if skip > 0 {
+					n := logSliceSize(slice)
+					if n <= skip {
+						skip -= n
+						continue
+					}
+					slice = trimLogSlice(slice, skip)
+					skip = 0
+				}
+				offset += logSliceSize(slice)
+				if section != "" && !logcutter.InSection(slice.Name, section) {
+					// servers which don't support sections send the whole log
+					continue
+				}
+
+				if caughtUp {
+					pringLogSlice(slice)
+				} else if isPrintableLogSlice(slice) {
+					buf = append(buf, slice)
+				}
+				if !idle.Stop() {
+					select {
+					case <-idle.C:
+					default:
+					}
+				}
+				idle.Reset(logsCatchUpTimeout)
+			case <-idle.C:
+				if !caughtUp {
+					flush()
+				}
+				if !follow {
+					cancel()
+					return nil
+				}
+			case err := <-errs:
+				cancel()
+				if err == io.EOF {
+					flush()
+					return nil
+				}
+				if !follow || retries >= logsMaxReconnects {
+					return err
+				}
+
+				retries++
+				log.WithError(err).Debugf("log stream dropped - reconnecting (%d/%d)", retries, logsMaxReconnects)
+				time.Sleep(time.Duration(retries) * time.Second)
+				break recv
+			}
+		}
+	}
+}
+
+// logSliceSize returns the number of bytes of log a slice stands for
+func logSliceSize(slice *v1.LogSliceEvent) int {
+	if slice.Type == v1.LogSliceType_SLICE_DROPPED {
+		n, _ := strconv.Atoi(slice.Payload)
+		return n
+	}
+	return len(slice.Payload)
+}
+
+// trimLogSlice returns a copy of slice without its first n bytes of log
+func trimLogSlice(slice *v1.LogSliceEvent, n int) *v1.LogSliceEvent {
+	res := &v1.LogSliceEvent{Name: slice.Name, Type: slice.Type, Parent: slice.Parent}
+	if slice.Type == v1.LogSliceType_SLICE_DROPPED {
+		res.Payload = strconv.Itoa(logSliceSize(slice) - n)
+	} else {
+		res.Payload = slice.Payload[n:]
+	}
+	return res
+}
+
+// downloadJobLogs writes the complete log of a job to dest, or stdout if dest is "-". If dest is a .tar.gz or .tgz file
+// we write an archive which contains a file for each section of the log. If section is not empty, we download the content
+// of that section only.
+func downloadJobLogs(client v1.WerftServiceClient, name, section, dest string, compress bool) (err error) {
+	var callOpts []grpc.CallOption
+	if compress {
+		callOpts = append(callOpts, grpc.UseCompressor(grpcgzip.Name))
+	}
+
+	ctx, cancel := context.WithCancel(cliContext)
+	defer cancel()
+	stream, err := client.DownloadLogs(ctx, &v1.DownloadLogsRequest{Name: name, Section: section}, callOpts...)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if dest != "-" {
+		f, err := os.Create(dest)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			cerr := f.Close()
+			if err == nil {
+				err = cerr
+			}
+		}()
+		out = f
+	}
+
+	in := &logDownloadReader{stream: stream}
+	if strings.HasSuffix(dest, ".tar.gz") || strings.HasSuffix(dest, ".tgz") {
+		return writeLogArchive(out, in)
+	}
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// logDownloadReader reads the chunks of a log download
+type logDownloadReader struct {
+	stream v1.WerftService_DownloadLogsClient
+	buf    []byte
+}
+
+func (r *logDownloadReader) Read(p []byte) (n int, err error) {
+	for len(r.buf) == 0 {
+		msg, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = msg.Data
+	}
+
+	n = copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// writeLogArchive writes a gzipped tar archive to out which contains a <section>.log file for each section of the log.
+// Nested sections are placed in a directory named after their parent, e.g. build/compile.log for [build|compile].
+func writeLogArchive(out io.Writer, in io.Reader) error {
+	var (
+		sections = make(map[string]*bytes.Buffer)
+		order    []string
+	)
+	evts, errs := logcutter.DefaultCutter.Slice(in)
+recv:
+	for {
+		select {
+		case evt := <-evts:
+			if evt == nil {
+				break recv
+			}
+			if evt.Type != v1.LogSliceType_SLICE_CONTENT {
+				continue
+			}
+
+			buf, ok := sections[evt.Name]
+			if !ok {
+				buf = bytes.NewBuffer(nil)
+				sections[evt.Name] = buf
+				order = append(order, evt.Name)
+			}
+			buf.WriteString(evt.Payload)
+			buf.WriteString("\n")
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	var (
+		gz  = gzip.NewWriter(out)
+		tw  = tar.NewWriter(gz)
+		now = time.Now()
+	)
+	for _, name := range order {
+		segs := strings.Split(name, logcutter.SectionSeparator)
+		for i, seg := range segs {
+			segs[i] = unsafeFilenameChars.ReplaceAllString(seg, "_")
+		}
+
+		content := sections[name].Bytes()
+		err := tw.WriteHeader(&tar.Header{
+			Name:    path.Join(segs...) + ".log",
+			Mode:    0644,
+			Size:    int64(len(content)),
+			ModTime: now,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(content)
+		if err != nil {
+			return err
+		}
+	}
+	err := tw.Close()
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
 func followJob(client v1.WerftServiceClient, name, prefix string) error {
-	ctx := context.Background()
-	logs, err := client.Listen(ctx, &v1.ListenRequest{
+	logs, err := client.Listen(cliContext, &v1.ListenRequest{
 		Name:    name,
 		Logs:    v1.ListenRequestLogs_LOGS_RAW,
 		Updates: true,
@@ -101,8 +432,16 @@ func followJob(client v1.WerftServiceClient, name, prefix string) error {
 	}
 }
 
-func pringLogSlice(slice *v1.LogSliceEvent) {
+// isPrintableLogSlice returns true if pringLogSlice would print the slice
+func isPrintableLogSlice(slice *v1.LogSliceEvent) bool {
 	if slice.Name == "werft:kubernetes" || slice.Name == "werft:status" {
+		return false
+	}
+	return slice.Type == v1.LogSliceType_SLICE_PHASE || slice.Type == v1.LogSliceType_SLICE_CONTENT || slice.Type == v1.LogSliceType_SLICE_DROPPED
+}
+
+func pringLogSlice(slice *v1.LogSliceEvent) {
+	if !isPrintableLogSlice(slice) {
 		return
 	}
 
@@ -112,6 +451,8 @@ func pringLogSlice(slice *v1.LogSliceEvent) {
 		tpl = "\033[33m\033[1m{{ .Name }}\t\033[39m{{ .Payload }}\033[0m\n"
 	case v1.LogSliceType_SLICE_CONTENT:
 		tpl = "\033[2m[{{ .Name }}]\033[0m {{ .Payload }}\n"
+	case v1.LogSliceType_SLICE_DROPPED:
+		tpl = "\033[2m[{{ .Name }}]\033[0m \033[33m... {{ .Payload }} bytes of log output dropped because we couldn't keep up\033[0m\n"
 	}
 	if tpl == "" {
 		return
@@ -129,6 +470,8 @@ func printLogSliceWithPrefix(prefix string, slice *v1.LogSliceEvent) {
 		fmt.Printf("[%s%s|PHASE] %s\n", prefix, slice.Name, slice.Payload)
 	case v1.LogSliceType_SLICE_CONTENT:
 		fmt.Printf("[%s%s] %s\n", prefix, slice.Name, slice.Payload)
+	case v1.LogSliceType_SLICE_DROPPED:
+		fmt.Printf("[%s%s] ... %s bytes of log output dropped\n", prefix, slice.Name, slice.Payload)
 	case v1.LogSliceType_SLICE_DONE:
 		fmt.Printf("[%s%s|DONE] %s\n", prefix, slice.Name, slice.Payload)
 	case v1.LogSliceType_SLICE_FAIL:
@@ -140,4 +483,10 @@ func printLogSliceWithPrefix(prefix string, slice *v1.LogSliceEvent) {
 
 func init() {
 	jobCmd.AddCommand(jobLogsCmd)
+
+	jobLogsCmd.Flags().BoolP("follow", "f", false, "keep streaming the logs until the job is done")
+	jobLogsCmd.Flags().Int("tail", -1, "print only the last N lines of the existing log output. Defaults to -1 which prints all lines")
+	jobLogsCmd.Flags().String("download", "", "write the complete log to a file instead of streaming it. Use - for stdout, or a .tar.gz file to get one file per log section")
+	jobLogsCmd.Flags().String("section", "", "print only this log section, including its nested sections, e.g. build or build|compile. Combined with --download only the section's content is written")
+	jobLogsCmd.Flags().Bool("compress", true, "receive the logs gzip compressed. Disable for werft servers which don't support compression")
 }
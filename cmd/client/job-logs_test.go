@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type logDownloadServer struct {
+	v1.UnimplementedWerftServiceServer
+	Log string
+}
+
+func (s *logDownloadServer) DownloadLogs(req *v1.DownloadLogsRequest, resp v1.WerftService_DownloadLogsServer) error {
+	// send the log in small chunks to make sure they're stitched back together
+	for i := 0; i < len(s.Log); i += 10 {
+		end := i + 10
+		if end > len(s.Log) {
+			end = len(s.Log)
+		}
+		err := resp.Send(&v1.DownloadLogsResponse{Data: []byte(s.Log[i:end])})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestDownloadJobLogs(t *testing.T) {
+	const joblog = `preparing
+[build|PHASE] building the thing
+compiling
+[build|lib] compiling lib
+[werft:kubernetes] pod started
+linking
+[test|PHASE] testing the thing
+all tests passed
+`
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	v1.RegisterWerftServiceServer(srv, &logDownloadServer{Log: joblog})
+	go srv.Serve(l)
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := v1.NewWerftServiceClient(conn)
+
+	base, err := ioutil.TempDir(os.TempDir(), "tdjl")
+	if err != nil {
+		t.Fatalf("cannot create test folder: %v", err)
+	}
+	defer os.RemoveAll(base)
+
+	fn := filepath.Join(base, "job.log")
+	err = downloadJobLogs(client, "foo", "", fn, true)
+	if err != nil {
+		t.Fatalf("cannot download log: %v", err)
+	}
+	act, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatalf("cannot read downloaded log: %v", err)
+	}
+	if string(act) != joblog {
+		t.Errorf("unexpected log content: %q", string(act))
+	}
+
+	fn = filepath.Join(base, "job.tar.gz")
+	err = downloadJobLogs(client, "foo", "", fn, false)
+	if err != nil {
+		t.Fatalf("cannot download log: %v", err)
+	}
+	f, err := os.Open(fn)
+	if err != nil {
+		t.Fatalf("cannot read downloaded archive: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("cannot read downloaded archive: %v", err)
+	}
+	sections := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("cannot read downloaded archive: %v", err)
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("cannot read downloaded archive: %v", err)
+		}
+		sections[hdr.Name] = string(content)
+	}
+
+	expected := map[string]string{
+		"default.log":          "preparing\n",
+		"build.log":            "compiling\nlinking\n",
+		"build/lib.log":        "compiling lib\n",
+		"werft_kubernetes.log": "pod started\n",
+		"test.log":             "all tests passed\n",
+	}
+	if !reflect.DeepEqual(expected, sections) {
+		t.Errorf("unexpected archive content: %v", sections)
+	}
+}
+
+type logListenServer struct {
+	v1.UnimplementedWerftServiceServer
+	// Streams are sent one per Listen call. All but the last stream end with an error.
+	Streams [][]*v1.LogSliceEvent
+	calls   int
+}
+
+func (s *logListenServer) Listen(req *v1.ListenRequest, resp v1.WerftService_ListenServer) error {
+	stream := s.Streams[s.calls]
+	s.calls++
+	for _, slice := range stream {
+		err := resp.Send(&v1.ListenResponse{Content: &v1.ListenResponse_Slice{Slice: slice}})
+		if err != nil {
+			return err
+		}
+	}
+	if s.calls < len(s.Streams) {
+		return status.Error(codes.Unavailable, "stream dropped")
+	}
+	return nil
+}
+
+func TestStreamJobLogsReconnect(t *testing.T) {
+	content := func(payload string) *v1.LogSliceEvent {
+		return &v1.LogSliceEvent{Name: "build", Type: v1.LogSliceType_SLICE_CONTENT, Payload: payload}
+	}
+	// the first stream could not keep up and drops in the middle of what the replay sends as a single slice
+	srv := &logListenServer{Streams: [][]*v1.LogSliceEvent{
+		{
+			content("hello"),
+			{Name: "build", Type: v1.LogSliceType_SLICE_DROPPED, Payload: "7"},
+		},
+		{
+			content("hello"),
+			content("world1234"),
+			content("done"),
+		},
+	}}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gsrv := grpc.NewServer()
+	v1.RegisterWerftServiceServer(gsrv, srv)
+	go gsrv.Serve(l)
+	defer gsrv.Stop()
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := v1.NewWerftServiceClient(conn)
+
+	defer func(tpl string, stdout *os.File) {
+		outputTemplate = tpl
+		os.Stdout = stdout
+	}(outputTemplate, os.Stdout)
+	outputTemplate = "{{ .Type }} {{ .Payload }}\n"
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+
+	err = streamJobLogs(client, "foo", "", true, -1, false)
+	w.Close()
+	if err != nil {
+		t.Fatalf("cannot stream logs: %v", err)
+	}
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "SLICE_CONTENT hello\nSLICE_DROPPED 7\nSLICE_CONTENT 34\nSLICE_CONTENT done\n"
+	if string(out) != expected {
+		t.Errorf("unexpected output: %q, expected %q", string(out), expected)
+	}
+}
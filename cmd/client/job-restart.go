@@ -0,0 +1,84 @@
+package cmd
+
+// Copyright © 2019 Christian Weichel
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+// jobRestartCmd represents the restart command
+var jobRestartCmd = &cobra.Command{
+	Use:   "restart [name]",
+	Short: "Starts a new job with the same inputs as a previous one",
+	Long: `Starts a new job with the same inputs as a previous one, i.e. the same repository, ref,
+trigger, annotations and job spec. The new job carries a werft.restartedFrom annotation
+pointing to the previous job.
+
+Jobs started from local or sideloaded content cannot be restarted.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		client := v1.NewWerftServiceClient(conn)
+		ctx, cancel := rpcContext()
+		defer cancel()
+
+		var name string
+		if len(args) == 0 {
+			name, err = findJobByLocalContext(ctx, client)
+			if err != nil {
+				return err
+			}
+			if name == "" {
+				return xerrors.Errorf("no job found - please specify job name")
+			}
+		} else {
+			name = args[0]
+		}
+
+		resp, err := client.StartFromPreviousJob(ctx, &v1.StartFromPreviousJobRequest{
+			PreviousJob: name,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Println(resp.Status.Name)
+
+		follow, _ := cmd.Flags().GetBool("follow")
+		if follow {
+			return followJob(client, resp.Status.Name, "")
+		}
+		return nil
+	},
+}
+
+func init() {
+	jobCmd.AddCommand(jobRestartCmd)
+
+	jobRestartCmd.Flags().BoolP("follow", "f", false, "follow the log output of the new job")
+}
@@ -0,0 +1,150 @@
+package cmd
+
+// Copyright © 2019 Christian Weichel
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/filterexpr"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+// jobStopCmd represents the stop command
+var jobStopCmd = &cobra.Command{
+	Use:   "stop [name]",
+	Short: "Stops a running job",
+	Long: `Stops a running job. The job's pod is marked as failed and eventually deleted.
+If the job is no longer running this command fails.
+
+With --filter, all running jobs matching the filter expressions are stopped. The expressions
+are the same as for "werft job list". Without --yes this only lists the jobs which would be stopped.
+
+For example:
+  werft job stop --filter repo.repo==werft --filter repo.ref==refs/heads/main
+  werft job stop --filter repo.repo==werft --filter repo.ref==refs/heads/main --yes --reason "bad commit"`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		client := v1.NewWerftServiceClient(conn)
+		ctx, cancel := rpcContext()
+		defer cancel()
+
+		reason, _ := cmd.Flags().GetString("reason")
+		filterExprs, _ := cmd.Flags().GetStringArray("filter")
+		if len(filterExprs) > 0 {
+			if len(args) > 0 {
+				return xerrors.Errorf("cannot combine a job name and --filter")
+			}
+			filter, err := filterexpr.ParseExpressions(filterExprs)
+			if err != nil {
+				return err
+			}
+			yes, _ := cmd.Flags().GetBool("yes")
+			return stopJobs(ctx, client, &v1.StopJobsRequest{Filter: filter, Reason: reason, DryRun: !yes})
+		}
+
+		var name string
+		if len(args) == 0 {
+			name, err = findJobByLocalContext(ctx, client)
+			if err != nil {
+				return err
+			}
+			if name == "" {
+				return xerrors.Errorf("no job found - please specify job name")
+			}
+		} else {
+			name = args[0]
+		}
+
+		_, err = client.StopJob(ctx, &v1.StopJobRequest{
+			Name:   name,
+			Reason: reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		// stopping a job is asynchronous - give it a moment to reach its final phase
+		for i := 0; i < 20; i++ {
+			resp, err := client.GetJob(ctx, &v1.GetJobRequest{Name: name})
+			if err != nil {
+				return err
+			}
+			if resp.Result.Phase == v1.JobPhase_PHASE_DONE || resp.Result.Phase == v1.JobPhase_PHASE_CLEANUP {
+				fmt.Printf("stopped %s: %s (%s)\n", name, resp.Result.Phase, resp.Result.Details)
+				return nil
+			}
+			time.Sleep(500 * time.Millisecond)
+		}
+		fmt.Printf("requested %s to stop\n", name)
+		return nil
+	},
+}
+
+// stopJobs stops all jobs matching the request's filter and prints what it did. It fails if any job could not be stopped.
+func stopJobs(ctx context.Context, client v1.WerftServiceClient, req *v1.StopJobsRequest) error {
+	resp, err := client.StopJobs(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	if req.DryRun {
+		for _, name := range resp.Stopped {
+			fmt.Printf("would stop %s\n", name)
+		}
+		for _, f := range resp.Failed {
+			fmt.Printf("cannot stop %s: %s\n", f.Name, f.Error)
+		}
+		fmt.Printf("%d jobs would be stopped - use --yes to stop them\n", len(resp.Stopped))
+		return nil
+	}
+
+	for _, name := range resp.Stopped {
+		fmt.Printf("stopped %s\n", name)
+	}
+	for _, name := range resp.Skipped {
+		fmt.Printf("skipped %s: job is done already\n", name)
+	}
+	for _, f := range resp.Failed {
+		fmt.Printf("cannot stop %s: %s\n", f.Name, f.Error)
+	}
+	fmt.Printf("stopped %d jobs, skipped %d, failed %d\n", len(resp.Stopped), len(resp.Skipped), len(resp.Failed))
+	if len(resp.Failed) > 0 {
+		return xerrors.Errorf("cannot stop %d jobs", len(resp.Failed))
+	}
+	return nil
+}
+
+func init() {
+	jobCmd.AddCommand(jobStopCmd)
+
+	jobStopCmd.Flags().String("reason", "", "explains why the job was stopped - shows up in the job's details")
+	jobStopCmd.Flags().StringArray("filter", nil, "stops all running jobs matching the filter expression (can be repeated)")
+	jobStopCmd.Flags().Bool("yes", false, "stops the jobs matching --filter instead of listing them")
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"google.golang.org/grpc"
+)
+
+type stopJobsServer struct {
+	v1.UnimplementedWerftServiceServer
+	Resp *v1.StopJobsResponse
+	Reqs []*v1.StopJobsRequest
+}
+
+func (s *stopJobsServer) StopJobs(ctx context.Context, req *v1.StopJobsRequest) (*v1.StopJobsResponse, error) {
+	s.Reqs = append(s.Reqs, req)
+	return s.Resp, nil
+}
+
+func TestStopJobs(t *testing.T) {
+	tests := []struct {
+		Name   string
+		DryRun bool
+		Resp   *v1.StopJobsResponse
+		Error  bool
+	}{
+		{Name: "dry run", DryRun: true, Resp: &v1.StopJobsResponse{Stopped: []string{"werft.1"}}},
+		{Name: "stopped", Resp: &v1.StopJobsResponse{Stopped: []string{"werft.1"}, Skipped: []string{"werft.2"}}},
+		{Name: "failed", Resp: &v1.StopJobsResponse{Stopped: []string{"werft.1"}, Failed: []*v1.StopJobsFailure{{Name: "werft.2", Error: "permission denied"}}}, Error: true},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			fakeServer := &stopJobsServer{Resp: test.Resp}
+			srv := grpc.NewServer()
+			v1.RegisterWerftServiceServer(srv, fakeServer)
+			go srv.Serve(l)
+			defer srv.Stop()
+
+			conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			req := &v1.StopJobsRequest{
+				Filter: []*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "repo.repo", Value: "werft"}}}},
+				DryRun: test.DryRun,
+			}
+			err = stopJobs(context.Background(), v1.NewWerftServiceClient(conn), req)
+			if test.Error && err == nil {
+				t.Error("expected an error")
+			} else if !test.Error && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if len(fakeServer.Reqs) != 1 || fakeServer.Reqs[0].DryRun != test.DryRun {
+				t.Errorf("unexpected requests: %v", fakeServer.Reqs)
+			}
+		})
+	}
+}
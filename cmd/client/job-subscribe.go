@@ -0,0 +1,78 @@
+package cmd
+
+// Copyright © 2019 Christian Weichel
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/filterexpr"
+	"github.com/spf13/cobra"
+)
+
+const jobSubscribeTpl = `{{ .Name }}	{{ .Phase }}	{{ .Conditions.Success }}	{{ .Details }}
+`
+
+// jobSubscribeCmd represents the subscribe command
+var jobSubscribeCmd = &cobra.Command{
+	Use:   "subscribe [filter...]",
+	Short: "Prints job status updates as they happen",
+	Long: `Prints job status updates as they happen. Only updates of jobs matching the filter are printed.
+The filter uses the same expressions as "werft job list" - see "werft job list --help" for details.
+
+Use -o json to produce machine-readable output.`,
+	ValidArgsFunction: completeFilterExpr,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter, err := filterexpr.ParseExpressions(args)
+		if err != nil {
+			return err
+		}
+
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		client := v1.NewWerftServiceClient(conn)
+
+		sub, err := client.Subscribe(cliContext, &v1.SubscribeRequest{Filter: filter})
+		if err != nil {
+			return err
+		}
+		for {
+			resp, err := sub.Recv()
+			if cliContext.Err() != nil {
+				// the user hit Ctrl-C
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			err = prettyPrint(resp.Result, jobSubscribeTpl)
+			if err != nil {
+				return err
+			}
+		}
+	},
+}
+
+func init() {
+	jobCmd.AddCommand(jobSubscribeCmd)
+}
@@ -0,0 +1,104 @@
+package cmd
+
+// Copyright © 2019 Christian Weichel
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/werft"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+// jobValidateCmd represents the validate command
+var jobValidateCmd = &cobra.Command{
+	Use:   "validate <file>",
+	Short: "Checks a job spec without starting a job",
+	Long: `Checks a job spec without starting a job. The job spec is rendered like for a job started from the
+current working directory, which must be the root of the repository if the job spec extends templates
+or references an env file.
+
+All problems werft would find when starting the job are listed, e.g. malformed YAML, invalid resource
+quantities, timeouts or conditions. With --strict, keys werft does not know - which it ignores otherwise - are
+problems as well.
+
+For example:
+  werft job validate .werft/build.yaml --strict
+  werft job validate .werft/release.yaml --annotation version=1.0.0 --trigger manual`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jobYAML, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			return xerrors.Errorf("cannot read job file: %w", err)
+		}
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		jobYAML, err = bundleLocalJobSpec(wd, jobYAML)
+		if err != nil {
+			return err
+		}
+
+		triggerName, _ := cmd.Flags().GetString("trigger")
+		trigger, ok := v1.JobTrigger_value[fmt.Sprintf("TRIGGER_%s", strings.ToUpper(triggerName))]
+		if !ok {
+			return xerrors.Errorf("invalid value for --trigger: %s", triggerName)
+		}
+		md, err := getLocalJobContext(wd, v1.JobTrigger(trigger))
+		if err != nil {
+			log.WithError(err).Debug("cannot extract local job context - continuing with sample metadata")
+			md = &v1.JobMetadata{
+				Owner:      "werft",
+				Repository: &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: "refs/heads/main"},
+				Trigger:    v1.JobTrigger(trigger),
+			}
+		}
+		annotations, _ := cmd.Flags().GetStringToString("annotation")
+		for k, v := range annotations {
+			md.Annotations = append(md.Annotations, &v1.Annotation{Key: k, Value: v})
+		}
+
+		strict, _ := cmd.Flags().GetBool("strict")
+		errs := werft.ValidateJobSpec(jobYAML, md, strict)
+		if len(errs) == 0 {
+			fmt.Printf("%s is valid\n", args[0])
+			return nil
+		}
+		for _, err := range errs {
+			fmt.Printf("%s: %v\n", args[0], err)
+		}
+		return xerrors.Errorf("%s is invalid", args[0])
+	},
+}
+
+func init() {
+	jobCmd.AddCommand(jobValidateCmd)
+
+	jobValidateCmd.Flags().Bool("strict", false, "rejects keys werft does not know")
+	jobValidateCmd.Flags().String("trigger", "push", "trigger of the job the spec is rendered for. One of push, manual")
+	jobValidateCmd.Flags().StringToStringP("annotation", "a", map[string]string{}, "adds an annotation to the job the spec is rendered for, e.g. --annotation key=value. Can be used multiple times")
+}
@@ -46,12 +46,20 @@ var jobCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(jobCmd)
 
-	jobCmd.PersistentFlags().StringVarP(&outputFormat, "output-format", "o", "template", "selects the output format: string, json, yaml, template")
-	jobCmd.PersistentFlags().StringVar(&outputTemplate, "output-template", "", "template to use in combination with --output-format template")
+	jobCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "template", "selects the output format: string, json, yaml, template (or tpl), wide, csv (job list only)")
+	jobCmd.PersistentFlags().StringVar(&outputFormat, "output-format", "template", "selects the output format: string, json, yaml, template")
+	jobCmd.PersistentFlags().MarkDeprecated("output-format", "use --output instead")
+	jobCmd.RegisterFlagCompletionFunc("output", completeOutputFormat)
+	jobCmd.PersistentFlags().StringVar(&outputTemplate, "output-template", "", "template to use in combination with --output template")
 }
 
+// prettyPrint prints obj in the format selected using --output. All read commands should use
+// this function to produce their output.
 func prettyPrint(obj proto.Message, defaultTpl string) error {
 	format := prettyprint.Format(outputFormat)
+	if format == "" || format == "tpl" || format == "wide" {
+		format = prettyprint.TemplateFormat
+	}
 	if !prettyprint.HasFormat(format) {
 		return xerrors.Errorf("format %s is not supported", format)
 	}
@@ -66,6 +74,9 @@ func prettyPrint(obj proto.Message, defaultTpl string) error {
 		Format:   format,
 		Writer:   os.Stdout,
 		Template: tpl,
+		Funcs: map[string]interface{}{
+			"colorByPhase": colorByPhase(colorEnabled()),
+		},
 	}
 	return ctnt.Print()
 }
@@ -0,0 +1,63 @@
+package cmd
+
+// Copyright © 2019 Christian Weichel
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var logErrorCmd = &cobra.Command{
+	Use:   "error <message>",
+	Short: "logs an error marker which integrations can attach to a file and line",
+	Long: `Logs an error marker in the form "werft error <file>:<line>: <message>".
+Integrations such as the GitHub checks support turn those markers into inline annotations.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		msg := args[0]
+		slice, _ := cmd.Flags().GetString("slice")
+		file, _ := cmd.Flags().GetString("file")
+		line, _ := cmd.Flags().GetInt("line")
+
+		var prefix string
+		if slice != "" {
+			prefix = fmt.Sprintf("[%s] ", slice)
+		}
+
+		if file == "" {
+			fmt.Printf("%swerft error %s\n", prefix, msg)
+			return
+		}
+		if line < 1 {
+			line = 1
+		}
+		fmt.Printf("%swerft error %s:%d: %s\n", prefix, file, line, msg)
+	},
+}
+
+func init() {
+	logCmd.AddCommand(logErrorCmd)
+
+	logErrorCmd.Flags().StringP("slice", "s", "", "slice the error belongs to")
+	logErrorCmd.Flags().StringP("file", "f", "", "file the error refers to, relative to the repository root")
+	logErrorCmd.Flags().IntP("line", "l", 0, "line the error refers to - defaults to the first line if a file is given")
+}
@@ -0,0 +1,64 @@
+package cmd
+
+// Copyright © 2019 Christian Weichel
+
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+import (
+	"fmt"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/spf13/cobra"
+)
+
+// pipelineRetryCmd represents the pipeline retry command
+var pipelineRetryCmd = &cobra.Command{
+	Use:   "retry <parent>",
+	Short: "Restarts the failed jobs of a matrix job",
+	Long: `Restarts the failed jobs of a matrix job with the job spec, revision and annotations they originally ran with.
+Jobs which succeeded are kept. The matrix job runs again until the restarted jobs are done, and succeeds if they do.
+
+For example:
+  werft pipeline retry werft-build-main.12`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		client := v1.NewWerftServiceClient(conn)
+		ctx, cancel := rpcContext()
+		defer cancel()
+
+		resp, err := client.RetryPipeline(ctx, &v1.RetryPipelineRequest{Parent: args[0]})
+		if err != nil {
+			return err
+		}
+		for _, r := range resp.Retried {
+			fmt.Printf("retrying %s as %s\n", r.PreviousJob, r.Name)
+		}
+		fmt.Printf("%s: %s (%s)\n", resp.Status.Name, resp.Status.Phase, resp.Status.Details)
+		return nil
+	},
+}
+
+func init() {
+	pipelineCmd.AddCommand(pipelineRetryCmd)
+}
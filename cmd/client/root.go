@@ -23,19 +23,28 @@ package cmd
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
+	"github.com/csweichel/werft/pkg/auth"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -57,6 +66,13 @@ var rootCmdOpts struct {
 	K8sLabelSelector string
 	K8sPodPort       string
 	DialMode         string
+	TLSCert          string
+	TLSInsecure      bool
+	TLSClientCert    string
+	TLSClientKey     string
+	Token            string
+	Timeout          time.Duration
+	NoColor          bool
 }
 
 // rootCmd represents the base command when called without any subcommands
@@ -68,9 +84,30 @@ var rootCmd = &cobra.Command{
 			log.SetLevel(log.DebugLevel)
 			log.Debug("verbose logging enabled")
 		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sigChan
+			cancel()
+
+			// if the command does not react to the cancellation, a second signal forces the exit
+			<-sigChan
+			os.Exit(130)
+		}()
+		cliContext = ctx
 	},
 }
 
+// cliContext is cancelled when the user hits Ctrl-C. All RPCs should derive their context from it.
+var cliContext = context.Background()
+
+// rpcContext returns a context for a single, non-streaming RPC which honours the --timeout flag
+func rpcContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(cliContext, rootCmdOpts.Timeout)
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -117,7 +154,14 @@ func init() {
 
 	rootCmd.PersistentFlags().BoolVar(&rootCmdOpts.Verbose, "verbose", false, "en/disable verbose logging")
 	rootCmd.PersistentFlags().StringVar(&rootCmdOpts.DialMode, "dial-mode", dialMode, "dial mode that determines how we connect to werft. Valid values are \"host\" or \"kubernetes\" (defaults to WERFT_DIAL_MODE env var).")
-	rootCmd.PersistentFlags().StringVar(&rootCmdOpts.Host, "host", werftHost, "[host dial mode] werft host to talk to (defaults to WERFT_HOST env var)")
+	rootCmd.PersistentFlags().StringVar(&rootCmdOpts.Host, "host", werftHost, "[host dial mode] werft host to talk to, either host:port, grpc://host:port or grpcs://host:port for TLS (defaults to WERFT_HOST env var)")
+	rootCmd.PersistentFlags().BoolVar(&rootCmdOpts.NoColor, "no-color", false, "do not color the output, even if it goes to a terminal. Setting the NO_COLOR env var has the same effect")
+	rootCmd.PersistentFlags().DurationVar(&rootCmdOpts.Timeout, "timeout", 30*time.Second, "timeout for connecting to werft and for each request. Does not limit the duration of streams, e.g. when following logs")
+	rootCmd.PersistentFlags().StringVar(&rootCmdOpts.TLSCert, "tls-cert", os.Getenv("WERFT_TLS_CERT"), "[host dial mode] CA certificate (PEM) used to verify the werft server when connecting using grpcs:// (defaults to WERFT_TLS_CERT env var)")
+	rootCmd.PersistentFlags().BoolVar(&rootCmdOpts.TLSInsecure, "insecure", false, "[host dial mode] do not verify the werft server's certificate when connecting using grpcs://")
+	rootCmd.PersistentFlags().StringVar(&rootCmdOpts.TLSClientCert, "tls-client-cert", os.Getenv("WERFT_TLS_CLIENT_CERT"), "[host dial mode] client certificate (PEM) presented to werft servers which require mutual TLS (defaults to WERFT_TLS_CLIENT_CERT env var)")
+	rootCmd.PersistentFlags().StringVar(&rootCmdOpts.TLSClientKey, "tls-client-key", os.Getenv("WERFT_TLS_CLIENT_KEY"), "[host dial mode] private key (PEM) of the client certificate (defaults to WERFT_TLS_CLIENT_KEY env var)")
+	rootCmd.PersistentFlags().StringVar(&rootCmdOpts.Token, "token", os.Getenv("WERFT_TOKEN"), "bearer token to authenticate with if the werft server requires auth (defaults to WERFT_TOKEN env var)")
 	rootCmd.PersistentFlags().StringVar(&rootCmdOpts.Kubeconfig, "kubeconfig", werftKubeconfig, "[kubernetes dial mode] kubeconfig file to use (defaults to KUEBCONFIG env var)")
 	rootCmd.PersistentFlags().StringVar(&rootCmdOpts.K8sNamespace, "k8s-namespace", werftNamespace, "[kubernetes dial mode] Kubernetes namespace in which to look for the werft pods (defaults to WERFT_K8S_NAMESPACE env var, or configured kube context namespace)")
 	// The following are such specific flags that really only matters if one doesn't use the stock helm charts.
@@ -131,21 +175,133 @@ type closableGrpcClientConnInterface interface {
 	io.Closer
 }
 
-func dial() (res closableGrpcClientConnInterface) {
-	var err error
+func dial() (res closableGrpcClientConnInterface, err error) {
 	switch rootCmdOpts.DialMode {
 	case dialModeHost:
-		res, err = grpc.Dial(rootCmdOpts.Host, grpc.WithInsecure())
+		res, err = dialHost()
 	case dialModeKubernetes:
 		res, err = dialKubernetes()
 	default:
-		log.Fatalf("unknown dial mode: %s", rootCmdOpts.DialMode)
+		return nil, xerrors.Errorf("unknown dial mode: %s", rootCmdOpts.DialMode)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("cannot connect to werft server: %w", err)
 	}
+	return res, nil
+}
 
+// parseHost parses a werft host which can either be a plain host:port, or a URL
+// using the grpc:// (plain text) or grpcs:// (TLS) scheme.
+func parseHost(host string) (addr string, useTLS bool, err error) {
+	if !strings.Contains(host, "://") {
+		if host == "" {
+			return "", false, xerrors.Errorf("host must not be empty")
+		}
+		return host, false, nil
+	}
+
+	u, err := url.Parse(host)
 	if err != nil {
-		log.WithError(err).Fatal("cannot connect to werft server")
+		return "", false, xerrors.Errorf("invalid host %s: %w", host, err)
 	}
-	return
+	switch u.Scheme {
+	case "grpc":
+	case "grpcs":
+		useTLS = true
+	default:
+		return "", false, xerrors.Errorf("unsupported scheme %s: must be grpc or grpcs", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", false, xerrors.Errorf("invalid host %s: missing host", host)
+	}
+
+	addr = u.Host
+	if u.Port() == "" {
+		if useTLS {
+			addr += ":443"
+		} else {
+			addr += ":7777"
+		}
+	}
+	return addr, useTLS, nil
+}
+
+func dialHost() (*grpc.ClientConn, error) {
+	addr, useTLS, err := parseHost(rootCmdOpts.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		opts      = []grpc.DialOption{grpc.WithBlock()}
+		tlsConfig *tls.Config
+	)
+	if useTLS {
+		tlsConfig = &tls.Config{
+			InsecureSkipVerify: rootCmdOpts.TLSInsecure,
+		}
+		if rootCmdOpts.TLSCert != "" {
+			cert, err := ioutil.ReadFile(rootCmdOpts.TLSCert)
+			if err != nil {
+				return nil, xerrors.Errorf("cannot read TLS certificate: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(cert) {
+				return nil, xerrors.Errorf("cannot load TLS certificate from %s", rootCmdOpts.TLSCert)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if rootCmdOpts.TLSClientCert != "" || rootCmdOpts.TLSClientKey != "" {
+			if rootCmdOpts.TLSClientCert == "" || rootCmdOpts.TLSClientKey == "" {
+				return nil, xerrors.Errorf("--tls-client-cert and --tls-client-key must be used together")
+			}
+			cert, err := tls.LoadX509KeyPair(rootCmdOpts.TLSClientCert, rootCmdOpts.TLSClientKey)
+			if err != nil {
+				return nil, xerrors.Errorf("cannot load TLS client certificate: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else if rootCmdOpts.TLSClientCert != "" {
+		return nil, xerrors.Errorf("--tls-client-cert requires a grpcs:// host")
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	opts = append(opts, authDialOptions()...)
+
+	ctx, cancel := context.WithTimeout(cliContext, rootCmdOpts.Timeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, addr, opts...)
+	if err != nil {
+		if tlsConfig != nil {
+			if herr := tlsHandshake(addr, tlsConfig); herr != nil {
+				return nil, xerrors.Errorf("cannot dial %s: TLS handshake failed: %w", addr, herr)
+			}
+		}
+		return nil, xerrors.Errorf("cannot dial %s: %w", addr, err)
+	}
+	return conn, nil
+}
+
+// tlsHandshake returns the error of a TLS handshake with the server. If the server rejects our client
+// certificate gRPC only tells us that the connection was closed, because with TLS 1.3 the server sends
+// its alert after the client considers the handshake done. Hence we read from the connection to receive the alert.
+func tlsHandshake(addr string, cfg *tls.Config) error {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: rootCmdOpts.Timeout}, "tcp", addr, cfg.Clone())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+		return nil
+	}
+	if err == io.EOF {
+		return nil
+	}
+	return err
 }
 
 func dialKubernetes() (closableGrpcClientConnInterface, error) {
@@ -178,7 +334,8 @@ func dialKubernetes() (closableGrpcClientConnInterface, error) {
 	case <-readychan:
 	}
 
-	res, err := grpc.Dial(fmt.Sprintf("localhost:%d", localPort), grpc.WithInsecure())
+	opts := append([]grpc.DialOption{grpc.WithInsecure()}, authDialOptions()...)
+	res, err := grpc.Dial(fmt.Sprintf("localhost:%d", localPort), opts...)
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("cannot dial forwarded connection: %w", err)
@@ -190,6 +347,17 @@ func dialKubernetes() (closableGrpcClientConnInterface, error) {
 	}, nil
 }
 
+// authDialOptions adds the --token to all calls
+func authDialOptions() []grpc.DialOption {
+	if rootCmdOpts.Token == "" {
+		return nil
+	}
+	return []grpc.DialOption{
+		grpc.WithUnaryInterceptor(auth.UnaryClientInterceptor(rootCmdOpts.Token)),
+		grpc.WithStreamInterceptor(auth.StreamClientInterceptor(rootCmdOpts.Token)),
+	}
+}
+
 type closableConn struct {
 	grpc.ClientConnInterface
 	Closer func() error
@@ -0,0 +1,277 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseHost(t *testing.T) {
+	tests := []struct {
+		Input string
+		Addr  string
+		TLS   bool
+		Error string
+	}{
+		{Input: "localhost:7777", Addr: "localhost:7777"},
+		{Input: "werft.example.com:8080", Addr: "werft.example.com:8080"},
+		{Input: "grpc://werft.example.com:8080", Addr: "werft.example.com:8080"},
+		{Input: "grpc://werft.example.com", Addr: "werft.example.com:7777"},
+		{Input: "grpcs://werft.example.com", Addr: "werft.example.com:443", TLS: true},
+		{Input: "grpcs://werft.example.com:9443", Addr: "werft.example.com:9443", TLS: true},
+		{Input: "http://werft.example.com", Error: "unsupported scheme http: must be grpc or grpcs"},
+		{Input: "grpcs://", Error: "invalid host grpcs://: missing host"},
+		{Input: "", Error: "host must not be empty"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			addr, useTLS, err := parseHost(test.Input)
+			if err != nil {
+				if err.Error() != test.Error {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if test.Error != "" {
+				t.Errorf("expected error %q but got none", test.Error)
+				return
+			}
+			if addr != test.Addr {
+				t.Errorf("expected address %s but got %s", test.Addr, addr)
+			}
+			if useTLS != test.TLS {
+				t.Errorf("expected TLS %v but got %v", test.TLS, useTLS)
+			}
+		})
+	}
+}
+
+type slowWerftServer struct {
+	v1.UnimplementedWerftServiceServer
+	Delay time.Duration
+}
+
+func (s *slowWerftServer) ListJobs(ctx context.Context, req *v1.ListJobsRequest) (*v1.ListJobsResponse, error) {
+	select {
+	case <-time.After(s.Delay):
+	case <-ctx.Done():
+	}
+	return &v1.ListJobsResponse{}, nil
+}
+
+func TestRPCTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	v1.RegisterWerftServiceServer(srv, &slowWerftServer{Delay: 5 * time.Second})
+	go srv.Serve(l)
+	defer srv.Stop()
+
+	oldOpts := rootCmdOpts
+	defer func() { rootCmdOpts = oldOpts }()
+	rootCmdOpts.DialMode = dialModeHost
+	rootCmdOpts.Host = l.Addr().String()
+	rootCmdOpts.Timeout = 100 * time.Millisecond
+
+	conn, err := dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := rpcContext()
+	defer cancel()
+	_, err = v1.NewWerftServiceClient(conn).ListJobs(ctx, &v1.ListJobsRequest{})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded error but got %v", err)
+	}
+}
+
+func TestDialToken(t *testing.T) {
+	interceptor, err := auth.NewInterceptor(auth.Config{Tokens: map[string]string{"ci": "secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(grpc.UnaryInterceptor(interceptor.UnaryServerInterceptor))
+	v1.RegisterWerftServiceServer(srv, &slowWerftServer{})
+	go srv.Serve(l)
+	defer srv.Stop()
+
+	oldOpts := rootCmdOpts
+	defer func() { rootCmdOpts = oldOpts }()
+	rootCmdOpts.DialMode = dialModeHost
+	rootCmdOpts.Host = l.Addr().String()
+	rootCmdOpts.Timeout = 5 * time.Second
+
+	for token, code := range map[string]codes.Code{"": codes.Unauthenticated, "guess": codes.Unauthenticated, "secret": codes.OK} {
+		rootCmdOpts.Token = token
+		conn, err := dial()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := rpcContext()
+		_, err = v1.NewWerftServiceClient(conn).ListJobs(ctx, &v1.ListJobsRequest{})
+		cancel()
+		conn.Close()
+		if status.Code(err) != code {
+			t.Errorf("token %q: expected %v but got %v", token, code, err)
+		}
+	}
+}
+
+func TestDialMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "werft-mtls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, dir, "ca", nil)
+	otherCA := newTestCert(t, dir, "other-ca", nil)
+	server := newTestCert(t, dir, "server", ca)
+	client := newTestCert(t, dir, "client", ca)
+	untrusted := newTestCert(t, dir, "untrusted", otherCA)
+
+	creds, err := auth.ServerCredentials(auth.TLSConfig{Cert: server.Cert, Key: server.Key, ClientCA: ca.Cert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(grpc.Creds(creds))
+	v1.RegisterWerftServiceServer(srv, &slowWerftServer{})
+	go srv.Serve(l)
+	defer srv.Stop()
+
+	tests := []struct {
+		Name   string
+		Client *testCert
+		Error  string
+	}{
+		{Name: "no client cert", Error: "certificate required"},
+		// Go clients don't present certificates which aren't signed by one of the CAs the server asks for
+		{Name: "untrusted client cert", Client: untrusted, Error: "certificate required"},
+		{Name: "valid client cert", Client: client},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			oldOpts := rootCmdOpts
+			defer func() { rootCmdOpts = oldOpts }()
+			rootCmdOpts.DialMode = dialModeHost
+			rootCmdOpts.Host = "grpcs://" + l.Addr().String()
+			rootCmdOpts.TLSCert = ca.Cert
+			rootCmdOpts.Timeout = time.Second
+			rootCmdOpts.TLSClientCert, rootCmdOpts.TLSClientKey = "", ""
+			if test.Client != nil {
+				rootCmdOpts.TLSClientCert, rootCmdOpts.TLSClientKey = test.Client.Cert, test.Client.Key
+			}
+
+			conn, err := dial()
+			if err == nil {
+				defer conn.Close()
+
+				ctx, cancel := rpcContext()
+				defer cancel()
+				_, err = v1.NewWerftServiceClient(conn).ListJobs(ctx, &v1.ListJobsRequest{})
+			}
+			if test.Error == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.Error) {
+				t.Errorf("expected error containing %q, got %v", test.Error, err)
+			}
+		})
+	}
+}
+
+type testCert struct {
+	Cert, Key string
+
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert creates a certificate for 127.0.0.1 signed by the parent, or a self-signed CA if parent is nil
+func newTestCert(t *testing.T, dir, name string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		tmpl.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+		tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, key.Public(), signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := &testCert{
+		Cert: filepath.Join(dir, name+".crt"),
+		Key:  filepath.Join(dir, name+".key"),
+		cert: cert,
+		key:  key,
+	}
+	err = ioutil.WriteFile(res.Cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(res.Key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
@@ -24,7 +24,6 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
-	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -47,19 +46,32 @@ const maxSideloadSizeBytes = 3 * 1024 * 1024
 var runGithubCmd = &cobra.Command{
 	Use:   "github [<owner>/<repo>(:ref | @revision)]",
 	Short: "starts a job from a remote repository",
-	Args:  cobra.MaximumNArgs(1),
+	Long: `Starts a job from a GitHub repository. Without argument this uses the repository and revision of the local
+working copy, which must be pushed to GitHub.
+
+Use --ref to start the job on any branch, tag or commit SHA, e.g.
+  werft run github csweichel/werft --ref v0.1.0 --annotation version=0.1.0
+werft resolves the revision the ref points to using the GitHub API.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		flags := cmd.Parent().PersistentFlags()
 		cwd, _ := flags.GetString("cwd")
 
+		ref, _ := cmd.Flags().GetString("ref")
+
 		var (
 			md  *v1.JobMetadata
 			err error
 		)
 		if len(args) == 0 {
 			md, err = getLocalJobContext(cwd, v1.JobTrigger_TRIGGER_MANUAL)
+			if err == nil && ref != "" {
+				// the local revision need not be the one ref points to on GitHub
+				md.Repository.Ref = ref
+				md.Repository.Revision = ""
+			}
 		} else {
-			repo, err := reporef.Parse(args[0])
+			repo, err := parseGitHubRepo(args[0], ref)
 			if err != nil {
 				return err
 			}
@@ -124,11 +136,15 @@ var runGithubCmd = &cobra.Command{
 			}
 		}
 
-		conn := dial()
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
 		defer conn.Close()
 		client := v1.NewWerftServiceClient(conn)
 
-		ctx := context.Background()
+		ctx, cancel := rpcContext()
+		defer cancel()
 		resp, err := client.StartGitHubJob(ctx, req)
 		if err != nil {
 			if status.Code(err) == codes.NotFound {
@@ -152,6 +168,23 @@ var runGithubCmd = &cobra.Command{
 	},
 }
 
+// parseGitHubRepo parses a <owner>/<repo>(:ref | @revision) spec. If ref is not empty the job runs on that
+// branch, tag or commit SHA, whose revision werft resolves using the GitHub API.
+func parseGitHubRepo(spec, ref string) (*v1.Repository, error) {
+	repo, err := reporef.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	if ref == "" {
+		return repo, nil
+	}
+	if repo.Ref != "" || repo.Revision != "" {
+		return nil, xerrors.Errorf("--ref cannot be combined with :ref or @revision in %s", spec)
+	}
+	repo.Ref = ref
+	return repo, nil
+}
+
 func compileSideload(files []string) ([]byte, error) {
 	res := bytes.NewBuffer(nil)
 	gw := gzip.NewWriter(res)
@@ -215,5 +248,6 @@ func init() {
 
 	runGithubCmd.Flags().String("token", "", "Token to use for authorization against GitHub")
 	runGithubCmd.Flags().String("remote-job-path", "", "start the job at that path in the repo (defaults to the default job of the repo)")
+	runGithubCmd.Flags().String("ref", "", "branch, tag or commit SHA to run the job on (defaults to the ref of the local working copy)")
 	runGithubCmd.Flags().StringArrayP("sideload", "s", []string{}, "sideload files overwriting/adding to the Git working copy")
 }
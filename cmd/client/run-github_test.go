@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"google.golang.org/grpc"
+)
+
+func TestParseGitHubRepo(t *testing.T) {
+	tests := []struct {
+		Spec     string
+		Ref      string
+		Expected *v1.Repository
+		Error    string
+	}{
+		{Spec: "csweichel/werft", Expected: &v1.Repository{Owner: "csweichel", Repo: "werft"}},
+		{Spec: "csweichel/werft:main", Expected: &v1.Repository{Owner: "csweichel", Repo: "werft", Ref: "main"}},
+		{Spec: "csweichel/werft@c0ffee", Expected: &v1.Repository{Owner: "csweichel", Repo: "werft", Revision: "c0ffee"}},
+		{Spec: "csweichel/werft", Ref: "v0.1.0", Expected: &v1.Repository{Owner: "csweichel", Repo: "werft", Ref: "v0.1.0"}},
+		{Spec: "github.com/csweichel/werft", Ref: "c0ffee", Expected: &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: "c0ffee"}},
+		{Spec: "csweichel/werft:main", Ref: "v0.1.0", Error: "--ref cannot be combined with :ref or @revision in csweichel/werft:main"},
+		{Spec: "csweichel/werft@c0ffee", Ref: "v0.1.0", Error: "--ref cannot be combined with :ref or @revision in csweichel/werft@c0ffee"},
+		{Spec: "werft", Ref: "main", Error: "invalid repository spec"},
+	}
+	for _, test := range tests {
+		t.Run(test.Spec+"/"+test.Ref, func(t *testing.T) {
+			act, err := parseGitHubRepo(test.Spec, test.Ref)
+			if test.Error != "" {
+				if err == nil || err.Error() != test.Error {
+					t.Fatalf("expected error %q, got %v", test.Error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(act, test.Expected) {
+				t.Errorf("unexpected repository:\n\texpected %v\n\tgot      %v", test.Expected, act)
+			}
+		})
+	}
+}
+
+type startGitHubJobServer struct {
+	v1.UnimplementedWerftServiceServer
+	Req *v1.StartGitHubJobRequest
+}
+
+func (s *startGitHubJobServer) StartGitHubJob(ctx context.Context, req *v1.StartGitHubJobRequest) (*v1.StartJobResponse, error) {
+	s.Req = req
+	return &v1.StartJobResponse{Status: &v1.JobStatus{Name: "werft-build-v0.1.0.1"}}, nil
+}
+
+func TestRunGithub(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeServer := &startGitHubJobServer{}
+	srv := grpc.NewServer()
+	v1.RegisterWerftServiceServer(srv, fakeServer)
+	go srv.Serve(l)
+	defer srv.Stop()
+
+	rootCmd.SetArgs([]string{
+		"--dial-mode", dialModeHost, "--host", l.Addr().String(),
+		"run", "github", "csweichel/werft",
+		"--ref", "v0.1.0",
+		"--trigger", "manual",
+		"--annotation", "version=0.1.0",
+		"--annotation", "release=true",
+		"-a", "notify=slack",
+	})
+	defer rootCmd.SetArgs(nil)
+	err = rootCmd.Execute()
+	if err != nil {
+		t.Fatalf("cannot run github job: %v", err)
+	}
+
+	req := fakeServer.Req
+	if req == nil {
+		t.Fatal("StartGitHubJob was not called")
+	}
+	annotations := req.Metadata.Annotations
+	sort.Slice(annotations, func(i, j int) bool { return strings.Compare(annotations[i].Key, annotations[j].Key) < 0 })
+	expected := &v1.JobMetadata{
+		Owner:      "csweichel",
+		Repository: &v1.Repository{Owner: "csweichel", Repo: "werft", Ref: "v0.1.0"},
+		Trigger:    v1.JobTrigger_TRIGGER_MANUAL,
+		Annotations: []*v1.Annotation{
+			{Key: "notify", Value: "slack"},
+			{Key: "release", Value: "true"},
+			{Key: "version", Value: "0.1.0"},
+		},
+	}
+	if !reflect.DeepEqual(req.Metadata, expected) {
+		t.Errorf("unexpected metadata:\n\texpected %v\n\tgot      %v", expected, req.Metadata)
+	}
+}
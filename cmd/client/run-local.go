@@ -21,26 +21,32 @@ package cmd
 // THE SOFTWARE.
 
 import (
-	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/csweichel/werft/pkg/api/repoconfig"
 	v1 "github.com/csweichel/werft/pkg/api/v1"
 	"github.com/paulbellamy/ratecounter"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v3"
 )
 
 // runLocalCmd represents the triggerLocal command
 var runLocalCmd = &cobra.Command{
-	Use:   "local",
+	Use:   "local [path]",
 	Short: "starts a job from a local directory",
+	Long: `Starts a job from a local directory, which defaults to the current working directory.
+
+The directory is uploaded as workspace of the job. Files ignored by .gitignore or .werftignore files are not
+uploaded, and .werftignore can re-include files using !. The log of the job is printed unless --follow=false.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if wu, _ := getWaitUntil(); wu != nil {
 			return xerrors.Errorf("--wait-until is not supported for local jobs")
@@ -48,6 +54,10 @@ var runLocalCmd = &cobra.Command{
 
 		flags := cmd.Parent().PersistentFlags()
 		workingdir, _ := cmd.Flags().GetString("cwd")
+		if len(args) > 0 {
+			workingdir = args[0]
+		}
+		maxUploadSize, _ := cmd.Flags().GetInt64("max-upload-size")
 		triggerName, _ := flags.GetString("trigger")
 		trigger, ok := v1.JobTrigger_value[fmt.Sprintf("TRIGGER_%s", strings.ToUpper(triggerName))]
 		if !ok {
@@ -76,19 +86,39 @@ var runLocalCmd = &cobra.Command{
 		var configYAML []byte
 		jobPath, _ := cmd.Flags().GetString("job-file")
 		if jobPath == "" {
-			return xerrors.Errorf("missing job file")
+			configYAML, err = ioutil.ReadFile(filepath.Join(workingdir, ".werft", "config.yaml"))
+			if err != nil {
+				return xerrors.Errorf("missing job file and cannot read the repo's config: %w", err)
+			}
+			var repoCfg repoconfig.C
+			err = yaml.Unmarshal(configYAML, &repoCfg)
+			if err != nil {
+				return xerrors.Errorf("cannot unmarshal .werft/config.yaml: %w", err)
+			}
+			jobPath = repoCfg.TemplatePath(md)
+			if jobPath == "" {
+				return xerrors.Errorf("missing job file and the repo has no default job")
+			}
+			jobPath = filepath.Join(workingdir, jobPath)
 		}
 		jobYAML, err := ioutil.ReadFile(jobPath)
 		if err != nil {
 			return xerrors.Errorf("cannot read job file: %w", err)
 		}
+		jobYAML, err = bundleLocalJobSpec(workingdir, jobYAML)
+		if err != nil {
+			return err
+		}
 
-		conn := dial()
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
 		defer conn.Close()
 		client := v1.NewWerftServiceClient(conn)
 
-		ctx := context.Background()
-		srv, err := client.StartLocalJob(ctx)
+		// uploading the workspace can take a while, hence we don't limit this stream using --timeout
+		srv, err := client.StartLocalJob(cliContext)
 		if err != nil {
 			return xerrors.Errorf("cannot start job: %w", err)
 		}
@@ -118,26 +148,18 @@ var runLocalCmd = &cobra.Command{
 			return xerrors.Errorf("cannot send job yaml: %w", err)
 		}
 
-		tarcmd := exec.Command("tar", "cz", ".")
-		tarcmd.Dir = workingdir
-		tarStream, err := tarcmd.StdoutPipe()
-		tarcmd.Stderr = os.Stderr
-		if err != nil {
-			return xerrors.Errorf("cannot setup tar: %w", err)
-		}
-		err = tarcmd.Start()
-		if err != nil {
-			return xerrors.Errorf("cannot start tar process: %w", err)
-		}
+		tarStream, tarOut := io.Pipe()
+		go func() {
+			tarOut.CloseWithError(packWorkspace(tarOut, workingdir, maxUploadSize*mib))
+		}()
 
 		buf := make([]byte, 32768)
 		total := 0
 		counter := ratecounter.NewRateCounter(1 * time.Second)
-		const mib = 1024 * 1024
 		for {
 			n, err := tarStream.Read(buf)
 			if err != nil && err != io.EOF {
-				return xerrors.Errorf("cannot read tar data: %w", err)
+				return xerrors.Errorf("cannot pack workspace: %w", err)
 			}
 
 			if n > 0 {
@@ -179,6 +201,10 @@ var runLocalCmd = &cobra.Command{
 		fmt.Println(resp.Status.Name)
 
 		follow, _ := flags.GetBool("follow")
+		if !flags.Changed("follow") {
+			// local jobs are usually started to see what happens
+			follow = true
+		}
 		withPrefix, _ := flags.GetString("follow-with-prefix")
 		if follow || withPrefix != "" {
 			err = followJob(client, resp.Status.Name, withPrefix)
@@ -191,10 +217,39 @@ var runLocalCmd = &cobra.Command{
 	},
 }
 
+// bundleLocalJobSpec adds the templates the job spec extends, its env file and the default image of the repository
+// from the working directory to the job spec
+func bundleLocalJobSpec(workingdir string, jobYAML []byte) ([]byte, error) {
+	var repoCfg repoconfig.C
+	configYAML, err := ioutil.ReadFile(filepath.Join(workingdir, ".werft", "config.yaml"))
+	if err == nil {
+		err = yaml.Unmarshal(configYAML, &repoCfg)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot unmarshal .werft/config.yaml: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, xerrors.Errorf("cannot read .werft/config.yaml: %w", err)
+	}
+
+	if repoconfig.NeedsBundle(jobYAML) {
+		jobYAML, err = repoconfig.BundleTemplates(jobYAML, repoCfg.Templates, func(path string) ([]byte, error) {
+			return ioutil.ReadFile(filepath.Join(workingdir, filepath.FromSlash(path)))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if repoCfg.DefaultImage != "" {
+		jobYAML = repoconfig.BundleDefaultImage(jobYAML, repoCfg.DefaultImage)
+	}
+	return jobYAML, nil
+}
+
 func init() {
 	runCmd.AddCommand(runLocalCmd)
 
 	wd, _ := os.Getwd()
-	runLocalCmd.Flags().String("cwd", wd, "working directory")
+	runLocalCmd.Flags().String("cwd", wd, "working directory (deprecated: pass the path as argument instead)")
+	runLocalCmd.Flags().Int64("max-upload-size", 100, "maximum size of the compressed workspace in MiB (0 means no limit)")
 	runLocalCmd.Flags().StringP("job-file", "j", "", "start a particular job (defaults to the default job of the repo)")
 }
@@ -21,7 +21,6 @@ package cmd
 // THE SOFTWARE.
 
 import (
-	"context"
 	"fmt"
 
 	v1 "github.com/csweichel/werft/pkg/api/v1"
@@ -42,15 +41,16 @@ var runPreviousJobCmd = &cobra.Command{
 			return fmt.Errorf("--annotation is not supported when replaying a previous job")
 		}
 
-		conn := dial()
+		conn, err := dial()
+		if err != nil {
+			return err
+		}
 		defer conn.Close()
 		client := v1.NewWerftServiceClient(conn)
-		ctx := context.Background()
+		ctx, cancel := rpcContext()
+		defer cancel()
 
-		var (
-			name string
-			err  error
-		)
+		var name string
 		if len(args) == 0 {
 			name, err = findJobByLocalContext(ctx, client)
 			if err != nil {
@@ -31,6 +31,7 @@ import (
 	v1 "github.com/csweichel/werft/pkg/api/v1"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"golang.org/x/xerrors"
 )
 
@@ -150,7 +151,15 @@ func init() {
 	runCmd.PersistentFlags().String("config-file", "$CWD/.werft/config.yaml", "location of the werft config file")
 	runCmd.PersistentFlags().String("trigger", "manual", "job trigger. One of push, manual")
 	runCmd.PersistentFlags().BoolP("follow", "f", false, "follow the log output once the job is running")
-	runCmd.PersistentFlags().StringToStringP("annotations", "a", map[string]string{}, "adds an annotation to the job")
+	runCmd.PersistentFlags().StringToStringP("annotations", "a", map[string]string{}, "adds an annotation to the job, e.g. --annotation key=value. Can be used multiple times")
 	runCmd.PersistentFlags().String("follow-with-prefix", "", "prints the log output with a prefix and disbales colors - useful for starting jobs from within jobs")
 	runCmd.PersistentFlags().String("wait-until", "", "delays the execution of the job by/until some time - use a valid duration (e.g. 5h) or RFC3339 timestamp")
+
+	// --annotation reads better when adding several annotations one by one
+	runCmd.SetGlobalNormalizationFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "annotation" {
+			name = "annotations"
+		}
+		return pflag.NormalizedName(name)
+	})
 }
@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// ignoreFiles are the files listing what we don't upload as workspace of local jobs.
+// Rules of later files take precedence, i.e. .werftignore can re-include files git ignores.
+var ignoreFiles = []string{".gitignore", ".werftignore"}
+
+// ignoreRule is a single line of an ignore file
+type ignoreRule struct {
+	// base is the directory of the ignore file, relative to the workspace root
+	base     string
+	segments []string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// parseIgnoreFile reads the rules of an ignore file using the .gitignore syntax
+func parseIgnoreFile(fn, base string) ([]ignoreRule, error) {
+	f, err := os.Open(fn)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var res []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var r ignoreRule
+		r.base = base
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			// escapes a leading # or !
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		// patterns with a slash other than a trailing one are relative to the ignore file, all others match at any depth
+		r.anchored = strings.Contains(line, "/")
+		r.segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+		for _, s := range r.segments {
+			if _, err := path.Match(s, ""); err != nil {
+				return nil, xerrors.Errorf("%s: invalid pattern %q: %w", fn, line, err)
+			}
+		}
+		res = append(res, r)
+	}
+	return res, scanner.Err()
+}
+
+// matches returns true if the rule matches the slash-separated path relative to the workspace root
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = strings.TrimPrefix(rel, r.base+"/")
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.segments[0], path.Base(rel))
+		return ok
+	}
+	return matchIgnoreSegments(r.segments, strings.Split(rel, "/"))
+}
+
+// matchIgnoreSegments matches path segments against pattern segments where ** matches any number of segments
+func matchIgnoreSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchIgnoreSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return matchIgnoreSegments(pattern[1:], name[1:])
+}
+
+// isIgnored returns true if the last rule matching the path ignores it
+func isIgnored(rules []ignoreRule, rel string, isDir bool) bool {
+	var ignored bool
+	for _, r := range rules {
+		if r.matches(rel, isDir) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// errWorkspaceTooLarge is returned when the packed workspace exceeds the upload limit
+var errWorkspaceTooLarge = xerrors.New("workspace too large")
+
+// limitedWriter fails writes which would exceed the limit
+type limitedWriter struct {
+	W     io.Writer
+	Limit int64
+
+	written int64
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.Limit > 0 && w.written+int64(len(p)) > w.Limit {
+		return 0, errWorkspaceTooLarge
+	}
+	n, err := w.W.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// packWorkspace writes the directory as gzipped tarball to out. It leaves out files ignored by .gitignore or .werftignore
+// files, and fails with errWorkspaceTooLarge once the tarball exceeds limit bytes. A limit of zero disables the limit.
+func packWorkspace(out io.Writer, dir string, limit int64) error {
+	lw := &limitedWriter{W: out, Limit: limit}
+	gz := gzip.NewWriter(lw)
+	tw := tar.NewWriter(gz)
+
+	err := packDir(tw, dir, "", nil)
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if xerrors.Is(err, errWorkspaceTooLarge) {
+		return xerrors.Errorf("%w: the compressed workspace exceeds %d MiB", errWorkspaceTooLarge, limit/mib)
+	}
+	return err
+}
+
+const mib = 1024 * 1024
+
+func packDir(tw *tar.Writer, root, rel string, rules []ignoreRule) error {
+	for _, fn := range ignoreFiles {
+		rs, err := parseIgnoreFile(filepath.Join(root, filepath.FromSlash(rel), fn), rel)
+		if err != nil {
+			return err
+		}
+		rules = append(rules[:len(rules):len(rules)], rs...)
+	}
+
+	f, err := os.Open(filepath.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		return err
+	}
+	entries, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, fi := range entries {
+		name := path.Join(rel, fi.Name())
+		if isIgnored(rules, name, fi.IsDir()) {
+			continue
+		}
+
+		var link string
+		switch {
+		case fi.Mode().IsRegular(), fi.IsDir():
+		case fi.Mode()&os.ModeSymlink != 0:
+			link, err = os.Readlink(filepath.Join(root, filepath.FromSlash(name)))
+			if err != nil {
+				return err
+			}
+		default:
+			// sockets, devices and the like have no place in a workspace
+			continue
+		}
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+
+		if fi.IsDir() {
+			err = packDir(tw, root, name, rules)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		err = copyFile(tw, filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(out io.Writer, fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(out, f)
+	return err
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+func TestPackWorkspace(t *testing.T) {
+	files := map[string]string{
+		".gitignore":             "# build output\n*.log\n/bin/\nnode_modules/\n!keep.log\n",
+		".werftignore":           "docs/**/*.png\n!bin/\nsecret.txt\n",
+		"main.go":                "package main",
+		"build.log":              "ignored by *.log",
+		"keep.log":               "re-included by !keep.log",
+		"bin/werft":              "re-included by .werftignore",
+		"web/node_modules/a.js":  "ignored at any depth",
+		"web/index.js":           "kept",
+		"web/bin/tool":           "kept because /bin/ is anchored",
+		"docs/img/arch.png":      "ignored by docs/**/*.png",
+		"docs/readme.md":         "kept",
+		"sub/.gitignore":         "*.tmp\n/local\n",
+		"sub/a.tmp":              "ignored by the nested .gitignore",
+		"sub/local/x":            "ignored by the nested anchored rule",
+		"sub/nested/local/y":     "kept: the nested rule is anchored to sub",
+		"sub/nested/b.tmp":       "ignored by the nested .gitignore",
+		"other/a.tmp":            "kept: the nested .gitignore only applies to sub",
+		"secret.txt":             "ignored by .werftignore",
+		"deeply/nested/main.log": "ignored by *.log",
+	}
+	dir := writeWorkspace(t, files)
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	err := packWorkspace(&buf, dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		".gitignore",
+		".werftignore",
+		"bin/werft",
+		"docs/readme.md",
+		"keep.log",
+		"main.go",
+		"other/a.tmp",
+		"sub/.gitignore",
+		"sub/nested/local/y",
+		"web/bin/tool",
+		"web/index.js",
+	}
+	act := readTarFiles(t, &buf)
+	if !reflect.DeepEqual(act, expected) {
+		t.Errorf("unexpected files:\n\texpected %v\n\tgot      %v", expected, act)
+	}
+}
+
+func TestPackWorkspaceLimit(t *testing.T) {
+	// random data doesn't compress, hence the tarball is at least as large as the file
+	data := make([]byte, 2*mib)
+	_, err := rand.Read(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := writeWorkspace(t, map[string]string{"data.bin": string(data)})
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		Name  string
+		Limit int64
+		Err   bool
+	}{
+		{Name: "no limit", Limit: 0},
+		{Name: "below limit", Limit: 3 * mib},
+		{Name: "exceeds limit", Limit: 1 * mib, Err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := packWorkspace(&buf, dir, test.Limit)
+			if test.Err {
+				if !xerrors.Is(err, errWorkspaceTooLarge) {
+					t.Fatalf("expected errWorkspaceTooLarge, got %v", err)
+				}
+				if int64(buf.Len()) > test.Limit {
+					t.Errorf("wrote %d bytes which exceeds the limit", buf.Len())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if files := readTarFiles(t, &buf); !reflect.DeepEqual(files, []string{"data.bin"}) {
+				t.Errorf("unexpected files: %v", files)
+			}
+		})
+	}
+}
+
+func writeWorkspace(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "werft-workspace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for fn, content := range files {
+		fn = filepath.Join(dir, filepath.FromSlash(fn))
+		err = os.MkdirAll(filepath.Dir(fn), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(fn, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// readTarFiles returns the regular files of a gzipped tarball
+func readTarFiles(t *testing.T, in io.Reader) []string {
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+
+	var res []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			res = append(res, hdr.Name)
+		}
+	}
+	return res
+}
@@ -34,18 +34,27 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"path"
+	"path/filepath"
 	"runtime"
 	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
 	rice "github.com/GeertJohan/go.rice"
 	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/audit"
+	"github.com/csweichel/werft/pkg/auth"
 	"github.com/csweichel/werft/pkg/executor"
+	"github.com/csweichel/werft/pkg/health"
 	"github.com/csweichel/werft/pkg/logcutter"
 	plugin "github.com/csweichel/werft/pkg/plugin/host"
 	"github.com/csweichel/werft/pkg/store"
+	"github.com/csweichel/werft/pkg/store/gcs"
 	"github.com/csweichel/werft/pkg/store/postgres"
+	"github.com/csweichel/werft/pkg/store/s3"
+	"github.com/csweichel/werft/pkg/tracing"
 	"github.com/csweichel/werft/pkg/version"
 	"github.com/csweichel/werft/pkg/werft"
 	"github.com/improbable-eng/grpc-web/go/grpcweb"
@@ -55,9 +64,14 @@ import (
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	// registers the gzip compressor so that clients can ask for compressed responses, e.g. of the log stream
+	_ "google.golang.org/grpc/encoding/gzip"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
@@ -83,37 +97,18 @@ var runCmd = &cobra.Command{
 			return err
 		}
 
-		log.Info("connecting to database")
-		db, err := sql.Open("postgres", cfg.Storage.JobStore)
-		if err != nil {
-			return err
-		}
-		maxConns := 10
-		maxIdleConns := 2
-		if cfg.Storage.JobStoreMaxConnections > 0 {
-			maxConns = cfg.Storage.JobStoreMaxConnections
-		}
-		if cfg.Storage.JobStoreMaxIdleConnections > 0 {
-			maxIdleConns = cfg.Storage.JobStoreMaxIdleConnections
-		}
-		log.WithField("maxOpenConns", maxConns).WithField("maxIdleConns", maxIdleConns).Debug("setting max open connections on job store DB")
-		db.SetMaxOpenConns(maxConns)
-		db.SetMaxIdleConns(maxIdleConns)
-		err = db.Ping()
+		shutdownTracing, err := tracing.Setup(context.Background(), "werft")
 		if err != nil {
 			return err
 		}
+		defer func() {
+			err := shutdownTracing(context.Background())
+			if err != nil {
+				log.WithError(err).Warn("cannot flush traces")
+			}
+		}()
 
-		log.Info("making sure database schema is up to date")
-		err = postgres.Migrate(db)
-		if err != nil {
-			return err
-		}
-		jobStore, err := postgres.NewJobStore(db)
-		if err != nil {
-			return err
-		}
-		nrGroups, err := postgres.NewNumberGroup(db)
+		storage, err := newJobStorage(cfg)
 		if err != nil {
 			return err
 		}
@@ -137,7 +132,14 @@ var runCmd = &cobra.Command{
 			execCfg.Namespace = "default"
 		}
 
-		logStore, err := store.NewFileLogStore(cfg.Storage.LogStore)
+		var blobs store.BlobStore
+		if bcfg := cfg.Storage.LogBlobStore; bcfg != nil {
+			blobs, err = newBlobStore(*bcfg)
+			if err != nil {
+				return err
+			}
+		}
+		logStore, err := newLogStore(cfg, blobs)
 		if err != nil {
 			return err
 		}
@@ -147,16 +149,29 @@ var runCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		if blobs != nil {
+			exec.Artifacts = blobs
+		}
 		exec.Run()
 		service := &werft.Service{
 			Logs:               logStore,
-			Jobs:               jobStore,
-			Groups:             nrGroups,
+			Jobs:               storage.Jobs,
+			Groups:             storage.Groups,
 			Executor:           exec,
 			Cutter:             logcutter.DefaultCutter,
 			Config:             cfg.Werft,
 			RepositoryProvider: werft.NoopRepositoryProvider{},
 		}
+		if blobs != nil {
+			service.Artifacts = blobs
+			service.ArtifactsPrefix = path.Join(cfg.Storage.LogBlobStore.Prefix, "artifacts")
+		}
+		if cfg.Service.Audit != nil {
+			service.Audit, err = newAuditLogger(*cfg.Service.Audit, storage.DB)
+			if err != nil {
+				return err
+			}
+		}
 		if val, _ := cmd.Flags().GetString("debug-webui-proxy"); val != "" {
 			cfg.Werft.DebugProxy = val
 		}
@@ -191,6 +206,47 @@ var runCmd = &cobra.Command{
 			log.WithError(err).Fatal("cannot start service")
 		}
 
+		shutdownGracePeriod := 10 * time.Second
+		if cfg.Service.ShutdownGracePeriod != "" {
+			shutdownGracePeriod, err = time.ParseDuration(cfg.Service.ShutdownGracePeriod)
+			if err != nil {
+				return fmt.Errorf("cannot parse shutdown grace period: %w", err)
+			}
+		}
+		shutdownTimeout := 15 * time.Second
+		if cfg.Service.ShutdownTimeout != "" {
+			shutdownTimeout, err = time.ParseDuration(cfg.Service.ShutdownTimeout)
+			if err != nil {
+				return fmt.Errorf("cannot parse shutdown timeout: %w", err)
+			}
+		}
+		healthChecker := health.NewChecker()
+		if storage.DB != nil {
+			healthChecker.AddCheck("store", storage.DB.PingContext)
+		}
+		healthChecker.AddCheck("kubernetes", func(ctx context.Context) error {
+			return exec.Client.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Error()
+		})
+
+		unaryInterceptors := []grpc.UnaryServerInterceptor{service.UnaryServerInterceptor, tracing.UnaryServerInterceptor}
+		streamInterceptors := []grpc.StreamServerInterceptor{service.StreamServerInterceptor}
+		if cfg.Service.Auth != nil {
+			authInterceptor, err := auth.NewInterceptor(*cfg.Service.Auth)
+			if err != nil {
+				return fmt.Errorf("cannot configure auth: %w", err)
+			}
+			if len(cfg.Service.Auth.Rules) > 0 {
+				service.Policy, err = auth.NewStaticPolicy(cfg.Service.Auth.Rules)
+				if err != nil {
+					return fmt.Errorf("cannot configure auth rules: %w", err)
+				}
+			}
+			unaryInterceptors = append(unaryInterceptors, authInterceptor.UnaryServerInterceptor)
+			streamInterceptors = append(streamInterceptors, authInterceptor.StreamServerInterceptor)
+		} else {
+			log.Warn("no auth configured - anyone who can reach the gRPC API can start and stop jobs")
+		}
+
 		grpcOpts := []grpc.ServerOption{
 			// We don't know how good our cients are at closing connections. If they don't close them properly
 			// we'll be leaking goroutines left and right. Closing Idle connections should prevent that.
@@ -198,20 +254,46 @@ var runCmd = &cobra.Command{
 			// the client can simply reconnect if they're still interested. WebUI is pretty good at maintaining
 			// connections anyways.
 			grpc.KeepaliveParams(keepalive.ServerParameters{MaxConnectionIdle: 15 * time.Minute}),
+			grpc.ChainUnaryInterceptor(unaryInterceptors...),
+			grpc.ChainStreamInterceptor(streamInterceptors...),
+		}
+		serviceGRPCOpts := grpcOpts
+		if cfg.Service.TLS != nil {
+			creds, err := auth.ServerCredentials(*cfg.Service.TLS)
+			if err != nil {
+				return fmt.Errorf("cannot configure TLS: %w", err)
+			}
+			serviceGRPCOpts = append([]grpc.ServerOption{grpc.Creds(creds)}, grpcOpts...)
 		}
-		go startGRPC(service, fmt.Sprintf(":%d", cfg.Service.GRPCPort), grpcOpts...)
-		go startWeb(service, uiservice, fmt.Sprintf(":%d", cfg.Service.WebPort), startWebOpts{
+		grpcServer := startGRPC(newGRPCServer(service, healthChecker, !cfg.Service.DisableReflection, serviceGRPCOpts...), fmt.Sprintf(":%d", cfg.Service.GRPCPort))
+		webServer := startWeb(service, uiservice, fmt.Sprintf(":%d", cfg.Service.WebPort), startWebOpts{
 			DebugProxy:  cfg.Werft.DebugProxy,
 			ReadOpsOnly: cfg.Service.WebReadOnly,
 			GRPCOpts:    grpcOpts,
 			Plugins:     plugins,
+			Health:      healthChecker,
+			Trigger:     cfg.Service.Trigger,
 		})
 
+		metrics := []func(prometheus.Registerer){
+			service.RegisterPrometheusMetrics,
+			exec.RegisterPrometheusMetrics,
+			plugins.RegisterPrometheusMetrics,
+		}
+		if jobs, ok := storage.Jobs.(*postgres.JobStore); ok {
+			metrics = append(metrics, jobs.RegisterPrometheusMetrics)
+		}
+		if cfg.Storage.LogRetention != nil {
+			sweeper, interval, err := newLogSweeper(*cfg.Storage.LogRetention, logStore, storage.Jobs)
+			if err != nil {
+				return err
+			}
+			go sweeper.Run(interval)
+			metrics = append(metrics, sweeper.RegisterPrometheusMetrics)
+		}
+
 		if cfg.Service.PromPort != 0 {
-			go startPrometheus(fmt.Sprintf(":%d", cfg.Service.PromPort),
-				jobStore.RegisterPrometheusMetrics,
-				service.RegisterPrometheusMetrics,
-			)
+			go startPrometheus(fmt.Sprintf(":%d", cfg.Service.PromPort), metrics...)
 		}
 		if cfg.Service.PprofPort != 0 {
 			var mpf int
@@ -247,6 +329,17 @@ var runCmd = &cobra.Command{
 		<-sigChan
 		log.Info("Received SIGINT - shutting down")
 
+		// we report not ready while shutting down so that load balancers stop sending us requests
+		healthChecker.Shutdown()
+		if shutdownGracePeriod > 0 {
+			log.WithField("gracePeriod", shutdownGracePeriod.String()).Info("draining connections")
+			time.Sleep(shutdownGracePeriod)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		shutdown(ctx, service, grpcServer, webServer)
+
 		return nil
 	},
 }
@@ -256,10 +349,62 @@ type startWebOpts struct {
 	ReadOpsOnly bool
 	GRPCOpts    []grpc.ServerOption
 	Plugins     http.Handler
+	Health      *health.Checker
+	Trigger     *werft.TriggerConfig
 }
 
-// startWeb starts the werft web UI service
-func startWeb(service *werft.Service, uiservice v1.WerftUIServer, addr string, opts startWebOpts) {
+// shutdown stops accepting new requests and gives in-flight requests until half the context deadline to finish.
+// Streams which are still running then (e.g. listening to the logs of a running job) end once the service
+// has flushed the logs and persisted its queue. Whatever is still running when the context is done is cut off.
+func shutdown(ctx context.Context, service *werft.Service, grpcServer *grpc.Server, webServer *http.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			grpcServer.GracefulStop()
+		}()
+		go func() {
+			defer wg.Done()
+			// the web server serves gRPC-web streams which only end once the service shuts down, hence no deadline here
+			err := webServer.Shutdown(context.Background())
+			if err != nil {
+				log.WithError(err).Warn("cannot shut down web server")
+			}
+		}()
+		wg.Wait()
+		close(stopped)
+	}()
+
+	var drainTimeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		drainTimeout = time.Until(deadline) / 2
+	}
+	log.WithField("timeout", drainTimeout.String()).Info("waiting for in-flight requests to finish")
+	select {
+	case <-stopped:
+	case <-time.After(drainTimeout):
+	case <-ctx.Done():
+	}
+
+	err := service.Shutdown(ctx)
+	if err != nil {
+		log.WithError(err).Warn("cannot shut down service cleanly")
+	}
+
+	select {
+	case <-stopped:
+		log.Info("all requests finished")
+	case <-ctx.Done():
+		log.Warn("requests did not finish in time - cutting them off")
+		grpcServer.Stop()
+		webServer.Close()
+	}
+}
+
+// startWeb starts the werft web UI service and returns the server it serves on
+func startWeb(service *werft.Service, uiservice v1.WerftUIServer, addr string, opts startWebOpts) *http.Server {
 	var webuiServer http.Handler
 	if opts.DebugProxy != "" {
 		tgt, err := url.Parse(opts.DebugProxy)
@@ -294,7 +439,10 @@ func startWeb(service *werft.Service, uiservice v1.WerftUIServer, addr string, o
 			case "/v1.WerftService/StartLocalJob",
 				"/v1.WerftService/StartGitHubJob",
 				"/v1.WerftService/StartFromPreviousJob",
-				"/v1.WerftService/StopJob":
+				"/v1.WerftService/RetryPipeline",
+				"/v1.WerftService/StopJob",
+				"/v1.WerftService/StopJobs",
+				"/v1.WerftService/SetJobAnnotations":
 				return nil, status.Error(codes.Unauthenticated, "Werft installation is read-only")
 			}
 
@@ -309,7 +457,13 @@ func startWeb(service *werft.Service, uiservice v1.WerftUIServer, addr string, o
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/version", serveVersion)
+	mux.HandleFunc("/healthz", opts.Health.ServeHealthz)
+	mux.HandleFunc("/readyz", opts.Health.ServeReadyz)
+	mux.Handle("/badge/", http.StripPrefix("/badge/", werft.NewBadgeHandler(service)))
 	mux.Handle("/plugins/", http.StripPrefix("/plugins/", opts.Plugins))
+	if opts.Trigger != nil && !opts.ReadOpsOnly {
+		mux.Handle("/trigger", werft.NewTriggerHandler(service, *opts.Trigger))
+	}
 	mux.Handle("/", hstsHandler(
 		grpcTrafficSplitter(
 			webuiServer,
@@ -317,28 +471,44 @@ func startWeb(service *werft.Service, uiservice v1.WerftUIServer, addr string, o
 		),
 	))
 
-	log.WithField("addr", addr).Info("serving werft web service")
-	err := http.ListenAndServe(addr, mux)
-	if err != nil {
-		log.WithField("addr", addr).WithError(err).Warn("cannot serve web service")
-	}
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		log.WithField("addr", addr).Info("serving werft web service")
+		err := srv.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.WithField("addr", addr).WithError(err).Warn("cannot serve web service")
+		}
+	}()
+	return srv
 }
 
-// startGRPC starts the werft GRPC service
-func startGRPC(service v1.WerftServiceServer, addr string, opts ...grpc.ServerOption) {
+// newGRPCServer creates the server of the werft GRPC service. Besides the werft service it serves the standard
+// gRPC health service, which reports the readiness of werft, and optionally the server reflection service.
+func newGRPCServer(service v1.WerftServiceServer, healthChecker *health.Checker, reflect bool, opts ...grpc.ServerOption) *grpc.Server {
 	grpcServer := grpc.NewServer(opts...)
 	v1.RegisterWerftServiceServer(grpcServer, service)
+	healthpb.RegisterHealthServer(grpcServer, health.NewGRPCServer(healthChecker, "v1.WerftService"))
+	if reflect {
+		reflection.Register(grpcServer)
+	}
+	return grpcServer
+}
 
+// startGRPC serves the werft GRPC service on addr
+func startGRPC(grpcServer *grpc.Server, addr string) *grpc.Server {
 	lis, err := net.Listen("tcp", addr)
 	if err != nil {
 		log.WithError(err).Error("cannot start GRPC server")
 	}
 
-	log.WithField("addr", addr).Info("serving werft GRPC service")
-	err = grpcServer.Serve(lis)
-	if err != nil {
-		log.WithError(err).Error("cannot start GRPC server")
-	}
+	go func() {
+		log.WithField("addr", addr).Info("serving werft GRPC service")
+		err := grpcServer.Serve(lis)
+		if err != nil {
+			log.WithError(err).Error("cannot start GRPC server")
+		}
+	}()
+	return grpcServer
 }
 
 // startPrometheus starts a Prometheus metrics server on addr.
@@ -479,14 +649,248 @@ type Config struct {
 		JobSpecRepos       []string `yaml:"jobSpecRepos"`
 		SpecUpdateInterval string   `yaml:"specUpdateInterval"`
 		WebReadOnly        bool     `yaml:"webReadOnly,omitempty"`
+		// ShutdownGracePeriod is the time we report not ready before shutting down, so that load balancers
+		// can drain our connections. Defaults to 10s.
+		ShutdownGracePeriod string `yaml:"shutdownGracePeriod,omitempty"`
+		// ShutdownTimeout is the time in-flight requests and streams have to finish once werft stops accepting
+		// new requests. Defaults to 15s.
+		ShutdownTimeout string `yaml:"shutdownTimeout,omitempty"`
+		// Auth requires bearer tokens for calls to the gRPC API
+		Auth *auth.Config `yaml:"auth,omitempty"`
+		// TLS serves the gRPC port using TLS and optionally requires client certificates.
+		// It does not apply to the web port.
+		TLS *auth.TLSConfig `yaml:"tls,omitempty"`
+		// Audit records who started and stopped which jobs
+		Audit *AuditConfig `yaml:"audit,omitempty"`
+		// Trigger serves an HTTP endpoint on the web port which starts jobs for signed requests, e.g. from Jenkins
+		Trigger *werft.TriggerConfig `yaml:"trigger,omitempty"`
+		// DisableReflection stops serving the gRPC server reflection service on the gRPC port, which tools like
+		// grpcurl use to explore the API
+		DisableReflection bool `yaml:"disableReflection,omitempty"`
 	}
 	Storage struct {
-		LogStore                   string `yaml:"logsPath"`
+		LogStore string `yaml:"logsPath"`
+
+		// JobStoreType is where jobs are stored: "postgres" (the default) in the database JobStore connects to,
+		// "file" in files below JobStorePath and "memory" in memory only, i.e. they're gone once werft stops.
+		JobStoreType               string `yaml:"jobsType,omitempty"`
 		JobStore                   string `yaml:"jobsConnectionString"`
 		JobStoreMaxConnections     int    `yaml:"jobsMaxConnections"`
 		JobStoreMaxIdleConnections int    `yaml:"jobsMaxIdleConnections"`
+		JobStorePath               string `yaml:"jobsPath,omitempty"`
+
+		// CompressLogs gzips logs once their job is done
+		CompressLogs bool `yaml:"compressLogs,omitempty"`
+		// LogBlobStore persists logs in an object storage. The logs in LogStore serve as cache.
+		// The artifacts jobs pass to each other are stored there as well, below <prefix>/artifacts.
+		LogBlobStore *LogBlobStoreConfig `yaml:"logsBlobStore,omitempty"`
+		// LogRetention deletes logs beyond their retention
+		LogRetention *LogRetentionConfig `yaml:"logRetention,omitempty"`
 	} `yaml:"storage"`
 	Executor   executor.Config `yaml:"executor"`
 	Kubeconfig string          `yaml:"kubeconfig,omitempty"`
 	Plugins    plugin.Config
 }
+
+const (
+	jobStorePostgres = "postgres"
+	jobStoreFile     = "file"
+	jobStoreMemory   = "memory"
+)
+
+// jobStorage is where werft stores jobs and the numbers of their names
+type jobStorage struct {
+	Jobs   store.Jobs
+	Groups store.NumberGroup
+	// DB is the database of the postgres job store, nil for all other job stores
+	DB *sql.DB
+}
+
+// newJobStorage creates the job store configured by the job store type
+func newJobStorage(cfg Config) (*jobStorage, error) {
+	switch cfg.Storage.JobStoreType {
+	case "", jobStorePostgres:
+		return newPostgresJobStorage(cfg)
+	case jobStoreFile:
+		if cfg.Storage.JobStorePath == "" {
+			return nil, fmt.Errorf("the file job store requires storage.jobsPath")
+		}
+		log.WithField("path", cfg.Storage.JobStorePath).Info("storing jobs in files")
+		jobs, err := store.NewFileJobStore(cfg.Storage.JobStorePath)
+		if err != nil {
+			return nil, err
+		}
+		groups, err := store.NewFileNumberGroup(filepath.Join(cfg.Storage.JobStorePath, "numbergroups.json"))
+		if err != nil {
+			return nil, err
+		}
+		return &jobStorage{Jobs: jobs, Groups: groups}, nil
+	case jobStoreMemory:
+		log.Warn("storing jobs in memory - they are gone once werft stops")
+		return &jobStorage{Jobs: store.NewInMemoryJobStore(), Groups: store.NewInMemoryNumberGroup()}, nil
+	default:
+		return nil, fmt.Errorf("unknown job store type %q: must be %s, %s or %s", cfg.Storage.JobStoreType, jobStorePostgres, jobStoreFile, jobStoreMemory)
+	}
+}
+
+// newPostgresJobStorage connects to the database and makes sure its schema is up to date
+func newPostgresJobStorage(cfg Config) (*jobStorage, error) {
+	log.Info("connecting to database")
+	db, err := sql.Open("postgres", cfg.Storage.JobStore)
+	if err != nil {
+		return nil, err
+	}
+	maxConns := 10
+	maxIdleConns := 2
+	if cfg.Storage.JobStoreMaxConnections > 0 {
+		maxConns = cfg.Storage.JobStoreMaxConnections
+	}
+	if cfg.Storage.JobStoreMaxIdleConnections > 0 {
+		maxIdleConns = cfg.Storage.JobStoreMaxIdleConnections
+	}
+	log.WithField("maxOpenConns", maxConns).WithField("maxIdleConns", maxIdleConns).Debug("setting max open connections on job store DB")
+	db.SetMaxOpenConns(maxConns)
+	db.SetMaxIdleConns(maxIdleConns)
+	err = db.Ping()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("making sure database schema is up to date")
+	err = postgres.Migrate(db)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := postgres.NewJobStore(db)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := postgres.NewNumberGroup(db)
+	if err != nil {
+		return nil, err
+	}
+	return &jobStorage{Jobs: jobs, Groups: groups, DB: db}, nil
+}
+
+// AuditConfig configures the audit log
+type AuditConfig struct {
+	// Sink is where audit entries go: "stdout" writes them as JSON lines, "store" writes them to the database
+	Sink string `yaml:"sink"`
+}
+
+// newAuditLogger creates the audit logger writing to the configured sink
+func newAuditLogger(cfg AuditConfig, db *sql.DB) (audit.Logger, error) {
+	switch cfg.Sink {
+	case "stdout":
+		return &audit.JSONLogger{Out: os.Stdout}, nil
+	case "store":
+		if db == nil {
+			return nil, fmt.Errorf("the store audit sink requires the postgres job store")
+		}
+		return postgres.NewAuditLog(db)
+	default:
+		return nil, fmt.Errorf("unknown audit sink %q: must be stdout or store", cfg.Sink)
+	}
+}
+
+// LogBlobStoreConfig configures the object storage job logs are persisted to
+type LogBlobStoreConfig struct {
+	// Type is the kind of object storage, either "s3" or "gcs"
+	Type string `yaml:"type"`
+	// Prefix is prepended to the name of all log files in the bucket
+	Prefix string `yaml:"prefix,omitempty"`
+
+	S3  *s3.Config  `yaml:"s3,omitempty"`
+	GCS *gcs.Config `yaml:"gcs,omitempty"`
+}
+
+// newLogStore creates the log store. If a blob store is configured, the log files on disk serve as its cache.
+func newLogStore(cfg Config, blobs store.BlobStore) (store.Logs, error) {
+	files, err := store.NewFileLogStore(cfg.Storage.LogStore)
+	if err != nil {
+		return nil, err
+	}
+	files.Compress = cfg.Storage.CompressLogs
+	if blobs == nil {
+		return files, nil
+	}
+
+	log.WithField("type", cfg.Storage.LogBlobStore.Type).Info("persisting logs to blob store")
+	res := store.NewBlobLogStore(files, blobs, cfg.Storage.LogBlobStore.Prefix)
+	res.Compress = cfg.Storage.CompressLogs
+	return res, nil
+}
+
+// newBlobStore creates the configured object storage
+func newBlobStore(bcfg LogBlobStoreConfig) (blobs store.BlobStore, err error) {
+	switch bcfg.Type {
+	case "s3":
+		if bcfg.S3 == nil {
+			return nil, fmt.Errorf("log blob store of type s3 requires s3 config")
+		}
+		blobs, err = s3.NewBlobStore(*bcfg.S3)
+	case "gcs":
+		if bcfg.GCS == nil {
+			return nil, fmt.Errorf("log blob store of type gcs requires gcs config")
+		}
+		blobs, err = gcs.NewBlobStore(context.Background(), *bcfg.GCS)
+	default:
+		return nil, fmt.Errorf("unknown log blob store type %q: must be s3 or gcs", bcfg.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return blobs, nil
+}
+
+// LogRetentionConfig configures how long logs are kept
+type LogRetentionConfig struct {
+	// MaxAge deletes logs which are older than this, e.g. 720h
+	MaxAge string `yaml:"maxAge,omitempty"`
+	// MaxTotalSize deletes the oldest logs once all logs take up more than this, e.g. 50Gi
+	MaxTotalSize string `yaml:"maxTotalSize,omitempty"`
+	// DeleteJobs deletes jobs together with their logs. By default jobs outlive their logs.
+	DeleteJobs bool `yaml:"deleteJobs,omitempty"`
+	// Interval is the time between two sweeps. Defaults to one hour.
+	Interval string `yaml:"interval,omitempty"`
+}
+
+// newLogSweeper creates a log sweeper enforcing the retention config
+func newLogSweeper(cfg LogRetentionConfig, logs store.Logs, jobs store.Jobs) (sweeper *store.LogSweeper, interval time.Duration, err error) {
+	prunable, ok := logs.(store.PrunableLogs)
+	if !ok {
+		return nil, 0, fmt.Errorf("log store does not support log retention")
+	}
+
+	var retention store.LogRetention
+	if cfg.MaxAge != "" {
+		retention.MaxAge, err = time.ParseDuration(cfg.MaxAge)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid log retention max age: %w", err)
+		}
+	}
+	if cfg.MaxTotalSize != "" {
+		q, err := resource.ParseQuantity(cfg.MaxTotalSize)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid log retention max total size: %w", err)
+		}
+		retention.MaxTotalSize = q.Value()
+	}
+	if retention.MaxAge <= 0 && retention.MaxTotalSize <= 0 {
+		return nil, 0, fmt.Errorf("log retention requires a positive max age or max total size")
+	}
+	retention.DeleteJobs = cfg.DeleteJobs
+
+	interval = time.Hour
+	if cfg.Interval != "" {
+		interval, err = time.ParseDuration(cfg.Interval)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid log retention interval: %w", err)
+		}
+		if interval <= 0 {
+			return nil, 0, fmt.Errorf("log retention interval must be positive")
+		}
+	}
+
+	return store.NewLogSweeper(prunable, jobs, retention), interval, nil
+}
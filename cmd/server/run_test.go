@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/health"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func dialGRPCServer(t *testing.T, srv *grpc.Server) *grpc.ClientConn {
+	l := bufconn.Listen(1024 * 1024)
+	go srv.Serve(l)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return l.Dial() }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestGRPCServerReflection(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Reflect     bool
+		Expectation []string
+	}{
+		{
+			Name:        "enabled",
+			Reflect:     true,
+			Expectation: []string{"grpc.health.v1.Health", "grpc.reflection.v1alpha.ServerReflection", "v1.WerftService"},
+		},
+		{Name: "disabled"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			srv := newGRPCServer(&v1.UnimplementedWerftServiceServer{}, health.NewChecker(), test.Reflect)
+			defer srv.Stop()
+			conn := dialGRPCServer(t, srv)
+			defer conn.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = stream.Send(&rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_ListServices{}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := stream.Recv()
+			if !test.Reflect {
+				if err == nil {
+					t.Fatalf("expected reflection to be disabled, got %v", resp)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var act []string
+			for _, s := range resp.GetListServicesResponse().GetService() {
+				act = append(act, s.Name)
+			}
+			sort.Strings(act)
+			if !reflect.DeepEqual(act, test.Expectation) {
+				t.Errorf("unexpected services: %v, expected %v", act, test.Expectation)
+			}
+		})
+	}
+}
+
+func TestGRPCServerHealth(t *testing.T) {
+	checker := health.NewChecker()
+	checker.AddCheck("store", func(ctx context.Context) error { return nil })
+	srv := newGRPCServer(&v1.UnimplementedWerftServiceServer{}, checker, false)
+	defer srv.Stop()
+	conn := dialGRPCServer(t, srv)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := healthpb.NewHealthClient(conn)
+	for _, service := range []string{"", "v1.WerftService"} {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("cannot check health of %q: %v", service, err)
+		}
+		if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("expected %q to be SERVING, got %v", service, resp.Status)
+		}
+	}
+
+	checker.Shutdown()
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected NOT_SERVING while shutting down, got %v", resp.Status)
+	}
+}
+
+func TestNewJobStorage(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), "tnjs")
+	if err != nil {
+		t.Fatalf("cannot create test folder: %v", err)
+	}
+	defer os.RemoveAll(base)
+
+	tests := []struct {
+		Name  string
+		Type  string
+		Path  string
+		Error string
+	}{
+		{Name: "memory", Type: "memory"},
+		{Name: "file", Type: "file", Path: base},
+		{Name: "file without path", Type: "file", Error: "requires storage.jobsPath"},
+		{Name: "unknown", Type: "bolt", Error: `unknown job store type "bolt"`},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var cfg Config
+			cfg.Storage.JobStoreType = test.Type
+			cfg.Storage.JobStorePath = test.Path
+
+			storage, err := newJobStorage(cfg)
+			if test.Error != "" {
+				if err == nil || !strings.Contains(err.Error(), test.Error) {
+					t.Fatalf("expected error containing %q, got %v", test.Error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("cannot create job storage: %v", err)
+			}
+			if storage.Jobs == nil || storage.Groups == nil {
+				t.Errorf("expected jobs and number groups, got %+v", storage)
+			}
+			if storage.DB != nil {
+				t.Errorf("expected no database for the %s job store", test.Type)
+			}
+
+			// jobs can be named using the number groups and stored
+			nr, err := storage.Groups.Next("werft")
+			if err != nil {
+				t.Fatalf("cannot get next number: %v", err)
+			}
+			err = storage.Jobs.Store(context.Background(), v1.JobStatus{Name: fmt.Sprintf("werft.%d", nr)})
+			if err != nil {
+				t.Fatalf("cannot store job: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package repoconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UnknownFields returns the paths of the keys of a decoded job spec which the job spec does not know,
+// e.g. pod.containers[0].imagee. Unknown keys are ignored when a job starts, hence they're mostly typos.
+func UnknownFields(spec map[string]interface{}) []string {
+	var res []string
+	unknownFields(&res, "", spec, reflect.TypeOf(JobSpec{}))
+	sort.Strings(res)
+	return res
+}
+
+var jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+func unknownFields(res *[]string, path string, val interface{}, typ reflect.Type) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if reflect.PtrTo(typ).Implements(jsonUnmarshaler) {
+		// types which decode themselves, e.g. resource quantities, have no fields
+		return
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := make(map[string]reflect.Type)
+		structFields(fields, typ)
+		for k, v := range obj {
+			ft, ok := fields[strings.ToLower(k)]
+			if !ok {
+				*res = append(*res, joinFieldPath(path, k))
+				continue
+			}
+			unknownFields(res, joinFieldPath(path, k), v, ft)
+		}
+	case reflect.Map:
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for k, v := range obj {
+			unknownFields(res, joinFieldPath(path, k), v, typ.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		lst, ok := val.([]interface{})
+		if !ok {
+			return
+		}
+		for i, v := range lst {
+			unknownFields(res, fmt.Sprintf("%s[%d]", path, i), v, typ.Elem())
+		}
+	}
+}
+
+// structFields adds the keys of a struct to fields. Like encoding/json, which decodes job specs, keys are
+// matched case-insensitively against the JSON name of a field. The YAML name is known as well.
+func structFields(fields map[string]reflect.Type, typ reflect.Type) {
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		name := f.Name
+		if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		} else if f.Anonymous {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				structFields(fields, ft)
+				continue
+			}
+		}
+		fields[strings.ToLower(name)] = f.Type
+
+		if tag := strings.Split(f.Tag.Get("yaml"), ",")[0]; tag != "" && tag != "-" {
+			fields[strings.ToLower(tag)] = f.Type
+		}
+	}
+}
+
+func joinFieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// MarshalJobSpec encodes a job spec as YAML using the keys job specs are written with, e.g. pod.containers[0].imagePullPolicy.
+// Empty values are left out.
+func MarshalJobSpec(js *JobSpec) ([]byte, error) {
+	val, err := specValue(reflect.ValueOf(js))
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return nil, nil
+	}
+	return yaml.Marshal(val)
+}
+
+var jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// specValue turns a value of a job spec into plain maps, lists and scalars which encode to YAML like the job spec is written
+func specValue(v reflect.Value) (interface{}, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if v.Type().Implements(jsonMarshaler) || reflect.PtrTo(v.Type()).Implements(jsonMarshaler) {
+		// types which encode themselves, e.g. resource quantities, are written the way they encode to JSON
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		b, err := p.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		var res interface{}
+		err = json.Unmarshal(b, &res)
+		return res, err
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		res := make(map[string]interface{})
+		err := structValues(res, v)
+		if err != nil {
+			return nil, err
+		}
+		if len(res) == 0 {
+			return nil, nil
+		}
+		return res, nil
+	case reflect.Map:
+		res := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			val, err := specValue(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			res[fmt.Sprint(iter.Key().Interface())] = val
+		}
+		return res, nil
+	case reflect.Slice, reflect.Array:
+		res := make([]interface{}, v.Len())
+		for i := range res {
+			val, err := specValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			res[i] = val
+		}
+		return res, nil
+	default:
+		return v.Interface(), nil
+	}
+}
+
+// structValues adds the non-empty fields of a struct to res, named like structFields knows them
+func structValues(res map[string]interface{}, v reflect.Value) error {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		fv := v.Field(i)
+		if fv.IsZero() || ((fv.Kind() == reflect.Slice || fv.Kind() == reflect.Map) && fv.Len() == 0) {
+			continue
+		}
+
+		tag := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if tag == "" {
+			tag = strings.Split(f.Tag.Get("json"), ",")[0]
+		}
+		if tag == "-" {
+			continue
+		}
+		if tag == "" && f.Anonymous && reflect.Indirect(fv).Kind() == reflect.Struct {
+			err := structValues(res, reflect.Indirect(fv))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		name := f.Name
+		if tag != "" {
+			name = tag
+		}
+
+		val, err := specValue(fv)
+		if err != nil {
+			return err
+		}
+		if val != nil {
+			res[name] = val
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package repoconfig
+
+import (
+	"path"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// matchesGlobs returns true if name matches at least one of the including globs, and none of
+// the excluding ones (those starting with !). If there are only excluding globs, all names that
+// are not excluded match.
+func matchesGlobs(globs []string, name string) bool {
+	var (
+		included   bool
+		hasInclude bool
+	)
+	for _, glob := range globs {
+		if strings.HasPrefix(glob, "!") {
+			if matchGlob(strings.TrimPrefix(glob, "!"), name) {
+				return false
+			}
+			continue
+		}
+
+		hasInclude = true
+		if !included && matchGlob(glob, name) {
+			included = true
+		}
+	}
+	return included || !hasInclude
+}
+
+// matchGlob matches a slash-separated name against a glob. Within a segment the glob supports the
+// syntax of path.Match, e.g. * and ?. A segment consisting of ** matches any number of segments,
+// e.g. docs/** matches all files below docs/.
+func matchGlob(glob, name string) bool {
+	return matchSegments(strings.Split(glob, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(glob, name []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			glob = glob[1:]
+			if len(glob) == 0 {
+				return true
+			}
+			for i := range name {
+				if matchSegments(glob, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(glob[0], name[0]); !ok {
+			return false
+		}
+		glob, name = glob[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// validateGlob returns an error if the glob is malformed
+func validateGlob(glob string) error {
+	trimmed := strings.TrimPrefix(glob, "!")
+	if trimmed == "" {
+		return xerrors.Errorf("empty glob")
+	}
+	for _, seg := range strings.Split(trimmed, "/") {
+		if _, err := path.Match(seg, ""); err != nil {
+			return xerrors.Errorf("invalid glob %s: %w", glob, err)
+		}
+	}
+	return nil
+}
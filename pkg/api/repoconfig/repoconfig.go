@@ -1,35 +1,139 @@
 package repoconfig
 
 import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
 	werftv1 "github.com/csweichel/werft/pkg/api/v1"
 	"github.com/csweichel/werft/pkg/filterexpr"
+	"golang.org/x/xerrors"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 // C is the struct we expect to find in the repo root which configures how we build things
 type C struct {
 	DefaultJob string          `yaml:"defaultJob"`
 	Rules      []*JobStartRule `yaml:"rules"`
+	GitHub     *GitHubConfig   `yaml:"github,omitempty"`
+
+	// Templates names job templates which job specs can extend, e.g. "go: .werft/templates/go.yaml"
+	Templates map[string]string `yaml:"templates,omitempty"`
+
+	// JobName is the template of the names of this repository's jobs, e.g. "${repo.repo}-${repo.shortRef}-${job.counter}".
+	// Jobs are named <repo>-<job spec>-<ref>.<counter> if it's empty.
+	JobName string `yaml:"jobName,omitempty"`
+
+	// DefaultImage is the image of the containers of this repository's jobs which don't name one themselves
+	DefaultImage string `yaml:"defaultImage,omitempty"`
+
+	// Annotations are added to all jobs of this repository, e.g. "team: platform".
+	// Annotations the job is started with take precedence.
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// UnmarshalYAML unmarshals the repo config and validates its default image
+func (rc *C) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type rawC C
+	var raw rawC
+	err := unmarshal(&raw)
+	if err != nil {
+		return err
+	}
+
+	if raw.DefaultImage != "" {
+		err = ValidateImage(raw.DefaultImage)
+		if err != nil {
+			return xerrors.Errorf("invalid defaultImage: %w", err)
+		}
+	}
+
+	*rc = C(raw)
+	return nil
+}
+
+// imagePattern describes image references, e.g. "golang:1.14" or "eu.gcr.io/project/image@sha256:...".
+// It follows the grammar of github.com/docker/distribution/reference.
+var imagePattern = regexp.MustCompile(`^` +
+	// optional domain, e.g. "eu.gcr.io" or "localhost:5000"
+	`(?:(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*(?::[0-9]+)?/)?` +
+	// path, e.g. "library/golang"
+	`[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*)*` +
+	// optional tag and digest
+	`(?::[\w][\w.-]{0,127})?(?:@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,})?$`)
+
+// ValidateImage returns an error if the image is not a valid image reference
+func ValidateImage(image string) error {
+	if len(image) > 255 {
+		return xerrors.Errorf("image %s is too long", image)
+	}
+	if !imagePattern.MatchString(image) {
+		return xerrors.Errorf("%s is not a valid image reference", image)
+	}
+	return nil
+}
+
+// GitHubConfig configures how jobs of this repository report back to GitHub
+type GitHubConfig struct {
+	// Checks enables reporting a check run per job using the GitHub Checks API
+	Checks bool `yaml:"checks"`
+
+	// CheckAnnotations turns "werft error" markers found in the job log into check run annotations
+	CheckAnnotations bool `yaml:"checkAnnotations"`
 }
 
 // JobStartRule determines if a job will be started
 type JobStartRule struct {
 	Path string                      `yaml:"path"`
 	Expr []*werftv1.FilterExpression `yaml:"matchesAll"`
+
+	// Branches restricts the rule to branches matching at least one of these globs.
+	// Globs starting with ! exclude branches.
+	Branches []string `yaml:"branches,omitempty"`
+
+	// Tags restricts the rule to tags matching at least one of these globs.
+	// Globs starting with ! exclude tags.
+	Tags []string `yaml:"tags,omitempty"`
+
+	// Paths restricts the rule to changes touching at least one file matching these globs.
+	// Globs starting with ! exclude files.
+	Paths []string `yaml:"paths,omitempty"`
 }
 
 // UnmarshalYAML unmarshals the filter expressions
 func (r *JobStartRule) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var rawJobStartRule struct {
-		Path string           `yaml:"path"`
-		Expr []JobStartRuleOr `yaml:"matchesAll"`
+		Path     string           `yaml:"path"`
+		Expr     []JobStartRuleOr `yaml:"matchesAll"`
+		Branches []string         `yaml:"branches"`
+		Tags     []string         `yaml:"tags"`
+		Paths    []string         `yaml:"paths"`
 	}
 	err := unmarshal(&rawJobStartRule)
 	if err != nil {
 		return err
 	}
 
+	var globs []string
+	globs = append(globs, rawJobStartRule.Branches...)
+	globs = append(globs, rawJobStartRule.Tags...)
+	globs = append(globs, rawJobStartRule.Paths...)
+	for _, glob := range globs {
+		err = validateGlob(glob)
+		if err != nil {
+			return err
+		}
+	}
+
 	r.Path = rawJobStartRule.Path
+	r.Branches = rawJobStartRule.Branches
+	r.Tags = rawJobStartRule.Tags
+	r.Paths = rawJobStartRule.Paths
 	for _, expr := range rawJobStartRule.Expr {
 		terms, err := filterexpr.Parse(expr.Or)
 		if err != nil {
@@ -48,9 +152,15 @@ type JobStartRuleOr struct {
 
 // TemplatePath returns the path to the job template in the repo
 func (rc *C) TemplatePath(md *werftv1.JobMetadata) string {
-	js := &werftv1.JobStatus{Metadata: md}
+	return rc.TemplatePathForChanges(md, nil)
+}
+
+// TemplatePathForChanges returns the path to the job template in the repo, taking the files changed
+// by the event which triggered the job into account. If changedFiles is empty, the changes are considered
+// unknown and path filters are ignored.
+func (rc *C) TemplatePathForChanges(md *werftv1.JobMetadata, changedFiles []string) string {
 	for _, rule := range rc.Rules {
-		if filterexpr.MatchesFilter(js, rule.Expr) {
+		if rule.Matches(md, changedFiles) {
 			return rule.Path
 		}
 	}
@@ -58,6 +168,67 @@ func (rc *C) TemplatePath(md *werftv1.JobMetadata) string {
 	return rc.DefaultJob
 }
 
+// Matches determines if a job with the given metadata and changes satisfies this rule
+func (r *JobStartRule) Matches(md *werftv1.JobMetadata, changedFiles []string) bool {
+	if !filterexpr.MatchesFilter(&werftv1.JobStatus{Metadata: md}, r.Expr) {
+		return false
+	}
+
+	if (len(r.Branches) > 0 || len(r.Tags) > 0) && !r.matchesRef(md) {
+		return false
+	}
+
+	if len(r.Paths) > 0 && len(changedFiles) > 0 {
+		var touched bool
+		for _, fn := range changedFiles {
+			if matchesGlobs(r.Paths, fn) {
+				touched = true
+				break
+			}
+		}
+		if !touched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchesRef determines if the job's ref is a branch matching the rule's branches, or a tag matching its tags
+func (r *JobStartRule) matchesRef(md *werftv1.JobMetadata) bool {
+	if branch, ok := branchName(md); ok && len(r.Branches) > 0 {
+		return matchesGlobs(r.Branches, branch)
+	}
+	if tag, ok := tagName(md); ok && len(r.Tags) > 0 {
+		return matchesGlobs(r.Tags, tag)
+	}
+	return false
+}
+
+// tagName extracts the tag name from the job's ref. Returns false if the ref does not point to a tag.
+func tagName(md *werftv1.JobMetadata) (string, bool) {
+	if md == nil || md.Repository == nil || !strings.HasPrefix(md.Repository.Ref, "refs/tags/") {
+		return "", false
+	}
+	return strings.TrimPrefix(md.Repository.Ref, "refs/tags/"), true
+}
+
+// branchName extracts the branch name from the job's ref. Returns false if the ref does not point to a branch.
+func branchName(md *werftv1.JobMetadata) (string, bool) {
+	if md == nil || md.Repository == nil || md.Repository.Ref == "" {
+		return "", false
+	}
+
+	ref := md.Repository.Ref
+	if strings.HasPrefix(ref, "refs/heads/") {
+		return strings.TrimPrefix(ref, "refs/heads/"), true
+	}
+	if strings.HasPrefix(ref, "refs/") {
+		return "", false
+	}
+	return ref, true
+}
+
 // ShouldRun determines based on the repo config if the job should run
 func (rc *C) ShouldRun(md *werftv1.JobMetadata) bool {
 	return rc.TemplatePath(md) != ""
@@ -68,13 +239,18 @@ type JobSpec struct {
 	// Desc describes the purpose of this job spec.
 	Desc string `yaml:"description,omitempty"`
 
+	// When is a condition on the job's metadata, e.g. trigger==push && repo.ref==refs/heads/main. If it's false,
+	// the job is skipped. The condition combines filter terms using &&, ||, ! and parentheses.
+	When string `yaml:"when,omitempty"`
+
 	// Pod is the actual job spec to start. Prior to deploying this to Kubernetes, we'll run this
 	// as a Go template.
 	Pod *corev1.PodSpec `yaml:"pod"`
 
 	// Mutex makes job execution exclusive, with new ones canceling the currently running one.
 	// For example: job A is running at the moment, and job B is about to start. If A and B share the
-	// same mutex, B will cancel A.
+	// same mutex, B will cancel A. If the mutex is "auto" (MutexAuto), it's derived from the job's repository,
+	// ref and job spec, so that a new push cancels the jobs still running for the previous one.
 	Mutex string `yaml:"mutex,omitempty"`
 
 	// Args describe annotations which this job expects. This list is only used on the UI when manually
@@ -84,7 +260,539 @@ type JobSpec struct {
 	// run is only if Kubernetes accepts the produced podspec.
 	Args []ArgSpec `yaml:"args,omitempty"`
 
+	// Sidecars lists containers of the pod which support the job, e.g. a database. Sidecars do not decide
+	// the outcome of the job, and are stopped once all other containers have finished.
 	Sidecars []string `yaml:"sidecars,omitempty"`
+
+	// Services are sidecar containers werft adds to the pod, e.g. a database for integration tests.
+	// Like all containers of a pod they share the network, i.e. are reachable on localhost.
+	// If a service has an exec readiness probe, the job's containers start only once the service is ready.
+	Services []corev1.Container `yaml:"services,omitempty"`
+
+	// Timeout limits how long the job may run, e.g. "30m". Once the timeout is exceeded the job
+	// is stopped and marked as failed. If empty, the server-wide total timeout applies.
+	Timeout string `yaml:"timeout,omitempty"`
+
+	// PodTTL is how long the pod of this job is kept after the job has finished, e.g. "1h".
+	// If empty, the server-wide pod TTL applies.
+	PodTTL string `yaml:"podTTL,omitempty"`
+
+	// Priority orders the job in the queue when werft limits the number of concurrent jobs.
+	// Jobs with a higher priority start first, jobs of equal priority in the order they were queued.
+	// The priority annotation takes precedence.
+	Priority int `yaml:"priority,omitempty"`
+
+	// PreStop runs a cleanup command in the job's containers when the job is stopped or times out,
+	// instead of killing them right away.
+	PreStop *PreStopSpec `yaml:"preStop,omitempty"`
+
+	// Resources are the compute resources the job's containers request, unless a container
+	// specifies its own. Sidecars are not affected.
+	Resources *ResourceSpec `yaml:"resources,omitempty"`
+
+	// NodeSelector, Tolerations and Affinity control which nodes the job runs on. They are added to
+	// the pod, so that job specs can share them without repeating the scheduling details in each pod.
+	NodeSelector map[string]string   `yaml:"nodeSelector,omitempty"`
+	Tolerations  []corev1.Toleration `yaml:"tolerations,omitempty"`
+	Affinity     *corev1.Affinity    `yaml:"affinity,omitempty"`
+
+	// Secrets are Kubernetes secrets made available to the job's containers, either as files or
+	// environment variables. Only references to the secrets end up in the pod, never their values.
+	Secrets []SecretSpec `yaml:"secrets,omitempty"`
+
+	// Env are plain environment variables of the job's containers, e.g. for configuration which isn't secret.
+	// Sidecars are not affected, and variables a container sets itself take precedence.
+	Env map[string]string `yaml:"env,omitempty"`
+
+	// EnvFile is the path of a file in the repository which declares environment variables like Env, one
+	// KEY=value per line. Variables of Env take precedence. The file is read when the job is started.
+	EnvFile string `yaml:"envFile,omitempty"`
+
+	// Cache mounts a persistent volume into the job's containers, so that e.g. dependency caches
+	// survive between runs.
+	Cache *CacheSpec `yaml:"cache,omitempty"`
+
+	// Matrix expands the job into one job per combination of the values of its variables, e.g.
+	// go: ["1.16", "1.17"] and os: ["linux", "darwin"] start four jobs. Each job finds its values in the
+	// WERFT_MATRIX_<VARIABLE> environment variables and in {{ .Matrix.<variable> }} of the template.
+	Matrix map[string][]string `yaml:"matrix,omitempty"`
+
+	// Needs lists the paths of job specs in the repository which must succeed before this job starts, e.g.
+	// .werft/build.yaml. Starting this job starts the jobs it needs, directly or through other jobs, as well.
+	// If one of them fails or is skipped, this job is skipped.
+	Needs []string `yaml:"needs,omitempty"`
+
+	// Artifacts passes directories of the workspace from jobs to the jobs which need them
+	Artifacts *ArtifactsSpec `yaml:"artifacts,omitempty"`
+}
+
+const (
+	// MaxMatrixJobs is the maximum number of jobs a matrix expands into
+	MaxMatrixJobs = 64
+
+	// MaxNeededJobs is the maximum number of jobs a job needs, directly or through other jobs
+	MaxNeededJobs = 32
+
+	// DefaultCachePath is the directory the cache is mounted to if the job spec doesn't name one
+	DefaultCachePath = "/cache"
+
+	// DefaultCacheSize is the size of newly created cache volumes if the job spec doesn't name one
+	DefaultCacheSize = "5Gi"
+
+	// MutexAuto makes a job use the mutex derived from its repository, ref and job spec
+	MutexAuto = "auto"
+)
+
+// ArtifactsSpec describes the artifacts a job produces and those it uses. Artifacts are identified by their name
+// among all jobs started by the same trigger.
+type ArtifactsSpec struct {
+	// Outputs are collected from the workspace once the job has succeeded
+	Outputs []ArtifactSpec `yaml:"outputs,omitempty"`
+
+	// Inputs are restored into the workspace before the job's containers start
+	Inputs []ArtifactSpec `yaml:"inputs,omitempty"`
+}
+
+// ArtifactSpec is a directory of the workspace passed between jobs
+type ArtifactSpec struct {
+	Name string `yaml:"name"`
+
+	// Path is the directory relative to the workspace. Defaults to the name of the artifact.
+	Path string `yaml:"path,omitempty"`
+}
+
+// ArtifactPath returns the directory of the artifact relative to the workspace
+func (a ArtifactSpec) ArtifactPath() string {
+	if a.Path == "" {
+		return a.Name
+	}
+	return path.Clean(a.Path)
+}
+
+// DefaultPreStopGracePeriod is how long the pre-stop command of a job may take if its spec doesn't say
+const DefaultPreStopGracePeriod = 30 * time.Second
+
+// PreStopSpec configures the cleanup of a job which is stopped before it finished
+type PreStopSpec struct {
+	// Command runs in each of the job's containers when the job is stopped, as a Kubernetes preStop hook.
+	// Sidecars and containers with a preStop hook of their own are not affected.
+	Command []string `yaml:"command"`
+
+	// GracePeriod is how long the command and the job's containers may take to finish before they are killed,
+	// e.g. "2m". Defaults to DefaultPreStopGracePeriod.
+	GracePeriod string `yaml:"gracePeriod,omitempty"`
+}
+
+// CacheSpec configures the persistent cache volume of a job
+type CacheSpec struct {
+	// Key identifies the cache volume: jobs with the same key share the volume.
+	// If empty, all jobs of the repository share the same cache.
+	Key string `yaml:"key,omitempty"`
+
+	// Path is the directory the cache is mounted to. Defaults to DefaultCachePath.
+	Path string `yaml:"path,omitempty"`
+
+	// Size is the size of the cache volume when it's created, e.g. 10Gi. Defaults to DefaultCacheSize.
+	// Changing the size does not resize existing volumes.
+	Size Quantity `yaml:"size,omitempty"`
+
+	// StorageClass is the storage class of the cache volume. If empty, the cluster's default storage class is used.
+	StorageClass string `yaml:"storageClass,omitempty"`
+}
+
+// SecretSpec references a Kubernetes secret in the namespace jobs run in
+type SecretSpec struct {
+	// Name is the name of the Kubernetes secret
+	Name string `yaml:"name"`
+
+	// MountPath is the directory the secret's keys are mounted to as files
+	MountPath string `yaml:"mountPath,omitempty"`
+	// Keys limits the keys mounted to MountPath. If empty, all keys are mounted.
+	Keys []string `yaml:"keys,omitempty"`
+
+	// Env exposes keys of the secret as environment variables
+	Env []SecretEnvSpec `yaml:"env,omitempty"`
+}
+
+// SecretEnvSpec exposes a single key of a secret as environment variable
+type SecretEnvSpec struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+}
+
+// ResourceSpec declares the CPU and memory requests and limits of a job, e.g. cpu: 500m and memory: 2Gi
+type ResourceSpec struct {
+	Requests map[string]Quantity `yaml:"requests,omitempty"`
+	Limits   map[string]Quantity `yaml:"limits,omitempty"`
+}
+
+// Quantity is a resource quantity as written in the job spec. Unlike resource.Quantity it's validated
+// only once we start the job, so that we can produce a helpful error message.
+type Quantity string
+
+// UnmarshalJSON accepts quantities written as string (e.g. "500m") or number (e.g. 2)
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*q = Quantity(s)
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return xerrors.Errorf("resource quantity must be a string or number: %w", err)
+	}
+	*q = Quantity(n.String())
+	return nil
+}
+
+// ParseResources parses and validates the resources of the job spec. Returns nil if the job spec declares no resources.
+func (js *JobSpec) ParseResources() (*corev1.ResourceRequirements, error) {
+	if js.Resources == nil {
+		return nil, nil
+	}
+
+	requests, err := parseResourceList(js.Resources.Requests)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid resource requests: %w", err)
+	}
+	limits, err := parseResourceList(js.Resources.Limits)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid resource limits: %w", err)
+	}
+	for name, req := range requests {
+		lim, ok := limits[name]
+		if ok && req.Cmp(lim) > 0 {
+			return nil, xerrors.Errorf("invalid resources: %s request %s exceeds its limit %s", name, req.String(), lim.String())
+		}
+	}
+
+	return &corev1.ResourceRequirements{
+		Requests: requests,
+		Limits:   limits,
+	}, nil
+}
+
+// ValidateScheduling validates the node selector and tolerations of the job spec
+func (js *JobSpec) ValidateScheduling() error {
+	for k, v := range js.NodeSelector {
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return xerrors.Errorf("invalid node selector key %q: %s", k, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+			return xerrors.Errorf("invalid node selector value %q for %s: %s", v, k, strings.Join(errs, "; "))
+		}
+	}
+
+	for i, t := range js.Tolerations {
+		if t.Key != "" {
+			if errs := validation.IsQualifiedName(t.Key); len(errs) > 0 {
+				return xerrors.Errorf("invalid key %q of toleration %d: %s", t.Key, i, strings.Join(errs, "; "))
+			}
+		}
+		switch t.Operator {
+		case corev1.TolerationOpEqual, "":
+			if t.Key == "" {
+				return xerrors.Errorf("invalid toleration %d: operator must be Exists if key is empty", i)
+			}
+			if errs := validation.IsValidLabelValue(t.Value); len(errs) > 0 {
+				return xerrors.Errorf("invalid value %q of toleration %d: %s", t.Value, i, strings.Join(errs, "; "))
+			}
+		case corev1.TolerationOpExists:
+			if t.Value != "" {
+				return xerrors.Errorf("invalid toleration %d: value must be empty if operator is Exists", i)
+			}
+		default:
+			return xerrors.Errorf("invalid operator %q of toleration %d: must be Equal or Exists", t.Operator, i)
+		}
+		switch t.Effect {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return xerrors.Errorf("invalid effect %q of toleration %d", t.Effect, i)
+		}
+		if t.TolerationSeconds != nil && t.Effect != corev1.TaintEffectNoExecute {
+			return xerrors.Errorf("invalid toleration %d: tolerationSeconds requires the NoExecute effect", i)
+		}
+	}
+
+	return nil
+}
+
+// ValidateSecrets validates the secret references of the job spec
+func (js *JobSpec) ValidateSecrets() error {
+	for _, s := range js.Secrets {
+		if errs := validation.IsDNS1123Subdomain(s.Name); len(errs) > 0 {
+			return xerrors.Errorf("invalid secret name %q: %s", s.Name, strings.Join(errs, "; "))
+		}
+		if s.MountPath == "" && len(s.Env) == 0 {
+			return xerrors.Errorf("secret %s is neither mounted nor exposed as environment variable", s.Name)
+		}
+		if s.MountPath != "" && !path.IsAbs(s.MountPath) {
+			return xerrors.Errorf("mount path %q of secret %s must be absolute", s.MountPath, s.Name)
+		}
+		if s.MountPath == "" && len(s.Keys) > 0 {
+			return xerrors.Errorf("secret %s selects keys but has no mount path", s.Name)
+		}
+		for _, k := range s.Keys {
+			if errs := validation.IsConfigMapKey(k); len(errs) > 0 {
+				return xerrors.Errorf("invalid key %q of secret %s: %s", k, s.Name, strings.Join(errs, "; "))
+			}
+		}
+		for _, e := range s.Env {
+			if errs := validation.IsEnvVarName(e.Name); len(errs) > 0 {
+				return xerrors.Errorf("invalid environment variable name %q for secret %s: %s", e.Name, s.Name, strings.Join(errs, "; "))
+			}
+			if errs := validation.IsConfigMapKey(e.Key); len(errs) > 0 {
+				return xerrors.Errorf("invalid key %q of secret %s: %s", e.Key, s.Name, strings.Join(errs, "; "))
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateEnv validates the environment variables of the job spec
+func (js *JobSpec) ValidateEnv() error {
+	for name := range js.Env {
+		if errs := validation.IsEnvVarName(name); len(errs) > 0 {
+			return xerrors.Errorf("invalid environment variable name %q: %s", name, strings.Join(errs, "; "))
+		}
+	}
+	if js.EnvFile != "" && (path.IsAbs(js.EnvFile) || strings.HasPrefix(path.Clean(js.EnvFile), "..")) {
+		return xerrors.Errorf("env file %q must be a path within the repository", js.EnvFile)
+	}
+	return nil
+}
+
+// ParseEnvFile parses the KEY=value lines of an env file. Empty lines and lines starting with # are ignored,
+// values can be quoted and lines can start with export.
+func ParseEnvFile(content []byte) (map[string]string, error) {
+	res := make(map[string]string)
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		segs := strings.SplitN(line, "=", 2)
+		if len(segs) != 2 {
+			return nil, xerrors.Errorf("line %d: expected KEY=value", i+1)
+		}
+		name, value := strings.TrimSpace(segs[0]), strings.TrimSpace(segs[1])
+		if errs := validation.IsEnvVarName(name); len(errs) > 0 {
+			return nil, xerrors.Errorf("line %d: invalid environment variable name %q: %s", i+1, name, strings.Join(errs, "; "))
+		}
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		res[name] = value
+	}
+	return res, nil
+}
+
+// ValidateServices validates the service containers of the job spec
+func (js *JobSpec) ValidateServices() error {
+	names := make(map[string]struct{}, len(js.Services))
+	for _, svc := range js.Services {
+		if errs := validation.IsDNS1123Label(svc.Name); len(errs) > 0 {
+			return xerrors.Errorf("invalid service name %q: %s", svc.Name, strings.Join(errs, "; "))
+		}
+		if _, exists := names[svc.Name]; exists {
+			return xerrors.Errorf("service %s is declared more than once", svc.Name)
+		}
+		names[svc.Name] = struct{}{}
+		if svc.Image == "" {
+			return xerrors.Errorf("service %s has no image", svc.Name)
+		}
+
+		probe := svc.ReadinessProbe
+		if probe == nil {
+			continue
+		}
+		if probe.Exec == nil || len(probe.Exec.Command) == 0 {
+			return xerrors.Errorf("readiness probe of service %s must be an exec probe", svc.Name)
+		}
+		if svc.Lifecycle != nil && svc.Lifecycle.PostStart != nil {
+			return xerrors.Errorf("service %s must not have a postStart hook as it's used to wait for its readiness", svc.Name)
+		}
+	}
+	return nil
+}
+
+// ValidateCache validates the cache configuration of the job spec
+func (js *JobSpec) ValidateCache() error {
+	c := js.Cache
+	if c == nil {
+		return nil
+	}
+
+	if c.Path != "" {
+		if !path.IsAbs(c.Path) {
+			return xerrors.Errorf("cache path %q must be absolute", c.Path)
+		}
+		if p := path.Clean(c.Path); p == "/" || p == "/workspace" || strings.HasPrefix(p, "/workspace/") {
+			return xerrors.Errorf("cache path %q must not overlap with the workspace", c.Path)
+		}
+	}
+	if c.Size != "" {
+		q, err := resource.ParseQuantity(string(c.Size))
+		if err != nil {
+			return xerrors.Errorf("invalid cache size %q: %w", c.Size, err)
+		}
+		if q.Sign() <= 0 {
+			return xerrors.Errorf("invalid cache size %q: must be positive", c.Size)
+		}
+	}
+	if c.StorageClass != "" {
+		if errs := validation.IsDNS1123Subdomain(c.StorageClass); len(errs) > 0 {
+			return xerrors.Errorf("invalid cache storage class %q: %s", c.StorageClass, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// artifactNamePattern describes the names of artifacts
+var artifactNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9._-]*$`)
+
+// ValidateArtifacts ensures artifacts have valid names and stay within the workspace
+func (js *JobSpec) ValidateArtifacts() error {
+	if js.Artifacts == nil {
+		return nil
+	}
+
+	for _, as := range [][]ArtifactSpec{js.Artifacts.Outputs, js.Artifacts.Inputs} {
+		names := make(map[string]struct{}, len(as))
+		for _, a := range as {
+			if !artifactNamePattern.MatchString(a.Name) {
+				return xerrors.Errorf("invalid artifact name %q: must consist of letters, digits, '.', '-' and '_'", a.Name)
+			}
+			if _, exists := names[a.Name]; exists {
+				return xerrors.Errorf("artifact %s is listed more than once", a.Name)
+			}
+			names[a.Name] = struct{}{}
+
+			if p := a.ArtifactPath(); path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+				return xerrors.Errorf("artifact path %q must be relative to the workspace", a.Path)
+			}
+		}
+	}
+	return nil
+}
+
+// ExpandMatrix validates the matrix of the job spec and returns all combinations of its values. The combinations
+// are ordered by the values of the variables in their alphabetical order, the first variable changing slowest.
+// Returns nil if the job spec has no matrix.
+func (js *JobSpec) ExpandMatrix() ([]map[string]string, error) {
+	if len(js.Matrix) == 0 {
+		return nil, nil
+	}
+
+	vars := make([]string, 0, len(js.Matrix))
+	total := 1
+	for k, vs := range js.Matrix {
+		if errs := validation.IsCIdentifier(k); len(errs) > 0 {
+			return nil, xerrors.Errorf("invalid matrix variable %q: %s", k, strings.Join(errs, "; "))
+		}
+		if len(vs) == 0 {
+			return nil, xerrors.Errorf("matrix variable %s has no values", k)
+		}
+		total *= len(vs)
+		if total > MaxMatrixJobs {
+			return nil, xerrors.Errorf("matrix expands into more than %d jobs", MaxMatrixJobs)
+		}
+		vars = append(vars, k)
+	}
+	sort.Strings(vars)
+
+	res := make([]map[string]string, total)
+	for i := range res {
+		res[i] = make(map[string]string, len(vars))
+		rem := i
+		for j := len(vars) - 1; j >= 0; j-- {
+			vs := js.Matrix[vars[j]]
+			res[i][vars[j]] = vs[rem%len(vs)]
+			rem /= len(vs)
+		}
+	}
+	return res, nil
+}
+
+func parseResourceList(spec map[string]Quantity) (corev1.ResourceList, error) {
+	if len(spec) == 0 {
+		return nil, nil
+	}
+
+	res := make(corev1.ResourceList, len(spec))
+	for name, val := range spec {
+		rn := corev1.ResourceName(name)
+		if rn != corev1.ResourceCPU && rn != corev1.ResourceMemory {
+			return nil, xerrors.Errorf("unsupported resource %q: only cpu and memory are supported", name)
+		}
+
+		q, err := resource.ParseQuantity(string(val))
+		if err != nil {
+			return nil, xerrors.Errorf("%s quantity %q: %w", name, val, err)
+		}
+		if q.Sign() < 0 {
+			return nil, xerrors.Errorf("%s quantity %q must not be negative", name, val)
+		}
+		res[rn] = q
+	}
+	return res, nil
+}
+
+// ParseTimeout parses the timeout of the job spec. Returns zero if no timeout is set.
+func (js *JobSpec) ParseTimeout() (time.Duration, error) {
+	if js.Timeout == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(js.Timeout)
+	if err != nil {
+		return 0, xerrors.Errorf("invalid timeout %q: %w", js.Timeout, err)
+	}
+	if d <= 0 {
+		return 0, xerrors.Errorf("invalid timeout %q: must be positive", js.Timeout)
+	}
+	return d, nil
+}
+
+// ParsePreStop validates the pre-stop command of the job spec and parses its grace period.
+// Returns zero if the job spec has no pre-stop command.
+func (js *JobSpec) ParsePreStop() (gracePeriod time.Duration, err error) {
+	ps := js.PreStop
+	if ps == nil {
+		return 0, nil
+	}
+	if len(ps.Command) == 0 {
+		return 0, xerrors.Errorf("preStop needs a command")
+	}
+	if ps.GracePeriod == "" {
+		return DefaultPreStopGracePeriod, nil
+	}
+
+	gracePeriod, err = time.ParseDuration(ps.GracePeriod)
+	if err != nil {
+		return 0, xerrors.Errorf("invalid preStop grace period %q: %w", ps.GracePeriod, err)
+	}
+	if gracePeriod < time.Second {
+		return 0, xerrors.Errorf("invalid preStop grace period %q: must be at least one second", ps.GracePeriod)
+	}
+	return gracePeriod, nil
+}
+
+// ParsePodTTL parses the pod TTL of the job spec. Returns false if the job spec does not set a pod TTL.
+func (js *JobSpec) ParsePodTTL() (ttl time.Duration, ok bool, err error) {
+	if js.PodTTL == "" {
+		return 0, false, nil
+	}
+	ttl, err = time.ParseDuration(js.PodTTL)
+	if err != nil {
+		return 0, false, xerrors.Errorf("invalid pod TTL %q: %w", js.PodTTL, err)
+	}
+	if ttl < 0 {
+		return 0, false, xerrors.Errorf("invalid pod TTL %q: must not be negative", js.PodTTL)
+	}
+	return ttl, true, nil
 }
 
 // ArgSpec specifies an argument/annotation for a job.
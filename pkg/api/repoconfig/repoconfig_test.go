@@ -2,11 +2,18 @@ package repoconfig_test
 
 import (
 	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/csweichel/werft/pkg/api/repoconfig"
 	v1 "github.com/csweichel/werft/pkg/api/v1"
 	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 func TestUnmarshalC(t *testing.T) {
@@ -14,7 +21,7 @@ func TestUnmarshalC(t *testing.T) {
 		Source      string
 		Expectation string
 	}{
-		{`defaultJob: "foo.yaml"`, `{"DefaultJob":"foo.yaml","Rules":null}`},
+		{`defaultJob: "foo.yaml"`, `{"DefaultJob":"foo.yaml","Rules":null,"GitHub":null,"Templates":null,"JobName":"","DefaultImage":"","Annotations":null}`},
 		{
 			`rules:
 - path: ""
@@ -23,7 +30,7 @@ func TestUnmarshalC(t *testing.T) {
 - path: ""
   matchesAll:
   - or: ["repo.ref !~= refs/branches/"]`,
-			`{"DefaultJob":"","Rules":[{"Path":"","Expr":[{"terms":[{"field":"repo.ref","value":"refs/tags/","operation":3}]}]},{"Path":"","Expr":[{"terms":[{"field":"repo.ref","value":"refs/branches/","operation":3,"negate":true}]}]}]}`,
+			`{"DefaultJob":"","Rules":[{"Path":"","Expr":[{"terms":[{"field":"repo.ref","value":"refs/tags/","operation":3}]}],"Branches":null,"Tags":null,"Paths":null},{"Path":"","Expr":[{"terms":[{"field":"repo.ref","value":"refs/branches/","operation":3,"negate":true}]}],"Branches":null,"Tags":null,"Paths":null}],"GitHub":null,"Templates":null,"JobName":"","DefaultImage":"","Annotations":null}`,
 		},
 		{
 			`rules:
@@ -33,7 +40,43 @@ func TestUnmarshalC(t *testing.T) {
     - "repo.ref ~= refs/branches/"
   - or:
     - "name !~= 0"
-`, `{"DefaultJob":"","Rules":[{"Path":"foo.yaml","Expr":[{"terms":[{"field":"repo.ref","value":"refs/branches/","operation":3}]},{"terms":[{"field":"name","value":"0","operation":3,"negate":true}]}]}]}`,
+`, `{"DefaultJob":"","Rules":[{"Path":"foo.yaml","Expr":[{"terms":[{"field":"repo.ref","value":"refs/branches/","operation":3}]},{"terms":[{"field":"name","value":"0","operation":3,"negate":true}]}],"Branches":null,"Tags":null,"Paths":null}],"GitHub":null,"Templates":null,"JobName":"","DefaultImage":"","Annotations":null}`,
+		},
+		{
+			`github:
+  checks: true
+  checkAnnotations: true`,
+			`{"DefaultJob":"","Rules":null,"GitHub":{"Checks":true,"CheckAnnotations":true},"Templates":null,"JobName":"","DefaultImage":"","Annotations":null}`,
+		},
+		{
+			`rules:
+- path: "docs.yaml"
+  branches: ["main", "release/*"]
+  paths: ["docs/**", "!docs/internal/**"]`,
+			`{"DefaultJob":"","Rules":[{"Path":"docs.yaml","Expr":null,"Branches":["main","release/*"],"Tags":null,"Paths":["docs/**","!docs/internal/**"]}],"GitHub":null,"Templates":null,"JobName":"","DefaultImage":"","Annotations":null}`,
+		},
+		{
+			`templates:
+  go: .werft/templates/go.yaml`,
+			`{"DefaultJob":"","Rules":null,"GitHub":null,"Templates":{"go":".werft/templates/go.yaml"},"JobName":"","DefaultImage":"","Annotations":null}`,
+		},
+		{
+			`jobName: "${repo.repo}-${repo.shortRef}-${job.counter}"`,
+			`{"DefaultJob":"","Rules":null,"GitHub":null,"Templates":null,"JobName":"${repo.repo}-${repo.shortRef}-${job.counter}","DefaultImage":"","Annotations":null}`,
+		},
+		{
+			`defaultImage: eu.gcr.io/werft/build:latest`,
+			`{"DefaultJob":"","Rules":null,"GitHub":null,"Templates":null,"JobName":"","DefaultImage":"eu.gcr.io/werft/build:latest","Annotations":null}`,
+		},
+		{
+			`defaultImage: localhost:5000/build@sha256:7d1a2e5b3f6c8d9e0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f`,
+			`{"DefaultJob":"","Rules":null,"GitHub":null,"Templates":null,"JobName":"","DefaultImage":"localhost:5000/build@sha256:7d1a2e5b3f6c8d9e0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f","Annotations":null}`,
+		},
+		{
+			`annotations:
+  team: platform
+  owner: csweichel`,
+			`{"DefaultJob":"","Rules":null,"GitHub":null,"Templates":null,"JobName":"","DefaultImage":"","Annotations":{"owner":"csweichel","team":"platform"}}`,
 		},
 	}
 
@@ -110,3 +153,565 @@ func TestTemplatePath(t *testing.T) {
 		}
 	}
 }
+
+func TestTemplatePathForChanges(t *testing.T) {
+	var (
+		branchRule = &repoconfig.JobStartRule{Path: "release.yaml", Branches: []string{"main", "release/*", "!release/old-*"}}
+		docsRule   = &repoconfig.JobStartRule{Path: "docs.yaml", Paths: []string{"docs/**", "!docs/internal/**"}}
+		excludeAll = &repoconfig.JobStartRule{Path: "code.yaml", Paths: []string{"!**/*.md"}}
+		tagRule    = &repoconfig.JobStartRule{Path: "publish.yaml", Tags: []string{"v*", "!v*-rc*"}}
+		refRule    = &repoconfig.JobStartRule{Path: "deploy.yaml", Branches: []string{"main"}, Tags: []string{"v*"}}
+		cfg        = repoconfig.C{DefaultJob: "build.yaml", Rules: []*repoconfig.JobStartRule{branchRule, docsRule}}
+		tagCfg     = repoconfig.C{DefaultJob: "build.yaml", Rules: []*repoconfig.JobStartRule{tagRule}}
+		refCfg     = repoconfig.C{Rules: []*repoconfig.JobStartRule{refRule}}
+		skipCfg    = repoconfig.C{Rules: []*repoconfig.JobStartRule{docsRule}}
+		exclCfg    = repoconfig.C{Rules: []*repoconfig.JobStartRule{excludeAll}}
+	)
+	md := func(ref string) *v1.JobMetadata {
+		return &v1.JobMetadata{Repository: &v1.Repository{Ref: ref}}
+	}
+
+	tests := []struct {
+		Name    string
+		C       repoconfig.C
+		M       *v1.JobMetadata
+		Changes []string
+		E       string
+	}{
+		{"branch include", cfg, md("refs/heads/main"), nil, "release.yaml"},
+		{"branch glob", cfg, md("refs/heads/release/v1"), nil, "release.yaml"},
+		{"branch glob does not cross segments", cfg, md("refs/heads/release/v1/hotfix"), []string{"main.go"}, "build.yaml"},
+		{"branch exclude", cfg, md("refs/heads/release/old-v0"), []string{"main.go"}, "build.yaml"},
+		{"short ref", cfg, md("main"), nil, "release.yaml"},
+		{"tags are no branches", cfg, md("refs/tags/main"), []string{"main.go"}, "build.yaml"},
+		{"no ref", cfg, md(""), []string{"main.go"}, "build.yaml"},
+		{"path include", cfg, md("refs/heads/feature"), []string{"main.go", "docs/guide/intro.md"}, "docs.yaml"},
+		{"path exclude", cfg, md("refs/heads/feature"), []string{"docs/internal/notes.md"}, "build.yaml"},
+		{"path include and exclude", cfg, md("refs/heads/feature"), []string{"docs/internal/notes.md", "docs/index.md"}, "docs.yaml"},
+		{"unknown changes ignore path filter", cfg, md("refs/heads/feature"), nil, "docs.yaml"},
+		{"no path matches and no default", skipCfg, md("refs/heads/feature"), []string{"main.go"}, ""},
+		{"exclude only matches other files", exclCfg, md("refs/heads/feature"), []string{"README.md", "main.go"}, "code.yaml"},
+		{"exclude only with excluded files", exclCfg, md("refs/heads/feature"), []string{"README.md", "docs/intro.md"}, ""},
+		{"tag include", tagCfg, md("refs/tags/v1.0.0"), nil, "publish.yaml"},
+		{"tag exclude", tagCfg, md("refs/tags/v1.0.0-rc1"), nil, "build.yaml"},
+		{"branches are no tags", tagCfg, md("refs/heads/v1"), nil, "build.yaml"},
+		{"short refs are no tags", tagCfg, md("v1.0.0"), nil, "build.yaml"},
+		{"branch or tag matches branch", refCfg, md("refs/heads/main"), nil, "deploy.yaml"},
+		{"branch or tag matches tag", refCfg, md("refs/tags/v1.0.0"), nil, "deploy.yaml"},
+		{"branch or tag matches neither", refCfg, md("refs/tags/main"), nil, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			act := test.C.TemplatePathForChanges(test.M, test.Changes)
+			if act != test.E {
+				t.Errorf("expected %q, actual %q", test.E, act)
+			}
+		})
+	}
+}
+
+func TestUnmarshalInvalidGlob(t *testing.T) {
+	tests := []string{
+		`rules: [{path: "foo.yaml", branches: ["release/["]}]`,
+		`rules: [{path: "foo.yaml", paths: ["!"]}]`,
+		`rules: [{path: "foo.yaml", tags: ["v["]}]`,
+	}
+	for _, test := range tests {
+		var c repoconfig.C
+		err := yaml.Unmarshal([]byte(test), &c)
+		if err == nil {
+			t.Errorf("expected error for %s", test)
+		}
+	}
+}
+
+func TestUnmarshalInvalidImage(t *testing.T) {
+	tests := []string{
+		`defaultImage: "Golang:1.17"`,
+		`defaultImage: "golang:"`,
+		`defaultImage: "golang 1.17"`,
+		`defaultImage: "eu.gcr.io/werft/build@sha256:abc"`,
+	}
+	for _, test := range tests {
+		var c repoconfig.C
+		err := yaml.Unmarshal([]byte(test), &c)
+		if err == nil {
+			t.Errorf("expected error for %s", test)
+		}
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		Timeout string
+		E       time.Duration
+		Err     bool
+	}{
+		{"", 0, false},
+		{"30m", 30 * time.Minute, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"0s", 0, true},
+		{"-5m", 0, true},
+		{"forever", 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.Timeout, func(t *testing.T) {
+			js := repoconfig.JobSpec{Timeout: test.Timeout}
+			act, err := js.ParseTimeout()
+			if (err != nil) != test.Err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if act != test.E {
+				t.Errorf("expected %s, actual %s", test.E, act)
+			}
+		})
+	}
+}
+
+func TestParsePreStop(t *testing.T) {
+	cmd := []string{"sh", "-c", "make clean"}
+	tests := []struct {
+		Name    string
+		PreStop *repoconfig.PreStopSpec
+		E       time.Duration
+		Err     bool
+	}{
+		{Name: "none"},
+		{Name: "default grace period", PreStop: &repoconfig.PreStopSpec{Command: cmd}, E: repoconfig.DefaultPreStopGracePeriod},
+		{Name: "grace period", PreStop: &repoconfig.PreStopSpec{Command: cmd, GracePeriod: "2m"}, E: 2 * time.Minute},
+		{Name: "no command", PreStop: &repoconfig.PreStopSpec{GracePeriod: "2m"}, Err: true},
+		{Name: "too short", PreStop: &repoconfig.PreStopSpec{Command: cmd, GracePeriod: "500ms"}, Err: true},
+		{Name: "invalid", PreStop: &repoconfig.PreStopSpec{Command: cmd, GracePeriod: "a bit"}, Err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			js := repoconfig.JobSpec{PreStop: test.PreStop}
+			act, err := js.ParsePreStop()
+			if (err != nil) != test.Err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if act != test.E {
+				t.Errorf("expected %s, actual %s", test.E, act)
+			}
+		})
+	}
+}
+
+func TestParsePodTTL(t *testing.T) {
+	tests := []struct {
+		PodTTL string
+		E      time.Duration
+		OK     bool
+		Err    bool
+	}{
+		{"", 0, false, false},
+		{"0s", 0, true, false},
+		{"2h", 2 * time.Hour, true, false},
+		{"-1h", 0, false, true},
+		{"a while", 0, false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.PodTTL, func(t *testing.T) {
+			js := repoconfig.JobSpec{PodTTL: test.PodTTL}
+			act, ok, err := js.ParsePodTTL()
+			if (err != nil) != test.Err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if act != test.E || ok != test.OK {
+				t.Errorf("expected (%s, %v), actual (%s, %v)", test.E, test.OK, act, ok)
+			}
+		})
+	}
+}
+
+func TestParseResources(t *testing.T) {
+	tests := []struct {
+		Name      string
+		Resources *repoconfig.ResourceSpec
+		E         *corev1.ResourceRequirements
+		Err       bool
+	}{
+		{Name: "no resources"},
+		{
+			Name: "requests and limits",
+			Resources: &repoconfig.ResourceSpec{
+				Requests: map[string]repoconfig.Quantity{"cpu": "500m", "memory": "1Gi"},
+				Limits:   map[string]repoconfig.Quantity{"cpu": "2", "memory": "1Gi"},
+			},
+			E: &corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("500m"),
+					corev1.ResourceMemory: resource.MustParse("1Gi"),
+				},
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("2"),
+					corev1.ResourceMemory: resource.MustParse("1Gi"),
+				},
+			},
+		},
+		{
+			Name:      "limits only",
+			Resources: &repoconfig.ResourceSpec{Limits: map[string]repoconfig.Quantity{"memory": "512Mi"}},
+			E: &corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+			},
+		},
+		{
+			Name:      "malformed quantity",
+			Resources: &repoconfig.ResourceSpec{Requests: map[string]repoconfig.Quantity{"memory": "lots"}},
+			Err:       true,
+		},
+		{
+			Name:      "negative quantity",
+			Resources: &repoconfig.ResourceSpec{Requests: map[string]repoconfig.Quantity{"cpu": "-1"}},
+			Err:       true,
+		},
+		{
+			Name:      "unsupported resource",
+			Resources: &repoconfig.ResourceSpec{Requests: map[string]repoconfig.Quantity{"nvidia.com/gpu": "1"}},
+			Err:       true,
+		},
+		{
+			Name: "request exceeds limit",
+			Resources: &repoconfig.ResourceSpec{
+				Requests: map[string]repoconfig.Quantity{"cpu": "4"},
+				Limits:   map[string]repoconfig.Quantity{"cpu": "2"},
+			},
+			Err: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			js := repoconfig.JobSpec{Resources: test.Resources}
+			act, err := js.ParseResources()
+			if (err != nil) != test.Err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !equality.Semantic.DeepEqual(act, test.E) {
+				t.Errorf("expected %v, actual %v", test.E, act)
+			}
+		})
+	}
+}
+
+func TestValidateScheduling(t *testing.T) {
+	tests := []struct {
+		Name string
+		Spec repoconfig.JobSpec
+		Err  bool
+	}{
+		{Name: "empty"},
+		{
+			Name: "valid",
+			Spec: repoconfig.JobSpec{
+				NodeSelector: map[string]string{"cloud.google.com/gke-nodepool": "ci"},
+				Tolerations: []corev1.Toleration{
+					{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "ci", Effect: corev1.TaintEffectNoSchedule},
+					{Operator: corev1.TolerationOpExists},
+				},
+			},
+		},
+		{Name: "invalid selector key", Spec: repoconfig.JobSpec{NodeSelector: map[string]string{"not a key": "ci"}}, Err: true},
+		{Name: "invalid selector value", Spec: repoconfig.JobSpec{NodeSelector: map[string]string{"pool": "c i"}}, Err: true},
+		{Name: "equal without key", Spec: repoconfig.JobSpec{Tolerations: []corev1.Toleration{{Value: "ci"}}}, Err: true},
+		{Name: "exists with value", Spec: repoconfig.JobSpec{Tolerations: []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists, Value: "ci"}}}, Err: true},
+		{Name: "unknown operator", Spec: repoconfig.JobSpec{Tolerations: []corev1.Toleration{{Key: "dedicated", Operator: "In"}}}, Err: true},
+		{Name: "unknown effect", Spec: repoconfig.JobSpec{Tolerations: []corev1.Toleration{{Key: "dedicated", Value: "ci", Effect: "Evict"}}}, Err: true},
+		{
+			Name: "seconds without NoExecute",
+			Spec: repoconfig.JobSpec{Tolerations: []corev1.Toleration{{Key: "dedicated", Value: "ci", Effect: corev1.TaintEffectNoSchedule, TolerationSeconds: new(int64)}}},
+			Err:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			err := test.Spec.ValidateScheduling()
+			if (err != nil) != test.Err {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateSecrets(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Secrets []repoconfig.SecretSpec
+		Err     bool
+	}{
+		{Name: "empty"},
+		{
+			Name: "valid",
+			Secrets: []repoconfig.SecretSpec{
+				{Name: "registry", MountPath: "/mnt/secrets/registry", Keys: []string{".dockerconfigjson"}},
+				{Name: "deploy", Env: []repoconfig.SecretEnvSpec{{Name: "DEPLOY_TOKEN", Key: "token"}}},
+			},
+		},
+		{Name: "invalid name", Secrets: []repoconfig.SecretSpec{{Name: "Not_A_Name", MountPath: "/mnt"}}, Err: true},
+		{Name: "unused", Secrets: []repoconfig.SecretSpec{{Name: "deploy"}}, Err: true},
+		{Name: "relative mount path", Secrets: []repoconfig.SecretSpec{{Name: "deploy", MountPath: "secrets"}}, Err: true},
+		{Name: "keys without mount path", Secrets: []repoconfig.SecretSpec{{Name: "deploy", Keys: []string{"token"}, Env: []repoconfig.SecretEnvSpec{{Name: "TOKEN", Key: "token"}}}}, Err: true},
+		{Name: "invalid key", Secrets: []repoconfig.SecretSpec{{Name: "deploy", MountPath: "/mnt", Keys: []string{"../token"}}}, Err: true},
+		{Name: "invalid env name", Secrets: []repoconfig.SecretSpec{{Name: "deploy", Env: []repoconfig.SecretEnvSpec{{Name: "1TOKEN", Key: "token"}}}}, Err: true},
+		{Name: "missing env key", Secrets: []repoconfig.SecretSpec{{Name: "deploy", Env: []repoconfig.SecretEnvSpec{{Name: "TOKEN"}}}}, Err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			js := repoconfig.JobSpec{Secrets: test.Secrets}
+			err := js.ValidateSecrets()
+			if (err != nil) != test.Err {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateCache(t *testing.T) {
+	tests := []struct {
+		Name  string
+		Cache *repoconfig.CacheSpec
+		Err   bool
+	}{
+		{Name: "no cache"},
+		{Name: "defaults", Cache: &repoconfig.CacheSpec{}},
+		{Name: "valid", Cache: &repoconfig.CacheSpec{Key: "go-mod", Path: "/go/pkg/mod", Size: "10Gi", StorageClass: "ssd"}},
+		{Name: "relative path", Cache: &repoconfig.CacheSpec{Path: "cache"}, Err: true},
+		{Name: "workspace path", Cache: &repoconfig.CacheSpec{Path: "/workspace/node_modules"}, Err: true},
+		{Name: "malformed size", Cache: &repoconfig.CacheSpec{Size: "10 gigs"}, Err: true},
+		{Name: "zero size", Cache: &repoconfig.CacheSpec{Size: "0"}, Err: true},
+		{Name: "invalid storage class", Cache: &repoconfig.CacheSpec{StorageClass: "Fast_SSD"}, Err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			js := repoconfig.JobSpec{Cache: test.Cache}
+			err := js.ValidateCache()
+			if (err != nil) != test.Err {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateArtifacts(t *testing.T) {
+	tests := []struct {
+		Name      string
+		Artifacts *repoconfig.ArtifactsSpec
+		Err       bool
+	}{
+		{Name: "no artifacts"},
+		{Name: "valid", Artifacts: &repoconfig.ArtifactsSpec{
+			Outputs: []repoconfig.ArtifactSpec{{Name: "dist"}, {Name: "coverage", Path: "reports/coverage"}},
+			Inputs:  []repoconfig.ArtifactSpec{{Name: "dist", Path: "./bin"}},
+		}},
+		{Name: "same name as input and output", Artifacts: &repoconfig.ArtifactsSpec{
+			Outputs: []repoconfig.ArtifactSpec{{Name: "dist"}},
+			Inputs:  []repoconfig.ArtifactSpec{{Name: "dist"}},
+		}},
+		{Name: "duplicate name", Artifacts: &repoconfig.ArtifactsSpec{Outputs: []repoconfig.ArtifactSpec{{Name: "dist"}, {Name: "dist", Path: "bin"}}}, Err: true},
+		{Name: "invalid name", Artifacts: &repoconfig.ArtifactsSpec{Outputs: []repoconfig.ArtifactSpec{{Name: "../dist"}}}, Err: true},
+		{Name: "no name", Artifacts: &repoconfig.ArtifactsSpec{Inputs: []repoconfig.ArtifactSpec{{Path: "dist"}}}, Err: true},
+		{Name: "absolute path", Artifacts: &repoconfig.ArtifactsSpec{Outputs: []repoconfig.ArtifactSpec{{Name: "dist", Path: "/dist"}}}, Err: true},
+		{Name: "path outside workspace", Artifacts: &repoconfig.ArtifactsSpec{Inputs: []repoconfig.ArtifactSpec{{Name: "dist", Path: "bin/../../dist"}}}, Err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			js := repoconfig.JobSpec{Artifacts: test.Artifacts}
+			err := js.ValidateArtifacts()
+			if (err != nil) != test.Err {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateServices(t *testing.T) {
+	execProbe := &corev1.Probe{Handler: corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"pg_isready"}}}}
+	tests := []struct {
+		Name     string
+		Services []corev1.Container
+		Err      bool
+	}{
+		{Name: "empty"},
+		{Name: "valid", Services: []corev1.Container{{Name: "postgres", Image: "postgres", ReadinessProbe: execProbe}, {Name: "redis", Image: "redis"}}},
+		{Name: "invalid name", Services: []corev1.Container{{Name: "Postgres_DB", Image: "postgres"}}, Err: true},
+		{Name: "duplicate name", Services: []corev1.Container{{Name: "db", Image: "postgres"}, {Name: "db", Image: "mysql"}}, Err: true},
+		{Name: "missing image", Services: []corev1.Container{{Name: "postgres"}}, Err: true},
+		{
+			Name: "non-exec probe",
+			Services: []corev1.Container{{Name: "web", Image: "nginx", ReadinessProbe: &corev1.Probe{
+				Handler: corev1.Handler{HTTPGet: &corev1.HTTPGetAction{Path: "/"}},
+			}}},
+			Err: true,
+		},
+		{
+			Name: "post start hook",
+			Services: []corev1.Container{{Name: "postgres", Image: "postgres", ReadinessProbe: execProbe, Lifecycle: &corev1.Lifecycle{
+				PostStart: &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"true"}}},
+			}}},
+			Err: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			js := repoconfig.JobSpec{Services: test.Services}
+			err := js.ValidateServices()
+			if (err != nil) != test.Err {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestExpandMatrix(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Matrix      map[string][]string
+		Cardinality int
+		First       map[string]string
+		Err         bool
+	}{
+		{Name: "no matrix"},
+		{Name: "single variable", Matrix: map[string][]string{"go": {"1.16", "1.17"}}, Cardinality: 2, First: map[string]string{"go": "1.16"}},
+		{
+			Name:        "product",
+			Matrix:      map[string][]string{"os": {"linux", "darwin", "windows"}, "go": {"1.16", "1.17"}},
+			Cardinality: 6,
+			First:       map[string]string{"go": "1.16", "os": "linux"},
+		},
+		{Name: "no values", Matrix: map[string][]string{"go": {"1.16"}, "os": {}}, Err: true},
+		{Name: "invalid variable", Matrix: map[string][]string{"go-version": {"1.16"}}, Err: true},
+		{Name: "too many jobs", Matrix: map[string][]string{"a": make([]string, 8), "b": make([]string, 9)}, Err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			js := repoconfig.JobSpec{Matrix: test.Matrix}
+			res, err := js.ExpandMatrix()
+			if (err != nil) != test.Err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(res) != test.Cardinality {
+				t.Fatalf("unexpected number of combinations: expected %d, got %d", test.Cardinality, len(res))
+			}
+			if len(res) > 0 && !reflect.DeepEqual(res[0], test.First) {
+				t.Errorf("unexpected first combination: expected %v, got %v", test.First, res[0])
+			}
+
+			seen := make(map[string]struct{}, len(res))
+			for _, c := range res {
+				if len(c) != len(test.Matrix) {
+					t.Errorf("combination %v does not set all variables", c)
+				}
+				key := fmt.Sprint(c)
+				if _, ok := seen[key]; ok {
+					t.Errorf("duplicate combination %v", c)
+				}
+				seen[key] = struct{}{}
+			}
+		})
+	}
+}
+
+func TestValidateEnv(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Env     map[string]string
+		EnvFile string
+		Err     bool
+	}{
+		{Name: "empty"},
+		{Name: "valid", Env: map[string]string{"LOG_LEVEL": "debug", "app.version": "1.0"}, EnvFile: ".werft/build.env"},
+		{Name: "invalid name", Env: map[string]string{"1LOG": "debug"}, Err: true},
+		{Name: "absolute env file", EnvFile: "/etc/build.env", Err: true},
+		{Name: "env file outside the repo", EnvFile: ".werft/../../build.env", Err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			js := repoconfig.JobSpec{Env: test.Env, EnvFile: test.EnvFile}
+			err := js.ValidateEnv()
+			if (err != nil) != test.Err {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseEnvFile(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Content     string
+		Expectation map[string]string
+		Error       string
+	}{
+		{Name: "empty", Expectation: map[string]string{}},
+		{
+			Name:        "valid",
+			Content:     "# build config\n\nLOG_LEVEL=debug\nexport REGISTRY=eu.gcr.io/werft\nGREETING=\"hello world\"\nQUOTED='a=b'\nEMPTY=\n",
+			Expectation: map[string]string{"LOG_LEVEL": "debug", "REGISTRY": "eu.gcr.io/werft", "GREETING": "hello world", "QUOTED": "a=b", "EMPTY": ""},
+		},
+		{Name: "missing value", Content: "LOG_LEVEL=debug\nREGISTRY\n", Error: "line 2: expected KEY=value"},
+		{Name: "invalid name", Content: "1LOG=debug\n", Error: `line 1: invalid environment variable name "1LOG"`},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			act, err := repoconfig.ParseEnvFile([]byte(test.Content))
+			if test.Error != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.Error) {
+					t.Fatalf("expected error %q, got %v", test.Error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(act, test.Expectation) {
+				t.Errorf("unexpected env: %v, expected %v", act, test.Expectation)
+			}
+		})
+	}
+}
+
+func TestMarshalJobSpec(t *testing.T) {
+	js := &repoconfig.JobSpec{
+		Timeout:  "30m",
+		Sidecars: []string{"db"},
+		Env:      map[string]string{"GOFLAGS": "-mod=readonly"},
+		Pod: &corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:            "build",
+					Image:           "golang:1.17",
+					ImagePullPolicy: corev1.PullIfNotPresent,
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+					},
+				},
+				{Name: "db", Image: "postgres"},
+			},
+		},
+	}
+	act, err := repoconfig.MarshalJobSpec(js)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `env:
+    GOFLAGS: -mod=readonly
+pod:
+    containers:
+        - image: golang:1.17
+          imagePullPolicy: IfNotPresent
+          name: build
+          resources:
+            requests:
+                cpu: 500m
+        - image: postgres
+          name: db
+sidecars:
+    - db
+timeout: 30m
+`
+	if string(act) != expected {
+		t.Errorf("unexpected job spec:\n%s\nexpected:\n%s", act, expected)
+	}
+}
@@ -0,0 +1,274 @@
+package repoconfig
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// MaxTemplateDepth limits how many templates a job spec can extend in a chain
+const MaxTemplateDepth = 8
+
+// templateMarker separates the job spec from the templates it extends once they're bundled
+const templateMarker = "# werft:template "
+
+// envFileMarker separates the env file a job spec references from the job spec and its templates once they're bundled
+const envFileMarker = "# werft:envfile "
+
+// defaultImageMarker adds the default image of the repository to a job spec once it's bundled
+const defaultImageMarker = "# werft:defaultImage "
+
+// extendsPattern finds the template a job spec or template extends. Job specs are Go templates and need not be
+// valid YAML before they're rendered, hence we cannot parse them but look for a plain top-level extends key.
+var extendsPattern = regexp.MustCompile(`(?m)^extends:[ \t]*["']?([^"'\s#]*)["']?[ \t]*(#.*)?$`)
+
+// envFilePattern finds the env file a job spec or template references, like extendsPattern
+var envFilePattern = regexp.MustCompile(`(?m)^envFile:[ \t]*["']?([^"'\s#]*)["']?[ \t]*(#.*)?$`)
+
+// defaultImagePattern finds the default image bundled with a job spec
+var defaultImagePattern = regexp.MustCompile(`(?m)^` + defaultImageMarker + `(\S+)$`)
+
+// templateNamePattern describes the names of templates in the repo config
+var templateNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// Extends returns the template the job spec extends, or an empty string if it doesn't extend one
+func Extends(spec []byte) string {
+	m := extendsPattern.FindSubmatch(spec)
+	if m == nil {
+		return ""
+	}
+	return string(m[1])
+}
+
+// EnvFile returns the env file the job spec references, or an empty string if it doesn't reference one
+func EnvFile(spec []byte) string {
+	m := envFilePattern.FindSubmatch(spec)
+	if m == nil {
+		return ""
+	}
+	return string(m[1])
+}
+
+// NeedsBundle returns true if the job spec references files of the repository which BundleTemplates must add to it
+func NeedsBundle(spec []byte) bool {
+	return Extends(spec) != "" || EnvFile(spec) != ""
+}
+
+// BundleTemplates appends the templates a job spec extends, directly or through other templates, and the env file
+// it references to the job spec. The bundle can be rendered without access to the repository, e.g. when the job is
+// restarted later on.
+//
+// A template is either the name of a template in the repo config or a path within the repository. read reads
+// a file of the repository. Bundling a bundle again returns it unchanged.
+func BundleTemplates(spec []byte, templates map[string]string, read func(path string) ([]byte, error)) ([]byte, error) {
+	if bytes.Contains(spec, []byte("\n"+templateMarker)) || bytes.Contains(spec, []byte("\n"+envFileMarker)) {
+		return spec, nil
+	}
+
+	var (
+		res     = bytes.NewBuffer(append([]byte{}, spec...))
+		chain   []string
+		cur     = spec
+		envFile = EnvFile(spec)
+	)
+	for {
+		ref := Extends(cur)
+		if ref == "" {
+			break
+		}
+		for _, r := range chain {
+			if r == ref {
+				return nil, xerrors.Errorf("templates extend each other: %s -> %s", strings.Join(chain, " -> "), ref)
+			}
+		}
+		chain = append(chain, ref)
+		if len(chain) > MaxTemplateDepth {
+			return nil, xerrors.Errorf("too many templates extend each other: %s", strings.Join(chain, " -> "))
+		}
+
+		path, err := templatePath(ref, templates)
+		if err != nil {
+			return nil, err
+		}
+		cur, err = read(path)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot read template %s: %w", ref, err)
+		}
+
+		if !bytes.HasSuffix(res.Bytes(), []byte("\n")) {
+			res.WriteString("\n")
+		}
+		res.WriteString(templateMarker + ref + "\n")
+		res.Write(cur)
+
+		// the job spec takes precedence over the templates it extends
+		if envFile == "" {
+			envFile = EnvFile(cur)
+		}
+	}
+
+	if envFile != "" {
+		content, err := read(strings.TrimPrefix(envFile, "/"))
+		if err != nil {
+			return nil, xerrors.Errorf("cannot read env file %s: %w", envFile, err)
+		}
+		if !bytes.HasSuffix(res.Bytes(), []byte("\n")) {
+			res.WriteString("\n")
+		}
+		res.WriteString(envFileMarker + envFile + "\n")
+		res.Write(content)
+	}
+	return res.Bytes(), nil
+}
+
+// BundleDefaultImage adds the default image of the repository to a job spec or bundle, so that jobs use it even when
+// they're restarted later on with another repo config. Bundling the default image again returns the bundle unchanged.
+func BundleDefaultImage(bundle []byte, image string) []byte {
+	if DefaultImage(bundle) != "" {
+		return bundle
+	}
+
+	line := []byte(defaultImageMarker + image + "\n")
+	// the default image belongs to the job spec, not the templates or env file which follow it
+	idx := bytes.Index(bundle, []byte("\n# werft:"))
+	if idx < 0 {
+		res := append([]byte{}, bundle...)
+		if len(res) > 0 && res[len(res)-1] != '\n' {
+			res = append(res, '\n')
+		}
+		return append(res, line...)
+	}
+
+	res := append([]byte{}, bundle[:idx+1]...)
+	res = append(res, line...)
+	return append(res, bundle[idx+1:]...)
+}
+
+// DefaultImage returns the default image bundled with a job spec, or an empty string if there is none
+func DefaultImage(bundle []byte) string {
+	m := defaultImagePattern.FindSubmatch(bundle)
+	if m == nil {
+		return ""
+	}
+	return string(m[1])
+}
+
+// SplitEnvFile splits the env file off a bundle produced by BundleTemplates. It returns the rest of the bundle and
+// the path and content of the env file, or an empty path if the bundle has no env file.
+func SplitEnvFile(bundle []byte) (rest []byte, path string, content []byte) {
+	idx := bytes.Index(bundle, []byte("\n"+envFileMarker))
+	if idx < 0 {
+		return bundle, "", nil
+	}
+
+	rest = bundle[:idx+1]
+	section := bundle[idx+1+len(envFileMarker):]
+	nl := bytes.IndexByte(section, '\n')
+	if nl < 0 {
+		return rest, string(section), nil
+	}
+	return rest, string(section[:nl]), section[nl+1:]
+}
+
+// templatePath resolves a reference to a template to its path in the repository
+func templatePath(ref string, templates map[string]string) (string, error) {
+	if path, ok := templates[ref]; ok {
+		return path, nil
+	}
+	if templateNamePattern.MatchString(ref) && !strings.HasSuffix(ref, ".yaml") && !strings.HasSuffix(ref, ".yml") {
+		return "", xerrors.Errorf("unknown template %s: the repo config has no template of that name", ref)
+	}
+	return strings.TrimPrefix(ref, "/"), nil
+}
+
+// SplitBundle splits a bundle produced by BundleTemplates into the job spec and its templates
+func SplitBundle(bundle []byte) (spec []byte, templates map[string][]byte) {
+	bundle, _, _ = SplitEnvFile(bundle)
+
+	var (
+		ref     string
+		section bytes.Buffer
+	)
+	flush := func() {
+		if ref == "" {
+			spec = append([]byte{}, section.Bytes()...)
+		} else {
+			templates[ref] = append([]byte{}, section.Bytes()...)
+		}
+		section.Reset()
+	}
+
+	templates = make(map[string][]byte)
+	scanner := bufio.NewScanner(bytes.NewReader(bundle))
+	scanner.Buffer(make([]byte, 64*1024), len(bundle)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, templateMarker) {
+			flush()
+			ref = strings.TrimPrefix(line, templateMarker)
+			continue
+		}
+		section.WriteString(line)
+		section.WriteString("\n")
+	}
+	flush()
+	return spec, templates
+}
+
+// MergeTemplates merges the job spec with the templates it extends. Both are YAML decoded into maps.
+// Values of the job spec take precedence over those of the templates it extends: maps are merged key by key,
+// all other values including lists replace the template's value. A null value removes the template's value.
+func MergeTemplates(spec map[string]interface{}, templates map[string]map[string]interface{}) (map[string]interface{}, error) {
+	var chain []map[string]interface{}
+	for cur := spec; ; {
+		chain = append(chain, cur)
+
+		ref, ok := cur["extends"]
+		if !ok {
+			break
+		}
+		name, ok := ref.(string)
+		if !ok {
+			return nil, xerrors.Errorf("extends must be a string")
+		}
+		if len(chain) > MaxTemplateDepth+1 {
+			return nil, xerrors.Errorf("too many templates extend each other")
+		}
+		cur, ok = templates[name]
+		if !ok {
+			return nil, xerrors.Errorf("unknown template %s", name)
+		}
+	}
+
+	res := make(map[string]interface{})
+	for i := len(chain) - 1; i >= 0; i-- {
+		res = mergeValues(res, chain[i])
+	}
+	delete(res, "extends")
+	return res, nil
+}
+
+// mergeValues merges override into base
+func mergeValues(base, override map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(base)+len(override))
+	for k, v := range base {
+		res[k] = v
+	}
+	for k, v := range override {
+		if v == nil {
+			delete(res, k)
+			continue
+		}
+		bm, bok := res[k].(map[string]interface{})
+		om, ook := v.(map[string]interface{})
+		if bok && ook {
+			res[k] = mergeValues(bm, om)
+			continue
+		}
+		res[k] = v
+	}
+	return res
+}
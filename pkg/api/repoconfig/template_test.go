@@ -0,0 +1,247 @@
+package repoconfig_test
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/csweichel/werft/pkg/api/repoconfig"
+)
+
+func TestBundleTemplates(t *testing.T) {
+	files := map[string]string{
+		".werft/templates/go.yaml":   "extends: base\npod:\n  containers:\n  - name: build\n    image: golang:1.17\n",
+		".werft/templates/base.yaml": "timeout: 30m\n",
+		".werft/loop-a.yaml":         "extends: .werft/loop-b.yaml\n",
+		".werft/loop-b.yaml":         "extends: .werft/loop-a.yaml\n",
+		".werft/templates/env.yaml":  "envFile: .werft/build.env\n",
+		".werft/build.env":           "FOO=bar\n",
+	}
+	read := func(path string) ([]byte, error) {
+		c, ok := files[path]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(c), nil
+	}
+	templates := map[string]string{
+		"go":      ".werft/templates/go.yaml",
+		"base":    ".werft/templates/base.yaml",
+		"env":     ".werft/templates/env.yaml",
+		"missing": ".werft/templates/missing.yaml",
+	}
+
+	tests := []struct {
+		Name        string
+		Spec        string
+		Expectation string
+		Error       string
+	}{
+		{Name: "no template", Spec: "pod: {}\n", Expectation: "pod: {}\n"},
+		{
+			Name:        "by path",
+			Spec:        "extends: .werft/templates/base.yaml\npod: {}\n",
+			Expectation: "extends: .werft/templates/base.yaml\npod: {}\n# werft:template .werft/templates/base.yaml\ntimeout: 30m\n",
+		},
+		{
+			Name:        "by name with chain",
+			Spec:        "extends: \"go\" # builds Go code\nmutex: {{ .Repository.Ref }}",
+			Expectation: "extends: \"go\" # builds Go code\nmutex: {{ .Repository.Ref }}\n# werft:template go\n" + files[".werft/templates/go.yaml"] + "# werft:template base\ntimeout: 30m\n",
+		},
+		{
+			Name:        "bundle",
+			Spec:        "extends: base\n# werft:template base\ntimeout: 30m\n",
+			Expectation: "extends: base\n# werft:template base\ntimeout: 30m\n",
+		},
+		{
+			Name:        "env file",
+			Spec:        "envFile: .werft/build.env\npod: {}\n",
+			Expectation: "envFile: .werft/build.env\npod: {}\n# werft:envfile .werft/build.env\nFOO=bar\n",
+		},
+		{
+			Name:        "env file of template",
+			Spec:        "extends: env\n",
+			Expectation: "extends: env\n# werft:template env\nenvFile: .werft/build.env\n# werft:envfile .werft/build.env\nFOO=bar\n",
+		},
+		{Name: "missing env file", Spec: "envFile: .werft/nope.env\n", Error: "cannot read env file .werft/nope.env: file does not exist"},
+		{Name: "unknown name", Spec: "extends: python\n", Error: "unknown template python: the repo config has no template of that name"},
+		{Name: "missing file", Spec: "extends: missing\n", Error: "cannot read template missing: file does not exist"},
+		{Name: "missing path", Spec: "extends: .werft/nope.yaml\n", Error: "cannot read template .werft/nope.yaml: file does not exist"},
+		{Name: "cycle", Spec: "extends: .werft/loop-a.yaml\n", Error: "templates extend each other: .werft/loop-a.yaml -> .werft/loop-b.yaml -> .werft/loop-a.yaml"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			act, err := repoconfig.BundleTemplates([]byte(test.Spec), templates, read)
+			if test.Error != "" {
+				if err == nil || err.Error() != test.Error {
+					t.Fatalf("expected error %q, got %v", test.Error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(act) != test.Expectation {
+				t.Errorf("unexpected bundle:\n%s\nexpected:\n%s", act, test.Expectation)
+			}
+
+			spec, tpls := repoconfig.SplitBundle(act)
+			if !strings.HasPrefix(test.Spec, strings.TrimSuffix(string(spec), "\n")) {
+				t.Errorf("split bundle does not start with the job spec: %q", spec)
+			}
+			for ref, tpl := range tpls {
+				if path := templates[ref]; path != "" {
+					ref = path
+				}
+				if string(tpl) != files[ref] {
+					t.Errorf("unexpected template %s: %q", ref, tpl)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeTemplates(t *testing.T) {
+	templates := map[string]map[string]interface{}{
+		"base": {
+			"timeout": "30m",
+			"mutex":   "base",
+			"nodeSelector": map[string]interface{}{
+				"pool": "build",
+				"arch": "amd64",
+			},
+			"sidecars": []interface{}{"db"},
+		},
+		"go": {
+			"extends": "base",
+			"mutex":   "go",
+			"pod": map[string]interface{}{
+				"serviceAccountName": "werft",
+				"containers":         []interface{}{map[string]interface{}{"name": "build", "image": "golang:1.17"}},
+			},
+		},
+		"loop": {"extends": "loop"},
+	}
+
+	tests := []struct {
+		Name        string
+		Spec        map[string]interface{}
+		Expectation map[string]interface{}
+		Error       string
+	}{
+		{
+			Name:        "no template",
+			Spec:        map[string]interface{}{"mutex": "job"},
+			Expectation: map[string]interface{}{"mutex": "job"},
+		},
+		{
+			Name: "job takes precedence over templates",
+			Spec: map[string]interface{}{
+				"extends":      "go",
+				"mutex":        "job",
+				"nodeSelector": map[string]interface{}{"arch": "arm64"},
+				"sidecars":     []interface{}{"redis"},
+				"pod": map[string]interface{}{
+					"containers": []interface{}{map[string]interface{}{"name": "test", "image": "alpine"}},
+				},
+			},
+			Expectation: map[string]interface{}{
+				"timeout":      "30m",
+				"mutex":        "job",
+				"nodeSelector": map[string]interface{}{"pool": "build", "arch": "arm64"},
+				"sidecars":     []interface{}{"redis"},
+				"pod": map[string]interface{}{
+					"serviceAccountName": "werft",
+					"containers":         []interface{}{map[string]interface{}{"name": "test", "image": "alpine"}},
+				},
+			},
+		},
+		{
+			Name: "templates further down the chain take precedence",
+			Spec: map[string]interface{}{"extends": "go"},
+			Expectation: map[string]interface{}{
+				"timeout":      "30m",
+				"mutex":        "go",
+				"nodeSelector": map[string]interface{}{"pool": "build", "arch": "amd64"},
+				"sidecars":     []interface{}{"db"},
+				"pod":          templates["go"]["pod"],
+			},
+		},
+		{
+			Name: "null removes values",
+			Spec: map[string]interface{}{"extends": "base", "sidecars": nil, "nodeSelector": map[string]interface{}{"pool": nil}},
+			Expectation: map[string]interface{}{
+				"timeout":      "30m",
+				"mutex":        "base",
+				"nodeSelector": map[string]interface{}{"arch": "amd64"},
+			},
+		},
+		{Name: "unknown template", Spec: map[string]interface{}{"extends": "python"}, Error: "unknown template python"},
+		{Name: "invalid extends", Spec: map[string]interface{}{"extends": []interface{}{"go"}}, Error: "extends must be a string"},
+		{Name: "cycle", Spec: map[string]interface{}{"extends": "loop"}, Error: "too many templates extend each other"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			act, err := repoconfig.MergeTemplates(test.Spec, templates)
+			if test.Error != "" {
+				if err == nil || err.Error() != test.Error {
+					t.Fatalf("expected error %q, got %v", test.Error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(act, test.Expectation) {
+				t.Errorf("unexpected job spec:\n%v\nexpected:\n%v", act, test.Expectation)
+			}
+		})
+	}
+	if templates["base"]["mutex"] != "base" {
+		t.Errorf("merging modified the templates")
+	}
+}
+
+func TestBundleDefaultImage(t *testing.T) {
+	const image = "eu.gcr.io/werft/build:latest"
+	tests := []struct {
+		Name        string
+		Bundle      string
+		Expectation string
+	}{
+		{Name: "spec", Bundle: "timeout: 30m\n", Expectation: "timeout: 30m\n# werft:defaultImage " + image + "\n"},
+		{Name: "no trailing newline", Bundle: "timeout: 30m", Expectation: "timeout: 30m\n# werft:defaultImage " + image + "\n"},
+		{
+			Name:        "templates",
+			Bundle:      "extends: base\n# werft:template base\ntimeout: 30m\n",
+			Expectation: "extends: base\n# werft:defaultImage " + image + "\n# werft:template base\ntimeout: 30m\n",
+		},
+		{
+			Name:        "env file",
+			Bundle:      "envFile: build.env\n# werft:envfile build.env\nFOO=bar\n",
+			Expectation: "envFile: build.env\n# werft:defaultImage " + image + "\n# werft:envfile build.env\nFOO=bar\n",
+		},
+		{
+			Name:        "bundled already",
+			Bundle:      "timeout: 30m\n# werft:defaultImage golang:1.17\n",
+			Expectation: "timeout: 30m\n# werft:defaultImage golang:1.17\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			act := repoconfig.BundleDefaultImage([]byte(test.Bundle), image)
+			if string(act) != test.Expectation {
+				t.Errorf("unexpected bundle:\n%s\nexpected:\n%s", act, test.Expectation)
+			}
+			if img := repoconfig.DefaultImage(act); img == "" {
+				t.Errorf("bundle has no default image")
+			}
+
+			spec, _ := repoconfig.SplitBundle(act)
+			if img := repoconfig.DefaultImage(spec); img == "" {
+				t.Errorf("default image is not part of the job spec")
+			}
+		})
+	}
+}
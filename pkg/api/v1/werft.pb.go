@@ -33,6 +33,9 @@ const (
 	FilterOp_OP_ENDS_WITH   FilterOp = 2
 	FilterOp_OP_CONTAINS    FilterOp = 3
 	FilterOp_OP_EXISTS      FilterOp = 4
+	FilterOp_OP_MATCHES     FilterOp = 5
+	FilterOp_OP_GREATER     FilterOp = 6
+	FilterOp_OP_LESS        FilterOp = 7
 )
 
 var FilterOp_name = map[int32]string{
@@ -41,6 +44,9 @@ var FilterOp_name = map[int32]string{
 	2: "OP_ENDS_WITH",
 	3: "OP_CONTAINS",
 	4: "OP_EXISTS",
+	5: "OP_MATCHES",
+	6: "OP_GREATER",
+	7: "OP_LESS",
 }
 
 var FilterOp_value = map[string]int32{
@@ -49,6 +55,9 @@ var FilterOp_value = map[string]int32{
 	"OP_ENDS_WITH":   2,
 	"OP_CONTAINS":    3,
 	"OP_EXISTS":      4,
+	"OP_MATCHES":     5,
+	"OP_GREATER":     6,
+	"OP_LESS":        7,
 }
 
 func (x FilterOp) String() string {
@@ -93,10 +102,15 @@ func (ListenRequestLogs) EnumDescriptor() ([]byte, []int) {
 type JobTrigger int32
 
 const (
-	JobTrigger_TRIGGER_UNKNOWN JobTrigger = 0
-	JobTrigger_TRIGGER_MANUAL  JobTrigger = 1
-	JobTrigger_TRIGGER_PUSH    JobTrigger = 2
-	JobTrigger_TRIGGER_DELETED JobTrigger = 3
+	JobTrigger_TRIGGER_UNKNOWN  JobTrigger = 0
+	JobTrigger_TRIGGER_MANUAL   JobTrigger = 1
+	JobTrigger_TRIGGER_PUSH     JobTrigger = 2
+	JobTrigger_TRIGGER_DELETED  JobTrigger = 3
+	JobTrigger_TRIGGER_SCHEDULE JobTrigger = 4
+	// Tag means a tag was pushed
+	JobTrigger_TRIGGER_TAG JobTrigger = 5
+	// Release means a release was published
+	JobTrigger_TRIGGER_RELEASE JobTrigger = 6
 )
 
 var JobTrigger_name = map[int32]string{
@@ -104,13 +118,19 @@ var JobTrigger_name = map[int32]string{
 	1: "TRIGGER_MANUAL",
 	2: "TRIGGER_PUSH",
 	3: "TRIGGER_DELETED",
+	4: "TRIGGER_SCHEDULE",
+	5: "TRIGGER_TAG",
+	6: "TRIGGER_RELEASE",
 }
 
 var JobTrigger_value = map[string]int32{
-	"TRIGGER_UNKNOWN": 0,
-	"TRIGGER_MANUAL":  1,
-	"TRIGGER_PUSH":    2,
-	"TRIGGER_DELETED": 3,
+	"TRIGGER_UNKNOWN":  0,
+	"TRIGGER_MANUAL":   1,
+	"TRIGGER_PUSH":     2,
+	"TRIGGER_DELETED":  3,
+	"TRIGGER_SCHEDULE": 4,
+	"TRIGGER_TAG":      5,
+	"TRIGGER_RELEASE":  6,
 }
 
 func (x JobTrigger) String() string {
@@ -139,6 +159,8 @@ const (
 	JobPhase_PHASE_CLEANUP JobPhase = 5
 	// Waiting means the job is waiting for its start time or some other condition to be met
 	JobPhase_PHASE_WAITING JobPhase = 6
+	// Queued means the job waits for a free slot because too many jobs are running already
+	JobPhase_PHASE_QUEUED JobPhase = 7
 )
 
 var JobPhase_name = map[int32]string{
@@ -149,6 +171,7 @@ var JobPhase_name = map[int32]string{
 	4: "PHASE_DONE",
 	5: "PHASE_CLEANUP",
 	6: "PHASE_WAITING",
+	7: "PHASE_QUEUED",
 }
 
 var JobPhase_value = map[string]int32{
@@ -159,6 +182,7 @@ var JobPhase_value = map[string]int32{
 	"PHASE_DONE":      4,
 	"PHASE_CLEANUP":   5,
 	"PHASE_WAITING":   6,
+	"PHASE_QUEUED":    7,
 }
 
 func (x JobPhase) String() string {
@@ -179,6 +203,9 @@ const (
 	LogSliceType_SLICE_DONE      LogSliceType = 4
 	LogSliceType_SLICE_FAIL      LogSliceType = 5
 	LogSliceType_SLICE_RESULT    LogSliceType = 6
+	// SLICE_DROPPED marks log content which was dropped because the listener could not keep up.
+	// The payload is the number of bytes dropped.
+	LogSliceType_SLICE_DROPPED LogSliceType = 7
 )
 
 var LogSliceType_name = map[int32]string{
@@ -189,6 +216,7 @@ var LogSliceType_name = map[int32]string{
 	4: "SLICE_DONE",
 	5: "SLICE_FAIL",
 	6: "SLICE_RESULT",
+	7: "SLICE_DROPPED",
 }
 
 var LogSliceType_value = map[string]int32{
@@ -199,6 +227,7 @@ var LogSliceType_value = map[string]int32{
 	"SLICE_DONE":      4,
 	"SLICE_FAIL":      5,
 	"SLICE_RESULT":    6,
+	"SLICE_DROPPED":   7,
 }
 
 func (x LogSliceType) String() string {
@@ -374,16 +403,19 @@ func (m *StartJobResponse) GetStatus() *JobStatus {
 }
 
 type StartGitHubJobRequest struct {
-	Metadata             *JobMetadata         `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`
-	JobPath              string               `protobuf:"bytes,2,opt,name=job_path,json=jobPath,proto3" json:"job_path,omitempty"`
-	JobYaml              []byte               `protobuf:"bytes,3,opt,name=job_yaml,json=jobYaml,proto3" json:"job_yaml,omitempty"`
-	GithubToken          string               `protobuf:"bytes,4,opt,name=github_token,json=githubToken,proto3" json:"github_token,omitempty"`
-	Sideload             []byte               `protobuf:"bytes,5,opt,name=sideload,proto3" json:"sideload,omitempty"`
-	WaitUntil            *timestamp.Timestamp `protobuf:"bytes,6,opt,name=wait_until,json=waitUntil,proto3" json:"wait_until,omitempty"`
-	NameSuffix           string               `protobuf:"bytes,7,opt,name=name_suffix,json=nameSuffix,proto3" json:"name_suffix,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
-	XXX_unrecognized     []byte               `json:"-"`
-	XXX_sizecache        int32                `json:"-"`
+	Metadata    *JobMetadata         `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	JobPath     string               `protobuf:"bytes,2,opt,name=job_path,json=jobPath,proto3" json:"job_path,omitempty"`
+	JobYaml     []byte               `protobuf:"bytes,3,opt,name=job_yaml,json=jobYaml,proto3" json:"job_yaml,omitempty"`
+	GithubToken string               `protobuf:"bytes,4,opt,name=github_token,json=githubToken,proto3" json:"github_token,omitempty"`
+	Sideload    []byte               `protobuf:"bytes,5,opt,name=sideload,proto3" json:"sideload,omitempty"`
+	WaitUntil   *timestamp.Timestamp `protobuf:"bytes,6,opt,name=wait_until,json=waitUntil,proto3" json:"wait_until,omitempty"`
+	NameSuffix  string               `protobuf:"bytes,7,opt,name=name_suffix,json=nameSuffix,proto3" json:"name_suffix,omitempty"`
+	// changed_files lists the files changed by the event that triggered this job, e.g. a push.
+	// An empty list means the changes are unknown.
+	ChangedFiles         []string `protobuf:"bytes,8,rep,name=changed_files,json=changedFiles,proto3" json:"changed_files,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *StartGitHubJobRequest) Reset()         { *m = StartGitHubJobRequest{} }
@@ -460,16 +492,26 @@ func (m *StartGitHubJobRequest) GetNameSuffix() string {
 	return ""
 }
 
+func (m *StartGitHubJobRequest) GetChangedFiles() []string {
+	if m != nil {
+		return m.ChangedFiles
+	}
+	return nil
+}
+
 type StartJobRequest struct {
-	Metadata             *JobMetadata         `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`
-	JobPath              string               `protobuf:"bytes,2,opt,name=job_path,json=jobPath,proto3" json:"job_path,omitempty"`
-	JobYaml              []byte               `protobuf:"bytes,3,opt,name=job_yaml,json=jobYaml,proto3" json:"job_yaml,omitempty"`
-	Sideload             []byte               `protobuf:"bytes,4,opt,name=sideload,proto3" json:"sideload,omitempty"`
-	WaitUntil            *timestamp.Timestamp `protobuf:"bytes,5,opt,name=wait_until,json=waitUntil,proto3" json:"wait_until,omitempty"`
-	NameSuffix           string               `protobuf:"bytes,6,opt,name=name_suffix,json=nameSuffix,proto3" json:"name_suffix,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
-	XXX_unrecognized     []byte               `json:"-"`
-	XXX_sizecache        int32                `json:"-"`
+	Metadata   *JobMetadata         `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	JobPath    string               `protobuf:"bytes,2,opt,name=job_path,json=jobPath,proto3" json:"job_path,omitempty"`
+	JobYaml    []byte               `protobuf:"bytes,3,opt,name=job_yaml,json=jobYaml,proto3" json:"job_yaml,omitempty"`
+	Sideload   []byte               `protobuf:"bytes,4,opt,name=sideload,proto3" json:"sideload,omitempty"`
+	WaitUntil  *timestamp.Timestamp `protobuf:"bytes,5,opt,name=wait_until,json=waitUntil,proto3" json:"wait_until,omitempty"`
+	NameSuffix string               `protobuf:"bytes,6,opt,name=name_suffix,json=nameSuffix,proto3" json:"name_suffix,omitempty"`
+	// changed_files lists the files changed by the event that triggered this job, e.g. a push.
+	// An empty list means the changes are unknown.
+	ChangedFiles         []string `protobuf:"bytes,7,rep,name=changed_files,json=changedFiles,proto3" json:"changed_files,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *StartJobRequest) Reset()         { *m = StartJobRequest{} }
@@ -539,6 +581,13 @@ func (m *StartJobRequest) GetNameSuffix() string {
 	return ""
 }
 
+func (m *StartJobRequest) GetChangedFiles() []string {
+	if m != nil {
+		return m.ChangedFiles
+	}
+	return nil
+}
+
 type StartFromPreviousJobRequest struct {
 	PreviousJob          string               `protobuf:"bytes,1,opt,name=previous_job,json=previousJob,proto3" json:"previous_job,omitempty"`
 	GithubToken          string               `protobuf:"bytes,2,opt,name=github_token,json=githubToken,proto3" json:"github_token,omitempty"`
@@ -594,21 +643,165 @@ func (m *StartFromPreviousJobRequest) GetWaitUntil() *timestamp.Timestamp {
 	return nil
 }
 
+type RetryPipelineRequest struct {
+	// parent is the matrix job whose failed jobs to restart
+	Parent               string   `protobuf:"bytes,1,opt,name=parent,proto3" json:"parent,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RetryPipelineRequest) Reset()         { *m = RetryPipelineRequest{} }
+func (m *RetryPipelineRequest) String() string { return proto.CompactTextString(m) }
+func (*RetryPipelineRequest) ProtoMessage()    {}
+func (*RetryPipelineRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9fe744feedd6d332, []int{5}
+}
+
+func (m *RetryPipelineRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RetryPipelineRequest.Unmarshal(m, b)
+}
+func (m *RetryPipelineRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RetryPipelineRequest.Marshal(b, m, deterministic)
+}
+func (m *RetryPipelineRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RetryPipelineRequest.Merge(m, src)
+}
+func (m *RetryPipelineRequest) XXX_Size() int {
+	return xxx_messageInfo_RetryPipelineRequest.Size(m)
+}
+func (m *RetryPipelineRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RetryPipelineRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RetryPipelineRequest proto.InternalMessageInfo
+
+func (m *RetryPipelineRequest) GetParent() string {
+	if m != nil {
+		return m.Parent
+	}
+	return ""
+}
+
+type RetryPipelineResponse struct {
+	// status is the status of the matrix job once the failed jobs were restarted
+	Status *JobStatus `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	// retried lists the jobs which were restarted
+	Retried              []*RetriedJob `protobuf:"bytes,2,rep,name=retried,proto3" json:"retried,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *RetryPipelineResponse) Reset()         { *m = RetryPipelineResponse{} }
+func (m *RetryPipelineResponse) String() string { return proto.CompactTextString(m) }
+func (*RetryPipelineResponse) ProtoMessage()    {}
+func (*RetryPipelineResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9fe744feedd6d332, []int{6}
+}
+
+func (m *RetryPipelineResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RetryPipelineResponse.Unmarshal(m, b)
+}
+func (m *RetryPipelineResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RetryPipelineResponse.Marshal(b, m, deterministic)
+}
+func (m *RetryPipelineResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RetryPipelineResponse.Merge(m, src)
+}
+func (m *RetryPipelineResponse) XXX_Size() int {
+	return xxx_messageInfo_RetryPipelineResponse.Size(m)
+}
+func (m *RetryPipelineResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_RetryPipelineResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RetryPipelineResponse proto.InternalMessageInfo
+
+func (m *RetryPipelineResponse) GetStatus() *JobStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *RetryPipelineResponse) GetRetried() []*RetriedJob {
+	if m != nil {
+		return m.Retried
+	}
+	return nil
+}
+
+type RetriedJob struct {
+	// previous_job is the failed job
+	PreviousJob string `protobuf:"bytes,1,opt,name=previous_job,json=previousJob,proto3" json:"previous_job,omitempty"`
+	// name is the job which replaces it
+	Name                 string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RetriedJob) Reset()         { *m = RetriedJob{} }
+func (m *RetriedJob) String() string { return proto.CompactTextString(m) }
+func (*RetriedJob) ProtoMessage()    {}
+func (*RetriedJob) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9fe744feedd6d332, []int{7}
+}
+
+func (m *RetriedJob) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RetriedJob.Unmarshal(m, b)
+}
+func (m *RetriedJob) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RetriedJob.Marshal(b, m, deterministic)
+}
+func (m *RetriedJob) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RetriedJob.Merge(m, src)
+}
+func (m *RetriedJob) XXX_Size() int {
+	return xxx_messageInfo_RetriedJob.Size(m)
+}
+func (m *RetriedJob) XXX_DiscardUnknown() {
+	xxx_messageInfo_RetriedJob.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RetriedJob proto.InternalMessageInfo
+
+func (m *RetriedJob) GetPreviousJob() string {
+	if m != nil {
+		return m.PreviousJob
+	}
+	return ""
+}
+
+func (m *RetriedJob) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
 type ListJobsRequest struct {
-	Filter               []*FilterExpression `protobuf:"bytes,1,rep,name=filter,proto3" json:"filter,omitempty"`
-	Order                []*OrderExpression  `protobuf:"bytes,2,rep,name=order,proto3" json:"order,omitempty"`
-	Start                int32               `protobuf:"varint,3,opt,name=start,proto3" json:"start,omitempty"`
-	Limit                int32               `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
-	XXX_unrecognized     []byte              `json:"-"`
-	XXX_sizecache        int32               `json:"-"`
+	Filter []*FilterExpression `protobuf:"bytes,1,rep,name=filter,proto3" json:"filter,omitempty"`
+	Order  []*OrderExpression  `protobuf:"bytes,2,rep,name=order,proto3" json:"order,omitempty"`
+	Start  int32               `protobuf:"varint,3,opt,name=start,proto3" json:"start,omitempty"`
+	Limit  int32               `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	// latest_per_group returns only the newest job of each group of jobs which share the values of these fields,
+	// e.g. repo.repo and repo.ref for the latest job per branch. Jobs are grouped after the filter is applied.
+	LatestPerGroup []string `protobuf:"bytes,5,rep,name=latest_per_group,json=latestPerGroup,proto3" json:"latest_per_group,omitempty"`
+	// cursor continues listing after the last job of a previous page, as returned in its next_cursor. Unlike start,
+	// the cursor pages stably while new jobs arrive. The filter and order must be the same as for the previous page.
+	Cursor               string   `protobuf:"bytes,6,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *ListJobsRequest) Reset()         { *m = ListJobsRequest{} }
 func (m *ListJobsRequest) String() string { return proto.CompactTextString(m) }
 func (*ListJobsRequest) ProtoMessage()    {}
 func (*ListJobsRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{5}
+	return fileDescriptor_9fe744feedd6d332, []int{8}
 }
 
 func (m *ListJobsRequest) XXX_Unmarshal(b []byte) error {
@@ -657,6 +850,20 @@ func (m *ListJobsRequest) GetLimit() int32 {
 	return 0
 }
 
+func (m *ListJobsRequest) GetLatestPerGroup() []string {
+	if m != nil {
+		return m.LatestPerGroup
+	}
+	return nil
+}
+
+func (m *ListJobsRequest) GetCursor() string {
+	if m != nil {
+		return m.Cursor
+	}
+	return ""
+}
+
 type FilterExpression struct {
 	Terms                []*FilterTerm `protobuf:"bytes,1,rep,name=terms,proto3" json:"terms,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
@@ -668,7 +875,7 @@ func (m *FilterExpression) Reset()         { *m = FilterExpression{} }
 func (m *FilterExpression) String() string { return proto.CompactTextString(m) }
 func (*FilterExpression) ProtoMessage()    {}
 func (*FilterExpression) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{6}
+	return fileDescriptor_9fe744feedd6d332, []int{9}
 }
 
 func (m *FilterExpression) XXX_Unmarshal(b []byte) error {
@@ -697,10 +904,12 @@ func (m *FilterExpression) GetTerms() []*FilterTerm {
 }
 
 type FilterTerm struct {
-	Field                string   `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
-	Value                string   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
-	Operation            FilterOp `protobuf:"varint,3,opt,name=operation,proto3,enum=v1.FilterOp" json:"operation,omitempty"`
-	Negate               bool     `protobuf:"varint,4,opt,name=negate,proto3" json:"negate,omitempty"`
+	Field     string   `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	Value     string   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Operation FilterOp `protobuf:"varint,3,opt,name=operation,proto3,enum=v1.FilterOp" json:"operation,omitempty"`
+	Negate    bool     `protobuf:"varint,4,opt,name=negate,proto3" json:"negate,omitempty"`
+	// ignore_case makes text comparisons case-insensitive. Enum, boolean and time fields are unaffected.
+	IgnoreCase           bool     `protobuf:"varint,5,opt,name=ignore_case,json=ignoreCase,proto3" json:"ignore_case,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -710,7 +919,7 @@ func (m *FilterTerm) Reset()         { *m = FilterTerm{} }
 func (m *FilterTerm) String() string { return proto.CompactTextString(m) }
 func (*FilterTerm) ProtoMessage()    {}
 func (*FilterTerm) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{7}
+	return fileDescriptor_9fe744feedd6d332, []int{10}
 }
 
 func (m *FilterTerm) XXX_Unmarshal(b []byte) error {
@@ -759,6 +968,13 @@ func (m *FilterTerm) GetNegate() bool {
 	return false
 }
 
+func (m *FilterTerm) GetIgnoreCase() bool {
+	if m != nil {
+		return m.IgnoreCase
+	}
+	return false
+}
+
 type OrderExpression struct {
 	Field                string   `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
 	Ascending            bool     `protobuf:"varint,2,opt,name=ascending,proto3" json:"ascending,omitempty"`
@@ -771,7 +987,7 @@ func (m *OrderExpression) Reset()         { *m = OrderExpression{} }
 func (m *OrderExpression) String() string { return proto.CompactTextString(m) }
 func (*OrderExpression) ProtoMessage()    {}
 func (*OrderExpression) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{8}
+	return fileDescriptor_9fe744feedd6d332, []int{11}
 }
 
 func (m *OrderExpression) XXX_Unmarshal(b []byte) error {
@@ -807,18 +1023,21 @@ func (m *OrderExpression) GetAscending() bool {
 }
 
 type ListJobsResponse struct {
-	Total                int32        `protobuf:"varint,1,opt,name=total,proto3" json:"total,omitempty"`
-	Result               []*JobStatus `protobuf:"bytes,2,rep,name=result,proto3" json:"result,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
-	XXX_unrecognized     []byte       `json:"-"`
-	XXX_sizecache        int32        `json:"-"`
+	Total  int32        `protobuf:"varint,1,opt,name=total,proto3" json:"total,omitempty"`
+	Result []*JobStatus `protobuf:"bytes,2,rep,name=result,proto3" json:"result,omitempty"`
+	// next_cursor lists the next page when passed as cursor. It's empty once a page isn't full, i.e. there are no
+	// more jobs, or if the jobs cannot be paged using a cursor, e.g. because they're ordered by duration.
+	NextCursor           string   `protobuf:"bytes,3,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *ListJobsResponse) Reset()         { *m = ListJobsResponse{} }
 func (m *ListJobsResponse) String() string { return proto.CompactTextString(m) }
 func (*ListJobsResponse) ProtoMessage()    {}
 func (*ListJobsResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{9}
+	return fileDescriptor_9fe744feedd6d332, []int{12}
 }
 
 func (m *ListJobsResponse) XXX_Unmarshal(b []byte) error {
@@ -853,6 +1072,13 @@ func (m *ListJobsResponse) GetResult() []*JobStatus {
 	return nil
 }
 
+func (m *ListJobsResponse) GetNextCursor() string {
+	if m != nil {
+		return m.NextCursor
+	}
+	return ""
+}
+
 type SubscribeRequest struct {
 	Filter               []*FilterExpression `protobuf:"bytes,1,rep,name=filter,proto3" json:"filter,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
@@ -864,7 +1090,7 @@ func (m *SubscribeRequest) Reset()         { *m = SubscribeRequest{} }
 func (m *SubscribeRequest) String() string { return proto.CompactTextString(m) }
 func (*SubscribeRequest) ProtoMessage()    {}
 func (*SubscribeRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{10}
+	return fileDescriptor_9fe744feedd6d332, []int{13}
 }
 
 func (m *SubscribeRequest) XXX_Unmarshal(b []byte) error {
@@ -903,7 +1129,7 @@ func (m *SubscribeResponse) Reset()         { *m = SubscribeResponse{} }
 func (m *SubscribeResponse) String() string { return proto.CompactTextString(m) }
 func (*SubscribeResponse) ProtoMessage()    {}
 func (*SubscribeResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{11}
+	return fileDescriptor_9fe744feedd6d332, []int{14}
 }
 
 func (m *SubscribeResponse) XXX_Unmarshal(b []byte) error {
@@ -942,7 +1168,7 @@ func (m *GetJobRequest) Reset()         { *m = GetJobRequest{} }
 func (m *GetJobRequest) String() string { return proto.CompactTextString(m) }
 func (*GetJobRequest) ProtoMessage()    {}
 func (*GetJobRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{12}
+	return fileDescriptor_9fe744feedd6d332, []int{15}
 }
 
 func (m *GetJobRequest) XXX_Unmarshal(b []byte) error {
@@ -971,17 +1197,19 @@ func (m *GetJobRequest) GetName() string {
 }
 
 type GetJobResponse struct {
-	Result               *JobStatus `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
-	XXX_unrecognized     []byte     `json:"-"`
-	XXX_sizecache        int32      `json:"-"`
+	Result *JobStatus `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+	// job_spec is the job YAML the job was started with, if it is known
+	JobSpec              string   `protobuf:"bytes,2,opt,name=job_spec,json=jobSpec,proto3" json:"job_spec,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *GetJobResponse) Reset()         { *m = GetJobResponse{} }
 func (m *GetJobResponse) String() string { return proto.CompactTextString(m) }
 func (*GetJobResponse) ProtoMessage()    {}
 func (*GetJobResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{13}
+	return fileDescriptor_9fe744feedd6d332, []int{16}
 }
 
 func (m *GetJobResponse) XXX_Unmarshal(b []byte) error {
@@ -1009,20 +1237,29 @@ func (m *GetJobResponse) GetResult() *JobStatus {
 	return nil
 }
 
+func (m *GetJobResponse) GetJobSpec() string {
+	if m != nil {
+		return m.JobSpec
+	}
+	return ""
+}
+
 type ListenRequest struct {
-	Name                 string            `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Updates              bool              `protobuf:"varint,2,opt,name=updates,proto3" json:"updates,omitempty"`
-	Logs                 ListenRequestLogs `protobuf:"varint,3,opt,name=logs,proto3,enum=v1.ListenRequestLogs" json:"logs,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
-	XXX_unrecognized     []byte            `json:"-"`
-	XXX_sizecache        int32             `json:"-"`
+	Name    string            `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Updates bool              `protobuf:"varint,2,opt,name=updates,proto3" json:"updates,omitempty"`
+	Logs    ListenRequestLogs `protobuf:"varint,3,opt,name=logs,proto3,enum=v1.ListenRequestLogs" json:"logs,omitempty"`
+	// section restricts the logs to a single log section, including its nested sections. Requires sliced logs.
+	Section              string   `protobuf:"bytes,4,opt,name=section,proto3" json:"section,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *ListenRequest) Reset()         { *m = ListenRequest{} }
 func (m *ListenRequest) String() string { return proto.CompactTextString(m) }
 func (*ListenRequest) ProtoMessage()    {}
 func (*ListenRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{14}
+	return fileDescriptor_9fe744feedd6d332, []int{17}
 }
 
 func (m *ListenRequest) XXX_Unmarshal(b []byte) error {
@@ -1064,6 +1301,13 @@ func (m *ListenRequest) GetLogs() ListenRequestLogs {
 	return ListenRequestLogs_LOGS_DISABLED
 }
 
+func (m *ListenRequest) GetSection() string {
+	if m != nil {
+		return m.Section
+	}
+	return ""
+}
+
 type ListenResponse struct {
 	// Types that are valid to be assigned to Content:
 	//	*ListenResponse_Update
@@ -1078,7 +1322,7 @@ func (m *ListenResponse) Reset()         { *m = ListenResponse{} }
 func (m *ListenResponse) String() string { return proto.CompactTextString(m) }
 func (*ListenResponse) ProtoMessage()    {}
 func (*ListenResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{15}
+	return fileDescriptor_9fe744feedd6d332, []int{18}
 }
 
 func (m *ListenResponse) XXX_Unmarshal(b []byte) error {
@@ -1144,140 +1388,328 @@ func (*ListenResponse) XXX_OneofWrappers() []interface{} {
 	}
 }
 
-type JobStatus struct {
-	Name                 string         `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Metadata             *JobMetadata   `protobuf:"bytes,2,opt,name=metadata,proto3" json:"metadata,omitempty"`
-	Phase                JobPhase       `protobuf:"varint,3,opt,name=phase,proto3,enum=v1.JobPhase" json:"phase,omitempty"`
-	Conditions           *JobConditions `protobuf:"bytes,4,opt,name=conditions,proto3" json:"conditions,omitempty"`
-	Details              string         `protobuf:"bytes,5,opt,name=details,proto3" json:"details,omitempty"`
-	Results              []*JobResult   `protobuf:"bytes,6,rep,name=results,proto3" json:"results,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
-	XXX_unrecognized     []byte         `json:"-"`
-	XXX_sizecache        int32          `json:"-"`
+type DownloadLogsRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// section restricts the download to the content of a single log section, including its nested sections
+	Section              string   `protobuf:"bytes,2,opt,name=section,proto3" json:"section,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *JobStatus) Reset()         { *m = JobStatus{} }
-func (m *JobStatus) String() string { return proto.CompactTextString(m) }
-func (*JobStatus) ProtoMessage()    {}
-func (*JobStatus) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{16}
+func (m *DownloadLogsRequest) Reset()         { *m = DownloadLogsRequest{} }
+func (m *DownloadLogsRequest) String() string { return proto.CompactTextString(m) }
+func (*DownloadLogsRequest) ProtoMessage()    {}
+func (*DownloadLogsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9fe744feedd6d332, []int{19}
 }
 
-func (m *JobStatus) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_JobStatus.Unmarshal(m, b)
+func (m *DownloadLogsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DownloadLogsRequest.Unmarshal(m, b)
 }
-func (m *JobStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_JobStatus.Marshal(b, m, deterministic)
+func (m *DownloadLogsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DownloadLogsRequest.Marshal(b, m, deterministic)
 }
-func (m *JobStatus) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_JobStatus.Merge(m, src)
+func (m *DownloadLogsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DownloadLogsRequest.Merge(m, src)
 }
-func (m *JobStatus) XXX_Size() int {
-	return xxx_messageInfo_JobStatus.Size(m)
+func (m *DownloadLogsRequest) XXX_Size() int {
+	return xxx_messageInfo_DownloadLogsRequest.Size(m)
 }
-func (m *JobStatus) XXX_DiscardUnknown() {
-	xxx_messageInfo_JobStatus.DiscardUnknown(m)
+func (m *DownloadLogsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DownloadLogsRequest.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_JobStatus proto.InternalMessageInfo
+var xxx_messageInfo_DownloadLogsRequest proto.InternalMessageInfo
 
-func (m *JobStatus) GetName() string {
+func (m *DownloadLogsRequest) GetName() string {
 	if m != nil {
 		return m.Name
 	}
 	return ""
 }
 
-func (m *JobStatus) GetMetadata() *JobMetadata {
+func (m *DownloadLogsRequest) GetSection() string {
 	if m != nil {
-		return m.Metadata
+		return m.Section
 	}
-	return nil
+	return ""
 }
 
-func (m *JobStatus) GetPhase() JobPhase {
-	if m != nil {
-		return m.Phase
-	}
-	return JobPhase_PHASE_UNKNOWN
+type DownloadLogsResponse struct {
+	Data                 []byte   `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *JobStatus) GetConditions() *JobConditions {
-	if m != nil {
-		return m.Conditions
-	}
-	return nil
+func (m *DownloadLogsResponse) Reset()         { *m = DownloadLogsResponse{} }
+func (m *DownloadLogsResponse) String() string { return proto.CompactTextString(m) }
+func (*DownloadLogsResponse) ProtoMessage()    {}
+func (*DownloadLogsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9fe744feedd6d332, []int{20}
 }
 
-func (m *JobStatus) GetDetails() string {
-	if m != nil {
-		return m.Details
-	}
-	return ""
+func (m *DownloadLogsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DownloadLogsResponse.Unmarshal(m, b)
+}
+func (m *DownloadLogsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DownloadLogsResponse.Marshal(b, m, deterministic)
+}
+func (m *DownloadLogsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DownloadLogsResponse.Merge(m, src)
+}
+func (m *DownloadLogsResponse) XXX_Size() int {
+	return xxx_messageInfo_DownloadLogsResponse.Size(m)
+}
+func (m *DownloadLogsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DownloadLogsResponse.DiscardUnknown(m)
 }
 
-func (m *JobStatus) GetResults() []*JobResult {
+var xxx_messageInfo_DownloadLogsResponse proto.InternalMessageInfo
+
+func (m *DownloadLogsResponse) GetData() []byte {
 	if m != nil {
-		return m.Results
+		return m.Data
 	}
 	return nil
 }
 
-type JobMetadata struct {
-	Owner                string               `protobuf:"bytes,1,opt,name=owner,proto3" json:"owner,omitempty"`
-	Repository           *Repository          `protobuf:"bytes,2,opt,name=repository,proto3" json:"repository,omitempty"`
-	Trigger              JobTrigger           `protobuf:"varint,3,opt,name=trigger,proto3,enum=v1.JobTrigger" json:"trigger,omitempty"`
-	Created              *timestamp.Timestamp `protobuf:"bytes,4,opt,name=created,proto3" json:"created,omitempty"`
-	Finished             *timestamp.Timestamp `protobuf:"bytes,5,opt,name=finished,proto3" json:"finished,omitempty"`
-	Annotations          []*Annotation        `protobuf:"bytes,6,rep,name=annotations,proto3" json:"annotations,omitempty"`
-	JobSpecName          string               `protobuf:"bytes,7,opt,name=job_spec_name,json=jobSpecName,proto3" json:"job_spec_name,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
-	XXX_unrecognized     []byte               `json:"-"`
-	XXX_sizecache        int32                `json:"-"`
+type DownloadArtifactRequest struct {
+	// name is the name of the job which produced the artifact
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Artifact             string   `protobuf:"bytes,2,opt,name=artifact,proto3" json:"artifact,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *JobMetadata) Reset()         { *m = JobMetadata{} }
-func (m *JobMetadata) String() string { return proto.CompactTextString(m) }
-func (*JobMetadata) ProtoMessage()    {}
-func (*JobMetadata) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{17}
+func (m *DownloadArtifactRequest) Reset()         { *m = DownloadArtifactRequest{} }
+func (m *DownloadArtifactRequest) String() string { return proto.CompactTextString(m) }
+func (*DownloadArtifactRequest) ProtoMessage()    {}
+func (*DownloadArtifactRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9fe744feedd6d332, []int{21}
 }
 
-func (m *JobMetadata) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_JobMetadata.Unmarshal(m, b)
+func (m *DownloadArtifactRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DownloadArtifactRequest.Unmarshal(m, b)
 }
-func (m *JobMetadata) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_JobMetadata.Marshal(b, m, deterministic)
+func (m *DownloadArtifactRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DownloadArtifactRequest.Marshal(b, m, deterministic)
 }
-func (m *JobMetadata) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_JobMetadata.Merge(m, src)
+func (m *DownloadArtifactRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DownloadArtifactRequest.Merge(m, src)
 }
-func (m *JobMetadata) XXX_Size() int {
-	return xxx_messageInfo_JobMetadata.Size(m)
+func (m *DownloadArtifactRequest) XXX_Size() int {
+	return xxx_messageInfo_DownloadArtifactRequest.Size(m)
 }
-func (m *JobMetadata) XXX_DiscardUnknown() {
-	xxx_messageInfo_JobMetadata.DiscardUnknown(m)
+func (m *DownloadArtifactRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DownloadArtifactRequest.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_JobMetadata proto.InternalMessageInfo
+var xxx_messageInfo_DownloadArtifactRequest proto.InternalMessageInfo
 
-func (m *JobMetadata) GetOwner() string {
+func (m *DownloadArtifactRequest) GetName() string {
 	if m != nil {
-		return m.Owner
+		return m.Name
 	}
 	return ""
 }
 
-func (m *JobMetadata) GetRepository() *Repository {
+func (m *DownloadArtifactRequest) GetArtifact() string {
 	if m != nil {
-		return m.Repository
+		return m.Artifact
 	}
-	return nil
+	return ""
 }
 
-func (m *JobMetadata) GetTrigger() JobTrigger {
-	if m != nil {
-		return m.Trigger
+type DownloadArtifactResponse struct {
+	Data                 []byte   `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DownloadArtifactResponse) Reset()         { *m = DownloadArtifactResponse{} }
+func (m *DownloadArtifactResponse) String() string { return proto.CompactTextString(m) }
+func (*DownloadArtifactResponse) ProtoMessage()    {}
+func (*DownloadArtifactResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9fe744feedd6d332, []int{22}
+}
+
+func (m *DownloadArtifactResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DownloadArtifactResponse.Unmarshal(m, b)
+}
+func (m *DownloadArtifactResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DownloadArtifactResponse.Marshal(b, m, deterministic)
+}
+func (m *DownloadArtifactResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DownloadArtifactResponse.Merge(m, src)
+}
+func (m *DownloadArtifactResponse) XXX_Size() int {
+	return xxx_messageInfo_DownloadArtifactResponse.Size(m)
+}
+func (m *DownloadArtifactResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DownloadArtifactResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DownloadArtifactResponse proto.InternalMessageInfo
+
+func (m *DownloadArtifactResponse) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+type JobStatus struct {
+	Name       string         `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Metadata   *JobMetadata   `protobuf:"bytes,2,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	Phase      JobPhase       `protobuf:"varint,3,opt,name=phase,proto3,enum=v1.JobPhase" json:"phase,omitempty"`
+	Conditions *JobConditions `protobuf:"bytes,4,opt,name=conditions,proto3" json:"conditions,omitempty"`
+	Details    string         `protobuf:"bytes,5,opt,name=details,proto3" json:"details,omitempty"`
+	Results    []*JobResult   `protobuf:"bytes,6,rep,name=results,proto3" json:"results,omitempty"`
+	// effective_spec is the job spec the job runs with as YAML, i.e. once templates, variables and the repository's
+	// defaults were applied. Only GetJob returns it.
+	EffectiveSpec        string   `protobuf:"bytes,7,opt,name=effective_spec,json=effectiveSpec,proto3" json:"effective_spec,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *JobStatus) Reset()         { *m = JobStatus{} }
+func (m *JobStatus) String() string { return proto.CompactTextString(m) }
+func (*JobStatus) ProtoMessage()    {}
+func (*JobStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9fe744feedd6d332, []int{23}
+}
+
+func (m *JobStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_JobStatus.Unmarshal(m, b)
+}
+func (m *JobStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_JobStatus.Marshal(b, m, deterministic)
+}
+func (m *JobStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_JobStatus.Merge(m, src)
+}
+func (m *JobStatus) XXX_Size() int {
+	return xxx_messageInfo_JobStatus.Size(m)
+}
+func (m *JobStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_JobStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_JobStatus proto.InternalMessageInfo
+
+func (m *JobStatus) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *JobStatus) GetMetadata() *JobMetadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+func (m *JobStatus) GetPhase() JobPhase {
+	if m != nil {
+		return m.Phase
+	}
+	return JobPhase_PHASE_UNKNOWN
+}
+
+func (m *JobStatus) GetConditions() *JobConditions {
+	if m != nil {
+		return m.Conditions
+	}
+	return nil
+}
+
+func (m *JobStatus) GetDetails() string {
+	if m != nil {
+		return m.Details
+	}
+	return ""
+}
+
+func (m *JobStatus) GetResults() []*JobResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+func (m *JobStatus) GetEffectiveSpec() string {
+	if m != nil {
+		return m.EffectiveSpec
+	}
+	return ""
+}
+
+type JobMetadata struct {
+	Owner      string               `protobuf:"bytes,1,opt,name=owner,proto3" json:"owner,omitempty"`
+	Repository *Repository          `protobuf:"bytes,2,opt,name=repository,proto3" json:"repository,omitempty"`
+	Trigger    JobTrigger           `protobuf:"varint,3,opt,name=trigger,proto3,enum=v1.JobTrigger" json:"trigger,omitempty"`
+	Created    *timestamp.Timestamp `protobuf:"bytes,4,opt,name=created,proto3" json:"created,omitempty"`
+	// finished is the time the job completed. It's empty for jobs which aren't done yet.
+	Finished    *timestamp.Timestamp `protobuf:"bytes,5,opt,name=finished,proto3" json:"finished,omitempty"`
+	Annotations []*Annotation        `protobuf:"bytes,6,rep,name=annotations,proto3" json:"annotations,omitempty"`
+	JobSpecName string               `protobuf:"bytes,7,opt,name=job_spec_name,json=jobSpecName,proto3" json:"job_spec_name,omitempty"`
+	// started is the time the job's containers started running. It's empty for jobs which haven't started yet,
+	// e.g. because they wait, are queued or are still being prepared.
+	Started              *timestamp.Timestamp `protobuf:"bytes,8,opt,name=started,proto3" json:"started,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *JobMetadata) Reset()         { *m = JobMetadata{} }
+func (m *JobMetadata) String() string { return proto.CompactTextString(m) }
+func (*JobMetadata) ProtoMessage()    {}
+func (*JobMetadata) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9fe744feedd6d332, []int{24}
+}
+
+func (m *JobMetadata) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_JobMetadata.Unmarshal(m, b)
+}
+func (m *JobMetadata) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_JobMetadata.Marshal(b, m, deterministic)
+}
+func (m *JobMetadata) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_JobMetadata.Merge(m, src)
+}
+func (m *JobMetadata) XXX_Size() int {
+	return xxx_messageInfo_JobMetadata.Size(m)
+}
+func (m *JobMetadata) XXX_DiscardUnknown() {
+	xxx_messageInfo_JobMetadata.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_JobMetadata proto.InternalMessageInfo
+
+func (m *JobMetadata) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *JobMetadata) GetRepository() *Repository {
+	if m != nil {
+		return m.Repository
+	}
+	return nil
+}
+
+func (m *JobMetadata) GetTrigger() JobTrigger {
+	if m != nil {
+		return m.Trigger
 	}
 	return JobTrigger_TRIGGER_UNKNOWN
 }
@@ -1310,6 +1742,13 @@ func (m *JobMetadata) GetJobSpecName() string {
 	return ""
 }
 
+func (m *JobMetadata) GetStarted() *timestamp.Timestamp {
+	if m != nil {
+		return m.Started
+	}
+	return nil
+}
+
 type Repository struct {
 	Host                 string   `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
 	Owner                string   `protobuf:"bytes,2,opt,name=owner,proto3" json:"owner,omitempty"`
@@ -1325,7 +1764,7 @@ func (m *Repository) Reset()         { *m = Repository{} }
 func (m *Repository) String() string { return proto.CompactTextString(m) }
 func (*Repository) ProtoMessage()    {}
 func (*Repository) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{18}
+	return fileDescriptor_9fe744feedd6d332, []int{25}
 }
 
 func (m *Repository) XXX_Unmarshal(b []byte) error {
@@ -1393,7 +1832,7 @@ func (m *Annotation) Reset()         { *m = Annotation{} }
 func (m *Annotation) String() string { return proto.CompactTextString(m) }
 func (*Annotation) ProtoMessage()    {}
 func (*Annotation) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{19}
+	return fileDescriptor_9fe744feedd6d332, []int{26}
 }
 
 func (m *Annotation) XXX_Unmarshal(b []byte) error {
@@ -1429,21 +1868,25 @@ func (m *Annotation) GetValue() string {
 }
 
 type JobConditions struct {
-	Success              bool                 `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
-	FailureCount         int32                `protobuf:"varint,2,opt,name=failure_count,json=failureCount,proto3" json:"failure_count,omitempty"`
-	CanReplay            bool                 `protobuf:"varint,3,opt,name=can_replay,json=canReplay,proto3" json:"can_replay,omitempty"`
-	WaitUntil            *timestamp.Timestamp `protobuf:"bytes,4,opt,name=wait_until,json=waitUntil,proto3" json:"wait_until,omitempty"`
-	DidExecute           bool                 `protobuf:"varint,5,opt,name=did_execute,json=didExecute,proto3" json:"did_execute,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
-	XXX_unrecognized     []byte               `json:"-"`
-	XXX_sizecache        int32                `json:"-"`
+	Success      bool                 `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	FailureCount int32                `protobuf:"varint,2,opt,name=failure_count,json=failureCount,proto3" json:"failure_count,omitempty"`
+	CanReplay    bool                 `protobuf:"varint,3,opt,name=can_replay,json=canReplay,proto3" json:"can_replay,omitempty"`
+	WaitUntil    *timestamp.Timestamp `protobuf:"bytes,4,opt,name=wait_until,json=waitUntil,proto3" json:"wait_until,omitempty"`
+	DidExecute   bool                 `protobuf:"varint,5,opt,name=did_execute,json=didExecute,proto3" json:"did_execute,omitempty"`
+	TimedOut     bool                 `protobuf:"varint,6,opt,name=timed_out,json=timedOut,proto3" json:"timed_out,omitempty"`
+	Skipped      bool                 `protobuf:"varint,7,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	// priority orders queued jobs: jobs with a higher priority start first, jobs of equal priority in the order they were queued
+	Priority             int32    `protobuf:"varint,8,opt,name=priority,proto3" json:"priority,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *JobConditions) Reset()         { *m = JobConditions{} }
 func (m *JobConditions) String() string { return proto.CompactTextString(m) }
 func (*JobConditions) ProtoMessage()    {}
 func (*JobConditions) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{20}
+	return fileDescriptor_9fe744feedd6d332, []int{27}
 }
 
 func (m *JobConditions) XXX_Unmarshal(b []byte) error {
@@ -1499,6 +1942,27 @@ func (m *JobConditions) GetDidExecute() bool {
 	return false
 }
 
+func (m *JobConditions) GetTimedOut() bool {
+	if m != nil {
+		return m.TimedOut
+	}
+	return false
+}
+
+func (m *JobConditions) GetSkipped() bool {
+	if m != nil {
+		return m.Skipped
+	}
+	return false
+}
+
+func (m *JobConditions) GetPriority() int32 {
+	if m != nil {
+		return m.Priority
+	}
+	return 0
+}
+
 type JobResult struct {
 	Type                 string   `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
 	Payload              string   `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
@@ -1513,7 +1977,7 @@ func (m *JobResult) Reset()         { *m = JobResult{} }
 func (m *JobResult) String() string { return proto.CompactTextString(m) }
 func (*JobResult) ProtoMessage()    {}
 func (*JobResult) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{21}
+	return fileDescriptor_9fe744feedd6d332, []int{28}
 }
 
 func (m *JobResult) XXX_Unmarshal(b []byte) error {
@@ -1563,19 +2027,21 @@ func (m *JobResult) GetChannels() []string {
 }
 
 type LogSliceEvent struct {
-	Name                 string       `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Type                 LogSliceType `protobuf:"varint,2,opt,name=type,proto3,enum=v1.LogSliceType" json:"type,omitempty"`
-	Payload              string       `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
-	XXX_unrecognized     []byte       `json:"-"`
-	XXX_sizecache        int32        `json:"-"`
+	Name    string       `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type    LogSliceType `protobuf:"varint,2,opt,name=type,proto3,enum=v1.LogSliceType" json:"type,omitempty"`
+	Payload string       `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
+	// parent is the name of the slice this slice is nested in, e.g. "build" for "build|compile"
+	Parent               string   `protobuf:"bytes,4,opt,name=parent,proto3" json:"parent,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *LogSliceEvent) Reset()         { *m = LogSliceEvent{} }
 func (m *LogSliceEvent) String() string { return proto.CompactTextString(m) }
 func (*LogSliceEvent) ProtoMessage()    {}
 func (*LogSliceEvent) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{22}
+	return fileDescriptor_9fe744feedd6d332, []int{29}
 }
 
 func (m *LogSliceEvent) XXX_Unmarshal(b []byte) error {
@@ -1617,8 +2083,17 @@ func (m *LogSliceEvent) GetPayload() string {
 	return ""
 }
 
+func (m *LogSliceEvent) GetParent() string {
+	if m != nil {
+		return m.Parent
+	}
+	return ""
+}
+
 type StopJobRequest struct {
-	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// reason explains why the job was stopped and ends up in the job's details
+	Reason               string   `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -1628,7 +2103,7 @@ func (m *StopJobRequest) Reset()         { *m = StopJobRequest{} }
 func (m *StopJobRequest) String() string { return proto.CompactTextString(m) }
 func (*StopJobRequest) ProtoMessage()    {}
 func (*StopJobRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{23}
+	return fileDescriptor_9fe744feedd6d332, []int{30}
 }
 
 func (m *StopJobRequest) XXX_Unmarshal(b []byte) error {
@@ -1656,6 +2131,13 @@ func (m *StopJobRequest) GetName() string {
 	return ""
 }
 
+func (m *StopJobRequest) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
 type StopJobResponse struct {
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
@@ -1666,7 +2148,7 @@ func (m *StopJobResponse) Reset()         { *m = StopJobResponse{} }
 func (m *StopJobResponse) String() string { return proto.CompactTextString(m) }
 func (*StopJobResponse) ProtoMessage()    {}
 func (*StopJobResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_9fe744feedd6d332, []int{24}
+	return fileDescriptor_9fe744feedd6d332, []int{31}
 }
 
 func (m *StopJobResponse) XXX_Unmarshal(b []byte) error {
@@ -1687,6 +2169,265 @@ func (m *StopJobResponse) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_StopJobResponse proto.InternalMessageInfo
 
+type StopJobsRequest struct {
+	// filter selects the jobs to stop - it must not be empty. Jobs which are done already are skipped.
+	Filter []*FilterExpression `protobuf:"bytes,1,rep,name=filter,proto3" json:"filter,omitempty"`
+	// reason explains why the jobs were stopped and ends up in the jobs' details
+	Reason string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	// dry_run lists the jobs which would be stopped without stopping them
+	DryRun               bool     `protobuf:"varint,3,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StopJobsRequest) Reset()         { *m = StopJobsRequest{} }
+func (m *StopJobsRequest) String() string { return proto.CompactTextString(m) }
+func (*StopJobsRequest) ProtoMessage()    {}
+func (*StopJobsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9fe744feedd6d332, []int{32}
+}
+
+func (m *StopJobsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StopJobsRequest.Unmarshal(m, b)
+}
+func (m *StopJobsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StopJobsRequest.Marshal(b, m, deterministic)
+}
+func (m *StopJobsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StopJobsRequest.Merge(m, src)
+}
+func (m *StopJobsRequest) XXX_Size() int {
+	return xxx_messageInfo_StopJobsRequest.Size(m)
+}
+func (m *StopJobsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StopJobsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StopJobsRequest proto.InternalMessageInfo
+
+func (m *StopJobsRequest) GetFilter() []*FilterExpression {
+	if m != nil {
+		return m.Filter
+	}
+	return nil
+}
+
+func (m *StopJobsRequest) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *StopJobsRequest) GetDryRun() bool {
+	if m != nil {
+		return m.DryRun
+	}
+	return false
+}
+
+type StopJobsResponse struct {
+	// stopped lists the jobs which were stopped, or would be stopped if this is a dry run
+	Stopped []string `protobuf:"bytes,1,rep,name=stopped,proto3" json:"stopped,omitempty"`
+	// skipped lists the jobs which finished between finding and stopping them
+	Skipped []string `protobuf:"bytes,2,rep,name=skipped,proto3" json:"skipped,omitempty"`
+	// failed lists the jobs which could not be stopped
+	Failed               []*StopJobsFailure `protobuf:"bytes,3,rep,name=failed,proto3" json:"failed,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *StopJobsResponse) Reset()         { *m = StopJobsResponse{} }
+func (m *StopJobsResponse) String() string { return proto.CompactTextString(m) }
+func (*StopJobsResponse) ProtoMessage()    {}
+func (*StopJobsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9fe744feedd6d332, []int{33}
+}
+
+func (m *StopJobsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StopJobsResponse.Unmarshal(m, b)
+}
+func (m *StopJobsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StopJobsResponse.Marshal(b, m, deterministic)
+}
+func (m *StopJobsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StopJobsResponse.Merge(m, src)
+}
+func (m *StopJobsResponse) XXX_Size() int {
+	return xxx_messageInfo_StopJobsResponse.Size(m)
+}
+func (m *StopJobsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_StopJobsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StopJobsResponse proto.InternalMessageInfo
+
+func (m *StopJobsResponse) GetStopped() []string {
+	if m != nil {
+		return m.Stopped
+	}
+	return nil
+}
+
+func (m *StopJobsResponse) GetSkipped() []string {
+	if m != nil {
+		return m.Skipped
+	}
+	return nil
+}
+
+func (m *StopJobsResponse) GetFailed() []*StopJobsFailure {
+	if m != nil {
+		return m.Failed
+	}
+	return nil
+}
+
+type StopJobsFailure struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Error                string   `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StopJobsFailure) Reset()         { *m = StopJobsFailure{} }
+func (m *StopJobsFailure) String() string { return proto.CompactTextString(m) }
+func (*StopJobsFailure) ProtoMessage()    {}
+func (*StopJobsFailure) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9fe744feedd6d332, []int{34}
+}
+
+func (m *StopJobsFailure) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StopJobsFailure.Unmarshal(m, b)
+}
+func (m *StopJobsFailure) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StopJobsFailure.Marshal(b, m, deterministic)
+}
+func (m *StopJobsFailure) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StopJobsFailure.Merge(m, src)
+}
+func (m *StopJobsFailure) XXX_Size() int {
+	return xxx_messageInfo_StopJobsFailure.Size(m)
+}
+func (m *StopJobsFailure) XXX_DiscardUnknown() {
+	xxx_messageInfo_StopJobsFailure.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StopJobsFailure proto.InternalMessageInfo
+
+func (m *StopJobsFailure) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *StopJobsFailure) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+type SetJobAnnotationsRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// annotations are added to the job, replacing annotations with the same key
+	Annotations []*Annotation `protobuf:"bytes,2,rep,name=annotations,proto3" json:"annotations,omitempty"`
+	// remove lists the keys of annotations to remove from the job
+	Remove               []string `protobuf:"bytes,3,rep,name=remove,proto3" json:"remove,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetJobAnnotationsRequest) Reset()         { *m = SetJobAnnotationsRequest{} }
+func (m *SetJobAnnotationsRequest) String() string { return proto.CompactTextString(m) }
+func (*SetJobAnnotationsRequest) ProtoMessage()    {}
+func (*SetJobAnnotationsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9fe744feedd6d332, []int{35}
+}
+
+func (m *SetJobAnnotationsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetJobAnnotationsRequest.Unmarshal(m, b)
+}
+func (m *SetJobAnnotationsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetJobAnnotationsRequest.Marshal(b, m, deterministic)
+}
+func (m *SetJobAnnotationsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetJobAnnotationsRequest.Merge(m, src)
+}
+func (m *SetJobAnnotationsRequest) XXX_Size() int {
+	return xxx_messageInfo_SetJobAnnotationsRequest.Size(m)
+}
+func (m *SetJobAnnotationsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetJobAnnotationsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetJobAnnotationsRequest proto.InternalMessageInfo
+
+func (m *SetJobAnnotationsRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *SetJobAnnotationsRequest) GetAnnotations() []*Annotation {
+	if m != nil {
+		return m.Annotations
+	}
+	return nil
+}
+
+func (m *SetJobAnnotationsRequest) GetRemove() []string {
+	if m != nil {
+		return m.Remove
+	}
+	return nil
+}
+
+type SetJobAnnotationsResponse struct {
+	Status               *JobStatus `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
+	XXX_unrecognized     []byte     `json:"-"`
+	XXX_sizecache        int32      `json:"-"`
+}
+
+func (m *SetJobAnnotationsResponse) Reset()         { *m = SetJobAnnotationsResponse{} }
+func (m *SetJobAnnotationsResponse) String() string { return proto.CompactTextString(m) }
+func (*SetJobAnnotationsResponse) ProtoMessage()    {}
+func (*SetJobAnnotationsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9fe744feedd6d332, []int{36}
+}
+
+func (m *SetJobAnnotationsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetJobAnnotationsResponse.Unmarshal(m, b)
+}
+func (m *SetJobAnnotationsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetJobAnnotationsResponse.Marshal(b, m, deterministic)
+}
+func (m *SetJobAnnotationsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetJobAnnotationsResponse.Merge(m, src)
+}
+func (m *SetJobAnnotationsResponse) XXX_Size() int {
+	return xxx_messageInfo_SetJobAnnotationsResponse.Size(m)
+}
+func (m *SetJobAnnotationsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetJobAnnotationsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetJobAnnotationsResponse proto.InternalMessageInfo
+
+func (m *SetJobAnnotationsResponse) GetStatus() *JobStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("v1.FilterOp", FilterOp_name, FilterOp_value)
 	proto.RegisterEnum("v1.ListenRequestLogs", ListenRequestLogs_name, ListenRequestLogs_value)
@@ -1698,6 +2439,9 @@ func init() {
 	proto.RegisterType((*StartGitHubJobRequest)(nil), "v1.StartGitHubJobRequest")
 	proto.RegisterType((*StartJobRequest)(nil), "v1.StartJobRequest")
 	proto.RegisterType((*StartFromPreviousJobRequest)(nil), "v1.StartFromPreviousJobRequest")
+	proto.RegisterType((*RetryPipelineRequest)(nil), "v1.RetryPipelineRequest")
+	proto.RegisterType((*RetryPipelineResponse)(nil), "v1.RetryPipelineResponse")
+	proto.RegisterType((*RetriedJob)(nil), "v1.RetriedJob")
 	proto.RegisterType((*ListJobsRequest)(nil), "v1.ListJobsRequest")
 	proto.RegisterType((*FilterExpression)(nil), "v1.FilterExpression")
 	proto.RegisterType((*FilterTerm)(nil), "v1.FilterTerm")
@@ -1709,6 +2453,10 @@ func init() {
 	proto.RegisterType((*GetJobResponse)(nil), "v1.GetJobResponse")
 	proto.RegisterType((*ListenRequest)(nil), "v1.ListenRequest")
 	proto.RegisterType((*ListenResponse)(nil), "v1.ListenResponse")
+	proto.RegisterType((*DownloadLogsRequest)(nil), "v1.DownloadLogsRequest")
+	proto.RegisterType((*DownloadLogsResponse)(nil), "v1.DownloadLogsResponse")
+	proto.RegisterType((*DownloadArtifactRequest)(nil), "v1.DownloadArtifactRequest")
+	proto.RegisterType((*DownloadArtifactResponse)(nil), "v1.DownloadArtifactResponse")
 	proto.RegisterType((*JobStatus)(nil), "v1.JobStatus")
 	proto.RegisterType((*JobMetadata)(nil), "v1.JobMetadata")
 	proto.RegisterType((*Repository)(nil), "v1.Repository")
@@ -1718,6 +2466,11 @@ func init() {
 	proto.RegisterType((*LogSliceEvent)(nil), "v1.LogSliceEvent")
 	proto.RegisterType((*StopJobRequest)(nil), "v1.StopJobRequest")
 	proto.RegisterType((*StopJobResponse)(nil), "v1.StopJobResponse")
+	proto.RegisterType((*StopJobsRequest)(nil), "v1.StopJobsRequest")
+	proto.RegisterType((*StopJobsResponse)(nil), "v1.StopJobsResponse")
+	proto.RegisterType((*StopJobsFailure)(nil), "v1.StopJobsFailure")
+	proto.RegisterType((*SetJobAnnotationsRequest)(nil), "v1.SetJobAnnotationsRequest")
+	proto.RegisterType((*SetJobAnnotationsResponse)(nil), "v1.SetJobAnnotationsResponse")
 }
 
 func init() {
@@ -1725,118 +2478,156 @@ func init() {
 }
 
 var fileDescriptor_9fe744feedd6d332 = []byte{
-	// 1776 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x58, 0xdd, 0x72, 0x1b, 0x4b,
-	0x11, 0xf6, 0xea, 0xcf, 0x52, 0x4b, 0xb2, 0x37, 0x63, 0x87, 0xd2, 0xf1, 0x81, 0x8a, 0xb3, 0x27,
-	0xa9, 0xf8, 0x18, 0x90, 0x8f, 0x7d, 0x52, 0x1c, 0x0e, 0xc5, 0x05, 0x8a, 0xad, 0x58, 0x0a, 0x8a,
-	0x24, 0x66, 0x25, 0x0c, 0x14, 0x55, 0x5b, 0xab, 0xd5, 0x48, 0xda, 0x64, 0xb5, 0xb3, 0xec, 0x8e,
-	0xec, 0xb8, 0x8a, 0x27, 0xe0, 0x86, 0x2b, 0xb8, 0xe4, 0x35, 0xb8, 0xe6, 0x92, 0x17, 0x81, 0x1b,
-	0x1e, 0x82, 0x9a, 0x9f, 0xfd, 0x91, 0xec, 0x24, 0x04, 0xaa, 0xb8, 0x53, 0x7f, 0xdd, 0xd3, 0xd3,
-	0xfd, 0x4d, 0x4f, 0x4f, 0xaf, 0xa0, 0x7a, 0x43, 0xc2, 0x19, 0x6b, 0x06, 0x21, 0x65, 0x14, 0xe5,
-	0xae, 0x4f, 0x0f, 0x1e, 0xcd, 0x29, 0x9d, 0x7b, 0xe4, 0x44, 0x20, 0x93, 0xd5, 0xec, 0x84, 0xb9,
-	0x4b, 0x12, 0x31, 0x7b, 0x19, 0x48, 0x23, 0xe3, 0x9f, 0x1a, 0xec, 0x9b, 0xcc, 0x0e, 0x59, 0x8f,
-	0x3a, 0xb6, 0xf7, 0x8a, 0x4e, 0x30, 0xf9, 0xdd, 0x8a, 0x44, 0x0c, 0xfd, 0x10, 0xca, 0x4b, 0xc2,
-	0xec, 0xa9, 0xcd, 0xec, 0x86, 0x76, 0xa8, 0x1d, 0x55, 0xcf, 0x76, 0x9b, 0xd7, 0xa7, 0xcd, 0x57,
-	0x74, 0xf2, 0x5a, 0xc1, 0x9d, 0x2d, 0x9c, 0x98, 0xa0, 0xc7, 0x50, 0x75, 0xa8, 0x3f, 0x73, 0xe7,
-	0xd6, 0xad, 0xbd, 0xf4, 0x1a, 0xb9, 0x43, 0xed, 0xa8, 0xd6, 0xd9, 0xc2, 0x20, 0xc1, 0x5f, 0xdb,
-	0x4b, 0x0f, 0x7d, 0x0e, 0xe5, 0x37, 0x74, 0x22, 0xf5, 0x79, 0xa5, 0xdf, 0x7e, 0x43, 0x27, 0x42,
-	0xf9, 0x14, 0xea, 0x37, 0x34, 0x7c, 0x1b, 0x05, 0xb6, 0x43, 0x2c, 0x66, 0x87, 0x8d, 0x82, 0xb2,
-	0xa8, 0x25, 0xf0, 0xc8, 0x0e, 0x51, 0x13, 0xd0, 0x9a, 0x99, 0x35, 0xa5, 0x3e, 0x69, 0x14, 0x0f,
-	0xb5, 0xa3, 0x72, 0x67, 0x0b, 0xeb, 0x59, 0xdb, 0x0b, 0xea, 0x93, 0x17, 0x15, 0xd8, 0x76, 0xa8,
-	0xcf, 0x88, 0xcf, 0x8c, 0x6f, 0x41, 0x17, 0x89, 0x8a, 0x1c, 0xa3, 0x80, 0xfa, 0x11, 0x41, 0x4f,
-	0xa1, 0x14, 0x31, 0x9b, 0xad, 0x22, 0x95, 0x62, 0x5d, 0xa5, 0x68, 0x0a, 0x10, 0x2b, 0xa5, 0xf1,
-	0xa7, 0x1c, 0x3c, 0x14, 0x6b, 0x2f, 0x5d, 0xd6, 0x59, 0x4d, 0x32, 0x2c, 0x7d, 0xff, 0xa3, 0x2c,
-	0x65, 0x38, 0xfa, 0x4c, 0x12, 0x10, 0xd8, 0x6c, 0x21, 0x08, 0xaa, 0x88, 0xf4, 0x87, 0x36, 0x5b,
-	0xc4, 0xaa, 0x94, 0x9b, 0x94, 0x99, 0xc7, 0x50, 0x9b, 0xbb, 0x6c, 0xb1, 0x9a, 0x58, 0x8c, 0xbe,
-	0x25, 0xbe, 0x20, 0xa6, 0x82, 0xab, 0x12, 0x1b, 0x71, 0x08, 0x1d, 0x40, 0x39, 0x72, 0xa7, 0xc4,
-	0xa3, 0xf6, 0x54, 0x70, 0x51, 0xc3, 0x89, 0x8c, 0xbe, 0x05, 0xb8, 0xb1, 0x5d, 0x66, 0xad, 0x7c,
-	0xe6, 0x7a, 0x8d, 0x92, 0x88, 0xf1, 0xa0, 0x29, 0xcb, 0xa2, 0x19, 0x97, 0x45, 0x73, 0x14, 0x97,
-	0x05, 0xae, 0x70, 0xeb, 0x31, 0x37, 0x46, 0x8f, 0xa0, 0xea, 0xdb, 0x4b, 0x62, 0x45, 0xab, 0xd9,
-	0xcc, 0x7d, 0xd7, 0xd8, 0x16, 0x1b, 0x03, 0x87, 0x4c, 0x81, 0x18, 0xff, 0xd2, 0x60, 0x37, 0xe5,
-	0xf4, 0xff, 0xc6, 0x48, 0x36, 0xdd, 0xc2, 0x07, 0xd3, 0x2d, 0xfe, 0x0f, 0xe9, 0x96, 0xee, 0xa4,
-	0xfb, 0x17, 0x0d, 0x3e, 0x17, 0xe9, 0xbe, 0x0c, 0xe9, 0x72, 0x18, 0x92, 0x6b, 0x97, 0xae, 0xa2,
-	0x4c, 0xea, 0x8f, 0xa1, 0x16, 0x28, 0xd4, 0x7a, 0x43, 0x27, 0x22, 0xfd, 0x0a, 0xae, 0x06, 0xa9,
-	0xe5, 0x9d, 0xc3, 0xcc, 0xdd, 0x3d, 0xcc, 0xf5, 0x0c, 0xf2, 0x9f, 0x90, 0x81, 0xf1, 0x67, 0x0d,
-	0x76, 0x7b, 0x6e, 0xc4, 0x8f, 0x23, 0x8a, 0x83, 0xfa, 0x01, 0x94, 0x66, 0xae, 0xc7, 0x48, 0xd8,
-	0xd0, 0x0e, 0xf3, 0x47, 0xd5, 0xb3, 0x7d, 0x7e, 0x1a, 0x2f, 0x05, 0xd2, 0x7e, 0x17, 0x84, 0x24,
-	0x8a, 0x5c, 0xea, 0x63, 0x65, 0x83, 0xbe, 0x84, 0x22, 0x0d, 0xa7, 0x24, 0x6c, 0xe4, 0x84, 0xf1,
-	0x1e, 0x37, 0x1e, 0x70, 0x20, 0x63, 0x2b, 0x2d, 0xd0, 0x3e, 0x14, 0x23, 0x4e, 0x86, 0x08, 0xb1,
-	0x88, 0xa5, 0xc0, 0x51, 0xcf, 0x5d, 0xba, 0x4c, 0x1c, 0x4c, 0x11, 0x4b, 0xc1, 0xf8, 0x31, 0xe8,
-	0x9b, 0x5b, 0xa2, 0x27, 0x50, 0x64, 0x24, 0x5c, 0x46, 0x2a, 0xae, 0x9d, 0x34, 0xae, 0x11, 0x09,
-	0x97, 0x58, 0x2a, 0x8d, 0xdf, 0x03, 0xa4, 0x20, 0xf7, 0x3e, 0x73, 0x89, 0x37, 0x55, 0xd4, 0x4a,
-	0x81, 0xa3, 0xd7, 0xb6, 0xb7, 0x22, 0x8a, 0x4d, 0x29, 0xa0, 0x63, 0xa8, 0xd0, 0x80, 0x84, 0x36,
-	0x73, 0xa9, 0x2f, 0x62, 0xdc, 0x39, 0xab, 0xa5, 0x7b, 0x0c, 0x02, 0x9c, 0xaa, 0xd1, 0x77, 0xa0,
-	0xe4, 0x93, 0xb9, 0xcd, 0x88, 0x08, 0xbb, 0x8c, 0x95, 0x64, 0xb4, 0x61, 0x77, 0x23, 0xfb, 0xf7,
-	0x84, 0xf0, 0x5d, 0xa8, 0xd8, 0x91, 0x43, 0xfc, 0xa9, 0xeb, 0xcf, 0x45, 0x18, 0x65, 0x9c, 0x02,
-	0xc6, 0x00, 0xf4, 0xf4, 0x58, 0x54, 0xeb, 0xd9, 0x87, 0x22, 0xa3, 0xcc, 0xf6, 0x84, 0x9f, 0x22,
-	0x96, 0x02, 0x6f, 0x48, 0x21, 0x89, 0x56, 0x1e, 0x53, 0x07, 0xb0, 0xd9, 0x90, 0xa4, 0xd2, 0xf8,
-	0x19, 0xe8, 0xe6, 0x6a, 0x12, 0x39, 0xa1, 0x3b, 0x21, 0xff, 0xd5, 0x41, 0x1b, 0x3f, 0x81, 0x07,
-	0x19, 0x0f, 0x69, 0x3b, 0x54, 0xbb, 0xdf, 0xdf, 0x0e, 0xd5, 0xee, 0x5f, 0x40, 0xfd, 0x92, 0x64,
-	0xef, 0x3c, 0x82, 0x02, 0xbf, 0x26, 0x8a, 0x12, 0xf1, 0xdb, 0xf8, 0x06, 0x76, 0x62, 0xa3, 0x4f,
-	0xf3, 0xbe, 0x80, 0x3a, 0x27, 0x8b, 0xf8, 0x1f, 0xf0, 0x8e, 0x1a, 0xb0, 0xbd, 0x0a, 0xa6, 0x36,
-	0x23, 0x91, 0x62, 0x3b, 0x16, 0xd1, 0x97, 0x50, 0xf0, 0xe8, 0x3c, 0x52, 0x27, 0xfe, 0x90, 0xef,
-	0xb1, 0xe6, 0xae, 0x47, 0xe7, 0x11, 0x16, 0x26, 0x06, 0x85, 0x9d, 0x58, 0xa5, 0x42, 0x7c, 0x06,
-	0x25, 0xe9, 0xe7, 0xde, 0x10, 0x3b, 0x5b, 0x58, 0xa9, 0xf9, 0x3d, 0x89, 0x3c, 0xd7, 0x91, 0x25,
-	0x57, 0x3d, 0x7b, 0x20, 0xb6, 0xa1, 0x73, 0x93, 0x63, 0xed, 0x6b, 0xe2, 0xb3, 0xce, 0x16, 0x96,
-	0x16, 0xd9, 0x27, 0xe8, 0x1f, 0x1a, 0x54, 0x12, 0x6f, 0xf7, 0xe6, 0x95, 0xed, 0x9e, 0xb9, 0x8f,
-	0x75, 0x4f, 0x03, 0x8a, 0xc1, 0xc2, 0x8e, 0x48, 0xb6, 0xba, 0x5f, 0xd1, 0xc9, 0x90, 0x63, 0x58,
-	0xaa, 0xd0, 0x29, 0xf0, 0x27, 0x78, 0xea, 0xf2, 0x32, 0x8f, 0x44, 0x75, 0xab, 0x68, 0x5f, 0xd1,
-	0xc9, 0x79, 0xa2, 0xc0, 0x19, 0x23, 0xce, 0xed, 0x94, 0x30, 0xdb, 0xf5, 0x22, 0xd1, 0x3f, 0x2b,
-	0x38, 0x16, 0xd1, 0x33, 0xd8, 0x96, 0x87, 0x14, 0x35, 0x4a, 0x6b, 0xe5, 0x89, 0x05, 0x8a, 0x63,
-	0xad, 0xf1, 0xb7, 0x1c, 0x54, 0x33, 0x31, 0xf3, 0x62, 0xa7, 0x37, 0xbe, 0x28, 0x4d, 0x71, 0x69,
-	0x84, 0x80, 0x9a, 0x00, 0x21, 0x09, 0x68, 0xe4, 0x32, 0x1a, 0xde, 0xaa, 0x74, 0x45, 0x1b, 0xc0,
-	0x09, 0x8a, 0x33, 0x16, 0xe8, 0x08, 0xb6, 0x59, 0xe8, 0xce, 0xe7, 0x24, 0x54, 0x19, 0xef, 0xa8,
-	0xed, 0x47, 0x12, 0xc5, 0xb1, 0x1a, 0x3d, 0x87, 0x6d, 0x27, 0x24, 0x36, 0x23, 0x53, 0x95, 0xf2,
-	0x87, 0x1a, 0x68, 0x6c, 0x8a, 0x7e, 0x04, 0xe5, 0x99, 0xeb, 0xbb, 0xd1, 0x82, 0x4c, 0xff, 0x83,
-	0x97, 0x23, 0xb1, 0x45, 0x5f, 0x41, 0xd5, 0xf6, 0x7d, 0xca, 0x6c, 0x49, 0x72, 0x29, 0xed, 0x67,
-	0xad, 0x04, 0xc6, 0x59, 0x13, 0x64, 0x40, 0x9d, 0x3f, 0x6e, 0x51, 0x40, 0x1c, 0x4b, 0xd4, 0x80,
-	0x7c, 0x5b, 0xab, 0x6f, 0xe8, 0xc4, 0x0c, 0x88, 0xd3, 0xe7, 0x17, 0xe8, 0x1d, 0x40, 0xca, 0x03,
-	0x2f, 0x96, 0x05, 0x8d, 0x58, 0x5c, 0x2c, 0xfc, 0x77, 0xca, 0x6a, 0x2e, 0xcb, 0x2a, 0x82, 0x02,
-	0xe7, 0x4c, 0x50, 0x54, 0xc1, 0xe2, 0x37, 0xd2, 0x21, 0x1f, 0x92, 0x99, 0x1a, 0x1d, 0xf8, 0x4f,
-	0xfe, 0x86, 0xf2, 0x67, 0x89, 0xf7, 0x04, 0x75, 0xca, 0x89, 0x6c, 0x3c, 0x07, 0x48, 0x03, 0xe7,
-	0x6b, 0xdf, 0x92, 0x5b, 0xb5, 0x31, 0xff, 0x79, 0x7f, 0xbf, 0x35, 0xfe, 0xae, 0x41, 0x7d, 0xad,
-	0xa8, 0x78, 0x21, 0x45, 0x2b, 0xc7, 0x21, 0x91, 0x1c, 0xaf, 0xca, 0x38, 0x16, 0xd1, 0x17, 0x50,
-	0x9f, 0xd9, 0xae, 0xb7, 0x0a, 0x89, 0xe5, 0xd0, 0x95, 0xcf, 0x84, 0xa7, 0x22, 0xae, 0x29, 0xf0,
-	0x9c, 0x63, 0xe8, 0x7b, 0x00, 0x8e, 0xed, 0x5b, 0x21, 0x09, 0x3c, 0xfb, 0x56, 0xa4, 0x53, 0xc6,
-	0x15, 0xc7, 0xf6, 0xb1, 0x00, 0x36, 0xde, 0xc9, 0xc2, 0x27, 0xbe, 0xf4, 0x53, 0x77, 0x6a, 0x91,
-	0x77, 0xc4, 0x59, 0x31, 0x35, 0x3e, 0x62, 0x98, 0xba, 0xd3, 0xb6, 0x44, 0x8c, 0x1b, 0x71, 0x4f,
-	0x65, 0x55, 0x73, 0x42, 0xd9, 0x6d, 0x90, 0xdc, 0x53, 0xfe, 0x9b, 0xa7, 0x16, 0xd8, 0xb7, 0x62,
-	0x02, 0x51, 0x73, 0x8b, 0x12, 0xd1, 0x21, 0x54, 0xa7, 0x84, 0xf7, 0xd5, 0x20, 0x79, 0x78, 0x2a,
-	0x38, 0x0b, 0x71, 0xea, 0x9d, 0x85, 0xed, 0xfb, 0xc4, 0xe3, 0x17, 0x32, 0xcf, 0xa9, 0x8f, 0x65,
-	0xc3, 0x81, 0xfa, 0x5a, 0x1b, 0xb9, 0xb7, 0x49, 0x3c, 0x51, 0x01, 0xe5, 0xc4, 0x25, 0xd0, 0xb3,
-	0xbd, 0x67, 0x74, 0x1b, 0x90, 0xbb, 0x21, 0xe6, 0xd7, 0x42, 0x34, 0x9e, 0xc0, 0x8e, 0xc9, 0x68,
-	0xf0, 0x91, 0x06, 0xfe, 0x80, 0xcf, 0x76, 0xca, 0x4a, 0xb6, 0xc7, 0x63, 0x0b, 0xca, 0xf1, 0xeb,
-	0x89, 0xea, 0x50, 0x19, 0x0c, 0xad, 0xf6, 0x2f, 0xc6, 0xad, 0x9e, 0xa9, 0x6f, 0x21, 0x04, 0x3b,
-	0x83, 0xa1, 0x65, 0x8e, 0x5a, 0x78, 0x64, 0x5a, 0x57, 0xdd, 0x51, 0x47, 0xd7, 0x90, 0x0e, 0x35,
-	0x6e, 0xd2, 0xbf, 0x50, 0x48, 0x0e, 0xed, 0x42, 0x75, 0x30, 0xb4, 0xce, 0x07, 0xfd, 0x51, 0xab,
-	0xdb, 0x37, 0xf5, 0x7c, 0xec, 0xe5, 0x57, 0x5d, 0x73, 0x64, 0xea, 0x85, 0xe3, 0x5f, 0xc2, 0x83,
-	0x3b, 0xcd, 0x1a, 0x3d, 0x80, 0x7a, 0x6f, 0x70, 0x69, 0x5a, 0x17, 0x5d, 0xb3, 0xf5, 0xa2, 0xd7,
-	0xbe, 0xd0, 0xb7, 0x12, 0x68, 0xdc, 0x37, 0x7b, 0xdd, 0xf3, 0xf6, 0x85, 0xae, 0xa1, 0x1a, 0x94,
-	0x05, 0x84, 0x5b, 0x57, 0x7a, 0x8e, 0xfb, 0x15, 0x52, 0x67, 0xf4, 0xba, 0xa7, 0xe7, 0x8f, 0x7f,
-	0x0b, 0x90, 0xb6, 0x09, 0xb4, 0x07, 0xbb, 0x23, 0xdc, 0xbd, 0xbc, 0x6c, 0x63, 0x6b, 0xdc, 0xff,
-	0x79, 0x7f, 0x70, 0xd5, 0x97, 0x09, 0xc4, 0xe0, 0xeb, 0x56, 0x7f, 0xdc, 0xea, 0xc9, 0x04, 0x62,
-	0x6c, 0x38, 0x36, 0x79, 0x02, 0x99, 0xa5, 0x17, 0xed, 0x5e, 0x7b, 0xd4, 0xbe, 0xd0, 0xf3, 0xc7,
-	0x7f, 0xd4, 0xa0, 0x1c, 0xf7, 0x5d, 0x1e, 0xda, 0xb0, 0xd3, 0x32, 0xdb, 0x19, 0xd7, 0x7b, 0xb0,
-	0x2b, 0xa1, 0x21, 0x6e, 0x0f, 0x5b, 0xb8, 0xdb, 0xbf, 0xd4, 0x35, 0xbe, 0x9f, 0x04, 0x05, 0x67,
-	0x1c, 0xcb, 0xa5, 0x6b, 0xf1, 0xb8, 0xdf, 0xe7, 0x50, 0x1e, 0xed, 0x00, 0x48, 0xe8, 0x62, 0xd0,
-	0x6f, 0xeb, 0x85, 0xd4, 0xe4, 0xbc, 0xd7, 0x6e, 0xf5, 0xc7, 0x43, 0xbd, 0x98, 0x42, 0x57, 0xad,
-	0xae, 0x70, 0x54, 0x3a, 0xfe, 0x83, 0x06, 0xb5, 0x6c, 0x49, 0xf0, 0x10, 0x04, 0x53, 0x56, 0xeb,
-	0x45, 0xab, 0xcf, 0x5d, 0x71, 0x16, 0x77, 0xa1, 0x2a, 0x41, 0xb1, 0x5c, 0xd7, 0x52, 0x40, 0xc4,
-	0x24, 0x03, 0x92, 0x00, 0x3f, 0xb2, 0x76, 0x7f, 0x24, 0x03, 0x92, 0x90, 0x0a, 0x28, 0x91, 0x5f,
-	0xb6, 0xba, 0x3d, 0xbd, 0xc8, 0x39, 0x93, 0x32, 0x6e, 0x9b, 0xe3, 0xde, 0x48, 0x2f, 0x9d, 0xfd,
-	0xb5, 0x00, 0xb5, 0x2b, 0xfe, 0x5d, 0x6a, 0x92, 0xf0, 0xda, 0x75, 0x08, 0x3a, 0x87, 0xfa, 0xda,
-	0x27, 0x27, 0x6a, 0xf0, 0x12, 0xbe, 0xef, 0x2b, 0xf4, 0x60, 0x3f, 0xd1, 0x64, 0xea, 0xd0, 0xd8,
-	0x3a, 0xd2, 0xd0, 0x39, 0x2f, 0xe2, 0xec, 0x27, 0x19, 0xfa, 0x2c, 0xb1, 0xdd, 0xfc, 0x4c, 0x7b,
-	0x9f, 0x1b, 0x34, 0x50, 0x1f, 0xbf, 0x1b, 0x03, 0x3d, 0x7a, 0x94, 0xd8, 0xdf, 0x3f, 0xea, 0xbf,
-	0xd7, 0xe1, 0x37, 0x50, 0x8e, 0x51, 0xb4, 0xb7, 0x6e, 0xf3, 0xd1, 0x85, 0xf1, 0x88, 0x28, 0x17,
-	0x6e, 0xcc, 0xf1, 0x72, 0xe1, 0xe6, 0x14, 0x69, 0x6c, 0xa1, 0x9f, 0x42, 0x25, 0x19, 0xe4, 0x90,
-	0xf4, 0xbe, 0x31, 0x19, 0x1e, 0x3c, 0xdc, 0x40, 0xe3, 0xb5, 0x5f, 0x69, 0xe8, 0x14, 0x4a, 0x72,
-	0x4a, 0x43, 0x62, 0x28, 0x58, 0x1b, 0xeb, 0x0e, 0x50, 0x16, 0x4a, 0x36, 0xfc, 0x1a, 0x4a, 0xf2,
-	0x8e, 0xca, 0x25, 0x6b, 0xf7, 0x55, 0x2e, 0x59, 0x1f, 0xaa, 0xc4, 0x3e, 0xcf, 0x61, 0x5b, 0x35,
-	0x13, 0x84, 0x24, 0x03, 0xd9, 0xfe, 0x73, 0xb0, 0xb7, 0x86, 0xc5, 0xeb, 0x5e, 0x3c, 0xfb, 0xcd,
-	0x53, 0xf9, 0x65, 0xd4, 0x74, 0xe8, 0xf2, 0xc4, 0x89, 0x6e, 0x88, 0xeb, 0x2c, 0x88, 0x77, 0x22,
-	0xfe, 0xe5, 0x38, 0x09, 0xde, 0xce, 0x4f, 0xec, 0xc0, 0x3d, 0xb9, 0x3e, 0x9d, 0x94, 0x44, 0xbf,
-	0xff, 0xfa, 0xdf, 0x01, 0x00, 0x00, 0xff, 0xff, 0x2e, 0x79, 0x4b, 0x3b, 0x00, 0x11, 0x00, 0x00,
+	// 2381 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x59, 0xcd, 0x72, 0x1b, 0xc7,
+	0x11, 0x26, 0x40, 0xfc, 0x36, 0x00, 0x72, 0x35, 0xa2, 0x62, 0x08, 0xb2, 0xcb, 0xf2, 0xda, 0x2a,
+	0xd3, 0x74, 0x42, 0x5a, 0xb2, 0x2a, 0x89, 0x13, 0x1f, 0x02, 0x01, 0x2b, 0x92, 0x0a, 0x04, 0xc0,
+	0xb3, 0x40, 0x94, 0xe4, 0xb2, 0xb5, 0xd8, 0x1d, 0x80, 0x2b, 0x01, 0x3b, 0x9b, 0xdd, 0x01, 0x7f,
+	0x2a, 0xb7, 0x9c, 0x93, 0x43, 0x6e, 0x39, 0xc4, 0xa9, 0xca, 0x25, 0x0f, 0x91, 0x27, 0xc9, 0x1b,
+	0xe4, 0x11, 0x72, 0x4d, 0xcd, 0xcf, 0xfe, 0x00, 0x84, 0x48, 0xcb, 0xa9, 0xca, 0x0d, 0xfd, 0x4d,
+	0xcf, 0x4c, 0xf7, 0x37, 0x3d, 0xdd, 0xbd, 0x03, 0xa8, 0x5d, 0x90, 0x70, 0xca, 0x0e, 0x83, 0x90,
+	0x32, 0x8a, 0xf2, 0xe7, 0x8f, 0x5b, 0x1f, 0xce, 0x28, 0x9d, 0xcd, 0xc9, 0x91, 0x40, 0x26, 0xcb,
+	0xe9, 0x11, 0xf3, 0x16, 0x24, 0x62, 0xf6, 0x22, 0x90, 0x4a, 0xfa, 0xbf, 0x73, 0xb0, 0x67, 0x32,
+	0x3b, 0x64, 0x3d, 0xea, 0xd8, 0xf3, 0x17, 0x74, 0x82, 0xc9, 0xef, 0x96, 0x24, 0x62, 0xe8, 0x47,
+	0x50, 0x59, 0x10, 0x66, 0xbb, 0x36, 0xb3, 0x9b, 0xb9, 0x87, 0xb9, 0xfd, 0xda, 0x93, 0xdd, 0xc3,
+	0xf3, 0xc7, 0x87, 0x2f, 0xe8, 0xe4, 0xa5, 0x82, 0x4f, 0xb6, 0x70, 0xa2, 0x82, 0x3e, 0x82, 0x9a,
+	0x43, 0xfd, 0xa9, 0x37, 0xb3, 0xae, 0xec, 0xc5, 0xbc, 0x99, 0x7f, 0x98, 0xdb, 0xaf, 0x9f, 0x6c,
+	0x61, 0x90, 0xe0, 0x6f, 0xec, 0xc5, 0x1c, 0x3d, 0x80, 0xca, 0x6b, 0x3a, 0x91, 0xe3, 0xdb, 0x6a,
+	0xbc, 0xfc, 0x9a, 0x4e, 0xc4, 0xe0, 0x23, 0x68, 0x5c, 0xd0, 0xf0, 0x4d, 0x14, 0xd8, 0x0e, 0xb1,
+	0x98, 0x1d, 0x36, 0x0b, 0x4a, 0xa3, 0x9e, 0xc0, 0x23, 0x3b, 0x44, 0x87, 0x80, 0x56, 0xd4, 0x2c,
+	0x97, 0xfa, 0xa4, 0x59, 0x7c, 0x98, 0xdb, 0xaf, 0x9c, 0x6c, 0x61, 0x2d, 0xab, 0xdb, 0xa5, 0x3e,
+	0x79, 0x56, 0x85, 0xb2, 0x43, 0x7d, 0x46, 0x7c, 0xa6, 0x7f, 0x05, 0x9a, 0x70, 0x54, 0xf8, 0x18,
+	0x05, 0xd4, 0x8f, 0x08, 0x7a, 0x04, 0xa5, 0x88, 0xd9, 0x6c, 0x19, 0x29, 0x17, 0x1b, 0xca, 0x45,
+	0x53, 0x80, 0x58, 0x0d, 0xea, 0xff, 0xcc, 0xc3, 0x3d, 0x31, 0xf7, 0xd8, 0x63, 0x27, 0xcb, 0x49,
+	0x86, 0xa5, 0xcf, 0x6f, 0x65, 0x29, 0xc3, 0xd1, 0x7d, 0x49, 0x40, 0x60, 0xb3, 0x33, 0x41, 0x50,
+	0x55, 0xb8, 0x3f, 0xb4, 0xd9, 0x19, 0xba, 0xbf, 0xce, 0x4d, 0xca, 0xcc, 0x47, 0x50, 0x9f, 0x79,
+	0xec, 0x6c, 0x39, 0xb1, 0x18, 0x7d, 0x43, 0x7c, 0x41, 0x4c, 0x15, 0xd7, 0x24, 0x36, 0xe2, 0x10,
+	0x6a, 0x41, 0x25, 0xf2, 0x5c, 0x32, 0xa7, 0xb6, 0x2b, 0xb8, 0xa8, 0xe3, 0x44, 0x46, 0x5f, 0x01,
+	0x5c, 0xd8, 0x1e, 0xb3, 0x96, 0x3e, 0xf3, 0xe6, 0xcd, 0x92, 0xb0, 0xb1, 0x75, 0x28, 0xc3, 0xe2,
+	0x30, 0x0e, 0x8b, 0xc3, 0x51, 0x1c, 0x16, 0xb8, 0xca, 0xb5, 0xc7, 0x5c, 0x19, 0x7d, 0x08, 0x35,
+	0xdf, 0x5e, 0x10, 0x2b, 0x5a, 0x4e, 0xa7, 0xde, 0x65, 0xb3, 0x2c, 0x36, 0x06, 0x0e, 0x99, 0x02,
+	0x41, 0x1f, 0x43, 0xc3, 0x39, 0xb3, 0xfd, 0x19, 0x71, 0xad, 0xa9, 0x37, 0x27, 0x51, 0xb3, 0xf2,
+	0x70, 0x7b, 0xbf, 0x8a, 0xeb, 0x0a, 0x7c, 0xce, 0x31, 0xfd, 0xcf, 0x79, 0xd8, 0x4d, 0x89, 0xff,
+	0xbf, 0xd1, 0x96, 0xe5, 0xa4, 0x70, 0x23, 0x27, 0xc5, 0xff, 0x81, 0x93, 0xd2, 0xed, 0x9c, 0x94,
+	0x37, 0x70, 0xf2, 0xb7, 0x1c, 0x3c, 0x10, 0x9c, 0x3c, 0x0f, 0xe9, 0x62, 0x18, 0x92, 0x73, 0x8f,
+	0x2e, 0xa3, 0x0c, 0x3f, 0x1f, 0x41, 0x3d, 0x50, 0xa8, 0xf5, 0x9a, 0x4e, 0x04, 0x47, 0x55, 0x5c,
+	0x0b, 0x52, 0xcd, 0x6b, 0x61, 0x91, 0xbf, 0x1e, 0x16, 0xab, 0x6e, 0x6e, 0xbf, 0x83, 0x9b, 0xfa,
+	0x21, 0xec, 0x61, 0xc2, 0xc2, 0xab, 0xa1, 0x17, 0x90, 0xb9, 0xe7, 0x93, 0xd8, 0xb0, 0x1f, 0x40,
+	0x29, 0xb0, 0x43, 0xe2, 0x33, 0x65, 0x92, 0x92, 0xf4, 0x33, 0xb8, 0xb7, 0xa6, 0xff, 0x4e, 0x37,
+	0x0c, 0xed, 0x43, 0x39, 0x24, 0x2c, 0xf4, 0x88, 0xdb, 0xcc, 0x3f, 0xdc, 0xde, 0xaf, 0x3d, 0xd9,
+	0xe1, 0x7a, 0x58, 0x42, 0x9c, 0x98, 0x78, 0x58, 0xef, 0x00, 0xa4, 0xf0, 0x77, 0x21, 0x0a, 0x41,
+	0x81, 0x1f, 0x8f, 0x22, 0x48, 0xfc, 0xd6, 0xff, 0x95, 0x83, 0xdd, 0x9e, 0x17, 0xf1, 0x90, 0x8c,
+	0x62, 0xd7, 0x7e, 0x08, 0xa5, 0xa9, 0x37, 0x67, 0x24, 0x6c, 0xe6, 0x84, 0x05, 0x7b, 0xdc, 0x82,
+	0xe7, 0x02, 0x31, 0x2e, 0x83, 0x90, 0x44, 0x91, 0x47, 0x7d, 0xac, 0x74, 0xd0, 0x67, 0x50, 0xa4,
+	0xa1, 0x4b, 0x42, 0x65, 0xee, 0x5d, 0xae, 0x3c, 0x08, 0xdd, 0x15, 0x5d, 0xa9, 0x81, 0xf6, 0xa0,
+	0x18, 0xf1, 0xb3, 0x16, 0x27, 0x50, 0xc4, 0x52, 0xe0, 0xe8, 0xdc, 0x5b, 0x78, 0x4c, 0x04, 0x67,
+	0x11, 0x4b, 0x01, 0xed, 0x83, 0x36, 0xb7, 0x19, 0x89, 0x98, 0x15, 0x90, 0xd0, 0x9a, 0x85, 0x74,
+	0x19, 0x34, 0x8b, 0x22, 0x80, 0x76, 0x24, 0x3e, 0x24, 0xe1, 0x31, 0x47, 0xf9, 0x49, 0x38, 0xcb,
+	0x30, 0xa2, 0xa1, 0x8a, 0x41, 0x25, 0xe9, 0x3f, 0x05, 0x6d, 0xdd, 0x68, 0xf4, 0x09, 0x14, 0x19,
+	0x09, 0x17, 0x51, 0x33, 0x97, 0x72, 0x2b, 0x95, 0x46, 0x24, 0x5c, 0x60, 0x39, 0xa8, 0x7f, 0x9b,
+	0x03, 0x48, 0x51, 0x6e, 0xe0, 0xd4, 0x23, 0x73, 0x57, 0x71, 0x2a, 0x05, 0x8e, 0x9e, 0xdb, 0xf3,
+	0x65, 0x4c, 0xa7, 0x14, 0xd0, 0x01, 0x54, 0x69, 0x40, 0x42, 0x9b, 0x79, 0xd4, 0x17, 0x6e, 0xee,
+	0x3c, 0xa9, 0xa7, 0x9b, 0x0c, 0x02, 0x9c, 0x0e, 0x73, 0xc3, 0x7d, 0x32, 0xb3, 0x19, 0x11, 0x9e,
+	0x57, 0xb0, 0x92, 0xf8, 0xcd, 0xf2, 0x66, 0x3e, 0x0d, 0x89, 0xe5, 0xd8, 0x91, 0xca, 0xe9, 0x18,
+	0x24, 0xd4, 0xb1, 0x23, 0xa2, 0x1b, 0xb0, 0xbb, 0xc6, 0xf0, 0x5b, 0x6c, 0x7c, 0x1f, 0xaa, 0x76,
+	0xe4, 0x10, 0xdf, 0xf5, 0xfc, 0x99, 0xb0, 0xb3, 0x82, 0x53, 0x40, 0x0f, 0x40, 0x4b, 0x8f, 0x5e,
+	0x45, 0xe9, 0x1e, 0x14, 0x19, 0x65, 0xf6, 0x5c, 0xac, 0x53, 0xc4, 0x52, 0xe0, 0xb1, 0x1b, 0x92,
+	0x68, 0x39, 0x67, 0xea, 0x90, 0xd7, 0x63, 0x57, 0x0e, 0x8a, 0x94, 0x40, 0x2e, 0x99, 0xa5, 0x8e,
+	0x63, 0x5b, 0xa5, 0x04, 0x72, 0xc9, 0x3a, 0xf2, 0x48, 0x7e, 0x01, 0x9a, 0xb9, 0x9c, 0x44, 0x4e,
+	0xe8, 0x4d, 0xc8, 0xf7, 0x8a, 0x36, 0xfd, 0x67, 0x70, 0x27, 0xb3, 0x42, 0x7a, 0xb5, 0x94, 0x79,
+	0x9b, 0xaf, 0x96, 0x1c, 0xd4, 0x3f, 0x86, 0xc6, 0x31, 0xc9, 0x26, 0xdf, 0xf8, 0x42, 0xe4, 0x32,
+	0x17, 0x02, 0xc3, 0x4e, 0xac, 0xf4, 0x4e, 0xab, 0xc7, 0x19, 0x38, 0x0a, 0x88, 0x93, 0x49, 0xce,
+	0x66, 0x40, 0x1c, 0xfd, 0x0f, 0x39, 0x68, 0x70, 0xa6, 0x89, 0x7f, 0xc3, 0xce, 0xa8, 0x09, 0xe5,
+	0x65, 0xe0, 0xf2, 0xd8, 0x56, 0x47, 0x15, 0x8b, 0xe8, 0x33, 0x28, 0xcc, 0xe9, 0x2c, 0x52, 0xf1,
+	0x74, 0x8f, 0xef, 0xbf, 0xb2, 0x5c, 0x8f, 0xce, 0x22, 0x2c, 0x54, 0xf8, 0x22, 0x11, 0x71, 0x44,
+	0xf4, 0xc9, 0xf2, 0x18, 0x8b, 0x3a, 0x85, 0x9d, 0x78, 0x92, 0x72, 0xec, 0x53, 0x28, 0xc9, 0x1d,
+	0x36, 0x3a, 0x76, 0xb2, 0x85, 0xd5, 0x30, 0xbf, 0xe2, 0xd1, 0xdc, 0x73, 0x64, 0xa8, 0xd7, 0x9e,
+	0xdc, 0x11, 0x06, 0xd0, 0x99, 0xc9, 0x31, 0xe3, 0x9c, 0xf8, 0xec, 0x64, 0x0b, 0x4b, 0x8d, 0x6c,
+	0x9b, 0xd1, 0x81, 0xbb, 0x5d, 0x7a, 0xe1, 0xf3, 0x3a, 0x23, 0x0c, 0xbc, 0xd9, 0xf5, 0xd8, 0xea,
+	0xfc, 0xaa, 0xd5, 0x07, 0xb0, 0xb7, 0xba, 0x88, 0xb2, 0x1d, 0x41, 0x21, 0xa9, 0x99, 0x75, 0x2c,
+	0x7e, 0xeb, 0xa7, 0xf0, 0x5e, 0xac, 0xdb, 0x0e, 0x99, 0x37, 0xb5, 0x1d, 0x76, 0xd3, 0xa6, 0x2d,
+	0xa8, 0xd8, 0x4a, 0x4d, 0xed, 0x9a, 0xc8, 0xfa, 0x21, 0x34, 0xaf, 0x2f, 0x75, 0xc3, 0xd6, 0x7f,
+	0xca, 0x43, 0x35, 0x61, 0x6e, 0xe3, 0x6e, 0xd9, 0x42, 0x9f, 0xbf, 0xad, 0xd0, 0xeb, 0x50, 0x0c,
+	0xce, 0xf8, 0xdd, 0xcf, 0x64, 0x90, 0x17, 0x74, 0x32, 0xe4, 0x18, 0x96, 0x43, 0xe8, 0x31, 0xf0,
+	0x96, 0xd2, 0xf5, 0x38, 0x4d, 0x51, 0xb3, 0x90, 0x9e, 0xcc, 0x0b, 0x3a, 0xe9, 0x24, 0x03, 0x38,
+	0xa3, 0xc4, 0x69, 0x76, 0x09, 0xb3, 0xbd, 0x79, 0x24, 0x92, 0x4a, 0x15, 0xc7, 0x22, 0xfa, 0x14,
+	0xca, 0x32, 0x8c, 0xa3, 0x66, 0x69, 0xe5, 0x86, 0x63, 0x81, 0xe2, 0x78, 0x14, 0x3d, 0x82, 0x1d,
+	0x32, 0x9d, 0xf2, 0xc3, 0x39, 0x27, 0x32, 0xd6, 0x65, 0x33, 0xd4, 0x48, 0x50, 0x11, 0xf1, 0xff,
+	0xc9, 0x43, 0x2d, 0xe3, 0x1a, 0x4f, 0x2b, 0xf4, 0xc2, 0x17, 0x77, 0x5c, 0xa4, 0x27, 0x21, 0xa0,
+	0x43, 0x80, 0x90, 0x04, 0x34, 0xf2, 0x18, 0x0d, 0xaf, 0x14, 0x2b, 0xaa, 0xdc, 0xc5, 0x28, 0xce,
+	0x68, 0xf0, 0xda, 0xc8, 0x42, 0x6f, 0x36, 0x23, 0xa1, 0x22, 0x66, 0x47, 0x59, 0x39, 0x92, 0x28,
+	0x8e, 0x87, 0xd1, 0x53, 0x28, 0x3b, 0x21, 0xb1, 0x19, 0x71, 0x9b, 0x85, 0x5b, 0xab, 0x7d, 0xac,
+	0x8a, 0x7e, 0x0c, 0x95, 0xa9, 0xe7, 0x7b, 0xd1, 0x19, 0x71, 0xbf, 0x43, 0x2f, 0x94, 0xe8, 0xa2,
+	0x2f, 0xa0, 0x66, 0xfb, 0x3e, 0x65, 0xb6, 0x3c, 0x8b, 0x52, 0x5a, 0x5b, 0xda, 0x09, 0x8c, 0xb3,
+	0x2a, 0x48, 0x87, 0x46, 0x9c, 0x2c, 0x2c, 0x11, 0x2a, 0x92, 0xc5, 0x9a, 0xca, 0x18, 0x7d, 0x1e,
+	0x31, 0x4f, 0xa1, 0x2c, 0x0a, 0x24, 0x71, 0x9b, 0x95, 0xdb, 0x7d, 0x50, 0xaa, 0xfa, 0x25, 0xef,
+	0x0a, 0x12, 0xc6, 0x10, 0x14, 0xce, 0x68, 0x14, 0xf7, 0x28, 0xe2, 0x77, 0x7a, 0x16, 0xf9, 0xec,
+	0x59, 0x20, 0x28, 0x70, 0xa6, 0x55, 0xd2, 0x16, 0xbf, 0x91, 0x06, 0xdb, 0x21, 0x99, 0xaa, 0x44,
+	0xc2, 0x7f, 0xf2, 0x3b, 0xc3, 0x1b, 0x0a, 0x9e, 0x92, 0x55, 0x08, 0x25, 0xb2, 0xfe, 0x14, 0x20,
+	0x75, 0x97, 0xcf, 0x7d, 0x43, 0xae, 0xd4, 0xc6, 0xfc, 0xe7, 0xe6, 0x82, 0xa9, 0xff, 0x35, 0x0f,
+	0x8d, 0x95, 0x88, 0x15, 0xc9, 0x60, 0xe9, 0x38, 0x24, 0x92, 0x9d, 0x52, 0x05, 0xc7, 0x22, 0xef,
+	0x28, 0xa7, 0xb6, 0x37, 0x5f, 0xf2, 0xca, 0x48, 0x97, 0xbe, 0xbc, 0xb6, 0x45, 0x5c, 0x57, 0x60,
+	0x87, 0x63, 0xe8, 0x03, 0x00, 0xc7, 0xf6, 0xad, 0x90, 0x04, 0x73, 0xfb, 0x4a, 0xb8, 0x53, 0xc1,
+	0x55, 0xc7, 0xf6, 0xb1, 0x00, 0xd6, 0x5a, 0xc1, 0xc2, 0x3b, 0x76, 0xbc, 0xae, 0xe7, 0x5a, 0xe4,
+	0x92, 0x38, 0x4b, 0x96, 0xd4, 0x65, 0xd7, 0x73, 0x0d, 0x89, 0xa0, 0x07, 0x50, 0xe5, 0x5f, 0x95,
+	0xae, 0x45, 0x97, 0x4c, 0x34, 0x23, 0x15, 0x5c, 0x11, 0xc0, 0x60, 0xc9, 0x84, 0x5b, 0x6f, 0xbc,
+	0x20, 0x20, 0x6e, 0xb3, 0xac, 0xdc, 0x92, 0x22, 0x27, 0x35, 0x08, 0x3d, 0x1a, 0x7a, 0xec, 0x4a,
+	0x9c, 0x74, 0x11, 0x27, 0xb2, 0x7e, 0x01, 0xd5, 0xe4, 0x16, 0xf2, 0x33, 0x62, 0x57, 0x41, 0x92,
+	0x57, 0xf8, 0x6f, 0xbe, 0x6c, 0x60, 0x5f, 0x89, 0xe6, 0x5e, 0xa5, 0x4e, 0x25, 0xa2, 0x87, 0x50,
+	0x73, 0x09, 0xaf, 0x94, 0x41, 0xd2, 0x8c, 0x54, 0x71, 0x16, 0xe2, 0x1b, 0xf3, 0x66, 0xdc, 0x27,
+	0x73, 0x9e, 0x40, 0x78, 0x6f, 0x95, 0xc8, 0xfa, 0xef, 0xa1, 0xb1, 0x92, 0xe2, 0x37, 0x26, 0xb5,
+	0x4f, 0x94, 0x41, 0x79, 0x71, 0x1b, 0xb5, 0x6c, 0x5d, 0x18, 0x5d, 0x05, 0xe4, 0xba, 0x89, 0xdb,
+	0xab, 0x26, 0xa6, 0x4d, 0x74, 0x61, 0xa5, 0x89, 0xfe, 0x1a, 0x76, 0x4c, 0x46, 0x83, 0x9b, 0x4b,
+	0x35, 0x9f, 0x1d, 0x12, 0x3b, 0x4a, 0x8a, 0x86, 0x92, 0xf4, 0x3b, 0xb0, 0x9b, 0xcc, 0x96, 0x39,
+	0x5b, 0x0f, 0x12, 0xe8, 0x7b, 0x76, 0xb9, 0x6f, 0xd9, 0x0b, 0xbd, 0x07, 0x65, 0x37, 0xbc, 0xb2,
+	0xc2, 0xa5, 0xaf, 0x42, 0xad, 0xe4, 0x86, 0x57, 0x78, 0xe9, 0xeb, 0x11, 0x68, 0xe9, 0x8e, 0xaa,
+	0x72, 0xf0, 0x10, 0x60, 0x54, 0x84, 0x40, 0x4e, 0xd0, 0x1d, 0x8b, 0xd9, 0xe0, 0xc8, 0xab, 0x11,
+	0x29, 0xa2, 0xcf, 0xa1, 0xc4, 0xc3, 0x9b, 0x70, 0xee, 0x92, 0xfe, 0x3a, 0x5e, 0xf9, 0xb9, 0x0c,
+	0x7c, 0xac, 0x54, 0xf4, 0x9f, 0xc3, 0xee, 0xda, 0xd0, 0x46, 0xe2, 0xf6, 0xa0, 0x48, 0xc2, 0x90,
+	0x26, 0x19, 0x40, 0x08, 0xfa, 0x25, 0x34, 0x4d, 0xd1, 0xf9, 0xa4, 0xb7, 0xf8, 0xc6, 0xa2, 0xbd,
+	0x96, 0xf5, 0xf2, 0xb7, 0x67, 0x3d, 0x41, 0xe2, 0x82, 0x9e, 0x13, 0xe1, 0x4b, 0x15, 0x2b, 0x49,
+	0x7f, 0x06, 0xf7, 0x37, 0xec, 0xfc, 0x4e, 0xdf, 0x4d, 0x07, 0x7f, 0xcc, 0x41, 0x25, 0x6e, 0xb2,
+	0x51, 0x03, 0xaa, 0x83, 0xa1, 0x65, 0x7c, 0x33, 0x6e, 0xf7, 0x4c, 0x6d, 0x0b, 0x21, 0xd8, 0x19,
+	0x0c, 0x2d, 0x73, 0xd4, 0xc6, 0x23, 0xd3, 0x7a, 0x75, 0x3a, 0x3a, 0xd1, 0x72, 0x48, 0x83, 0x3a,
+	0x57, 0xe9, 0x77, 0x15, 0x92, 0x47, 0xbb, 0x50, 0x1b, 0x0c, 0xad, 0xce, 0xa0, 0x3f, 0x6a, 0x9f,
+	0xf6, 0x4d, 0x6d, 0x3b, 0x5e, 0xe5, 0xd7, 0xa7, 0xe6, 0xc8, 0xd4, 0x0a, 0x68, 0x07, 0x60, 0x30,
+	0xb4, 0x5e, 0xb6, 0x47, 0x9d, 0x13, 0xc3, 0xd4, 0x8a, 0x4a, 0x3e, 0xc6, 0x46, 0x7b, 0x64, 0x60,
+	0xad, 0x84, 0x6a, 0x50, 0x1e, 0x0c, 0xad, 0x9e, 0x61, 0x9a, 0x5a, 0xf9, 0xe0, 0x57, 0x70, 0xe7,
+	0x5a, 0x8b, 0x86, 0xee, 0x40, 0xa3, 0x37, 0x38, 0x36, 0xad, 0xee, 0xa9, 0xd9, 0x7e, 0xd6, 0x33,
+	0xba, 0xda, 0x56, 0x02, 0x8d, 0xfb, 0x66, 0xef, 0xb4, 0x63, 0x74, 0xb5, 0x1c, 0xaa, 0x43, 0x45,
+	0x40, 0xb8, 0xfd, 0x4a, 0xcb, 0x73, 0x23, 0x84, 0x74, 0x32, 0x7a, 0xd9, 0xd3, 0xb6, 0x0f, 0xfe,
+	0x92, 0x03, 0x48, 0x0b, 0x1e, 0xba, 0x0b, 0xbb, 0x23, 0x7c, 0x7a, 0x7c, 0x6c, 0x60, 0x6b, 0xdc,
+	0xff, 0x65, 0x7f, 0xf0, 0xaa, 0x2f, 0xdd, 0x8d, 0xc1, 0x97, 0xed, 0xfe, 0xb8, 0xdd, 0x93, 0xee,
+	0xc6, 0xd8, 0x70, 0x6c, 0x72, 0x77, 0x33, 0x53, 0xbb, 0x46, 0xcf, 0x18, 0x19, 0x5d, 0x6d, 0x1b,
+	0xed, 0x81, 0x16, 0x83, 0x66, 0xe7, 0xc4, 0xe8, 0x8e, 0x7b, 0x86, 0x56, 0xe0, 0xcc, 0xc4, 0xe8,
+	0xa8, 0x7d, 0xac, 0x15, 0xb3, 0x73, 0xb1, 0xd1, 0x33, 0xda, 0xa6, 0xa1, 0x95, 0x0e, 0xfe, 0x9e,
+	0x83, 0x4a, 0xdc, 0xa4, 0x70, 0xbf, 0x86, 0x27, 0x6d, 0xd3, 0xc8, 0x98, 0x75, 0x17, 0x76, 0x25,
+	0x34, 0xc4, 0xc6, 0xb0, 0x8d, 0x4f, 0xfb, 0xc7, 0x5a, 0x8e, 0xdb, 0x2a, 0x41, 0x71, 0x3a, 0x1c,
+	0xcb, 0xa7, 0x73, 0xf1, 0xb8, 0xdf, 0xe7, 0xd0, 0x36, 0xe7, 0x5a, 0x42, 0xdd, 0x41, 0x9f, 0x5b,
+	0x94, 0xa8, 0x74, 0x7a, 0x46, 0xbb, 0x3f, 0x1e, 0x6a, 0xc5, 0x14, 0x7a, 0xd5, 0x3e, 0x15, 0x0b,
+	0x95, 0xb8, 0xd3, 0x12, 0xfa, 0x66, 0x6c, 0x8c, 0x8d, 0xae, 0x56, 0x3e, 0xf8, 0x36, 0x07, 0xf5,
+	0x6c, 0x86, 0xe2, 0x46, 0x09, 0xe2, 0xad, 0xf6, 0xb3, 0x76, 0x9f, 0x2f, 0xce, 0x0f, 0x65, 0x17,
+	0x6a, 0x12, 0x14, 0xb3, 0xb5, 0x5c, 0x0a, 0x08, 0x2b, 0xa5, 0x89, 0x12, 0xe0, 0xe1, 0x62, 0xf4,
+	0x47, 0xd2, 0x44, 0x09, 0x29, 0x13, 0x13, 0xf9, 0x79, 0xfb, 0xb4, 0xa7, 0x15, 0xb9, 0x31, 0x52,
+	0xc6, 0x86, 0x39, 0xee, 0x8d, 0xb4, 0x52, 0xba, 0x48, 0x17, 0x0f, 0x86, 0x43, 0x6e, 0xdf, 0x93,
+	0x7f, 0x94, 0xa1, 0xfe, 0x8a, 0xbf, 0x5c, 0x9a, 0x24, 0x3c, 0xf7, 0x1c, 0x82, 0x3a, 0xd0, 0x58,
+	0x79, 0x94, 0x44, 0x4d, 0x79, 0xff, 0xaf, 0xbf, 0x53, 0xb6, 0xf6, 0x92, 0x91, 0x6c, 0xe6, 0xdb,
+	0xda, 0xcf, 0xa1, 0x0e, 0xec, 0xac, 0x3e, 0xda, 0xa1, 0xfb, 0x89, 0xee, 0xfa, 0x43, 0xde, 0xdb,
+	0x96, 0x41, 0x03, 0xd8, 0xdb, 0xf4, 0x50, 0x83, 0x3e, 0x4c, 0xf4, 0x37, 0x3f, 0xe1, 0xbc, 0x75,
+	0xc1, 0x9f, 0x40, 0x25, 0x46, 0xd1, 0xdd, 0x55, 0x9d, 0x5b, 0x27, 0xc6, 0xdf, 0xad, 0x72, 0xe2,
+	0xda, 0x03, 0x46, 0x6b, 0x6f, 0x15, 0x4c, 0x26, 0x7e, 0x0d, 0xd5, 0xe4, 0xe3, 0x11, 0xc9, 0xd5,
+	0xd7, 0xbe, 0x46, 0x5b, 0xf7, 0xd6, 0xd0, 0x78, 0xee, 0x17, 0x39, 0xf4, 0x18, 0x4a, 0xf2, 0xcb,
+	0x10, 0x89, 0x36, 0x7b, 0xe5, 0x53, 0xb2, 0x85, 0xb2, 0x50, 0xb2, 0xe1, 0x97, 0x50, 0x92, 0x59,
+	0x40, 0x4e, 0x59, 0xc9, 0x08, 0x2d, 0x94, 0x85, 0x32, 0xfb, 0x18, 0x50, 0xcf, 0x7e, 0xf2, 0xa0,
+	0xf7, 0xb8, 0xde, 0x86, 0x2f, 0xa9, 0x56, 0xf3, 0xfa, 0x40, 0x66, 0x99, 0x6f, 0x40, 0x5b, 0xff,
+	0x84, 0x41, 0x0f, 0xb2, 0x33, 0xd6, 0xbe, 0x91, 0x5a, 0xef, 0x6f, 0x1e, 0xcc, 0x2c, 0xf9, 0x1c,
+	0x1a, 0x2b, 0x6f, 0x5b, 0x32, 0x18, 0x37, 0x3d, 0x8f, 0xb5, 0xee, 0x6f, 0x18, 0x49, 0x68, 0x79,
+	0x0a, 0x65, 0x55, 0xa6, 0x10, 0xca, 0x94, 0xb3, 0x78, 0xee, 0xdd, 0x15, 0x6c, 0x35, 0x5e, 0x68,
+	0x90, 0x1e, 0xfb, 0x5a, 0x45, 0x6f, 0xed, 0xad, 0x82, 0xc9, 0x44, 0x0c, 0x77, 0xae, 0x95, 0x17,
+	0x24, 0xbc, 0x7d, 0x5b, 0xbd, 0x6b, 0x7d, 0xf0, 0x96, 0xd1, 0x78, 0xcd, 0x67, 0x9f, 0xfe, 0xf6,
+	0x91, 0x7c, 0x60, 0x3c, 0x74, 0xe8, 0xe2, 0xc8, 0x89, 0x2e, 0x88, 0xe7, 0x9c, 0x91, 0xf9, 0x91,
+	0xf8, 0xdb, 0xe1, 0x28, 0x78, 0x33, 0x3b, 0xb2, 0x03, 0xef, 0xe8, 0xfc, 0xf1, 0xa4, 0x24, 0x7a,
+	0xca, 0x2f, 0xff, 0x3b, 0x00, 0x27, 0x74, 0x48, 0x40, 0x91, 0x18, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -1873,8 +2664,18 @@ type WerftServiceClient interface {
 	GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*GetJobResponse, error)
 	// Listen listens to job updates and log output of a running job
 	Listen(ctx context.Context, in *ListenRequest, opts ...grpc.CallOption) (WerftService_ListenClient, error)
+	// DownloadLogs retrieves the complete log of a job in chunks. If the job is still running, the log is sent until the job is done.
+	DownloadLogs(ctx context.Context, in *DownloadLogsRequest, opts ...grpc.CallOption) (WerftService_DownloadLogsClient, error)
+	// DownloadArtifact retrieves an artifact a job produced in chunks. The artifact is a gzipped tarball.
+	DownloadArtifact(ctx context.Context, in *DownloadArtifactRequest, opts ...grpc.CallOption) (WerftService_DownloadArtifactClient, error)
+	// RetryPipeline restarts the failed jobs of a matrix job, which reflects the status of the new jobs from then on
+	RetryPipeline(ctx context.Context, in *RetryPipelineRequest, opts ...grpc.CallOption) (*RetryPipelineResponse, error)
 	// StopJob stops a currently running job
 	StopJob(ctx context.Context, in *StopJobRequest, opts ...grpc.CallOption) (*StopJobResponse, error)
+	// StopJobs stops all running jobs which match a filter
+	StopJobs(ctx context.Context, in *StopJobsRequest, opts ...grpc.CallOption) (*StopJobsResponse, error)
+	// SetJobAnnotations adds, changes or removes annotations of an existing job
+	SetJobAnnotations(ctx context.Context, in *SetJobAnnotationsRequest, opts ...grpc.CallOption) (*SetJobAnnotationsResponse, error)
 }
 
 type werftServiceClient struct {
@@ -2028,6 +2829,79 @@ func (x *werftServiceListenClient) Recv() (*ListenResponse, error) {
 	return m, nil
 }
 
+func (c *werftServiceClient) DownloadLogs(ctx context.Context, in *DownloadLogsRequest, opts ...grpc.CallOption) (WerftService_DownloadLogsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_WerftService_serviceDesc.Streams[3], "/v1.WerftService/DownloadLogs", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &werftServiceDownloadLogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type WerftService_DownloadLogsClient interface {
+	Recv() (*DownloadLogsResponse, error)
+	grpc.ClientStream
+}
+
+type werftServiceDownloadLogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *werftServiceDownloadLogsClient) Recv() (*DownloadLogsResponse, error) {
+	m := new(DownloadLogsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *werftServiceClient) DownloadArtifact(ctx context.Context, in *DownloadArtifactRequest, opts ...grpc.CallOption) (WerftService_DownloadArtifactClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_WerftService_serviceDesc.Streams[4], "/v1.WerftService/DownloadArtifact", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &werftServiceDownloadArtifactClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type WerftService_DownloadArtifactClient interface {
+	Recv() (*DownloadArtifactResponse, error)
+	grpc.ClientStream
+}
+
+type werftServiceDownloadArtifactClient struct {
+	grpc.ClientStream
+}
+
+func (x *werftServiceDownloadArtifactClient) Recv() (*DownloadArtifactResponse, error) {
+	m := new(DownloadArtifactResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *werftServiceClient) RetryPipeline(ctx context.Context, in *RetryPipelineRequest, opts ...grpc.CallOption) (*RetryPipelineResponse, error) {
+	out := new(RetryPipelineResponse)
+	err := c.cc.Invoke(ctx, "/v1.WerftService/RetryPipeline", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *werftServiceClient) StopJob(ctx context.Context, in *StopJobRequest, opts ...grpc.CallOption) (*StopJobResponse, error) {
 	out := new(StopJobResponse)
 	err := c.cc.Invoke(ctx, "/v1.WerftService/StopJob", in, out, opts...)
@@ -2037,6 +2911,24 @@ func (c *werftServiceClient) StopJob(ctx context.Context, in *StopJobRequest, op
 	return out, nil
 }
 
+func (c *werftServiceClient) StopJobs(ctx context.Context, in *StopJobsRequest, opts ...grpc.CallOption) (*StopJobsResponse, error) {
+	out := new(StopJobsResponse)
+	err := c.cc.Invoke(ctx, "/v1.WerftService/StopJobs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *werftServiceClient) SetJobAnnotations(ctx context.Context, in *SetJobAnnotationsRequest, opts ...grpc.CallOption) (*SetJobAnnotationsResponse, error) {
+	out := new(SetJobAnnotationsResponse)
+	err := c.cc.Invoke(ctx, "/v1.WerftService/SetJobAnnotations", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // WerftServiceServer is the server API for WerftService service.
 type WerftServiceServer interface {
 	// StartLocalJob starts a job by uploading the workspace content directly. The incoming requests are expected in the following order:
@@ -2061,8 +2953,18 @@ type WerftServiceServer interface {
 	GetJob(context.Context, *GetJobRequest) (*GetJobResponse, error)
 	// Listen listens to job updates and log output of a running job
 	Listen(*ListenRequest, WerftService_ListenServer) error
+	// DownloadLogs retrieves the complete log of a job in chunks. If the job is still running, the log is sent until the job is done.
+	DownloadLogs(*DownloadLogsRequest, WerftService_DownloadLogsServer) error
+	// DownloadArtifact retrieves an artifact a job produced in chunks. The artifact is a gzipped tarball.
+	DownloadArtifact(*DownloadArtifactRequest, WerftService_DownloadArtifactServer) error
+	// RetryPipeline restarts the failed jobs of a matrix job, which reflects the status of the new jobs from then on
+	RetryPipeline(context.Context, *RetryPipelineRequest) (*RetryPipelineResponse, error)
 	// StopJob stops a currently running job
 	StopJob(context.Context, *StopJobRequest) (*StopJobResponse, error)
+	// StopJobs stops all running jobs which match a filter
+	StopJobs(context.Context, *StopJobsRequest) (*StopJobsResponse, error)
+	// SetJobAnnotations adds, changes or removes annotations of an existing job
+	SetJobAnnotations(context.Context, *SetJobAnnotationsRequest) (*SetJobAnnotationsResponse, error)
 }
 
 // UnimplementedWerftServiceServer can be embedded to have forward compatible implementations.
@@ -2093,9 +2995,24 @@ func (*UnimplementedWerftServiceServer) GetJob(ctx context.Context, req *GetJobR
 func (*UnimplementedWerftServiceServer) Listen(req *ListenRequest, srv WerftService_ListenServer) error {
 	return status.Errorf(codes.Unimplemented, "method Listen not implemented")
 }
+func (*UnimplementedWerftServiceServer) DownloadLogs(req *DownloadLogsRequest, srv WerftService_DownloadLogsServer) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadLogs not implemented")
+}
+func (*UnimplementedWerftServiceServer) DownloadArtifact(req *DownloadArtifactRequest, srv WerftService_DownloadArtifactServer) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadArtifact not implemented")
+}
+func (*UnimplementedWerftServiceServer) RetryPipeline(ctx context.Context, req *RetryPipelineRequest) (*RetryPipelineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RetryPipeline not implemented")
+}
 func (*UnimplementedWerftServiceServer) StopJob(ctx context.Context, req *StopJobRequest) (*StopJobResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method StopJob not implemented")
 }
+func (*UnimplementedWerftServiceServer) StopJobs(ctx context.Context, req *StopJobsRequest) (*StopJobsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopJobs not implemented")
+}
+func (*UnimplementedWerftServiceServer) SetJobAnnotations(ctx context.Context, req *SetJobAnnotationsRequest) (*SetJobAnnotationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetJobAnnotations not implemented")
+}
 
 func RegisterWerftServiceServer(s *grpc.Server, srv WerftServiceServer) {
 	s.RegisterService(&_WerftService_serviceDesc, srv)
@@ -2259,6 +3176,66 @@ func (x *werftServiceListenServer) Send(m *ListenResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _WerftService_DownloadLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadLogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WerftServiceServer).DownloadLogs(m, &werftServiceDownloadLogsServer{stream})
+}
+
+type WerftService_DownloadLogsServer interface {
+	Send(*DownloadLogsResponse) error
+	grpc.ServerStream
+}
+
+type werftServiceDownloadLogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *werftServiceDownloadLogsServer) Send(m *DownloadLogsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _WerftService_DownloadArtifact_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadArtifactRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WerftServiceServer).DownloadArtifact(m, &werftServiceDownloadArtifactServer{stream})
+}
+
+type WerftService_DownloadArtifactServer interface {
+	Send(*DownloadArtifactResponse) error
+	grpc.ServerStream
+}
+
+type werftServiceDownloadArtifactServer struct {
+	grpc.ServerStream
+}
+
+func (x *werftServiceDownloadArtifactServer) Send(m *DownloadArtifactResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _WerftService_RetryPipeline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RetryPipelineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WerftServiceServer).RetryPipeline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1.WerftService/RetryPipeline",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WerftServiceServer).RetryPipeline(ctx, req.(*RetryPipelineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _WerftService_StopJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(StopJobRequest)
 	if err := dec(in); err != nil {
@@ -2277,6 +3254,42 @@ func _WerftService_StopJob_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _WerftService_StopJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopJobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WerftServiceServer).StopJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1.WerftService/StopJobs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WerftServiceServer).StopJobs(ctx, req.(*StopJobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WerftService_SetJobAnnotations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetJobAnnotationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WerftServiceServer).SetJobAnnotations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1.WerftService/SetJobAnnotations",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WerftServiceServer).SetJobAnnotations(ctx, req.(*SetJobAnnotationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _WerftService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "v1.WerftService",
 	HandlerType: (*WerftServiceServer)(nil),
@@ -2301,10 +3314,22 @@ var _WerftService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetJob",
 			Handler:    _WerftService_GetJob_Handler,
 		},
+		{
+			MethodName: "RetryPipeline",
+			Handler:    _WerftService_RetryPipeline_Handler,
+		},
 		{
 			MethodName: "StopJob",
 			Handler:    _WerftService_StopJob_Handler,
 		},
+		{
+			MethodName: "StopJobs",
+			Handler:    _WerftService_StopJobs_Handler,
+		},
+		{
+			MethodName: "SetJobAnnotations",
+			Handler:    _WerftService_SetJobAnnotations_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -2322,6 +3347,16 @@ var _WerftService_serviceDesc = grpc.ServiceDesc{
 			Handler:       _WerftService_Listen_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "DownloadLogs",
+			Handler:       _WerftService_DownloadLogs_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "DownloadArtifact",
+			Handler:       _WerftService_DownloadArtifact_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "werft.proto",
 }
@@ -0,0 +1,100 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Action is a mutating operation
+type Action string
+
+const (
+	// ActionStart starts a new job
+	ActionStart Action = "start"
+	// ActionRestart starts a new job from a previous one
+	ActionRestart Action = "restart"
+	// ActionStop stops a running job
+	ActionStop Action = "stop"
+	// ActionAnnotate changes the annotations of a job
+	ActionAnnotate Action = "annotate"
+)
+
+// Entry records who did what and when
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Actor is who performed the action, e.g. the authenticated principal or plugin:<name> for plugins
+	Actor  string `json:"actor"`
+	Action Action `json:"action"`
+	// Job is the job which was started or stopped. It's empty if starting a job failed early on.
+	Job string `json:"job,omitempty"`
+	// PreviousJob is the job a restart started from
+	PreviousJob string `json:"previousJob,omitempty"`
+	// Repo is the repository of the job as host/owner/repo
+	Repo string `json:"repo,omitempty"`
+	// Owner is who the job belongs to, e.g. the GitHub user who pushed
+	Owner   string `json:"owner,omitempty"`
+	Trigger string `json:"trigger,omitempty"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Logger records audit entries
+type Logger interface {
+	Log(ctx context.Context, entry Entry) error
+}
+
+// JSONLogger writes each entry as a single line of JSON
+type JSONLogger struct {
+	Out io.Writer
+
+	mu sync.Mutex
+}
+
+// Log writes the entry
+func (l *JSONLogger) Log(ctx context.Context, entry Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return json.NewEncoder(l.Out).Encode(entry)
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx which carries the actor of a call
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor set using WithActor
+func ActorFromContext(ctx context.Context) (actor string, ok bool) {
+	actor, ok = ctx.Value(actorKey{}).(string)
+	return
+}
+
+// UnaryServerInterceptor sets the actor of all unary calls, e.g. to the plugin using a socket
+func UnaryServerInterceptor(actor string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(WithActor(ctx, actor), req)
+	}
+}
+
+// StreamServerInterceptor sets the actor of all streaming calls
+func StreamServerInterceptor(actor string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &actorServerStream{ServerStream: ss, ctx: WithActor(ss.Context(), actor)})
+	}
+}
+
+type actorServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *actorServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,33 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/csweichel/werft/pkg/audit"
+)
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := &audit.JSONLogger{Out: &buf}
+
+	entries := []audit.Entry{
+		{Time: time.Date(2021, 10, 20, 12, 0, 0, 0, time.UTC), Actor: "alice", Action: audit.ActionStart, Job: "werft-build-main.1", Repo: "github.com/csweichel/werft", Success: true},
+		{Time: time.Date(2021, 10, 20, 12, 1, 0, 0, time.UTC), Actor: "plugin:github", Action: audit.ActionStop, Job: "werft-build-main.1", Error: "job is in unstoppable phase"},
+	}
+	for _, e := range entries {
+		err := logger.Log(context.Background(), e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := `{"time":"2021-10-20T12:00:00Z","actor":"alice","action":"start","job":"werft-build-main.1","repo":"github.com/csweichel/werft","success":true}
+{"time":"2021-10-20T12:01:00Z","actor":"plugin:github","action":"stop","job":"werft-build-main.1","success":false,"error":"job is in unstoppable phase"}
+`
+	if act := buf.String(); act != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", act, expected)
+	}
+}
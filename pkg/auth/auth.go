@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Config configures the authentication of werft's gRPC API
+type Config struct {
+	// Tokens are static bearer tokens which grant access to the API, keyed by the principal they authenticate
+	Tokens map[string]string `yaml:"tokens,omitempty"`
+	// JWKS accepts JWTs as bearer tokens if they're signed by one of the keys served by an identity provider
+	JWKS *JWKSConfig `yaml:"jwks,omitempty"`
+	// PublicReads allows unauthenticated calls to RPCs which don't change anything, e.g. listing jobs or reading their logs
+	PublicReads bool `yaml:"publicReads,omitempty"`
+	// Rules restrict what principals may do with the jobs of a repository. Without rules all principals may do everything.
+	Rules []Rule `yaml:"rules,omitempty"`
+}
+
+// readMethods are the RPCs which don't change anything. All other RPCs, e.g. starting or stopping
+// a job, always require a valid token - even those we add in the future.
+var readMethods = map[string]struct{}{
+	"/v1.WerftService/ListJobs":         {},
+	"/v1.WerftService/Subscribe":        {},
+	"/v1.WerftService/GetJob":           {},
+	"/v1.WerftService/Listen":           {},
+	"/v1.WerftService/DownloadLogs":     {},
+	"/v1.WerftService/DownloadArtifact": {},
+	"/v1.WerftUI/ListJobSpecs":          {},
+	"/v1.WerftUI/IsReadOnly":            {},
+	// reflection describes the API, which is no secret, but not worth exposing to anonymous callers either
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo": {},
+}
+
+// publicMethods never require a token, e.g. so that load balancers can probe the health of werft
+var publicMethods = map[string]struct{}{
+	"/grpc.health.v1.Health/Check": {},
+	"/grpc.health.v1.Health/Watch": {},
+}
+
+// Verifier checks bearer tokens
+type Verifier interface {
+	// Verify returns the principal the token authenticates, or an error if the token does not grant access to the API
+	Verify(ctx context.Context, token string) (principal string, err error)
+}
+
+// StaticTokens accepts a fixed set of tokens, keyed by the principal they authenticate
+type StaticTokens map[string]string
+
+// Verify returns an error if the token is not one of the static tokens
+func (s StaticTokens) Verify(ctx context.Context, token string) (principal string, err error) {
+	for p, t := range s {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return p, nil
+		}
+	}
+	return "", xerrors.Errorf("unknown token")
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx which carries the principal a call was authenticated as.
+// Anonymous calls, i.e. public reads without a valid token, have an empty principal.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal a call was authenticated as. If ok is false,
+// the call did not pass the interceptor, e.g. because it came from a plugin.
+func PrincipalFromContext(ctx context.Context) (principal string, ok bool) {
+	principal, ok = ctx.Value(principalKey{}).(string)
+	return
+}
+
+// Interceptor rejects calls which don't carry a valid bearer token
+type Interceptor struct {
+	Verifiers   []Verifier
+	PublicReads bool
+}
+
+// NewInterceptor creates an interceptor from the config
+func NewInterceptor(cfg Config) (*Interceptor, error) {
+	res := &Interceptor{PublicReads: cfg.PublicReads}
+	if len(cfg.Tokens) > 0 {
+		for p, t := range cfg.Tokens {
+			if p == "" || t == "" {
+				return nil, xerrors.Errorf("tokens and their principals must not be empty")
+			}
+		}
+		res.Verifiers = append(res.Verifiers, StaticTokens(cfg.Tokens))
+	}
+	if cfg.JWKS != nil {
+		v, err := NewJWKSVerifier(*cfg.JWKS)
+		if err != nil {
+			return nil, err
+		}
+		res.Verifiers = append(res.Verifiers, v)
+	}
+	if len(res.Verifiers) == 0 {
+		return nil, xerrors.Errorf("auth requires tokens or a JWKS endpoint")
+	}
+	return res, nil
+}
+
+// UnaryServerInterceptor authenticates unary calls
+func (a *Interceptor) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamServerInterceptor authenticates streaming calls
+func (a *Interceptor) StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &principalServerStream{ServerStream: ss, ctx: ctx})
+}
+
+// principalServerStream carries the principal in its context
+type principalServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *principalServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate returns a context carrying the principal of the call
+func (a *Interceptor) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if _, ok := publicMethods[method]; ok {
+		return WithPrincipal(ctx, ""), nil
+	}
+
+	_, read := readMethods[method]
+	public := read && a.PublicReads
+
+	token := bearerToken(ctx)
+	if token == "" {
+		if public {
+			return WithPrincipal(ctx, ""), nil
+		}
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	for _, v := range a.Verifiers {
+		principal, err := v.Verify(ctx, token)
+		if err == nil {
+			return WithPrincipal(ctx, principal), nil
+		}
+		log.WithError(err).WithField("method", method).Debug("rejected bearer token")
+	}
+	if public {
+		return WithPrincipal(ctx, ""), nil
+	}
+	return nil, status.Error(codes.Unauthenticated, "invalid bearer token")
+}
+
+// bearerToken returns the token from the authorization metadata or an empty string if there is none
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, v := range md.Get("authorization") {
+		if len(v) > len("bearer ") && strings.EqualFold(v[:len("bearer ")], "bearer ") {
+			return strings.TrimSpace(v[len("bearer "):])
+		}
+	}
+	return ""
+}
+
+// UnaryClientInterceptor adds the token to unary calls
+func UnaryClientInterceptor(token string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(withToken(ctx, token), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor adds the token to streaming calls
+func StreamClientInterceptor(token string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withToken(ctx, token), desc, cc, method, opts...)
+	}
+}
+
+func withToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
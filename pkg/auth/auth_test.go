@@ -0,0 +1,276 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+type fakeWerftServer struct {
+	v1.UnimplementedWerftServiceServer
+}
+
+// GetJob returns the principal of the call as job spec
+func (fakeWerftServer) GetJob(ctx context.Context, req *v1.GetJobRequest) (*v1.GetJobResponse, error) {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		principal = "<none>"
+	}
+	return &v1.GetJobResponse{JobSpec: principal}, nil
+}
+
+func (fakeWerftServer) StopJob(context.Context, *v1.StopJobRequest) (*v1.StopJobResponse, error) {
+	return &v1.StopJobResponse{}, nil
+}
+
+func (fakeWerftServer) Listen(req *v1.ListenRequest, srv v1.WerftService_ListenServer) error {
+	return nil
+}
+
+func (fakeWerftServer) StartLocalJob(srv v1.WerftService_StartLocalJobServer) error {
+	return srv.SendAndClose(&v1.StartJobResponse{})
+}
+
+// call invokes a unary or streaming, read or mutating RPC
+type call func(ctx context.Context, client v1.WerftServiceClient) error
+
+var (
+	unaryRead call = func(ctx context.Context, client v1.WerftServiceClient) error {
+		_, err := client.GetJob(ctx, &v1.GetJobRequest{Name: "foo"})
+		return err
+	}
+	unaryWrite call = func(ctx context.Context, client v1.WerftServiceClient) error {
+		_, err := client.StopJob(ctx, &v1.StopJobRequest{Name: "foo"})
+		return err
+	}
+	streamRead call = func(ctx context.Context, client v1.WerftServiceClient) error {
+		s, err := client.Listen(ctx, &v1.ListenRequest{Name: "foo"})
+		if err != nil {
+			return err
+		}
+		_, err = s.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	streamWrite call = func(ctx context.Context, client v1.WerftServiceClient) error {
+		s, err := client.StartLocalJob(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = s.CloseAndRecv()
+		return err
+	}
+)
+
+func TestInterceptor(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: key.Public(), KeyID: "key", Algorithm: string(jose.RS256), Use: "sig"},
+		}})
+	}))
+	defer jwks.Close()
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		validJWT   = signJWT(t, key, "key", jwt.Claims{Subject: "alice", Issuer: "werft-test", Audience: jwt.Audience{"werft"}, Expiry: jwt.NewNumericDate(time.Now().Add(time.Hour))})
+		expiredJWT = signJWT(t, key, "key", jwt.Claims{Subject: "alice", Issuer: "werft-test", Audience: jwt.Audience{"werft"}, Expiry: jwt.NewNumericDate(time.Now().Add(-time.Hour))})
+		issuerJWT  = signJWT(t, key, "key", jwt.Claims{Subject: "alice", Issuer: "someone-else", Audience: jwt.Audience{"werft"}, Expiry: jwt.NewNumericDate(time.Now().Add(time.Hour))})
+		noSubJWT   = signJWT(t, key, "key", jwt.Claims{Issuer: "werft-test", Audience: jwt.Audience{"werft"}, Expiry: jwt.NewNumericDate(time.Now().Add(time.Hour))})
+		forgedJWT  = signJWT(t, otherKey, "key", jwt.Claims{Subject: "alice", Issuer: "werft-test", Audience: jwt.Audience{"werft"}, Expiry: jwt.NewNumericDate(time.Now().Add(time.Hour))})
+	)
+
+	cfg := auth.Config{
+		Tokens: map[string]string{"ci": "secret"},
+		JWKS:   &auth.JWKSConfig{URL: jwks.URL, Issuer: "werft-test", Audience: "werft"},
+	}
+	publicCfg := cfg
+	publicCfg.PublicReads = true
+
+	tests := []struct {
+		Name   string
+		Config auth.Config
+		Token  string
+		Call   call
+		Code   codes.Code
+	}{
+		{Name: "unary read without token", Config: cfg, Call: unaryRead, Code: codes.Unauthenticated},
+		{Name: "unary read with token", Config: cfg, Token: "secret", Call: unaryRead, Code: codes.OK},
+		{Name: "unary read with invalid token", Config: cfg, Token: "guess", Call: unaryRead, Code: codes.Unauthenticated},
+		{Name: "public unary read without token", Config: publicCfg, Call: unaryRead, Code: codes.OK},
+		{Name: "public unary read with invalid token", Config: publicCfg, Token: "guess", Call: unaryRead, Code: codes.OK},
+		{Name: "unary write without token", Config: cfg, Call: unaryWrite, Code: codes.Unauthenticated},
+		{Name: "unary write with token", Config: cfg, Token: "secret", Call: unaryWrite, Code: codes.OK},
+		{Name: "unary write with invalid token", Config: cfg, Token: "guess", Call: unaryWrite, Code: codes.Unauthenticated},
+		{Name: "public unary write without token", Config: publicCfg, Call: unaryWrite, Code: codes.Unauthenticated},
+		{Name: "public unary write with token", Config: publicCfg, Token: "secret", Call: unaryWrite, Code: codes.OK},
+		{Name: "stream read without token", Config: cfg, Call: streamRead, Code: codes.Unauthenticated},
+		{Name: "stream read with token", Config: cfg, Token: "secret", Call: streamRead, Code: codes.OK},
+		{Name: "public stream read without token", Config: publicCfg, Call: streamRead, Code: codes.OK},
+		{Name: "stream write without token", Config: cfg, Call: streamWrite, Code: codes.Unauthenticated},
+		{Name: "stream write with token", Config: cfg, Token: "secret", Call: streamWrite, Code: codes.OK},
+		{Name: "stream write with invalid token", Config: cfg, Token: "guess", Call: streamWrite, Code: codes.Unauthenticated},
+		{Name: "public stream write without token", Config: publicCfg, Call: streamWrite, Code: codes.Unauthenticated},
+		{Name: "unary write with JWT", Config: cfg, Token: validJWT, Call: unaryWrite, Code: codes.OK},
+		{Name: "stream write with JWT", Config: cfg, Token: validJWT, Call: streamWrite, Code: codes.OK},
+		{Name: "unary write with expired JWT", Config: cfg, Token: expiredJWT, Call: unaryWrite, Code: codes.Unauthenticated},
+		{Name: "unary write with JWT from other issuer", Config: cfg, Token: issuerJWT, Call: unaryWrite, Code: codes.Unauthenticated},
+		{Name: "unary write with JWT without subject", Config: cfg, Token: noSubJWT, Call: unaryWrite, Code: codes.Unauthenticated},
+		{Name: "stream write with forged JWT", Config: cfg, Token: forgedJWT, Call: streamWrite, Code: codes.Unauthenticated},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			interceptor, err := auth.NewInterceptor(test.Config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			l := bufconn.Listen(1024 * 1024)
+			srv := grpc.NewServer(
+				grpc.UnaryInterceptor(interceptor.UnaryServerInterceptor),
+				grpc.StreamInterceptor(interceptor.StreamServerInterceptor),
+			)
+			v1.RegisterWerftServiceServer(srv, &fakeWerftServer{})
+			go srv.Serve(l)
+			defer srv.Stop()
+
+			opts := []grpc.DialOption{
+				grpc.WithInsecure(),
+				grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return l.Dial() }),
+			}
+			if test.Token != "" {
+				opts = append(opts,
+					grpc.WithUnaryInterceptor(auth.UnaryClientInterceptor(test.Token)),
+					grpc.WithStreamInterceptor(auth.StreamClientInterceptor(test.Token)),
+				)
+			}
+			conn, err := grpc.Dial("bufnet", opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			err = test.Call(ctx, v1.NewWerftServiceClient(conn))
+			if code := status.Code(err); code != test.Code {
+				t.Errorf("expected %v, got %v", test.Code, err)
+			}
+		})
+	}
+}
+
+func TestInterceptorPublicMethods(t *testing.T) {
+	interceptor, err := auth.NewInterceptor(auth.Config{Tokens: map[string]string{"ci": "secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+
+	tests := []struct {
+		Method string
+		Code   codes.Code
+	}{
+		{Method: "/grpc.health.v1.Health/Check", Code: codes.OK},
+		{Method: "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo", Code: codes.Unauthenticated},
+		{Method: "/v1.WerftService/GetJob", Code: codes.Unauthenticated},
+	}
+	for _, test := range tests {
+		t.Run(test.Method, func(t *testing.T) {
+			_, err := interceptor.UnaryServerInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: test.Method}, handler)
+			if code := status.Code(err); code != test.Code {
+				t.Errorf("expected %v, got %v", test.Code, err)
+			}
+		})
+	}
+}
+
+func TestPrincipal(t *testing.T) {
+	interceptor, err := auth.NewInterceptor(auth.Config{Tokens: map[string]string{"ci": "secret"}, PublicReads: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for token, expected := range map[string]string{"secret": "ci", "guess": "", "": ""} {
+		ctx := context.Background()
+		if token != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token))
+		}
+		resp, err := interceptor.UnaryServerInterceptor(ctx, &v1.GetJobRequest{}, &grpc.UnaryServerInfo{FullMethod: "/v1.WerftService/GetJob"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return fakeWerftServer{}.GetJob(ctx, req.(*v1.GetJobRequest))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if act := resp.(*v1.GetJobResponse).JobSpec; act != expected {
+			t.Errorf("token %q: expected principal %q, got %q", token, expected, act)
+		}
+	}
+}
+
+func TestNewInterceptor(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Config auth.Config
+		Error  string
+	}{
+		{Name: "no verifier", Config: auth.Config{PublicReads: true}, Error: "auth requires tokens or a JWKS endpoint"},
+		{Name: "empty token", Config: auth.Config{Tokens: map[string]string{"ci": ""}}, Error: "tokens and their principals must not be empty"},
+		{Name: "empty principal", Config: auth.Config{Tokens: map[string]string{"": "secret"}}, Error: "tokens and their principals must not be empty"},
+		{Name: "empty JWKS URL", Config: auth.Config{JWKS: &auth.JWKSConfig{}}, Error: "JWKS URL must not be empty"},
+		{Name: "tokens", Config: auth.Config{Tokens: map[string]string{"ci": "secret"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			_, err := auth.NewInterceptor(test.Config)
+			var act string
+			if err != nil {
+				act = err.Error()
+			}
+			if act != test.Error {
+				t.Errorf("expected error %q, got %q", test.Error, act)
+			}
+		})
+	}
+}
+
+func signJWT(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.Claims) string {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", kid),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
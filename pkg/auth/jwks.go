@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// JWKSConfig configures the verification of JWTs
+type JWKSConfig struct {
+	// URL serves the JSON Web Key Set the tokens are signed with
+	URL string `yaml:"url"`
+	// Issuer is the expected iss claim. If empty, we accept any issuer.
+	Issuer string `yaml:"issuer,omitempty"`
+	// Audience is the expected aud claim. If empty, we accept any audience.
+	Audience string `yaml:"audience,omitempty"`
+	// RefreshInterval is the time after which we download the key set again. Defaults to one hour.
+	RefreshInterval string `yaml:"refreshInterval,omitempty"`
+}
+
+const (
+	// jwksMinRefreshInterval limits how often tokens signed with an unknown key make us download the key set
+	jwksMinRefreshInterval = time.Minute
+	// jwtLeeway is the clock skew we tolerate when checking exp and nbf
+	jwtLeeway = time.Minute
+)
+
+// JWKSVerifier accepts JWTs signed by one of the keys of a JSON Web Key Set
+type JWKSVerifier struct {
+	Config          JWKSConfig
+	RefreshInterval time.Duration
+	Client          *http.Client
+
+	mu      sync.Mutex
+	keys    *jose.JSONWebKeySet
+	fetched time.Time
+}
+
+// NewJWKSVerifier creates a new JWKS verifier. The key set is downloaded on first use.
+func NewJWKSVerifier(cfg JWKSConfig) (*JWKSVerifier, error) {
+	if cfg.URL == "" {
+		return nil, xerrors.Errorf("JWKS URL must not be empty")
+	}
+	refresh := time.Hour
+	if cfg.RefreshInterval != "" {
+		var err error
+		refresh, err = time.ParseDuration(cfg.RefreshInterval)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid JWKS refresh interval: %w", err)
+		}
+	}
+	return &JWKSVerifier{
+		Config:          cfg,
+		RefreshInterval: refresh,
+		Client:          &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Verify returns the subject of the token, or an error if the token is not a valid JWT signed by one of the keys
+func (v *JWKSVerifier) Verify(ctx context.Context, token string) (principal string, err error) {
+	tkn, err := jwt.ParseSigned(token)
+	if err != nil {
+		return "", xerrors.Errorf("cannot parse JWT: %w", err)
+	}
+	if len(tkn.Headers) == 0 {
+		return "", xerrors.Errorf("JWT has no header")
+	}
+
+	key, err := v.key(ctx, tkn.Headers[0].KeyID)
+	if err != nil {
+		return "", err
+	}
+
+	var claims jwt.Claims
+	err = tkn.Claims(key.Key, &claims)
+	if err != nil {
+		return "", xerrors.Errorf("invalid JWT signature: %w", err)
+	}
+	expected := jwt.Expected{
+		Issuer: v.Config.Issuer,
+		Time:   time.Now(),
+	}
+	if v.Config.Audience != "" {
+		expected.Audience = jwt.Audience{v.Config.Audience}
+	}
+	err = claims.ValidateWithLeeway(expected, jwtLeeway)
+	if err != nil {
+		return "", xerrors.Errorf("invalid JWT claims: %w", err)
+	}
+	if claims.Subject == "" {
+		return "", xerrors.Errorf("JWT has no subject")
+	}
+	return claims.Subject, nil
+}
+
+// key returns the key with the ID. If we don't know the key we download the key set again
+// in case the identity provider rotated its keys.
+func (v *JWKSVerifier) key(ctx context.Context, kid string) (*jose.JSONWebKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	age := time.Since(v.fetched)
+	if v.keys == nil || age > v.RefreshInterval || (len(v.keys.Key(kid)) == 0 && age > jwksMinRefreshInterval) {
+		err := v.fetch(ctx)
+		if err != nil && v.keys == nil {
+			return nil, err
+		}
+	}
+
+	keys := v.keys.Key(kid)
+	if len(keys) == 0 {
+		return nil, xerrors.Errorf("unknown key %s", kid)
+	}
+	return &keys[0], nil
+}
+
+func (v *JWKSVerifier) fetch(ctx context.Context) error {
+	// we don't retry failed downloads more often than we refresh for unknown keys
+	v.fetched = time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.Config.URL, nil)
+	if err != nil {
+		return xerrors.Errorf("cannot download JWKS: %w", err)
+	}
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return xerrors.Errorf("cannot download JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return xerrors.Errorf("cannot download JWKS: %s", resp.Status)
+	}
+
+	var keys jose.JSONWebKeySet
+	err = json.NewDecoder(resp.Body).Decode(&keys)
+	if err != nil {
+		return xerrors.Errorf("cannot decode JWKS: %w", err)
+	}
+	v.keys = &keys
+	return nil
+}
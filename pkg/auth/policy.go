@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"golang.org/x/xerrors"
+)
+
+// Action is something a principal does with the jobs of a repository
+type Action string
+
+const (
+	// ActionRead lists jobs and reads their logs
+	ActionRead Action = "read"
+	// ActionStart starts new jobs, including restarting previous ones
+	ActionStart Action = "start"
+	// ActionStop stops running jobs
+	ActionStop Action = "stop"
+	// ActionAnnotate changes the annotations of existing jobs
+	ActionAnnotate Action = "annotate"
+)
+
+// Policy decides if a principal may perform an action on the jobs of a repository
+type Policy interface {
+	// Allowed returns true if the principal may perform the action. Anonymous principals are empty.
+	// The repository is nil for jobs which have none, e.g. some local jobs.
+	Allowed(principal string, action Action, repo *v1.Repository) bool
+
+	// Repos returns glob patterns (see Rule.Repos) matching all repositories on whose jobs the principal
+	// may perform the action. Job lists use them to have the store return only the jobs a principal may read.
+	Repos(principal string, action Action) []string
+}
+
+// Rule grants principals actions on the jobs of repositories
+type Rule struct {
+	// Principals are glob patterns matching the principal, e.g. team-a-*. "*" matches anonymous principals, too.
+	Principals []string `yaml:"principals"`
+	// Repos are glob patterns matching host/owner/repo, e.g. github.com/csweichel/*.
+	// As with file paths, * does not match /, hence */*/* matches all repositories.
+	// Jobs without a repository only match *.
+	Repos []string `yaml:"repos"`
+	// Actions are the actions this rule grants
+	Actions []Action `yaml:"actions"`
+}
+
+// StaticPolicy allows an action if one of its rules grants it
+type StaticPolicy []Rule
+
+// NewStaticPolicy creates a policy from rules
+func NewStaticPolicy(rules []Rule) (StaticPolicy, error) {
+	for i, r := range rules {
+		for _, p := range append(append([]string{}, r.Principals...), r.Repos...) {
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, xerrors.Errorf("rule %d: invalid pattern %q: %w", i, p, err)
+			}
+		}
+		for _, a := range r.Actions {
+			switch a {
+			case ActionRead, ActionStart, ActionStop, ActionAnnotate:
+			default:
+				return nil, xerrors.Errorf("rule %d: unknown action %q: must be read, start, stop or annotate", i, a)
+			}
+		}
+	}
+	return StaticPolicy(rules), nil
+}
+
+// Allowed returns true if one of the rules grants the action
+func (p StaticPolicy) Allowed(principal string, action Action, repo *v1.Repository) bool {
+	var name string
+	if repo != nil {
+		name = fmt.Sprintf("%s/%s/%s", repo.Host, repo.Owner, repo.Repo)
+	}
+	for _, r := range p {
+		if containsAction(r.Actions, action) && matchesAny(r.Principals, principal) && matchesAny(r.Repos, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Repos returns the repo patterns of all rules which grant the action to the principal
+func (p StaticPolicy) Repos(principal string, action Action) []string {
+	var (
+		res  []string
+		seen = make(map[string]struct{})
+	)
+	for _, r := range p {
+		if !containsAction(r.Actions, action) || !matchesAny(r.Principals, principal) {
+			continue
+		}
+		for _, repo := range r.Repos {
+			if _, ok := seen[repo]; ok {
+				continue
+			}
+			seen[repo] = struct{}{}
+			res = append(res, repo)
+		}
+	}
+	return res
+}
+
+// RepoFilter produces a filter expression which matches the jobs whose repository matches one of the
+// patterns, using the repo field (host/owner/repo) of job filters.
+func RepoFilter(patterns []string) *v1.FilterExpression {
+	res := &v1.FilterExpression{}
+	for _, p := range patterns {
+		res.Terms = append(res.Terms, &v1.FilterTerm{
+			Field:     "repo",
+			Value:     globRegexp(p),
+			Operation: v1.FilterOp_OP_MATCHES,
+		})
+	}
+	return res
+}
+
+// globRegexp turns a glob pattern as understood by path.Match into an anchored regular expression which
+// Go and Postgres evaluate alike
+func globRegexp(pattern string) string {
+	// both regexp dialects treat ASCII punctuation preceded by a backslash as literal, within brackets, too
+	literal := func(c rune) string {
+		if c <= unicode.MaxASCII && (unicode.IsPunct(c) || unicode.IsSymbol(c)) {
+			return `\` + string(c)
+		}
+		return string(c)
+	}
+
+	var (
+		res     strings.Builder
+		runes   = []rune(pattern)
+		inClass bool
+	)
+	res.WriteString("^")
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '\\' && i+1 < len(runes):
+			i++
+			res.WriteString(literal(runes[i]))
+		case inClass:
+			// character ranges and the negating ^ mean the same in globs and regular expressions
+			if c == ']' {
+				inClass = false
+			}
+			res.WriteRune(c)
+		case c == '[':
+			inClass = true
+			res.WriteRune(c)
+		case c == '*':
+			res.WriteString("[^/]*")
+		case c == '?':
+			res.WriteString("[^/]")
+		default:
+			res.WriteString(literal(c))
+		}
+	}
+	res.WriteString("$")
+	return res.String()
+}
+
+func containsAction(actions []Action, action Action) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
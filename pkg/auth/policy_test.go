@@ -0,0 +1,120 @@
+package auth_test
+
+import (
+	"path"
+	"reflect"
+	"regexp"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/auth"
+)
+
+func TestStaticPolicy(t *testing.T) {
+	policy, err := auth.NewStaticPolicy([]auth.Rule{
+		{Principals: []string{"team-a-*", "alice"}, Repos: []string{"github.com/team-a/*"}, Actions: []auth.Action{auth.ActionRead, auth.ActionStart, auth.ActionStop}},
+		{Principals: []string{"*"}, Repos: []string{"*/*/*"}, Actions: []auth.Action{auth.ActionRead}},
+		{Principals: []string{"bob"}, Repos: []string{"*"}, Actions: []auth.Action{auth.ActionStart}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	teamA := &v1.Repository{Host: "github.com", Owner: "team-a", Repo: "werft"}
+	teamB := &v1.Repository{Host: "github.com", Owner: "team-b", Repo: "werft"}
+	tests := []struct {
+		Principal string
+		Action    auth.Action
+		Repo      *v1.Repository
+		Allowed   bool
+	}{
+		{"team-a-bot", auth.ActionStart, teamA, true},
+		{"alice", auth.ActionStop, teamA, true},
+		{"team-a-bot", auth.ActionStart, teamB, false},
+		{"team-b-bot", auth.ActionStart, teamA, false},
+		{"team-b-bot", auth.ActionRead, teamA, true},
+		{"", auth.ActionRead, teamB, true},
+		{"", auth.ActionStart, teamB, false},
+		{"alice", auth.ActionStart, nil, false},
+		{"bob", auth.ActionStart, nil, true},
+		{"bob", auth.ActionStart, teamA, false},
+	}
+	for _, test := range tests {
+		var repo string
+		if test.Repo != nil {
+			repo = test.Repo.Owner
+		}
+		if act := policy.Allowed(test.Principal, test.Action, test.Repo); act != test.Allowed {
+			t.Errorf("%q %s %s: expected %v, got %v", test.Principal, test.Action, repo, test.Allowed, act)
+		}
+	}
+}
+
+func TestNewStaticPolicy(t *testing.T) {
+	tests := []struct {
+		Name  string
+		Rule  auth.Rule
+		Error string
+	}{
+		{Name: "valid", Rule: auth.Rule{Principals: []string{"*"}, Repos: []string{"github.com/*/*"}, Actions: []auth.Action{auth.ActionRead}}},
+		{Name: "invalid pattern", Rule: auth.Rule{Repos: []string{"github.com/[a"}}, Error: `rule 0: invalid pattern "github.com/[a": syntax error in pattern`},
+		{Name: "unknown action", Rule: auth.Rule{Actions: []auth.Action{"delete"}}, Error: `rule 0: unknown action "delete": must be read, start, stop or annotate`},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			_, err := auth.NewStaticPolicy([]auth.Rule{test.Rule})
+			var act string
+			if err != nil {
+				act = err.Error()
+			}
+			if act != test.Error {
+				t.Errorf("expected error %q, got %q", test.Error, act)
+			}
+		})
+	}
+}
+
+func TestStaticPolicyRepos(t *testing.T) {
+	policy := auth.StaticPolicy{
+		{Principals: []string{"team-a-*"}, Repos: []string{"github.com/team-a/*"}, Actions: []auth.Action{auth.ActionRead, auth.ActionStart}},
+		{Principals: []string{"*"}, Repos: []string{"github.com/public/*", "github.com/team-a/*"}, Actions: []auth.Action{auth.ActionRead}},
+	}
+
+	tests := []struct {
+		Principal string
+		Action    auth.Action
+		Repos     []string
+	}{
+		{"team-a-bot", auth.ActionRead, []string{"github.com/team-a/*", "github.com/public/*"}},
+		{"team-a-bot", auth.ActionStart, []string{"github.com/team-a/*"}},
+		{"team-b-bot", auth.ActionStart, nil},
+	}
+	for _, test := range tests {
+		if act := policy.Repos(test.Principal, test.Action); !reflect.DeepEqual(act, test.Repos) {
+			t.Errorf("%q %s: expected %v, got %v", test.Principal, test.Action, test.Repos, act)
+		}
+	}
+}
+
+func TestRepoFilter(t *testing.T) {
+	// the filter must match the same repositories as the patterns themselves
+	names := []string{"", "github.com/team-a/werft", "github.com/team-a/werft/x", "github.com/team-b/werft", "gitlab.com/team-a/werft", "github.com/team.a/w-rft", "githubXcom/team-a/werft"}
+	patterns := []string{"*", "*/*/*", "github.com/team-a/*", "github.com/team?a/*", "github.com/[a-z]*/w[^a]rft", `github.com/team\-a/*`, "github.com/team-[ab]/werft"}
+	for _, p := range patterns {
+		filter := auth.RepoFilter([]string{p})
+		if len(filter.Terms) != 1 || filter.Terms[0].Field != "repo" || filter.Terms[0].Operation != v1.FilterOp_OP_MATCHES {
+			t.Fatalf("%s: unexpected filter %v", p, filter)
+		}
+		re, err := regexp.Compile(filter.Terms[0].Value)
+		if err != nil {
+			t.Errorf("%s: invalid regexp: %v", p, err)
+			continue
+		}
+		for _, name := range names {
+			expected, _ := path.Match(p, name)
+			if act := re.MatchString(name); act != expected {
+				t.Errorf("%s (%s) on %q: expected %v, got %v", p, re, name, expected, act)
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
+	"google.golang.org/grpc/credentials"
+)
+
+// TLSConfig configures TLS for the gRPC API
+type TLSConfig struct {
+	// Cert is the server's certificate (PEM file)
+	Cert string `yaml:"cert"`
+	// Key is the server's private key (PEM file)
+	Key string `yaml:"key"`
+	// ClientCA requires clients to present a certificate signed by this CA (PEM file)
+	ClientCA string `yaml:"clientCA,omitempty"`
+}
+
+// ServerCredentials creates the transport credentials for serving the gRPC API using TLS
+func ServerCredentials(cfg TLSConfig) (credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot load server certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.ClientCA != "" {
+		ca, err := ioutil.ReadFile(cfg.ClientCA)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, xerrors.Errorf("cannot load client CA from %s", cfg.ClientCA)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return &handshakeLogger{credentials.NewTLS(tlsConfig)}, nil
+}
+
+// handshakeLogger logs failed handshakes which gRPC would otherwise swallow,
+// e.g. because a client presented no or an untrusted certificate
+type handshakeLogger struct {
+	credentials.TransportCredentials
+}
+
+func (h *handshakeLogger) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	c, info, err := h.TransportCredentials.ServerHandshake(conn)
+	if err != nil {
+		log.WithError(err).WithField("remote", conn.RemoteAddr().String()).Warn("TLS handshake failed")
+	}
+	return c, info, err
+}
+
+func (h *handshakeLogger) Clone() credentials.TransportCredentials {
+	return &handshakeLogger{h.TransportCredentials.Clone()}
+}
@@ -0,0 +1,253 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+
+	werftv1 "github.com/csweichel/werft/pkg/api/v1"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+const (
+	// ArtifactsContainer is the sidecar we collect the outputs of a job from once its other containers have finished
+	ArtifactsContainer = "werft-artifacts"
+	// ArtifactsInitContainer is the init container we restore the inputs of a job into before its containers start
+	ArtifactsInitContainer = "werft-artifacts-restore"
+	// ResultTypeArtifact is the type of the results which list the artifacts a job produced
+	ResultTypeArtifact = "artifact"
+
+	// artifactsImage must provide sh and tar
+	artifactsImage = "alpine:latest"
+	// artifactsReadyMarker tells the artifacts init container that all inputs are restored
+	artifactsReadyMarker = "/workspace/.werft-artifacts-ready"
+	// collectingArtifactsDetails are the details of a job whose outputs we're collecting
+	collectingArtifactsDetails = "collecting artifacts"
+)
+
+// ArtifactStore stores the artifacts of jobs. A store.BlobStore satisfies this interface.
+type ArtifactStore interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// Artifacts are the directories of the workspace a job passes to other jobs
+type Artifacts struct {
+	// Outputs are collected from the workspace once the job has succeeded
+	Outputs []Artifact `json:"outputs,omitempty"`
+	// Inputs are restored into the workspace before the job's containers start
+	Inputs []Artifact `json:"inputs,omitempty"`
+}
+
+// Artifact is a directory of the workspace which is stored as gzipped tarball
+type Artifact struct {
+	Name string `json:"name"`
+	// Path is the directory relative to the workspace
+	Path string `json:"path"`
+	// Key identifies the tarball in the artifact store
+	Key string `json:"key"`
+}
+
+// ArtifactsSidecar returns the container we collect the outputs of a job from. It must mount the workspace.
+func ArtifactsSidecar() corev1.Container {
+	return corev1.Container{
+		Name:    ArtifactsContainer,
+		Image:   artifactsImage,
+		Command: []string{"sh", "-c", "trap 'exit 0' TERM; while true; do sleep 1; done"},
+	}
+}
+
+// ArtifactsInit returns the init container we restore the inputs of a job into. It must mount the workspace.
+func ArtifactsInit() corev1.Container {
+	return corev1.Container{
+		Name:    ArtifactsInitContainer,
+		Image:   artifactsImage,
+		Command: []string{"sh", "-c", fmt.Sprintf("echo waiting for artifacts; while [ ! -f %[1]s ]; do sleep 1; done; rm %[1]s", artifactsReadyMarker)},
+	}
+}
+
+// getArtifacts returns the artifacts of a job pod
+func getArtifacts(obj *corev1.Pod, labels labelSet) (*Artifacts, error) {
+	val, ok := obj.Annotations[labels.AnnotationArtifacts]
+	if !ok {
+		return nil, nil
+	}
+	var res Artifacts
+	err := json.Unmarshal([]byte(val), &res)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot unmarshal artifacts: %w", err)
+	}
+	return &res, nil
+}
+
+// collectingArtifacts returns true if the job has outputs we haven't collected yet
+func collectingArtifacts(obj *corev1.Pod, labels labelSet) bool {
+	if _, done := obj.Annotations[labels.AnnotationArtifactsCollected]; done {
+		return false
+	}
+	artifacts, _ := getArtifacts(obj, labels)
+	return artifacts != nil && len(artifacts.Outputs) > 0
+}
+
+// restoringArtifacts returns true if the artifacts init container waits for us to restore the job's inputs
+func restoringArtifacts(obj *corev1.Pod, labels labelSet) bool {
+	if _, done := obj.Annotations[labels.AnnotationArtifactsRestored]; done {
+		return false
+	}
+	for _, cs := range obj.Status.InitContainerStatuses {
+		if cs.Name == ArtifactsInitContainer {
+			return cs.State.Running != nil
+		}
+	}
+	return false
+}
+
+// transferArtifacts runs the transfer of artifacts in the background, one at a time per pod. The transfer only runs if
+// it's still pending for the current pod, as the pod can change while we wait for the previous transfer to finish.
+// If the transfer fails, so does the job.
+func (js *Executor) transferArtifacts(obj *corev1.Pod, pending func(obj *corev1.Pod, labels labelSet) bool, transfer func(obj *corev1.Pod, artifacts *Artifacts) error) {
+	js.mu.Lock()
+	if _, running := js.artifactTransfers[obj.Name]; running {
+		js.mu.Unlock()
+		return
+	}
+	js.artifactTransfers[obj.Name] = struct{}{}
+	js.mu.Unlock()
+
+	go func() {
+		defer func() {
+			js.mu.Lock()
+			delete(js.artifactTransfers, obj.Name)
+			js.mu.Unlock()
+		}()
+
+		pod, err := js.Client.CoreV1().Pods(js.Config.Namespace).Get(context.Background(), obj.Name, metav1.GetOptions{})
+		if err != nil {
+			log.WithError(err).WithField("name", obj.Name).Warn("cannot get job pod to transfer artifacts")
+			return
+		}
+		if !pending(pod, js.labels) {
+			return
+		}
+
+		artifacts, err := getArtifacts(pod, js.labels)
+		if err == nil && js.Artifacts == nil {
+			err = xerrors.Errorf("werft has no artifact store configured")
+		}
+		if err == nil {
+			err = transfer(pod, artifacts)
+		}
+		if err == nil {
+			return
+		}
+
+		log.WithError(err).WithField("name", obj.Name).Warn("cannot transfer artifacts")
+		err = js.addAnnotation(obj.Name, map[string]string{
+			js.labels.AnnotationFailed: err.Error(),
+		})
+		if err != nil {
+			log.WithError(err).WithField("name", obj.Name).Error("cannot fail job after its artifacts transfer failed")
+		}
+	}()
+}
+
+// collectArtifacts uploads the outputs of a job to the artifact store and lists them as results of the job
+func (js *Executor) collectArtifacts(obj *corev1.Pod, artifacts *Artifacts) error {
+	jobName, _ := getJobName(obj, js.labels)
+	for _, a := range artifacts.Outputs {
+		pr, pw := io.Pipe()
+		go func(dir string) {
+			pw.CloseWithError(js.execInPod(obj.Name, ArtifactsContainer, []string{"tar", "cz", "-C", dir, "."}, nil, pw))
+		}(path.Join("/workspace", a.Path))
+
+		cr := &countingReader{Reader: pr}
+		err := js.Artifacts.Put(context.Background(), a.Key, cr)
+		pr.CloseWithError(err)
+		if err != nil {
+			return xerrors.Errorf("cannot collect artifact %s: %w", a.Name, err)
+		}
+
+		err = js.RegisterResult(jobName, &werftv1.JobResult{
+			Type:        ResultTypeArtifact,
+			Payload:     a.Name,
+			Description: fmt.Sprintf("%s (%d bytes)", a.Path, cr.N),
+		})
+		if err != nil {
+			return xerrors.Errorf("cannot collect artifact %s: %w", a.Name, err)
+		}
+	}
+
+	return js.addAnnotation(obj.Name, map[string]string{
+		js.labels.AnnotationArtifactsCollected: "true",
+	})
+}
+
+// restoreArtifacts downloads the inputs of a job from the artifact store into its workspace
+func (js *Executor) restoreArtifacts(obj *corev1.Pod, artifacts *Artifacts) error {
+	for _, a := range artifacts.Inputs {
+		blob, err := js.Artifacts.Get(context.Background(), a.Key)
+		if err != nil {
+			return xerrors.Errorf("cannot restore artifact %s: %w", a.Name, err)
+		}
+		err = js.execInPod(obj.Name, ArtifactsInitContainer, []string{"sh", "-c", `mkdir -p "$0" && tar xz -C "$0"`, path.Join("/workspace", a.Path)}, blob, ioutil.Discard)
+		blob.Close()
+		if err != nil {
+			return xerrors.Errorf("cannot restore artifact %s: %w", a.Name, err)
+		}
+	}
+
+	err := js.execInPod(obj.Name, ArtifactsInitContainer, []string{"touch", artifactsReadyMarker}, nil, ioutil.Discard)
+	if err != nil {
+		return xerrors.Errorf("cannot restore artifacts: %w", err)
+	}
+	return js.addAnnotation(obj.Name, map[string]string{
+		js.labels.AnnotationArtifactsRestored: "true",
+	})
+}
+
+// remoteExec runs a command in a container of a pod and waits for it to finish
+func (js *Executor) remoteExec(podName, container string, cmd []string, stdin io.Reader, stdout io.Writer) error {
+	req := js.Client.CoreV1().RESTClient().
+		Post().
+		Namespace(js.Config.Namespace).
+		Resource("pods").
+		Name(podName).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   cmd,
+			Stdin:     stdin != nil,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(js.KubeConfig, "POST", req.URL())
+	if err != nil {
+		return xerrors.Errorf("cannot exec in %s: %w", podName, err)
+	}
+	return exec.Stream(remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: log.WithField("pod", podName).WithField("container", container).WriterLevel(log.WarnLevel),
+	})
+}
+
+// countingReader counts the bytes read
+type countingReader struct {
+	io.Reader
+	N int64
+}
+
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	r.N += int64(n)
+	return
+}
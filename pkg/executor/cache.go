@@ -0,0 +1,144 @@
+package executor
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+
+	werftv1 "github.com/csweichel/werft/pkg/api/v1"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
+	corev1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// cacheVolumeName is the name of the pod volume the cache is mounted from
+const cacheVolumeName = "werft-cache"
+
+// Cache is a persistent volume shared by all jobs with the same key
+type Cache struct {
+	// Key identifies the cache volume
+	Key string
+	// MountPath is the directory the cache is mounted to in all init and non-sidecar containers
+	MountPath string
+	// Size is the size of the volume if it has to be created
+	Size resource.Quantity
+	// StorageClass is the storage class of the volume if it has to be created. Empty means the cluster's default.
+	StorageClass string
+}
+
+// cacheID turns a cache key into a valid label value
+func cacheID(key string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(key)))
+}
+
+// cacheClaimName returns the name of the persistent volume claim backing a cache
+func cacheClaimName(id string) string {
+	return "werft-cache-" + id
+}
+
+// mountCache adds the cache volume to the pod. Persistent volumes are mounted by one job at a time,
+// hence if another job uses the cache already this job gets a fresh, empty volume instead.
+// Callers must hold cacheMu until the pod is created, so that no other job claims the cache in between.
+func (js *Executor) mountCache(pod *corev1.Pod, cache *Cache, sidecars []string) error {
+	id := cacheID(cache.Key)
+	inUse, err := js.cacheInUse(id)
+	if err != nil {
+		return err
+	}
+
+	var src corev1.VolumeSource
+	if inUse {
+		log.WithField("name", pod.Name).WithField("cache", cache.Key).Info("cache is in use by another job - using a fresh volume")
+		src.EmptyDir = &corev1.EmptyDirVolumeSource{}
+	} else {
+		claim, err := js.ensureCacheClaim(id, cache)
+		if err != nil {
+			return err
+		}
+		pod.ObjectMeta.Labels[js.labels.LabelCache] = id
+		src.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim}
+	}
+
+	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+		Name:         cacheVolumeName,
+		VolumeSource: src,
+	})
+	isSidecar := make(map[string]struct{}, len(sidecars))
+	for _, s := range sidecars {
+		isSidecar[s] = struct{}{}
+	}
+	for i, c := range pod.Spec.Containers {
+		if _, ok := isSidecar[c.Name]; ok {
+			continue
+		}
+		pod.Spec.Containers[i].VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+			Name:      cacheVolumeName,
+			MountPath: cache.MountPath,
+		})
+	}
+	for i, c := range pod.Spec.InitContainers {
+		pod.Spec.InitContainers[i].VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+			Name:      cacheVolumeName,
+			MountPath: cache.MountPath,
+		})
+	}
+	return nil
+}
+
+// cacheInUse returns true if a job which hasn't finished yet mounts the cache
+func (js *Executor) cacheInUse(id string) (bool, error) {
+	pods, err := js.Client.CoreV1().Pods(js.Config.Namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", js.labels.LabelCache, id),
+	})
+	if err != nil {
+		return false, xerrors.Errorf("cannot list jobs using the cache: %w", err)
+	}
+	for _, pod := range pods.Items {
+		status, err := getStatus(&pod, js.labels)
+		if err == nil && (status.Phase == werftv1.JobPhase_PHASE_DONE || status.Phase == werftv1.JobPhase_PHASE_CLEANUP) {
+			continue
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// ensureCacheClaim creates the persistent volume claim of a cache unless it exists already
+func (js *Executor) ensureCacheClaim(id string, cache *Cache) (name string, err error) {
+	name = cacheClaimName(id)
+	claims := js.Client.CoreV1().PersistentVolumeClaims(js.Config.Namespace)
+	_, err = claims.Get(context.Background(), name, metav1.GetOptions{})
+	if err == nil {
+		return name, nil
+	}
+	if !k8serr.IsNotFound(err) {
+		return "", xerrors.Errorf("cannot get cache volume: %w", err)
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{js.labels.LabelCache: id},
+			Annotations: map[string]string{js.labels.AnnotationCacheKey: cache.Key},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: cache.Size},
+			},
+		},
+	}
+	if cache.StorageClass != "" {
+		sc := cache.StorageClass
+		pvc.Spec.StorageClassName = &sc
+	}
+	_, err = claims.Create(context.Background(), pvc, metav1.CreateOptions{})
+	if err != nil && !k8serr.IsAlreadyExists(err) {
+		return "", xerrors.Errorf("cannot create cache volume: %w", err)
+	}
+	log.WithField("cache", cache.Key).WithField("claim", name).Info("created cache volume")
+	return name, nil
+}
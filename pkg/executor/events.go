@@ -0,0 +1,79 @@
+package executor
+
+import (
+	"fmt"
+
+	werftv1 "github.com/csweichel/werft/pkg/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Reasons of the Kubernetes events we record on job pods
+const (
+	EventReasonScheduled = "JobScheduled"
+	EventReasonStarted   = "JobStarted"
+	EventReasonSucceeded = "JobSucceeded"
+	EventReasonFailed    = "JobFailed"
+	EventReasonTimedOut  = "JobTimedOut"
+	EventReasonCleanedUp = "JobCleanedUp"
+)
+
+// eventComponent is the source of the Kubernetes events we record
+const eventComponent = "werft"
+
+// newEventRecorder produces a recorder which sends events to the Kubernetes API
+func newEventRecorder(client kubernetes.Interface, namespace string) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events(namespace)})
+	return broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: eventComponent})
+}
+
+// recordEvent records a Kubernetes event on a job pod, so that the job's lifecycle shows in kubectl describe
+func (js *Executor) recordEvent(pod *corev1.Pod, eventtype, reason, message string) {
+	if js.Events == nil {
+		return
+	}
+	js.Events.Event(pod, eventtype, reason, message)
+}
+
+// recordPhaseChange records the events for the transition of a job into its current phase
+func (js *Executor) recordPhaseChange(pod *corev1.Pod, status *werftv1.JobStatus) {
+	js.mu.Lock()
+	prev, known := js.phases[status.Name]
+	js.phases[status.Name] = status.Phase
+	js.mu.Unlock()
+	if known && prev == status.Phase {
+		return
+	}
+
+	switch status.Phase {
+	case werftv1.JobPhase_PHASE_RUNNING:
+		js.recordEvent(pod, corev1.EventTypeNormal, EventReasonStarted, fmt.Sprintf("job %s started", status.Name))
+	case werftv1.JobPhase_PHASE_DONE:
+		if status.Conditions != nil && status.Conditions.Success {
+			js.recordEvent(pod, corev1.EventTypeNormal, EventReasonSucceeded, fmt.Sprintf("job %s succeeded", status.Name))
+			return
+		}
+		msg := fmt.Sprintf("job %s failed", status.Name)
+		if status.Details != "" {
+			msg += ": " + status.Details
+		}
+		js.recordEvent(pod, corev1.EventTypeWarning, EventReasonFailed, msg)
+	}
+}
+
+// forgetPhase drops the phase we last saw a job in once its pod is gone
+func (js *Executor) forgetPhase(name string) {
+	js.mu.Lock()
+	delete(js.phases, name)
+	js.mu.Unlock()
+}
+
+// podRef is the pod a job ran in, for recording events once the pod itself is gone
+func (js *Executor) podRef(podName string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: js.Config.Namespace}}
+}
@@ -2,11 +2,15 @@ package executor
 
 import (
 	"context"
+	"crypto/sha1"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -14,15 +18,18 @@ import (
 	werftv1 "github.com/csweichel/werft/pkg/api/v1"
 	"github.com/gogo/protobuf/jsonpb"
 	"github.com/golang/protobuf/ptypes"
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	"github.com/technosophos/moniker"
 	"golang.org/x/xerrors"
 	corev1 "k8s.io/api/core/v1"
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 )
 
@@ -33,8 +40,29 @@ type Config struct {
 	JobPrepTimeout  *Duration `yaml:"preperationTimeout"`
 	JobTotalTimeout *Duration `yaml:"totalTimeout"`
 	LabelPrefix     string    `json:"labelPrefix"`
+
+	// PodTTL is how long the pod of a finished job is kept around before it's deleted.
+	// Job metadata and logs remain in the store regardless. If not set, pods are deleted right away.
+	PodTTL *Duration `yaml:"podTTL,omitempty"`
+
+	// MaxConcurrentJobs limits the number of jobs running at the same time. Jobs beyond this limit
+	// are queued until a slot frees up. Zero means no limit.
+	MaxConcurrentJobs int `yaml:"maxConcurrentJobs,omitempty"`
+	// MaxConcurrentJobsPerRepo limits the number of jobs running at the same time for a single repository.
+	// Zero means no limit.
+	MaxConcurrentJobsPerRepo int `yaml:"maxConcurrentJobsPerRepo,omitempty"`
 }
 
+// ReasonSuperseded starts the details of jobs which were canceled because a newer job with the same mutex started
+const ReasonSuperseded = "superseded"
+
+// defaultGracePeriod is how long the containers of a job pod get to stop when the pod is deleted,
+// unless the job has a grace period of its own
+const defaultGracePeriod = 5 * time.Second
+
+// logDrainTimeout is the maximum time we wait for the logs of a job to be forwarded before deleting its pod
+const logDrainTimeout = 30 * time.Second
+
 // Duration is a JSON un-/marshallable type
 type Duration struct {
 	time.Duration
@@ -71,17 +99,29 @@ func NewExecutor(config Config, kubeConfig *rest.Config) (*Executor, error) {
 	if config.JobTotalTimeout.Duration < config.JobPrepTimeout.Duration {
 		return nil, xerrors.Errorf("total job timeout must be greater than the preparation timeout")
 	}
+	if config.PodTTL != nil && config.PodTTL.Duration < 0 {
+		return nil, xerrors.Errorf("pod TTL must not be negative")
+	}
+	if config.MaxConcurrentJobs < 0 || config.MaxConcurrentJobsPerRepo < 0 {
+		return nil, xerrors.Errorf("concurrent job limits must not be negative")
+	}
 
-	return &Executor{
+	js := &Executor{
 		OnUpdate: func(pod *corev1.Pod, status *werftv1.JobStatus) {},
 
 		Config:     config,
 		Client:     kubeClient,
 		KubeConfig: kubeConfig,
 
-		labels:      newLabelSetet(config.LabelPrefix),
-		waitingJobs: make(map[string]*waitingJob),
-	}, nil
+		labels:            newLabelSetet(config.LabelPrefix),
+		waitingJobs:       make(map[string]*waitingJob),
+		logListeners:      make(map[string]*logListener),
+		artifactTransfers: make(map[string]struct{}),
+		phases:            make(map[string]werftv1.JobPhase),
+		metrics:           newMetrics(),
+	}
+	js.execInPod = js.remoteExec
+	return js, nil
 }
 
 // Executor starts and watches jobs running in Kubernetes
@@ -94,21 +134,106 @@ type Executor struct {
 	Config     Config
 	KubeConfig *rest.Config
 
-	labels      labelSet
-	waitingJobs map[string]*waitingJob
-	mu          sync.RWMutex
+	// Artifacts stores the artifacts jobs pass to each other. Jobs with artifacts fail if it's nil.
+	Artifacts ArtifactStore
+
+	// Events records the lifecycle of jobs as Kubernetes events on their pods.
+	// Run sets up a recorder sending them to the Kubernetes API if it's nil.
+	Events record.EventRecorder
+
+	labels       labelSet
+	waitingJobs  map[string]*waitingJob
+	queue        []string
+	logListeners map[string]*logListener
+	mu           sync.RWMutex
+	// draining is true once werft shuts down. We don't start waiting or queued jobs anymore then,
+	// as werft restores them when it starts again.
+	draining bool
+
+	// queueMu serialises the decision whether a job can start or has to be queued
+	queueMu sync.Mutex
+
+	// cacheMu serialises the decision whether a job can mount its cache volume
+	cacheMu sync.Mutex
+
+	// artifactTransfers lists the pods whose artifacts we're transferring
+	artifactTransfers map[string]struct{}
+	// execInPod runs a command in a container of a job pod
+	execInPod func(pod, container string, cmd []string, stdin io.Reader, stdout io.Writer) error
+
+	// phases are the phases we last saw jobs in, so that we record each lifecycle event once
+	phases map[string]werftv1.JobPhase
+
+	metrics *metrics
+}
+
+type metrics struct {
+	PodsCreatedTotal         prometheus.Counter
+	PodCreationFailuresTotal prometheus.Counter
+	PodsDeletedTotal         prometheus.Counter
+	PodDeletionFailuresTotal prometheus.Counter
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		PodsCreatedTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "werft",
+			Subsystem: "executor",
+			Name:      "pods_created_total",
+			Help:      "Total amount of job pods the executor created.",
+		}),
+		PodCreationFailuresTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "werft",
+			Subsystem: "executor",
+			Name:      "pod_creation_failures_total",
+			Help:      "Total amount of job pods the executor failed to create.",
+		}),
+		PodsDeletedTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "werft",
+			Subsystem: "executor",
+			Name:      "pods_deleted_total",
+			Help:      "Total amount of job pods the executor deleted.",
+		}),
+		PodDeletionFailuresTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "werft",
+			Subsystem: "executor",
+			Name:      "pod_deletion_failures_total",
+			Help:      "Total amount of job pods the executor failed to delete.",
+		}),
+	}
 }
 
-// waitingJob is a job which doesn't run yet, but waits until it can start (e.g. based on time)
+// RegisterPrometheusMetrics registers the executor metrics on the registerer with MustRegister
+func (js *Executor) RegisterPrometheusMetrics(reg prometheus.Registerer) {
+	reg.MustRegister(
+		js.metrics.PodsCreatedTotal,
+		js.metrics.PodCreationFailuresTotal,
+		js.metrics.PodsDeletedTotal,
+		js.metrics.PodDeletionFailuresTotal,
+	)
+}
+
+// waitingJob is a job which doesn't run yet, but waits until it can start (e.g. based on time or a free slot)
 type waitingJob struct {
 	Cancel func(reason string)
 	Start  func()
 	Mutex  string
-	Status *werftv1.JobStatus
+	// Priority orders queued jobs, see WithPriority
+	Priority int
+	Status   *werftv1.JobStatus
+	// Pod is the pod we'll create once the job starts
+	Pod *corev1.Pod
+	// Needs are the jobs which have to succeed before this job can start
+	Needs map[string]struct{}
+	// Skip marks a job as skipped because one of the jobs it needs failed or was skipped
+	Skip func(reason string)
 }
 
 // Run starts the executor and returns immediately
 func (js *Executor) Run() {
+	if js.Events == nil {
+		js.Events = newEventRecorder(js.Client, js.Config.Namespace)
+	}
 	go js.monitorJobs()
 	go js.doHousekeeping()
 }
@@ -122,6 +247,13 @@ type startOptions struct {
 	CanReplay    bool
 	WaitUntil    time.Time
 	Sidecars     []string
+	Timeout      time.Duration
+	PodTTL       *time.Duration
+	Cache        *Cache
+	Needs        []string
+	Artifacts    *Artifacts
+	Priority     int
+	GracePeriod  time.Duration
 }
 
 // StartOpt configures a job at startup
@@ -188,6 +320,59 @@ func WithSidecars(names []string) StartOpt {
 	}
 }
 
+// WithTimeout overrides the total timeout of a job. A zero timeout uses the executor's default.
+func WithTimeout(timeout time.Duration) StartOpt {
+	return func(opts *startOptions) {
+		opts.Timeout = timeout
+	}
+}
+
+// WithPodTTL overrides how long the pod of a job is kept after the job has finished
+func WithPodTTL(ttl time.Duration) StartOpt {
+	return func(opts *startOptions) {
+		opts.PodTTL = &ttl
+	}
+}
+
+// WithCache mounts a persistent cache volume into the job's containers
+func WithCache(cache Cache) StartOpt {
+	return func(opts *startOptions) {
+		opts.Cache = &cache
+	}
+}
+
+// WithPriority orders the job in the queue: jobs with a higher priority start first,
+// jobs of equal priority in the order they were queued.
+func WithPriority(priority int) StartOpt {
+	return func(opts *startOptions) {
+		opts.Priority = priority
+	}
+}
+
+// WithGracePeriod gives the containers of a job time to stop when the job is stopped or times out, e.g. to run
+// their preStop hooks. Once the grace period has passed, the containers are killed and the job pod is deleted.
+func WithGracePeriod(gracePeriod time.Duration) StartOpt {
+	return func(opts *startOptions) {
+		opts.GracePeriod = gracePeriod
+	}
+}
+
+// WithNeeds makes a job wait until the jobs it needs have succeeded. The executor learns about finished jobs
+// through JobDone, hence jobs must start before the jobs they need finish.
+func WithNeeds(jobs []string) StartOpt {
+	return func(opts *startOptions) {
+		opts.Needs = jobs
+	}
+}
+
+// WithArtifacts collects the outputs of a job once it has succeeded and restores its inputs before it starts.
+// The job pod must contain the ArtifactsSidecar if the job has outputs, and the ArtifactsInit container if it has inputs.
+func WithArtifacts(artifacts Artifacts) StartOpt {
+	return func(opts *startOptions) {
+		opts.Artifacts = &artifacts
+	}
+}
+
 // Start starts a new job
 func (js *Executor) Start(podspec corev1.PodSpec, metadata werftv1.JobMetadata, options ...StartOpt) (status *werftv1.JobStatus, err error) {
 	opts := startOptions{
@@ -213,6 +398,27 @@ func (js *Executor) Start(podspec corev1.PodSpec, metadata werftv1.JobMetadata,
 	if len(opts.Sidecars) > 0 {
 		annotations[js.labels.AnnotationSidecars] = strings.Join(opts.Sidecars, " ")
 	}
+	if opts.Timeout > 0 {
+		annotations[js.labels.AnnotationTimeout] = opts.Timeout.String()
+	}
+	if opts.PodTTL != nil {
+		annotations[js.labels.AnnotationPodTTL] = opts.PodTTL.String()
+	}
+	if opts.Priority != 0 {
+		annotations[js.labels.AnnotationPriority] = strconv.Itoa(opts.Priority)
+	}
+	if opts.GracePeriod > 0 {
+		annotations[js.labels.AnnotationGracePeriod] = opts.GracePeriod.String()
+		gracePeriodSeconds := int64(math.Ceil(opts.GracePeriod.Seconds()))
+		podspec.TerminationGracePeriodSeconds = &gracePeriodSeconds
+	}
+	if opts.Artifacts != nil && (len(opts.Artifacts.Outputs) > 0 || len(opts.Artifacts.Inputs) > 0) {
+		artifacts, err := json.Marshal(opts.Artifacts)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot marshal artifacts: %w", err)
+		}
+		annotations[js.labels.AnnotationArtifacts] = string(artifacts)
+	}
 
 	metadata.Created = ptypes.TimestampNow()
 	mdjson, err := (&jsonpb.Marshaler{
@@ -243,38 +449,16 @@ func (js *Executor) Start(podspec corev1.PodSpec, metadata werftv1.JobMetadata,
 		opt(&poddesc)
 	}
 
-	mutexCancelationMsg := fmt.Sprintf("a newer job (%s) with the same mutex (%s) started", opts.JobName, opts.Mutex)
 	if opts.Mutex != "" {
-		labelMutex := js.labels.LabelMutex
-		poddesc.ObjectMeta.Labels[labelMutex] = opts.Mutex
+		mutexID := mutexLabelValue(opts.Mutex)
+		poddesc.ObjectMeta.Labels[js.labels.LabelMutex] = mutexID
 
 		// enforce mutex by marking all other jobs with the same mutex as failed
-		pods, err := js.Client.CoreV1().Pods(js.Config.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", labelMutex, opts.Mutex)})
+		msg := fmt.Sprintf("%s: a newer job (%s) with the same mutex (%s) started", ReasonSuperseded, opts.JobName, opts.Mutex)
+		err := js.cancelJobs(js.labels.LabelMutex, mutexID, func(wj *waitingJob) bool { return wj.Mutex == opts.Mutex }, msg)
 		if err != nil {
 			return nil, xerrors.Errorf("cannot enforce mutex: %w", err)
 		}
-		for _, pod := range pods.Items {
-			err := js.addAnnotation(pod.Name, map[string]string{
-				js.labels.AnnotationFailed: mutexCancelationMsg,
-			})
-			if err, ok := err.(*k8serr.StatusError); ok && err.ErrStatus.Code == http.StatusNotFound {
-				// if the pod is gone by now that's ok. The mutex was enfored alright.
-				continue
-			}
-			if err != nil {
-				return nil, xerrors.Errorf("cannot enforce mutex: %w", err)
-			}
-		}
-
-		// enforce mutex on all waiting jobs
-		js.mu.Lock()
-		for k, wj := range js.waitingJobs {
-			if wj.Mutex == opts.Mutex {
-				wj.Cancel(mutexCancelationMsg)
-				delete(js.waitingJobs, k)
-			}
-		}
-		js.mu.Unlock()
 	}
 
 	startJob := func() (*werftv1.JobStatus, error) {
@@ -283,20 +467,45 @@ func (js *Executor) Start(podspec corev1.PodSpec, metadata werftv1.JobMetadata,
 			log.Debugf("scheduling job\n%s", dbg)
 		}
 
+		if opts.Cache != nil {
+			// whether the cache is in use is decided when the job actually starts, as waiting and
+			// queued jobs might start much later
+			js.cacheMu.Lock()
+			defer js.cacheMu.Unlock()
+
+			err := js.mountCache(&poddesc, opts.Cache, opts.Sidecars)
+			if err != nil {
+				return nil, xerrors.Errorf("cannot mount cache: %w", err)
+			}
+		}
+
 		job, err := js.Client.CoreV1().Pods(js.Config.Namespace).Create(context.Background(), &poddesc, metav1.CreateOptions{})
 		if err != nil {
+			js.metrics.PodCreationFailuresTotal.Inc()
 			return nil, err
 		}
+		js.metrics.PodsCreatedTotal.Inc()
+		js.recordEvent(job, corev1.EventTypeNormal, EventReasonScheduled, fmt.Sprintf("job %s scheduled", opts.JobName))
 
 		return getStatus(job, js.labels)
 	}
 
+	if len(opts.Needs) > 0 {
+		return js.waitForNeeds(&poddesc, opts, func() (*werftv1.JobStatus, error) {
+			return js.schedule(&poddesc, opts, startJob)
+		})
+	}
+	return js.schedule(&poddesc, opts, startJob)
+}
+
+// schedule starts a job once its time has come, as far as the concurrency limits permit
+func (js *Executor) schedule(poddesc *corev1.Pod, opts startOptions, startJob func() (*werftv1.JobStatus, error)) (*werftv1.JobStatus, error) {
 	// Register the go routine to start the job when its time comes.
 	// Werft will tell us again about this job upon startup (pass set of waiting jobs into NewExecutor).
 	// When a waiting job is canceled manually or by a mutex it's deleted from the store.
 	log.WithField("wait-until", opts.WaitUntil).Debug("waiting until")
 	if !opts.WaitUntil.IsZero() && opts.WaitUntil.After(time.Now()) {
-		status, err := getStatus(&poddesc, js.labels)
+		status, err := getStatus(poddesc, js.labels)
 		if err != nil {
 			return nil, err
 		}
@@ -309,15 +518,20 @@ func (js *Executor) Start(podspec corev1.PodSpec, metadata werftv1.JobMetadata,
 			Start:  func() { close(startChan) },
 			Mutex:  opts.Mutex,
 			Status: status,
+			Pod:    poddesc,
 		}
 		js.mu.Unlock()
 
 		run := func() {
 			js.mu.Lock()
+			if js.draining {
+				js.mu.Unlock()
+				return
+			}
 			delete(js.waitingJobs, opts.JobName)
 			js.mu.Unlock()
 
-			startJob()
+			js.startOrQueue(poddesc, opts, startJob)
 		}
 
 		go func() {
@@ -332,7 +546,7 @@ func (js *Executor) Start(podspec corev1.PodSpec, metadata werftv1.JobMetadata,
 				status.Phase = werftv1.JobPhase_PHASE_DONE
 				status.Conditions.Success = false
 				status.Details = reason
-				js.OnUpdate(&poddesc, status)
+				js.OnUpdate(poddesc, status)
 			}
 		}()
 
@@ -341,12 +555,362 @@ func (js *Executor) Start(podspec corev1.PodSpec, metadata werftv1.JobMetadata,
 		// normally we'd see a Kubernetes event as the job would start immediately. This Kubernetes event would propagate
 		// throughout the system. However, waiting jobs do not produce Kubernetes events right away, hence we have to
 		// call OnUpdate ourselves.
-		js.OnUpdate(&poddesc, status)
+		js.OnUpdate(poddesc, status)
 
 		return status, nil
 	}
 
-	return startJob()
+	return js.startOrQueue(poddesc, opts, startJob)
+}
+
+// waitForNeeds registers a job which starts once all jobs it needs have succeeded, see JobDone
+func (js *Executor) waitForNeeds(poddesc *corev1.Pod, opts startOptions, schedule func() (*werftv1.JobStatus, error)) (*werftv1.JobStatus, error) {
+	status, err := getStatus(poddesc, js.labels)
+	if err != nil {
+		return nil, err
+	}
+	status.Phase = werftv1.JobPhase_PHASE_WAITING
+	status.Details = fmt.Sprintf("waiting for %s", strings.Join(opts.Needs, ", "))
+
+	needs := make(map[string]struct{}, len(opts.Needs))
+	for _, n := range opts.Needs {
+		needs[n] = struct{}{}
+	}
+	finish := func(success, skipped bool, reason string) {
+		status.Phase = werftv1.JobPhase_PHASE_DONE
+		status.Conditions.Success = success
+		status.Conditions.Skipped = skipped
+		status.Details = reason
+		status.Metadata.Finished = ptypes.TimestampNow()
+		js.OnUpdate(poddesc, status)
+	}
+
+	js.mu.Lock()
+	js.waitingJobs[opts.JobName] = &waitingJob{
+		// Cancel is called while holding mu, hence must not call OnUpdate synchronously
+		Cancel: func(reason string) {
+			log.WithField("name", opts.JobName).Debug("canceled this job waiting for others")
+			go finish(false, false, reason)
+		},
+		Start: func() {
+			_, err := schedule()
+			if err != nil {
+				log.WithError(err).WithField("name", opts.JobName).Error("cannot start job after the jobs it needs")
+				finish(false, false, fmt.Sprintf("cannot start job: %v", err))
+			}
+		},
+		Skip: func(reason string) {
+			log.WithField("name", opts.JobName).Debug("skipped this job waiting for others")
+			finish(true, true, reason)
+		},
+		Mutex:  opts.Mutex,
+		Status: status,
+		Pod:    poddesc,
+		Needs:  needs,
+	}
+	js.mu.Unlock()
+
+	// like waiting jobs, jobs waiting for others do not produce Kubernetes events, hence we call OnUpdate ourselves
+	js.OnUpdate(poddesc, status)
+
+	return status, nil
+}
+
+// JobDone tells the executor that a job has finished. Jobs which need it start once all jobs they need
+// have succeeded, and are skipped if it failed or was skipped itself.
+func (js *Executor) JobDone(status *werftv1.JobStatus) {
+	if status == nil || status.Phase != werftv1.JobPhase_PHASE_DONE {
+		return
+	}
+	succeeded := status.Conditions != nil && status.Conditions.Success && !status.Conditions.Skipped
+
+	var start, skip []*waitingJob
+	js.mu.Lock()
+	if js.draining {
+		// werft tells us about the jobs they need again once it's back
+		js.mu.Unlock()
+		return
+	}
+	for name, wj := range js.waitingJobs {
+		if _, ok := wj.Needs[status.Name]; !ok {
+			continue
+		}
+		delete(wj.Needs, status.Name)
+		if succeeded && len(wj.Needs) > 0 {
+			continue
+		}
+
+		delete(js.waitingJobs, name)
+		if succeeded {
+			start = append(start, wj)
+		} else {
+			skip = append(skip, wj)
+		}
+	}
+	js.mu.Unlock()
+
+	reason := fmt.Sprintf("skipped: needed job %s failed", status.Name)
+	if status.Conditions != nil && status.Conditions.Skipped {
+		reason = fmt.Sprintf("skipped: needed job %s was skipped", status.Name)
+	}
+	for _, wj := range skip {
+		wj.Skip(reason)
+	}
+	for _, wj := range start {
+		log.WithField("name", wj.Status.Name).WithField("after", status.Name).Debug("starting job after the jobs it needs")
+		wj.Start()
+	}
+}
+
+// Drain stops starting waiting and queued jobs, e.g. because werft shuts down, and returns their status.
+// Those jobs keep waiting until werft restores them from the job store when it starts again.
+func (js *Executor) Drain() []werftv1.JobStatus {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	js.draining = true
+
+	res := make([]werftv1.JobStatus, 0, len(js.waitingJobs))
+	for _, wj := range js.waitingJobs {
+		res = append(res, *wj.Status)
+	}
+	return res
+}
+
+// cancelJobs marks all jobs which aren't done yet and carry the label as failed. Waiting and queued jobs
+// for which matchWaiting returns true are canceled.
+func (js *Executor) cancelJobs(label, value string, matchWaiting func(wj *waitingJob) bool, reason string) error {
+	pods, err := js.Client.CoreV1().Pods(js.Config.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", label, value)})
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods.Items {
+		status, err := getStatus(&pod, js.labels)
+		if err == nil && (status.Phase == werftv1.JobPhase_PHASE_DONE || status.Phase == werftv1.JobPhase_PHASE_CLEANUP) {
+			// this job is done already and must keep its outcome
+			continue
+		}
+
+		err = js.addAnnotation(pod.Name, map[string]string{
+			js.labels.AnnotationFailed: reason,
+		})
+		if err, ok := err.(*k8serr.StatusError); ok && err.ErrStatus.Code == http.StatusNotFound {
+			// if the pod is gone by now that's ok. The job was canceled alright.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	js.mu.Lock()
+	for k, wj := range js.waitingJobs {
+		if matchWaiting(wj) {
+			wj.Cancel(reason)
+			delete(js.waitingJobs, k)
+		}
+	}
+	js.mu.Unlock()
+
+	return nil
+}
+
+// mutexLabelValue turns a mutex into a valid label value. Mutexes which are valid label values already
+// are used as they are, so that jobs started by earlier versions of werft keep their mutex.
+func mutexLabelValue(mutex string) string {
+	if len(validation.IsValidLabelValue(mutex)) == 0 {
+		return mutex
+	}
+	return fmt.Sprintf("%x", sha1.Sum([]byte(mutex)))
+}
+
+// startOrQueue starts a job if the concurrency limits permit, or queues it otherwise
+func (js *Executor) startOrQueue(poddesc *corev1.Pod, opts startOptions, startJob func() (*werftv1.JobStatus, error)) (*werftv1.JobStatus, error) {
+	if js.Config.MaxConcurrentJobs == 0 && js.Config.MaxConcurrentJobsPerRepo == 0 {
+		return startJob()
+	}
+
+	status, err := getStatus(poddesc, js.labels)
+	if err != nil {
+		return nil, err
+	}
+
+	js.queueMu.Lock()
+	defer js.queueMu.Unlock()
+
+	// We queue every job and let startQueuedJobs decide if it can start right away.
+	// This way jobs which were queued earlier take precedence.
+	var (
+		started       bool
+		startedStatus *werftv1.JobStatus
+		startErr      error
+	)
+	js.mu.Lock()
+	js.waitingJobs[status.Name] = &waitingJob{
+		// Cancel is called while holding mu, hence must not call OnUpdate synchronously
+		Cancel: func(reason string) {
+			log.WithField("name", status.Name).Debug("canceled this queued job")
+			status.Phase = werftv1.JobPhase_PHASE_DONE
+			status.Conditions.Success = false
+			status.Details = reason
+			go js.OnUpdate(poddesc, status)
+		},
+		Start: func() {
+			started = true
+			startedStatus, startErr = startJob()
+			if startErr != nil {
+				log.WithError(startErr).WithField("name", status.Name).Error("cannot start queued job")
+			}
+		},
+		Mutex:    opts.Mutex,
+		Priority: opts.Priority,
+		Status:   status,
+		Pod:      poddesc,
+	}
+	js.queue = append(js.queue, status.Name)
+	js.mu.Unlock()
+
+	err = js.startQueuedJobsLocked()
+	if err != nil {
+		js.mu.Lock()
+		delete(js.waitingJobs, status.Name)
+		js.mu.Unlock()
+		return nil, xerrors.Errorf("cannot enforce concurrency limits: %w", err)
+	}
+	if started {
+		return startedStatus, startErr
+	}
+
+	status.Phase = werftv1.JobPhase_PHASE_QUEUED
+	status.Details = "waiting for a free slot as too many jobs are running"
+	log.WithField("name", status.Name).Info("too many jobs running - queueing job")
+
+	// like waiting jobs, queued jobs do not produce Kubernetes events, hence we call OnUpdate ourselves
+	js.OnUpdate(poddesc, status)
+
+	return status, nil
+}
+
+// startQueuedJobs starts queued jobs by priority and in the order they were queued, as far as the concurrency limits permit
+func (js *Executor) startQueuedJobs() {
+	js.queueMu.Lock()
+	defer js.queueMu.Unlock()
+
+	err := js.startQueuedJobsLocked()
+	if err != nil {
+		log.WithError(err).Warn("cannot start queued jobs")
+	}
+}
+
+// startQueuedJobsLocked starts queued jobs. Callers must hold queueMu.
+func (js *Executor) startQueuedJobsLocked() error {
+	js.mu.RLock()
+	queueLen, draining := len(js.queue), js.draining
+	js.mu.RUnlock()
+	if queueLen == 0 || draining {
+		return nil
+	}
+
+	running, err := js.countRunningJobs()
+	if err != nil {
+		return err
+	}
+
+	js.mu.Lock()
+	// js.queue is in the order jobs were queued, which the stable sort keeps for jobs of equal priority
+	sort.SliceStable(js.queue, func(i, j int) bool {
+		return js.queuedPriority(js.queue[i]) > js.queuedPriority(js.queue[j])
+	})
+	var (
+		queue []string
+		start []*waitingJob
+	)
+	for _, name := range js.queue {
+		qj, ok := js.waitingJobs[name]
+		if !ok {
+			// job was canceled in the meantime
+			continue
+		}
+		if !running.HasCapacity(js.Config, qj.Status.Metadata) {
+			queue = append(queue, name)
+			continue
+		}
+
+		running.Add(qj.Status.Metadata)
+		delete(js.waitingJobs, name)
+		start = append(start, qj)
+	}
+	js.queue = queue
+	js.mu.Unlock()
+
+	for _, qj := range start {
+		log.WithField("name", qj.Status.Name).Debug("starting queued job")
+		qj.Start()
+	}
+	return nil
+}
+
+// queuedPriority returns the priority of a queued job. Callers must hold mu.
+func (js *Executor) queuedPriority(name string) int {
+	qj, ok := js.waitingJobs[name]
+	if !ok {
+		return 0
+	}
+	return qj.Priority
+}
+
+// runningJobs counts the jobs currently running, overall and per repository
+type runningJobs struct {
+	Total   int
+	PerRepo map[string]int
+}
+
+// HasCapacity returns true if another job for this repository can start given the limits in the config
+func (r *runningJobs) HasCapacity(cfg Config, md *werftv1.JobMetadata) bool {
+	if cfg.MaxConcurrentJobs > 0 && r.Total >= cfg.MaxConcurrentJobs {
+		return false
+	}
+	if cfg.MaxConcurrentJobsPerRepo > 0 && r.PerRepo[repoKey(md)] >= cfg.MaxConcurrentJobsPerRepo {
+		return false
+	}
+	return true
+}
+
+// Add counts another running job
+func (r *runningJobs) Add(md *werftv1.JobMetadata) {
+	r.Total++
+	r.PerRepo[repoKey(md)]++
+}
+
+func repoKey(md *werftv1.JobMetadata) string {
+	if md == nil || md.Repository == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/%s", md.Repository.Host, md.Repository.Owner, md.Repository.Repo)
+}
+
+// countRunningJobs counts all jobs which have a pod and are not done yet
+func (js *Executor) countRunningJobs() (*runningJobs, error) {
+	pods, err := js.Client.CoreV1().Pods(js.Config.Namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=true", js.labels.LabelWerftMarker),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &runningJobs{PerRepo: make(map[string]int)}
+	for _, pod := range pods.Items {
+		status, err := getStatus(&pod, js.labels)
+		if err != nil {
+			log.WithError(err).WithField("name", pod.Name).Warn("cannot compute status")
+			continue
+		}
+		if status.Phase == werftv1.JobPhase_PHASE_DONE || status.Phase == werftv1.JobPhase_PHASE_CLEANUP {
+			continue
+		}
+		res.Add(status.Metadata)
+	}
+	return res, nil
 }
 
 func (js *Executor) monitorJobs() {
@@ -390,32 +954,164 @@ func (js *Executor) handleJobEvent(evttpe watch.EventType, obj *corev1.Pod) {
 	}
 
 	js.OnUpdate(obj, status)
+	js.recordPhaseChange(obj, status)
+	if evttpe == watch.Deleted {
+		js.forgetPhase(status.Name)
+	}
 	err = js.actOnUpdate(status, obj)
 	if err != nil {
 		log.WithError(err).WithField("name", obj.Name).Error("cannot act on status update")
 		return
 	}
+
+	if evttpe == watch.Deleted || status.Phase == werftv1.JobPhase_PHASE_DONE || status.Phase == werftv1.JobPhase_PHASE_CLEANUP {
+		// this job no longer takes up a slot
+		go js.startQueuedJobs()
+	}
 }
 
 func (js *Executor) actOnUpdate(status *werftv1.JobStatus, obj *corev1.Pod) error {
-	if status.Phase == werftv1.JobPhase_PHASE_DONE {
-		gracePeriod := int64(5)
-		policy := metav1.DeletePropagationForeground
+	if restoringArtifacts(obj, js.labels) {
+		js.transferArtifacts(obj, restoringArtifacts, js.restoreArtifacts)
+	}
+	if status.Phase == werftv1.JobPhase_PHASE_RUNNING && status.Details == collectingArtifactsDetails {
+		js.transferArtifacts(obj, collectingArtifacts, js.collectArtifacts)
+	}
+	if status.Phase != werftv1.JobPhase_PHASE_DONE {
+		return nil
+	}
 
-		err := js.Client.CoreV1().Pods(js.Config.Namespace).Delete(context.Background(), obj.Name, metav1.DeleteOptions{
-			GracePeriodSeconds: &gracePeriod,
-			PropagationPolicy:  &policy,
+	// Sidecars keep running after the job's containers have finished. We stop them first, so that
+	// their logs end and the pod can be kept until its TTL expires.
+	if sidecarsRunning(obj, js.labels) {
+		return js.stopSidecars(obj.Name)
+	}
+
+	// Pods whose containers still run (e.g. because the job was stopped) are deleted right away.
+	// All others are kept around until their TTL expires.
+	if ttl := getPodTTL(obj, js.labels, js.podTTL()); ttl > 0 && containersTerminated(obj) {
+		if _, ok := getFinished(obj, js.labels); ok {
+			return nil
+		}
+		return js.addAnnotation(obj.Name, map[string]string{
+			js.labels.AnnotationFinished: time.Now().Format(time.RFC3339),
 		})
-		if err != nil {
-			log.WithError(err).WithField("name", obj.Name).Error("cannot delete job pod")
+	}
+
+	if containersTerminated(obj) {
+		// waiting for the logs to drain can take a while - don't block the event loop
+		go js.deleteJob(status.Name, obj.Name, true, defaultGracePeriod)
+	} else {
+		js.deleteJob(status.Name, obj.Name, false, getGracePeriod(obj, js.labels, defaultGracePeriod))
+	}
+
+	// TODO: clean up workspace content
+
+	return nil
+}
+
+// podTTL returns the server-wide pod TTL
+func (js *Executor) podTTL() time.Duration {
+	if js.Config.PodTTL == nil {
+		return 0
+	}
+	return js.Config.PodTTL.Duration
+}
+
+// deleteJob deletes the pod of a job and all config maps and secrets labeled with the job's name.
+// If drain is true, we wait for the job's logs to be forwarded first. Only do this if all containers
+// have terminated, otherwise their logs never end. The pod's containers get gracePeriod to stop,
+// if the pod still exists after that it's deleted forcefully.
+func (js *Executor) deleteJob(name, podName string, drain bool, gracePeriod time.Duration) {
+	js.mu.RLock()
+	ll, ok := js.logListeners[name]
+	js.mu.RUnlock()
+	if ok && drain {
+		ctx, cancel := context.WithTimeout(context.Background(), logDrainTimeout)
+		if !ll.Drain(ctx) {
+			log.WithField("name", name).Warn("logs were not forwarded completely before deleting the job pod")
 		}
+		cancel()
+	}
 
-		// TODO: clean up workspace content
+	var (
+		ctx    = context.Background()
+		client = js.Client.CoreV1()
+		opts   = metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", js.labels.LabelJobName, name)}
+	)
+	cms, err := client.ConfigMaps(js.Config.Namespace).List(ctx, opts)
+	if err != nil {
+		log.WithError(err).WithField("name", name).Warn("cannot list job config maps")
+	} else {
+		for _, cm := range cms.Items {
+			err = client.ConfigMaps(js.Config.Namespace).Delete(ctx, cm.Name, metav1.DeleteOptions{})
+			if err != nil && !k8serr.IsNotFound(err) {
+				log.WithError(err).WithField("name", name).WithField("configMap", cm.Name).Warn("cannot delete job config map")
+			}
+		}
+	}
+	secrets, err := client.Secrets(js.Config.Namespace).List(ctx, opts)
+	if err != nil {
+		log.WithError(err).WithField("name", name).Warn("cannot list job secrets")
+	} else {
+		for _, secret := range secrets.Items {
+			err = client.Secrets(js.Config.Namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{})
+			if err != nil && !k8serr.IsNotFound(err) {
+				log.WithError(err).WithField("name", name).WithField("secret", secret.Name).Warn("cannot delete job secret")
+			}
+		}
+	}
 
-		return nil
+	gracePeriodSeconds := int64(math.Ceil(gracePeriod.Seconds()))
+	policy := metav1.DeletePropagationForeground
+	err = client.Pods(js.Config.Namespace).Delete(ctx, podName, metav1.DeleteOptions{
+		GracePeriodSeconds: &gracePeriodSeconds,
+		PropagationPolicy:  &policy,
+	})
+	if err != nil && !k8serr.IsNotFound(err) {
+		js.metrics.PodDeletionFailuresTotal.Inc()
+		log.WithError(err).WithField("name", podName).Error("cannot delete job pod")
+	} else if err == nil {
+		js.metrics.PodsDeletedTotal.Inc()
+		js.recordEvent(js.podRef(podName), corev1.EventTypeNormal, EventReasonCleanedUp, fmt.Sprintf("job %s cleaned up", name))
+		time.AfterFunc(gracePeriod, func() { js.forceDeletePod(podName) })
 	}
+	js.forgetPhase(name)
 
-	return nil
+	js.mu.Lock()
+	if js.logListeners[name] == ll {
+		delete(js.logListeners, name)
+	}
+	js.mu.Unlock()
+}
+
+// forceDeletePod deletes a job pod which is still terminating without waiting for its containers to stop,
+// e.g. because they ignore SIGTERM or their node is gone
+func (js *Executor) forceDeletePod(podName string) {
+	pods := js.Client.CoreV1().Pods(js.Config.Namespace)
+	pod, err := pods.Get(context.Background(), podName, metav1.GetOptions{})
+	if k8serr.IsNotFound(err) {
+		return
+	}
+	if err != nil {
+		log.WithError(err).WithField("name", podName).Warn("cannot get job pod")
+		return
+	}
+	if pod.DeletionTimestamp == nil {
+		// this is a new pod of the same name
+		return
+	}
+
+	log.WithField("name", podName).Info("job pod outlived its grace period - deleting it forcefully")
+	gracePeriodSeconds := int64(0)
+	err = pods.Delete(context.Background(), podName, metav1.DeleteOptions{
+		GracePeriodSeconds: &gracePeriodSeconds,
+		Preconditions:      metav1.NewUIDPreconditions(string(pod.UID)),
+	})
+	if err != nil && !k8serr.IsNotFound(err) {
+		js.metrics.PodDeletionFailuresTotal.Inc()
+		log.WithError(err).WithField("name", podName).Error("cannot force-delete job pod")
+	}
 }
 
 func (js *Executor) writeEventTraceLog(status *werftv1.JobStatus, obj *corev1.Pod) {
@@ -453,54 +1149,119 @@ func (js *Executor) writeEventTraceLog(status *werftv1.JobStatus, obj *corev1.Po
 
 // Logs provides the log output of a running job. If the job is unknown, nil is returned.
 func (js *Executor) Logs(name string) io.Reader {
-	return listenToLogs(js.Client, name, js.Config.Namespace, js.labels)
+	ll := listenToLogs(js.Client, name, js.Config.Namespace, js.labels)
+
+	js.mu.Lock()
+	js.logListeners[name] = ll
+	js.mu.Unlock()
+
+	return ll.out
 }
 
 func (js *Executor) doHousekeeping() {
 	tick := time.NewTicker(js.Config.JobPrepTimeout.Duration / 2)
 	for {
-		// check our state and watch for non-existent jobs/events that we missed
-		pods, err := js.Client.CoreV1().Pods(js.Config.Namespace).List(context.Background(), metav1.ListOptions{
-			LabelSelector: fmt.Sprintf("%s=true", js.labels.LabelWerftMarker),
-		})
+		err := js.enforceTimeouts(time.Now())
 		if err != nil {
 			log.WithError(err).Warn("cannot perform housekeeping")
-			<-tick.C
+		}
+		err = js.collectGarbage(time.Now())
+		if err != nil {
+			log.WithError(err).Warn("cannot perform housekeeping")
+		}
+		js.startQueuedJobs()
+
+		<-tick.C
+	}
+}
+
+// enforceTimeouts fails all jobs which have exceeded their preparation or total timeout at the time now.
+// Once a job is marked as failed, it's done and actOnUpdate deletes its pod.
+func (js *Executor) enforceTimeouts(now time.Time) error {
+	// check our state and watch for non-existent jobs/events that we missed
+	pods, err := js.Client.CoreV1().Pods(js.Config.Namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=true", js.labels.LabelWerftMarker),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, pod := range pods.Items {
+		if _, failed := pod.Annotations[js.labels.AnnotationFailed]; failed {
 			continue
 		}
 
-		for _, pod := range pods.Items {
-			status, err := getStatus(&pod, js.labels)
-			if err != nil {
-				log.WithError(err).WithField("name", pod.Name).Warn("cannot perform housekeeping")
-				continue
-			}
+		status, err := getStatus(&pod, js.labels)
+		if err != nil {
+			log.WithError(err).WithField("name", pod.Name).Warn("cannot perform housekeeping")
+			continue
+		}
+		if status.Phase == werftv1.JobPhase_PHASE_DONE || status.Phase == werftv1.JobPhase_PHASE_CLEANUP {
+			continue
+		}
 
-			created, err := ptypes.Timestamp(status.Metadata.Created)
-			if err != nil {
-				log.WithError(err).WithField("name", pod.Name).Warn("cannot perform housekeeping")
-				continue
-			}
+		created, err := ptypes.Timestamp(status.Metadata.Created)
+		if err != nil {
+			log.WithError(err).WithField("name", pod.Name).Warn("cannot perform housekeeping")
+			continue
+		}
 
-			var ttl time.Duration
-			if status.Phase == werftv1.JobPhase_PHASE_PREPARING {
-				ttl = js.Config.JobPrepTimeout.Duration
-			} else {
-				ttl = js.Config.JobTotalTimeout.Duration
-			}
-			if time.Since(created) < ttl {
-				continue
-			}
+		var ttl time.Duration
+		if status.Phase == werftv1.JobPhase_PHASE_PREPARING {
+			ttl = js.Config.JobPrepTimeout.Duration
+		} else {
+			ttl = getTimeout(&pod, js.labels, js.Config.JobTotalTimeout.Duration)
+		}
+		if now.Sub(created) < ttl {
+			continue
+		}
 
-			msg := fmt.Sprintf("job timed out during %s", strings.TrimPrefix(strings.ToLower(status.Phase.String()), "phase_"))
-			log.WithField("job", status.Name).Info(msg)
-			err = js.addAnnotation(pod.Name, map[string]string{
-				js.labels.AnnotationFailed: msg,
-			})
+		msg := fmt.Sprintf("job timed out during %s after %s", strings.TrimPrefix(strings.ToLower(status.Phase.String()), "phase_"), ttl)
+		log.WithField("job", status.Name).Info(msg)
+		err = js.addAnnotation(pod.Name, map[string]string{
+			js.labels.AnnotationFailed:   msg,
+			js.labels.AnnotationTimedOut: "true",
+		})
+		if err != nil {
+			log.WithError(err).WithField("name", pod.Name).Warn("cannot mark job as timed out")
+			continue
 		}
+		js.recordEvent(&pod, corev1.EventTypeWarning, EventReasonTimedOut, msg)
+	}
 
-		<-tick.C
+	return nil
+}
+
+// collectGarbage deletes the pods of all jobs which finished longer than their pod TTL ago at the time now
+func (js *Executor) collectGarbage(now time.Time) error {
+	pods, err := js.Client.CoreV1().Pods(js.Config.Namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=true", js.labels.LabelWerftMarker),
+	})
+	if err != nil {
+		return err
 	}
+
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		finished, ok := getFinished(&pod, js.labels)
+		if !ok {
+			continue
+		}
+		if now.Sub(finished) < getPodTTL(&pod, js.labels, js.podTTL()) {
+			continue
+		}
+
+		name, ok := getJobName(&pod, js.labels)
+		if !ok {
+			continue
+		}
+		log.WithField("job", name).Debug("pod TTL expired - deleting job pod")
+		js.deleteJob(name, pod.Name, true, defaultGracePeriod)
+	}
+
+	return nil
 }
 
 // errNotFound is returned by getJobPod if no running job was found
@@ -553,6 +1314,64 @@ func (js *Executor) Stop(name, reason string) error {
 	return nil
 }
 
+// SetAnnotations replaces the annotations of a job which has not finished yet, s.t. its subsequent status updates
+// carry them. Jobs without a pod, e.g. because they're done and the pod is gone, are left untouched.
+func (js *Executor) SetAnnotations(name string, annotations []*werftv1.Annotation) error {
+	js.mu.Lock()
+	if wj, ok := js.waitingJobs[name]; ok {
+		err := setMetadataAnnotations(wj.Pod, js.labels, annotations)
+		if err == nil {
+			wj.Status.Metadata.Annotations = annotations
+		}
+		js.mu.Unlock()
+		return err
+	}
+	js.mu.Unlock()
+
+	pod, err := js.getJobPod(name)
+	if xerrors.Is(err, errNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	podname := pod.Name
+
+	client := js.Client.CoreV1().Pods(js.Config.Namespace)
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		pod, err := client.Get(context.Background(), podname, metav1.GetOptions{})
+		if err != nil {
+			return xerrors.Errorf("cannot find job pod %s: %w", podname, err)
+		}
+
+		err = setMetadataAnnotations(pod, js.labels, annotations)
+		if err != nil {
+			return err
+		}
+
+		_, err = client.Update(context.Background(), pod, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// setMetadataAnnotations replaces the annotations in the metadata of a job pod
+func setMetadataAnnotations(pod *corev1.Pod, labels labelSet, annotations []*werftv1.Annotation) error {
+	var md werftv1.JobMetadata
+	err := jsonpb.UnmarshalString(pod.Annotations[labels.AnnotationMetadata], &md)
+	if err != nil {
+		return xerrors.Errorf("cannot unmarshal metadata: %w", err)
+	}
+	md.Annotations = annotations
+	mdjson, err := (&jsonpb.Marshaler{
+		EnumsAsInts: true,
+	}).MarshalToString(&md)
+	if err != nil {
+		return xerrors.Errorf("cannot marshal metadata: %w", err)
+	}
+	pod.Annotations[labels.AnnotationMetadata] = mdjson
+	return nil
+}
+
 // GetKnownJobs returns a list of all jobs the executor knows about
 func (js *Executor) GetKnownJobs() (jobs []werftv1.JobStatus, err error) {
 	js.mu.RLock()
@@ -617,6 +1436,28 @@ func (js *Executor) RegisterResult(jobname string, res *werftv1.JobResult) error
 	return err
 }
 
+// stopSidecars stops all containers of a pod without deleting it. Kubernetes cannot stop individual containers,
+// but it stops all containers of a pod once its active deadline is exceeded.
+func (js *Executor) stopSidecars(podname string) error {
+	client := js.Client.CoreV1().Pods(js.Config.Namespace)
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		pod, err := client.Get(context.Background(), podname, metav1.GetOptions{})
+		if err != nil {
+			return xerrors.Errorf("cannot find job pod %s: %w", podname, err)
+		}
+
+		deadline := int64(1)
+		if ads := pod.Spec.ActiveDeadlineSeconds; ads != nil && *ads == deadline {
+			// we've stopped the sidecars already
+			return nil
+		}
+		pod.Spec.ActiveDeadlineSeconds = &deadline
+
+		_, err = client.Update(context.Background(), pod, metav1.UpdateOptions{})
+		return err
+	})
+}
+
 // addAnnotation adds annotations to a pod
 func (js *Executor) addAnnotation(podname string, annotations map[string]string) error {
 	client := js.Client.CoreV1().Pods(js.Config.Namespace)
@@ -0,0 +1,1468 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	werftv1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+func newTestExecutor() *Executor {
+	return &Executor{
+		OnUpdate: func(pod *corev1.Pod, status *werftv1.JobStatus) {},
+		Config: Config{
+			Namespace:       "default",
+			JobPrepTimeout:  &Duration{10 * time.Minute},
+			JobTotalTimeout: &Duration{60 * time.Minute},
+		},
+		Client:            fake.NewSimpleClientset(),
+		labels:            newLabelSetet(""),
+		waitingJobs:       make(map[string]*waitingJob),
+		logListeners:      make(map[string]*logListener),
+		artifactTransfers: make(map[string]struct{}),
+		phases:            make(map[string]werftv1.JobPhase),
+		metrics:           newMetrics(),
+	}
+}
+
+func TestEnforceTimeouts(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Timeout  time.Duration
+		Elapsed  time.Duration
+		TimedOut bool
+	}{
+		{Name: "within default", Elapsed: 30 * time.Minute},
+		{Name: "exceeds default", Elapsed: 61 * time.Minute, TimedOut: true},
+		{Name: "within job timeout", Timeout: 2 * time.Hour, Elapsed: 90 * time.Minute},
+		{Name: "exceeds job timeout", Timeout: 5 * time.Minute, Elapsed: 6 * time.Minute, TimedOut: true},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var (
+				js      = newTestExecutor()
+				updates []*werftv1.JobStatus
+			)
+			js.OnUpdate = func(pod *corev1.Pod, status *werftv1.JobStatus) {
+				updates = append(updates, status)
+			}
+
+			status, err := js.Start(corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "build", Image: "alpine"}},
+			}, werftv1.JobMetadata{}, WithName("test-job"), WithTimeout(test.Timeout))
+			if err != nil {
+				t.Fatalf("cannot start job: %v", err)
+			}
+
+			// pretend the job is running
+			pods := js.Client.CoreV1().Pods(js.Config.Namespace)
+			pod, err := pods.Get(context.Background(), status.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("cannot get job pod: %v", err)
+			}
+			pod.Status.Phase = corev1.PodRunning
+			_, err = pods.UpdateStatus(context.Background(), pod, metav1.UpdateOptions{})
+			if err != nil {
+				t.Fatalf("cannot update job pod: %v", err)
+			}
+
+			err = js.enforceTimeouts(time.Now().Add(test.Elapsed))
+			if err != nil {
+				t.Fatalf("cannot enforce timeouts: %v", err)
+			}
+
+			// the watch in monitorJobs would tell us about the change
+			pod, err = pods.Get(context.Background(), status.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("cannot get job pod: %v", err)
+			}
+			js.handleJobEvent(watch.Modified, pod)
+
+			if len(updates) == 0 {
+				t.Fatal("expected a status update")
+			}
+			act := updates[len(updates)-1]
+			_, err = pods.Get(context.Background(), status.Name, metav1.GetOptions{})
+			if !test.TimedOut {
+				if act.Phase == werftv1.JobPhase_PHASE_DONE {
+					t.Errorf("job should not be done: %s", act.Details)
+				}
+				if err != nil {
+					t.Errorf("job pod should still exist: %v", err)
+				}
+				return
+			}
+
+			if act.Phase != werftv1.JobPhase_PHASE_DONE {
+				t.Errorf("expected phase %s, actual %s", werftv1.JobPhase_PHASE_DONE, act.Phase)
+			}
+			if act.Conditions.Success {
+				t.Error("timed out job should not succeed")
+			}
+			if !act.Conditions.TimedOut {
+				t.Error("job should be marked as timed out")
+			}
+			if act.Details == "" {
+				t.Error("job should explain the timeout")
+			}
+			if !k8serr.IsNotFound(err) {
+				t.Errorf("job pod should have been deleted, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPodTTL(t *testing.T) {
+	ttl := func(d time.Duration) *time.Duration { return &d }
+	tests := []struct {
+		Name      string
+		Default   time.Duration
+		JobTTL    *time.Duration
+		Elapsed   time.Duration
+		KeepsPod  bool
+		DeletesAt time.Duration
+	}{
+		{Name: "no ttl"},
+		{Name: "default ttl", Default: time.Hour, KeepsPod: true, DeletesAt: time.Hour},
+		{Name: "job ttl", JobTTL: ttl(10 * time.Minute), KeepsPod: true, DeletesAt: 10 * time.Minute},
+		{Name: "job overrides default", Default: time.Hour, JobTTL: ttl(0)},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			js := newTestExecutor()
+			if test.Default > 0 {
+				js.Config.PodTTL = &Duration{test.Default}
+			}
+			opts := []StartOpt{WithName("test-job")}
+			if test.JobTTL != nil {
+				opts = append(opts, WithPodTTL(*test.JobTTL))
+			}
+
+			status, err := js.Start(corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "build", Image: "alpine"}},
+			}, werftv1.JobMetadata{}, opts...)
+			if err != nil {
+				t.Fatalf("cannot start job: %v", err)
+			}
+			_, err = js.Client.CoreV1().ConfigMaps(js.Config.Namespace).Create(context.Background(), &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "test-job-config",
+					Labels: map[string]string{js.labels.LabelJobName: status.Name},
+				},
+			}, metav1.CreateOptions{})
+			if err != nil {
+				t.Fatalf("cannot create config map: %v", err)
+			}
+
+			// finish the job
+			pods := js.Client.CoreV1().Pods(js.Config.Namespace)
+			pod, err := pods.Get(context.Background(), status.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("cannot get job pod: %v", err)
+			}
+			pod.Status.Phase = corev1.PodSucceeded
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+				{Name: "build", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}},
+			}
+			pod, err = pods.UpdateStatus(context.Background(), pod, metav1.UpdateOptions{})
+			if err != nil {
+				t.Fatalf("cannot update job pod: %v", err)
+			}
+			js.handleJobEvent(watch.Modified, pod)
+
+			if !test.KeepsPod {
+				waitForDeletion(t, js, status.Name)
+				return
+			}
+
+			pod, err = pods.Get(context.Background(), status.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("job pod should still exist: %v", err)
+			}
+			finished, ok := getFinished(pod, js.labels)
+			if !ok {
+				t.Fatal("job pod should be marked as finished")
+			}
+			// the pod update triggers another event which must not change anything
+			js.handleJobEvent(watch.Modified, pod)
+
+			err = js.collectGarbage(finished.Add(test.DeletesAt - time.Second))
+			if err != nil {
+				t.Fatalf("cannot collect garbage: %v", err)
+			}
+			_, err = pods.Get(context.Background(), status.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("job pod should still exist before its TTL expired: %v", err)
+			}
+
+			err = js.collectGarbage(finished.Add(test.DeletesAt))
+			if err != nil {
+				t.Fatalf("cannot collect garbage: %v", err)
+			}
+			waitForDeletion(t, js, status.Name)
+		})
+	}
+}
+
+func waitForDeletion(t *testing.T, js *Executor, name string) {
+	t.Helper()
+
+	var err error
+	for i := 0; i < 50; i++ {
+		_, err = js.Client.CoreV1().Pods(js.Config.Namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if k8serr.IsNotFound(err) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !k8serr.IsNotFound(err) {
+		t.Fatalf("job pod should have been deleted, got %v", err)
+	}
+
+	cms, err := js.Client.CoreV1().ConfigMaps(js.Config.Namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("cannot list config maps: %v", err)
+	}
+	if len(cms.Items) != 0 {
+		t.Errorf("job config maps should have been deleted, found %d", len(cms.Items))
+	}
+}
+
+func TestConcurrencyLimits(t *testing.T) {
+	repo := func(name string) werftv1.JobMetadata {
+		return werftv1.JobMetadata{Repository: &werftv1.Repository{Host: "github.com", Owner: "csweichel", Repo: name}}
+	}
+	type job struct {
+		Name string
+		MD   werftv1.JobMetadata
+	}
+	tests := []struct {
+		Name       string
+		Max        int
+		MaxPerRepo int
+		Jobs       []job
+		Queued     []string
+	}{
+		{
+			Name:   "no limits",
+			Jobs:   []job{{"a", repo("werft")}, {"b", repo("werft")}, {"c", repo("werft")}},
+			Queued: []string{},
+		},
+		{
+			Name:   "global limit",
+			Max:    2,
+			Jobs:   []job{{"a", repo("werft")}, {"b", repo("other")}, {"c", repo("werft")}},
+			Queued: []string{"c"},
+		},
+		{
+			Name:       "per repo limit",
+			MaxPerRepo: 1,
+			Jobs:       []job{{"a", repo("werft")}, {"b", repo("werft")}, {"c", repo("other")}},
+			Queued:     []string{"b"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			js := newTestExecutor()
+			js.Config.MaxConcurrentJobs = test.Max
+			js.Config.MaxConcurrentJobsPerRepo = test.MaxPerRepo
+
+			queued := make(map[string]struct{})
+			for _, j := range test.Jobs {
+				status, err := js.Start(corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "build", Image: "alpine"}},
+				}, j.MD, WithName(j.Name))
+				if err != nil {
+					t.Fatalf("cannot start job %s: %v", j.Name, err)
+				}
+				if status.Phase == werftv1.JobPhase_PHASE_QUEUED {
+					queued[j.Name] = struct{}{}
+				}
+			}
+
+			if len(queued) != len(test.Queued) {
+				t.Errorf("expected %d queued jobs, actual %d", len(test.Queued), len(queued))
+			}
+			for _, name := range test.Queued {
+				if _, ok := queued[name]; !ok {
+					t.Errorf("expected %s to be queued", name)
+				}
+				_, err := js.Client.CoreV1().Pods(js.Config.Namespace).Get(context.Background(), name, metav1.GetOptions{})
+				if !k8serr.IsNotFound(err) {
+					t.Errorf("queued job %s should not have a pod", name)
+				}
+			}
+		})
+	}
+}
+
+func TestStartQueuedJobs(t *testing.T) {
+	js := newTestExecutor()
+	js.Config.MaxConcurrentJobs = 1
+
+	updates := make(chan *werftv1.JobStatus, 10)
+	js.OnUpdate = func(pod *corev1.Pod, status *werftv1.JobStatus) {
+		updates <- status
+	}
+
+	for _, name := range []string{"first", "second", "third"} {
+		_, err := js.Start(corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "build", Image: "alpine"}},
+		}, werftv1.JobMetadata{}, WithName(name))
+		if err != nil {
+			t.Fatalf("cannot start job %s: %v", name, err)
+		}
+	}
+
+	// canceling a queued job removes it from the queue
+	err := js.Stop("second", "job was stopped manually")
+	if err != nil {
+		t.Fatalf("cannot stop queued job: %v", err)
+	}
+	timeout := time.After(5 * time.Second)
+	for canceled := false; !canceled; {
+		select {
+		case s := <-updates:
+			canceled = s.Name == "second" && s.Phase == werftv1.JobPhase_PHASE_DONE
+		case <-timeout:
+			t.Fatal("queued job was not canceled")
+		}
+	}
+
+	// once the first job is done, the third one starts
+	pods := js.Client.CoreV1().Pods(js.Config.Namespace)
+	pod, err := pods.Get(context.Background(), "first", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("cannot get job pod: %v", err)
+	}
+	pod.Status.Phase = corev1.PodSucceeded
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "build", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}},
+	}
+	pod, err = pods.UpdateStatus(context.Background(), pod, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("cannot update job pod: %v", err)
+	}
+	js.handleJobEvent(watch.Modified, pod)
+
+	for i := 0; i < 50; i++ {
+		_, err = pods.Get(context.Background(), "third", metav1.GetOptions{})
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("queued job did not start: %v", err)
+	}
+	_, err = pods.Get(context.Background(), "second", metav1.GetOptions{})
+	if !k8serr.IsNotFound(err) {
+		t.Errorf("canceled job should not have started")
+	}
+}
+
+func TestQueuePriority(t *testing.T) {
+	type job struct {
+		Name     string
+		Priority int
+	}
+	tests := []struct {
+		Name        string
+		Jobs        []job
+		Expectation []string
+	}{
+		{
+			Name:        "mixed priorities",
+			Jobs:        []job{{"a", 0}, {"b", 10}, {"c", -5}, {"d", 5}},
+			Expectation: []string{"b", "d", "a", "c"},
+		},
+		{
+			Name:        "FIFO on equal priority",
+			Jobs:        []job{{"a", 1}, {"b", 1}, {"c", 1}},
+			Expectation: []string{"a", "b", "c"},
+		},
+		{
+			Name:        "mixed with ties",
+			Jobs:        []job{{"a", 0}, {"b", 5}, {"c", 0}, {"d", 5}},
+			Expectation: []string{"b", "d", "a", "c"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			js := newTestExecutor()
+			js.Config.MaxConcurrentJobs = 1
+
+			start := func(name string, priority int) *werftv1.JobStatus {
+				status, err := js.Start(corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "build", Image: "alpine"}},
+				}, werftv1.JobMetadata{}, WithName(name), WithPriority(priority))
+				if err != nil {
+					t.Fatalf("cannot start job %s: %v", name, err)
+				}
+				return status
+			}
+
+			// the running job takes up the only slot, hence all others are queued
+			start("running", 0)
+			for _, j := range test.Jobs {
+				status := start(j.Name, j.Priority)
+				if status.Phase != werftv1.JobPhase_PHASE_QUEUED {
+					t.Fatalf("expected %s to be queued, got %s", j.Name, status.Phase)
+				}
+				if status.Conditions.Priority != int32(j.Priority) {
+					t.Errorf("expected %s to have priority %d, got %d", j.Name, j.Priority, status.Conditions.Priority)
+				}
+			}
+
+			var (
+				pods    = js.Client.CoreV1().Pods(js.Config.Namespace)
+				current = "running"
+				act     []string
+			)
+			for range test.Jobs {
+				// free the slot and see which job takes it
+				err := pods.Delete(context.Background(), current, metav1.DeleteOptions{})
+				if err != nil {
+					t.Fatalf("cannot delete job pod: %v", err)
+				}
+				js.startQueuedJobs()
+
+				podList, err := pods.List(context.Background(), metav1.ListOptions{})
+				if err != nil {
+					t.Fatalf("cannot list job pods: %v", err)
+				}
+				if len(podList.Items) != 1 {
+					t.Fatalf("expected exactly one running job, got %d", len(podList.Items))
+				}
+				current = podList.Items[0].Name
+				act = append(act, current)
+			}
+
+			if !reflect.DeepEqual(act, test.Expectation) {
+				t.Errorf("unexpected start order: expected %v, got %v", test.Expectation, act)
+			}
+		})
+	}
+}
+
+func TestDrain(t *testing.T) {
+	js := newTestExecutor()
+	js.Config.MaxConcurrentJobs = 1
+
+	spec := corev1.PodSpec{Containers: []corev1.Container{{Name: "build", Image: "alpine"}}}
+	for _, j := range []struct {
+		Name string
+		Opts []StartOpt
+	}{
+		{Name: "running"},
+		{Name: "queued"},
+		{Name: "waiting", Opts: []StartOpt{WithNeeds([]string{"running"})}},
+	} {
+		_, err := js.Start(spec, werftv1.JobMetadata{}, append(j.Opts, WithName(j.Name))...)
+		if err != nil {
+			t.Fatalf("cannot start job %s: %v", j.Name, err)
+		}
+	}
+
+	drained := js.Drain()
+	phases := make(map[string]werftv1.JobPhase)
+	for _, s := range drained {
+		phases[s.Name] = s.Phase
+	}
+	expected := map[string]werftv1.JobPhase{
+		"queued":  werftv1.JobPhase_PHASE_QUEUED,
+		"waiting": werftv1.JobPhase_PHASE_WAITING,
+	}
+	if !reflect.DeepEqual(phases, expected) {
+		t.Errorf("unexpected drained jobs: %v, expected %v", phases, expected)
+	}
+
+	// neither a free slot nor the needed job being done start jobs while draining
+	pods := js.Client.CoreV1().Pods(js.Config.Namespace)
+	err := pods.Delete(context.Background(), "running", metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatalf("cannot delete job pod: %v", err)
+	}
+	js.startQueuedJobs()
+	js.JobDone(&werftv1.JobStatus{Name: "running", Phase: werftv1.JobPhase_PHASE_DONE, Conditions: &werftv1.JobConditions{Success: true}})
+	for _, name := range []string{"queued", "waiting"} {
+		_, err = pods.Get(context.Background(), name, metav1.GetOptions{})
+		if !k8serr.IsNotFound(err) {
+			t.Errorf("job %s should not have started while draining", name)
+		}
+	}
+}
+
+func TestSetAnnotations(t *testing.T) {
+	js := newTestExecutor()
+	spec := corev1.PodSpec{Containers: []corev1.Container{{Name: "build", Image: "alpine"}}}
+	md := werftv1.JobMetadata{Owner: "alice", Annotations: []*werftv1.Annotation{{Key: "env", Value: "staging"}}}
+	_, err := js.Start(spec, md, WithName("running"))
+	if err != nil {
+		t.Fatalf("cannot start job: %v", err)
+	}
+	_, err = js.Start(spec, md, WithName("waiting"), WithWaitUntil(time.Now().Add(time.Hour)))
+	if err != nil {
+		t.Fatalf("cannot start job: %v", err)
+	}
+
+	expected := []*werftv1.Annotation{{Key: "env", Value: "production"}, {Key: "release", Value: "true"}}
+	for _, name := range []string{"running", "waiting", "unknown"} {
+		err = js.SetAnnotations(name, expected)
+		if err != nil {
+			t.Fatalf("cannot set annotations of %s: %v", name, err)
+		}
+	}
+
+	js.mu.RLock()
+	wj := js.waitingJobs["waiting"]
+	js.mu.RUnlock()
+	if act := wj.Status.Metadata.Annotations; !equality.Semantic.DeepEqual(act, expected) {
+		t.Errorf("unexpected annotations of waiting job: %v", act)
+	}
+	// once the waiting job starts, its pod carries the new annotations
+	wj.Start()
+
+	pods := js.Client.CoreV1().Pods(js.Config.Namespace)
+	for _, name := range []string{"running", "waiting"} {
+		var pod *corev1.Pod
+		for i := 0; i < 50; i++ {
+			pod, err = pods.Get(context.Background(), name, metav1.GetOptions{})
+			if err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatalf("cannot get pod of %s: %v", name, err)
+		}
+		status, err := getStatus(pod, js.labels)
+		if err != nil {
+			t.Fatalf("cannot get status of %s: %v", name, err)
+		}
+		if act := status.Metadata.Annotations; !equality.Semantic.DeepEqual(act, expected) {
+			t.Errorf("unexpected annotations of %s: %v", name, act)
+		}
+		if status.Metadata.Owner != "alice" {
+			t.Errorf("%s lost its metadata: %v", name, status.Metadata)
+		}
+	}
+}
+
+func TestMutex(t *testing.T) {
+	js := newTestExecutor()
+	pods := js.Client.CoreV1().Pods(js.Config.Namespace)
+	start := func(name, mutex string, opts ...StartOpt) *werftv1.JobStatus {
+		status, err := js.Start(corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "build", Image: "alpine"}},
+		}, werftv1.JobMetadata{}, append(opts, WithName(name), WithMutex(mutex))...)
+		if err != nil {
+			t.Fatalf("cannot start job %s: %v", name, err)
+		}
+		return status
+	}
+	statusOf := func(name string) *werftv1.JobStatus {
+		pod, err := pods.Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("cannot get job pod %s: %v", name, err)
+		}
+		status, err := getStatus(pod, js.labels)
+		if err != nil {
+			t.Fatalf("cannot compute status of %s: %v", name, err)
+		}
+		return status
+	}
+
+	// mutexes which aren't valid label values must work, too
+	start("main-1", "github.com/csweichel/werft@refs/heads/main")
+	start("other-1", "github.com/csweichel/werft@refs/heads/other")
+
+	updates := make(chan *werftv1.JobStatus, 10)
+	js.OnUpdate = func(pod *corev1.Pod, status *werftv1.JobStatus) {
+		// the executor keeps modifying the status of waiting jobs
+		s := *status
+		updates <- &s
+	}
+	start("main-2", "github.com/csweichel/werft@refs/heads/main", WithWaitUntil(time.Now().Add(time.Hour)))
+	start("main-3", "github.com/csweichel/werft@refs/heads/main")
+
+	pod, err := pods.Get(context.Background(), "main-3", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("cannot get job pod main-3: %v", err)
+	}
+	if act := pod.Labels[js.labels.LabelMutex]; len(validation.IsValidLabelValue(act)) > 0 {
+		t.Errorf("mutex label is not a valid label value: %q", act)
+	}
+
+	status := statusOf("main-1")
+	if status.Phase != werftv1.JobPhase_PHASE_DONE || status.Conditions.Success {
+		t.Errorf("main-1 should have failed, but is %s", status.Phase)
+	}
+	if !strings.HasPrefix(status.Details, ReasonSuperseded) {
+		t.Errorf("main-1 should be superseded, details are %q", status.Details)
+	}
+	for _, name := range []string{"other-1", "main-3"} {
+		status := statusOf(name)
+		if status.Phase == werftv1.JobPhase_PHASE_DONE {
+			t.Errorf("%s should not be done: %s", name, status.Details)
+		}
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case s := <-updates:
+			if s.Name != "main-2" || s.Phase != werftv1.JobPhase_PHASE_DONE {
+				continue
+			}
+			if !strings.HasPrefix(s.Details, ReasonSuperseded) {
+				t.Errorf("waiting job should be superseded, details are %q", s.Details)
+			}
+			return
+		case <-timeout:
+			t.Fatal("waiting job was not superseded")
+		}
+	}
+}
+
+func TestNeeds(t *testing.T) {
+	// a diamond: both tests need the build, and deploy needs both tests
+	needs := map[string][]string{
+		"deploy": {"test-a", "test-b"},
+		"test-a": {"build"},
+		"test-b": {"build"},
+	}
+	type outcome struct {
+		Name    string
+		Success bool
+	}
+	tests := []struct {
+		Name    string
+		Done    []outcome
+		Started []string
+		Skipped []string
+		Waiting []string
+	}{
+		{
+			Name:    "nothing done",
+			Started: []string{"build"},
+			Waiting: []string{"test-a", "test-b", "deploy"},
+		},
+		{
+			Name:    "build succeeded",
+			Done:    []outcome{{"build", true}},
+			Started: []string{"build", "test-a", "test-b"},
+			Waiting: []string{"deploy"},
+		},
+		{
+			Name:    "one test succeeded",
+			Done:    []outcome{{"build", true}, {"test-a", true}},
+			Started: []string{"build", "test-a", "test-b"},
+			Waiting: []string{"deploy"},
+		},
+		{
+			Name:    "all succeeded",
+			Done:    []outcome{{"build", true}, {"test-a", true}, {"test-b", true}},
+			Started: []string{"build", "test-a", "test-b", "deploy"},
+		},
+		{
+			Name:    "build failed",
+			Done:    []outcome{{"build", false}},
+			Started: []string{"build"},
+			Skipped: []string{"test-a", "test-b", "deploy"},
+		},
+		{
+			Name:    "one test failed",
+			Done:    []outcome{{"build", true}, {"test-a", false}},
+			Started: []string{"build", "test-a", "test-b"},
+			Skipped: []string{"deploy"},
+		},
+		{
+			Name:    "other test succeeds after one failed",
+			Done:    []outcome{{"build", true}, {"test-a", false}, {"test-b", true}},
+			Started: []string{"build", "test-a", "test-b"},
+			Skipped: []string{"deploy"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			js := newTestExecutor()
+			updates := make(map[string]werftv1.JobStatus)
+			js.OnUpdate = func(pod *corev1.Pod, status *werftv1.JobStatus) {
+				updates[status.Name] = *status
+				// like the werft service, tell the executor about finished jobs
+				js.JobDone(status)
+			}
+
+			// jobs must be known to the executor before the jobs they need finish, hence we start them first
+			for _, name := range []string{"deploy", "test-a", "test-b", "build"} {
+				_, err := js.Start(corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "build", Image: "alpine"}},
+				}, werftv1.JobMetadata{}, WithName(name), WithNeeds(needs[name]))
+				if err != nil {
+					t.Fatalf("cannot start job %s: %v", name, err)
+				}
+			}
+			for _, o := range test.Done {
+				js.JobDone(&werftv1.JobStatus{
+					Name:       o.Name,
+					Phase:      werftv1.JobPhase_PHASE_DONE,
+					Conditions: &werftv1.JobConditions{Success: o.Success},
+				})
+			}
+
+			pods, err := js.Client.CoreV1().Pods(js.Config.Namespace).List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			started := make(map[string]bool)
+			for _, pod := range pods.Items {
+				started[pod.Name] = true
+			}
+			if len(started) != len(test.Started) {
+				t.Errorf("expected %d started jobs, actual %d", len(test.Started), len(started))
+			}
+			for _, name := range test.Started {
+				if !started[name] {
+					t.Errorf("expected %s to have started", name)
+				}
+			}
+			for _, name := range test.Skipped {
+				s := updates[name]
+				if s.Phase != werftv1.JobPhase_PHASE_DONE || !s.Conditions.Skipped || !s.Conditions.Success {
+					t.Errorf("expected %s to be skipped, got %v: %s", name, s.Phase, s.Details)
+				}
+				if started[name] {
+					t.Errorf("skipped job %s should not have a pod", name)
+				}
+			}
+			for _, name := range test.Waiting {
+				js.mu.RLock()
+				_, waiting := js.waitingJobs[name]
+				js.mu.RUnlock()
+				if !waiting || updates[name].Phase != werftv1.JobPhase_PHASE_WAITING {
+					t.Errorf("expected %s to wait, got %v", name, updates[name].Phase)
+				}
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	js := newTestExecutor()
+	cache := Cache{Key: "github.com/csweichel/werft", MountPath: "/cache", Size: resource.MustParse("5Gi"), StorageClass: "ssd"}
+	start := func(name string) *corev1.Pod {
+		_, err := js.Start(corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "build", Image: "alpine"},
+				{Name: "proxy", Image: "envoy"},
+			},
+		}, werftv1.JobMetadata{}, WithName(name), WithSidecars([]string{"proxy"}), WithCache(cache))
+		if err != nil {
+			t.Fatalf("cannot start job: %v", err)
+		}
+		pod, err := js.Client.CoreV1().Pods(js.Config.Namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("cannot get pod: %v", err)
+		}
+		return pod
+	}
+
+	first := start("first-job")
+	claimName := cacheClaimName(cacheID(cache.Key))
+	expectedVolume := corev1.Volume{Name: cacheVolumeName, VolumeSource: corev1.VolumeSource{
+		PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+	}}
+	if len(first.Spec.Volumes) != 1 || !equality.Semantic.DeepEqual(first.Spec.Volumes[0], expectedVolume) {
+		t.Errorf("unexpected volumes: %v; expected %v", first.Spec.Volumes, expectedVolume)
+	}
+	expectedMounts := []corev1.VolumeMount{{Name: cacheVolumeName, MountPath: "/cache"}}
+	if !equality.Semantic.DeepEqual(first.Spec.Containers[0].VolumeMounts, expectedMounts) {
+		t.Errorf("unexpected volume mounts: %v; expected %v", first.Spec.Containers[0].VolumeMounts, expectedMounts)
+	}
+	if len(first.Spec.Containers[1].VolumeMounts) > 0 {
+		t.Errorf("sidecar should not mount the cache: %v", first.Spec.Containers[1].VolumeMounts)
+	}
+	if first.Labels[js.labels.LabelCache] != cacheID(cache.Key) {
+		t.Errorf("job using the cache is not labeled: %v", first.Labels)
+	}
+
+	pvc, err := js.Client.CoreV1().PersistentVolumeClaims(js.Config.Namespace).Get(context.Background(), claimName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("cannot get cache volume claim: %v", err)
+	}
+	if size := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; size.Cmp(cache.Size) != 0 {
+		t.Errorf("unexpected cache size: %s; expected %s", size.String(), cache.Size.String())
+	}
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName != "ssd" {
+		t.Errorf("unexpected storage class: %v", pvc.Spec.StorageClassName)
+	}
+
+	// the first job still runs, hence the second one must not share its volume
+	second := start("second-job")
+	if len(second.Spec.Volumes) != 1 || second.Spec.Volumes[0].EmptyDir == nil {
+		t.Errorf("expected a fresh volume while the cache is in use: %v", second.Spec.Volumes)
+	}
+	if _, ok := second.Labels[js.labels.LabelCache]; ok {
+		t.Errorf("job not using the cache must not be labeled: %v", second.Labels)
+	}
+
+	// once the first job is done the cache is free again
+	err = js.addAnnotation("first-job", map[string]string{js.labels.AnnotationFailed: "canceled"})
+	if err != nil {
+		t.Fatalf("cannot fail job: %v", err)
+	}
+	third := start("third-job")
+	if len(third.Spec.Volumes) != 1 || third.Spec.Volumes[0].PersistentVolumeClaim == nil {
+		t.Errorf("expected the cache volume once it's free: %v", third.Spec.Volumes)
+	}
+}
+
+func TestSidecars(t *testing.T) {
+	terminated := func(code int32) corev1.ContainerState {
+		return corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: code}}
+	}
+	running := corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+
+	tests := []struct {
+		Name          string
+		Build         corev1.ContainerState
+		Database      corev1.ContainerState
+		Phase         werftv1.JobPhase
+		Success       bool
+		StopsSidecars bool
+	}{
+		{Name: "build running", Build: running, Database: running, Phase: werftv1.JobPhase_PHASE_RUNNING, Success: true},
+		{Name: "build succeeded", Build: terminated(0), Database: running, Phase: werftv1.JobPhase_PHASE_DONE, Success: true, StopsSidecars: true},
+		{Name: "build failed", Build: terminated(1), Database: running, Phase: werftv1.JobPhase_PHASE_DONE, StopsSidecars: true},
+		{Name: "sidecar stopped", Build: terminated(0), Database: terminated(137), Phase: werftv1.JobPhase_PHASE_DONE, Success: true},
+		{Name: "sidecar crashed", Build: running, Database: terminated(1), Phase: werftv1.JobPhase_PHASE_RUNNING, Success: true},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			js := newTestExecutor()
+			js.Config.PodTTL = &Duration{time.Hour}
+
+			status, err := js.Start(corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "database", Image: "postgres"},
+					{Name: "build", Image: "alpine"},
+				},
+			}, werftv1.JobMetadata{}, WithName("test-job"), WithSidecars([]string{"database"}))
+			if err != nil {
+				t.Fatalf("cannot start job: %v", err)
+			}
+
+			pods := js.Client.CoreV1().Pods(js.Config.Namespace)
+			pod, err := pods.Get(context.Background(), status.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("cannot get job pod: %v", err)
+			}
+			pod.Status.Phase = corev1.PodRunning
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+				{Name: "database", State: test.Database},
+				{Name: "build", State: test.Build},
+			}
+			pod, err = pods.UpdateStatus(context.Background(), pod, metav1.UpdateOptions{})
+			if err != nil {
+				t.Fatalf("cannot update job pod: %v", err)
+			}
+
+			status, err = getStatus(pod, js.labels)
+			if err != nil {
+				t.Fatalf("cannot get status: %v", err)
+			}
+			if status.Phase != test.Phase {
+				t.Errorf("unexpected phase: %v; expected %v", status.Phase, test.Phase)
+			}
+			if status.Conditions.Success != test.Success {
+				t.Errorf("unexpected success: %v; expected %v", status.Conditions.Success, test.Success)
+			}
+
+			err = js.actOnUpdate(status, pod)
+			if err != nil {
+				t.Fatalf("cannot act on update: %v", err)
+			}
+			pod, err = pods.Get(context.Background(), status.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("job pod should still exist: %v", err)
+			}
+			stopped := pod.Spec.ActiveDeadlineSeconds != nil
+			if stopped != test.StopsSidecars {
+				t.Errorf("unexpected sidecar stop: %v; expected %v", stopped, test.StopsSidecars)
+			}
+		})
+	}
+}
+
+func TestInitContainerFailure(t *testing.T) {
+	failed := &corev1.ContainerStateTerminated{ExitCode: 2}
+	waiting := corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "PodInitializing"}}
+	tests := []struct {
+		Name    string
+		Pod     corev1.PodStatus
+		Phase   werftv1.JobPhase
+		Details string
+	}{
+		{
+			Name: "succeeded",
+			Pod: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				InitContainerStatuses: []corev1.ContainerStatus{
+					{Name: "checkout", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}},
+					{Name: "fetch-artifacts", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}},
+				},
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "build", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}},
+			},
+			Phase: werftv1.JobPhase_PHASE_RUNNING,
+		},
+		{
+			Name: "restarted",
+			Pod: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				InitContainerStatuses: []corev1.ContainerStatus{
+					{Name: "checkout", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}},
+					{Name: "fetch-artifacts", RestartCount: 1, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}, LastTerminationState: corev1.ContainerState{Terminated: failed}},
+				},
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "build", State: waiting}},
+			},
+			Phase:   werftv1.JobPhase_PHASE_DONE,
+			Details: "init container fetch-artifacts failed with exit code 2",
+		},
+		{
+			Name: "never restarted",
+			Pod: corev1.PodStatus{
+				Phase: corev1.PodFailed,
+				InitContainerStatuses: []corev1.ContainerStatus{
+					{Name: "checkout", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}},
+					{Name: "fetch-artifacts", State: corev1.ContainerState{Terminated: failed}},
+				},
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "build", State: waiting}},
+			},
+			Phase:   werftv1.JobPhase_PHASE_DONE,
+			Details: "init container fetch-artifacts failed with exit code 2",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			js := newTestExecutor()
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-job",
+					Labels:      map[string]string{js.labels.LabelJobName: "test-job"},
+					Annotations: map[string]string{js.labels.AnnotationMetadata: "{}"},
+				},
+				Status: test.Pod,
+			}
+
+			status, err := getStatus(pod, js.labels)
+			if err != nil {
+				t.Fatalf("cannot get status: %v", err)
+			}
+			if status.Phase != test.Phase {
+				t.Errorf("unexpected phase: %v; expected %v", status.Phase, test.Phase)
+			}
+			if success := test.Details == ""; status.Conditions.Success != success {
+				t.Errorf("unexpected success: %v; expected %v", status.Conditions.Success, success)
+			}
+			if status.Details != test.Details {
+				t.Errorf("unexpected details: \"%s\"; expected \"%s\"", status.Details, test.Details)
+			}
+		})
+	}
+}
+
+type fakeArtifactStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func (s *fakeArtifactStore) Put(ctx context.Context, key string, r io.Reader) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.blobs[key] = b
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *fakeArtifactStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.blobs[key]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+func TestArtifacts(t *testing.T) {
+	// every pod's workspace is a local directory in which we run the commands meant for the pod
+	root, err := ioutil.TempDir("", "werft-artifacts")
+	if err != nil {
+		t.Fatalf("cannot create temp dir: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	store := &fakeArtifactStore{blobs: make(map[string][]byte)}
+	js := newTestExecutor()
+	js.Artifacts = store
+	js.execInPod = func(pod, container string, cmd []string, stdin io.Reader, stdout io.Writer) error {
+		workspace := filepath.Join(root, pod)
+		args := make([]string, len(cmd))
+		for i, a := range cmd {
+			args[i] = strings.Replace(a, "/workspace", workspace, 1)
+		}
+		c := exec.Command(args[0], args[1:]...)
+		c.Stdin, c.Stdout = stdin, stdout
+		return c.Run()
+	}
+
+	pods := js.Client.CoreV1().Pods(js.Config.Namespace)
+	update := func(name string, modify func(pod *corev1.Pod)) {
+		pod, err := pods.Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("cannot get job pod: %v", err)
+		}
+		pod.Status.Phase = corev1.PodRunning
+		modify(pod)
+		pod, err = pods.UpdateStatus(context.Background(), pod, metav1.UpdateOptions{})
+		if err != nil {
+			t.Fatalf("cannot update job pod: %v", err)
+		}
+		js.handleJobEvent(watch.Modified, pod)
+	}
+	waitForAnnotation := func(name, annotation string) *corev1.Pod {
+		for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			pod, err := pods.Get(context.Background(), name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("cannot get job pod: %v", err)
+			}
+			if msg, failed := pod.Annotations[js.labels.AnnotationFailed]; failed {
+				t.Fatalf("job failed: %s", msg)
+			}
+			if _, ok := pod.Annotations[annotation]; ok {
+				return pod
+			}
+		}
+		t.Fatalf("job %s was not annotated with %s in time", name, annotation)
+		return nil
+	}
+	dist := Artifact{Name: "dist", Path: "dist", Key: "werft-main.1/dist.tar.gz"}
+	files := map[string]string{
+		"app":            "binary",
+		"docs/README.md": "# werft",
+	}
+
+	// the producer's outputs are collected once its build container has succeeded
+	_, err = js.Start(corev1.PodSpec{
+		Containers: []corev1.Container{{Name: "build", Image: "alpine"}, ArtifactsSidecar()},
+	}, werftv1.JobMetadata{}, WithName("producer"), WithSidecars([]string{ArtifactsContainer}), WithArtifacts(Artifacts{Outputs: []Artifact{dist}}))
+	if err != nil {
+		t.Fatalf("cannot start producer: %v", err)
+	}
+	for name, content := range files {
+		fn := filepath.Join(root, "producer", "dist", name)
+		_ = os.MkdirAll(filepath.Dir(fn), 0755)
+		err = ioutil.WriteFile(fn, []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("cannot write workspace: %v", err)
+		}
+	}
+	update("producer", func(pod *corev1.Pod) {
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+			{Name: "build", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}},
+			{Name: ArtifactsContainer, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+		}
+		status, err := getStatus(pod, js.labels)
+		if err != nil {
+			t.Fatalf("cannot get status: %v", err)
+		}
+		if status.Phase != werftv1.JobPhase_PHASE_RUNNING || status.Details != collectingArtifactsDetails {
+			t.Errorf("job should collect artifacts before it's done: %v %q", status.Phase, status.Details)
+		}
+	})
+	producer := waitForAnnotation("producer", js.labels.AnnotationArtifactsCollected)
+	status, err := getStatus(producer, js.labels)
+	if err != nil {
+		t.Fatalf("cannot get status: %v", err)
+	}
+	if status.Phase != werftv1.JobPhase_PHASE_DONE || !status.Conditions.Success {
+		t.Errorf("job should be done once its artifacts are collected: %v", status)
+	}
+	if len(status.Results) != 1 || status.Results[0].Type != ResultTypeArtifact || status.Results[0].Payload != "dist" {
+		t.Errorf("unexpected results: %v", status.Results)
+	}
+	if _, ok := store.blobs[dist.Key]; !ok {
+		t.Fatalf("artifact was not stored under %s", dist.Key)
+	}
+
+	// the consumer's init container waits until its inputs are restored
+	input := dist
+	input.Path = "build/dist"
+	_, err = js.Start(corev1.PodSpec{
+		InitContainers: []corev1.Container{ArtifactsInit()},
+		Containers:     []corev1.Container{{Name: "deploy", Image: "alpine"}},
+	}, werftv1.JobMetadata{}, WithName("consumer"), WithArtifacts(Artifacts{Inputs: []Artifact{input}}))
+	if err != nil {
+		t.Fatalf("cannot start consumer: %v", err)
+	}
+	_ = os.MkdirAll(filepath.Join(root, "consumer"), 0755)
+	update("consumer", func(pod *corev1.Pod) {
+		pod.Status.InitContainerStatuses = []corev1.ContainerStatus{
+			{Name: ArtifactsInitContainer, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+		}
+	})
+	waitForAnnotation("consumer", js.labels.AnnotationArtifactsRestored)
+	for name, content := range files {
+		act, err := ioutil.ReadFile(filepath.Join(root, "consumer", "build", "dist", name))
+		if err != nil {
+			t.Errorf("artifact was not restored: %v", err)
+			continue
+		}
+		if string(act) != content {
+			t.Errorf("unexpected content of %s: %q; expected %q", name, act, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "consumer", strings.TrimPrefix(artifactsReadyMarker, "/workspace/"))); err != nil {
+		t.Errorf("init container was not told that the artifacts are ready: %v", err)
+	}
+}
+
+func TestStartedAndCompleted(t *testing.T) {
+	var (
+		firstStart = metav1.NewTime(time.Date(2020, 3, 15, 12, 0, 0, 0, time.UTC))
+		restart    = metav1.NewTime(firstStart.Add(time.Minute))
+		sidecar    = metav1.NewTime(firstStart.Add(10 * time.Second))
+	)
+	tests := []struct {
+		Name      string
+		Pod       corev1.PodStatus
+		Phase     werftv1.JobPhase
+		Started   *metav1.Time
+		Completed bool
+	}{
+		{
+			Name:  "pending",
+			Pod:   corev1.PodStatus{Phase: corev1.PodPending},
+			Phase: werftv1.JobPhase_PHASE_PREPARING,
+		},
+		{
+			Name: "init containers running",
+			Pod: corev1.PodStatus{
+				Phase:                 corev1.PodPending,
+				InitContainerStatuses: []corev1.ContainerStatus{{Name: "werft-checkout", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: firstStart}}}},
+				ContainerStatuses:     []corev1.ContainerStatus{{Name: "build", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "PodInitializing"}}}},
+			},
+			Phase: werftv1.JobPhase_PHASE_PREPARING,
+		},
+		{
+			Name: "running",
+			Pod: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Name: "build", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: firstStart}}},
+					{Name: "database", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: sidecar}}},
+				},
+			},
+			Phase:   werftv1.JobPhase_PHASE_RUNNING,
+			Started: &firstStart,
+		},
+		{
+			Name: "restarted",
+			Pod: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:                 "build",
+					State:                corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: restart}},
+					LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, StartedAt: firstStart}},
+				}},
+			},
+			Phase:   werftv1.JobPhase_PHASE_RUNNING,
+			Started: &firstStart,
+		},
+		{
+			Name: "done",
+			Pod: corev1.PodStatus{
+				Phase:             corev1.PodSucceeded,
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "build", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{StartedAt: firstStart}}}},
+			},
+			Phase:     werftv1.JobPhase_PHASE_DONE,
+			Started:   &firstStart,
+			Completed: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			js := newTestExecutor()
+			// the metadata of a restarted job stems from the previous job
+			previous := werftv1.JobMetadata{Started: &timestamp.Timestamp{Seconds: 1}}
+			status, err := js.Start(corev1.PodSpec{Containers: []corev1.Container{{Name: "build", Image: "alpine"}}}, previous, WithName("test-job"))
+			if err != nil {
+				t.Fatalf("cannot start job: %v", err)
+			}
+
+			pods := js.Client.CoreV1().Pods(js.Config.Namespace)
+			pod, err := pods.Get(context.Background(), status.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("cannot get job pod: %v", err)
+			}
+			pod.Status = test.Pod
+
+			status, err = getStatus(pod, js.labels)
+			if err != nil {
+				t.Fatalf("cannot get status: %v", err)
+			}
+			if status.Phase != test.Phase {
+				t.Errorf("unexpected phase: %v; expected %v", status.Phase, test.Phase)
+			}
+			if test.Started == nil && status.Metadata.Started != nil {
+				t.Errorf("unexpected start time: %v; expected none", status.Metadata.Started)
+			}
+			if test.Started != nil {
+				started, err := ptypes.Timestamp(status.Metadata.Started)
+				if err != nil || !started.Equal(test.Started.Time) {
+					t.Errorf("unexpected start time: %v; expected %v", status.Metadata.Started, test.Started.Time)
+				}
+			}
+			if completed := status.Metadata.Finished != nil; completed != test.Completed {
+				t.Errorf("unexpected completion time: %v; expected completed=%v", status.Metadata.Finished, test.Completed)
+			}
+		})
+	}
+}
+
+func TestEvents(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Finish      func(t *testing.T, js *Executor, pod *corev1.Pod) *corev1.Pod
+		Expectation []string
+	}{
+		{
+			Name: "succeeded",
+			Finish: func(t *testing.T, js *Executor, pod *corev1.Pod) *corev1.Pod {
+				pod.Status.Phase = corev1.PodSucceeded
+				pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+					{Name: "build", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}},
+				}
+				return pod
+			},
+			Expectation: []string{"Normal JobScheduled", "Normal JobStarted", "Normal JobSucceeded", "Normal JobCleanedUp"},
+		},
+		{
+			Name: "failed",
+			Finish: func(t *testing.T, js *Executor, pod *corev1.Pod) *corev1.Pod {
+				pod.Status.Phase = corev1.PodFailed
+				pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+					{Name: "build", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1}}},
+				}
+				return pod
+			},
+			Expectation: []string{"Normal JobScheduled", "Normal JobStarted", "Warning JobFailed", "Normal JobCleanedUp"},
+		},
+		{
+			Name: "timed out",
+			Finish: func(t *testing.T, js *Executor, pod *corev1.Pod) *corev1.Pod {
+				err := js.enforceTimeouts(time.Now().Add(2 * time.Hour))
+				if err != nil {
+					t.Fatalf("cannot enforce timeouts: %v", err)
+				}
+				pod, err = js.Client.CoreV1().Pods(js.Config.Namespace).Get(context.Background(), pod.Name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("cannot get job pod: %v", err)
+				}
+				return pod
+			},
+			Expectation: []string{"Normal JobScheduled", "Normal JobStarted", "Warning JobTimedOut", "Warning JobFailed", "Normal JobCleanedUp"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var (
+				js       = newTestExecutor()
+				recorder = record.NewFakeRecorder(100)
+			)
+			js.Events = recorder
+
+			status, err := js.Start(corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "build", Image: "alpine"}},
+			}, werftv1.JobMetadata{}, WithName("test-job"))
+			if err != nil {
+				t.Fatalf("cannot start job: %v", err)
+			}
+
+			pods := js.Client.CoreV1().Pods(js.Config.Namespace)
+			pod, err := pods.Get(context.Background(), status.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("cannot get job pod: %v", err)
+			}
+			pod.Status.Phase = corev1.PodRunning
+			pod, err = pods.UpdateStatus(context.Background(), pod, metav1.UpdateOptions{})
+			if err != nil {
+				t.Fatalf("cannot update job pod: %v", err)
+			}
+			// the watch tells us about the same state several times, which must not record the event twice
+			js.handleJobEvent(watch.Modified, pod)
+			js.handleJobEvent(watch.Modified, pod)
+
+			pod, err = pods.UpdateStatus(context.Background(), test.Finish(t, js, pod), metav1.UpdateOptions{})
+			if err != nil {
+				t.Fatalf("cannot update job pod: %v", err)
+			}
+			js.handleJobEvent(watch.Modified, pod)
+
+			var act []string
+			timeout := time.After(2 * time.Second)
+		collect:
+			for len(act) < len(test.Expectation) {
+				select {
+				case evt := <-recorder.Events:
+					segs := strings.Fields(evt)
+					act = append(act, strings.Join(segs[:2], " "))
+				case <-timeout:
+					break collect
+				}
+			}
+			select {
+			case evt := <-recorder.Events:
+				act = append(act, evt)
+			default:
+			}
+			if !reflect.DeepEqual(act, test.Expectation) {
+				t.Errorf("unexpected events: expected %v, got %v", test.Expectation, act)
+			}
+		})
+	}
+}
+
+func TestGracePeriod(t *testing.T) {
+	tests := []struct {
+		Name string
+		// Hangs keeps the pod terminating, as if its containers ignored SIGTERM
+		Hangs     bool
+		Deletions int
+	}{
+		{Name: "stops within grace period", Deletions: 1},
+		{Name: "force-deleted after grace period", Hangs: true, Deletions: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var (
+				js        = newTestExecutor()
+				client    = fake.NewSimpleClientset()
+				podsGVR   = corev1.SchemeGroupVersion.WithResource("pods")
+				mu        sync.Mutex
+				deletions int
+			)
+			js.Client = client
+			client.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				del := action.(k8stesting.DeleteActionImpl)
+				mu.Lock()
+				deletions++
+				first := deletions == 1
+				mu.Unlock()
+				if !first {
+					return false, nil, nil
+				}
+
+				// the pod is terminating while its containers run their preStop hook
+				obj, err := client.Tracker().Get(podsGVR, del.Namespace, del.Name)
+				if err != nil {
+					return true, nil, err
+				}
+				pod := obj.(*corev1.Pod)
+				now := metav1.Now()
+				pod.DeletionTimestamp = &now
+				err = client.Tracker().Update(podsGVR, pod, del.Namespace)
+				if err != nil {
+					return true, nil, err
+				}
+				if !test.Hangs {
+					go func() {
+						time.Sleep(200 * time.Millisecond)
+						_ = client.Tracker().Delete(podsGVR, del.Namespace, del.Name)
+					}()
+				}
+				return true, nil, nil
+			})
+
+			status, err := js.Start(corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "build", Image: "alpine"}},
+			}, werftv1.JobMetadata{}, WithName("test-job"), WithGracePeriod(time.Second))
+			if err != nil {
+				t.Fatalf("cannot start job: %v", err)
+			}
+
+			pods := js.Client.CoreV1().Pods(js.Config.Namespace)
+			pod, err := pods.Get(context.Background(), status.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("cannot get job pod: %v", err)
+			}
+			if gp := pod.Spec.TerminationGracePeriodSeconds; gp == nil || *gp != 1 {
+				t.Errorf("expected the pod to have a termination grace period of 1s, got %v", gp)
+			}
+			pod.Status.Phase = corev1.PodRunning
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+				{Name: "build", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+			}
+			_, err = pods.UpdateStatus(context.Background(), pod, metav1.UpdateOptions{})
+			if err != nil {
+				t.Fatalf("cannot update job pod: %v", err)
+			}
+
+			err = js.Stop(status.Name, "job was stopped manually")
+			if err != nil {
+				t.Fatalf("cannot stop job: %v", err)
+			}
+			pod, err = pods.Get(context.Background(), status.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("cannot get job pod: %v", err)
+			}
+			js.handleJobEvent(watch.Modified, pod)
+
+			// within the grace period the pod is left alone
+			time.Sleep(500 * time.Millisecond)
+			mu.Lock()
+			if deletions != 1 {
+				t.Errorf("expected a single deletion within the grace period, got %d", deletions)
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Second)
+			mu.Lock()
+			if deletions != test.Deletions {
+				t.Errorf("expected %d deletions, got %d", test.Deletions, deletions)
+			}
+			mu.Unlock()
+			_, err = pods.Get(context.Background(), status.Name, metav1.GetOptions{})
+			if !k8serr.IsNotFound(err) {
+				t.Errorf("job pod should have been deleted, got %v", err)
+			}
+		})
+	}
+}
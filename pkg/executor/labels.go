@@ -15,9 +15,14 @@ type labelSet struct {
 	// LabelJobName adds the ID of the job to the k8s object
 	LabelJobName string
 
-	// LabelMutex makes jobs findable via their mutex
+	// LabelMutex makes jobs findable via their mutex. Mutexes which aren't valid label values
+	// are stored as their hash.
 	LabelMutex string
 
+	// LabelCache marks cache volumes and the jobs which mount them. As cache keys aren't
+	// necessarily valid label values, this label holds a hash of the key.
+	LabelCache string
+
 	// UserDataAnnotationPrefix is prepended together with the label prefix to all user annotations added to jobs
 	UserDataAnnotationPrefix string
 
@@ -41,6 +46,36 @@ type labelSet struct {
 
 	// AnnotationSidecars lists all container whose lifecycle depends on that of the others
 	AnnotationSidecars string
+
+	// AnnotationTimeout stores the total timeout of a job if it differs from the server default
+	AnnotationTimeout string
+
+	// AnnotationTimedOut marks a job which was stopped because it exceeded its timeout
+	AnnotationTimedOut string
+
+	// AnnotationPodTTL stores how long the pod of a finished job is kept if it differs from the server default
+	AnnotationPodTTL string
+
+	// AnnotationFinished stores the time a job was first seen done
+	AnnotationFinished string
+
+	// AnnotationCacheKey stores the key of a cache volume
+	AnnotationCacheKey string
+
+	// AnnotationArtifacts stores the JSON encoded artifacts a job passes to other jobs
+	AnnotationArtifacts string
+
+	// AnnotationArtifactsCollected marks a job whose outputs are in the artifact store
+	AnnotationArtifactsCollected string
+
+	// AnnotationArtifactsRestored marks a job whose inputs were restored into its workspace
+	AnnotationArtifactsRestored string
+
+	// AnnotationPriority stores the priority of a job in the queue if it's not zero
+	AnnotationPriority string
+
+	// AnnotationGracePeriod stores how long the containers of a job get to stop when the job is stopped
+	AnnotationGracePeriod string
 }
 
 // newLabelSetet returns a new label set initialized with a particular prefix
@@ -51,16 +86,27 @@ func newLabelSetet(prefix string) labelSet {
 	prefix = strings.TrimSuffix(prefix, "/") + "/"
 
 	return labelSet{
-		LabelWerftMarker:         prefix + "job",
-		LabelJobName:             prefix + "jobName",
-		LabelMutex:               prefix + "mutex",
-		UserDataAnnotationPrefix: "userdata." + prefix,
-		AnnotationFailureLimit:   prefix + "failureLimit",
-		AnnotationMetadata:       prefix + "metadata",
-		AnnotationFailed:         prefix + "failed",
-		AnnotationResults:        prefix + "results",
-		AnnotationCanReplay:      prefix + "canReplay",
-		AnnotationWaitUntil:      prefix + "waitUntil",
-		AnnotationSidecars:       prefix + "sidecars",
+		LabelWerftMarker:             prefix + "job",
+		LabelJobName:                 prefix + "jobName",
+		LabelMutex:                   prefix + "mutex",
+		LabelCache:                   prefix + "cache",
+		UserDataAnnotationPrefix:     "userdata." + prefix,
+		AnnotationFailureLimit:       prefix + "failureLimit",
+		AnnotationMetadata:           prefix + "metadata",
+		AnnotationFailed:             prefix + "failed",
+		AnnotationResults:            prefix + "results",
+		AnnotationCanReplay:          prefix + "canReplay",
+		AnnotationWaitUntil:          prefix + "waitUntil",
+		AnnotationSidecars:           prefix + "sidecars",
+		AnnotationTimeout:            prefix + "timeout",
+		AnnotationTimedOut:           prefix + "timedOut",
+		AnnotationPodTTL:             prefix + "podTTL",
+		AnnotationFinished:           prefix + "finished",
+		AnnotationCacheKey:           prefix + "cacheKey",
+		AnnotationArtifacts:          prefix + "artifacts",
+		AnnotationArtifactsCollected: prefix + "artifactsCollected",
+		AnnotationArtifactsRestored:  prefix + "artifactsRestored",
+		AnnotationPriority:           prefix + "priority",
+		AnnotationGracePeriod:        prefix + "gracePeriod",
 	}
 }
@@ -5,7 +5,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"strings"
 	"sync"
 	"time"
 
@@ -23,6 +22,7 @@ type logListener struct {
 	Labels    labelSet
 
 	listener map[string]io.Closer
+	tailing  int
 	started  time.Time
 	closed   bool
 	mu       sync.RWMutex
@@ -33,7 +33,7 @@ type logListener struct {
 }
 
 // Listen establishes a log listener for a job
-func listenToLogs(client kubernetes.Interface, job, namespace string, labels labelSet) io.Reader {
+func listenToLogs(client kubernetes.Interface, job, namespace string, labels labelSet) *logListener {
 	ll := &logListener{
 		Clientset: client,
 		Job:       job,
@@ -45,7 +45,27 @@ func listenToLogs(client kubernetes.Interface, job, namespace string, labels lab
 	ll.out, ll.in = io.Pipe()
 	go ll.Start()
 
-	return ll.out
+	return ll
+}
+
+// Drain waits until all container logs have been forwarded. Returns false if the context is done before that.
+func (ll *logListener) Drain(ctx context.Context) bool {
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		ll.mu.RLock()
+		tailing := ll.tailing
+		ll.mu.RUnlock()
+		if tailing == 0 {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-tick.C:
+		}
+	}
 }
 
 func (ll *logListener) Close() error {
@@ -100,7 +120,7 @@ func (ll *logListener) Start() {
 			for _, c := range statuses {
 				if c.State.Running != nil {
 					var prefix string
-					if isSidecar := strings.Contains(pod.Annotations[ll.Labels.AnnotationSidecars], c.Name); isSidecar {
+					if isSidecar(pod, ll.Labels, c.Name) {
 						prefix = fmt.Sprintf("[%s] ", c.Name)
 					}
 					go ll.tail(pod.Name, c.Name, prefix)
@@ -147,7 +167,13 @@ func (ll *logListener) tail(pod, container, prefix string) {
 		return
 	}
 	ll.listener[id] = logs
+	ll.tailing++
 	once.Do(ll.mu.Unlock)
+	defer func() {
+		ll.mu.Lock()
+		ll.tailing--
+		ll.mu.Unlock()
+	}()
 
 	// forward the logs line by line to ensure we don't mix the output of different conainer
 	scanner := bufio.NewScanner(logs)
@@ -2,6 +2,7 @@ package executor
 
 import (
 	"encoding/json"
+	"fmt"
 	"strconv"
 	"strings"
 	"time"
@@ -17,7 +18,13 @@ import (
 // extracts the phase from the job object
 func getStatus(obj *corev1.Pod, labels labelSet) (status *v1.JobStatus, err error) {
 	defer func() {
-		if status != nil && status.Phase == v1.JobPhase_PHASE_DONE {
+		if status == nil || status.Phase != v1.JobPhase_PHASE_DONE {
+			return
+		}
+		if finished, ok := getFinished(obj, labels); ok {
+			status.Metadata.Finished, _ = ptypes.TimestampProto(finished)
+		}
+		if status.Metadata.Finished == nil {
 			status.Metadata.Finished = ptypes.TimestampNow()
 		}
 	}()
@@ -61,6 +68,15 @@ func getStatus(obj *corev1.Pod, labels labelSet) (status *v1.JobStatus, err erro
 		}
 	}
 
+	var priority int32
+	if p, ok := obj.Annotations[labels.AnnotationPriority]; ok {
+		pv, err := strconv.ParseInt(p, 10, 32)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot parse %s annotation: %w", labels.AnnotationPriority, err)
+		}
+		priority = int32(pv)
+	}
+
 	status = &v1.JobStatus{
 		Name:     name,
 		Metadata: &md,
@@ -69,13 +85,20 @@ func getStatus(obj *corev1.Pod, labels labelSet) (status *v1.JobStatus, err erro
 			Success:   true,
 			CanReplay: canReplay,
 			WaitUntil: waitUntil,
+			Priority:  priority,
 		},
 		Results: results,
 	}
+	// the metadata we started the job with may stem from a previous job, hence the pod is the only source of truth
+	status.Metadata.Started = nil
+	if started, ok := getStarted(obj); ok {
+		status.Metadata.Started, _ = ptypes.TimestampProto(started)
+	}
 
 	var (
 		statuses      = append(obj.Status.InitContainerStatuses, obj.Status.ContainerStatuses...)
 		anyFailed     bool
+		initFailure   string
 		maxRestart    int32
 		allTerminated = len(statuses) != 0
 	)
@@ -87,12 +110,15 @@ func getStatus(obj *corev1.Pod, labels labelSet) (status *v1.JobStatus, err erro
 			return
 		}
 
-		isSidecarContainer := strings.Contains(obj.Annotations[labels.AnnotationSidecars], cs.Name)
+		if isSidecar(obj, labels, cs.Name) {
+			// the job's outcome depends on its main containers only - sidecars are stopped once those are done
+			continue
+		}
 		if cs.State.Terminated != nil {
 			if cs.State.Terminated.ExitCode != 0 {
 				anyFailed = true
 			}
-		} else if !isSidecarContainer {
+		} else {
 			allTerminated = false
 		}
 
@@ -100,6 +126,17 @@ func getStatus(obj *corev1.Pod, labels labelSet) (status *v1.JobStatus, err erro
 			maxRestart = cs.RestartCount
 		}
 	}
+	for _, cs := range obj.Status.InitContainerStatuses {
+		// restarted init containers report their failure as last state
+		t := cs.State.Terminated
+		if t == nil || t.ExitCode == 0 {
+			t = cs.LastTerminationState.Terminated
+		}
+		if t != nil && t.ExitCode != 0 {
+			initFailure = fmt.Sprintf("init container %s failed with exit code %d", cs.Name, t.ExitCode)
+			break
+		}
+	}
 	status.Conditions.FailureCount = maxRestart
 	status.Conditions.Success = !(anyFailed || maxRestart > getFailureLimit(obj, labels))
 	status.Conditions.DidExecute = obj.Status.Phase != "" || len(statuses) > 0
@@ -110,6 +147,7 @@ func getStatus(obj *corev1.Pod, labels labelSet) (status *v1.JobStatus, err erro
 			status.Phase = v1.JobPhase_PHASE_CLEANUP
 		}
 		status.Conditions.Success = false
+		_, status.Conditions.TimedOut = obj.Annotations[labels.AnnotationTimedOut]
 		status.Details = msg
 
 		return
@@ -120,10 +158,16 @@ func getStatus(obj *corev1.Pod, labels labelSet) (status *v1.JobStatus, err erro
 	}
 	if maxRestart > getFailureLimit(obj, labels) {
 		status.Phase = v1.JobPhase_PHASE_DONE
+		status.Details = initFailure
 		return
 	}
 	if allTerminated {
 		status.Phase = v1.JobPhase_PHASE_DONE
+		if status.Conditions.Success && collectingArtifacts(obj, labels) {
+			// the job is done once its outputs are in the artifact store
+			status.Phase = v1.JobPhase_PHASE_RUNNING
+			status.Details = collectingArtifactsDetails
+		}
 		return
 	}
 
@@ -133,6 +177,14 @@ func getStatus(obj *corev1.Pod, labels labelSet) (status *v1.JobStatus, err erro
 		return
 	case corev1.PodRunning:
 		status.Phase = v1.JobPhase_PHASE_RUNNING
+	case corev1.PodFailed:
+		// e.g. an init container failed and the pod does not restart it
+		status.Phase = v1.JobPhase_PHASE_DONE
+		status.Conditions.Success = false
+		status.Details = initFailure
+		if status.Details == "" {
+			status.Details = obj.Status.Message
+		}
 	}
 
 	return
@@ -148,6 +200,125 @@ func getFailureLimit(obj *corev1.Pod, labels labelSet) int32 {
 	return int32(res)
 }
 
+// getTimeout returns the total timeout of a job, or def if the job has no timeout of its own
+func getTimeout(obj *corev1.Pod, labels labelSet, def time.Duration) time.Duration {
+	val := obj.Annotations[labels.AnnotationTimeout]
+	if val == "" {
+		return def
+	}
+
+	res, err := time.ParseDuration(val)
+	if err != nil || res <= 0 {
+		return def
+	}
+	return res
+}
+
+// getGracePeriod returns how long the containers of a job get to stop, or def if the job does not specify this itself
+func getGracePeriod(obj *corev1.Pod, labels labelSet, def time.Duration) time.Duration {
+	val := obj.Annotations[labels.AnnotationGracePeriod]
+	if val == "" {
+		return def
+	}
+
+	res, err := time.ParseDuration(val)
+	if err != nil || res <= 0 {
+		return def
+	}
+	return res
+}
+
+// getPodTTL returns how long the pod of a finished job is kept, or def if the job does not specify this itself
+func getPodTTL(obj *corev1.Pod, labels labelSet, def time.Duration) time.Duration {
+	val, ok := obj.Annotations[labels.AnnotationPodTTL]
+	if !ok {
+		return def
+	}
+
+	res, err := time.ParseDuration(val)
+	if err != nil || res < 0 {
+		return def
+	}
+	return res
+}
+
+// getFinished returns the time a job was first seen done
+func getFinished(obj *corev1.Pod, labels labelSet) (time.Time, bool) {
+	val, ok := obj.Annotations[labels.AnnotationFinished]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	res, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return res, true
+}
+
+// getStarted returns the time the first of the job's containers started running. Init containers don't count,
+// as they prepare the job. Restarted containers count from their first start.
+func getStarted(obj *corev1.Pod) (started time.Time, ok bool) {
+	for _, cs := range obj.Status.ContainerStatuses {
+		for _, st := range []corev1.ContainerState{cs.State, cs.LastTerminationState} {
+			var t time.Time
+			if st.Running != nil {
+				t = st.Running.StartedAt.Time
+			} else if st.Terminated != nil {
+				t = st.Terminated.StartedAt.Time
+			}
+			if t.IsZero() {
+				continue
+			}
+			if !ok || t.Before(started) {
+				started, ok = t, true
+			}
+		}
+	}
+	return started, ok
+}
+
+// isSidecar returns true if the container is a sidecar of the job
+func isSidecar(obj *corev1.Pod, labels labelSet, container string) bool {
+	for _, s := range strings.Fields(obj.Annotations[labels.AnnotationSidecars]) {
+		if s == container {
+			return true
+		}
+	}
+	return false
+}
+
+// sidecarsRunning returns true if all containers of a pod but some of its sidecars have terminated
+func sidecarsRunning(obj *corev1.Pod, labels labelSet) (running bool) {
+	if len(obj.Status.ContainerStatuses) == 0 {
+		return false
+	}
+	for _, cs := range obj.Status.ContainerStatuses {
+		if cs.State.Terminated != nil {
+			continue
+		}
+		if !isSidecar(obj, labels, cs.Name) {
+			return false
+		}
+		running = true
+	}
+	return running
+}
+
+// containersTerminated returns true if all containers of a pod have terminated
+func containersTerminated(obj *corev1.Pod) bool {
+	statuses := append(obj.Status.InitContainerStatuses, obj.Status.ContainerStatuses...)
+	if len(statuses) == 0 {
+		return false
+	}
+	for _, cs := range statuses {
+		if cs.State.Terminated == nil {
+			return false
+		}
+	}
+	return true
+}
+
 func getJobName(obj *corev1.Pod, labels labelSet) (id string, ok bool) {
 	id, ok = obj.Labels[labels.LabelJobName]
 	return
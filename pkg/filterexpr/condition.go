@@ -0,0 +1,162 @@
+package filterexpr
+
+import (
+	"strings"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"golang.org/x/xerrors"
+)
+
+// Condition combines filter terms using && (and), || (or), ! (not) and parentheses,
+// e.g. trigger==push && (repo.ref==refs/heads/main || annotation.release==true).
+// && binds stronger than ||.
+type Condition struct {
+	op   string
+	term *v1.FilterTerm
+	args []*Condition
+}
+
+// Matches returns true if the job satisfies the condition
+func (c *Condition) Matches(js *v1.JobStatus) bool {
+	switch c.op {
+	case "&&":
+		for _, a := range c.args {
+			if !a.Matches(js) {
+				return false
+			}
+		}
+		return true
+	case "||":
+		for _, a := range c.args {
+			if a.Matches(js) {
+				return true
+			}
+		}
+		return false
+	case "!":
+		return !c.args[0].Matches(js)
+	default:
+		return MatchesFilter(js, []*v1.FilterExpression{{Terms: []*v1.FilterTerm{c.term}}})
+	}
+}
+
+// ParseCondition parses a condition. Its terms are the same as in filter expressions, e.g. repo.ref|=refs/tags/.
+func ParseCondition(expr string) (*Condition, error) {
+	p := &conditionParser{expr: expr}
+	res, err := p.parseOr()
+	if err != nil {
+		return nil, xerrors.Errorf("invalid condition %q: %w", expr, err)
+	}
+	p.skipSpace()
+	if p.pos < len(p.expr) {
+		return nil, xerrors.Errorf("invalid condition %q: unexpected %q at position %d", expr, p.expr[p.pos:], p.pos)
+	}
+	return res, nil
+}
+
+type conditionParser struct {
+	expr string
+	pos  int
+}
+
+func (p *conditionParser) skipSpace() {
+	for p.pos < len(p.expr) && (p.expr[p.pos] == ' ' || p.expr[p.pos] == '\t' || p.expr[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+// consume advances past tkn if the expression continues with it
+func (p *conditionParser) consume(tkn string) bool {
+	p.skipSpace()
+	if !strings.HasPrefix(p.expr[p.pos:], tkn) {
+		return false
+	}
+	p.pos += len(tkn)
+	return true
+}
+
+func (p *conditionParser) parseOr() (*Condition, error) {
+	return p.parseBinary("||", p.parseAnd)
+}
+
+func (p *conditionParser) parseAnd() (*Condition, error) {
+	return p.parseBinary("&&", p.parseUnary)
+}
+
+func (p *conditionParser) parseBinary(op string, operand func() (*Condition, error)) (*Condition, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	args := []*Condition{first}
+	for p.consume(op) {
+		next, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, next)
+	}
+	if len(args) == 1 {
+		return first, nil
+	}
+	return &Condition{op: op, args: args}, nil
+}
+
+func (p *conditionParser) parseUnary() (*Condition, error) {
+	if p.consume("!") {
+		arg, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Condition{op: "!", args: []*Condition{arg}}, nil
+	}
+	if p.consume("(") {
+		res, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, xerrors.Errorf("missing ) at position %d", p.pos)
+		}
+		return res, nil
+	}
+	return p.parseTerm()
+}
+
+// parseTerm reads a filter term up to the next &&, || or ) which is not within quotes
+func (p *conditionParser) parseTerm() (*Condition, error) {
+	p.skipSpace()
+	var (
+		start = p.pos
+		quote byte
+	)
+	for ; p.pos < len(p.expr); p.pos++ {
+		c := p.expr[p.pos]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		if c == '"' || c == '\'' {
+			quote = c
+			continue
+		}
+		if c == ')' || strings.HasPrefix(p.expr[p.pos:], "&&") || strings.HasPrefix(p.expr[p.pos:], "||") {
+			break
+		}
+	}
+	if quote != 0 {
+		return nil, xerrors.Errorf("unterminated quote at position %d", start)
+	}
+
+	term := strings.TrimSpace(p.expr[start:p.pos])
+	if term == "" {
+		return nil, xerrors.Errorf("missing term at position %d", start)
+	}
+	terms, err := Parse([]string{term})
+	if err != nil {
+		return nil, xerrors.Errorf("%s: %w", term, err)
+	}
+	return &Condition{term: terms[0]}, nil
+}
@@ -0,0 +1,67 @@
+package filterexpr_test
+
+import (
+	"strings"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/filterexpr"
+)
+
+func TestCondition(t *testing.T) {
+	job := &v1.JobStatus{
+		Name: "werft-build-main.1",
+		Metadata: &v1.JobMetadata{
+			Owner:       "alice",
+			Trigger:     v1.JobTrigger_TRIGGER_PUSH,
+			Repository:  &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: "refs/heads/main"},
+			Annotations: []*v1.Annotation{{Key: "release", Value: "true"}},
+		},
+	}
+
+	tests := []struct {
+		Condition string
+		Matches   bool
+		Error     string
+	}{
+		{Condition: `trigger == "push" && repo.ref == "refs/heads/main"`, Matches: true},
+		{Condition: `trigger == "push" && repo.ref == "refs/heads/dev"`, Matches: false},
+		{Condition: `trigger==manual || owner==alice`, Matches: true},
+		{Condition: `trigger==manual || owner==bob`, Matches: false},
+		{Condition: `!trigger==manual`, Matches: true},
+		{Condition: `!(trigger==push && owner==alice)`, Matches: false},
+		{Condition: `trigger==manual || owner==bob && repo.repo==werft`, Matches: false},
+		{Condition: `(trigger==push || owner==bob) && repo.repo==werft`, Matches: true},
+		{Condition: `trigger==manual || owner==alice && repo.repo==werft`, Matches: true},
+		{Condition: `annotation.release==true && repo.ref|=refs/heads/`, Matches: true},
+		{Condition: `annotation.release && trigger==push`, Matches: true},
+		{Condition: `!annotation.skip`, Matches: true},
+		{Condition: `!annotation.release`, Matches: false},
+		{Condition: `repo.ref=~"^refs/heads/(main|master)$"`, Matches: true},
+		{Condition: `name == "a && b"`, Matches: false},
+		{Condition: ``, Error: "missing term"},
+		{Condition: `trigger==push &&`, Error: "missing term"},
+		{Condition: `(trigger==push`, Error: "missing )"},
+		{Condition: `trigger==push)`, Error: `unexpected ")"`},
+		{Condition: `trigger push`, Error: "missing operator"},
+		{Condition: `branch==main`, Error: "unknown field branch"},
+		{Condition: `name=="main`, Error: "unterminated quote"},
+	}
+	for _, test := range tests {
+		t.Run(test.Condition, func(t *testing.T) {
+			cond, err := filterexpr.ParseCondition(test.Condition)
+			if test.Error != "" {
+				if err == nil || !strings.Contains(err.Error(), test.Error) {
+					t.Fatalf("expected error containing %q, got %v", test.Error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if act := cond.Matches(job); act != test.Matches {
+				t.Errorf("expected match to be %v", test.Matches)
+			}
+		})
+	}
+}
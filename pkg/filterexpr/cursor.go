@@ -0,0 +1,104 @@
+package filterexpr
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"golang.org/x/xerrors"
+)
+
+// cursor is the position of a job in a list of jobs. It holds the values the job was ordered by.
+type cursor struct {
+	Order  []string `json:"o"`
+	Values []string `json:"v"`
+}
+
+// PaginationOrder returns the order which cursors page through. Jobs are ordered by name last, so that
+// each job has a unique position. Jobs cannot be paged through using a cursor if they're ordered by a field
+// whose value changes over time, or which some jobs lack.
+func PaginationOrder(order []*v1.OrderExpression) ([]*v1.OrderExpression, error) {
+	res := make([]*v1.OrderExpression, 0, len(order)+1)
+	for _, o := range order {
+		switch o.Field {
+		case "duration", "started", "completed":
+			return nil, xerrors.Errorf("cannot page through jobs ordered by %s using a cursor", o.Field)
+		case "name":
+			return append(res, o), nil
+		}
+		res = append(res, o)
+	}
+	return append(res, &v1.OrderExpression{Field: "name", Ascending: true}), nil
+}
+
+// NewCursor produces an opaque cursor pointing at the position of the job in a list ordered using a pagination order
+func NewCursor(job *v1.JobStatus, order []*v1.OrderExpression) string {
+	var (
+		idx = jobFields(job)
+		c   cursor
+	)
+	for _, o := range order {
+		c.Order = append(c.Order, orderKey(o))
+		c.Values = append(c.Values, idx[o.Field])
+	}
+
+	// a cursor consists of strings only - marshalling cannot fail
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// CursorFilter produces the filter which matches all jobs after the cursor in a list ordered using the pagination order
+// the cursor was produced with. For example, if jobs are ordered by owner and name, the filter matches jobs whose owner
+// comes after the cursor's, or whose owner is the same but whose name comes after the cursor's.
+func CursorFilter(token string, order []*v1.OrderExpression) ([]*v1.FilterExpression, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid cursor")
+	}
+	var c cursor
+	err = json.Unmarshal(raw, &c)
+	if err != nil || len(c.Order) != len(c.Values) {
+		return nil, xerrors.Errorf("invalid cursor")
+	}
+	if len(c.Order) != len(order) {
+		return nil, xerrors.Errorf("cursor does not match the order - was it produced for another list?")
+	}
+	for i, o := range order {
+		if c.Order[i] != orderKey(o) {
+			return nil, xerrors.Errorf("cursor does not match the order - was it produced for another list?")
+		}
+	}
+
+	// The filter is a conjunction of disjunctions, hence "a after x, or a is x and b after y" becomes
+	// "(a after x or a is x) and (a after x or b after y)". Only the last field, which is unique, must be strictly after.
+	var (
+		res   = make([]*v1.FilterExpression, len(order))
+		after []*v1.FilterTerm
+	)
+	for i, o := range order {
+		op := v1.FilterOp_OP_GREATER
+		if !o.Ascending {
+			op = v1.FilterOp_OP_LESS
+		}
+		term := &v1.FilterTerm{Field: o.Field, Value: c.Values[i], Operation: op}
+
+		terms := append([]*v1.FilterTerm{}, after...)
+		terms = append(terms, term)
+		if i < len(order)-1 {
+			terms = append(terms, &v1.FilterTerm{Field: o.Field, Value: c.Values[i], Operation: v1.FilterOp_OP_EQUALS})
+		}
+		res[i] = &v1.FilterExpression{Terms: terms}
+		after = append(after, term)
+	}
+	return res, nil
+}
+
+// orderKey identifies an order expression within a cursor
+func orderKey(o *v1.OrderExpression) string {
+	dir := "desc"
+	if o.Ascending {
+		dir = "asc"
+	}
+	return strings.Join([]string{o.Field, dir}, ":")
+}
@@ -0,0 +1,160 @@
+package filterexpr_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/filterexpr"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+func TestPaginationOrder(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Order       []string
+		Expectation []string
+		Error       string
+	}{
+		{Name: "no order", Expectation: []string{"name:asc"}},
+		{Name: "created", Order: []string{"created:desc"}, Expectation: []string{"created:desc", "name:asc"}},
+		{Name: "name", Order: []string{"owner:asc", "name:desc"}, Expectation: []string{"owner:asc", "name:desc"}},
+		{Name: "after name", Order: []string{"name:desc", "created:asc"}, Expectation: []string{"name:desc"}},
+		{Name: "duration", Order: []string{"owner:asc", "duration:desc"}, Error: "cannot page through jobs ordered by duration using a cursor"},
+		{Name: "completed", Order: []string{"completed:desc"}, Error: "cannot page through jobs ordered by completed using a cursor"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			order, err := filterexpr.ParseOrder(test.Order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := filterexpr.PaginationOrder(order)
+			if test.Error != "" {
+				if err == nil || err.Error() != test.Error {
+					t.Fatalf("expected error %q, got %v", test.Error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var act []string
+			for _, o := range res {
+				dir := "desc"
+				if o.Ascending {
+					dir = "asc"
+				}
+				act = append(act, o.Field+":"+dir)
+			}
+			if !reflect.DeepEqual(act, test.Expectation) {
+				t.Errorf("unexpected order: %v, expected %v", act, test.Expectation)
+			}
+		})
+	}
+}
+
+func TestCursorFilter(t *testing.T) {
+	job := func(name, owner string, created int64) v1.JobStatus {
+		return v1.JobStatus{Name: name, Metadata: &v1.JobMetadata{Owner: owner, Created: &timestamp.Timestamp{Seconds: created}}}
+	}
+	jobs := []v1.JobStatus{
+		job("a", "foo", 1000),
+		job("b", "foo", 2000),
+		job("c", "bar", 2000),
+		job("d", "bar", 3000),
+		job("e", "foo", 3000),
+		job("f", "bar", 4000),
+	}
+
+	tests := []struct {
+		Name        string
+		Order       []string
+		Expectation []string
+	}{
+		{Name: "created", Order: []string{"created:desc"}, Expectation: []string{"f", "d", "e", "b", "c", "a"}},
+		{Name: "owner and created", Order: []string{"owner:asc", "created:asc"}, Expectation: []string{"c", "d", "f", "a", "b", "e"}},
+		{Name: "name", Order: []string{"name:desc"}, Expectation: []string{"f", "e", "d", "c", "b", "a"}},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			order := paginationOrder(t, test.Order...)
+
+			// page through the jobs one at a time - each page must hold the job following the previous one
+			var (
+				act    []string
+				cursor string
+			)
+			for i := 0; i < len(jobs)+1; i++ {
+				var (
+					filter []*v1.FilterExpression
+					err    error
+				)
+				if cursor != "" {
+					filter, err = filterexpr.CursorFilter(cursor, order)
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+				var page []v1.JobStatus
+				for _, j := range jobs {
+					if filterexpr.MatchesFilter(&j, filter) {
+						page = append(page, j)
+					}
+				}
+				if len(page) == 0 {
+					break
+				}
+				filterexpr.SortJobs(page, order)
+				act = append(act, page[0].Name)
+				cursor = filterexpr.NewCursor(&page[0], order)
+			}
+			if !reflect.DeepEqual(act, test.Expectation) {
+				t.Errorf("unexpected jobs: %v, expected %v", act, test.Expectation)
+			}
+		})
+	}
+}
+
+func TestCursorFilterInvalid(t *testing.T) {
+	var (
+		byCreated = paginationOrder(t, "created:desc")
+		byName    = paginationOrder(t)
+		cursor    = filterexpr.NewCursor(&v1.JobStatus{Name: "a"}, byCreated)
+	)
+
+	tests := []struct {
+		Name   string
+		Cursor string
+		Order  []*v1.OrderExpression
+		Error  string
+	}{
+		{Name: "garbage", Cursor: "not a cursor", Order: byCreated, Error: "invalid cursor"},
+		{Name: "not JSON", Cursor: "Zm9v", Order: byCreated, Error: "invalid cursor"},
+		{Name: "other order", Cursor: cursor, Order: byName, Error: "cursor does not match the order"},
+		{Name: "other direction", Cursor: cursor, Order: paginationOrder(t, "created:asc"), Error: "cursor does not match the order"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			_, err := filterexpr.CursorFilter(test.Cursor, test.Order)
+			if err == nil || !strings.Contains(err.Error(), test.Error) {
+				t.Errorf("expected error containing %q, got %v", test.Error, err)
+			}
+		})
+	}
+}
+
+// paginationOrder parses the order expressions and completes them the way the server does when paging through jobs
+func paginationOrder(t *testing.T, exprs ...string) []*v1.OrderExpression {
+	order, err := filterexpr.ParseOrder(exprs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err = filterexpr.PaginationOrder(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return order
+}
@@ -0,0 +1,10 @@
+package filterexpr
+
+import "time"
+
+// FreezeTime makes relative times and durations resolve against now. It returns a function which restores the clock.
+func FreezeTime(now time.Time) (restore func()) {
+	prev := timeNow
+	timeNow = func() time.Time { return now }
+	return func() { timeNow = prev }
+}
@@ -2,58 +2,191 @@ package filterexpr
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/golang/protobuf/ptypes"
 	"golang.org/x/xerrors"
 )
 
 // ErrMissingOp indicates that the expression was not complete
 var ErrMissingOp = fmt.Errorf("missing operator")
 
+// Fields lists all fields that can be filtered on. Additionally, annotations can be filtered
+// on using annotation.<key> and results using result.<type>.
+var Fields = []string{"name", "trigger", "owner", "phase", "repo", "repo.owner", "repo.repo", "repo.host", "repo.ref", "repo.revision", "success", "created", "started", "completed", "duration"}
+
+// fieldAliases maps the names fields had in earlier versions to the fields, so that filters which use those
+// names keep working. Aliases are not listed in Fields.
+var fieldAliases = map[string]string{
+	"repo.rev": "repo.revision",
+}
+
+// IsTimeField returns true if the field holds a timestamp: the time a job was created, started running or
+// completed. Jobs which haven't started or completed yet don't have a value for these fields.
+func IsTimeField(field string) bool {
+	return field == "created" || field == "started" || field == "completed"
+}
+
+// IsTimeComparison returns true if the operation compares the values of time fields as points in time, i.e.
+// the value must be a time. All other operations treat the times as RFC3339 text, e.g. created~=2019.
+func IsTimeComparison(op v1.FilterOp) bool {
+	return op == v1.FilterOp_OP_EQUALS || op == v1.FilterOp_OP_GREATER || op == v1.FilterOp_OP_LESS
+}
+
+// annotationFieldPrefix is the prefix of fields which filter on annotations
+const annotationFieldPrefix = "annotation."
+
+// resultFieldPrefix is the prefix of fields which filter on the payload of results. A job matches
+// if any of its results of that type matches.
+const resultFieldPrefix = "result."
+
+// validateField returns an error if field is not a known filter field
+func validateField(field string) error {
+	if strings.HasPrefix(field, annotationFieldPrefix) && len(field) > len(annotationFieldPrefix) {
+		return nil
+	}
+	if strings.HasPrefix(field, resultFieldPrefix) && len(field) > len(resultFieldPrefix) {
+		return nil
+	}
+	if _, ok := fieldAliases[field]; ok {
+		return nil
+	}
+	for _, f := range Fields {
+		if f == field {
+			return nil
+		}
+	}
+	return xerrors.Errorf("unknown field %s - valid fields are: %s, %s<key>, %s<type>", field, strings.Join(Fields, ", "), annotationFieldPrefix, resultFieldPrefix)
+}
+
+// ParseExpressions parses a list of filter expressions where each expression is a comma-separated
+// list of terms. Terms within an expression are OR'ed, while the expressions themselves are AND'ed.
+// For example, ["phase==running,phase==starting", "owner==foo"] finds all running or starting jobs
+// owned by foo.
+func ParseExpressions(exprs []string) ([]*v1.FilterExpression, error) {
+	res := make([]*v1.FilterExpression, 0, len(exprs))
+	for _, expr := range exprs {
+		terms, err := Parse(splitUnquoted(expr, ','))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, &v1.FilterExpression{Terms: terms})
+	}
+	return res, nil
+}
+
 // Parse parses a list of expressions
 func Parse(exprs []string) ([]*v1.FilterTerm, error) {
-	ops := map[string]v1.FilterOp{
-		"==": v1.FilterOp_OP_EQUALS,
-		"~=": v1.FilterOp_OP_CONTAINS,
-		"|=": v1.FilterOp_OP_STARTS_WITH,
-		"=|": v1.FilterOp_OP_ENDS_WITH,
+	ops := []struct {
+		Name string
+		Op   v1.FilterOp
+		Neg  bool
+	}{
+		{"!==", v1.FilterOp_OP_EQUALS, true},
+		{"!~=", v1.FilterOp_OP_CONTAINS, true},
+		{"!|=", v1.FilterOp_OP_STARTS_WITH, true},
+		{"!=|", v1.FilterOp_OP_ENDS_WITH, true},
+		{"!=~", v1.FilterOp_OP_MATCHES, true},
+		{"==", v1.FilterOp_OP_EQUALS, false},
+		{"~=", v1.FilterOp_OP_CONTAINS, false},
+		{"|=", v1.FilterOp_OP_STARTS_WITH, false},
+		{"=|", v1.FilterOp_OP_ENDS_WITH, false},
+		{"=~", v1.FilterOp_OP_MATCHES, false},
+		{"!=", v1.FilterOp_OP_EQUALS, true},
+		{">", v1.FilterOp_OP_GREATER, false},
+		{"<", v1.FilterOp_OP_LESS, false},
 	}
 
 	res := make([]*v1.FilterTerm, len(exprs))
 	for i, expr := range exprs {
+		// We look for the first operator in the expression, preferring the longest match at that
+		// position (e.g. !== over !=). Everything after that operator is the value, even if it
+		// contains operator-like characters itself. Operators within quotes are ignored.
 		var (
-			op  v1.FilterOp
-			opn string
-			neg bool
+			op    v1.FilterOp
+			opn   string
+			neg   bool
+			pos   = -1
+			quote byte
 		)
-		for k, v := range ops {
-			if strings.Contains(expr, "!"+k) {
-				op = v
-				opn = "!" + k
-				neg = true
-				break
+		for i := 0; i < len(expr) && pos < 0; i++ {
+			if quote != 0 {
+				if expr[i] == quote {
+					quote = 0
+				}
+				continue
 			}
-			if strings.Contains(expr, k) {
-				op = v
-				opn = k
-				break
+			if expr[i] == '"' || expr[i] == '\'' {
+				quote = expr[i]
+				continue
+			}
+
+			for _, o := range ops {
+				if !strings.HasPrefix(expr[i:], o.Name) || len(o.Name) <= len(opn) {
+					continue
+				}
+				op = o.Op
+				opn = o.Name
+				neg = o.Neg
+				pos = i
 			}
 		}
-		if opn == "" {
-			return nil, ErrMissingOp
+		if pos < 0 {
+			term, ok := parseExists(expr)
+			if !ok {
+				return nil, ErrMissingOp
+			}
+			res[i] = term
+			continue
 		}
 
-		segs := strings.Split(expr, opn)
-		field, val := strings.TrimSpace(segs[0]), strings.TrimSpace(segs[1])
+		field, val := strings.TrimSpace(expr[:pos]), unquote(strings.TrimSpace(expr[pos+len(opn):]))
+		if err := validateField(field); err != nil {
+			return nil, err
+		}
 		if field == "success" {
-			if val == "true" {
+			switch strings.ToLower(val) {
+			case "true", "1", "yes":
 				val = "1"
-			} else {
+			case "false", "0", "no":
 				val = "0"
+			default:
+				return nil, xerrors.Errorf("invalid success value: %s (must be one of true, false, 1, 0, yes, no)", val)
+			}
+		}
+		if op == v1.FilterOp_OP_MATCHES {
+			if _, err := regexp.Compile(val); err != nil {
+				return nil, xerrors.Errorf("invalid regular expression %s: %w", val, err)
+			}
+		}
+		if IsTimeField(field) && IsTimeComparison(op) && strings.HasPrefix(val, "-") {
+			ts, err := parseRelativeTime(field, val)
+			if err != nil {
+				return nil, err
+			}
+			val = ts
+		}
+		if IsTimeField(field) && IsTimeComparison(op) {
+			if _, err := time.Parse(time.RFC3339, val); err != nil {
+				return nil, xerrors.Errorf("invalid time for %s: %s (expected RFC3339, e.g. 2019-01-01T00:00:00Z)", field, val)
+			}
+		}
+		if field == "duration" {
+			if op != v1.FilterOp_OP_GREATER && op != v1.FilterOp_OP_LESS {
+				return nil, xerrors.Errorf("duration supports only the > and < operators")
+			}
+			if _, err := time.ParseDuration(val); err != nil {
+				return nil, xerrors.Errorf("invalid duration: %s (expected e.g. 10m or 1h30m)", val)
 			}
 		}
-		if field == "phase" {
+		if field == "phase" && op != v1.FilterOp_OP_MATCHES {
+			val = NormalizePhase(val)
 			phn := strings.ToUpper(fmt.Sprintf("PHASE_%s", val))
 			if _, ok := v1.JobPhase_value[phn]; !ok {
 				return nil, xerrors.Errorf("invalid phase: %s", val)
@@ -71,6 +204,23 @@ func Parse(exprs []string) ([]*v1.FilterTerm, error) {
 	return res, nil
 }
 
+// parseExists parses an expression without operator, e.g. annotation.release or !annotation.release,
+// which checks if a job has (or doesn't have) an annotation or result at all, regardless of its value
+func parseExists(expr string) (term *v1.FilterTerm, ok bool) {
+	field := strings.TrimSpace(expr)
+	neg := strings.HasPrefix(field, "!")
+	if neg {
+		field = strings.TrimSpace(strings.TrimPrefix(field, "!"))
+	}
+	if !strings.HasPrefix(field, annotationFieldPrefix) && !strings.HasPrefix(field, resultFieldPrefix) {
+		return nil, false
+	}
+	if validateField(field) != nil {
+		return nil, false
+	}
+	return &v1.FilterTerm{Field: field, Operation: v1.FilterOp_OP_EXISTS, Negate: neg}, true
+}
+
 // MatchesFilter returns true if the annotations are matched by the filter
 func MatchesFilter(js *v1.JobStatus, filter []*v1.FilterExpression) (matches bool) {
 	if len(filter) == 0 {
@@ -80,60 +230,466 @@ func MatchesFilter(js *v1.JobStatus, filter []*v1.FilterExpression) (matches boo
 		return false
 	}
 
+	idx := jobFields(js)
+
+	matches = true
+	for _, req := range filter {
+		var tm bool
+		for _, alt := range req.Terms {
+			if alt.Operation == v1.FilterOp_OP_EXISTS {
+				// a job without the field matches a negated exists term, just like it does in the database
+				tm = hasField(js, idx, alt.Field) != alt.Negate
+			} else if strings.HasPrefix(alt.Field, resultFieldPrefix) {
+				tpe := strings.TrimPrefix(alt.Field, resultFieldPrefix)
+				for _, r := range js.Results {
+					if r.Type == tpe && matchesTerm(alt, r.Payload) {
+						tm = true
+						break
+					}
+				}
+			} else if val, ok := idx[alt.Field]; ok {
+				tm = matchesTerm(alt, val)
+			}
+
+			if tm {
+				break
+			}
+		}
+
+		if !tm {
+			matches = false
+			break
+		}
+	}
+	return matches
+}
+
+// hasField returns true if the job has a value for the field, e.g. the annotation or a result of the type
+func hasField(js *v1.JobStatus, idx map[string]string, field string) bool {
+	if strings.HasPrefix(field, resultFieldPrefix) {
+		tpe := strings.TrimPrefix(field, resultFieldPrefix)
+		for _, r := range js.Results {
+			if r.Type == tpe {
+				return true
+			}
+		}
+		return false
+	}
+	_, ok := idx[field]
+	return ok
+}
+
+// matchesTerm returns true if the value of the term's field matches the term
+func matchesTerm(alt *v1.FilterTerm, val string) (tm bool) {
+	if alt.Field == "phase" && alt.Operation != v1.FilterOp_OP_MATCHES {
+		alt = &v1.FilterTerm{Field: alt.Field, Value: NormalizePhase(alt.Value), Operation: alt.Operation, Negate: alt.Negate}
+	}
+	if alt.IgnoreCase && IsTextField(alt.Field) {
+		val = strings.ToLower(val)
+		if alt.Operation == v1.FilterOp_OP_MATCHES {
+			alt = &v1.FilterTerm{Field: alt.Field, Value: "(?i)" + alt.Value, Operation: alt.Operation, Negate: alt.Negate}
+		} else {
+			alt = &v1.FilterTerm{Field: alt.Field, Value: strings.ToLower(alt.Value), Operation: alt.Operation, Negate: alt.Negate}
+		}
+	}
+
+	switch alt.Operation {
+	case v1.FilterOp_OP_CONTAINS:
+		tm = strings.Contains(val, alt.Value)
+	case v1.FilterOp_OP_ENDS_WITH:
+		tm = strings.HasSuffix(val, alt.Value)
+	case v1.FilterOp_OP_EQUALS:
+		tm = val == alt.Value
+	case v1.FilterOp_OP_STARTS_WITH:
+		tm = strings.HasPrefix(val, alt.Value)
+	case v1.FilterOp_OP_EXISTS:
+		tm = true
+	case v1.FilterOp_OP_MATCHES:
+		re, err := compileRegexp(alt.Value)
+		if err != nil {
+			tm = false
+			break
+		}
+		tm = re.MatchString(val)
+	case v1.FilterOp_OP_GREATER:
+		cmp, ok := compareValues(alt.Field, val, alt.Value)
+		tm = ok && cmp > 0
+	case v1.FilterOp_OP_LESS:
+		cmp, ok := compareValues(alt.Field, val, alt.Value)
+		tm = ok && cmp < 0
+	}
+
+	if alt.Negate {
+		tm = !tm
+	}
+	return tm
+}
+
+// splitUnquoted splits s at each sep which is not within single or double quotes
+func splitUnquoted(s string, sep byte) []string {
+	var (
+		res   []string
+		quote byte
+		start int
+	)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == sep:
+			res = append(res, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(res, s[start:])
+}
+
+// unquote removes matching single or double quotes surrounding s
+func unquote(s string) string {
+	if len(s) < 2 {
+		return s
+	}
+	if (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// timeNow is the clock used to resolve relative times. Tests replace this to freeze time.
+var timeNow = time.Now
+
+// parseRelativeTime turns a relative time, e.g. -24h or -7d, into an absolute RFC3339 timestamp
+// relative to now. Besides the units supported by time.ParseDuration we support d for days.
+func parseRelativeTime(field, val string) (string, error) {
+	dur := strings.TrimPrefix(val, "-")
+
+	var (
+		d   time.Duration
+		err error
+	)
+	if strings.HasSuffix(dur, "d") {
+		var days float64
+		days, err = strconv.ParseFloat(strings.TrimSuffix(dur, "d"), 64)
+		d = time.Duration(days * float64(24*time.Hour))
+	} else {
+		d, err = time.ParseDuration(dur)
+	}
+	if err != nil || d < 0 {
+		return "", xerrors.Errorf("invalid relative time for %s: %s (expected e.g. -24h or -7d)", field, val)
+	}
+
+	return timeNow().Add(-d).UTC().Format(time.RFC3339), nil
+}
+
+// IsTextField returns true if the field holds free text, as opposed to enum, boolean or time values
+func IsTextField(field string) bool {
+	switch field {
+	case "phase", "trigger", "success", "created", "started", "completed", "duration":
+		return false
+	default:
+		return true
+	}
+}
+
+// NormalizePhase turns a phase filter value into its canonical representation, which is the
+// lowercase phase name without the PHASE_ prefix, e.g. running. It accepts the canonical form,
+// the enum name (PHASE_RUNNING) and the enum number (3). Values which are not a phase are
+// returned lowercased.
+func NormalizePhase(val string) string {
+	if n, err := strconv.ParseInt(val, 10, 32); err == nil {
+		if name, ok := v1.JobPhase_name[int32(n)]; ok {
+			val = name
+		}
+	}
+	return strings.TrimPrefix(strings.ToLower(val), "phase_")
+}
+
+// jobFields returns the values of all filterable fields of a job
+func jobFields(js *v1.JobStatus) map[string]string {
 	idx := map[string]string{
 		"name":  js.Name,
-		"phase": strings.ToLower(strings.TrimPrefix(js.Phase.String(), "PHASE_")),
+		"phase": NormalizePhase(js.Phase.String()),
+		// jobs without a repository are empty, just like in auth policies
+		"repo": "",
+	}
+	if js.Conditions != nil {
+		if js.Conditions.Success {
+			idx["success"] = "1"
+		} else {
+			idx["success"] = "0"
+		}
 	}
 	if js.Metadata != nil {
 		idx["owner"] = js.Metadata.Owner
-		idx["trigger"] = strings.ToLower(strings.TrimPrefix("TRIGGER_", js.Metadata.Trigger.String()))
+		idx["trigger"] = strings.ToLower(strings.TrimPrefix(js.Metadata.Trigger.String(), "TRIGGER_"))
+		if created, err := ptypes.Timestamp(js.Metadata.Created); err == nil {
+			idx["created"] = created.UTC().Format(time.RFC3339)
+
+			// the duration counts from the start of the job, or its creation if it hasn't started (yet).
+			// Running jobs don't have a finished time yet - their duration is measured against now.
+			begin := created
+			if started, err := ptypes.Timestamp(js.Metadata.Started); js.Metadata.Started != nil && err == nil {
+				begin = started
+			}
+			end := timeNow()
+			if finished, err := ptypes.Timestamp(js.Metadata.Finished); js.Metadata.Finished != nil && err == nil {
+				end = finished
+			}
+			idx["duration"] = end.Sub(begin).String()
+		}
+		if started, err := ptypes.Timestamp(js.Metadata.Started); js.Metadata.Started != nil && err == nil {
+			idx["started"] = started.UTC().Format(time.RFC3339)
+		}
+		if finished, err := ptypes.Timestamp(js.Metadata.Finished); js.Metadata.Finished != nil && err == nil {
+			idx["completed"] = finished.UTC().Format(time.RFC3339)
+		}
 		if js.Metadata.Repository != nil {
+			idx["repo"] = js.Metadata.Repository.Host + "/" + js.Metadata.Repository.Owner + "/" + js.Metadata.Repository.Repo
 			idx["repo.owner"] = js.Metadata.Repository.Owner
 			idx["repo.repo"] = js.Metadata.Repository.Repo
 			idx["repo.host"] = js.Metadata.Repository.Host
 			idx["repo.ref"] = js.Metadata.Repository.Ref
-			idx["repo.rev"] = js.Metadata.Repository.Revision
+			idx["repo.revision"] = js.Metadata.Repository.Revision
+		}
+		for _, at := range js.Metadata.Annotations {
+			idx[annotationFieldPrefix+at.Key] = at.Value
 		}
 	}
-	for _, at := range js.Metadata.Annotations {
-		idx["annotation."+at.Key] = at.Value
+	for alias, field := range fieldAliases {
+		if val, ok := idx[field]; ok {
+			idx[alias] = val
+		}
 	}
+	return idx
+}
 
-	matches = true
-	for _, req := range filter {
-		var tm bool
-		for _, alt := range req.Terms {
-			val, ok := idx[alt.Field]
-			if !ok {
+// ParseOrder parses a list of order expressions in the form of field:asc or field:desc, or
+// using the shorthand +field (ascending), -field (descending) or field (ascending).
+// The first expression is the primary sort key, the second one the secondary and so on.
+func ParseOrder(exprs []string) ([]*v1.OrderExpression, error) {
+	res := make([]*v1.OrderExpression, len(exprs))
+	for i, expr := range exprs {
+		var field, dir string
+		if segs := strings.Split(expr, ":"); len(segs) == 2 {
+			field, dir = strings.TrimSpace(segs[0]), strings.TrimSpace(segs[1])
+		} else if len(segs) > 2 {
+			return nil, xerrors.Errorf("invalid order expression: %s", expr)
+		} else if strings.HasPrefix(expr, "-") {
+			field, dir = strings.TrimSpace(expr[1:]), "desc"
+		} else {
+			field, dir = strings.TrimSpace(strings.TrimPrefix(expr, "+")), "asc"
+		}
+
+		if err := validateOrderField(field); err != nil {
+			return nil, err
+		}
+		if dir != "asc" && dir != "desc" {
+			return nil, xerrors.Errorf("invalid order direction in %s: must be asc or desc", expr)
+		}
+
+		res[i] = &v1.OrderExpression{
+			Field:     field,
+			Ascending: dir == "asc",
+		}
+	}
+	return res, nil
+}
+
+// validateOrderField returns an error if the job list cannot be ordered by field
+func validateOrderField(field string) error {
+	if _, ok := fieldAliases[field]; ok {
+		return nil
+	}
+	for _, f := range Fields {
+		if f == field {
+			return nil
+		}
+	}
+	return xerrors.Errorf("cannot order by %s - valid fields are: %s", field, strings.Join(Fields, ", "))
+}
+
+// SortJobs sorts jobs by the order expressions. The first expression is the primary sort key,
+// subsequent expressions break ties. Jobs which compare equal under all expressions are ordered
+// by name to make the order deterministic.
+func SortJobs(jobs []v1.JobStatus, order []*v1.OrderExpression) {
+	idx := make(map[string]map[string]string, len(jobs))
+	for i := range jobs {
+		idx[jobs[i].Name] = jobFields(&jobs[i])
+	}
+
+	sort.SliceStable(jobs, func(i, j int) bool {
+		a, b := idx[jobs[i].Name], idx[jobs[j].Name]
+		for _, o := range order {
+			cmp, _ := compareValues(o.Field, a[o.Field], b[o.Field])
+			if cmp == 0 {
 				continue
 			}
-
-			switch alt.Operation {
-			case v1.FilterOp_OP_CONTAINS:
-				tm = strings.Contains(val, alt.Value)
-			case v1.FilterOp_OP_ENDS_WITH:
-				tm = strings.HasSuffix(val, alt.Value)
-			case v1.FilterOp_OP_EQUALS:
-				tm = val == alt.Value
-			case v1.FilterOp_OP_STARTS_WITH:
-				tm = strings.HasPrefix(val, alt.Value)
-			case v1.FilterOp_OP_EXISTS:
-				tm = true
+			if o.Ascending {
+				return cmp < 0
 			}
+			return cmp > 0
+		}
+		return jobs[i].Name < jobs[j].Name
+	})
+}
 
-			if alt.Negate {
-				tm = !tm
-			}
+// ValidateGroup returns an error if jobs cannot be grouped by the fields. Jobs can be grouped by all
+// fields other than timestamps and duration, and by annotations using annotation.<key>.
+func ValidateGroup(fields []string) error {
+	for _, field := range fields {
+		if IsTimeField(field) || field == "duration" {
+			return xerrors.Errorf("cannot group by %s", field)
+		}
+		if strings.HasPrefix(field, annotationFieldPrefix) && len(field) > len(annotationFieldPrefix) {
+			continue
+		}
+		if err := validateOrderField(field); err != nil {
+			return xerrors.Errorf("cannot group by %s - valid fields are: %s, %s<key>", field, strings.Join(Fields, ", "), annotationFieldPrefix)
+		}
+	}
+	return nil
+}
 
-			if tm {
-				break
+// LatestPerGroup returns the newest job of each group of jobs which share the values of the fields.
+// Jobs which were created at the same time are ordered by name, the greater name being the newer job.
+// Jobs lacking an annotation we group by form a group of their own. The order of the result is undefined.
+func LatestPerGroup(jobs []v1.JobStatus, fields []string) []v1.JobStatus {
+	if len(fields) == 0 {
+		return jobs
+	}
+
+	var (
+		res    []v1.JobStatus
+		groups = make(map[string]int)
+	)
+	for i := range jobs {
+		idx := jobFields(&jobs[i])
+
+		key := make([]string, len(fields))
+		for j, f := range fields {
+			if v, ok := idx[f]; ok {
+				key[j] = strconv.Quote(v)
+			} else {
+				key[j] = "-"
 			}
 		}
+		k := strings.Join(key, ",")
 
-		if !tm {
-			matches = false
-			break
+		pos, exists := groups[k]
+		if !exists {
+			groups[k] = len(res)
+			res = append(res, jobs[i])
+			continue
+		}
+		if isNewer(&jobs[i], &res[pos]) {
+			res[pos] = jobs[i]
 		}
 	}
-	return matches
+	return res
+}
+
+// isNewer returns true if job a was created after job b, or at the same time and a's name is greater
+func isNewer(a, b *v1.JobStatus) bool {
+	var ca, cb time.Time
+	if a.Metadata != nil {
+		ca, _ = ptypes.Timestamp(a.Metadata.Created)
+	}
+	if b.Metadata != nil {
+		cb, _ = ptypes.Timestamp(b.Metadata.Created)
+	}
+	if !ca.Equal(cb) {
+		return ca.After(cb)
+	}
+	return a.Name > b.Name
+}
+
+// compareValues compares a and b and returns -1, 0 or 1 if a is less than, equal to or greater than b.
+// Time fields are compared as timestamps, numeric values are compared numerically and everything
+// else lexicographically. If the values cannot be compared ok is false.
+func compareValues(field, a, b string) (cmp int, ok bool) {
+	if field == "duration" {
+		da, err := time.ParseDuration(a)
+		if err != nil {
+			return 0, false
+		}
+		db, err := time.ParseDuration(b)
+		if err != nil {
+			return 0, false
+		}
+		switch {
+		case da < db:
+			return -1, true
+		case da > db:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+	if IsTimeField(field) {
+		ta, err := time.Parse(time.RFC3339, a)
+		if err != nil {
+			return 0, false
+		}
+		tb, err := time.Parse(time.RFC3339, b)
+		if err != nil {
+			return 0, false
+		}
+		switch {
+		case ta.Before(tb):
+			return -1, true
+		case ta.After(tb):
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+
+	na, erra := strconv.ParseFloat(a, 64)
+	nb, errb := strconv.ParseFloat(b, 64)
+	if erra == nil && errb == nil {
+		switch {
+		case na < nb:
+			return -1, true
+		case na > nb:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+
+	return strings.Compare(a, b), true
+}
+
+// maxRegexpCacheSize limits the number of compiled regular expressions we keep around
+const maxRegexpCacheSize = 256
+
+var regexpCache = struct {
+	mu  sync.Mutex
+	res map[string]*regexp.Regexp
+}{res: make(map[string]*regexp.Regexp)}
+
+// compileRegexp compiles a regular expression and caches the result. Filters are
+// typically evaluated against many jobs, hence we don't want to re-compile the
+// same expression for each job.
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	regexpCache.mu.Lock()
+	defer regexpCache.mu.Unlock()
+
+	if re, ok := regexpCache.res[expr]; ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(regexpCache.res) >= maxRegexpCacheSize {
+		regexpCache.res = make(map[string]*regexp.Regexp)
+	}
+	regexpCache.res[expr] = re
+	return re, nil
 }
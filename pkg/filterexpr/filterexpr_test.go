@@ -2,34 +2,116 @@ package filterexpr_test
 
 import (
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/alecthomas/repr"
 	v1 "github.com/csweichel/werft/pkg/api/v1"
 	"github.com/csweichel/werft/pkg/filterexpr"
+	"github.com/golang/protobuf/ptypes/timestamp"
 )
 
+// frozenNow is the time relative times resolve against in tests
+var frozenNow = time.Date(2020, 3, 15, 12, 0, 0, 0, time.UTC)
+
 func TestValidBasics(t *testing.T) {
+	defer filterexpr.FreezeTime(frozenNow)()
+
 	tests := []struct {
 		Input  string
 		Result *v1.FilterTerm
 		Error  string
 	}{
-		{"foo==bar", &v1.FilterTerm{Field: "foo", Value: "bar", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
-		{"foo!==bar", &v1.FilterTerm{Field: "foo", Value: "bar", Operation: v1.FilterOp_OP_EQUALS, Negate: true}, ""},
-		{"foo~=bar", &v1.FilterTerm{Field: "foo", Value: "bar", Operation: v1.FilterOp_OP_CONTAINS, Negate: false}, ""},
-		{"foo!~=bar", &v1.FilterTerm{Field: "foo", Value: "bar", Operation: v1.FilterOp_OP_CONTAINS, Negate: true}, ""},
-		{"foo|=bar", &v1.FilterTerm{Field: "foo", Value: "bar", Operation: v1.FilterOp_OP_STARTS_WITH, Negate: false}, ""},
-		{"foo!|=bar", &v1.FilterTerm{Field: "foo", Value: "bar", Operation: v1.FilterOp_OP_STARTS_WITH, Negate: true}, ""},
-		{"foo=|bar", &v1.FilterTerm{Field: "foo", Value: "bar", Operation: v1.FilterOp_OP_ENDS_WITH, Negate: false}, ""},
-		{"foo!=|bar", &v1.FilterTerm{Field: "foo", Value: "bar", Operation: v1.FilterOp_OP_ENDS_WITH, Negate: true}, ""},
+		{"name==bar", &v1.FilterTerm{Field: "name", Value: "bar", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{"name!==bar", &v1.FilterTerm{Field: "name", Value: "bar", Operation: v1.FilterOp_OP_EQUALS, Negate: true}, ""},
+		{"name~=bar", &v1.FilterTerm{Field: "name", Value: "bar", Operation: v1.FilterOp_OP_CONTAINS, Negate: false}, ""},
+		{"name!~=bar", &v1.FilterTerm{Field: "name", Value: "bar", Operation: v1.FilterOp_OP_CONTAINS, Negate: true}, ""},
+		{"name|=bar", &v1.FilterTerm{Field: "name", Value: "bar", Operation: v1.FilterOp_OP_STARTS_WITH, Negate: false}, ""},
+		{"name!|=bar", &v1.FilterTerm{Field: "name", Value: "bar", Operation: v1.FilterOp_OP_STARTS_WITH, Negate: true}, ""},
+		{"name=|bar", &v1.FilterTerm{Field: "name", Value: "bar", Operation: v1.FilterOp_OP_ENDS_WITH, Negate: false}, ""},
+		{"name!=|bar", &v1.FilterTerm{Field: "name", Value: "bar", Operation: v1.FilterOp_OP_ENDS_WITH, Negate: true}, ""},
+		{"name!=bar", &v1.FilterTerm{Field: "name", Value: "bar", Operation: v1.FilterOp_OP_EQUALS, Negate: true}, ""},
+		{"phase!=done", &v1.FilterTerm{Field: "phase", Value: "done", Operation: v1.FilterOp_OP_EQUALS, Negate: true}, ""},
+		{"name=~^ba[rz]$", &v1.FilterTerm{Field: "name", Value: "^ba[rz]$", Operation: v1.FilterOp_OP_MATCHES, Negate: false}, ""},
+		{"name!=~^ba[rz]$", &v1.FilterTerm{Field: "name", Value: "^ba[rz]$", Operation: v1.FilterOp_OP_MATCHES, Negate: true}, ""},
+		{"created>2019-01-01T00:00:00Z", &v1.FilterTerm{Field: "created", Value: "2019-01-01T00:00:00Z", Operation: v1.FilterOp_OP_GREATER, Negate: false}, ""},
+		{"created<2019-01-01T00:00:00Z", &v1.FilterTerm{Field: "created", Value: "2019-01-01T00:00:00Z", Operation: v1.FilterOp_OP_LESS, Negate: false}, ""},
+		{"created>yesterday", nil, "invalid time for created: yesterday (expected RFC3339, e.g. 2019-01-01T00:00:00Z)"},
+		{"created==yesterday", nil, "invalid time for created: yesterday (expected RFC3339, e.g. 2019-01-01T00:00:00Z)"},
+		{"created>-24h", &v1.FilterTerm{Field: "created", Value: "2020-03-14T12:00:00Z", Operation: v1.FilterOp_OP_GREATER, Negate: false}, ""},
+		{"created>-7d", &v1.FilterTerm{Field: "created", Value: "2020-03-08T12:00:00Z", Operation: v1.FilterOp_OP_GREATER, Negate: false}, ""},
+		{"created<-1.5d", &v1.FilterTerm{Field: "created", Value: "2020-03-14T00:00:00Z", Operation: v1.FilterOp_OP_LESS, Negate: false}, ""},
+		{"created>-90m", &v1.FilterTerm{Field: "created", Value: "2020-03-15T10:30:00Z", Operation: v1.FilterOp_OP_GREATER, Negate: false}, ""},
+		{"created>-2w", nil, "invalid relative time for created: -2w (expected e.g. -24h or -7d)"},
+		{"created>--1h", nil, "invalid relative time for created: --1h (expected e.g. -24h or -7d)"},
+		{"duration>10m", &v1.FilterTerm{Field: "duration", Value: "10m", Operation: v1.FilterOp_OP_GREATER, Negate: false}, ""},
+		{"duration<1h30m", &v1.FilterTerm{Field: "duration", Value: "1h30m", Operation: v1.FilterOp_OP_LESS, Negate: false}, ""},
+		{"duration==10m", nil, "duration supports only the > and < operators"},
+		{"duration>10 minutes", nil, "invalid duration: 10 minutes (expected e.g. 10m or 1h30m)"},
+		{"started>2020-03-15T11:00:00Z", &v1.FilterTerm{Field: "started", Value: "2020-03-15T11:00:00Z", Operation: v1.FilterOp_OP_GREATER, Negate: false}, ""},
+		{"completed<-7d", &v1.FilterTerm{Field: "completed", Value: "2020-03-08T12:00:00Z", Operation: v1.FilterOp_OP_LESS, Negate: false}, ""},
+		{"started>yesterday", nil, "invalid time for started: yesterday (expected RFC3339, e.g. 2019-01-01T00:00:00Z)"},
+		{"completed>-2w", nil, "invalid relative time for completed: -2w (expected e.g. -24h or -7d)"},
+		{"created~=2019", &v1.FilterTerm{Field: "created", Value: "2019", Operation: v1.FilterOp_OP_CONTAINS, Negate: false}, ""},
+		{"created|=2019-01", &v1.FilterTerm{Field: "created", Value: "2019-01", Operation: v1.FilterOp_OP_STARTS_WITH, Negate: false}, ""},
+		{"completed=|T00:00:00Z", &v1.FilterTerm{Field: "completed", Value: "T00:00:00Z", Operation: v1.FilterOp_OP_ENDS_WITH, Negate: false}, ""},
+		{"name==foo=|bar", &v1.FilterTerm{Field: "name", Value: "foo=|bar", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{"name|=a==b!=c", &v1.FilterTerm{Field: "name", Value: "a==b!=c", Operation: v1.FilterOp_OP_STARTS_WITH, Negate: false}, ""},
+		{"name!=foo~=bar", &v1.FilterTerm{Field: "name", Value: "foo~=bar", Operation: v1.FilterOp_OP_EQUALS, Negate: true}, ""},
+		{"name=~a|=b", &v1.FilterTerm{Field: "name", Value: "a|=b", Operation: v1.FilterOp_OP_MATCHES, Negate: false}, ""},
+		{"name==a>b", &v1.FilterTerm{Field: "name", Value: "a>b", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{"name==", &v1.FilterTerm{Field: "name", Value: "", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{`name=="my build step"`, &v1.FilterTerm{Field: "name", Value: "my build step", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{`repo.ref=='feature/foo'`, &v1.FilterTerm{Field: "repo.ref", Value: "feature/foo", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{`name=="a==b"`, &v1.FilterTerm{Field: "name", Value: "a==b", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{`name=="a!=b"`, &v1.FilterTerm{Field: "name", Value: "a!=b", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{`name=="a~=b"`, &v1.FilterTerm{Field: "name", Value: "a~=b", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{`name=="a|=b"`, &v1.FilterTerm{Field: "name", Value: "a|=b", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{`name=="a=|b"`, &v1.FilterTerm{Field: "name", Value: "a=|b", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{`name=="a=~b"`, &v1.FilterTerm{Field: "name", Value: "a=~b", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{`name=="a>b<c"`, &v1.FilterTerm{Field: "name", Value: "a>b<c", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{`name~='it"s'`, &v1.FilterTerm{Field: "name", Value: `it"s`, Operation: v1.FilterOp_OP_CONTAINS, Negate: false}, ""},
+		{`name=="foo`, &v1.FilterTerm{Field: "name", Value: `"foo`, Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{`"a==b"`, nil, "missing operator"},
+		{"name=~ba[r", nil, "invalid regular expression ba[r: error parsing regexp: missing closing ]: `[r`"},
 		{"success==true", &v1.FilterTerm{Field: "success", Value: "1", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
 		{"success==false", &v1.FilterTerm{Field: "success", Value: "0", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
 		{"success!==true", &v1.FilterTerm{Field: "success", Value: "1", Operation: v1.FilterOp_OP_EQUALS, Negate: true}, ""},
 		{"success!==false", &v1.FilterTerm{Field: "success", Value: "0", Operation: v1.FilterOp_OP_EQUALS, Negate: true}, ""},
-		{"trim == whitespace", &v1.FilterTerm{Field: "trim", Value: "whitespace", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{"success==TRUE", &v1.FilterTerm{Field: "success", Value: "1", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{"success==1", &v1.FilterTerm{Field: "success", Value: "1", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{"success==yes", &v1.FilterTerm{Field: "success", Value: "1", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{"success==Yes", &v1.FilterTerm{Field: "success", Value: "1", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{"success==False", &v1.FilterTerm{Field: "success", Value: "0", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{"success==0", &v1.FilterTerm{Field: "success", Value: "0", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{"success==no", &v1.FilterTerm{Field: "success", Value: "0", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{"success==NO", &v1.FilterTerm{Field: "success", Value: "0", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{"success==tru", nil, "invalid success value: tru (must be one of true, false, 1, 0, yes, no)"},
+		{"success==", nil, "invalid success value:  (must be one of true, false, 1, 0, yes, no)"},
+		{"owner == whitespace", &v1.FilterTerm{Field: "owner", Value: "whitespace", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
 		{"foo", nil, filterexpr.ErrMissingOp.Error()},
 		{"phase==blabla", nil, "invalid phase: blabla"},
+		{"phase==PHASE_RUNNING", &v1.FilterTerm{Field: "phase", Value: "running", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{"phase==Running", &v1.FilterTerm{Field: "phase", Value: "running", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{"phase==3", &v1.FilterTerm{Field: "phase", Value: "running", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{"phase==queued", &v1.FilterTerm{Field: "phase", Value: "queued", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{"phse==running", nil, "unknown field phse - valid fields are: name, trigger, owner, phase, repo, repo.owner, repo.repo, repo.host, repo.ref, repo.revision, success, created, started, completed, duration, annotation.<key>, result.<type>"},
+		{"annotation==foo", nil, "unknown field annotation - valid fields are: name, trigger, owner, phase, repo, repo.owner, repo.repo, repo.host, repo.ref, repo.revision, success, created, started, completed, duration, annotation.<key>, result.<type>"},
+		{"annotation.==foo", nil, "unknown field annotation. - valid fields are: name, trigger, owner, phase, repo, repo.owner, repo.repo, repo.host, repo.ref, repo.revision, success, created, started, completed, duration, annotation.<key>, result.<type>"},
+		{"annotation.version==1.0", &v1.FilterTerm{Field: "annotation.version", Value: "1.0", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{"result.url|=https://", &v1.FilterTerm{Field: "result.url", Value: "https://", Operation: v1.FilterOp_OP_STARTS_WITH, Negate: false}, ""},
+		{"annotation.release", &v1.FilterTerm{Field: "annotation.release", Operation: v1.FilterOp_OP_EXISTS, Negate: false}, ""},
+		{"!annotation.release", &v1.FilterTerm{Field: "annotation.release", Operation: v1.FilterOp_OP_EXISTS, Negate: true}, ""},
+		{" ! annotation.release ", &v1.FilterTerm{Field: "annotation.release", Operation: v1.FilterOp_OP_EXISTS, Negate: true}, ""},
+		{"result.image", &v1.FilterTerm{Field: "result.image", Operation: v1.FilterOp_OP_EXISTS, Negate: false}, ""},
+		{"annotation.", nil, filterexpr.ErrMissingOp.Error()},
+		{"!owner", nil, filterexpr.ErrMissingOp.Error()},
+		{"result.==foo", nil, "unknown field result. - valid fields are: name, trigger, owner, phase, repo, repo.owner, repo.repo, repo.host, repo.ref, repo.revision, success, created, started, completed, duration, annotation.<key>, result.<type>"},
+		{"repo.host==github.com", &v1.FilterTerm{Field: "repo.host", Value: "github.com", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
+		{"repo.rev|=c0ffee7", &v1.FilterTerm{Field: "repo.rev", Value: "c0ffee7", Operation: v1.FilterOp_OP_STARTS_WITH, Negate: false}, ""},
+		{"trigger==push", &v1.FilterTerm{Field: "trigger", Value: "push", Operation: v1.FilterOp_OP_EQUALS, Negate: false}, ""},
 	}
 
 	for _, test := range tests {
@@ -52,11 +134,102 @@ func TestValidBasics(t *testing.T) {
 	}
 }
 
+func TestParseExpressions(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Input  []string
+		Result []*v1.FilterExpression
+		Error  string
+	}{
+		{
+			Name:   "single term",
+			Input:  []string{"phase==running"},
+			Result: []*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "phase", Value: "running", Operation: v1.FilterOp_OP_EQUALS}}}},
+		},
+		{
+			Name:  "or group",
+			Input: []string{"phase==running,phase==starting"},
+			Result: []*v1.FilterExpression{{Terms: []*v1.FilterTerm{
+				{Field: "phase", Value: "running", Operation: v1.FilterOp_OP_EQUALS},
+				{Field: "phase", Value: "starting", Operation: v1.FilterOp_OP_EQUALS},
+			}}},
+		},
+		{
+			Name:  "mixed operators in or group",
+			Input: []string{"name|=build-,owner!=foo,repo.repo~=werft"},
+			Result: []*v1.FilterExpression{{Terms: []*v1.FilterTerm{
+				{Field: "name", Value: "build-", Operation: v1.FilterOp_OP_STARTS_WITH},
+				{Field: "owner", Value: "foo", Operation: v1.FilterOp_OP_EQUALS, Negate: true},
+				{Field: "repo.repo", Value: "werft", Operation: v1.FilterOp_OP_CONTAINS},
+			}}},
+		},
+		{
+			Name:  "and of or groups",
+			Input: []string{"phase==running,phase==starting", "owner==foo"},
+			Result: []*v1.FilterExpression{
+				{Terms: []*v1.FilterTerm{
+					{Field: "phase", Value: "running", Operation: v1.FilterOp_OP_EQUALS},
+					{Field: "phase", Value: "starting", Operation: v1.FilterOp_OP_EQUALS},
+				}},
+				{Terms: []*v1.FilterTerm{{Field: "owner", Value: "foo", Operation: v1.FilterOp_OP_EQUALS}}},
+			},
+		},
+		{
+			Name:  "quoted comma",
+			Input: []string{`name=="a,b",owner=='c,d'`},
+			Result: []*v1.FilterExpression{{Terms: []*v1.FilterTerm{
+				{Field: "name", Value: "a,b", Operation: v1.FilterOp_OP_EQUALS},
+				{Field: "owner", Value: "c,d", Operation: v1.FilterOp_OP_EQUALS},
+			}}},
+		},
+		{
+			Name:  "missing op in or group",
+			Input: []string{"phase==running,foo"},
+			Error: filterexpr.ErrMissingOp.Error(),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			res, err := filterexpr.ParseExpressions(test.Input)
+			if err != nil {
+				if err.Error() != test.Error {
+					t.Errorf("unexpected error: %v != %v", err, test.Error)
+				}
+				return
+			}
+			if test.Error != "" {
+				t.Errorf("expected error %s but got none", test.Error)
+				return
+			}
+
+			if !reflect.DeepEqual(res, test.Result) {
+				t.Errorf("expected %s but got %s", repr.String(test.Result), repr.String(res))
+			}
+		})
+	}
+}
+
 func TestMatchesFilter(t *testing.T) {
+	defer filterexpr.FreezeTime(frozenNow)()
+
 	md := &v1.JobMetadata{
 		Owner:      "foo",
 		Repository: &v1.Repository{},
 	}
+	ago := func(d time.Duration) *timestamp.Timestamp {
+		return &timestamp.Timestamp{Seconds: frozenNow.Add(-d).Unix()}
+	}
+	var (
+		completedJob = &v1.JobStatus{Metadata: &v1.JobMetadata{Created: ago(time.Hour), Finished: ago(55 * time.Minute)}}
+		runningJob   = &v1.JobStatus{Metadata: &v1.JobMetadata{Created: ago(time.Hour)}}
+		// jobs which waited in the queue count their duration from when they started
+		queuedRunningJob = &v1.JobStatus{Metadata: &v1.JobMetadata{Created: ago(time.Hour), Started: ago(30 * time.Minute)}}
+		queuedDoneJob    = &v1.JobStatus{Metadata: &v1.JobMetadata{Created: ago(time.Hour), Started: ago(30 * time.Minute), Finished: ago(25 * time.Minute)}}
+		doneJob          = &v1.JobStatus{Metadata: &v1.JobMetadata{Created: ago(time.Hour), Started: ago(50 * time.Minute), Finished: ago(10 * time.Minute)}}
+		startedJob       = &v1.JobStatus{Metadata: &v1.JobMetadata{Created: ago(time.Hour), Started: ago(50 * time.Minute)}}
+		queuedJob        = &v1.JobStatus{Metadata: &v1.JobMetadata{Created: ago(time.Hour)}}
+	)
 	tests := []struct {
 		Job     *v1.JobStatus
 		Expr    []*v1.FilterExpression
@@ -72,6 +245,312 @@ func TestMatchesFilter(t *testing.T) {
 			[]*v1.FilterExpression{&v1.FilterExpression{Terms: []*v1.FilterTerm{&v1.FilterTerm{Field: "name", Value: "foobar", Operation: v1.FilterOp_OP_STARTS_WITH}}}},
 			true,
 		},
+		{
+			&v1.JobStatus{Metadata: md, Name: "build-foo-pr42.1"},
+			[]*v1.FilterExpression{&v1.FilterExpression{Terms: []*v1.FilterTerm{&v1.FilterTerm{Field: "name", Value: "^build-.*-pr[0-9]+", Operation: v1.FilterOp_OP_MATCHES}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: md, Name: "build-foo-main.1"},
+			[]*v1.FilterExpression{&v1.FilterExpression{Terms: []*v1.FilterTerm{&v1.FilterTerm{Field: "name", Value: "^build-.*-pr[0-9]+", Operation: v1.FilterOp_OP_MATCHES}}}},
+			false,
+		},
+		{
+			&v1.JobStatus{Metadata: md, Phase: v1.JobPhase_PHASE_DONE},
+			[]*v1.FilterExpression{&v1.FilterExpression{Terms: []*v1.FilterTerm{&v1.FilterTerm{Field: "phase", Value: "done", Operation: v1.FilterOp_OP_EQUALS, Negate: true}}}},
+			false,
+		},
+		{
+			&v1.JobStatus{Metadata: md, Phase: v1.JobPhase_PHASE_RUNNING},
+			[]*v1.FilterExpression{&v1.FilterExpression{Terms: []*v1.FilterTerm{&v1.FilterTerm{Field: "phase", Value: "done", Operation: v1.FilterOp_OP_EQUALS, Negate: true}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Repository: &v1.Repository{}, Trigger: v1.JobTrigger_TRIGGER_MANUAL}},
+			[]*v1.FilterExpression{&v1.FilterExpression{Terms: []*v1.FilterTerm{&v1.FilterTerm{Field: "trigger", Value: "manual", Operation: v1.FilterOp_OP_EQUALS, Negate: true}}}},
+			false,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Repository: &v1.Repository{}, Trigger: v1.JobTrigger_TRIGGER_PUSH}},
+			[]*v1.FilterExpression{&v1.FilterExpression{Terms: []*v1.FilterTerm{&v1.FilterTerm{Field: "trigger", Value: "manual", Operation: v1.FilterOp_OP_EQUALS, Negate: true}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Repository: &v1.Repository{}, Trigger: v1.JobTrigger_TRIGGER_SCHEDULE}},
+			[]*v1.FilterExpression{&v1.FilterExpression{Terms: []*v1.FilterTerm{&v1.FilterTerm{Field: "trigger", Value: "schedule", Operation: v1.FilterOp_OP_EQUALS}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Repository: &v1.Repository{}, Created: &timestamp.Timestamp{Seconds: 1577836800}}},
+			[]*v1.FilterExpression{&v1.FilterExpression{Terms: []*v1.FilterTerm{&v1.FilterTerm{Field: "created", Value: "2019-01-01T00:00:00Z", Operation: v1.FilterOp_OP_GREATER}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Repository: &v1.Repository{}, Created: &timestamp.Timestamp{Seconds: 1577836800}}},
+			[]*v1.FilterExpression{&v1.FilterExpression{Terms: []*v1.FilterTerm{&v1.FilterTerm{Field: "created", Value: "2019-01-01T00:00:00Z", Operation: v1.FilterOp_OP_LESS}}}},
+			false,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Repository: &v1.Repository{}, Created: &timestamp.Timestamp{Seconds: 1577836800}}},
+			[]*v1.FilterExpression{&v1.FilterExpression{Terms: []*v1.FilterTerm{&v1.FilterTerm{Field: "created", Value: "2020-01-01T00:00:00Z", Operation: v1.FilterOp_OP_GREATER}}}},
+			false,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Repository: &v1.Repository{}, Created: &timestamp.Timestamp{Seconds: 1577836800}}},
+			[]*v1.FilterExpression{&v1.FilterExpression{Terms: []*v1.FilterTerm{&v1.FilterTerm{Field: "created", Value: "2020", Operation: v1.FilterOp_OP_CONTAINS}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Repository: &v1.Repository{}, Created: &timestamp.Timestamp{Seconds: 1577836800}}},
+			[]*v1.FilterExpression{&v1.FilterExpression{Terms: []*v1.FilterTerm{&v1.FilterTerm{Field: "created", Value: "2019", Operation: v1.FilterOp_OP_CONTAINS}}}},
+			false,
+		},
+		{
+			&v1.JobStatus{Metadata: md, Name: "9"},
+			[]*v1.FilterExpression{&v1.FilterExpression{Terms: []*v1.FilterTerm{&v1.FilterTerm{Field: "name", Value: "10", Operation: v1.FilterOp_OP_LESS}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Annotations: []*v1.Annotation{{Key: "team", Value: "payments"}}}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "annotation.team", Value: "payments", Operation: v1.FilterOp_OP_EQUALS}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Annotations: []*v1.Annotation{{Key: "team", Value: "payments"}}}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "annotation.team", Value: "pay", Operation: v1.FilterOp_OP_STARTS_WITH}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Annotations: []*v1.Annotation{{Key: "werft.dev/team", Value: "payments"}}}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "annotation.werft.dev/team", Value: "payments", Operation: v1.FilterOp_OP_EQUALS}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Annotations: []*v1.Annotation{{Key: "team", Value: "payments"}}}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "annotation.owner", Value: "payments", Operation: v1.FilterOp_OP_EQUALS}}}},
+			false,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "annotation.team", Value: "", Operation: v1.FilterOp_OP_EQUALS}}}},
+			false,
+		},
+		{
+			&v1.JobStatus{Name: "no-metadata"},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "annotation.team", Value: "payments", Operation: v1.FilterOp_OP_EQUALS}}}},
+			false,
+		},
+		{
+			&v1.JobStatus{Metadata: md, Phase: v1.JobPhase_PHASE_RUNNING},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "phase", Value: "PHASE_RUNNING", Operation: v1.FilterOp_OP_EQUALS}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: md, Phase: v1.JobPhase_PHASE_RUNNING},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "phase", Value: "3", Operation: v1.FilterOp_OP_EQUALS}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Repository: &v1.Repository{Repo: "werft"}}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "repo.repo", Value: "WERFT", Operation: v1.FilterOp_OP_CONTAINS, IgnoreCase: true}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Repository: &v1.Repository{Repo: "werft"}}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "repo.repo", Value: "WERFT", Operation: v1.FilterOp_OP_CONTAINS}}}},
+			false,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Repository: &v1.Repository{Ref: "refs/tags/v1.0.0", Revision: "c0ffee7a0b1c2d3e"}}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "repo.revision", Value: "c0ffee7", Operation: v1.FilterOp_OP_STARTS_WITH}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Repository: &v1.Repository{Ref: "refs/tags/v1.0.0", Revision: "c0ffee7a0b1c2d3e"}}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "repo.revision", Value: "deadbeef", Operation: v1.FilterOp_OP_STARTS_WITH}}}},
+			false,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Repository: &v1.Repository{Ref: "refs/tags/v1.0.0", Revision: "c0ffee7a0b1c2d3e"}}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "repo.rev", Value: "c0ffee7", Operation: v1.FilterOp_OP_STARTS_WITH}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Repository: &v1.Repository{Ref: "refs/tags/v1.0.0", Revision: "c0ffee7a0b1c2d3e"}}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "repo.rev", Value: "deadbeef", Operation: v1.FilterOp_OP_STARTS_WITH}}}},
+			false,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Owner: "CSWeichel"}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "owner", Value: "csw", Operation: v1.FilterOp_OP_STARTS_WITH, IgnoreCase: true}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Owner: "CSWeichel"}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "owner", Value: "^csw.*L$", Operation: v1.FilterOp_OP_MATCHES, IgnoreCase: true}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Annotations: []*v1.Annotation{{Key: "team", Value: "Payments"}}}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "annotation.team", Value: "payments", Operation: v1.FilterOp_OP_EQUALS, IgnoreCase: true}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: md, Results: []*v1.JobResult{{Type: "url", Payload: "https://preview.example.com"}, {Type: "url", Payload: "https://docs.example.com"}}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "result.url", Value: "https://docs", Operation: v1.FilterOp_OP_STARTS_WITH}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: md, Results: []*v1.JobResult{{Type: "url", Payload: "https://preview.example.com"}}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "result.image", Operation: v1.FilterOp_OP_EXISTS}}}},
+			false,
+		},
+		{
+			&v1.JobStatus{Metadata: md, Results: []*v1.JobResult{{Type: "image", Payload: "werft:main"}}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "result.image", Operation: v1.FilterOp_OP_EXISTS}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: md},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "result.image", Value: "werft:main", Operation: v1.FilterOp_OP_EQUALS, Negate: true}}}},
+			false,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Annotations: []*v1.Annotation{{Key: "release", Value: ""}}}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "annotation.release", Operation: v1.FilterOp_OP_EXISTS}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Annotations: []*v1.Annotation{{Key: "team", Value: "payments"}}}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "annotation.release", Operation: v1.FilterOp_OP_EXISTS}}}},
+			false,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Annotations: []*v1.Annotation{{Key: "release", Value: "v1.0"}}}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "annotation.release", Operation: v1.FilterOp_OP_EXISTS, Negate: true}}}},
+			false,
+		},
+		{
+			&v1.JobStatus{Metadata: &v1.JobMetadata{Annotations: []*v1.Annotation{{Key: "team", Value: "payments"}}}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "annotation.release", Operation: v1.FilterOp_OP_EXISTS, Negate: true}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: md},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "result.image", Operation: v1.FilterOp_OP_EXISTS, Negate: true}}}},
+			true,
+		},
+		{
+			&v1.JobStatus{Metadata: md, Results: []*v1.JobResult{{Type: "image", Payload: "werft:main"}}},
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "result.image", Operation: v1.FilterOp_OP_EXISTS, Negate: true}}}},
+			false,
+		},
+
+		{
+			completedJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "duration", Value: "1m", Operation: v1.FilterOp_OP_GREATER}}}},
+			true,
+		},
+		{
+			completedJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "duration", Value: "10m", Operation: v1.FilterOp_OP_GREATER}}}},
+			false,
+		},
+		{
+			completedJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "duration", Value: "10m", Operation: v1.FilterOp_OP_LESS}}}},
+			true,
+		},
+		{
+			runningJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "duration", Value: "10m", Operation: v1.FilterOp_OP_GREATER}}}},
+			true,
+		},
+		{
+			runningJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "duration", Value: "2h", Operation: v1.FilterOp_OP_GREATER}}}},
+			false,
+		},
+		{
+			runningJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "duration", Value: "2h", Operation: v1.FilterOp_OP_LESS}}}},
+			true,
+		},
+		{
+			queuedRunningJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "duration", Value: "20m", Operation: v1.FilterOp_OP_GREATER}}}},
+			true,
+		},
+		{
+			queuedRunningJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "duration", Value: "45m", Operation: v1.FilterOp_OP_GREATER}}}},
+			false,
+		},
+		{
+			queuedDoneJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "duration", Value: "10m", Operation: v1.FilterOp_OP_LESS}}}},
+			true,
+		},
+		{
+			queuedDoneJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "duration", Value: "30m", Operation: v1.FilterOp_OP_GREATER}}}},
+			false,
+		},
+		{
+			doneJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "started", Value: "2020-03-15T11:00:00Z", Operation: v1.FilterOp_OP_GREATER}}}},
+			true,
+		},
+		{
+			doneJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "started", Value: "2020-03-15T11:00:00Z", Operation: v1.FilterOp_OP_LESS}}}},
+			false,
+		},
+		{
+			doneJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "completed", Value: "2020-03-15T11:30:00Z", Operation: v1.FilterOp_OP_GREATER}}}},
+			true,
+		},
+		{
+			doneJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "completed", Value: "2020-03-15T11:30:00Z", Operation: v1.FilterOp_OP_LESS}}}},
+			false,
+		},
+		{
+			doneJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "completed", Value: "2020-03-15T11:50:00Z", Operation: v1.FilterOp_OP_EQUALS}}}},
+			true,
+		},
+		{
+			startedJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "started", Value: "2020-03-15T11:00:00Z", Operation: v1.FilterOp_OP_GREATER}}}},
+			true,
+		},
+		{
+			startedJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "completed", Value: "2020-03-15T11:30:00Z", Operation: v1.FilterOp_OP_GREATER}}}},
+			false,
+		},
+		{
+			startedJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "completed", Value: "2020-03-15T11:30:00Z", Operation: v1.FilterOp_OP_LESS}}}},
+			false,
+		},
+		{
+			startedJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "completed", Value: ".+", Operation: v1.FilterOp_OP_MATCHES}}}},
+			false,
+		},
+		{
+			queuedJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "started", Value: "2020-03-15T10:00:00Z", Operation: v1.FilterOp_OP_GREATER}}}},
+			false,
+		},
+		{
+			queuedJob,
+			[]*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "started", Value: "2020-03-15T10:00:00Z", Operation: v1.FilterOp_OP_LESS}}}},
+			false,
+		},
 	}
 
 	for idx, test := range tests {
@@ -80,3 +559,218 @@ func TestMatchesFilter(t *testing.T) {
 		}
 	}
 }
+
+func TestParseOrder(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Input  []string
+		Result []*v1.OrderExpression
+		Error  string
+	}{
+		{
+			Name:   "single field",
+			Input:  []string{"name:desc"},
+			Result: []*v1.OrderExpression{{Field: "name", Ascending: false}},
+		},
+		{
+			Name:  "multiple fields",
+			Input: []string{"created:desc", "name:asc"},
+			Result: []*v1.OrderExpression{
+				{Field: "created", Ascending: false},
+				{Field: "name", Ascending: true},
+			},
+		},
+		{
+			Name:  "invalid direction",
+			Input: []string{"name:ascending"},
+			Error: "invalid order direction in name:ascending: must be asc or desc",
+		},
+		{
+			Name:  "too many segments",
+			Input: []string{"name:asc:desc"},
+			Error: "invalid order expression: name:asc:desc",
+		},
+		{
+			Name:  "shorthand",
+			Input: []string{"-created", "+name", "owner"},
+			Result: []*v1.OrderExpression{
+				{Field: "created", Ascending: false},
+				{Field: "name", Ascending: true},
+				{Field: "owner", Ascending: true},
+			},
+		},
+		{
+			Name:   "alias",
+			Input:  []string{"repo.rev:asc"},
+			Result: []*v1.OrderExpression{{Field: "repo.rev", Ascending: true}},
+		},
+		{
+			Name:  "shorthand unknown field",
+			Input: []string{"-"},
+			Error: "cannot order by  - valid fields are: name, trigger, owner, phase, repo, repo.owner, repo.repo, repo.host, repo.ref, repo.revision, success, created, started, completed, duration",
+		},
+		{
+			Name:  "unknown field",
+			Input: []string{"nme:asc"},
+			Error: "cannot order by nme - valid fields are: name, trigger, owner, phase, repo, repo.owner, repo.repo, repo.host, repo.ref, repo.revision, success, created, started, completed, duration",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			res, err := filterexpr.ParseOrder(test.Input)
+			if err != nil {
+				if err.Error() != test.Error {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if test.Error != "" {
+				t.Errorf("expected error %q but got none", test.Error)
+				return
+			}
+			if !reflect.DeepEqual(res, test.Result) {
+				t.Errorf("expected %s but got %s", repr.String(test.Result), repr.String(res))
+			}
+		})
+	}
+}
+
+func TestParseOrderShorthand(t *testing.T) {
+	tests := []struct {
+		Long      []string
+		Shorthand []string
+	}{
+		{[]string{"name:asc"}, []string{"+name"}},
+		{[]string{"name:asc"}, []string{"name"}},
+		{[]string{"name:desc"}, []string{"-name"}},
+		{[]string{"created:desc", "name:asc"}, []string{"-created", "+name"}},
+		{[]string{"created:desc", "name:asc"}, []string{"created:desc", "+name"}},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.Shorthand, " "), func(t *testing.T) {
+			long, err := filterexpr.ParseOrder(test.Long)
+			if err != nil {
+				t.Fatal(err)
+			}
+			short, err := filterexpr.ParseOrder(test.Shorthand)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(long, short) {
+				t.Errorf("expected %s but got %s", repr.String(long), repr.String(short))
+			}
+		})
+	}
+}
+
+func TestSortJobs(t *testing.T) {
+	ts := func(sec int64) *timestamp.Timestamp { return &timestamp.Timestamp{Seconds: sec} }
+	job := func(name string, created, started, finished int64) v1.JobStatus {
+		return v1.JobStatus{Name: name, Metadata: &v1.JobMetadata{Created: ts(created), Started: ts(started), Finished: ts(finished)}}
+	}
+	jobs := []v1.JobStatus{job("b", 10, 12, 50), job("a", 10, 20, 40), job("c", 20, 21, 22), job("d", 5, 30, 35)}
+
+	tests := []struct {
+		Order    []string
+		Expected []string
+	}{
+		{[]string{"created:desc", "name:asc"}, []string{"c", "a", "b", "d"}},
+		{[]string{"started:asc"}, []string{"b", "a", "c", "d"}},
+		{[]string{"completed:desc"}, []string{"b", "a", "d", "c"}},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.Order, " "), func(t *testing.T) {
+			order, err := filterexpr.ParseOrder(test.Order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			in := make([]v1.JobStatus, len(jobs))
+			copy(in, jobs)
+			filterexpr.SortJobs(in, order)
+
+			var names []string
+			for _, j := range in {
+				names = append(names, j.Name)
+			}
+			if !reflect.DeepEqual(names, test.Expected) {
+				t.Errorf("expected %v but got %v", test.Expected, names)
+			}
+		})
+	}
+}
+
+func TestLatestPerGroup(t *testing.T) {
+	job := func(name, repo, ref string, created int64, annotations ...*v1.Annotation) v1.JobStatus {
+		return v1.JobStatus{Name: name, Metadata: &v1.JobMetadata{
+			Created:     &timestamp.Timestamp{Seconds: created},
+			Repository:  &v1.Repository{Repo: repo, Ref: ref},
+			Annotations: annotations,
+		}}
+	}
+	team := func(v string) *v1.Annotation { return &v1.Annotation{Key: "team", Value: v} }
+	jobs := []v1.JobStatus{
+		job("werft-main.1", "werft", "main", 10, team("a")),
+		job("werft-main.3", "werft", "main", 30),
+		job("werft-main.2", "werft", "main", 20, team("a")),
+		job("werft-dev.1", "werft", "dev", 15, team("b")),
+		job("werft-dev.2", "werft", "dev", 15, team("b")),
+		job("other-main.1", "other", "main", 5, team("a")),
+	}
+
+	tests := []struct {
+		Desc     string
+		Group    []string
+		Expected []string
+	}{
+		{"no group", nil, []string{"other-main.1", "werft-dev.1", "werft-dev.2", "werft-main.1", "werft-main.2", "werft-main.3"}},
+		{"repo and ref", []string{"repo.repo", "repo.ref"}, []string{"werft-main.3", "werft-dev.2", "other-main.1"}},
+		{"ref", []string{"repo.ref"}, []string{"werft-main.3", "werft-dev.2"}},
+		{"annotation", []string{"annotation.team"}, []string{"werft-main.3", "werft-main.2", "werft-dev.2"}},
+	}
+	for _, test := range tests {
+		t.Run(test.Desc, func(t *testing.T) {
+			in := make([]v1.JobStatus, len(jobs))
+			copy(in, jobs)
+
+			res := filterexpr.LatestPerGroup(in, test.Group)
+			filterexpr.SortJobs(res, []*v1.OrderExpression{{Field: "created", Ascending: false}})
+
+			var names []string
+			for _, j := range res {
+				names = append(names, j.Name)
+			}
+			if test.Group == nil {
+				sort.Strings(names)
+			}
+			if !reflect.DeepEqual(names, test.Expected) {
+				t.Errorf("expected %v but got %v", test.Expected, names)
+			}
+		})
+	}
+}
+
+func TestValidateGroup(t *testing.T) {
+	tests := []struct {
+		Group []string
+		Valid bool
+	}{
+		{nil, true},
+		{[]string{"repo.repo", "repo.ref"}, true},
+		{[]string{"annotation.team"}, true},
+		{[]string{"annotation."}, false},
+		{[]string{"created"}, false},
+		{[]string{"duration"}, false},
+		{[]string{"result.url"}, false},
+		{[]string{"foo"}, false},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.Group, ","), func(t *testing.T) {
+			err := filterexpr.ValidateGroup(test.Group)
+			if (err == nil) != test.Valid {
+				t.Errorf("expected valid=%v but got %v", test.Valid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package health
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultWatchInterval is the time between two readiness checks of a client watching the health of a service
+const DefaultWatchInterval = 10 * time.Second
+
+// GRPCServer implements the standard gRPC health checking protocol using the readiness checks of a checker.
+// Services are SERVING if the server is ready and NOT_SERVING otherwise.
+type GRPCServer struct {
+	Checker *Checker
+	// WatchInterval is the time between two readiness checks while a client watches. Defaults to DefaultWatchInterval.
+	WatchInterval time.Duration
+
+	services map[string]struct{}
+}
+
+// NewGRPCServer creates a gRPC health server reporting the health of the server as a whole and of the services
+func NewGRPCServer(checker *Checker, services ...string) *GRPCServer {
+	idx := map[string]struct{}{"": {}}
+	for _, s := range services {
+		idx[s] = struct{}{}
+	}
+	return &GRPCServer{
+		Checker:       checker,
+		WatchInterval: DefaultWatchInterval,
+		services:      idx,
+	}
+}
+
+// Check reports the health of a service
+func (s *GRPCServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if _, ok := s.services[req.Service]; !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown service %s", req.Service)
+	}
+	return &healthpb.HealthCheckResponse{Status: s.status(ctx)}, nil
+}
+
+// Watch reports the health of a service whenever it changes. Unknown services are reported as SERVICE_UNKNOWN.
+func (s *GRPCServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	ctx := stream.Context()
+	if _, ok := s.services[req.Service]; !ok {
+		err := stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVICE_UNKNOWN})
+		if err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return status.FromContextError(ctx.Err()).Err()
+	}
+
+	interval := s.WatchInterval
+	if interval == 0 {
+		interval = DefaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_UNKNOWN
+	for {
+		current := s.status(ctx)
+		if current != last {
+			err := stream.Send(&healthpb.HealthCheckResponse{Status: current})
+			if err != nil {
+				return err
+			}
+			last = current
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *GRPCServer) status(ctx context.Context) healthpb.HealthCheckResponse_ServingStatus {
+	_, err := s.Checker.Ready(ctx)
+	if err != nil {
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	return healthpb.HealthCheckResponse_SERVING
+}
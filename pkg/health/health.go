@@ -0,0 +1,143 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// DefaultCheckTimeout is the time a readiness check may take before it's considered failed
+const DefaultCheckTimeout = 5 * time.Second
+
+// Check returns an error if the dependency it checks is not usable
+type Check func(ctx context.Context) error
+
+// Checker serves the liveness and readiness of the werft server
+type Checker struct {
+	// Timeout limits the time each readiness check may take. Defaults to DefaultCheckTimeout.
+	Timeout time.Duration
+
+	mu           sync.RWMutex
+	checks       map[string]Check
+	shuttingDown bool
+}
+
+// NewChecker creates a new checker without any readiness checks
+func NewChecker() *Checker {
+	return &Checker{
+		Timeout: DefaultCheckTimeout,
+		checks:  make(map[string]Check),
+	}
+}
+
+// AddCheck adds a readiness check. The server is ready only if all checks pass.
+func (c *Checker) AddCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks[name] = check
+}
+
+// Shutdown marks the server as not ready so that load balancers drain its connections.
+// There's no going back - once shutting down the server never becomes ready again.
+func (c *Checker) Shutdown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.shuttingDown = true
+}
+
+// Ready runs all readiness checks and returns the result of each one. The server is ready if
+// err is nil.
+func (c *Checker) Ready(ctx context.Context) (results map[string]error, err error) {
+	c.mu.RLock()
+	shuttingDown := c.shuttingDown
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.RUnlock()
+
+	if shuttingDown {
+		return nil, xerrors.Errorf("shutting down")
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	results = make(map[string]error, len(checks))
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			err := check(ctx)
+			mu.Lock()
+			results[name] = err
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	var failed []string
+	for name, err := range results {
+		if err != nil {
+			failed = append(failed, name)
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return results, xerrors.Errorf("failed checks: %s", strings.Join(failed, ", "))
+	}
+	return results, nil
+}
+
+// ServeHealthz reports whether the process is alive. As long as we can serve this request, it is.
+func (c *Checker) ServeHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// ServeReadyz reports whether the server is ready to serve requests, listing the result of each check.
+// If it's not ready, it responds with 503 Service Unavailable.
+func (c *Checker) ServeReadyz(w http.ResponseWriter, r *http.Request) {
+	results, err := c.Ready(r.Context())
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if cerr := results[name]; cerr != nil {
+			fmt.Fprintf(w, "%s: %v\n", name, cerr)
+		} else {
+			fmt.Fprintf(w, "%s: ok\n", name)
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(w, "not ready: %v\n", err)
+		return
+	}
+	fmt.Fprintln(w, "ready")
+}
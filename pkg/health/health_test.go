@@ -0,0 +1,205 @@
+package health_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/csweichel/werft/pkg/health"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestReadyz(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return fmt.Errorf("connection refused") }
+	hanging := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	tests := []struct {
+		Name     string
+		Checks   map[string]health.Check
+		Shutdown bool
+		Status   int
+		Body     string
+	}{
+		{Name: "no checks", Status: http.StatusOK, Body: "ready\n"},
+		{Name: "all checks pass", Checks: map[string]health.Check{"store": ok, "kubernetes": ok}, Status: http.StatusOK, Body: "kubernetes: ok\nstore: ok\nready\n"},
+		{Name: "check fails", Checks: map[string]health.Check{"store": failing, "kubernetes": ok}, Status: http.StatusServiceUnavailable, Body: "kubernetes: ok\nstore: connection refused\nnot ready: failed checks: store\n"},
+		{Name: "check times out", Checks: map[string]health.Check{"kubernetes": hanging}, Status: http.StatusServiceUnavailable, Body: "kubernetes: context deadline exceeded\nnot ready: failed checks: kubernetes\n"},
+		{Name: "shutting down", Checks: map[string]health.Check{"store": ok}, Shutdown: true, Status: http.StatusServiceUnavailable, Body: "not ready: shutting down\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			c := health.NewChecker()
+			c.Timeout = 10 * time.Millisecond
+			for name, check := range test.Checks {
+				c.AddCheck(name, check)
+			}
+			if test.Shutdown {
+				c.Shutdown()
+			}
+
+			rec := httptest.NewRecorder()
+			c.ServeReadyz(rec, httptest.NewRequest("GET", "/readyz", nil))
+			if rec.Code != test.Status {
+				t.Errorf("unexpected status: want %d, got %d", test.Status, rec.Code)
+			}
+			if body := rec.Body.String(); body != test.Body {
+				t.Errorf("unexpected body: want %q, got %q", test.Body, body)
+			}
+		})
+	}
+}
+
+func TestReadyzTransitions(t *testing.T) {
+	var storeErr error
+	c := health.NewChecker()
+	c.AddCheck("store", func(ctx context.Context) error { return storeErr })
+
+	ready := func() bool {
+		rec := httptest.NewRecorder()
+		c.ServeReadyz(rec, httptest.NewRequest("GET", "/readyz", nil))
+		return rec.Code == http.StatusOK
+	}
+
+	if !ready() {
+		t.Fatal("expected to be ready initially")
+	}
+	storeErr = fmt.Errorf("connection refused")
+	if ready() {
+		t.Fatal("expected to be not ready while the store is unusable")
+	}
+	storeErr = nil
+	if !ready() {
+		t.Fatal("expected to be ready once the store recovered")
+	}
+	c.Shutdown()
+	if ready() {
+		t.Fatal("expected to be not ready while shutting down")
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	c := health.NewChecker()
+	c.AddCheck("store", func(ctx context.Context) error { return fmt.Errorf("connection refused") })
+	c.Shutdown()
+
+	// the process is alive even if it's not ready
+	rec := httptest.NewRecorder()
+	c.ServeHealthz(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("unexpected status: want %d, got %d", http.StatusOK, rec.Code)
+	}
+	if body := rec.Body.String(); !strings.HasPrefix(body, "ok") {
+		t.Errorf("unexpected body: %q", body)
+	}
+}
+
+func TestGRPCCheck(t *testing.T) {
+	var storeErr error
+	c := health.NewChecker()
+	c.AddCheck("store", func(ctx context.Context) error { return storeErr })
+	srv := health.NewGRPCServer(c, "v1.WerftService")
+
+	check := func(service string) (healthpb.HealthCheckResponse_ServingStatus, codes.Code) {
+		resp, err := srv.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return healthpb.HealthCheckResponse_UNKNOWN, status.Code(err)
+		}
+		return resp.Status, codes.OK
+	}
+
+	steps := []struct {
+		Name    string
+		Do      func()
+		Service string
+		Status  healthpb.HealthCheckResponse_ServingStatus
+		Code    codes.Code
+	}{
+		{Name: "server", Status: healthpb.HealthCheckResponse_SERVING},
+		{Name: "service", Service: "v1.WerftService", Status: healthpb.HealthCheckResponse_SERVING},
+		{Name: "unknown service", Service: "v1.Foo", Code: codes.NotFound},
+		{Name: "check fails", Do: func() { storeErr = fmt.Errorf("connection refused") }, Service: "v1.WerftService", Status: healthpb.HealthCheckResponse_NOT_SERVING},
+		{Name: "check recovers", Do: func() { storeErr = nil }, Status: healthpb.HealthCheckResponse_SERVING},
+		{Name: "shutting down", Do: c.Shutdown, Status: healthpb.HealthCheckResponse_NOT_SERVING},
+	}
+	for _, step := range steps {
+		if step.Do != nil {
+			step.Do()
+		}
+		s, code := check(step.Service)
+		if s != step.Status || code != step.Code {
+			t.Errorf("%s: want %v (%v), got %v (%v)", step.Name, step.Status, step.Code, s, code)
+		}
+	}
+}
+
+type watchServer struct {
+	grpc.ServerStream
+	ctx     context.Context
+	updates chan healthpb.HealthCheckResponse_ServingStatus
+}
+
+func (s *watchServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s *watchServer) Send(resp *healthpb.HealthCheckResponse) error {
+	s.updates <- resp.Status
+	return nil
+}
+
+func TestGRPCWatch(t *testing.T) {
+	c := health.NewChecker()
+	srv := health.NewGRPCServer(c)
+	srv.WatchInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := &watchServer{ctx: ctx, updates: make(chan healthpb.HealthCheckResponse_ServingStatus, 10)}
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Watch(&healthpb.HealthCheckRequest{}, stream)
+	}()
+
+	next := func() healthpb.HealthCheckResponse_ServingStatus {
+		select {
+		case s := <-stream.updates:
+			return s
+		case <-time.After(time.Second):
+			t.Fatal("no update")
+			return healthpb.HealthCheckResponse_UNKNOWN
+		}
+	}
+	if s := next(); s != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected SERVING, got %v", s)
+	}
+	c.Shutdown()
+	if s := next(); s != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected NOT_SERVING once shutting down, got %v", s)
+	}
+	select {
+	case s := <-stream.updates:
+		t.Errorf("expected updates only when the status changes, got %v", s)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.Canceled {
+			t.Errorf("expected the watch to be canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watch did not end with its context")
+	}
+}
@@ -0,0 +1,74 @@
+package logcutter
+
+import (
+	"bytes"
+	"strings"
+)
+
+// SectionStart is where a section starts within a log
+type SectionStart struct {
+	// Offset is the byte offset of the first line which belongs to the section or one of its nested sections
+	Offset int64 `json:"offset"`
+	// Phase is the phase unmarked content belongs to at that offset, see PhaseCutter
+	Phase string `json:"phase"`
+}
+
+// SectionIndex records where the sections of a log start, so that a single section can be read
+// without cutting the log from the start. Write the log to the index as it's written.
+// SectionIndex is not safe for concurrent use.
+type SectionIndex struct {
+	Sections map[string]SectionStart `json:"sections"`
+
+	offset  int64
+	phase   string
+	partial []byte
+}
+
+// NewSectionIndex creates an empty section index
+func NewSectionIndex() *SectionIndex {
+	return &SectionIndex{
+		Sections: make(map[string]SectionStart),
+		phase:    DefaultSlice,
+	}
+}
+
+// Write indexes the sections of log content. Lines are indexed once they are complete.
+func (idx *SectionIndex) Write(p []byte) (n int, err error) {
+	n = len(p)
+	for len(p) > 0 {
+		eol := bytes.IndexByte(p, '\n')
+		if eol < 0 {
+			idx.partial = append(idx.partial, p...)
+			break
+		}
+
+		line := p[:eol+1]
+		if len(idx.partial) > 0 {
+			line = append(idx.partial, line...)
+			idx.partial = nil
+		}
+		idx.index(line)
+		p = p[eol+1:]
+	}
+	return n, nil
+}
+
+func (idx *SectionIndex) index(line []byte) {
+	name, verb, _ := parseLine(strings.TrimRight(string(line), "\r\n"), idx.phase)
+	for n := name; n != ""; n = Parent(n) {
+		if _, exists := idx.Sections[n]; exists {
+			break
+		}
+		idx.Sections[n] = SectionStart{Offset: idx.offset, Phase: idx.phase}
+	}
+	if verb == "PHASE" {
+		idx.phase = name
+	}
+	idx.offset += int64(len(line))
+}
+
+// Lookup returns where a section starts
+func (idx *SectionIndex) Lookup(section string) (start SectionStart, ok bool) {
+	start, ok = idx.Sections[section]
+	return
+}
@@ -0,0 +1,96 @@
+package logcutter_test
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/logcutter"
+)
+
+const indexedLog = `preparing workspace
+[build|PHASE] Building
+compiling
+[build|compile] go build ./...
+[lint] golangci-lint run
+[build|DONE]
+[test|PHASE] Testing
+go test ./...
+[test|unit] ok pkg/foo
+[test|integration] ok pkg/bar
+[test|DONE]
+[deploy|PHASE] Deploying
+`
+
+func TestSectionIndex(t *testing.T) {
+	idx := logcutter.NewSectionIndex()
+	// lines written in pieces are indexed once they are complete
+	for _, chunk := range []string{indexedLog[:5], indexedLog[5:50], indexedLog[50:]} {
+		_, err := idx.Write([]byte(chunk))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	offset := func(line string) int64 {
+		return int64(strings.Index(indexedLog, line))
+	}
+	expected := map[string]logcutter.SectionStart{
+		logcutter.DefaultSlice: {Offset: 0, Phase: logcutter.DefaultSlice},
+		"build":                {Offset: offset("[build|PHASE]"), Phase: logcutter.DefaultSlice},
+		"build|compile":        {Offset: offset("[build|compile]"), Phase: "build"},
+		"lint":                 {Offset: offset("[lint]"), Phase: "build"},
+		"test":                 {Offset: offset("[test|PHASE]"), Phase: "build"},
+		"test|unit":            {Offset: offset("[test|unit]"), Phase: "test"},
+		"test|integration":     {Offset: offset("[test|integration]"), Phase: "test"},
+		"deploy":               {Offset: offset("[deploy|PHASE]"), Phase: "test"},
+	}
+	if !reflect.DeepEqual(expected, idx.Sections) {
+		t.Errorf("unexpected index: expected %v, got %v", expected, idx.Sections)
+	}
+	if _, ok := idx.Lookup("release"); ok {
+		t.Errorf("sections which haven't started must not be found")
+	}
+}
+
+func TestPhaseCutterFromSectionStart(t *testing.T) {
+	idx := logcutter.NewSectionIndex()
+	idx.Write([]byte(indexedLog))
+
+	for _, section := range []string{"build", "build|compile", "test", "test|unit"} {
+		t.Run(section, func(t *testing.T) {
+			// cutting the log from where the section starts must yield the same section events as cutting all of it
+			expected := sectionEvents(logcutter.DefaultCutter, indexedLog, section)
+
+			start, ok := idx.Lookup(section)
+			if !ok {
+				t.Fatalf("section %s not indexed", section)
+			}
+			act := sectionEvents(logcutter.PhaseCutter(start.Phase), indexedLog[start.Offset:], section)
+			if !reflect.DeepEqual(expected, act) {
+				t.Errorf("unexpected events: expected %v, got %v", expected, act)
+			}
+		})
+	}
+}
+
+func sectionEvents(cutter logcutter.Cutter, log, section string) []v1.LogSliceEvent {
+	var res []v1.LogSliceEvent
+	evts, _ := cutter.Slice(strings.NewReader(log))
+	for evt := range evts {
+		if !logcutter.InSection(evt.Name, section) {
+			continue
+		}
+		res = append(res, *evt)
+	}
+	// slices still open at the end of the log are abandoned in no particular order
+	abandoned := len(res)
+	for abandoned > 0 && res[abandoned-1].Type == v1.LogSliceType_SLICE_ABANDONED {
+		abandoned--
+	}
+	tail := res[abandoned:]
+	sort.Slice(tail, func(i, j int) bool { return tail[i].Name < tail[j].Name })
+	return res
+}
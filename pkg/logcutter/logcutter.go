@@ -19,8 +19,33 @@ type Cutter interface {
 const (
 	// DefaultSlice is the parent slice of all unmarked content
 	DefaultSlice = "default"
+
+	// SectionSeparator separates the names of nested slices, e.g. [build|compile] is the compile slice within the build slice
+	SectionSeparator = "|"
 )
 
+// Parent returns the name of the slice a nested slice belongs to, or an empty string if the slice isn't nested
+func Parent(name string) string {
+	idx := strings.LastIndex(name, SectionSeparator)
+	if idx < 0 {
+		return ""
+	}
+	return name[:idx]
+}
+
+// InSection returns true if the slice is the section itself or nested within it
+func InSection(name, section string) bool {
+	return name == section || strings.HasPrefix(name, section+SectionSeparator)
+}
+
+// verbs are the markers which can follow a slice name, e.g. [build|DONE]
+var verbs = map[string]struct{}{
+	"DONE":   {},
+	"FAIL":   {},
+	"RESULT": {},
+	"PHASE":  {},
+}
+
 // NoCutter does not slice the content up at all
 var NoCutter Cutter = noCutter{}
 
@@ -55,55 +80,69 @@ func (noCutter) Slice(in io.Reader) (events <-chan *v1.LogSliceEvent, errchan <-
 // DefaultCutter implements the default cutting behaviour
 var DefaultCutter Cutter = defaultCutter{}
 
-type defaultCutter struct{}
+// PhaseCutter cuts like the DefaultCutter, but attributes unmarked content to the phase until the log starts
+// another one. Use it to cut a log read from the middle, e.g. from the start of a section.
+func PhaseCutter(phase string) Cutter {
+	return defaultCutter{phase: phase}
+}
+
+type defaultCutter struct {
+	phase string
+}
 
-// Slice cuts a log stream into pieces based on a configurable delimiter
-func (defaultCutter) Slice(in io.Reader) (events <-chan *v1.LogSliceEvent, errchan <-chan error) {
+// parseLine determines the slice a line belongs to. Unmarked lines belong to the current phase.
+func parseLine(line, phase string) (name, verb, payload string) {
+	sl := strings.TrimSpace(line)
+	if !(strings.HasPrefix(sl, "[") && strings.Contains(sl, "]")) {
+		return phase, "", line
+	}
+
+	start := strings.IndexRune(sl, '[')
+	end := strings.IndexRune(sl, ']')
+	name = sl[start+1 : end]
+	payload = strings.TrimPrefix(sl[end+1:], " ")
+
+	if sep := strings.LastIndex(name, SectionSeparator); sep >= 0 {
+		if _, ok := verbs[name[sep+1:]]; ok {
+			verb = name[sep+1:]
+			name = name[:sep]
+		}
+	}
+	return name, verb, payload
+}
+
+// Slice cuts a log stream into pieces based on a configurable delimiter.
+// Slices can be nested, e.g. [build|compile], in which case the events carry the name of their parent slice.
+func (c defaultCutter) Slice(in io.Reader) (events <-chan *v1.LogSliceEvent, errchan <-chan error) {
 	evts := make(chan *v1.LogSliceEvent)
 	errc := make(chan error)
 	events, errchan = evts, errc
 
 	scanner := bufio.NewScanner(in)
-	phase := DefaultSlice
+	phase := c.phase
+	if phase == "" {
+		phase = DefaultSlice
+	}
 	go func() {
 		idx := make(map[string]struct{})
 		for scanner.Scan() {
-			line := scanner.Text()
-			sl := strings.TrimSpace(line)
-
-			var (
-				name    string
-				verb    string
-				payload string
-			)
-
-			if !(strings.HasPrefix(sl, "[") && strings.Contains(sl, "]")) {
-				name = phase
-				payload = line
-			} else {
-				start := strings.IndexRune(sl, '[')
-				end := strings.IndexRune(sl, ']')
-				name = sl[start+1 : end]
-				payload = strings.TrimPrefix(sl[end+1:], " ")
-
-				if segs := strings.Split(name, "|"); len(segs) == 2 {
-					name = segs[0]
-					verb = segs[1]
-				}
-			}
+			name, verb, payload := parseLine(scanner.Text(), phase)
+			parent := Parent(name)
 
 			switch verb {
 			case "DONE":
 				delete(idx, name)
 				evts <- &v1.LogSliceEvent{
-					Name: name,
-					Type: v1.LogSliceType_SLICE_DONE,
+					Name:   name,
+					Parent: parent,
+					Type:   v1.LogSliceType_SLICE_DONE,
 				}
 				continue
 			case "FAIL":
 				delete(idx, name)
 				evts <- &v1.LogSliceEvent{
 					Name:    name,
+					Parent:  parent,
 					Payload: payload,
 					Type:    v1.LogSliceType_SLICE_FAIL,
 				}
@@ -111,6 +150,7 @@ func (defaultCutter) Slice(in io.Reader) (events <-chan *v1.LogSliceEvent, errch
 			case "RESULT":
 				evts <- &v1.LogSliceEvent{
 					Name:    name,
+					Parent:  parent,
 					Type:    v1.LogSliceType_SLICE_RESULT,
 					Payload: payload,
 				}
@@ -118,6 +158,7 @@ func (defaultCutter) Slice(in io.Reader) (events <-chan *v1.LogSliceEvent, errch
 			case "PHASE":
 				evts <- &v1.LogSliceEvent{
 					Name:    name,
+					Parent:  parent,
 					Type:    v1.LogSliceType_SLICE_PHASE,
 					Payload: payload,
 				}
@@ -125,16 +166,26 @@ func (defaultCutter) Slice(in io.Reader) (events <-chan *v1.LogSliceEvent, errch
 				continue
 			}
 
-			_, exists := idx[name]
-			if !exists {
-				idx[name] = struct{}{}
+			// a nested slice starts its parents first so that the tree of slices is complete
+			var starting []string
+			for n := name; n != ""; n = Parent(n) {
+				if _, exists := idx[n]; exists {
+					break
+				}
+				starting = append(starting, n)
+			}
+			for i := len(starting) - 1; i >= 0; i-- {
+				n := starting[i]
+				idx[n] = struct{}{}
 				evts <- &v1.LogSliceEvent{
-					Name: name,
-					Type: v1.LogSliceType_SLICE_START,
+					Name:   n,
+					Parent: Parent(n),
+					Type:   v1.LogSliceType_SLICE_START,
 				}
 			}
 			evts <- &v1.LogSliceEvent{
 				Name:    name,
+				Parent:  parent,
 				Type:    v1.LogSliceType_SLICE_CONTENT,
 				Payload: string([]byte(payload)),
 			}
@@ -145,8 +196,9 @@ func (defaultCutter) Slice(in io.Reader) (events <-chan *v1.LogSliceEvent, errch
 
 		for name := range idx {
 			evts <- &v1.LogSliceEvent{
-				Name: name,
-				Type: v1.LogSliceType_SLICE_ABANDONED,
+				Name:   name,
+				Parent: Parent(name),
+				Type:   v1.LogSliceType_SLICE_ABANDONED,
 			}
 		}
 
@@ -3,8 +3,10 @@ package logcutter_test
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 
 	v1 "github.com/csweichel/werft/pkg/api/v1"
@@ -50,6 +52,27 @@ func TestDefaultCutterSlice(t *testing.T) {
 			},
 			nil,
 		},
+		{
+			`
+[build|compile] compiling foo
+[build] building
+[build|compile|lib] compiling lib
+[build|compile|DONE]
+[build|FAIL] something broke
+			`,
+			[]v1.LogSliceEvent{
+				v1.LogSliceEvent{Name: "build", Type: v1.LogSliceType_SLICE_START},
+				v1.LogSliceEvent{Name: "build|compile", Parent: "build", Type: v1.LogSliceType_SLICE_START},
+				v1.LogSliceEvent{Name: "build|compile", Parent: "build", Type: v1.LogSliceType_SLICE_CONTENT, Payload: "compiling foo"},
+				v1.LogSliceEvent{Name: "build", Type: v1.LogSliceType_SLICE_CONTENT, Payload: "building"},
+				v1.LogSliceEvent{Name: "build|compile|lib", Parent: "build|compile", Type: v1.LogSliceType_SLICE_START},
+				v1.LogSliceEvent{Name: "build|compile|lib", Parent: "build|compile", Type: v1.LogSliceType_SLICE_CONTENT, Payload: "compiling lib"},
+				v1.LogSliceEvent{Name: "build|compile", Parent: "build", Type: v1.LogSliceType_SLICE_DONE},
+				v1.LogSliceEvent{Name: "build", Type: v1.LogSliceType_SLICE_FAIL, Payload: "something broke"},
+				v1.LogSliceEvent{Name: "build|compile|lib", Parent: "build|compile", Type: v1.LogSliceType_SLICE_ABANDONED},
+			},
+			nil,
+		},
 	}
 
 	for _, test := range tests {
@@ -91,3 +114,77 @@ func TestDefaultCutterSlice(t *testing.T) {
 		}
 	}
 }
+
+func TestDefaultCutterConcurrentWriters(t *testing.T) {
+	const (
+		writers = 8
+		lines   = 200
+	)
+
+	// writers write whole lines, but their lines interleave arbitrarily - just like containers writing to a job log
+	pr, pw := io.Pipe()
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < lines; j++ {
+				line := fmt.Sprintf("[writer%d|step%d] writer%d line %d\n", i, j%3, i, j)
+				if j%2 == 0 {
+					line = fmt.Sprintf("[writer%d] writer%d line %d\n", i, i, j)
+				}
+
+				mu.Lock()
+				pw.Write([]byte(line))
+				mu.Unlock()
+			}
+		}(i)
+	}
+	go func() {
+		wg.Wait()
+		pw.Close()
+	}()
+
+	evtchan, errchan := logcutter.DefaultCutter.Slice(pr)
+	var content int
+recv:
+	for {
+		select {
+		case evt := <-evtchan:
+			if evt == nil {
+				break recv
+			}
+			if evt.Type != v1.LogSliceType_SLICE_CONTENT {
+				continue
+			}
+			content++
+
+			var writer, line int
+			_, err := fmt.Sscanf(evt.Payload, "writer%d line %d", &writer, &line)
+			if err != nil {
+				t.Fatalf("unexpected payload %q: %v", evt.Payload, err)
+			}
+			expected := fmt.Sprintf("writer%d", writer)
+			if line%2 == 1 {
+				expected = fmt.Sprintf("writer%d|step%d", writer, line%3)
+			}
+			if evt.Name != expected {
+				t.Errorf("line %q was attributed to %s instead of %s", evt.Payload, evt.Name, expected)
+			}
+			if parent := logcutter.Parent(evt.Name); evt.Parent != parent {
+				t.Errorf("slice %s has parent %q instead of %q", evt.Name, evt.Parent, parent)
+			}
+		case err := <-errchan:
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+	}
+
+	if content != writers*lines {
+		t.Errorf("received %d lines instead of %d", content, writers*lines)
+	}
+}
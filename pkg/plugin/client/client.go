@@ -12,6 +12,7 @@ import (
 
 	v1 "github.com/csweichel/werft/pkg/api/v1"
 	"github.com/csweichel/werft/pkg/plugin/common"
+	"github.com/csweichel/werft/pkg/tracing"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/xerrors"
 	"google.golang.org/grpc"
@@ -43,7 +44,7 @@ func WithIntegrationPlugin(p IntegrationPlugin) ServeOpt {
 	return ServeOpt{
 		Type: common.TypeIntegration,
 		Run: func(ctx context.Context, config interface{}, socket string) error {
-			conn, err := grpc.Dial(socket, grpc.WithInsecure(), grpc.WithDialer(unixConnect))
+			conn, err := grpc.Dial(socket, grpc.WithInsecure(), grpc.WithDialer(unixConnect), grpc.WithUnaryInterceptor(tracing.UnaryClientInterceptor))
 			if err != nil {
 				return xerrors.Errorf("did not connect: %v", err)
 			}
@@ -16,9 +16,14 @@ import (
 	"time"
 
 	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/audit"
 	"github.com/csweichel/werft/pkg/plugin/common"
+	"github.com/csweichel/werft/pkg/tracing"
 	"github.com/csweichel/werft/pkg/werft"
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/xerrors"
 	"google.golang.org/grpc"
 	"gopkg.in/yaml.v3"
@@ -45,6 +50,10 @@ type Plugins struct {
 	sockets      map[string]string
 	werftService v1.WerftServiceServer
 	repoProvider *compoundRepositoryProvider
+
+	metrics struct {
+		WebhookRequestsTotal *prometheus.CounterVec
+	}
 }
 
 // RepositoryProvider provides access to all repo providers contributed via plugins
@@ -52,6 +61,11 @@ func (p *Plugins) RepositoryProvider() werft.RepositoryProvider {
 	return p.repoProvider
 }
 
+// RegisterPrometheusMetrics registers the plugin metrics on the registerer with MustRegister
+func (p *Plugins) RegisterPrometheusMetrics(reg prometheus.Registerer) {
+	reg.MustRegister(p.metrics.WebhookRequestsTotal)
+}
+
 // Stop stops all plugins
 func (p *Plugins) Stop() {
 	close(p.stopchan)
@@ -79,6 +93,12 @@ func Start(cfg Config, srv v1.WerftServiceServer) (*Plugins, error) {
 		werftService: srv,
 		repoProvider: &compoundRepositoryProvider{},
 	}
+	plugins.metrics.WebhookRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "werft",
+		Subsystem: "plugin",
+		Name:      "webhook_requests_total",
+		Help:      "Total amount of webhook requests received by plugins, e.g. GitHub events.",
+	}, []string{"plugin"})
 
 	for _, pr := range cfg {
 		err := plugins.startPlugin(pr)
@@ -90,10 +110,10 @@ func Start(cfg Config, srv v1.WerftServiceServer) (*Plugins, error) {
 	return plugins, nil
 }
 
-func (p *Plugins) socketFor(t common.Type) (string, error) {
+func (p *Plugins) socketFor(name string, t common.Type) (string, error) {
 	switch t {
 	case common.TypeIntegration:
-		return p.socketForIntegrationPlugin()
+		return p.socketForIntegrationPlugin(name)
 	case common.TypeRepository:
 		return p.sockerForRepositoryPlugin()
 	default:
@@ -101,8 +121,11 @@ func (p *Plugins) socketFor(t common.Type) (string, error) {
 	}
 }
 
-func (p *Plugins) socketForIntegrationPlugin() (string, error) {
-	if socket, ok := p.sockets[string(common.TypeIntegration)]; ok {
+// socketForIntegrationPlugin starts a werft service for an integration plugin. Each plugin gets its own
+// socket so that we know which plugin made a call, e.g. for the audit log.
+func (p *Plugins) socketForIntegrationPlugin(name string) (string, error) {
+	key := string(common.TypeIntegration) + "/" + name
+	if socket, ok := p.sockets[key]; ok {
 		return socket, nil
 	}
 
@@ -111,14 +134,18 @@ func (p *Plugins) socketForIntegrationPlugin() (string, error) {
 	if err != nil {
 		return "", xerrors.Errorf("cannot start integration plugin server: %w", err)
 	}
-	s := grpc.NewServer()
+	actor := "plugin:" + name
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(audit.UnaryServerInterceptor(actor), tracing.UnaryServerInterceptor),
+		grpc.StreamInterceptor(audit.StreamServerInterceptor(actor)),
+	)
 	v1.RegisterWerftServiceServer(s, p.werftService)
 	go func() {
 		err := s.Serve(lis)
 		if err != nil {
 			p.Errchan <- Error{Err: err}
 		}
-		delete(p.sockets, string(common.TypeIntegration))
+		delete(p.sockets, key)
 	}()
 
 	go func() {
@@ -129,7 +156,7 @@ func (p *Plugins) socketForIntegrationPlugin() (string, error) {
 		s.Stop()
 	}()
 
-	p.sockets[string(common.TypeIntegration)] = socketFN
+	p.sockets[key] = socketFN
 	return socketFN, nil
 }
 
@@ -145,11 +172,18 @@ func (p *Plugins) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 		resp.WriteHeader(http.StatusNotFound)
 		return
 	}
+	p.metrics.WebhookRequestsTotal.WithLabelValues(plgn).Inc()
+
+	// the plugin can continue the trace using tracing.Extract
+	ctx, span := tracing.Tracer().Start(tracing.Extract(req.Context(), req.Header), "webhook", trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attribute.String("werft.plugin", plgn)))
+	defer span.End()
+	req = req.WithContext(ctx)
 
 	proxy := &httputil.ReverseProxy{
 		Director: func(req *http.Request) {
 			req.URL.Scheme = "http"
 			req.URL.Host = "localhost"
+			tracing.Inject(req.Context(), req.Header)
 
 			pth := req.URL.Path
 			pth = strings.TrimPrefix(pth, "/")
@@ -180,7 +214,7 @@ func (p *Plugins) startPlugin(reg Registration) error {
 	}
 
 	for _, t := range reg.Type {
-		socket, err := p.socketFor(t)
+		socket, err := p.socketFor(reg.Name, t)
 		if err != nil {
 			return err
 		}
@@ -1,6 +1,10 @@
 package prettyprint
 
-import "github.com/gogo/protobuf/jsonpb"
+import (
+	"fmt"
+
+	"github.com/gogo/protobuf/jsonpb"
+)
 
 // JSONFormat formats everythign as JSON
 const JSONFormat Format = "json"
@@ -10,5 +14,11 @@ func formatJSON(pp *Content) error {
 		EnumsAsInts: false,
 		Indent:      "  ",
 	}
-	return enc.Marshal(pp.Writer, pp.Obj)
+	err := enc.Marshal(pp.Writer, pp.Obj)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(pp.Writer)
+	return err
 }
@@ -41,6 +41,8 @@ type Content struct {
 	Format   Format
 	Writer   io.Writer
 	Template string
+	// Funcs are functions templates can use besides the built-in ones, e.g. to color their output
+	Funcs map[string]interface{}
 }
 
 // Print outputs the content to its writer in the given format
@@ -1,6 +1,7 @@
 package prettyprint
 
 import (
+	"fmt"
 	"text/tabwriter"
 	"text/template"
 	"time"
@@ -15,6 +16,7 @@ const TemplateFormat Format = "template"
 func formatTemplate(pp *Content) error {
 	tmpl, err := template.
 		New("prettyprint").
+		Funcs(pp.Funcs).
 		Funcs(map[string]interface{}{
 			"toRFC3339": func(t *tspb.Timestamp) string {
 				ts, err := ptypes.Timestamp(t)
@@ -23,6 +25,13 @@ func formatTemplate(pp *Content) error {
 				}
 				return ts.Format(time.RFC3339)
 			},
+			"age": func(t *tspb.Timestamp) string {
+				ts, err := ptypes.Timestamp(t)
+				if err != nil {
+					return "-"
+				}
+				return FormatAge(time.Since(ts))
+			},
 		}).
 		Parse(pp.Template)
 	if err != nil {
@@ -38,3 +47,31 @@ func formatTemplate(pp *Content) error {
 	}
 	return nil
 }
+
+// FormatAge formats a duration compactly using at most two units, e.g. 3s, 5m, 1h30m or 2d4h.
+func FormatAge(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+
+	var (
+		days    = int(d / (24 * time.Hour))
+		hours   = int(d/time.Hour) % 24
+		minutes = int(d/time.Minute) % 60
+		seconds = int(d/time.Second) % 60
+	)
+	switch {
+	case days > 0 && hours > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case days > 0:
+		return fmt.Sprintf("%dd", days)
+	case hours > 0 && minutes > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	case hours > 0:
+		return fmt.Sprintf("%dh", hours)
+	case minutes > 0:
+		return fmt.Sprintf("%dm", minutes)
+	default:
+		return fmt.Sprintf("%ds", seconds)
+	}
+}
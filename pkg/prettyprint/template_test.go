@@ -0,0 +1,89 @@
+package prettyprint_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/prettyprint"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+func TestTemplateFormat(t *testing.T) {
+	job := &v1.JobStatus{
+		Name:  "werft-build-main.1",
+		Phase: v1.JobPhase_PHASE_DONE,
+		Metadata: &v1.JobMetadata{
+			Owner:   "foo",
+			Created: &timestamp.Timestamp{Seconds: 1577836800},
+			Annotations: []*v1.Annotation{
+				{Key: "version", Value: "1.0"},
+				{Key: "updateGitHubStatus", Value: "csweichel/werft"},
+			},
+		},
+	}
+
+	tests := []struct {
+		Name     string
+		Obj      *v1.ListJobsResponse
+		Template string
+		Result   string
+	}{
+		{
+			Name:     "annotations",
+			Obj:      &v1.ListJobsResponse{Result: []*v1.JobStatus{job}},
+			Template: "{{ range .Result }}{{ .Name }}{{ range .Metadata.Annotations }} {{ .Key }}={{ .Value }}{{ end }}\n{{ end }}",
+			Result:   "werft-build-main.1 version=1.0 updateGitHubStatus=csweichel/werft\n",
+		},
+		{
+			Name:     "created",
+			Obj:      &v1.ListJobsResponse{Result: []*v1.JobStatus{job}},
+			Template: "{{ range .Result }}{{ .Phase }} {{ .Metadata.Created | toRFC3339 }}\n{{ end }}",
+			Result:   "PHASE_DONE 2020-01-01T00:00:00Z\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := (&prettyprint.Content{
+				Obj:      test.Obj,
+				Format:   prettyprint.TemplateFormat,
+				Writer:   &out,
+				Template: test.Template,
+			}).Print()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != test.Result {
+				t.Errorf("expected %q but got %q", test.Result, out.String())
+			}
+		})
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		Input  time.Duration
+		Result string
+	}{
+		{-5 * time.Second, "0s"},
+		{0, "0s"},
+		{3 * time.Second, "3s"},
+		{59*time.Second + 900*time.Millisecond, "59s"},
+		{5*time.Minute + 20*time.Second, "5m"},
+		{time.Hour, "1h"},
+		{90 * time.Minute, "1h30m"},
+		{24 * time.Hour, "1d"},
+		{52*time.Hour + 10*time.Minute, "2d4h"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input.String(), func(t *testing.T) {
+			if res := prettyprint.FormatAge(test.Input); res != test.Result {
+				t.Errorf("expected %s but got %s", test.Result, res)
+			}
+		})
+	}
+}
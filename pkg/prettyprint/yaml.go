@@ -1,6 +1,9 @@
 package prettyprint
 
 import (
+	"bytes"
+
+	"github.com/gogo/protobuf/jsonpb"
 	"gopkg.in/yaml.v3"
 )
 
@@ -8,10 +11,35 @@ import (
 const YAMLFormat Format = "yaml"
 
 func formatYAML(pp *Content) error {
-	err := yaml.NewEncoder(pp.Writer).Encode(pp.Obj)
+	// We go through the protobuf JSON marshaler first so that we get the same field names
+	// and enums rendered as their names rather than integers.
+	var buf bytes.Buffer
+	err := (&jsonpb.Marshaler{EnumsAsInts: false}).Marshal(&buf, pp.Obj)
+	if err != nil {
+		return err
+	}
+
+	var node yaml.Node
+	err = yaml.Unmarshal(buf.Bytes(), &node)
+	if err != nil {
+		return err
+	}
+	resetYAMLStyle(&node)
+
+	enc := yaml.NewEncoder(pp.Writer)
+	enc.SetIndent(2)
+	err = enc.Encode(&node)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return enc.Close()
+}
+
+// resetYAMLStyle removes the flow/quoting style the YAML parser picked up from the JSON input
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, c := range node.Content {
+		resetYAMLStyle(c)
+	}
 }
@@ -0,0 +1,231 @@
+package store
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"path"
+
+	"github.com/csweichel/werft/pkg/logcutter"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
+)
+
+// BlobStore stores opaque blobs, e.g. in an object storage such as S3 or GCS
+type BlobStore interface {
+	// Put stores a blob, replacing the blob stored under that key before.
+	Put(ctx context.Context, key string, r io.Reader) error
+
+	// Get retrieves a blob.
+	// Returns ErrNotFound if there's no blob with that key.
+	// Callers are supposed to close the reader once done.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// BlobLogStore persists logs in a blob store. While a job runs its logs are written to and read from
+// a cache log store. Once the log is closed it's uploaded to the blob store, which serves logs the cache
+// no longer has, e.g. after a restart.
+type BlobLogStore struct {
+	Cache Logs
+	Blobs BlobStore
+
+	// Prefix is prepended to the key of all log blobs
+	Prefix string
+
+	// Compress gzips logs before uploading them. Compressed and uncompressed blobs can be read either way.
+	Compress bool
+}
+
+// NewBlobLogStore creates a new blob backed log store
+func NewBlobLogStore(cache Logs, blobs BlobStore, prefix string) *BlobLogStore {
+	return &BlobLogStore{
+		Cache:  cache,
+		Blobs:  blobs,
+		Prefix: prefix,
+	}
+}
+
+func (bs *BlobLogStore) key(id string, compressed bool) string {
+	key := path.Join(bs.Prefix, id+".log")
+	if compressed {
+		key += compressedSuffix
+	}
+	return key
+}
+
+// get retrieves the uncompressed log from the blob store. We first look for the log in the format
+// we'd upload it in, and fall back to the other one in case the compression setting changed.
+func (bs *BlobLogStore) get(id string) (io.ReadCloser, error) {
+	for _, compressed := range []bool{bs.Compress, !bs.Compress} {
+		blob, err := bs.Blobs.Get(context.Background(), bs.key(id, compressed))
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !compressed {
+			return blob, nil
+		}
+
+		zr, err := gzip.NewReader(blob)
+		if err != nil {
+			blob.Close()
+			return nil, err
+		}
+		return &gzipBlob{Reader: zr, blob: blob}, nil
+	}
+	return nil, ErrNotFound
+}
+
+// Open places a logfile in this store. If the cache does not have the log but the blob store has,
+// the log is restored into the cache before we continue writing.
+func (bs *BlobLogStore) Open(id string) (io.WriteCloser, error) {
+	rd, err := bs.Cache.Read(id)
+	if err == nil {
+		rd.Close()
+	} else if err == ErrNotFound {
+		err = bs.restore(id)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+
+	w, err := bs.Cache.Open(id)
+	if err != nil {
+		return nil, err
+	}
+	return &uploadingWriter{WriteCloser: w, store: bs, id: id}, nil
+}
+
+// restore copies a log from the blob store into the cache
+func (bs *BlobLogStore) restore(id string) error {
+	blob, err := bs.get(id)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return xerrors.Errorf("cannot restore log %s: %w", id, err)
+	}
+	defer blob.Close()
+
+	w, err := bs.Cache.Open(id)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, blob)
+	if err != nil {
+		w.Close()
+		return xerrors.Errorf("cannot restore log %s: %w", id, err)
+	}
+	return w.Close()
+}
+
+// upload copies a closed log from the cache to the blob store
+func (bs *BlobLogStore) upload(id string) error {
+	rd, err := bs.Cache.Read(id)
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+
+	if !bs.Compress {
+		return bs.Blobs.Put(context.Background(), bs.key(id, false), rd)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		zw := gzip.NewWriter(pw)
+		_, err := io.Copy(zw, rd)
+		if err == nil {
+			err = zw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	err = bs.Blobs.Put(context.Background(), bs.key(id, true), pr)
+	pr.CloseWithError(err)
+	return err
+}
+
+// Write provides write access to a previously placed logfile
+func (bs *BlobLogStore) Write(id string) (io.Writer, error) {
+	return bs.Cache.Write(id)
+}
+
+// Read retrieves a log file from the cache, or the blob store if the cache does not have it
+func (bs *BlobLogStore) Read(id string) (io.ReadCloser, error) {
+	rd, err := bs.Cache.Read(id)
+	if err != ErrNotFound {
+		return rd, err
+	}
+
+	return bs.get(id)
+}
+
+// ReadSection retrieves a log file starting at the first line of a section, provided the cache indexes sections.
+// Otherwise, or if the log comes from the blob store, the log is read from the start.
+func (bs *BlobLogStore) ReadSection(id, section string) (rd io.ReadCloser, phase string, err error) {
+	if cache, ok := bs.Cache.(SectionLogs); ok {
+		rd, phase, err = cache.ReadSection(id, section)
+		if err != ErrNotFound {
+			return rd, phase, err
+		}
+	}
+
+	rd, err = bs.Read(id)
+	return rd, logcutter.DefaultSlice, err
+}
+
+// List returns the logs in the cache, provided the cache supports this. Logs in the blob store
+// are not listed, their retention is up to the blob store, e.g. using a bucket lifecycle rule.
+func (bs *BlobLogStore) List() ([]LogInfo, error) {
+	cache, ok := bs.Cache.(PrunableLogs)
+	if !ok {
+		return nil, nil
+	}
+	return cache.List()
+}
+
+// Delete removes a log from the cache. The log remains available from the blob store.
+func (bs *BlobLogStore) Delete(id string) error {
+	cache, ok := bs.Cache.(PrunableLogs)
+	if !ok {
+		return ErrNotFound
+	}
+	return cache.Delete(id)
+}
+
+// uploadingWriter uploads the log to the blob store once it's closed
+type uploadingWriter struct {
+	io.WriteCloser
+
+	store *BlobLogStore
+	id    string
+}
+
+func (w *uploadingWriter) Close() error {
+	err := w.WriteCloser.Close()
+	if err != nil {
+		return err
+	}
+
+	err = w.store.upload(w.id)
+	if err != nil {
+		log.WithError(err).WithField("name", w.id).Error("cannot upload log to blob store")
+		return xerrors.Errorf("cannot upload log %s: %w", w.id, err)
+	}
+	return nil
+}
+
+// gzipBlob decompresses a gzipped blob
+type gzipBlob struct {
+	*gzip.Reader
+	blob io.ReadCloser
+}
+
+func (g *gzipBlob) Close() error {
+	g.Reader.Close()
+	return g.blob.Close()
+}
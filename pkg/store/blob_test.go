@@ -0,0 +1,159 @@
+package store_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/csweichel/werft/pkg/store"
+)
+
+type fakeBlobStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func (f *fakeBlobStore) Put(ctx context.Context, key string, r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.blobs[key] = data
+	return nil
+}
+
+func (f *fakeBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.blobs[key]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func newBlobLogStore(t *testing.T, blobs store.BlobStore) *store.BlobLogStore {
+	base, err := ioutil.TempDir(os.TempDir(), "tbls")
+	if err != nil {
+		t.Fatalf("cannot create test folder: %v", err)
+	}
+
+	cache, err := store.NewFileLogStore(base)
+	if err != nil {
+		t.Fatalf("cannot create cache store: %v", err)
+	}
+	return store.NewBlobLogStore(cache, blobs, "logs")
+}
+
+func readAll(t *testing.T, s store.Logs, id string) string {
+	r, err := s.Read(id)
+	if err != nil {
+		t.Fatalf("cannot read log: %v", err)
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("cannot read log: %v", err)
+	}
+	return string(data)
+}
+
+func TestBlobLogStore(t *testing.T) {
+	blobs := &fakeBlobStore{blobs: make(map[string][]byte)}
+	s := newBlobLogStore(t, blobs)
+
+	w, err := s.Open("foo")
+	if err != nil {
+		t.Fatalf("cannot place log: %v", err)
+	}
+	_, err = w.Write([]byte("hello world\n"))
+	if err != nil {
+		t.Fatalf("cannot write log: %v", err)
+	}
+	if _, uploaded := blobs.blobs["logs/foo.log"]; uploaded {
+		t.Error("log must not be uploaded before it's closed")
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("cannot close log: %v", err)
+	}
+	if act := string(blobs.blobs["logs/foo.log"]); act != "hello world\n" {
+		t.Errorf("unexpected blob content: %q", act)
+	}
+
+	// a store with an empty cache, e.g. after the node died, serves logs from the blob store
+	restarted := newBlobLogStore(t, blobs)
+	if act := readAll(t, restarted, "foo"); act != "hello world\n" {
+		t.Errorf("unexpected log content: %q", act)
+	}
+
+	// re-opening a log continues where the blob store left off
+	w, err = restarted.Open("foo")
+	if err != nil {
+		t.Fatalf("cannot place log: %v", err)
+	}
+	_, err = w.Write([]byte("hello again\n"))
+	if err != nil {
+		t.Fatalf("cannot write log: %v", err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("cannot close log: %v", err)
+	}
+	if act := string(blobs.blobs["logs/foo.log"]); act != "hello world\nhello again\n" {
+		t.Errorf("unexpected blob content: %q", act)
+	}
+
+	_, err = restarted.Read("bar")
+	if err != store.ErrNotFound {
+		t.Errorf("expected ErrNotFound for unknown log, got %v", err)
+	}
+}
+
+func TestCompressedBlobLogStore(t *testing.T) {
+	blobs := &fakeBlobStore{blobs: make(map[string][]byte)}
+	s := newBlobLogStore(t, blobs)
+	s.Compress = true
+
+	w, err := s.Open("foo")
+	if err != nil {
+		t.Fatalf("cannot place log: %v", err)
+	}
+	_, err = w.Write([]byte("hello world\n"))
+	if err != nil {
+		t.Fatalf("cannot write log: %v", err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("cannot close log: %v", err)
+	}
+	if _, uploaded := blobs.blobs["logs/foo.log"]; uploaded {
+		t.Error("log must not be uploaded uncompressed")
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(blobs.blobs["logs/foo.log.gz"]))
+	if err != nil {
+		t.Fatalf("cannot decompress blob: %v", err)
+	}
+	if act, _ := ioutil.ReadAll(zr); string(act) != "hello world\n" {
+		t.Errorf("unexpected blob content: %q", act)
+	}
+
+	restarted := newBlobLogStore(t, blobs)
+	if act := readAll(t, restarted, "foo"); act != "hello world\n" {
+		t.Errorf("uncompressed store cannot read compressed log: %q", act)
+	}
+
+	blobs.blobs["logs/bar.log"] = []byte("uncompressed\n")
+	if act := readAll(t, s, "bar"); act != "uncompressed\n" {
+		t.Errorf("compressed store cannot read uncompressed log: %q", act)
+	}
+}
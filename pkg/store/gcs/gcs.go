@@ -0,0 +1,68 @@
+package gcs
+
+import (
+	"context"
+	"io"
+
+	"cloud.google.com/go/storage"
+	"github.com/csweichel/werft/pkg/store"
+	"golang.org/x/xerrors"
+)
+
+// Config configures a Google Cloud Storage blob store
+type Config struct {
+	Bucket string `yaml:"bucket"`
+}
+
+// BlobStore stores blobs in a Google Cloud Storage bucket
+type BlobStore struct {
+	Bucket string
+
+	client *storage.Client
+}
+
+// NewBlobStore creates a new GCS blob store. Credentials are taken from the environment,
+// e.g. the GOOGLE_APPLICATION_CREDENTIALS environment variable or workload identity.
+func NewBlobStore(ctx context.Context, cfg Config) (*BlobStore, error) {
+	if cfg.Bucket == "" {
+		return nil, xerrors.Errorf("GCS bucket is required")
+	}
+
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot create GCS client: %w", err)
+	}
+
+	return &BlobStore{
+		Bucket: cfg.Bucket,
+		client: client,
+	}, nil
+}
+
+// Put uploads a blob
+func (s *BlobStore) Put(ctx context.Context, key string, r io.Reader) error {
+	w := s.client.Bucket(s.Bucket).Object(key).NewWriter(ctx)
+	w.ContentType = "text/plain; charset=utf-8"
+	_, err := io.Copy(w, r)
+	if err != nil {
+		w.Close()
+		return xerrors.Errorf("cannot upload %s: %w", key, err)
+	}
+	err = w.Close()
+	if err != nil {
+		return xerrors.Errorf("cannot upload %s: %w", key, err)
+	}
+	return nil
+}
+
+// Get downloads a blob
+func (s *BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	rd, err := s.client.Bucket(s.Bucket).Object(key).NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("cannot download %s: %w", key, err)
+	}
+	return rd, nil
+}
@@ -0,0 +1,266 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/golang/protobuf/jsonpb"
+	"golang.org/x/xerrors"
+)
+
+const (
+	// jobFileSuffix is appended to the (escaped) name of a job to name the file which holds its status
+	jobFileSuffix = ".json"
+	// jobSpecFileSuffix is appended to the (escaped) name of a job to name the file which holds its job spec
+	jobSpecFileSuffix = ".yaml"
+	// tmpFileSuffix is appended to the name of files while they're written
+	tmpFileSuffix = ".tmp"
+)
+
+// FileJobStore stores each job and its job spec in a file below a directory. All jobs are kept in memory
+// to search them, hence this store suits small installations which don't want to run a database.
+type FileJobStore struct {
+	Base string
+
+	mu  sync.Mutex
+	mem *inMemoryJobStore
+}
+
+// NewFileJobStore creates a new file backed job store, loading all jobs previously stored in base
+func NewFileJobStore(base string) (*FileJobStore, error) {
+	s := &FileJobStore{
+		Base: base,
+		mem:  newInMemoryJobStore(),
+	}
+	for _, dir := range []string{s.jobsDir(), s.specsDir()} {
+		err := os.MkdirAll(dir, 0755)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot create job store directory: %w", err)
+		}
+	}
+
+	err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileJobStore) jobsDir() string {
+	return filepath.Join(s.Base, "jobs")
+}
+
+func (s *FileJobStore) specsDir() string {
+	return filepath.Join(s.Base, "specs")
+}
+
+func (s *FileJobStore) jobFile(name string) string {
+	return filepath.Join(s.jobsDir(), url.PathEscape(name)+jobFileSuffix)
+}
+
+func (s *FileJobStore) specFile(name string) string {
+	return filepath.Join(s.specsDir(), url.PathEscape(name)+jobSpecFileSuffix)
+}
+
+// load reads all jobs and job specs from disk
+func (s *FileJobStore) load() error {
+	files, err := ioutil.ReadDir(s.jobsDir())
+	if err != nil {
+		return xerrors.Errorf("cannot load jobs: %w", err)
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), jobFileSuffix) {
+			continue
+		}
+
+		fn := filepath.Join(s.jobsDir(), f.Name())
+		fp, err := os.Open(fn)
+		if err != nil {
+			return xerrors.Errorf("cannot load job %s: %w", fn, err)
+		}
+		var job v1.JobStatus
+		err = jsonpb.Unmarshal(fp, &job)
+		fp.Close()
+		if err != nil {
+			return xerrors.Errorf("cannot load job %s: %w", fn, err)
+		}
+		s.mem.jobs[job.Name] = job
+	}
+
+	files, err = ioutil.ReadDir(s.specsDir())
+	if err != nil {
+		return xerrors.Errorf("cannot load job specs: %w", err)
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), jobSpecFileSuffix) {
+			continue
+		}
+
+		name, err := url.PathUnescape(strings.TrimSuffix(f.Name(), jobSpecFileSuffix))
+		if err != nil {
+			return xerrors.Errorf("cannot load job spec %s: %w", f.Name(), err)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(s.specsDir(), f.Name()))
+		if err != nil {
+			return xerrors.Errorf("cannot load job spec %s: %w", f.Name(), err)
+		}
+		s.mem.specs[name] = data
+	}
+	return nil
+}
+
+// Store stores job information in the store.
+// Storing a job whose name we already have in store will override the previously
+// stored job.
+func (s *FileJobStore) Store(ctx context.Context, job v1.JobStatus) error {
+	var marshaler jsonpb.Marshaler
+	data, err := marshaler.MarshalToString(&job)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err = writeFileAtomically(s.jobFile(job.Name), []byte(data))
+	if err != nil {
+		return err
+	}
+	return s.mem.Store(ctx, job)
+}
+
+// StoreJobSpec stores job YAML data.
+func (s *FileJobStore) StoreJobSpec(name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := writeFileAtomically(s.specFile(name), data)
+	if err != nil {
+		return err
+	}
+	return s.mem.StoreJobSpec(name, data)
+}
+
+// Get retrieves a particular job based on its name.
+// If the job is unknown we'll return ErrNotFound.
+func (s *FileJobStore) Get(ctx context.Context, name string) (*v1.JobStatus, error) {
+	return s.mem.Get(ctx, name)
+}
+
+// GetJobSpec retrieves previously stored job spec data
+func (s *FileJobStore) GetJobSpec(name string) (data []byte, err error) {
+	return s.mem.GetJobSpec(name)
+}
+
+// Find searches for jobs based on their annotations. If filter is empty no filter is applied.
+func (s *FileJobStore) Find(ctx context.Context, filter []*v1.FilterExpression, order []*v1.OrderExpression, group []string, start, limit int) (slice []v1.JobStatus, total int, err error) {
+	return s.mem.Find(ctx, filter, order, group, start, limit)
+}
+
+// Delete removes a job and its job spec from the store.
+// Returns ErrNotFound if the job is unknown.
+func (s *FileJobStore) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.mem.Get(ctx, name); err != nil {
+		return err
+	}
+	for _, fn := range []string{s.jobFile(name), s.specFile(name)} {
+		err := os.Remove(fn)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return s.mem.Delete(ctx, name)
+}
+
+// FileNumberGroup stores number groups in a single file
+type FileNumberGroup struct {
+	Filename string
+
+	mu     sync.Mutex
+	groups map[string]int
+}
+
+// NewFileNumberGroup creates file backed number groups, loading the numbers previously stored in fn
+func NewFileNumberGroup(fn string) (*FileNumberGroup, error) {
+	g := &FileNumberGroup{
+		Filename: fn,
+		groups:   make(map[string]int),
+	}
+
+	fc, err := ioutil.ReadFile(fn)
+	if os.IsNotExist(err) {
+		return g, nil
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("cannot load number groups: %w", err)
+	}
+	err = json.Unmarshal(fc, &g.groups)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot load number groups from %s: %w", fn, err)
+	}
+	return g, nil
+}
+
+// Latest returns the latest number of a particular number group.
+func (g *FileNumberGroup) Latest(group string) (nr int, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	nr, ok := g.groups[group]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return nr, nil
+}
+
+// Next returns the next number in the group, starting at 0. The number is written to disk
+// before it's returned, hence no number is handed out twice - not even across restarts.
+func (g *FileNumberGroup) Next(group string) (nr int, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	nr = nextNumber(g.groups, group)
+	groups := make(map[string]int, len(g.groups)+1)
+	for k, v := range g.groups {
+		groups[k] = v
+	}
+	groups[group] = nr
+
+	fc, err := json.Marshal(groups)
+	if err != nil {
+		return 0, err
+	}
+	err = writeFileAtomically(g.Filename, fc)
+	if err != nil {
+		return 0, err
+	}
+
+	g.groups = groups
+	return nr, nil
+}
+
+// writeFileAtomically writes data to a temporary file which then replaces fn, so that readers and
+// crashes never see a partially written file
+func writeFileAtomically(fn string, data []byte) error {
+	tmp := fn + tmpFileSuffix
+	err := ioutil.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, fn)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package store_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/store"
+	"github.com/csweichel/werft/pkg/store/storetest"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestFileJobStore(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), "tfjs")
+	if err != nil {
+		t.Fatalf("cannot create test folder: %v", err)
+	}
+	defer os.RemoveAll(base)
+
+	storetest.TestJobs(t, func(t *testing.T) store.Jobs {
+		dir, err := ioutil.TempDir(base, "jobs")
+		if err != nil {
+			t.Fatalf("cannot create test folder: %v", err)
+		}
+		jobs, err := store.NewFileJobStore(dir)
+		if err != nil {
+			t.Fatalf("cannot create job store: %v", err)
+		}
+		return jobs
+	})
+}
+
+func TestFileJobStoreReload(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), "tfjsr")
+	if err != nil {
+		t.Fatalf("cannot create test folder: %v", err)
+	}
+	defer os.RemoveAll(base)
+
+	ctx := context.Background()
+	jobs, err := store.NewFileJobStore(base)
+	if err != nil {
+		t.Fatalf("cannot create job store: %v", err)
+	}
+	var (
+		kept    = storetest.Job{Name: "werft-build/feature.1", Phase: v1.JobPhase_PHASE_DONE, Owner: "foo", Repo: "werft", Ref: "feature", Created: 1000, Annotations: map[string]string{"version": "1"}}.Status()
+		deleted = storetest.Job{Name: "werft-build.2", Phase: v1.JobPhase_PHASE_DONE, Created: 2000}.Status()
+	)
+	for _, job := range []v1.JobStatus{kept, deleted} {
+		err = jobs.Store(ctx, job)
+		if err != nil {
+			t.Fatalf("cannot store job: %v", err)
+		}
+		err = jobs.StoreJobSpec(job.Name, []byte("pod: {}"))
+		if err != nil {
+			t.Fatalf("cannot store job spec: %v", err)
+		}
+	}
+	err = jobs.Delete(ctx, deleted.Name)
+	if err != nil {
+		t.Fatalf("cannot delete job: %v", err)
+	}
+
+	// a new store on the same directory, e.g. after werft restarted, sees what was stored before
+	jobs, err = store.NewFileJobStore(base)
+	if err != nil {
+		t.Fatalf("cannot reload job store: %v", err)
+	}
+	act, err := jobs.Get(ctx, kept.Name)
+	if err != nil {
+		t.Fatalf("cannot get job after reload: %v", err)
+	}
+	if !proto.Equal(act, &kept) {
+		t.Errorf("unexpected job after reload: %v, expected %v", act, &kept)
+	}
+	spec, err := jobs.GetJobSpec(kept.Name)
+	if err != nil {
+		t.Fatalf("cannot get job spec after reload: %v", err)
+	}
+	if string(spec) != "pod: {}" {
+		t.Errorf("unexpected job spec after reload: %q", string(spec))
+	}
+	_, err = jobs.Get(ctx, deleted.Name)
+	if err != store.ErrNotFound {
+		t.Errorf("expected ErrNotFound for deleted job after reload, got %v", err)
+	}
+	_, total, err := jobs.Find(ctx, nil, nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot find jobs: %v", err)
+	}
+	if total != 1 {
+		t.Errorf("expected one job after reload, found %d", total)
+	}
+}
+
+func TestFileNumberGroup(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), "tfng")
+	if err != nil {
+		t.Fatalf("cannot create test folder: %v", err)
+	}
+	defer os.RemoveAll(base)
+
+	storetest.TestNumberGroup(t, func(t *testing.T) store.NumberGroup {
+		dir, err := ioutil.TempDir(base, "groups")
+		if err != nil {
+			t.Fatalf("cannot create test folder: %v", err)
+		}
+		ngrp, err := store.NewFileNumberGroup(filepath.Join(dir, "numbergroups.json"))
+		if err != nil {
+			t.Fatalf("cannot create number group: %v", err)
+		}
+		return ngrp
+	})
+}
+
+func TestFileNumberGroupReload(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), "tfngr")
+	if err != nil {
+		t.Fatalf("cannot create test folder: %v", err)
+	}
+	defer os.RemoveAll(base)
+
+	fn := filepath.Join(base, "numbergroups.json")
+	ngrp, err := store.NewFileNumberGroup(fn)
+	if err != nil {
+		t.Fatalf("cannot create number group: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		_, err = ngrp.Next("werft")
+		if err != nil {
+			t.Fatalf("cannot get next number: %v", err)
+		}
+	}
+
+	// numbers must never be handed out twice, not even after a restart
+	ngrp, err = store.NewFileNumberGroup(fn)
+	if err != nil {
+		t.Fatalf("cannot reload number group: %v", err)
+	}
+	nr, err := ngrp.Next("werft")
+	if err != nil {
+		t.Fatalf("cannot get next number: %v", err)
+	}
+	if nr != 3 {
+		t.Errorf("expected 3 after reload but got %d", nr)
+	}
+}
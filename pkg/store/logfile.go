@@ -1,26 +1,48 @@
 package store
 
 import (
+	"compress/gzip"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+
+	"github.com/csweichel/werft/pkg/logcutter"
+	"golang.org/x/xerrors"
 )
 
+// compressedSuffix is appended to the filename of compressed logs
+const compressedSuffix = ".gz"
+
+// sectionsSuffix is appended to the filename of a log to name the file which holds its section index
+const sectionsSuffix = ".sections"
+
 // FileLogStore is a file backed log store
 type FileLogStore struct {
 	Base string
 
+	// Compress gzips logs once they're closed. Compressed logs are decompressed when they're read,
+	// hence readers always see the uncompressed log.
+	Compress bool
+
 	mu    sync.Mutex
 	files map[string]*file
 }
 
 type file struct {
-	closed bool
-	fn     string
-	fp     *os.File
-	cond   *sync.Cond
+	closed   bool
+	base     string
+	fn       string
+	compress bool
+	fp       *os.File
+	cond     *sync.Cond
+
+	// sections indexes where the sections of the log start. Nil until the log is written or a section is read.
+	sections *logcutter.SectionIndex
 }
 
 // NewFileLogStore creates a new file backed log store
@@ -40,7 +62,7 @@ func (fs *FileLogStore) Open(id string) (io.WriteCloser, error) {
 
 	if f, exists := fs.files[id]; exists {
 		if f.Closed() {
-			err := f.openForWriting(fs.Base)
+			err := f.openForWriting()
 			if err != nil {
 				return nil, err
 			}
@@ -49,14 +71,8 @@ func (fs *FileLogStore) Open(id string) (io.WriteCloser, error) {
 		return f, nil
 	}
 
-	fn := fmt.Sprintf("%s.log", id)
-	f := &file{
-		closed: true,
-		fn:     fn,
-		fp:     nil,
-		cond:   sync.NewCond(&sync.Mutex{}),
-	}
-	err := f.openForWriting(fs.Base)
+	f := fs.newFile(id)
+	err := f.openForWriting()
 	if err != nil {
 		return nil, err
 	}
@@ -64,6 +80,17 @@ func (fs *FileLogStore) Open(id string) (io.WriteCloser, error) {
 	return f, nil
 }
 
+func (fs *FileLogStore) newFile(id string) *file {
+	return &file{
+		closed:   true,
+		base:     fs.Base,
+		fn:       fmt.Sprintf("%s.log", id),
+		compress: fs.Compress,
+		fp:       nil,
+		cond:     sync.NewCond(&sync.Mutex{}),
+	}
+}
+
 // Write provides write access to a previously placed file
 func (fs *FileLogStore) Write(id string) (io.Writer, error) {
 	fs.mu.Lock()
@@ -77,15 +104,28 @@ func (fs *FileLogStore) Write(id string) (io.Writer, error) {
 	return f, nil
 }
 
-func (f *file) openForWriting(base string) error {
+func (f *file) openForWriting() error {
 	f.cond.L.Lock()
 	defer f.cond.L.Unlock()
 
-	fp, err := os.OpenFile(filepath.Join(base, f.fn), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	fn := filepath.Join(f.base, f.fn)
+	err := decompressFile(fn)
+	if err != nil {
+		return xerrors.Errorf("cannot decompress log: %w", err)
+	}
+
+	fp, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		return err
 	}
+	// we append to what's been written before, hence the index has to cover that, too
+	sections, err := indexSections(fn)
+	if err != nil {
+		fp.Close()
+		return xerrors.Errorf("cannot index log sections: %w", err)
+	}
 	f.fp = fp
+	f.sections = sections
 	f.closed = false
 
 	return nil
@@ -101,6 +141,7 @@ func (f *file) Write(b []byte) (n int, err error) {
 
 	n, err = f.fp.Write(b)
 	if n > 0 {
+		f.sections.Write(b[:n])
 		f.cond.Broadcast()
 	}
 	return n, err
@@ -121,6 +162,19 @@ func (f *file) Close() error {
 	}
 	f.cond.Broadcast()
 
+	err = f.writeSections()
+	if err != nil {
+		return xerrors.Errorf("cannot write log section index: %w", err)
+	}
+
+	if f.compress {
+		// we hold the lock while compressing so that no reader opens the log half way through
+		err = compressFile(filepath.Join(f.base, f.fn))
+		if err != nil {
+			return xerrors.Errorf("cannot compress log: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -138,21 +192,17 @@ func (fs *FileLogStore) Read(id string) (io.ReadCloser, error) {
 
 	f, ok := fs.files[id]
 	if !ok {
-		fn := fmt.Sprintf("%s.log", id)
-		if _, err := os.Stat(filepath.Join(fs.Base, fn)); err != nil {
-			return nil, ErrNotFound
-		}
-
-		f = &file{
-			closed: true,
-			fn:     fn,
-			fp:     nil,
-			cond:   sync.NewCond(&sync.Mutex{}),
+		f = fs.newFile(id)
+		fn := filepath.Join(fs.Base, f.fn)
+		if _, err := os.Stat(fn); err != nil {
+			if _, err := os.Stat(fn + compressedSuffix); err != nil {
+				return nil, ErrNotFound
+			}
 		}
 		fs.files[id] = f
 	}
 
-	fp, err := os.OpenFile(filepath.Join(fs.Base, f.fn), os.O_RDONLY, 0644)
+	fp, err := f.openForReading()
 	if err != nil {
 		return nil, err
 	}
@@ -160,6 +210,293 @@ func (fs *FileLogStore) Read(id string) (io.ReadCloser, error) {
 	return &fileReader{f: f, fp: fp}, nil
 }
 
+// openForReading opens the compressed log if the log is closed and has been compressed,
+// or the uncompressed log otherwise.
+func (f *file) openForReading() (io.ReadCloser, error) {
+	f.cond.L.Lock()
+	defer f.cond.L.Unlock()
+
+	fn := filepath.Join(f.base, f.fn)
+	if f.closed {
+		fp, err := os.Open(fn + compressedSuffix)
+		if err == nil {
+			zr, err := gzip.NewReader(fp)
+			if err != nil {
+				fp.Close()
+				return nil, err
+			}
+			return &gzipFile{Reader: zr, fp: fp}, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	return os.OpenFile(fn, os.O_RDONLY, 0644)
+}
+
+// ReadSection retrieves a log file from this store starting at the first line of a section
+func (fs *FileLogStore) ReadSection(id, section string) (rd io.ReadCloser, phase string, err error) {
+	rd, err = fs.Read(id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	fs.mu.Lock()
+	f := fs.files[id]
+	fs.mu.Unlock()
+	start, err := f.lookupSection(section)
+	if err != nil {
+		rd.Close()
+		return nil, "", err
+	}
+	if start.Offset == 0 {
+		return rd, start.Phase, nil
+	}
+
+	_, err = io.CopyN(ioutil.Discard, rd, start.Offset)
+	if err != nil {
+		rd.Close()
+		return nil, "", err
+	}
+	return rd, start.Phase, nil
+}
+
+// lookupSection finds where a section starts. Sections which haven't started (yet) start at the beginning of the log.
+func (f *file) lookupSection(section string) (logcutter.SectionStart, error) {
+	f.cond.L.Lock()
+	defer f.cond.L.Unlock()
+
+	if f.sections == nil {
+		fn := filepath.Join(f.base, f.fn)
+		sections, err := readSections(fn)
+		if os.IsNotExist(err) {
+			// logs written before we indexed sections are indexed once
+			sections, err = indexSections(fn)
+			if err == nil {
+				f.sections = sections
+				err = f.writeSections()
+			}
+		}
+		if err != nil {
+			return logcutter.SectionStart{}, err
+		}
+		f.sections = sections
+	}
+
+	start, ok := f.sections.Lookup(section)
+	if !ok {
+		return logcutter.SectionStart{Phase: logcutter.DefaultSlice}, nil
+	}
+	return start, nil
+}
+
+// writeSections persists the section index of a log next to it
+func (f *file) writeSections() error {
+	if f.sections == nil {
+		return nil
+	}
+	fc, err := json.Marshal(f.sections)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(f.base, f.fn)+sectionsSuffix, fc, 0644)
+}
+
+// readSections reads the persisted section index of a log
+func readSections(fn string) (*logcutter.SectionIndex, error) {
+	fc, err := ioutil.ReadFile(fn + sectionsSuffix)
+	if err != nil {
+		return nil, err
+	}
+	res := logcutter.NewSectionIndex()
+	err = json.Unmarshal(fc, res)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// indexSections indexes the sections of a log, which may be compressed
+func indexSections(fn string) (*logcutter.SectionIndex, error) {
+	res := logcutter.NewSectionIndex()
+
+	var in io.ReadCloser
+	fp, err := os.Open(fn)
+	if os.IsNotExist(err) {
+		fp, err = os.Open(fn + compressedSuffix)
+		if os.IsNotExist(err) {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		zr, err := gzip.NewReader(fp)
+		if err != nil {
+			fp.Close()
+			return nil, err
+		}
+		in = &gzipFile{Reader: zr, fp: fp}
+	} else if err != nil {
+		return nil, err
+	} else {
+		in = fp
+	}
+	defer in.Close()
+
+	_, err = io.Copy(res, in)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// List returns all log files which aren't open for writing
+func (fs *FileLogStore) List() ([]LogInfo, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	entries, err := ioutil.ReadDir(fs.Base)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		res = make([]LogInfo, 0, len(entries))
+		idx = make(map[string]int, len(entries))
+	)
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), compressedSuffix)
+		if e.IsDir() || !strings.HasSuffix(name, ".log") {
+			continue
+		}
+		id := strings.TrimSuffix(name, ".log")
+		if f, ok := fs.files[id]; ok && !f.Closed() {
+			continue
+		}
+
+		// while a log is (de)compressed both files exist for a brief moment
+		if i, ok := idx[id]; ok {
+			res[i].Size += e.Size()
+			if e.ModTime().After(res[i].Modified) {
+				res[i].Modified = e.ModTime()
+			}
+			continue
+		}
+		idx[id] = len(res)
+		res = append(res, LogInfo{
+			ID:       id,
+			Size:     e.Size(),
+			Modified: e.ModTime(),
+		})
+	}
+	return res, nil
+}
+
+// Delete removes a log file which isn't open for writing
+func (fs *FileLogStore) Delete(id string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if f, ok := fs.files[id]; ok && !f.Closed() {
+		return xerrors.Errorf("cannot delete log %s: log is being written", id)
+	}
+
+	var found bool
+	fn := filepath.Join(fs.Base, fmt.Sprintf("%s.log", id))
+	for _, n := range []string{fn, fn + compressedSuffix} {
+		err := os.Remove(n)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+	}
+	if !found {
+		return ErrNotFound
+	}
+	err := os.Remove(fn + sectionsSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(fs.files, id)
+	return nil
+}
+
+// compressFile gzips fn to fn.gz and removes fn
+func compressFile(fn string) error {
+	in, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := fn + compressedSuffix + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, fn+compressedSuffix)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Remove(fn)
+}
+
+// decompressFile restores fn from fn.gz and removes fn.gz. If there's no fn.gz this function does nothing.
+func decompressFile(fn string) error {
+	in, err := os.Open(fn + compressedSuffix)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if _, err := os.Stat(fn); err == nil {
+		// we didn't get to remove the uncompressed log after compressing it
+		return os.Remove(fn + compressedSuffix)
+	}
+
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return err
+	}
+	tmp := fn + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, zr)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, fn)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Remove(fn + compressedSuffix)
+}
+
 type fileReader struct {
 	f  *file
 	fp io.ReadCloser
@@ -193,3 +530,14 @@ func (fr *fileReader) Read(p []byte) (n int, err error) {
 func (fr *fileReader) Close() error {
 	return fr.fp.Close()
 }
+
+// gzipFile decompresses a gzipped file
+type gzipFile struct {
+	*gzip.Reader
+	fp *os.File
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.fp.Close()
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -94,3 +95,199 @@ func TestContinuousWriteReading(t *testing.T) {
 		t.Errorf("did not read message back, but: %s", string(actual))
 	}
 }
+
+func TestCompressedLogs(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), "tcl")
+	if err != nil {
+		t.Fatalf("cannot create test folder: %v", err)
+	}
+	defer os.RemoveAll(base)
+
+	s, err := store.NewFileLogStore(base)
+	if err != nil {
+		t.Fatalf("cannot create test store: %v", err)
+	}
+	s.Compress = true
+
+	msg := strings.Repeat("[build] compiling package foo/bar\n", 100)
+	w, err := s.Open("foo")
+	if err != nil {
+		t.Fatalf("cannot place log: %v", err)
+	}
+	_, err = w.Write([]byte(msg))
+	if err != nil {
+		t.Fatalf("cannot write log: %v", err)
+	}
+	// a reader which started while the log was written continues to read the uncompressed log
+	r, err := s.Read("foo")
+	if err != nil {
+		t.Fatalf("cannot read log: %v", err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("cannot close log: %v", err)
+	}
+	act, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatalf("cannot read log: %v", err)
+	}
+	if string(act) != msg {
+		t.Errorf("unexpected log content while compressing: %q", string(act))
+	}
+
+	if _, err := os.Stat(filepath.Join(base, "foo.log")); !os.IsNotExist(err) {
+		t.Errorf("uncompressed log should have been removed: %v", err)
+	}
+	fi, err := os.Stat(filepath.Join(base, "foo.log.gz"))
+	if err != nil {
+		t.Fatalf("compressed log is missing: %v", err)
+	}
+	if fi.Size() >= int64(len(msg)) {
+		t.Errorf("compressed log is not smaller than the log: %d >= %d", fi.Size(), len(msg))
+	}
+	if act := readAll(t, s, "foo"); act != msg {
+		t.Errorf("unexpected compressed log content: %q", act)
+	}
+
+	// re-opening a compressed log continues where it left off
+	w, err = s.Open("foo")
+	if err != nil {
+		t.Fatalf("cannot place log: %v", err)
+	}
+	_, err = w.Write([]byte("hello again\n"))
+	if err != nil {
+		t.Fatalf("cannot write log: %v", err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("cannot close log: %v", err)
+	}
+	// a fresh store, e.g. after a restart, finds the compressed log
+	restarted, err := store.NewFileLogStore(base)
+	if err != nil {
+		t.Fatalf("cannot create test store: %v", err)
+	}
+	if act := readAll(t, restarted, "foo"); act != msg+"hello again\n" {
+		t.Errorf("unexpected log content after re-opening: %q", act)
+	}
+
+	logs, err := restarted.List()
+	if err != nil {
+		t.Fatalf("cannot list logs: %v", err)
+	}
+	if len(logs) != 1 || logs[0].ID != "foo" {
+		t.Errorf("unexpected logs: %v", logs)
+	}
+	err = restarted.Delete("foo")
+	if err != nil {
+		t.Fatalf("cannot delete log: %v", err)
+	}
+	if _, err := restarted.Read("foo"); err != store.ErrNotFound {
+		t.Errorf("expected ErrNotFound for deleted log, got %v", err)
+	}
+}
+
+func TestReadSection(t *testing.T) {
+	const (
+		before  = "[build|PHASE] Building\ngo build ./...\n"
+		section = "[test|PHASE] Testing\ngo test ./...\n[test|unit] ok\n"
+		after   = "[deploy|PHASE] Deploying\n"
+	)
+	base, err := ioutil.TempDir(os.TempDir(), "trs")
+	if err != nil {
+		t.Fatalf("cannot create test folder: %v", err)
+	}
+	defer os.RemoveAll(base)
+
+	s, err := store.NewFileLogStore(base)
+	if err != nil {
+		t.Fatalf("cannot create test store: %v", err)
+	}
+	s.Compress = true
+	w, err := s.Open("foo")
+	if err != nil {
+		t.Fatalf("cannot place log: %v", err)
+	}
+	_, err = w.Write([]byte(before + section))
+	if err != nil {
+		t.Fatalf("cannot write log: %v", err)
+	}
+
+	readSection := func(s *store.FileLogStore, name, expectedPhase string) string {
+		rd, phase, err := s.ReadSection("foo", name)
+		if err != nil {
+			t.Fatalf("cannot read section: %v", err)
+		}
+		defer rd.Close()
+		if phase != expectedPhase {
+			t.Errorf("unexpected phase: expected %q, got %q", expectedPhase, phase)
+		}
+		act, err := ioutil.ReadAll(rd)
+		if err != nil {
+			t.Fatalf("cannot read section: %v", err)
+		}
+		return string(act)
+	}
+
+	// while the log is written we skip to the section and continue reading as the log grows
+	rd, phase, err := s.ReadSection("foo", "test")
+	if err != nil {
+		t.Fatalf("cannot read section: %v", err)
+	}
+	if phase != "build" {
+		t.Errorf("unexpected phase: %q", phase)
+	}
+	_, err = w.Write([]byte(after))
+	if err != nil {
+		t.Fatalf("cannot write log: %v", err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("cannot close log: %v", err)
+	}
+	act, err := ioutil.ReadAll(rd)
+	rd.Close()
+	if err != nil {
+		t.Fatalf("cannot read section: %v", err)
+	}
+	if string(act) != section+after {
+		t.Errorf("unexpected content while writing: %q", string(act))
+	}
+
+	// a fresh store, e.g. after a restart, uses the persisted index
+	restarted, err := store.NewFileLogStore(base)
+	if err != nil {
+		t.Fatalf("cannot create test store: %v", err)
+	}
+	if act := readSection(restarted, "test", "build"); act != section+after {
+		t.Errorf("unexpected content after restart: %q", act)
+	}
+	if act := readSection(restarted, "release", "default"); act != before+section+after {
+		t.Errorf("sections which haven't started must be read from the start, got %q", act)
+	}
+
+	// logs written before we indexed sections are indexed when they're read
+	err = os.Remove(filepath.Join(base, "foo.log.sections"))
+	if err != nil {
+		t.Fatalf("cannot remove index: %v", err)
+	}
+	restarted, err = store.NewFileLogStore(base)
+	if err != nil {
+		t.Fatalf("cannot create test store: %v", err)
+	}
+	if act := readSection(restarted, "test|unit", "test"); act != "[test|unit] ok\n"+after {
+		t.Errorf("unexpected content without index: %q", act)
+	}
+	if _, err := os.Stat(filepath.Join(base, "foo.log.sections")); err != nil {
+		t.Errorf("index should have been persisted: %v", err)
+	}
+
+	err = restarted.Delete("foo")
+	if err != nil {
+		t.Fatalf("cannot delete log: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "foo.log.sections")); !os.IsNotExist(err) {
+		t.Errorf("index should have been deleted with the log: %v", err)
+	}
+}
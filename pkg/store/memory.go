@@ -145,6 +145,10 @@ func (s *inMemoryLogStore) Read(id string) (io.ReadCloser, error) {
 
 // NewInMemoryJobStore creates a new in-memory job store
 func NewInMemoryJobStore() Jobs {
+	return newInMemoryJobStore()
+}
+
+func newInMemoryJobStore() *inMemoryJobStore {
 	return &inMemoryJobStore{
 		jobs:  make(map[string]v1.JobStatus),
 		specs: make(map[string][]byte),
@@ -183,7 +187,7 @@ func (s *inMemoryJobStore) Get(ctx context.Context, name string) (*v1.JobStatus,
 }
 
 // Searches for jobs based on their annotations
-func (s *inMemoryJobStore) Find(ctx context.Context, filter []*v1.FilterExpression, order []*v1.OrderExpression, start, limit int) (slice []v1.JobStatus, total int, err error) {
+func (s *inMemoryJobStore) Find(ctx context.Context, filter []*v1.FilterExpression, order []*v1.OrderExpression, group []string, start, limit int) (slice []v1.JobStatus, total int, err error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -194,7 +198,18 @@ func (s *inMemoryJobStore) Find(ctx context.Context, filter []*v1.FilterExpressi
 		}
 		res = append(res, js)
 	}
-	return res, len(res), nil
+	res = filterexpr.LatestPerGroup(res, group)
+	filterexpr.SortJobs(res, order)
+
+	total = len(res)
+	if start > len(res) {
+		start = len(res)
+	}
+	res = res[start:]
+	if limit > 0 && limit < len(res) {
+		res = res[:limit]
+	}
+	return res, total, nil
 }
 
 func (s *inMemoryJobStore) StoreJobSpec(name string, data []byte) error {
@@ -207,7 +222,7 @@ func (s *inMemoryJobStore) StoreJobSpec(name string, data []byte) error {
 
 func (s *inMemoryJobStore) GetJobSpec(name string) (data []byte, err error) {
 	s.mu.RLock()
-	s.mu.RUnlock()
+	defer s.mu.RUnlock()
 
 	data, ok := s.specs[name]
 	if !ok {
@@ -215,3 +230,59 @@ func (s *inMemoryJobStore) GetJobSpec(name string) (data []byte, err error) {
 	}
 	return data, nil
 }
+
+// Delete removes a job and its job spec from the store
+func (s *inMemoryJobStore) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[name]; !ok {
+		return ErrNotFound
+	}
+	delete(s.jobs, name)
+	delete(s.specs, name)
+	return nil
+}
+
+// NewInMemoryNumberGroup creates number groups which are kept in memory only
+func NewInMemoryNumberGroup() NumberGroup {
+	return &inMemoryNumberGroup{
+		groups: make(map[string]int),
+	}
+}
+
+type inMemoryNumberGroup struct {
+	groups map[string]int
+	mu     sync.Mutex
+}
+
+// Latest returns the latest number of a particular number group.
+func (g *inMemoryNumberGroup) Latest(group string) (nr int, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	nr, ok := g.groups[group]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return nr, nil
+}
+
+// Next returns the next number in the group, starting at 0.
+func (g *inMemoryNumberGroup) Next(group string) (nr int, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	nr = nextNumber(g.groups, group)
+	g.groups[group] = nr
+	return nr, nil
+}
+
+// nextNumber returns the number following the latest one of a group, or 0 if the group does not exist
+func nextNumber(groups map[string]int, group string) int {
+	nr, ok := groups[group]
+	if !ok {
+		return 0
+	}
+	return nr + 1
+}
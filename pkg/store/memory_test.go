@@ -0,0 +1,164 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/filterexpr"
+	"github.com/csweichel/werft/pkg/store"
+	"github.com/csweichel/werft/pkg/store/storetest"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+func TestInMemoryJobStore(t *testing.T) {
+	storetest.TestJobs(t, func(t *testing.T) store.Jobs {
+		return store.NewInMemoryJobStore()
+	})
+}
+
+func TestInMemoryNumberGroup(t *testing.T) {
+	storetest.TestNumberGroup(t, func(t *testing.T) store.NumberGroup {
+		return store.NewInMemoryNumberGroup()
+	})
+}
+
+func TestInMemoryJobStoreFindPhase(t *testing.T) {
+	jobs := store.NewInMemoryJobStore()
+	for _, js := range []v1.JobStatus{
+		{Name: "running-1", Phase: v1.JobPhase_PHASE_RUNNING, Metadata: &v1.JobMetadata{}},
+		{Name: "running-2", Phase: v1.JobPhase_PHASE_RUNNING, Metadata: &v1.JobMetadata{}},
+		{Name: "done-1", Phase: v1.JobPhase_PHASE_DONE, Metadata: &v1.JobMetadata{}},
+		{Name: "waiting-1", Phase: v1.JobPhase_PHASE_WAITING, Metadata: &v1.JobMetadata{}},
+	} {
+		err := jobs.Store(context.Background(), js)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		Filter   string
+		Expected []string
+	}{
+		{"phase==running", []string{"running-1", "running-2"}},
+		{"phase==PHASE_RUNNING", []string{"running-1", "running-2"}},
+		{"phase==done", []string{"done-1"}},
+		{"phase!=running", []string{"done-1", "waiting-1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.Filter, func(t *testing.T) {
+			filter, err := filterexpr.ParseExpressions([]string{test.Filter})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, _, err := jobs.Find(context.Background(), filter, nil, nil, 0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, js := range res {
+				names = append(names, js.Name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, test.Expected) {
+				t.Errorf("expected %v but got %v", test.Expected, names)
+			}
+		})
+	}
+}
+
+func TestInMemoryJobStoreFindLatestPerGroup(t *testing.T) {
+	jobs := store.NewInMemoryJobStore()
+	for _, js := range []v1.JobStatus{
+		{Name: "werft-main.1", Phase: v1.JobPhase_PHASE_DONE, Metadata: &v1.JobMetadata{Created: &timestamp.Timestamp{Seconds: 10}, Repository: &v1.Repository{Repo: "werft", Ref: "main"}}},
+		{Name: "werft-main.2", Phase: v1.JobPhase_PHASE_DONE, Metadata: &v1.JobMetadata{Created: &timestamp.Timestamp{Seconds: 20}, Repository: &v1.Repository{Repo: "werft", Ref: "main"}}},
+		{Name: "werft-main.3", Phase: v1.JobPhase_PHASE_RUNNING, Metadata: &v1.JobMetadata{Created: &timestamp.Timestamp{Seconds: 30}, Repository: &v1.Repository{Repo: "werft", Ref: "main"}}},
+		{Name: "werft-dev.1", Phase: v1.JobPhase_PHASE_DONE, Metadata: &v1.JobMetadata{Created: &timestamp.Timestamp{Seconds: 25}, Repository: &v1.Repository{Repo: "werft", Ref: "dev"}}},
+		{Name: "other-main.1", Phase: v1.JobPhase_PHASE_DONE, Metadata: &v1.JobMetadata{Created: &timestamp.Timestamp{Seconds: 5}, Repository: &v1.Repository{Repo: "other", Ref: "main"}}},
+	} {
+		err := jobs.Store(context.Background(), js)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		Filter   []string
+		Expected []string
+	}{
+		{nil, []string{"werft-main.3", "werft-dev.1", "other-main.1"}},
+		{[]string{"phase==done"}, []string{"werft-dev.1", "werft-main.2", "other-main.1"}},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.Filter, " "), func(t *testing.T) {
+			filter, err := filterexpr.ParseExpressions(test.Filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			order := []*v1.OrderExpression{{Field: "created", Ascending: false}}
+			res, total, err := jobs.Find(context.Background(), filter, order, []string{"repo.repo", "repo.ref"}, 0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, js := range res {
+				names = append(names, js.Name)
+			}
+			if !reflect.DeepEqual(names, test.Expected) {
+				t.Errorf("expected %v but got %v", test.Expected, names)
+			}
+			if total != len(test.Expected) {
+				t.Errorf("expected total %d but got %d", len(test.Expected), total)
+			}
+		})
+	}
+}
+
+func TestInMemoryJobStoreFindPage(t *testing.T) {
+	jobs := store.NewInMemoryJobStore()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		err := jobs.Store(context.Background(), v1.JobStatus{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		Start    int
+		Limit    int
+		Expected []string
+	}{
+		{0, 0, []string{"a", "b", "c", "d", "e"}},
+		{0, 2, []string{"a", "b"}},
+		{2, 2, []string{"c", "d"}},
+		{4, 2, []string{"e"}},
+		{10, 2, nil},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%d-%d", test.Start, test.Limit), func(t *testing.T) {
+			order := []*v1.OrderExpression{{Field: "name", Ascending: true}}
+			res, total, err := jobs.Find(context.Background(), nil, order, nil, test.Start, test.Limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, js := range res {
+				names = append(names, js.Name)
+			}
+			if !reflect.DeepEqual(names, test.Expected) {
+				t.Errorf("expected %v but got %v", test.Expected, names)
+			}
+			if total != 5 {
+				t.Errorf("expected total 5 but got %d", total)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/csweichel/werft/pkg/audit"
+)
+
+// AuditLog stores audit entries in postgres
+type AuditLog struct {
+	DB *sql.DB
+}
+
+// NewAuditLog creates a new SQL audit log
+func NewAuditLog(db *sql.DB) (*AuditLog, error) {
+	return &AuditLog{DB: db}, nil
+}
+
+// Log stores the entry
+func (a *AuditLog) Log(ctx context.Context, entry audit.Entry) error {
+	var success int
+	if entry.Success {
+		success = 1
+	}
+	_, err := a.DB.ExecContext(ctx, `
+		INSERT
+		INTO   audit_log (time, actor, action, job, previous_job, repo, owner, trigger_src, success, error)
+		VALUES           ($1  , $2   , $3    , $4 , $5          , $6  , $7   , $8         , $9     , $10  )`,
+		entry.Time,
+		entry.Actor,
+		string(entry.Action),
+		nullString(entry.Job),
+		nullString(entry.PreviousJob),
+		nullString(entry.Repo),
+		nullString(entry.Owner),
+		nullString(entry.Trigger),
+		success,
+		nullString(entry.Error),
+	)
+	return err
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
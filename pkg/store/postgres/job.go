@@ -8,8 +8,10 @@ import (
 	"time"
 
 	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/filterexpr"
 	"github.com/csweichel/werft/pkg/store"
 	"github.com/golang/protobuf/jsonpb"
+	"github.com/lib/pq"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/xerrors"
@@ -84,22 +86,23 @@ func (s *JobStore) Store(ctx context.Context, job v1.JobStatus) error {
 	var jobID int
 	err = tx.QueryRow(`
 		INSERT
-		INTO   job_status (name, data, owner, phase, repo_owner, repo_repo, repo_host, repo_ref, trigger_src, success, created)
-		VALUES            ($1  , $2  , $3   , $4   , $5        , $6       , $7       , $8      , $9         , $10,     $11    ) 
+		INTO   job_status (name, data, owner, phase, repo, repo.owner, repo_repo, repo_host, repo_ref, trigger_src, success, created, repo_revision)
+		VALUES            ($1  , $2  , $3   , $4   , $5        , $6       , $7       , $8      , $9         , $10,     $11    , $12          ) 
 		ON CONFLICT (name) DO UPDATE 
-			SET data = $2, owner = $3, phase = $4, repo_owner = $5, repo_repo = $6, repo_host = $7, repo_ref = $8, trigger_src = $9, success = $10, created = $11
+			SET data = $2, owner = $3, phase = $4, repo_owner = $5, repo_repo = $6, repo_host = $7, repo_ref = $8, trigger_src = $9, success = $10, created = $11, repo_revision = $12
 		RETURNING id`,
 		job.Name,
 		serializedJob,
 		job.Metadata.Owner,
-		strings.ToLower(strings.TrimPrefix(job.Phase.String(), "PHASE_")),
+		filterexpr.NormalizePhase(job.Phase.String()),
 		job.Metadata.Repository.Owner,
 		job.Metadata.Repository.Repo,
 		job.Metadata.Repository.Host,
 		job.Metadata.Repository.Ref,
-		strings.ToLower(strings.TrimPrefix("TRIGGER_", job.Metadata.Trigger.String())),
+		strings.ToLower(strings.TrimPrefix(job.Metadata.Trigger.String(), "TRIGGER_")),
 		success,
 		job.Metadata.Created.Seconds,
+		job.Metadata.Repository.Revision,
 	).Scan(&jobID)
 	if err != nil {
 		tx.Rollback()
@@ -148,18 +151,28 @@ func (s *JobStore) Get(ctx context.Context, name string) (*v1.JobStatus, error)
 }
 
 // Find searches for jobs based on their annotations. If filter is empty no filter is applied.
-func (s *JobStore) Find(ctx context.Context, filter []*v1.FilterExpression, order []*v1.OrderExpression, start, limit int) (slice []v1.JobStatus, total int, err error) {
+func (s *JobStore) Find(ctx context.Context, filter []*v1.FilterExpression, order []*v1.OrderExpression, group []string, start, limit int) (slice []v1.JobStatus, total int, err error) {
 	fieldMap := map[string]string{
-		"name":       "name",
-		"owner":      "owner",
-		"phase":      "phase",
-		"repo.owner": "repo_owner",
-		"repo.repo":  "repo_repo",
-		"repo.host":  "repo_host",
-		"repo.ref":   "repo_ref",
-		"trigger":    "trigger",
-		"success":    "success",
-		"created":    "created",
+		"name":          "name",
+		"owner":         "owner",
+		"phase":         "phase",
+		"repo.owner":    "repo_owner",
+		"repo.repo":     "repo_repo",
+		"repo.host":     "repo_host",
+		"repo.ref":      "repo_ref",
+		"repo.revision": "repo_revision",
+		"repo.rev":      "repo_revision",
+		"trigger":       "trigger_src",
+		"success":       "success",
+		"created":       "created",
+		// repo is host/owner/repo like in auth policies, or empty for jobs without a repository
+		"repo": "CASE WHEN data::jsonb->'metadata'->'repository' IS NULL THEN '' ELSE concat(repo_host, '/', repo_owner, '/', repo_repo) END",
+		// started and completed live in the job's data only, where they're empty until the job started or completed
+		"started":   "EXTRACT(EPOCH FROM (data::jsonb->'metadata'->>'started')::timestamptz)",
+		"completed": "EXTRACT(EPOCH FROM (data::jsonb->'metadata'->>'finished')::timestamptz)",
+		// duration is computed from the started (or created, if the job hasn't started) and finished time, or now for
+		// jobs which haven't finished yet
+		"duration": "(COALESCE(EXTRACT(EPOCH FROM (data::jsonb->'metadata'->>'finished')::timestamptz), EXTRACT(EPOCH FROM now())) - COALESCE(EXTRACT(EPOCH FROM (data::jsonb->'metadata'->>'started')::timestamptz), created))",
 	}
 
 	var (
@@ -178,29 +191,117 @@ func (s *JobStore) Find(ctx context.Context, filter []*v1.FilterExpression, orde
 				not = "NOT"
 			}
 
-			field, ok := fieldMap[t.Field]
-			if !ok {
-				return nil, 0, xerrors.Errorf("unknown field %s", t.Field)
-			}
-
-			var op string
+			var (
+				op   string
+				like bool
+			)
 			switch t.Operation {
 			case v1.FilterOp_OP_CONTAINS:
-				op = "LIKE '%' || ? || '%'"
+				op, like = "LIKE '%' || ? || '%'", true
 			case v1.FilterOp_OP_ENDS_WITH:
-				op = "LIKE '%' || ?"
+				op, like = "LIKE '%' || ?", true
 			case v1.FilterOp_OP_EQUALS:
 				op = "= ?"
 			case v1.FilterOp_OP_STARTS_WITH:
-				op = "LIKE ? || '%'"
+				op, like = "LIKE ? || '%'", true
 			case v1.FilterOp_OP_EXISTS:
 				op = "IS NOT NULL"
+			case v1.FilterOp_OP_MATCHES:
+				op = "~ ?"
+			case v1.FilterOp_OP_GREATER:
+				op = "> ?"
+			case v1.FilterOp_OP_LESS:
+				op = "< ?"
 			default:
 				return nil, 0, xerrors.Errorf("unknown operation %v", t.Operation)
 			}
+
+			if strings.HasPrefix(t.Field, "annotation.") {
+				// Annotations live in their own table. A job without the annotation never matches,
+				// regardless of negation - unless we're checking for the annotation's existence.
+				key := strings.TrimPrefix(t.Field, "annotation.")
+				subq := "SELECT 1 FROM annotations WHERE annotations.job_id = job_status.id AND annotations.name = ?"
+				if t.Operation == v1.FilterOp_OP_EXISTS {
+					terms = append(terms, fmt.Sprintf("%s EXISTS (%s)", not, subq))
+					args = append(args, key)
+				} else {
+					col, val := "annotations.value", t.Value
+					if t.IgnoreCase {
+						col, val, op = ignoreCase(col, val, op)
+					}
+					if like {
+						val = escapeLike(val)
+					}
+					terms = append(terms, fmt.Sprintf("EXISTS (%s AND %s %s %s)", subq, not, col, op))
+					args = append(args, key, val)
+				}
+				continue
+			}
+
+			if strings.HasPrefix(t.Field, "result.") {
+				// Results are part of the job's data. A job matches if any of its results of that type matches.
+				tpe := strings.TrimPrefix(t.Field, "result.")
+				subq := "SELECT 1 FROM jsonb_array_elements(COALESCE(job_status.data::jsonb->'results', '[]'::jsonb)) AS results WHERE results->>'type' = ?"
+				if t.Operation == v1.FilterOp_OP_EXISTS {
+					terms = append(terms, fmt.Sprintf("%s EXISTS (%s)", not, subq))
+					args = append(args, tpe)
+				} else {
+					col, val := "results->>'payload'", t.Value
+					if t.IgnoreCase {
+						col, val, op = ignoreCase(col, val, op)
+					}
+					if like {
+						val = escapeLike(val)
+					}
+					terms = append(terms, fmt.Sprintf("EXISTS (%s AND %s %s %s)", subq, not, col, op))
+					args = append(args, tpe, val)
+				}
+				continue
+			}
+
+			field, ok := fieldMap[t.Field]
+			if !ok {
+				return nil, 0, xerrors.Errorf("unknown field %s", t.Field)
+			}
+
+			var val interface{} = t.Value
+			if t.Field == "duration" {
+				dur, err := time.ParseDuration(t.Value)
+				if err != nil {
+					return nil, 0, xerrors.Errorf("invalid duration: %s", t.Value)
+				}
+				val = dur.Seconds()
+			}
+			if t.Field == "phase" && t.Operation != v1.FilterOp_OP_MATCHES {
+				// phases are stored in their canonical form
+				val = filterexpr.NormalizePhase(t.Value)
+			}
+			if filterexpr.IsTimeField(t.Field) && filterexpr.IsTimeComparison(t.Operation) {
+				// times are compared as seconds since epoch
+				ts, err := time.Parse(time.RFC3339, t.Value)
+				if err != nil {
+					return nil, 0, xerrors.Errorf("invalid time for %s: %s", t.Field, t.Value)
+				}
+				val = ts.Unix()
+			} else if filterexpr.IsTimeField(t.Field) && t.Operation != v1.FilterOp_OP_EXISTS {
+				// all other operations match the time as RFC3339 text, just like the in-memory store does
+				field = fmt.Sprintf(`to_char(to_timestamp(%s) AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')`, field)
+			}
+
+			if t.IgnoreCase && filterexpr.IsTextField(t.Field) && t.Operation != v1.FilterOp_OP_EXISTS {
+				var v string
+				field, v, op = ignoreCase(field, t.Value, op)
+				val = v
+			}
+			if v, ok := val.(string); ok && like {
+				val = escapeLike(v)
+			}
+
 			expr := fmt.Sprintf("%s %s %s", not, field, op)
 			terms = append(terms, expr)
-			args = append(args, t.Value)
+			if strings.Contains(op, "?") {
+				args = append(args, val)
+			}
 		}
 
 		expr := fmt.Sprintf("(%s)", strings.Join(terms, " OR "))
@@ -231,22 +332,47 @@ func (s *JobStore) Find(ctx context.Context, filter []*v1.FilterExpression, orde
 	}
 	var orderExp string
 	if len(orderExps) > 0 {
+		// break ties using the name so that the order is deterministic, e.g. when paginating
+		orderExps = append(orderExps, "name ASC")
 		orderExp = fmt.Sprintf("ORDER BY %s", strings.Join(orderExps, ", "))
 	}
 
+	from := "job_status"
+	if len(group) > 0 {
+		// DISTINCT ON keeps the first row of each group, hence we order the rows within a group newest first.
+		// The result is aliased as job_status so that the order expressions apply to it unchanged.
+		var groupExps []string
+		for _, g := range group {
+			if strings.HasPrefix(g, "annotation.") {
+				key := strings.TrimPrefix(g, "annotation.")
+				groupExps = append(groupExps, fmt.Sprintf("(SELECT value FROM annotations WHERE annotations.job_id = job_status.id AND annotations.name = %s)", pq.QuoteLiteral(key)))
+				continue
+			}
+
+			field, ok := fieldMap[g]
+			if !ok || filterexpr.IsTimeField(g) || g == "duration" {
+				return nil, 0, xerrors.Errorf("cannot group by %s", g)
+			}
+			groupExps = append(groupExps, field)
+		}
+		groupExp := strings.Join(groupExps, ", ")
+		from = fmt.Sprintf("(SELECT DISTINCT ON (%s) * FROM job_status %s ORDER BY %s, created DESC NULLS LAST, name DESC) AS job_status", groupExp, whereExp, groupExp)
+		whereExp = ""
+	}
+
 	limitExp := "ALL"
 	if limit > 0 {
 		limitExp = fmt.Sprintf("%d", limit)
 	}
 
-	countQuery := fmt.Sprintf("SELECT COUNT(1) FROM job_status %s", whereExp)
+	countQuery := fmt.Sprintf("SELECT COUNT(1) FROM %s %s", from, whereExp)
 	log.WithField("query", countQuery).Debug("running query")
 	err = s.DB.QueryRow(countQuery, args...).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	query := fmt.Sprintf("SELECT data FROM job_status %s %s LIMIT %s OFFSET %d", whereExp, orderExp, limitExp, start)
+	query := fmt.Sprintf("SELECT data FROM %s %s %s LIMIT %s OFFSET %d", from, whereExp, orderExp, limitExp, start)
 	log.WithField("query", query).Debug("running query")
 	rows, err := s.DB.Query(query, args...)
 	if err != nil {
@@ -277,6 +403,21 @@ func (s *JobStore) Find(ctx context.Context, filter []*v1.FilterExpression, orde
 	return result, total, nil
 }
 
+// ignoreCase turns a comparison of col against val using op into a case-insensitive one
+func ignoreCase(col, val, op string) (string, string, string) {
+	if op == "~ ?" {
+		return col, val, "~* ?"
+	}
+	return fmt.Sprintf("LOWER(%s)", col), strings.ToLower(val), op
+}
+
+// escapeLike escapes the LIKE wildcards in val so that they match literally, like they do in the in-memory filter
+func escapeLike(val string) string {
+	return likeEscaper.Replace(val)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 // StoreJobSpec stores job information in the store.
 func (s *JobStore) StoreJobSpec(name string, data []byte) error {
 	rows, err := s.DB.Query(`
@@ -310,3 +451,39 @@ func (s *JobStore) GetJobSpec(name string) ([]byte, error) {
 
 	return data, nil
 }
+
+// Delete removes a job, its annotations and job spec from the store.
+func (s *JobStore) Delete(ctx context.Context, name string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var id int
+	err = tx.QueryRow("SELECT id FROM job_status WHERE name = $1", name).Scan(&id)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return store.ErrNotFound
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, stmt := range []struct {
+		Query string
+		Arg   interface{}
+	}{
+		{"DELETE FROM annotations WHERE job_id = $1", id},
+		{"DELETE FROM job_status WHERE id = $1", id},
+		{"DELETE FROM job_spec WHERE name = $1", name},
+	} {
+		_, err = tx.Exec(stmt.Query, stmt.Arg)
+		if err != nil {
+			tx.Rollback()
+			return xerrors.Errorf("cannot delete job %s: %w", name, err)
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,620 @@
+//go:build integration
+// +build integration
+
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/audit"
+	"github.com/csweichel/werft/pkg/filterexpr"
+	"github.com/csweichel/werft/pkg/store"
+	"github.com/csweichel/werft/pkg/store/postgres"
+	"github.com/csweichel/werft/pkg/store/storetest"
+	"github.com/golang/protobuf/proto"
+	_ "github.com/lib/pq"
+)
+
+// These tests run against a real Postgres database. Set WERFT_TEST_POSTGRES to the connection string of an empty
+// database, or have docker available in which case we start a throw-away Postgres container:
+//
+//   go test -tags integration ./pkg/store/postgres/
+
+var db *sql.DB
+
+func TestMain(m *testing.M) {
+	dsn := os.Getenv("WERFT_TEST_POSTGRES")
+	var container string
+	if dsn == "" {
+		var err error
+		container, dsn, err = startPostgres()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot start postgres: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	code, err := run(m, dsn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		code = 1
+	}
+	if container != "" {
+		exec.Command("docker", "stop", container).Run()
+	}
+	os.Exit(code)
+}
+
+func run(m *testing.M, dsn string) (int, error) {
+	var err error
+	db, err = sql.Open("postgres", dsn)
+	if err != nil {
+		return 0, fmt.Errorf("cannot connect to postgres: %w", err)
+	}
+	defer db.Close()
+
+	// the container takes a moment to accept connections
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		err = db.Ping()
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return 0, fmt.Errorf("postgres did not become ready: %w", err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	err = postgres.Migrate(db)
+	if err != nil {
+		return 0, fmt.Errorf("cannot migrate database: %w", err)
+	}
+
+	return m.Run(), nil
+}
+
+func startPostgres() (container, dsn string, err error) {
+	out, err := exec.Command("docker", "run", "--rm", "-d", "-e", "POSTGRES_PASSWORD=werft", "-p", "127.0.0.1::5432", "postgres:12-alpine").Output()
+	if err != nil {
+		return "", "", fmt.Errorf("cannot run postgres container: %w", err)
+	}
+	container = strings.TrimSpace(string(out))
+
+	out, err = exec.Command("docker", "port", container, "5432/tcp").Output()
+	if err != nil {
+		exec.Command("docker", "stop", container).Run()
+		return "", "", fmt.Errorf("cannot get postgres port: %w", err)
+	}
+	// docker port might list several addresses, one per line
+	addr := strings.SplitN(strings.TrimSpace(string(out)), "\n", 2)[0]
+	port := addr[strings.LastIndex(addr, ":")+1:]
+
+	dsn = fmt.Sprintf("host=127.0.0.1 port=%s user=postgres password=werft dbname=postgres sslmode=disable", port)
+	return container, dsn, nil
+}
+
+// newJobStore returns a job store on an empty database
+func newJobStore(t *testing.T) *postgres.JobStore {
+	_, err := db.Exec("TRUNCATE job_status, annotations, job_spec, number_group, audit_log")
+	if err != nil {
+		t.Fatalf("cannot clear database: %v", err)
+	}
+
+	jobs, err := postgres.NewJobStore(db)
+	if err != nil {
+		t.Fatalf("cannot create job store: %v", err)
+	}
+	return jobs
+}
+
+func TestJobStoreSuite(t *testing.T) {
+	storetest.TestJobs(t, func(t *testing.T) store.Jobs {
+		return newJobStore(t)
+	})
+}
+
+func TestNumberGroupSuite(t *testing.T) {
+	storetest.TestNumberGroup(t, func(t *testing.T) store.NumberGroup {
+		newJobStore(t)
+		ngrp, err := postgres.NewNumberGroup(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ngrp
+	})
+}
+
+func TestStoreGet(t *testing.T) {
+	jobs := newJobStore(t)
+	ctx := context.Background()
+
+	_, err := jobs.Get(ctx, "unknown")
+	if err != store.ErrNotFound {
+		t.Errorf("expected ErrNotFound for unknown job, got %v", err)
+	}
+
+	job := storetest.Job{Name: "werft-build.1", Phase: v1.JobPhase_PHASE_RUNNING, Owner: "foo", Repo: "werft", Ref: "main", Created: 1000, Annotations: map[string]string{"version": "1"}}.Status()
+	err = jobs.Store(ctx, job)
+	if err != nil {
+		t.Fatalf("cannot store job: %v", err)
+	}
+	act, err := jobs.Get(ctx, job.Name)
+	if err != nil {
+		t.Fatalf("cannot get job: %v", err)
+	}
+	if !proto.Equal(act, &job) {
+		t.Errorf("unexpected job: %v, expected %v", act, &job)
+	}
+
+	// storing the job again updates it, including its annotations and indexed fields
+	job.Phase = v1.JobPhase_PHASE_DONE
+	job.Conditions.Success = true
+	job.Metadata.Annotations[0].Value = "2"
+	err = jobs.Store(ctx, job)
+	if err != nil {
+		t.Fatalf("cannot update job: %v", err)
+	}
+	act, err = jobs.Get(ctx, job.Name)
+	if err != nil {
+		t.Fatalf("cannot get job: %v", err)
+	}
+	if !proto.Equal(act, &job) {
+		t.Errorf("unexpected job: %v, expected %v", act, &job)
+	}
+
+	filter, err := filterexpr.ParseExpressions([]string{"phase==done", "success==true", "annotation.version==2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, total, err := jobs.Find(ctx, filter, nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot find job: %v", err)
+	}
+	if total != 1 {
+		t.Errorf("expected to find the updated job, found %d", total)
+	}
+}
+
+func TestFind(t *testing.T) {
+	jobs := newJobStore(t)
+	for _, j := range []storetest.Job{
+		{Name: "werft-build.1", Phase: v1.JobPhase_PHASE_DONE, Owner: "foo", Repo: "werft", Ref: "main", Success: true, Created: 1000, Started: 1100, Finished: 1500, Annotations: map[string]string{"version": "1"}},
+		{Name: "werft-build.2", Phase: v1.JobPhase_PHASE_DONE, Owner: "Bar", Repo: "werft", Ref: "feature", Success: false, Created: 2000, Started: 2050, Finished: 2500, Results: []*v1.JobResult{{Type: "url", Payload: "https://preview.example.com"}, {Type: "tests", Payload: "41 passed, 1 failed"}}},
+		{Name: "werft-build.3", Phase: v1.JobPhase_PHASE_RUNNING, Owner: "foo", Repo: "werft", Ref: "main", Created: 3000, Started: 3100, Annotations: map[string]string{"version": "2"}},
+		{Name: "leeway-build.1", Phase: v1.JobPhase_PHASE_WAITING, Owner: "bar", Repo: "leeway", Ref: "main", Created: 4000},
+	} {
+		err := jobs.Store(context.Background(), j.Status())
+		if err != nil {
+			t.Fatalf("cannot store job: %v", err)
+		}
+	}
+
+	tests := []struct {
+		Name     string
+		Filter   []string
+		Terms    []*v1.FilterTerm
+		Order    []*v1.OrderExpression
+		Group    []string
+		Start    int
+		Limit    int
+		Expected []string
+		Total    int
+	}{
+		{
+			Name:     "no filter",
+			Order:    []*v1.OrderExpression{{Field: "name", Ascending: true}},
+			Expected: []string{"leeway-build.1", "werft-build.1", "werft-build.2", "werft-build.3"},
+			Total:    4,
+		},
+		{
+			Name:     "phase",
+			Filter:   []string{"phase==done"},
+			Order:    []*v1.OrderExpression{{Field: "name", Ascending: true}},
+			Expected: []string{"werft-build.1", "werft-build.2"},
+			Total:    2,
+		},
+		{
+			Name:     "negated phase",
+			Filter:   []string{"phase!=done"},
+			Order:    []*v1.OrderExpression{{Field: "name", Ascending: true}},
+			Expected: []string{"leeway-build.1", "werft-build.3"},
+			Total:    2,
+		},
+		{
+			Name:     "or",
+			Filter:   []string{"phase==running,phase==waiting"},
+			Order:    []*v1.OrderExpression{{Field: "name", Ascending: true}},
+			Expected: []string{"leeway-build.1", "werft-build.3"},
+			Total:    2,
+		},
+		{
+			Name:     "and",
+			Filter:   []string{"owner==foo", "repo.ref==main", "repo.repo==werft"},
+			Order:    []*v1.OrderExpression{{Field: "name", Ascending: true}},
+			Expected: []string{"werft-build.1", "werft-build.3"},
+			Total:    2,
+		},
+		{
+			Name:     "starts with",
+			Filter:   []string{"name|=leeway"},
+			Expected: []string{"leeway-build.1"},
+			Total:    1,
+		},
+		{
+			Name:     "ends with",
+			Filter:   []string{"name=|.2"},
+			Expected: []string{"werft-build.2"},
+			Total:    1,
+		},
+		{
+			Name:     "matches",
+			Filter:   []string{"name=~^werft-build\\.[13]$"},
+			Order:    []*v1.OrderExpression{{Field: "name", Ascending: true}},
+			Expected: []string{"werft-build.1", "werft-build.3"},
+			Total:    2,
+		},
+		{
+			Name:     "success",
+			Filter:   []string{"success==true"},
+			Expected: []string{"werft-build.1"},
+			Total:    1,
+		},
+		{
+			Name:     "created",
+			Filter:   []string{"created>1970-01-01T00:40:00Z"},
+			Order:    []*v1.OrderExpression{{Field: "name", Ascending: true}},
+			Expected: []string{"leeway-build.1", "werft-build.3"},
+			Total:    2,
+		},
+		{
+			Name:     "started",
+			Filter:   []string{"started>1970-01-01T00:30:00Z"},
+			Order:    []*v1.OrderExpression{{Field: "name", Ascending: true}},
+			Expected: []string{"werft-build.2", "werft-build.3"},
+			Total:    2,
+		},
+		{
+			Name:     "completed",
+			Filter:   []string{"completed<1970-01-01T00:30:00Z"},
+			Expected: []string{"werft-build.1"},
+			Total:    1,
+		},
+		{
+			Name:     "order by completed",
+			Order:    []*v1.OrderExpression{{Field: "completed", Ascending: true}},
+			Expected: []string{"werft-build.1", "werft-build.2", "leeway-build.1", "werft-build.3"},
+			Total:    4,
+		},
+		{
+			Name:     "annotation equals",
+			Filter:   []string{"annotation.version==2"},
+			Expected: []string{"werft-build.3"},
+			Total:    1,
+		},
+		{
+			Name:     "annotation exists",
+			Filter:   []string{"annotation.version"},
+			Order:    []*v1.OrderExpression{{Field: "name", Ascending: true}},
+			Expected: []string{"werft-build.1", "werft-build.3"},
+			Total:    2,
+		},
+		{
+			Name:     "annotation does not exist",
+			Filter:   []string{"!annotation.version"},
+			Order:    []*v1.OrderExpression{{Field: "name", Ascending: true}},
+			Expected: []string{"leeway-build.1", "werft-build.2"},
+			Total:    2,
+		},
+		{
+			Name:     "negated annotation does not match jobs without the annotation",
+			Filter:   []string{"annotation.version!=2"},
+			Expected: []string{"werft-build.1"},
+			Total:    1,
+		},
+		{
+			Name:     "result",
+			Filter:   []string{"result.tests~=failed"},
+			Expected: []string{"werft-build.2"},
+			Total:    1,
+		},
+		{
+			Name:     "result exists",
+			Terms:    []*v1.FilterTerm{{Field: "result.url", Operation: v1.FilterOp_OP_EXISTS}},
+			Expected: []string{"werft-build.2"},
+			Total:    1,
+		},
+		{
+			Name:     "negated result does not match jobs without the result",
+			Filter:   []string{"result.url!=https://docs.example.com"},
+			Expected: []string{"werft-build.2"},
+			Total:    1,
+		},
+		{
+			Name:     "ignore case",
+			Terms:    []*v1.FilterTerm{{Field: "owner", Value: "BAR", Operation: v1.FilterOp_OP_EQUALS, IgnoreCase: true}},
+			Order:    []*v1.OrderExpression{{Field: "name", Ascending: true}},
+			Expected: []string{"leeway-build.1", "werft-build.2"},
+			Total:    2,
+		},
+		{
+			Name:     "order by created",
+			Order:    []*v1.OrderExpression{{Field: "created", Ascending: false}},
+			Expected: []string{"leeway-build.1", "werft-build.3", "werft-build.2", "werft-build.1"},
+			Total:    4,
+		},
+		{
+			Name:     "pagination",
+			Order:    []*v1.OrderExpression{{Field: "created", Ascending: true}},
+			Start:    1,
+			Limit:    2,
+			Expected: []string{"werft-build.2", "werft-build.3"},
+			Total:    4,
+		},
+		{
+			Name:     "latest per repo and ref",
+			Order:    []*v1.OrderExpression{{Field: "created", Ascending: false}},
+			Group:    []string{"repo.repo", "repo.ref"},
+			Expected: []string{"leeway-build.1", "werft-build.3", "werft-build.2"},
+			Total:    3,
+		},
+		{
+			Name:     "latest per ref after filtering",
+			Filter:   []string{"repo.repo==werft", "phase==done"},
+			Order:    []*v1.OrderExpression{{Field: "created", Ascending: false}},
+			Group:    []string{"repo.ref"},
+			Expected: []string{"werft-build.2", "werft-build.1"},
+			Total:    2,
+		},
+		{
+			Name:     "latest per annotation",
+			Order:    []*v1.OrderExpression{{Field: "name", Ascending: true}},
+			Group:    []string{"annotation.version"},
+			Expected: []string{"leeway-build.1", "werft-build.1", "werft-build.3"},
+			Total:    3,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			filter, err := filterexpr.ParseExpressions(test.Filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(test.Terms) > 0 {
+				filter = append(filter, &v1.FilterExpression{Terms: test.Terms})
+			}
+
+			res, total, err := jobs.Find(context.Background(), filter, test.Order, test.Group, test.Start, test.Limit)
+			if err != nil {
+				t.Fatalf("cannot find jobs: %v", err)
+			}
+
+			var names []string
+			for _, js := range res {
+				names = append(names, js.Name)
+			}
+			if !reflect.DeepEqual(names, test.Expected) {
+				t.Errorf("expected %v but got %v", test.Expected, names)
+			}
+			if total != test.Total {
+				t.Errorf("expected total of %d but got %d", test.Total, total)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	jobs := newJobStore(t)
+	ctx := context.Background()
+
+	job := storetest.Job{Name: "werft-build.1", Phase: v1.JobPhase_PHASE_DONE, Created: 1000, Annotations: map[string]string{"version": "1"}}.Status()
+	err := jobs.Store(ctx, job)
+	if err != nil {
+		t.Fatalf("cannot store job: %v", err)
+	}
+	err = jobs.StoreJobSpec(job.Name, []byte("pod: {}"))
+	if err != nil {
+		t.Fatalf("cannot store job spec: %v", err)
+	}
+
+	err = jobs.Delete(ctx, job.Name)
+	if err != nil {
+		t.Fatalf("cannot delete job: %v", err)
+	}
+	_, err = jobs.Get(ctx, job.Name)
+	if err != store.ErrNotFound {
+		t.Errorf("expected ErrNotFound for deleted job, got %v", err)
+	}
+	_, err = jobs.GetJobSpec(job.Name)
+	if err != store.ErrNotFound {
+		t.Errorf("expected ErrNotFound for deleted job spec, got %v", err)
+	}
+	var annotations int
+	err = db.QueryRow("SELECT COUNT(1) FROM annotations").Scan(&annotations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if annotations != 0 {
+		t.Errorf("expected annotations to be deleted, found %d", annotations)
+	}
+
+	err = jobs.Delete(ctx, job.Name)
+	if err != store.ErrNotFound {
+		t.Errorf("expected ErrNotFound when deleting an unknown job, got %v", err)
+	}
+}
+
+func TestJobSpec(t *testing.T) {
+	jobs := newJobStore(t)
+
+	_, err := jobs.GetJobSpec("unknown")
+	if err != store.ErrNotFound {
+		t.Errorf("expected ErrNotFound for unknown job spec, got %v", err)
+	}
+
+	for _, spec := range []string{"pod: {}", "pod: {containers: []}"} {
+		err = jobs.StoreJobSpec("werft-build.1", []byte(spec))
+		if err != nil {
+			t.Fatalf("cannot store job spec: %v", err)
+		}
+		act, err := jobs.GetJobSpec("werft-build.1")
+		if err != nil {
+			t.Fatalf("cannot get job spec: %v", err)
+		}
+		if string(act) != spec {
+			t.Errorf("unexpected job spec: %q, expected %q", string(act), spec)
+		}
+	}
+}
+
+func TestNumberGroup(t *testing.T) {
+	newJobStore(t)
+	ngrp, err := postgres.NewNumberGroup(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ngrp.Latest("werft")
+	if err != store.ErrNotFound {
+		t.Errorf("expected ErrNotFound for unknown group, got %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		nr, err := ngrp.Next("werft")
+		if err != nil {
+			t.Fatalf("cannot get next number: %v", err)
+		}
+		if nr != i {
+			t.Errorf("expected %d but got %d", i, nr)
+		}
+	}
+	nr, err := ngrp.Latest("werft")
+	if err != nil {
+		t.Fatalf("cannot get latest number: %v", err)
+	}
+	if nr != 2 {
+		t.Errorf("expected latest number 2 but got %d", nr)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	newJobStore(t)
+	auditLog, err := postgres.NewAuditLog(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	entries := []audit.Entry{
+		{Time: now, Actor: "alice", Action: audit.ActionStart, Job: "werft-build-main.1", Repo: "github.com/csweichel/werft", Owner: "alice", Trigger: "manual", Success: true},
+		{Time: now, Actor: "plugin:github", Action: audit.ActionStop, Job: "werft-build-main.1", Error: "job is in unstoppable phase"},
+	}
+	for _, e := range entries {
+		err = auditLog.Log(context.Background(), e)
+		if err != nil {
+			t.Fatalf("cannot log audit entry: %v", err)
+		}
+	}
+
+	rows, err := db.Query("SELECT time, actor, action, job, COALESCE(repo, ''), success, COALESCE(error, '') FROM audit_log ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var i int
+	for ; rows.Next(); i++ {
+		var (
+			act     audit.Entry
+			success int
+		)
+		err = rows.Scan(&act.Time, &act.Actor, &act.Action, &act.Job, &act.Repo, &success, &act.Error)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp := entries[i]
+		if !act.Time.Equal(exp.Time) || act.Actor != exp.Actor || act.Action != exp.Action || act.Job != exp.Job || act.Repo != exp.Repo || (success == 1) != exp.Success || act.Error != exp.Error {
+			t.Errorf("entry %d: expected %+v, got %+v (success %d)", i, exp, act, success)
+		}
+	}
+	if i != len(entries) {
+		t.Errorf("expected %d entries, got %d", len(entries), i)
+	}
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	err := postgres.Migrate(db)
+	if err != nil {
+		t.Errorf("cannot migrate an up-to-date database: %v", err)
+	}
+}
+
+func TestFindMatchesInMemory(t *testing.T) {
+	var (
+		ctx     = context.Background()
+		sqlJobs = newJobStore(t)
+		memJobs = store.NewInMemoryJobStore()
+	)
+	for _, j := range []storetest.Job{
+		{Name: "werft_build.1", Phase: v1.JobPhase_PHASE_DONE, Owner: "foo", Repo: "werft", Ref: "main", Success: true, Created: 1000, Annotations: map[string]string{"coverage": "80%"}},
+		{Name: "werft%build.2", Phase: v1.JobPhase_PHASE_DONE, Owner: "Foo", Repo: "werft", Ref: "feature_x", Created: 2000, Annotations: map[string]string{"coverage": "75"}},
+		{Name: "werftXbuild.3", Phase: v1.JobPhase_PHASE_RUNNING, Owner: "bar", Repo: "werft", Ref: "featureXx", Created: 3000},
+		{Name: `werft\build.4`, Phase: v1.JobPhase_PHASE_WAITING, Owner: "bar", Repo: "leeway", Ref: "main", Created: 4000},
+	} {
+		for _, jobs := range []store.Jobs{sqlJobs, memJobs} {
+			err := jobs.Store(ctx, j.Status())
+			if err != nil {
+				t.Fatalf("cannot store job: %v", err)
+			}
+		}
+	}
+
+	tests := [][]string{
+		{"name~=_"},
+		{"name~=%"},
+		{`name~=\`},
+		{"name|=werft_"},
+		{"name!|=werft_"},
+		{"name=|%build.2"},
+		{"repo.ref|=feature_"},
+		{"repo.ref~=_x"},
+		{"annotation.coverage=|%"},
+		{"annotation.coverage!~=%"},
+		{"owner==foo"},
+		{"phase!=done", "repo.repo==werft"},
+		{"name=~^werft.build", "success==false"},
+		{"created>1970-01-01T00:25:00Z,owner==foo"},
+		{"created~=1970-01-01T00:3"},
+		{"created|=1970-01-01T01"},
+		{"created=~T00:(16|50)"},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test, " "), func(t *testing.T) {
+			filter, err := filterexpr.ParseExpressions(test)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var res [2][]string
+			for i, jobs := range []store.Jobs{sqlJobs, memJobs} {
+				found, _, err := jobs.Find(ctx, filter, []*v1.OrderExpression{{Field: "name", Ascending: true}}, nil, 0, 0)
+				if err != nil {
+					t.Fatalf("cannot find jobs: %v", err)
+				}
+				for _, js := range found {
+					res[i] = append(res[i], js.Name)
+				}
+			}
+			if !reflect.DeepEqual(res[0], res[1]) {
+				t.Errorf("SQL store found %v, but in-memory store found %v", res[0], res[1])
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package postgres
+
+import "testing"
+
+func TestEscapeLike(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Expected string
+	}{
+		{"werft", "werft"},
+		{"100%", `100\%`},
+		{"werft_build", `werft\_build`},
+		{`C:\werft`, `C:\\werft`},
+		{`%_\`, `\%\_\\`},
+	}
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			act := escapeLike(test.Input)
+			if act != test.Expected {
+				t.Errorf("expected %q but got %q", test.Expected, act)
+			}
+		})
+	}
+}
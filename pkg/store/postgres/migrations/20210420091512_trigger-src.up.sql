@@ -0,0 +1,6 @@
+UPDATE job_status SET trigger_src = CASE data::jsonb->'metadata'->>'trigger'
+		WHEN '1' THEN 'manual'
+		WHEN '2' THEN 'push'
+		WHEN '3' THEN 'deleted'
+		ELSE 'unknown'
+	END;
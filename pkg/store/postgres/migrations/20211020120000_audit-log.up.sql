@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+	id SERIAL PRIMARY KEY,
+	time TIMESTAMP WITH TIME ZONE NOT NULL,
+	actor varchar(255) NOT NULL,
+	action varchar(255) NOT NULL,
+	job varchar(255) NULL,
+	previous_job varchar(255) NULL,
+	repo varchar(255) NULL,
+	owner varchar(255) NULL,
+	trigger_src varchar(255) NULL,
+	success int NOT NULL,
+	error text NULL
+);
+
+CREATE INDEX idx_audit_log_time ON audit_log(time);
+CREATE INDEX idx_audit_log_actor ON audit_log(actor);
+CREATE INDEX idx_audit_log_job ON audit_log(job);
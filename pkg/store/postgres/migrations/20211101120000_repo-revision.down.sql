@@ -0,0 +1,2 @@
+DROP INDEX idx_job_status_repo_revision;
+ALTER TABLE job_status DROP COLUMN repo_revision;
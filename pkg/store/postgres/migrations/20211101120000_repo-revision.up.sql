@@ -0,0 +1,3 @@
+ALTER TABLE job_status ADD COLUMN repo_revision varchar(255) NULL;
+UPDATE job_status SET repo_revision = data::jsonb->'metadata'->'repository'->>'revision';
+CREATE INDEX idx_job_status_repo_revision ON job_status(repo_revision);
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
+)
+
+// LogRetention configures how long logs are kept
+type LogRetention struct {
+	// MaxAge deletes logs which haven't been written to for longer than this. Zero means no limit.
+	MaxAge time.Duration
+	// MaxTotalSize deletes the oldest logs until the remaining ones take up at most this many bytes. Zero means no limit.
+	MaxTotalSize int64
+	// DeleteJobs deletes the jobs from the job store together with their logs.
+	// Otherwise the jobs outlive their logs.
+	DeleteJobs bool
+}
+
+// LogSweeper deletes logs according to a retention policy
+type LogSweeper struct {
+	Logs      PrunableLogs
+	Jobs      Jobs
+	Retention LogRetention
+
+	metrics struct {
+		ReclaimedBytes prometheus.Counter
+		DeletedLogs    prometheus.Counter
+	}
+}
+
+// NewLogSweeper creates a new log sweeper
+func NewLogSweeper(logs PrunableLogs, jobs Jobs, retention LogRetention) *LogSweeper {
+	res := &LogSweeper{
+		Logs:      logs,
+		Jobs:      jobs,
+		Retention: retention,
+	}
+	res.metrics.ReclaimedBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "log_retention_reclaimed_bytes_total",
+		Help: "Bytes reclaimed by deleting logs beyond their retention",
+	})
+	res.metrics.DeletedLogs = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "log_retention_deleted_logs_total",
+		Help: "Logs deleted because they were beyond their retention",
+	})
+	return res
+}
+
+// RegisterPrometheusMetrics registers metrics on the registerer with MustRegister
+func (s *LogSweeper) RegisterPrometheusMetrics(reg prometheus.Registerer) {
+	reg.MustRegister(
+		s.metrics.ReclaimedBytes,
+		s.metrics.DeletedLogs,
+	)
+}
+
+// Run sweeps the logs periodically. This function does not return.
+func (s *LogSweeper) Run(interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for ; true; <-tick.C {
+		reclaimed, err := s.Sweep(context.Background(), time.Now())
+		if err != nil {
+			log.WithError(err).Warn("cannot enforce log retention")
+		}
+		if reclaimed > 0 {
+			log.WithField("bytes", reclaimed).Info("deleted logs beyond their retention")
+		}
+	}
+}
+
+// Sweep deletes all logs which are older than the max age, and the oldest logs while the logs exceed
+// the max total size. Returns the number of bytes reclaimed.
+func (s *LogSweeper) Sweep(ctx context.Context, now time.Time) (reclaimed int64, err error) {
+	logs, err := s.Logs.List()
+	if err != nil {
+		return 0, xerrors.Errorf("cannot list logs: %w", err)
+	}
+	sort.Slice(logs, func(i, j int) bool { return logs[i].Modified.Before(logs[j].Modified) })
+
+	var total int64
+	for _, l := range logs {
+		total += l.Size
+	}
+
+	for _, l := range logs {
+		expired := s.Retention.MaxAge > 0 && now.Sub(l.Modified) > s.Retention.MaxAge
+		tooLarge := s.Retention.MaxTotalSize > 0 && total > s.Retention.MaxTotalSize
+		if !expired && !tooLarge {
+			// logs are sorted by age, hence all remaining ones are within the retention
+			break
+		}
+
+		err = s.Logs.Delete(l.ID)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return reclaimed, xerrors.Errorf("cannot delete log %s: %w", l.ID, err)
+		}
+		total -= l.Size
+		reclaimed += l.Size
+		s.metrics.ReclaimedBytes.Add(float64(l.Size))
+		s.metrics.DeletedLogs.Inc()
+
+		if s.Retention.DeleteJobs {
+			err = s.Jobs.Delete(ctx, l.ID)
+			if err != nil && err != ErrNotFound {
+				return reclaimed, xerrors.Errorf("cannot delete job %s: %w", l.ID, err)
+			}
+		}
+	}
+
+	return reclaimed, nil
+}
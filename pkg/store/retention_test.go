@@ -0,0 +1,139 @@
+package store_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/store"
+)
+
+func TestLogRetention(t *testing.T) {
+	now := time.Now()
+	type logfile struct {
+		ID   string
+		Size int
+		Age  time.Duration
+		Open bool
+	}
+	tests := []struct {
+		Name      string
+		Logs      []logfile
+		Retention store.LogRetention
+		Deleted   []string
+		Reclaimed int64
+	}{
+		{
+			Name: "max age",
+			Logs: []logfile{
+				{ID: "old", Size: 10, Age: 40 * 24 * time.Hour},
+				{ID: "new", Size: 10, Age: time.Hour},
+			},
+			Retention: store.LogRetention{MaxAge: 30 * 24 * time.Hour},
+			Deleted:   []string{"old"},
+			Reclaimed: 10,
+		},
+		{
+			Name: "max total size",
+			Logs: []logfile{
+				{ID: "oldest", Size: 100, Age: 3 * time.Hour},
+				{ID: "older", Size: 100, Age: 2 * time.Hour},
+				{ID: "newest", Size: 100, Age: time.Hour},
+			},
+			Retention: store.LogRetention{MaxTotalSize: 150},
+			Deleted:   []string{"oldest", "older"},
+			Reclaimed: 200,
+		},
+		{
+			Name: "jobs metadata deleted",
+			Logs: []logfile{
+				{ID: "old", Size: 10, Age: 40 * 24 * time.Hour},
+			},
+			Retention: store.LogRetention{MaxAge: 30 * 24 * time.Hour, DeleteJobs: true},
+			Deleted:   []string{"old"},
+			Reclaimed: 10,
+		},
+		{
+			Name: "open logs are kept",
+			Logs: []logfile{
+				{ID: "running", Size: 10, Age: 40 * 24 * time.Hour, Open: true},
+			},
+			Retention: store.LogRetention{MaxAge: 30 * 24 * time.Hour},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			base, err := ioutil.TempDir(os.TempDir(), "tlr")
+			if err != nil {
+				t.Fatalf("cannot create test folder: %v", err)
+			}
+			defer os.RemoveAll(base)
+
+			logs, err := store.NewFileLogStore(base)
+			if err != nil {
+				t.Fatalf("cannot create log store: %v", err)
+			}
+			jobs := store.NewInMemoryJobStore()
+			for _, l := range test.Logs {
+				w, err := logs.Open(l.ID)
+				if err != nil {
+					t.Fatalf("cannot place log: %v", err)
+				}
+				_, err = w.Write([]byte(strings.Repeat("x", l.Size)))
+				if err != nil {
+					t.Fatalf("cannot write log: %v", err)
+				}
+				if !l.Open {
+					w.Close()
+				}
+				mtime := now.Add(-l.Age)
+				err = os.Chtimes(filepath.Join(base, l.ID+".log"), mtime, mtime)
+				if err != nil {
+					t.Fatalf("cannot change log modification time: %v", err)
+				}
+
+				err = jobs.Store(context.Background(), v1.JobStatus{Name: l.ID})
+				if err != nil {
+					t.Fatalf("cannot store job: %v", err)
+				}
+			}
+
+			sweeper := store.NewLogSweeper(logs, jobs, test.Retention)
+			reclaimed, err := sweeper.Sweep(context.Background(), now)
+			if err != nil {
+				t.Fatalf("cannot sweep logs: %v", err)
+			}
+			if reclaimed != test.Reclaimed {
+				t.Errorf("unexpected bytes reclaimed: %d; expected %d", reclaimed, test.Reclaimed)
+			}
+
+			deleted := make(map[string]bool)
+			for _, id := range test.Deleted {
+				deleted[id] = true
+			}
+			for _, l := range test.Logs {
+				_, err := logs.Read(l.ID)
+				if deleted[l.ID] && err != store.ErrNotFound {
+					t.Errorf("log %s should have been deleted, got %v", l.ID, err)
+				}
+				if !deleted[l.ID] && err != nil {
+					t.Errorf("log %s should have been kept, got %v", l.ID, err)
+				}
+
+				_, err = jobs.Get(context.Background(), l.ID)
+				if deleted[l.ID] && test.Retention.DeleteJobs {
+					if err != store.ErrNotFound {
+						t.Errorf("job %s should have been deleted with its log, got %v", l.ID, err)
+					}
+				} else if err != nil {
+					t.Errorf("job %s should outlive its log, got %v", l.ID, err)
+				}
+			}
+		})
+	}
+}
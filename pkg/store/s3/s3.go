@@ -0,0 +1,88 @@
+package s3
+
+import (
+	"context"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/csweichel/werft/pkg/store"
+	"golang.org/x/xerrors"
+)
+
+// Config configures an S3 blob store
+type Config struct {
+	Bucket string `yaml:"bucket"`
+	Region string `yaml:"region,omitempty"`
+
+	// Endpoint is the URL of an S3 compatible object storage, e.g. MinIO. If empty, AWS is used.
+	Endpoint string `yaml:"endpoint,omitempty"`
+	// ForcePathStyle addresses buckets as part of the path instead of the host name, as some S3 compatible storages require.
+	ForcePathStyle bool `yaml:"forcePathStyle,omitempty"`
+}
+
+// BlobStore stores blobs in an S3 bucket
+type BlobStore struct {
+	Bucket string
+
+	client   *awss3.S3
+	uploader *s3manager.Uploader
+}
+
+// NewBlobStore creates a new S3 blob store. Credentials are taken from the environment,
+// e.g. the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables or an instance role.
+func NewBlobStore(cfg Config) (*BlobStore, error) {
+	if cfg.Bucket == "" {
+		return nil, xerrors.Errorf("S3 bucket is required")
+	}
+
+	awsCfg := aws.NewConfig().WithS3ForcePathStyle(cfg.ForcePathStyle)
+	if cfg.Region != "" {
+		awsCfg = awsCfg.WithRegion(cfg.Region)
+	}
+	if cfg.Endpoint != "" {
+		awsCfg = awsCfg.WithEndpoint(cfg.Endpoint)
+	}
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot create S3 session: %w", err)
+	}
+
+	return &BlobStore{
+		Bucket:   cfg.Bucket,
+		client:   awss3.New(sess),
+		uploader: s3manager.NewUploader(sess),
+	}, nil
+}
+
+// Put uploads a blob. Large blobs are uploaded in parts, hence we don't need to know the size upfront.
+func (s *BlobStore) Put(ctx context.Context, key string, r io.Reader) error {
+	_, err := s.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(key),
+		Body:        r,
+		ContentType: aws.String("text/plain; charset=utf-8"),
+	})
+	if err != nil {
+		return xerrors.Errorf("cannot upload %s: %w", key, err)
+	}
+	return nil
+}
+
+// Get downloads a blob
+func (s *BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	obj, err := s.client.GetObjectWithContext(ctx, &awss3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == awss3.ErrCodeNoSuchKey {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("cannot download %s: %w", key, err)
+	}
+	return obj.Body, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"time"
 
 	v1 "github.com/csweichel/werft/pkg/api/v1"
 )
@@ -34,6 +35,37 @@ type Logs interface {
 	Read(id string) (io.ReadCloser, error)
 }
 
+// SectionLogs is a log store which indexes where the sections of its logs start,
+// so that a single section can be read without cutting the log from the start
+type SectionLogs interface {
+	Logs
+
+	// ReadSection retrieves a log file from this store starting at the first line of a section.
+	// Phase is the phase unmarked content belongs to at that point, see logcutter.PhaseCutter.
+	// If the section hasn't started (yet), the log is read from the start.
+	// Returns ErrNotFound if the log file isn't found.
+	ReadSection(id, section string) (rd io.ReadCloser, phase string, err error)
+}
+
+// PrunableLogs is a log store whose logs can be deleted, e.g. to enforce a retention policy
+type PrunableLogs interface {
+	Logs
+
+	// List returns all logs which aren't being written to at the moment.
+	List() ([]LogInfo, error)
+
+	// Delete removes a log from this store.
+	// Returns ErrNotFound if the log isn't found. Logs currently being written cannot be deleted.
+	Delete(id string) error
+}
+
+// LogInfo describes a log in a store
+type LogInfo struct {
+	ID       string
+	Size     int64
+	Modified time.Time
+}
+
 // Jobs provides access to past jobs
 type Jobs interface {
 	// Store stores job information in the store.
@@ -51,9 +83,14 @@ type Jobs interface {
 	// Get retrieves previously stored job spec data
 	GetJobSpec(name string) (data []byte, err error)
 
+	// Delete removes a job and its job spec from the store.
+	// Returns ErrNotFound if the job is unknown.
+	Delete(ctx context.Context, name string) error
+
 	// Searches for jobs based on their annotations. If filter is empty no filter is applied.
-	// If limit is 0, no limit is applied.
-	Find(ctx context.Context, filter []*v1.FilterExpression, order []*v1.OrderExpression, start, limit int) (slice []v1.JobStatus, total int, err error)
+	// If group is not empty, only the newest job of each group of jobs which share the values
+	// of the group fields is returned. If limit is 0, no limit is applied.
+	Find(ctx context.Context, filter []*v1.FilterExpression, order []*v1.OrderExpression, group []string, start, limit int) (slice []v1.JobStatus, total int, err error)
 }
 
 // NumberGroup enables to atomic generation and storage of numbers.
@@ -0,0 +1,439 @@
+// Package storetest verifies that job stores and number groups implement the semantics werft expects of them.
+// Every implementation runs the same suite, hence they're interchangeable.
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/filterexpr"
+	"github.com/csweichel/werft/pkg/store"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+// TestJobs runs the job store suite. newStore must return an empty store each time it's called.
+func TestJobs(t *testing.T, newStore func(t *testing.T) store.Jobs) {
+	t.Run("StoreGet", func(t *testing.T) { testStoreGet(t, newStore(t)) })
+	t.Run("JobSpec", func(t *testing.T) { testJobSpec(t, newStore(t)) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, newStore(t)) })
+	t.Run("Find", func(t *testing.T) { testFind(t, newStore(t)) })
+}
+
+// TestNumberGroup runs the number group suite. newGroup must return empty number groups each time it's called.
+func TestNumberGroup(t *testing.T, newGroup func(t *testing.T) store.NumberGroup) {
+	t.Run("Sequence", func(t *testing.T) { testNumberGroupSequence(t, newGroup(t)) })
+	t.Run("Concurrent", func(t *testing.T) { testNumberGroupConcurrent(t, newGroup(t)) })
+}
+
+// Job describes a job in terms of the fields which can be searched for
+type Job struct {
+	Name        string
+	Phase       v1.JobPhase
+	Owner       string
+	Repo        string
+	Ref         string
+	Revision    string
+	Success     bool
+	Created     int64
+	Started     int64
+	Finished    int64
+	Annotations map[string]string
+	Results     []*v1.JobResult
+}
+
+// Status produces the status of the job
+func (j Job) Status() v1.JobStatus {
+	res := v1.JobStatus{
+		Name:  j.Name,
+		Phase: j.Phase,
+		Metadata: &v1.JobMetadata{
+			Owner: j.Owner,
+			Repository: &v1.Repository{
+				Host:     "github.com",
+				Owner:    "csweichel",
+				Repo:     j.Repo,
+				Ref:      j.Ref,
+				Revision: j.Revision,
+			},
+			Trigger: v1.JobTrigger_TRIGGER_PUSH,
+			Created: &timestamp.Timestamp{Seconds: j.Created},
+		},
+		Conditions: &v1.JobConditions{Success: j.Success, DidExecute: true},
+		Results:    j.Results,
+	}
+	if j.Started != 0 {
+		res.Metadata.Started = &timestamp.Timestamp{Seconds: j.Started}
+	}
+	if j.Finished != 0 {
+		res.Metadata.Finished = &timestamp.Timestamp{Seconds: j.Finished}
+	}
+	for k, v := range j.Annotations {
+		res.Metadata.Annotations = append(res.Metadata.Annotations, &v1.Annotation{Key: k, Value: v})
+	}
+	return res
+}
+
+func testStoreGet(t *testing.T, jobs store.Jobs) {
+	ctx := context.Background()
+
+	_, err := jobs.Get(ctx, "unknown")
+	if err != store.ErrNotFound {
+		t.Errorf("expected ErrNotFound for unknown job, got %v", err)
+	}
+
+	job := Job{Name: "werft-build.1", Phase: v1.JobPhase_PHASE_RUNNING, Owner: "foo", Repo: "werft", Ref: "main", Created: 1000, Annotations: map[string]string{"version": "1"}}.Status()
+	err = jobs.Store(ctx, job)
+	if err != nil {
+		t.Fatalf("cannot store job: %v", err)
+	}
+	act, err := jobs.Get(ctx, job.Name)
+	if err != nil {
+		t.Fatalf("cannot get job: %v", err)
+	}
+	if !proto.Equal(act, &job) {
+		t.Errorf("unexpected job: %v, expected %v", act, &job)
+	}
+
+	// storing a job again replaces it
+	job = Job{Name: "werft-build.1", Phase: v1.JobPhase_PHASE_DONE, Owner: "foo", Repo: "werft", Ref: "main", Success: true, Created: 1000, Finished: 2000, Annotations: map[string]string{"version": "2"}}.Status()
+	err = jobs.Store(ctx, job)
+	if err != nil {
+		t.Fatalf("cannot update job: %v", err)
+	}
+	act, err = jobs.Get(ctx, job.Name)
+	if err != nil {
+		t.Fatalf("cannot get job: %v", err)
+	}
+	if !proto.Equal(act, &job) {
+		t.Errorf("unexpected job: %v, expected %v", act, &job)
+	}
+
+	res, total, err := jobs.Find(ctx, nil, nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot find jobs: %v", err)
+	}
+	if total != 1 || len(res) != 1 || !proto.Equal(&res[0], &job) {
+		t.Errorf("expected to find the updated job only, found %d: %v", total, res)
+	}
+}
+
+func testJobSpec(t *testing.T, jobs store.Jobs) {
+	_, err := jobs.GetJobSpec("unknown")
+	if err != store.ErrNotFound {
+		t.Errorf("expected ErrNotFound for unknown job spec, got %v", err)
+	}
+
+	for _, spec := range []string{"pod: {}", "pod: {containers: []}"} {
+		err = jobs.StoreJobSpec("werft-build.1", []byte(spec))
+		if err != nil {
+			t.Fatalf("cannot store job spec: %v", err)
+		}
+		act, err := jobs.GetJobSpec("werft-build.1")
+		if err != nil {
+			t.Fatalf("cannot get job spec: %v", err)
+		}
+		if string(act) != spec {
+			t.Errorf("unexpected job spec: %q, expected %q", string(act), spec)
+		}
+	}
+}
+
+func testDelete(t *testing.T, jobs store.Jobs) {
+	ctx := context.Background()
+
+	for _, j := range []Job{
+		{Name: "werft-build.1", Phase: v1.JobPhase_PHASE_DONE, Created: 1000, Annotations: map[string]string{"version": "1"}},
+		{Name: "werft-build.2", Phase: v1.JobPhase_PHASE_DONE, Created: 2000},
+	} {
+		err := jobs.Store(ctx, j.Status())
+		if err != nil {
+			t.Fatalf("cannot store job: %v", err)
+		}
+		err = jobs.StoreJobSpec(j.Name, []byte("pod: {}"))
+		if err != nil {
+			t.Fatalf("cannot store job spec: %v", err)
+		}
+	}
+
+	err := jobs.Delete(ctx, "werft-build.1")
+	if err != nil {
+		t.Fatalf("cannot delete job: %v", err)
+	}
+	_, err = jobs.Get(ctx, "werft-build.1")
+	if err != store.ErrNotFound {
+		t.Errorf("expected ErrNotFound for deleted job, got %v", err)
+	}
+	_, err = jobs.GetJobSpec("werft-build.1")
+	if err != store.ErrNotFound {
+		t.Errorf("expected ErrNotFound for deleted job spec, got %v", err)
+	}
+	filter, err := filterexpr.ParseExpressions([]string{"annotation.version"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, total, err := jobs.Find(ctx, filter, nil, nil, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot find jobs: %v", err)
+	}
+	if total != 0 {
+		t.Errorf("expected the deleted job not to be found, found %d jobs", total)
+	}
+
+	// other jobs are left alone
+	_, err = jobs.Get(ctx, "werft-build.2")
+	if err != nil {
+		t.Errorf("cannot get job which was not deleted: %v", err)
+	}
+	_, err = jobs.GetJobSpec("werft-build.2")
+	if err != nil {
+		t.Errorf("cannot get job spec of job which was not deleted: %v", err)
+	}
+
+	err = jobs.Delete(ctx, "werft-build.1")
+	if err != store.ErrNotFound {
+		t.Errorf("expected ErrNotFound when deleting an unknown job, got %v", err)
+	}
+}
+
+func testFind(t *testing.T, jobs store.Jobs) {
+	for _, j := range []Job{
+		{Name: "werft-build.1", Phase: v1.JobPhase_PHASE_DONE, Owner: "foo", Repo: "werft", Ref: "main", Success: true, Created: 1000, Started: 1100, Finished: 1500, Annotations: map[string]string{"version": "1"}},
+		{Name: "werft-build.2", Phase: v1.JobPhase_PHASE_DONE, Owner: "Bar", Repo: "werft", Ref: "feature", Revision: "c0ffee7a0b1c2d3e", Success: false, Created: 2000, Started: 2050, Finished: 2500, Results: []*v1.JobResult{{Type: "url", Payload: "https://preview.example.com"}}},
+		{Name: "werft-build.3", Phase: v1.JobPhase_PHASE_RUNNING, Owner: "foo", Repo: "werft", Ref: "main", Created: 3000, Started: 3100, Annotations: map[string]string{"version": "2"}},
+		{Name: "leeway-build.1", Phase: v1.JobPhase_PHASE_WAITING, Owner: "bar", Repo: "leeway", Ref: "main", Created: 4000},
+	} {
+		err := jobs.Store(context.Background(), j.Status())
+		if err != nil {
+			t.Fatalf("cannot store job: %v", err)
+		}
+	}
+
+	byName := []*v1.OrderExpression{{Field: "name", Ascending: true}}
+	tests := []struct {
+		Name     string
+		Filter   []string
+		Order    []*v1.OrderExpression
+		Group    []string
+		Start    int
+		Limit    int
+		Expected []string
+		Total    int
+	}{
+		{
+			Name:     "no filter",
+			Order:    byName,
+			Expected: []string{"leeway-build.1", "werft-build.1", "werft-build.2", "werft-build.3"},
+			Total:    4,
+		},
+		{
+			Name:     "filter",
+			Filter:   []string{"phase==running,phase==waiting", "owner==foo"},
+			Order:    byName,
+			Expected: []string{"werft-build.3"},
+			Total:    1,
+		},
+		{
+			Name:     "no match",
+			Filter:   []string{"owner==nobody"},
+			Order:    byName,
+			Expected: nil,
+			Total:    0,
+		},
+		{
+			Name:     "annotation",
+			Filter:   []string{"annotation.version"},
+			Order:    byName,
+			Expected: []string{"werft-build.1", "werft-build.3"},
+			Total:    2,
+		},
+		{
+			Name:     "result",
+			Filter:   []string{"result.url|=https://preview."},
+			Order:    byName,
+			Expected: []string{"werft-build.2"},
+			Total:    1,
+		},
+		{
+			Name:     "time",
+			Filter:   []string{"completed>1970-01-01T00:30:00Z"},
+			Order:    byName,
+			Expected: []string{"werft-build.2"},
+			Total:    1,
+		},
+		{
+			Name:     "repo",
+			Filter:   []string{`repo=~^github\.com/csweichel/[^/]*ee`},
+			Order:    byName,
+			Expected: []string{"leeway-build.1"},
+			Total:    1,
+		},
+		{
+			Name:     "revision",
+			Filter:   []string{"repo.revision|=c0ffee7"},
+			Order:    byName,
+			Expected: []string{"werft-build.2"},
+			Total:    1,
+		},
+		{
+			Name:     "revision alias",
+			Filter:   []string{"repo.rev|=c0ffee7"},
+			Order:    byName,
+			Expected: []string{"werft-build.2"},
+			Total:    1,
+		},
+		{
+			// measured from their creation both jobs ran for 500s
+			Name:     "duration from start",
+			Filter:   []string{"phase==done", "duration<445s"},
+			Order:    byName,
+			Expected: []string{"werft-build.1"},
+			Total:    1,
+		},
+		{
+			Name:     "order descending",
+			Order:    []*v1.OrderExpression{{Field: "created", Ascending: false}},
+			Expected: []string{"leeway-build.1", "werft-build.3", "werft-build.2", "werft-build.1"},
+			Total:    4,
+		},
+		{
+			Name:     "first page",
+			Order:    byName,
+			Limit:    3,
+			Expected: []string{"leeway-build.1", "werft-build.1", "werft-build.2"},
+			Total:    4,
+		},
+		{
+			Name:     "last page",
+			Order:    byName,
+			Start:    3,
+			Limit:    3,
+			Expected: []string{"werft-build.3"},
+			Total:    4,
+		},
+		{
+			Name:     "beyond last page",
+			Order:    byName,
+			Start:    10,
+			Limit:    3,
+			Expected: nil,
+			Total:    4,
+		},
+		{
+			Name:     "latest per group",
+			Order:    byName,
+			Group:    []string{"repo.repo", "repo.ref"},
+			Expected: []string{"leeway-build.1", "werft-build.2", "werft-build.3"},
+			Total:    3,
+		},
+		{
+			Name:     "latest per group with filter",
+			Filter:   []string{"phase==done"},
+			Order:    byName,
+			Group:    []string{"repo.ref"},
+			Expected: []string{"werft-build.1", "werft-build.2"},
+			Total:    2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			filter, err := filterexpr.ParseExpressions(test.Filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, total, err := jobs.Find(context.Background(), filter, test.Order, test.Group, test.Start, test.Limit)
+			if err != nil {
+				t.Fatalf("cannot find jobs: %v", err)
+			}
+
+			var names []string
+			for _, js := range res {
+				names = append(names, js.Name)
+			}
+			if !reflect.DeepEqual(names, test.Expected) {
+				t.Errorf("expected %v but got %v", test.Expected, names)
+			}
+			if total != test.Total {
+				t.Errorf("expected total of %d but got %d", test.Total, total)
+			}
+		})
+	}
+}
+
+func testNumberGroupSequence(t *testing.T, ngrp store.NumberGroup) {
+	_, err := ngrp.Latest("werft")
+	if err != store.ErrNotFound {
+		t.Errorf("expected ErrNotFound for unknown group, got %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		nr, err := ngrp.Next("werft")
+		if err != nil {
+			t.Fatalf("cannot get next number: %v", err)
+		}
+		if nr != i {
+			t.Errorf("expected %d but got %d", i, nr)
+		}
+	}
+	nr, err := ngrp.Latest("werft")
+	if err != nil {
+		t.Fatalf("cannot get latest number: %v", err)
+	}
+	if nr != 2 {
+		t.Errorf("expected latest number 2 but got %d", nr)
+	}
+
+	// groups are independent of each other
+	nr, err = ngrp.Next("leeway")
+	if err != nil {
+		t.Fatalf("cannot get next number: %v", err)
+	}
+	if nr != 0 {
+		t.Errorf("expected a new group to start at 0, got %d", nr)
+	}
+}
+
+func testNumberGroupConcurrent(t *testing.T, ngrp store.NumberGroup) {
+	const n = 20
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = make(map[int]struct{})
+		errs []error
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nr, err := ngrp.Next("werft")
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			if _, exists := seen[nr]; exists {
+				errs = append(errs, fmt.Errorf("number %d was handed out twice", nr))
+			}
+			seen[nr] = struct{}{}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		t.Error(err)
+	}
+	nr, err := ngrp.Latest("werft")
+	if err != nil {
+		t.Fatalf("cannot get latest number: %v", err)
+	}
+	if nr != n-1 {
+		t.Errorf("expected latest number %d but got %d", n-1, nr)
+	}
+}
@@ -0,0 +1,50 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/csweichel/werft/pkg/tracing"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestPropagation(t *testing.T) {
+	tp := sdktrace.NewTracerProvider()
+	ctx, span := tp.Tracer("test").Start(context.Background(), "webhook")
+	defer span.End()
+	expected := span.SpanContext().TraceID()
+
+	// werft proxies the webhook to the plugin
+	header := make(http.Header)
+	tracing.Inject(ctx, header)
+	ctx = tracing.Extract(context.Background(), header)
+	if act := trace.SpanContextFromContext(ctx).TraceID(); act != expected {
+		t.Fatalf("HTTP header lost the trace: %s, expected %s", act, expected)
+	}
+
+	// the plugin calls werft
+	var md metadata.MD
+	err := tracing.UnaryClientInterceptor(ctx, "/v1.WerftService/StartGitHubJob", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var act trace.TraceID
+	_, err = tracing.UnaryServerInterceptor(metadata.NewIncomingContext(context.Background(), md), nil, &grpc.UnaryServerInfo{FullMethod: "/v1.WerftService/StartGitHubJob"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		act = trace.SpanContextFromContext(ctx).TraceID()
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if act != expected {
+		t.Errorf("gRPC metadata lost the trace: %s, expected %s", act, expected)
+	}
+}
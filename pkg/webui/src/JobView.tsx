@@ -340,7 +340,7 @@ class JobViewImpl extends React.Component<JobViewProps, JobViewState> {
                 </Tabs>
             </Grid>
             <Grid item>
-                { !this.props.readonly && !!job && !![JobPhase.PHASE_WAITING, JobPhase.PHASE_PREPARING, JobPhase.PHASE_STARTING, JobPhase.PHASE_RUNNING].find(i => job.phase === i) && 
+                { !this.props.readonly && !!job && !![JobPhase.PHASE_QUEUED, JobPhase.PHASE_WAITING, JobPhase.PHASE_PREPARING, JobPhase.PHASE_STARTING, JobPhase.PHASE_RUNNING].find(i => job.phase === i) && 
                     <Tooltip title="Cancel Job">
                         <IconButton color="inherit" onClick={() => this.stopJob()}>
                             <StopIcon />
@@ -454,6 +454,9 @@ export class ListenRequest extends jspb.Message {
   getLogs(): ListenRequestLogsMap[keyof ListenRequestLogsMap];
   setLogs(value: ListenRequestLogsMap[keyof ListenRequestLogsMap]): void;
 
+  getSection(): string;
+  setSection(value: string): void;
+
   serializeBinary(): Uint8Array;
   toObject(includeInstance?: boolean): ListenRequest.AsObject;
   static toObject(includeInstance: boolean, msg: ListenRequest): ListenRequest.AsObject;
@@ -469,6 +472,7 @@ export namespace ListenRequest {
     name: string,
     updates: boolean,
     logs: ListenRequestLogsMap[keyof ListenRequestLogsMap],
+    section: string,
   }
 }
 
@@ -743,6 +747,9 @@ export class LogSliceEvent extends jspb.Message {
   getPayload(): string;
   setPayload(value: string): void;
 
+  getParent(): string;
+  setParent(value: string): void;
+
   serializeBinary(): Uint8Array;
   toObject(includeInstance?: boolean): LogSliceEvent.AsObject;
   static toObject(includeInstance: boolean, msg: LogSliceEvent): LogSliceEvent.AsObject;
@@ -758,6 +765,7 @@ export namespace LogSliceEvent {
     name: string,
     type: LogSliceTypeMap[keyof LogSliceTypeMap],
     payload: string,
+    parent: string,
   }
 }
 
@@ -803,6 +811,9 @@ export interface FilterOpMap {
   OP_ENDS_WITH: 2;
   OP_CONTAINS: 3;
   OP_EXISTS: 4;
+  OP_MATCHES: 5;
+  OP_GREATER: 6;
+  OP_LESS: 7;
 }
 
 export const FilterOp: FilterOpMap;
@@ -821,6 +832,9 @@ export interface JobTriggerMap {
   TRIGGER_MANUAL: 1;
   TRIGGER_PUSH: 2;
   TRIGGER_DELETED: 3;
+  TRIGGER_SCHEDULE: 4;
+  TRIGGER_TAG: 5;
+  TRIGGER_RELEASE: 6;
 }
 
 export const JobTrigger: JobTriggerMap;
@@ -833,6 +847,7 @@ export interface JobPhaseMap {
   PHASE_DONE: 4;
   PHASE_CLEANUP: 5;
   PHASE_WAITING: 6;
+  PHASE_QUEUED: 7;
 }
 
 export const JobPhase: JobPhaseMap;
@@ -845,6 +860,7 @@ export interface LogSliceTypeMap {
   SLICE_DONE: 4;
   SLICE_FAIL: 5;
   SLICE_RESULT: 6;
+  SLICE_DROPPED: 7;
 }
 
 export const LogSliceType: LogSliceTypeMap;
@@ -3614,7 +3614,8 @@ proto.v1.ListenRequest.toObject = function(includeInstance, msg) {
   var f, obj = {
     name: jspb.Message.getFieldWithDefault(msg, 1, ""),
     updates: jspb.Message.getFieldWithDefault(msg, 2, false),
-    logs: jspb.Message.getFieldWithDefault(msg, 3, 0)
+    logs: jspb.Message.getFieldWithDefault(msg, 3, 0),
+    section: jspb.Message.getFieldWithDefault(msg, 4, "")
   };
 
   if (includeInstance) {
@@ -3663,6 +3664,10 @@ proto.v1.ListenRequest.deserializeBinaryFromReader = function(msg, reader) {
       var value = /** @type {!proto.v1.ListenRequestLogs} */ (reader.readEnum());
       msg.setLogs(value);
       break;
+    case 4:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setSection(value);
+      break;
     default:
       reader.skipField();
       break;
@@ -3713,6 +3718,13 @@ proto.v1.ListenRequest.serializeBinaryToWriter = function(message, writer) {
       f
     );
   }
+  f = message.getSection();
+  if (f.length > 0) {
+    writer.writeString(
+      4,
+      f
+    );
+  }
 };
 
 
@@ -3763,6 +3775,21 @@ proto.v1.ListenRequest.prototype.setLogs = function(value) {
 };
 
 
+/**
+ * optional string section = 4;
+ * @return {string}
+ */
+proto.v1.ListenRequest.prototype.getSection = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 4, ""));
+};
+
+
+/** @param {string} value */
+proto.v1.ListenRequest.prototype.setSection = function(value) {
+  jspb.Message.setProto3StringField(this, 4, value);
+};
+
+
 
 /**
  * Oneof group definitions for this message. Each group defines the field
@@ -5565,7 +5592,8 @@ proto.v1.LogSliceEvent.toObject = function(includeInstance, msg) {
   var f, obj = {
     name: jspb.Message.getFieldWithDefault(msg, 1, ""),
     type: jspb.Message.getFieldWithDefault(msg, 2, 0),
-    payload: jspb.Message.getFieldWithDefault(msg, 3, "")
+    payload: jspb.Message.getFieldWithDefault(msg, 3, ""),
+    parent: jspb.Message.getFieldWithDefault(msg, 4, "")
   };
 
   if (includeInstance) {
@@ -5614,6 +5642,10 @@ proto.v1.LogSliceEvent.deserializeBinaryFromReader = function(msg, reader) {
       var value = /** @type {string} */ (reader.readString());
       msg.setPayload(value);
       break;
+    case 4:
+      var value = /** @type {string} */ (reader.readString());
+      msg.setParent(value);
+      break;
     default:
       reader.skipField();
       break;
@@ -5664,6 +5696,13 @@ proto.v1.LogSliceEvent.serializeBinaryToWriter = function(message, writer) {
       f
     );
   }
+  f = message.getParent();
+  if (f.length > 0) {
+    writer.writeString(
+      4,
+      f
+    );
+  }
 };
 
 
@@ -5712,6 +5751,21 @@ proto.v1.LogSliceEvent.prototype.setPayload = function(value) {
 };
 
 
+/**
+ * optional string parent = 4;
+ * @return {string}
+ */
+proto.v1.LogSliceEvent.prototype.getParent = function() {
+  return /** @type {string} */ (jspb.Message.getFieldWithDefault(this, 4, ""));
+};
+
+
+/** @param {string} value */
+proto.v1.LogSliceEvent.prototype.setParent = function(value) {
+  jspb.Message.setProto3StringField(this, 4, value);
+};
+
+
 
 
 
@@ -5944,7 +5998,10 @@ proto.v1.FilterOp = {
   OP_STARTS_WITH: 1,
   OP_ENDS_WITH: 2,
   OP_CONTAINS: 3,
-  OP_EXISTS: 4
+  OP_EXISTS: 4,
+  OP_MATCHES: 5,
+  OP_GREATER: 6,
+  OP_LESS: 7
 };
 
 /**
@@ -5964,7 +6021,10 @@ proto.v1.JobTrigger = {
   TRIGGER_UNKNOWN: 0,
   TRIGGER_MANUAL: 1,
   TRIGGER_PUSH: 2,
-  TRIGGER_DELETED: 3
+  TRIGGER_DELETED: 3,
+  TRIGGER_SCHEDULE: 4,
+  TRIGGER_TAG: 5,
+  TRIGGER_RELEASE: 6
 };
 
 /**
@@ -5977,7 +6037,8 @@ proto.v1.JobPhase = {
   PHASE_RUNNING: 3,
   PHASE_DONE: 4,
   PHASE_CLEANUP: 5,
-  PHASE_WAITING: 6
+  PHASE_WAITING: 6,
+  PHASE_QUEUED: 7
 };
 
 /**
@@ -5990,7 +6051,8 @@ proto.v1.LogSliceType = {
   SLICE_CONTENT: 3,
   SLICE_DONE: 4,
   SLICE_FAIL: 5,
-  SLICE_RESULT: 6
+  SLICE_RESULT: 6,
+  SLICE_DROPPED: 7
 };
 
 goog.object.extend(exports, proto.v1);
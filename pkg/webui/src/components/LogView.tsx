@@ -63,6 +63,8 @@ type Chunk = Content | Phase;
 interface Content {
     type: "content"
     name: string
+    // parent is the name of the section this section is nested in
+    parent: string
     lines: string[]
     status: "running" | "done" | "failed" | "unknown"
 }
@@ -135,6 +137,7 @@ class LogViewImpl extends React.Component<LogViewProps, LogViewState> {
                     chunks.set(id, {
                         type: "content",
                         name: le.getName(),
+                        parent: le.getParent(),
                         lines: [],
                         status: "running",
                     });
@@ -149,6 +152,14 @@ class LogViewImpl extends React.Component<LogViewProps, LogViewState> {
                     break;
                 }
 
+                case LogSliceType.SLICE_DROPPED: {
+                    const chunk = chunks.get(id) as Content;
+                    if (!!chunk) {
+                        chunk.lines.push(`... ${le.getPayload()} bytes of log output dropped because the browser couldn't keep up`);
+                    }
+                    break;
+                }
+
                 case LogSliceType.SLICE_DONE: {
                     const chunk = chunks.get(id) as Content;
                     if (!!chunk) {
@@ -199,6 +210,7 @@ class LogViewImpl extends React.Component<LogViewProps, LogViewState> {
         const activeChunk = window.location.hash ? window.location.hash.substring(1) : undefined;
         
         const phases = chunks.map(c => c[1]).filter(c => isPhase(c));
+        const isNested = (id: string, chunk: Chunk) => isContent(chunk) && !!chunk.parent && this.chunks.has(this.parentID(id, chunk));
         return <React.Fragment>
             <Stepper className={classes.stepper} alternativeLabel activeStep={phases.length-1}>{ phases.map((c, i) => 
                 <Step key={i}>
@@ -209,34 +221,14 @@ class LogViewImpl extends React.Component<LogViewProps, LogViewState> {
             <StickyScroll>
             { chunks.map((kv, i) => {
                 const chunk = kv[1];
-                const isActiveChunk = kv[0] === activeChunk;
-                let activeChunkEl: HTMLElement | undefined;
-                if (isActiveChunk) {
-                    setTimeout(() => {
-                        if (!activeChunkEl) {
-                            return;
-                        }
-
-                        window.scrollTo({top: activeChunkEl.scrollHeight, behavior: 'smooth'});
-                    }, 100);
+                if (isNested(kv[0], chunk)) {
+                    // nested sections are rendered within their parent
+                    return undefined;
                 }
 
-                if (isContent(chunk) && !chunk.name.startsWith("werft:")) { return (
-                    <ExpansionPanel ref={(el: HTMLElement) => activeChunkEl = el} key={kv[0]} defaultExpanded={chunk.status === "failed" || isActiveChunk}>
-                        <ExpansionPanelSummary className={classes.sectionHeader} style={chunk.status === "failed" ? { color: ColorFailure} : {}}>
-                            { chunk.status === "done" && <DoneIcon /> }
-                            { chunk.status === "failed" && <ErrorIcon /> }
-                            { chunk.status === "running" && !this.props.finished && <CircularProgress style={{width:'24px', height:'24px'}} /> }
-                            { ((chunk.status === "running" && this.props.finished) || chunk.status === "unknown") && <DoneIcon style={{opacity:0.25}} /> }
-                            <span className={classes.sectionTitle}>{ chunk.name }</span>
-                            <span className={classes.sectionDesc} dangerouslySetInnerHTML={{__html: chunk.lines[chunk.lines.length - 1]}}></span>
-                            <a className={classes.sectionLink} href={`#${kv[0]}`}><LinkIcon /></a>
-                        </ExpansionPanelSummary>
-                        <ExpansionPanelDetails>
-                            <div className="term-container" style={{width:"100%"}} dangerouslySetInnerHTML={{__html: chunk.lines.join("<br />")}} />
-                        </ExpansionPanelDetails>
-                    </ExpansionPanel>
-                 )}
+                if (isContent(chunk) && !chunk.name.startsWith("werft:")) {
+                    return this.renderContent(kv[0], chunk, activeChunk);
+                }
                  if (isPhase(chunk)) { return (
                     <Typography
                         className={this.props.classes.dividerFullWidth}
@@ -256,6 +248,46 @@ class LogViewImpl extends React.Component<LogViewProps, LogViewState> {
         </React.Fragment>
     }
 
+    protected parentID(id: string, chunk: Content) {
+        return id.substring(0, id.length - chunk.name.length) + chunk.parent;
+    }
+
+    protected renderContent(id: string, chunk: Content, activeChunk?: string): React.ReactNode {
+        const classes = this.props.classes;
+        const isActiveChunk = id === activeChunk;
+        let activeChunkEl: HTMLElement | undefined;
+        if (isActiveChunk) {
+            setTimeout(() => {
+                if (!activeChunkEl) {
+                    return;
+                }
+
+                window.scrollTo({top: activeChunkEl.scrollHeight, behavior: 'smooth'});
+            }, 100);
+        }
+
+        const children = Array.from(this.chunks.entries())
+            .filter(([cid, c]) => isContent(c) && !!c.parent && c.parent === chunk.name && this.parentID(cid, c) === id);
+        const title = !!chunk.parent ? chunk.name.substring(chunk.parent.length + 1) : chunk.name;
+        return (
+            <ExpansionPanel ref={(el: HTMLElement) => activeChunkEl = el} key={id} defaultExpanded={chunk.status === "failed" || isActiveChunk}>
+                <ExpansionPanelSummary className={classes.sectionHeader} style={chunk.status === "failed" ? { color: ColorFailure} : {}}>
+                    { chunk.status === "done" && <DoneIcon /> }
+                    { chunk.status === "failed" && <ErrorIcon /> }
+                    { chunk.status === "running" && !this.props.finished && <CircularProgress style={{width:'24px', height:'24px'}} /> }
+                    { ((chunk.status === "running" && this.props.finished) || chunk.status === "unknown") && <DoneIcon style={{opacity:0.25}} /> }
+                    <span className={classes.sectionTitle}>{ title }</span>
+                    <span className={classes.sectionDesc} dangerouslySetInnerHTML={{__html: chunk.lines[chunk.lines.length - 1]}}></span>
+                    <a className={classes.sectionLink} href={`#${id}`}><LinkIcon /></a>
+                </ExpansionPanelSummary>
+                <ExpansionPanelDetails style={{flexDirection: "column"}}>
+                    <div className="term-container" style={{width:"100%"}} dangerouslySetInnerHTML={{__html: chunk.lines.join("<br />")}} />
+                    { children.map(([cid, c]) => this.renderContent(cid, c as Content, activeChunk)) }
+                </ExpansionPanelDetails>
+            </ExpansionPanel>
+        );
+    }
+
     render() {
         return <React.Fragment>
             { this.props.raw && this.renderRaw() }
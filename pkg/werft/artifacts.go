@@ -0,0 +1,114 @@
+package werft
+
+import (
+	"io"
+	"path"
+
+	"github.com/csweichel/werft/pkg/api/repoconfig"
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/auth"
+	"github.com/csweichel/werft/pkg/executor"
+	"github.com/csweichel/werft/pkg/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AnnotationPipeline is set on all jobs started by the same trigger, e.g. a job and the jobs it needs. It names the
+// job which was started and scopes the artifacts the jobs pass to each other.
+const AnnotationPipeline = "werft.pipeline"
+
+// pipelineOf returns the pipeline a job belongs to. Jobs which were started by themselves are their own pipeline.
+func pipelineOf(name string, md *v1.JobMetadata) string {
+	if md != nil {
+		for _, a := range md.Annotations {
+			if a.Key == AnnotationPipeline && a.Value != "" {
+				return a.Value
+			}
+		}
+	}
+	return name
+}
+
+// artifactKey returns the key of an artifact in the artifact store
+func (srv *Service) artifactKey(pipeline, artifact string) string {
+	return path.Join(srv.ArtifactsPrefix, pipeline, artifact+".tar.gz")
+}
+
+// jobArtifacts returns the artifacts of a job spec with their keys in the artifact store, or nil if the job has none
+func (srv *Service) jobArtifacts(name string, md *v1.JobMetadata, jobspec *repoconfig.JobSpec) *executor.Artifacts {
+	if jobspec.Artifacts == nil || (len(jobspec.Artifacts.Outputs) == 0 && len(jobspec.Artifacts.Inputs) == 0) {
+		return nil
+	}
+
+	pipeline := pipelineOf(name, md)
+	convert := func(specs []repoconfig.ArtifactSpec) []executor.Artifact {
+		var res []executor.Artifact
+		for _, a := range specs {
+			res = append(res, executor.Artifact{
+				Name: a.Name,
+				Path: a.ArtifactPath(),
+				Key:  srv.artifactKey(pipeline, a.Name),
+			})
+		}
+		return res
+	}
+	return &executor.Artifacts{
+		Outputs: convert(jobspec.Artifacts.Outputs),
+		Inputs:  convert(jobspec.Artifacts.Inputs),
+	}
+}
+
+// DownloadArtifact retrieves an artifact a job produced in chunks. The artifact is a gzipped tarball.
+func (srv *Service) DownloadArtifact(req *v1.DownloadArtifactRequest, resp v1.WerftService_DownloadArtifactServer) error {
+	job, err := srv.Jobs.Get(resp.Context(), req.Name)
+	if err == store.ErrNotFound {
+		return status.Errorf(codes.NotFound, "%s not found", req.Name)
+	}
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	err = srv.authorizeJob(resp.Context(), auth.ActionRead, job)
+	if err != nil {
+		return err
+	}
+
+	var found bool
+	for _, r := range job.Results {
+		if r.Type == executor.ResultTypeArtifact && r.Payload == req.Artifact {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return status.Errorf(codes.NotFound, "%s has no artifact %s", req.Name, req.Artifact)
+	}
+	if srv.Artifacts == nil {
+		return status.Error(codes.FailedPrecondition, "werft has no artifact store configured")
+	}
+
+	rd, err := srv.Artifacts.Get(resp.Context(), srv.artifactKey(pipelineOf(job.Name, job.Metadata), req.Artifact))
+	if err == store.ErrNotFound {
+		return status.Errorf(codes.NotFound, "artifact %s of %s is no longer available", req.Artifact, req.Name)
+	}
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	defer rd.Close()
+
+	buf := make([]byte, downloadChunkSize)
+	for {
+		n, err := rd.Read(buf)
+		if n > 0 {
+			serr := resp.Send(&v1.DownloadArtifactResponse{Data: buf[:n]})
+			if serr != nil {
+				return serr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+}
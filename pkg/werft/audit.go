@@ -0,0 +1,65 @@
+package werft
+
+import (
+	"context"
+	"time"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/audit"
+	"github.com/csweichel/werft/pkg/auth"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/status"
+)
+
+// auditLog records the outcome of a mutating operation
+func (srv *Service) auditLog(ctx context.Context, entry audit.Entry, md *v1.JobMetadata, err error) {
+	if srv.Audit == nil {
+		return
+	}
+
+	entry.Time = time.Now().UTC()
+	entry.Actor = auditActor(ctx)
+	if md != nil {
+		entry.Repo = repoLabel(md)
+		entry.Owner = md.Owner
+		entry.Trigger = triggerLabel(md)
+	}
+	entry.Success = err == nil
+	if err != nil {
+		entry.Error = status.Convert(err).Message()
+	}
+
+	// the request context might be done already, but the entry must be written nonetheless
+	lerr := srv.Audit.Log(context.Background(), entry)
+	if lerr != nil {
+		log.WithError(lerr).WithField("entry", entry).Error("cannot write audit log")
+	}
+}
+
+// auditActor returns who made a call: the plugin which called us, or the authenticated principal
+func auditActor(ctx context.Context) string {
+	if actor, ok := audit.ActorFromContext(ctx); ok {
+		return actor
+	}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		if principal == "" {
+			return "anonymous"
+		}
+		return principal
+	}
+	return "unauthenticated"
+}
+
+func startedJob(resp *v1.StartJobResponse) string {
+	if resp == nil || resp.Status == nil {
+		return ""
+	}
+	return resp.Status.Name
+}
+
+func jobMetadata(job *v1.JobStatus) *v1.JobMetadata {
+	if job == nil {
+		return nil
+	}
+	return job.Metadata
+}
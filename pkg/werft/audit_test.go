@@ -0,0 +1,77 @@
+package werft
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/audit"
+	"github.com/csweichel/werft/pkg/auth"
+	"github.com/csweichel/werft/pkg/store"
+)
+
+type recordingAuditLogger struct {
+	mu      sync.Mutex
+	Entries []audit.Entry
+}
+
+func (l *recordingAuditLogger) Log(ctx context.Context, entry audit.Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Entries = append(l.Entries, entry)
+	return nil
+}
+
+func TestAuditLog(t *testing.T) {
+	jobs := store.NewInMemoryJobStore()
+	md := &v1.JobMetadata{
+		Owner:      "alice",
+		Repository: &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: "main"},
+		Trigger:    v1.JobTrigger_TRIGGER_PUSH,
+	}
+	err := jobs.Store(context.Background(), v1.JobStatus{
+		Name:       "werft-build-main.1",
+		Phase:      v1.JobPhase_PHASE_DONE,
+		Metadata:   md,
+		Conditions: &v1.JobConditions{CanReplay: false},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := &recordingAuditLogger{}
+	srv := &Service{
+		Jobs:               jobs,
+		RepositoryProvider: NoopRepositoryProvider{},
+		Audit:              logger,
+	}
+
+	var (
+		api    = auth.WithPrincipal(context.Background(), "ci-bot")
+		plugin = audit.WithActor(context.Background(), "plugin:github-integration")
+	)
+	// none of these succeed: we have no executor and the repository provider cannot resolve anything
+	srv.StartGitHubJob(plugin, &v1.StartGitHubJobRequest{Metadata: md})
+	srv.StartFromPreviousJob(api, &v1.StartFromPreviousJobRequest{PreviousJob: "werft-build-main.1"})
+	srv.StopJob(api, &v1.StopJobRequest{Name: "werft-build-main.1"})
+	srv.StopJob(context.Background(), &v1.StopJobRequest{Name: "does-not-exist"})
+
+	expected := []audit.Entry{
+		{Actor: "plugin:github-integration", Action: audit.ActionStart, Repo: "github.com/csweichel/werft", Owner: "alice", Trigger: "push", Error: `cannot resolve request: "not supported"`},
+		{Actor: "ci-bot", Action: audit.ActionRestart, PreviousJob: "werft-build-main.1", Repo: "github.com/csweichel/werft", Owner: "alice", Trigger: "push", Error: "job werft-build-main.1 cannot be replayed: it was started from local or sideloaded content which was not retained"},
+		{Actor: "ci-bot", Action: audit.ActionStop, Job: "werft-build-main.1", Repo: "github.com/csweichel/werft", Owner: "alice", Trigger: "push", Error: "job is in unstoppable phase"},
+		{Actor: "unauthenticated", Action: audit.ActionStop, Job: "does-not-exist", Error: "does-not-exist not found"},
+	}
+	if len(logger.Entries) != len(expected) {
+		t.Fatalf("expected %d audit entries, got %d: %+v", len(expected), len(logger.Entries), logger.Entries)
+	}
+	for i, act := range logger.Entries {
+		if act.Time.IsZero() {
+			t.Errorf("entry %d has no time", i)
+		}
+		act.Time = expected[i].Time
+		if act != expected[i] {
+			t.Errorf("entry %d: expected %+v, got %+v", i, expected[i], act)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package werft
+
+import (
+	"context"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// authorize returns a PermissionDenied error if the caller may not perform the action on the jobs of the repository
+func (srv *Service) authorize(ctx context.Context, action auth.Action, repo *v1.Repository) error {
+	if srv.allowed(ctx, action, repo) {
+		return nil
+	}
+
+	principal, _ := auth.PrincipalFromContext(ctx)
+	if principal == "" {
+		principal = "anonymous"
+	}
+	var name string
+	if repo != nil {
+		name = repo.Owner + "/" + repo.Repo
+	}
+	return status.Errorf(codes.PermissionDenied, "%s may not %s jobs of %q", principal, action, name)
+}
+
+// allowed returns true if the caller may perform the action on the jobs of the repository
+func (srv *Service) allowed(ctx context.Context, action auth.Action, repo *v1.Repository) bool {
+	if srv.Policy == nil {
+		return true
+	}
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		// the call did not come through the API but e.g. from a plugin
+		return true
+	}
+	return srv.Policy.Allowed(principal, action, repo)
+}
+
+// authorizeJob returns a PermissionDenied error if the caller may not perform the action on the job
+func (srv *Service) authorizeJob(ctx context.Context, action auth.Action, job *v1.JobStatus) error {
+	return srv.authorize(ctx, action, jobRepository(job))
+}
+
+// canRead returns true if the caller may see the job
+func (srv *Service) canRead(ctx context.Context, job *v1.JobStatus) bool {
+	return srv.allowed(ctx, auth.ActionRead, jobRepository(job))
+}
+
+// readFilter returns a filter expression which matches the jobs the caller may read, or nil if the caller
+// may read all jobs. If the caller may read no jobs at all, none is true.
+func (srv *Service) readFilter(ctx context.Context) (filter *v1.FilterExpression, none bool) {
+	if srv.Policy == nil {
+		return nil, false
+	}
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	repos := srv.Policy.Repos(principal, auth.ActionRead)
+	if len(repos) == 0 {
+		return nil, true
+	}
+	return auth.RepoFilter(repos), false
+}
+
+func jobRepository(job *v1.JobStatus) *v1.Repository {
+	if job == nil || job.Metadata == nil {
+		return nil
+	}
+	return job.Metadata.Repository
+}
@@ -0,0 +1,125 @@
+package werft
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/auth"
+	"github.com/csweichel/werft/pkg/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var testPolicy = auth.StaticPolicy{
+	{Principals: []string{"team-a-*"}, Repos: []string{"github.com/team-a/*"}, Actions: []auth.Action{auth.ActionRead, auth.ActionStart}},
+	{Principals: []string{"*"}, Repos: []string{"github.com/public/*"}, Actions: []auth.Action{auth.ActionRead}},
+}
+
+func TestAuthorizeStartJob(t *testing.T) {
+	srv := &Service{
+		Jobs:               store.NewInMemoryJobStore(),
+		RepositoryProvider: NoopRepositoryProvider{},
+		Policy:             testPolicy,
+	}
+
+	tests := []struct {
+		Name    string
+		Context context.Context
+		Owner   string
+		Denied  bool
+	}{
+		{Name: "allowed", Context: auth.WithPrincipal(context.Background(), "team-a-bot"), Owner: "team-a"},
+		{Name: "other team's repo", Context: auth.WithPrincipal(context.Background(), "team-a-bot"), Owner: "team-b", Denied: true},
+		{Name: "other principal", Context: auth.WithPrincipal(context.Background(), "team-b-bot"), Owner: "team-a", Denied: true},
+		{Name: "read only", Context: auth.WithPrincipal(context.Background(), "team-a-bot"), Owner: "public", Denied: true},
+		{Name: "anonymous", Context: auth.WithPrincipal(context.Background(), ""), Owner: "team-a", Denied: true},
+		{Name: "plugin", Context: context.Background(), Owner: "team-b"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			_, err := srv.StartGitHubJob(test.Context, &v1.StartGitHubJobRequest{
+				Metadata: &v1.JobMetadata{
+					Owner:      "someone",
+					Repository: &v1.Repository{Owner: test.Owner, Repo: "werft", Ref: "main"},
+					Trigger:    v1.JobTrigger_TRIGGER_MANUAL,
+				},
+			})
+			// allowed calls fail later on because the noop repository provider cannot resolve the repo
+			if denied := status.Code(err) == codes.PermissionDenied; denied != test.Denied {
+				t.Errorf("expected denied=%v, got %v", test.Denied, err)
+			}
+		})
+	}
+}
+
+func TestAuthorizeListJobs(t *testing.T) {
+	jobs := store.NewInMemoryJobStore()
+	for _, j := range []struct{ Name, Owner string }{{"a1", "team-a"}, {"a2", "team-a"}, {"b1", "team-b"}, {"p1", "public"}} {
+		err := jobs.Store(context.Background(), v1.JobStatus{
+			Name:     j.Name,
+			Metadata: &v1.JobMetadata{Repository: &v1.Repository{Host: "github.com", Owner: j.Owner, Repo: "werft"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := &Service{Jobs: jobs, Policy: testPolicy}
+
+	tests := []struct {
+		Name    string
+		Context context.Context
+		Jobs    []string
+	}{
+		{Name: "team member", Context: auth.WithPrincipal(context.Background(), "team-a-bot"), Jobs: []string{"a1", "a2", "p1"}},
+		{Name: "other team", Context: auth.WithPrincipal(context.Background(), "team-b-bot"), Jobs: []string{"p1"}},
+		{Name: "anonymous", Context: auth.WithPrincipal(context.Background(), ""), Jobs: []string{"p1"}},
+		{Name: "plugin", Context: context.Background(), Jobs: []string{"a1", "a2", "b1", "p1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			resp, err := srv.ListJobs(test.Context, &v1.ListJobsRequest{
+				Order: []*v1.OrderExpression{{Field: "name", Ascending: true}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var act []string
+			for _, j := range resp.Result {
+				act = append(act, j.Name)
+			}
+			if len(act) != len(test.Jobs) || int(resp.Total) != len(test.Jobs) {
+				t.Fatalf("expected %v, got %v (total %d)", test.Jobs, act, resp.Total)
+			}
+			for i := range act {
+				if act[i] != test.Jobs[i] {
+					t.Errorf("expected %v, got %v", test.Jobs, act)
+					break
+				}
+			}
+
+			// the total counts only the jobs the caller may read, and pages are filled with them
+			resp, err = srv.ListJobs(test.Context, &v1.ListJobsRequest{
+				Order: []*v1.OrderExpression{{Field: "name", Ascending: true}},
+				Limit: 1,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if int(resp.Total) != len(test.Jobs) || len(resp.Result) != 1 || resp.Result[0].Name != test.Jobs[0] {
+				t.Errorf("expected the first of %v as page, got %v (total %d)", test.Jobs, resp.Result, resp.Total)
+			}
+
+			for _, name := range []string{"a1", "b1", "p1"} {
+				var visible bool
+				for _, j := range test.Jobs {
+					visible = visible || j == name
+				}
+				_, err = srv.GetJob(test.Context, &v1.GetJobRequest{Name: name})
+				if denied := status.Code(err) == codes.PermissionDenied; denied == visible {
+					t.Errorf("GetJob %s: expected visible=%v, got %v", name, visible, err)
+				}
+			}
+		})
+	}
+}
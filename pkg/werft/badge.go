@@ -0,0 +1,213 @@
+package werft
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultBadgeCacheTTL is the time a badge is served from cache before we ask the job store again
+const DefaultBadgeCacheTTL = 30 * time.Second
+
+// badgeLabel is the left-hand side of every badge
+const badgeLabel = "werft"
+
+// badge is the state a status badge shows
+type badge struct {
+	Message string
+	Color   string
+}
+
+var (
+	badgePassing = badge{Message: "passing", Color: "brightgreen"}
+	badgeFailing = badge{Message: "failing", Color: "red"}
+	badgeRunning = badge{Message: "running", Color: "blue"}
+	badgeSkipped = badge{Message: "skipped", Color: "lightgrey"}
+	badgeUnknown = badge{Message: "unknown", Color: "lightgrey"}
+)
+
+// badgeColors maps the shields.io color names we use to their hex value for our own SVG badges
+var badgeColors = map[string]string{
+	"brightgreen": "#4c1",
+	"red":         "#e05d44",
+	"blue":        "#007ec6",
+	"lightgrey":   "#9f9f9f",
+}
+
+// jobBadge produces the badge for the latest job. job is nil if there is none.
+func jobBadge(job *v1.JobStatus) badge {
+	if job == nil {
+		return badgeUnknown
+	}
+	if job.Phase != v1.JobPhase_PHASE_DONE {
+		return badgeRunning
+	}
+	if job.Conditions == nil {
+		return badgeFailing
+	}
+	if job.Conditions.Skipped {
+		return badgeSkipped
+	}
+	if job.Conditions.Success {
+		return badgePassing
+	}
+	return badgeFailing
+}
+
+type badgeCacheEntry struct {
+	Badge   badge
+	Expires time.Time
+}
+
+// BadgeHandler serves status badges of the latest job on a repository's ref, e.g. for a README.
+// GET /badge/<owner>/<repo>/<ref> responds with an SVG badge, adding ?format=json responds with
+// shields.io endpoint JSON instead. Badges are anonymous requests, hence they only show jobs
+// anonymous users may read.
+type BadgeHandler struct {
+	Service *Service
+	// TTL is the time badges are cached. Defaults to DefaultBadgeCacheTTL.
+	TTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]badgeCacheEntry
+}
+
+// NewBadgeHandler creates a new badge handler serving the jobs of the service
+func NewBadgeHandler(srv *Service) *BadgeHandler {
+	return &BadgeHandler{
+		Service: srv,
+		TTL:     DefaultBadgeCacheTTL,
+		cache:   make(map[string]badgeCacheEntry),
+	}
+}
+
+// ServeHTTP serves a badge. The handler expects the /badge/ prefix to be stripped from the path.
+func (h *BadgeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segs := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+	if len(segs) != 3 || segs[0] == "" || segs[1] == "" || segs[2] == "" {
+		http.Error(w, "badges are served on /badge/<owner>/<repo>/<ref>", http.StatusNotFound)
+		return
+	}
+	owner, repo, ref := segs[0], segs[1], segs[2]
+
+	b, err := h.badge(r.Context(), owner, repo, ref)
+	if err != nil {
+		log.WithError(err).WithField("repo", owner+"/"+repo).WithField("ref", ref).Warn("cannot produce badge")
+		http.Error(w, "cannot produce badge", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(h.ttl().Seconds())))
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(shieldsEndpoint(b))
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	fmt.Fprint(w, renderBadge(b))
+}
+
+func (h *BadgeHandler) ttl() time.Duration {
+	if h.TTL == 0 {
+		return DefaultBadgeCacheTTL
+	}
+	return h.TTL
+}
+
+// badge returns the badge of the latest job on the ref, from cache if we've looked it up recently
+func (h *BadgeHandler) badge(ctx context.Context, owner, repo, ref string) (badge, error) {
+	key := owner + "/" + repo + "/" + ref
+	h.mu.Lock()
+	entry, ok := h.cache[key]
+	h.mu.Unlock()
+	if ok && time.Now().Before(entry.Expires) {
+		return entry.Badge, nil
+	}
+
+	job, err := h.latestJob(ctx, owner, repo, ref)
+	if err != nil {
+		return badge{}, err
+	}
+	b := jobBadge(job)
+
+	h.mu.Lock()
+	if h.cache == nil {
+		h.cache = make(map[string]badgeCacheEntry)
+	}
+	now := time.Now()
+	for k, e := range h.cache {
+		if now.After(e.Expires) {
+			delete(h.cache, k)
+		}
+	}
+	h.cache[key] = badgeCacheEntry{Badge: b, Expires: now.Add(h.ttl())}
+	h.mu.Unlock()
+
+	return b, nil
+}
+
+// latestJob finds the latest job on the ref using the same logic as ListJobs' latest-per-group.
+// Short refs like main match their branch, i.e. refs/heads/main, too.
+func (h *BadgeHandler) latestJob(ctx context.Context, owner, repo, ref string) (*v1.JobStatus, error) {
+	refTerms := []*v1.FilterTerm{{Field: "repo.ref", Value: ref}}
+	if !strings.HasPrefix(ref, "refs/") {
+		refTerms = append(refTerms, &v1.FilterTerm{Field: "repo.ref", Value: "refs/heads/" + ref})
+	}
+	filter := []*v1.FilterExpression{
+		{Terms: []*v1.FilterTerm{{Field: "repo.owner", Value: owner}}},
+		{Terms: []*v1.FilterTerm{{Field: "repo.repo", Value: repo}}},
+		{Terms: refTerms},
+	}
+	group := []string{"repo.owner", "repo.repo"}
+	jobs, _, err := h.Service.Jobs.Find(ctx, filter, nil, group, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+
+	job := &jobs[0]
+	if !h.Service.canRead(auth.WithPrincipal(ctx, ""), job) {
+		return nil, nil
+	}
+	return job, nil
+}
+
+// shieldsEndpoint produces the shields.io endpoint JSON for a badge, see https://shields.io/endpoint
+func shieldsEndpoint(b badge) map[string]interface{} {
+	return map[string]interface{}{
+		"schemaVersion": 1,
+		"label":         badgeLabel,
+		"message":       b.Message,
+		"color":         b.Color,
+	}
+}
+
+// renderBadge produces a flat SVG badge. Its text is fixed, hence we estimate the text width instead of measuring it.
+func renderBadge(b badge) string {
+	const charWidth, padding = 7, 10
+	var (
+		labelWidth   = len(badgeLabel)*charWidth + padding
+		messageWidth = len(b.Message)*charWidth + padding
+		width        = labelWidth + messageWidth
+		title        = badgeLabel + ": " + b.Message
+	)
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[2]s"><title>%[2]s</title>`+
+		`<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`+
+		`<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>`+
+		`<g clip-path="url(#r)"><rect width="%[3]d" height="20" fill="#555"/><rect x="%[3]d" width="%[4]d" height="20" fill="%[5]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`+
+		`<text x="%[6]d" y="14">%[7]s</text><text x="%[8]d" y="14">%[9]s</text></g></svg>`,
+		width, title, labelWidth, messageWidth, badgeColors[b.Color],
+		labelWidth/2, badgeLabel, labelWidth+messageWidth/2, b.Message,
+	)
+}
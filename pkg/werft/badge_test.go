@@ -0,0 +1,218 @@
+package werft
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/store"
+	"github.com/golang/protobuf/ptypes"
+)
+
+func TestBadge(t *testing.T) {
+	type job struct {
+		Name       string
+		Owner      string
+		Ref        string
+		Phase      v1.JobPhase
+		Conditions *v1.JobConditions
+		Age        time.Duration
+	}
+	tests := []struct {
+		Name        string
+		Jobs        []job
+		Path        string
+		Policy      bool
+		Expectation badge
+	}{
+		{
+			Name:        "no job",
+			Path:        "/csweichel/werft/main",
+			Expectation: badgeUnknown,
+		},
+		{
+			Name:        "passing",
+			Jobs:        []job{{Name: "a", Ref: "refs/heads/main", Phase: v1.JobPhase_PHASE_DONE, Conditions: &v1.JobConditions{Success: true}}},
+			Path:        "/csweichel/werft/main",
+			Expectation: badgePassing,
+		},
+		{
+			Name:        "failing",
+			Jobs:        []job{{Name: "a", Ref: "refs/heads/main", Phase: v1.JobPhase_PHASE_DONE, Conditions: &v1.JobConditions{FailureCount: 1}}},
+			Path:        "/csweichel/werft/main",
+			Expectation: badgeFailing,
+		},
+		{
+			Name:        "skipped",
+			Jobs:        []job{{Name: "a", Ref: "refs/heads/main", Phase: v1.JobPhase_PHASE_DONE, Conditions: &v1.JobConditions{Success: true, Skipped: true}}},
+			Path:        "/csweichel/werft/main",
+			Expectation: badgeSkipped,
+		},
+		{
+			Name:        "running",
+			Jobs:        []job{{Name: "a", Ref: "refs/heads/main", Phase: v1.JobPhase_PHASE_RUNNING, Conditions: &v1.JobConditions{}}},
+			Path:        "/csweichel/werft/main",
+			Expectation: badgeRunning,
+		},
+		{
+			Name:        "queued",
+			Jobs:        []job{{Name: "a", Ref: "refs/heads/main", Phase: v1.JobPhase_PHASE_QUEUED}},
+			Path:        "/csweichel/werft/main",
+			Expectation: badgeRunning,
+		},
+		{
+			Name: "latest job wins",
+			Jobs: []job{
+				{Name: "a", Ref: "refs/heads/main", Phase: v1.JobPhase_PHASE_DONE, Conditions: &v1.JobConditions{Success: true}, Age: 2 * time.Hour},
+				{Name: "b", Ref: "refs/heads/main", Phase: v1.JobPhase_PHASE_DONE, Conditions: &v1.JobConditions{FailureCount: 1}, Age: time.Hour},
+			},
+			Path:        "/csweichel/werft/main",
+			Expectation: badgeFailing,
+		},
+		{
+			Name: "other refs don't count",
+			Jobs: []job{
+				{Name: "a", Ref: "refs/heads/main", Phase: v1.JobPhase_PHASE_DONE, Conditions: &v1.JobConditions{Success: true}, Age: 2 * time.Hour},
+				{Name: "b", Ref: "refs/heads/feature", Phase: v1.JobPhase_PHASE_DONE, Conditions: &v1.JobConditions{FailureCount: 1}, Age: time.Hour},
+			},
+			Path:        "/csweichel/werft/main",
+			Expectation: badgePassing,
+		},
+		{
+			Name:        "full ref",
+			Jobs:        []job{{Name: "a", Ref: "refs/tags/v0.1.0", Phase: v1.JobPhase_PHASE_DONE, Conditions: &v1.JobConditions{Success: true}}},
+			Path:        "/csweichel/werft/refs/tags/v0.1.0",
+			Expectation: badgePassing,
+		},
+		{
+			Name:        "anonymous users may not read",
+			Jobs:        []job{{Name: "a", Owner: "team-a", Ref: "refs/heads/main", Phase: v1.JobPhase_PHASE_DONE, Conditions: &v1.JobConditions{Success: true}}},
+			Path:        "/team-a/werft/main",
+			Policy:      true,
+			Expectation: badgeUnknown,
+		},
+		{
+			Name:        "anonymous users may read",
+			Jobs:        []job{{Name: "a", Owner: "public", Ref: "refs/heads/main", Phase: v1.JobPhase_PHASE_DONE, Conditions: &v1.JobConditions{Success: true}}},
+			Path:        "/public/werft/main",
+			Policy:      true,
+			Expectation: badgePassing,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			jobs := store.NewInMemoryJobStore()
+			for _, j := range test.Jobs {
+				owner := j.Owner
+				if owner == "" {
+					owner = "csweichel"
+				}
+				created, _ := ptypes.TimestampProto(time.Now().Add(-j.Age))
+				err := jobs.Store(context.Background(), v1.JobStatus{
+					Name:  j.Name,
+					Phase: j.Phase,
+					Metadata: &v1.JobMetadata{
+						Repository: &v1.Repository{Host: "github.com", Owner: owner, Repo: "werft", Ref: j.Ref},
+						Created:    created,
+					},
+					Conditions: j.Conditions,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			srv := &Service{Jobs: jobs}
+			if test.Policy {
+				srv.Policy = testPolicy
+			}
+			h := NewBadgeHandler(srv)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.Path+"?format=json", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+			}
+			var act struct {
+				SchemaVersion int    `json:"schemaVersion"`
+				Label         string `json:"label"`
+				Message       string `json:"message"`
+				Color         string `json:"color"`
+			}
+			err := json.Unmarshal(rec.Body.Bytes(), &act)
+			if err != nil {
+				t.Fatalf("cannot unmarshal badge: %v", err)
+			}
+			if act.SchemaVersion != 1 || act.Label != badgeLabel || act.Message != test.Expectation.Message || act.Color != test.Expectation.Color {
+				t.Errorf("unexpected badge: %+v, expected %+v", act, test.Expectation)
+			}
+
+			rec = httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.Path, nil))
+			if ct := rec.Header().Get("Content-Type"); ct != "image/svg+xml" {
+				t.Errorf("unexpected content type %q", ct)
+			}
+			svg := rec.Body.String()
+			if !strings.Contains(svg, ">"+test.Expectation.Message+"</text>") || !strings.Contains(svg, badgeColors[test.Expectation.Color]) {
+				t.Errorf("SVG badge does not show %+v: %s", test.Expectation, svg)
+			}
+		})
+	}
+}
+
+func TestBadgeCache(t *testing.T) {
+	jobs := store.NewInMemoryJobStore()
+	storeJob := func(phase v1.JobPhase) {
+		err := jobs.Store(context.Background(), v1.JobStatus{
+			Name:       "a",
+			Phase:      phase,
+			Metadata:   &v1.JobMetadata{Repository: &v1.Repository{Owner: "csweichel", Repo: "werft", Ref: "refs/heads/main"}},
+			Conditions: &v1.JobConditions{Success: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := NewBadgeHandler(&Service{Jobs: jobs})
+
+	storeJob(v1.JobPhase_PHASE_RUNNING)
+	b, err := h.badge(context.Background(), "csweichel", "werft", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b != badgeRunning {
+		t.Fatalf("unexpected badge %+v", b)
+	}
+
+	storeJob(v1.JobPhase_PHASE_DONE)
+	b, _ = h.badge(context.Background(), "csweichel", "werft", "main")
+	if b != badgeRunning {
+		t.Errorf("badge was not cached: %+v", b)
+	}
+
+	h.mu.Lock()
+	for k, e := range h.cache {
+		e.Expires = time.Now().Add(-time.Second)
+		h.cache[k] = e
+	}
+	h.mu.Unlock()
+	b, _ = h.badge(context.Background(), "csweichel", "werft", "main")
+	if b != badgePassing {
+		t.Errorf("expired badge was not refreshed: %+v", b)
+	}
+}
+
+func TestBadgeInvalidPath(t *testing.T) {
+	h := NewBadgeHandler(&Service{Jobs: store.NewInMemoryJobStore()})
+	for _, p := range []string{"/", "/csweichel", "/csweichel/werft", "/csweichel/werft/"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, p, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", p, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package werft
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/filterexpr"
+)
+
+// AnnotationFingerprint identifies the jobs started for the same commit, job spec and annotations.
+// It's set only if job deduplication is enabled, see Config.DeduplicateJobs.
+const AnnotationFingerprint = "werft.fingerprint"
+
+// jobFingerprint hashes everything that makes a job what it is: the repository and revision, the job spec and
+// the user-provided annotations. Two start requests with the same fingerprint would run the same job twice.
+func jobFingerprint(md *v1.JobMetadata, jobYAML []byte) string {
+	annotations := make([]string, 0, len(md.Annotations))
+	for _, a := range md.Annotations {
+		if strings.HasPrefix(a.Key, ReservedAnnotationPrefix) {
+			continue
+		}
+		annotations = append(annotations, a.Key+"="+a.Value)
+	}
+	sort.Strings(annotations)
+
+	h := sha256.New()
+	repo := md.Repository
+	fmt.Fprintf(h, "%s/%s/%s\n%s\n%s\n", repo.Host, repo.Owner, repo.Repo, repo.Revision, md.JobSpecName)
+	h.Write(jobYAML)
+	fmt.Fprintf(h, "\n%s", strings.Join(annotations, "\n"))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// claimFingerprint returns the job which has not finished yet and has the same fingerprint.
+// If there is no such job, the fingerprint is claimed until release is called, so that concurrent requests
+// for the same job wait until this one has started and attach to it. We claim the fingerprint before looking
+// for the job, hence only requests with the same fingerprint wait on each other while we query the store.
+func (srv *Service) claimFingerprint(ctx context.Context, md *v1.JobMetadata, fingerprint string) (existing *v1.JobStatus, release func(), err error) {
+	for {
+		srv.fingerprintsMu.Lock()
+		starting, ok := srv.fingerprints[fingerprint]
+		if !ok {
+			if srv.fingerprints == nil {
+				srv.fingerprints = make(map[string]chan struct{})
+			}
+			done := make(chan struct{})
+			srv.fingerprints[fingerprint] = done
+			srv.fingerprintsMu.Unlock()
+
+			release = func() {
+				srv.fingerprintsMu.Lock()
+				delete(srv.fingerprints, fingerprint)
+				srv.fingerprintsMu.Unlock()
+				close(done)
+			}
+
+			existing, err = srv.findUnfinishedJob(ctx, md, fingerprint)
+			if err != nil || existing != nil {
+				release()
+				return existing, nil, err
+			}
+			return nil, release, nil
+		}
+		srv.fingerprintsMu.Unlock()
+
+		// the same job is being started right now - once that's done we'll find it in the store
+		select {
+		case <-starting:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+}
+
+// findUnfinishedJob finds the job which has not finished yet and was started for the same fingerprint.
+// Jobs started alongside it, e.g. the jobs of a matrix or the jobs it needs, carry the same fingerprint,
+// hence we look for the job the request would have started.
+func (srv *Service) findUnfinishedJob(ctx context.Context, md *v1.JobMetadata, fingerprint string) (*v1.JobStatus, error) {
+	unfinished := &v1.FilterExpression{}
+	for _, p := range stoppablePhases {
+		unfinished.Terms = append(unfinished.Terms, &v1.FilterTerm{Field: "phase", Value: filterexpr.NormalizePhase(p.String()), Operation: v1.FilterOp_OP_EQUALS})
+	}
+	filter := []*v1.FilterExpression{
+		{Terms: []*v1.FilterTerm{{Field: "annotation." + AnnotationFingerprint, Value: fingerprint, Operation: v1.FilterOp_OP_EQUALS}}},
+		unfinished,
+	}
+	jobs, _, err := srv.Jobs.Find(ctx, filter, []*v1.OrderExpression{{Field: "created", Ascending: true}}, nil, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range jobs {
+		job := &jobs[i]
+		if job.Metadata == nil || job.Metadata.JobSpecName != md.JobSpecName {
+			continue
+		}
+		if _, isMatrixJob := matrixParent(job.Metadata); isMatrixJob {
+			continue
+		}
+		return job, nil
+	}
+	return nil, nil
+}
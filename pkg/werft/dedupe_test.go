@@ -0,0 +1,215 @@
+package werft
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/store"
+	"github.com/golang/protobuf/ptypes"
+)
+
+func TestJobFingerprint(t *testing.T) {
+	metadata := func(mod func(md *v1.JobMetadata)) *v1.JobMetadata {
+		md := &v1.JobMetadata{
+			Repository:  &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: "refs/heads/main", Revision: "abc"},
+			JobSpecName: "build",
+			Annotations: []*v1.Annotation{{Key: "version", Value: "1.0.0"}, {Key: "updateGitHubStatus", Value: "csweichel/werft"}},
+		}
+		if mod != nil {
+			mod(md)
+		}
+		return md
+	}
+	const spec = "pod:\n  containers: []\n"
+	reference := jobFingerprint(metadata(nil), []byte(spec))
+
+	tests := []struct {
+		Name     string
+		Metadata *v1.JobMetadata
+		Spec     string
+		Same     bool
+	}{
+		{Name: "identical", Metadata: metadata(nil), Spec: spec, Same: true},
+		{Name: "annotation order", Metadata: metadata(func(md *v1.JobMetadata) {
+			md.Annotations[0], md.Annotations[1] = md.Annotations[1], md.Annotations[0]
+		}), Spec: spec, Same: true},
+		{Name: "werft annotations", Metadata: metadata(func(md *v1.JobMetadata) {
+			md.Annotations = append(md.Annotations, &v1.Annotation{Key: AnnotationTraceID, Value: "trace"})
+		}), Spec: spec, Same: true},
+		{Name: "other ref same revision", Metadata: metadata(func(md *v1.JobMetadata) { md.Repository.Ref = "refs/tags/v1.0.0" }), Spec: spec, Same: true},
+		{Name: "revision", Metadata: metadata(func(md *v1.JobMetadata) { md.Repository.Revision = "def" }), Spec: spec},
+		{Name: "repo", Metadata: metadata(func(md *v1.JobMetadata) { md.Repository.Repo = "werft-fork" }), Spec: spec},
+		{Name: "job spec name", Metadata: metadata(func(md *v1.JobMetadata) { md.JobSpecName = "deploy" }), Spec: spec},
+		{Name: "job spec", Metadata: metadata(nil), Spec: spec + "sidecars: [db]\n"},
+		{Name: "annotation value", Metadata: metadata(func(md *v1.JobMetadata) { md.Annotations[0].Value = "1.0.1" }), Spec: spec},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			act := jobFingerprint(test.Metadata, []byte(test.Spec))
+			if same := act == reference; same != test.Same {
+				t.Errorf("expected same fingerprint: %v, got %v", test.Same, same)
+			}
+		})
+	}
+}
+
+func TestClaimFingerprint(t *testing.T) {
+	const fingerprint = "fp"
+	job := func(name string, phase v1.JobPhase, fingerprint string, annotations ...*v1.Annotation) v1.JobStatus {
+		return v1.JobStatus{
+			Name:  name,
+			Phase: phase,
+			Metadata: &v1.JobMetadata{
+				Repository:  &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Revision: "abc"},
+				JobSpecName: "build",
+				Created:     ptypes.TimestampNow(),
+				Annotations: append(annotations, &v1.Annotation{Key: AnnotationFingerprint, Value: fingerprint}),
+			},
+		}
+	}
+
+	tests := []struct {
+		Name        string
+		Jobs        []v1.JobStatus
+		Expectation string
+	}{
+		{Name: "no jobs"},
+		{Name: "running", Jobs: []v1.JobStatus{job("werft-build-main.1", v1.JobPhase_PHASE_RUNNING, fingerprint)}, Expectation: "werft-build-main.1"},
+		{Name: "queued", Jobs: []v1.JobStatus{job("werft-build-main.1", v1.JobPhase_PHASE_QUEUED, fingerprint)}, Expectation: "werft-build-main.1"},
+		{Name: "done", Jobs: []v1.JobStatus{job("werft-build-main.1", v1.JobPhase_PHASE_DONE, fingerprint)}},
+		{Name: "other fingerprint", Jobs: []v1.JobStatus{job("werft-build-main.1", v1.JobPhase_PHASE_RUNNING, "other")}},
+		{Name: "matrix job", Jobs: []v1.JobStatus{
+			job("werft-build-main.1-1", v1.JobPhase_PHASE_RUNNING, fingerprint, &v1.Annotation{Key: AnnotationMatrixParent, Value: "werft-build-main.1"}),
+		}},
+		{Name: "needed job", Jobs: []v1.JobStatus{
+			func() v1.JobStatus {
+				j := job("werft-test-main.1", v1.JobPhase_PHASE_RUNNING, fingerprint)
+				j.Metadata.JobSpecName = "test"
+				return j
+			}(),
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			ctx := context.Background()
+			srv := &Service{Jobs: store.NewInMemoryJobStore()}
+			for _, j := range test.Jobs {
+				err := srv.Jobs.Store(ctx, j)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			existing, release, err := srv.claimFingerprint(ctx, &v1.JobMetadata{JobSpecName: "build"}, fingerprint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var act string
+			if existing != nil {
+				act = existing.Name
+			}
+			if act != test.Expectation {
+				t.Errorf("unexpected existing job: expected %q, got %q", test.Expectation, act)
+			}
+			if (existing == nil) != (release != nil) {
+				t.Errorf("the fingerprint must be claimed if and only if there's no existing job")
+			}
+			if release != nil {
+				release()
+			}
+		})
+	}
+}
+
+func TestClaimFingerprintConcurrently(t *testing.T) {
+	var (
+		ctx = context.Background()
+		srv = &Service{Jobs: store.NewInMemoryJobStore()}
+		md  = &v1.JobMetadata{JobSpecName: "build"}
+	)
+	_, release, err := srv.claimFingerprint(ctx, md, "fp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attached := make(chan *v1.JobStatus)
+	go func() {
+		existing, _, err := srv.claimFingerprint(ctx, md, "fp")
+		if err != nil {
+			t.Error(err)
+		}
+		attached <- existing
+	}()
+
+	select {
+	case <-attached:
+		t.Fatal("a concurrent request must wait until the job it attaches to has started")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	err = srv.Jobs.Store(ctx, v1.JobStatus{
+		Name:     "werft-build-main.1",
+		Phase:    v1.JobPhase_PHASE_PREPARING,
+		Metadata: &v1.JobMetadata{JobSpecName: "build", Created: ptypes.TimestampNow(), Annotations: []*v1.Annotation{{Key: AnnotationFingerprint, Value: "fp"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+
+	select {
+	case existing := <-attached:
+		if existing == nil || existing.Name != "werft-build-main.1" {
+			t.Errorf("expected to attach to werft-build-main.1, got %v", existing)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("concurrent request did not attach to the started job")
+	}
+}
+
+// blockingJobStore blocks finding jobs with the fingerprint annotation until unblock is closed
+type blockingJobStore struct {
+	store.Jobs
+	fingerprint string
+	unblock     chan struct{}
+}
+
+func (s *blockingJobStore) Find(ctx context.Context, filter []*v1.FilterExpression, order []*v1.OrderExpression, group []string, start, limit int) ([]v1.JobStatus, int, error) {
+	if filter[0].Terms[0].Value == s.fingerprint {
+		<-s.unblock
+	}
+	return s.Jobs.Find(ctx, filter, order, group, start, limit)
+}
+
+func TestClaimFingerprintDoesNotBlockOthers(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		jobs = &blockingJobStore{Jobs: store.NewInMemoryJobStore(), fingerprint: "slow", unblock: make(chan struct{})}
+		srv  = &Service{Jobs: jobs}
+		md   = &v1.JobMetadata{JobSpecName: "build"}
+	)
+	defer close(jobs.unblock)
+
+	go srv.claimFingerprint(ctx, md, "slow")
+	time.Sleep(50 * time.Millisecond)
+
+	claimed := make(chan struct{})
+	go func() {
+		_, release, err := srv.claimFingerprint(ctx, md, "fast")
+		if err != nil {
+			t.Error(err)
+		}
+		if release != nil {
+			release()
+		}
+		close(claimed)
+	}()
+
+	select {
+	case <-claimed:
+	case <-time.After(time.Second):
+		t.Fatal("looking for the job of one fingerprint must not block claiming another")
+	}
+}
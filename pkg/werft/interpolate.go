@@ -0,0 +1,99 @@
+package werft
+
+import (
+	"bytes"
+	"strings"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"golang.org/x/xerrors"
+)
+
+// interpolationNamespaces are the variables and variable prefixes we interpolate. Everything else, e.g. ${HOME} in
+// a shell script, stays as it is.
+var interpolationNamespaces = []string{"job.", "repo.", "annotations.", "trigger", "owner"}
+
+// interpolationVars returns the variables job specs can reference using ${...}
+func interpolationVars(name string, md *v1.JobMetadata) map[string]string {
+	res := map[string]string{
+		"job.name": name,
+		"owner":    md.Owner,
+		"trigger":  strings.ToLower(strings.TrimPrefix(md.Trigger.String(), "TRIGGER_")),
+	}
+	if repo := md.Repository; repo != nil {
+		res["repo.host"] = repo.Host
+		res["repo.owner"] = repo.Owner
+		res["repo.repo"] = repo.Repo
+		res["repo.ref"] = repo.Ref
+		res["repo.revision"] = repo.Revision
+	}
+	for _, a := range md.Annotations {
+		res["annotations."+a.Key] = a.Value
+	}
+	return res
+}
+
+// interpolate replaces ${variable} in the job spec with the value of the variable. ${variable:-default} uses the
+// default if the variable is undefined, otherwise undefined variables are an error. $${variable} produces a literal ${variable}.
+func interpolate(spec []byte, vars map[string]string) ([]byte, error) {
+	var (
+		res  = bytes.NewBuffer(make([]byte, 0, len(spec)))
+		line = 1
+	)
+	for i := 0; i < len(spec); i++ {
+		c := spec[i]
+		if c == '\n' {
+			line++
+		}
+		if c != '$' || i+1 >= len(spec) {
+			res.WriteByte(c)
+			continue
+		}
+		if bytes.HasPrefix(spec[i+1:], []byte("${")) {
+			res.WriteString("${")
+			i += 2
+			continue
+		}
+		if spec[i+1] != '{' {
+			res.WriteByte(c)
+			continue
+		}
+		end := bytes.IndexAny(spec[i+2:], "}\n")
+		if end < 0 || spec[i+2+end] != '}' {
+			res.WriteByte(c)
+			continue
+		}
+
+		expr := string(spec[i+2 : i+2+end])
+		name, def, hasDefault := expr, "", false
+		if idx := strings.Index(expr, ":-"); idx >= 0 {
+			name, def, hasDefault = expr[:idx], expr[idx+2:], true
+		}
+		if !isInterpolated(name) {
+			res.WriteByte(c)
+			continue
+		}
+
+		val, ok := vars[name]
+		if !ok && !hasDefault {
+			return nil, xerrors.Errorf("undefined variable ${%s} on line %d: use ${%s:-default} to provide a default or $${%s} for a literal", name, line, name, name)
+		}
+		if !ok {
+			val = def
+		}
+		res.WriteString(val)
+		i += 2 + end
+	}
+	return res.Bytes(), nil
+}
+
+func isInterpolated(name string) bool {
+	for _, ns := range interpolationNamespaces {
+		if strings.HasSuffix(ns, ".") && strings.HasPrefix(name, ns) && len(name) > len(ns) {
+			return true
+		}
+		if name == ns {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package werft
+
+import (
+	"strings"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+)
+
+func TestInterpolate(t *testing.T) {
+	md := &v1.JobMetadata{
+		Owner: "alice",
+		Repository: &v1.Repository{
+			Host:     "github.com",
+			Owner:    "csweichel",
+			Repo:     "werft",
+			Ref:      "refs/heads/main",
+			Revision: "8f3a2b1",
+		},
+		Trigger: v1.JobTrigger_TRIGGER_PUSH,
+		Annotations: []*v1.Annotation{
+			{Key: "version", Value: "1.2.3"},
+			{Key: "empty", Value: ""},
+		},
+	}
+	vars := interpolationVars("werft-build-main.1", md)
+
+	tests := []struct {
+		Name        string
+		Spec        string
+		Expectation string
+		Err         string
+	}{
+		{Name: "job name", Spec: "name: ${job.name}", Expectation: "name: werft-build-main.1"},
+		{Name: "owner", Spec: "owner: ${owner}", Expectation: "owner: alice"},
+		{Name: "trigger", Spec: "trigger: ${trigger}", Expectation: "trigger: push"},
+		{Name: "repo host", Spec: "${repo.host}", Expectation: "github.com"},
+		{Name: "repo owner", Spec: "${repo.owner}", Expectation: "csweichel"},
+		{Name: "repo repo", Spec: "${repo.repo}", Expectation: "werft"},
+		{Name: "repo ref", Spec: "${repo.ref}", Expectation: "refs/heads/main"},
+		{Name: "repo revision", Spec: "${repo.revision}", Expectation: "8f3a2b1"},
+		{Name: "annotation", Spec: "image: werft:${annotations.version}", Expectation: "image: werft:1.2.3"},
+		{Name: "empty annotation", Spec: "[${annotations.empty:-default}]", Expectation: "[]"},
+		{Name: "several on one line", Spec: "${repo.owner}/${repo.repo}@${repo.revision}", Expectation: "csweichel/werft@8f3a2b1"},
+		{Name: "default", Spec: "${annotations.missing:-latest}", Expectation: "latest"},
+		{Name: "empty default", Spec: "[${annotations.missing:-}]", Expectation: "[]"},
+		{Name: "escaped", Spec: "echo $${repo.ref}", Expectation: "echo ${repo.ref}"},
+		{Name: "shell variables", Spec: "echo ${HOME} $PATH $$ ${#ARR[@]}", Expectation: "echo ${HOME} $PATH $$ ${#ARR[@]}"},
+		{Name: "unterminated", Spec: "echo ${repo.ref\n}", Expectation: "echo ${repo.ref\n}"},
+		{Name: "undefined annotation", Spec: "a: b\nimage: ${annotations.missing}", Err: "undefined variable ${annotations.missing} on line 2"},
+		{Name: "undefined repo field", Spec: "${repo.branch}", Err: "undefined variable ${repo.branch} on line 1"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			act, err := interpolate([]byte(test.Spec), vars)
+			if test.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.Err) {
+					t.Fatalf("expected error %q, got %v", test.Err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(act) != test.Expectation {
+				t.Errorf("expected %q, got %q", test.Expectation, string(act))
+			}
+		})
+	}
+}
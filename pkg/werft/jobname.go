@@ -0,0 +1,143 @@
+package werft
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"golang.org/x/xerrors"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// jobCounterVar stands for the number of a job in a job name template. The number makes names unique,
+// hence it's appended to names whose template doesn't end with it.
+const jobCounterVar = "${job.counter}"
+
+// maxJobNameLength is the length of a job name without its number. Job names end up in label values,
+// which must not be longer than 63 characters - we leave room for up to 9999 jobs of the same name.
+const maxJobNameLength = 58
+
+// validateJobNameTemplate returns an error if a job name template cannot produce unique names
+func validateJobNameTemplate(tpl string) error {
+	if strings.TrimSpace(tpl) == "" {
+		return xerrors.Errorf("job name template is empty")
+	}
+	idx := strings.Index(tpl, jobCounterVar)
+	if idx < 0 {
+		return nil
+	}
+	if idx != len(tpl)-len(jobCounterVar) {
+		return xerrors.Errorf("invalid job name template %q: %s must come last", tpl, jobCounterVar)
+	}
+	// without a separator, foo1 with number 2 and foo with number 12 would have the same name
+	if !strings.HasSuffix(tpl[:idx], "-") && !strings.HasSuffix(tpl[:idx], ".") {
+		return xerrors.Errorf("invalid job name template %q: %s must follow - or .", tpl, jobCounterVar)
+	}
+	return nil
+}
+
+// jobNameVars returns the variables job name templates can reference using ${...}
+func jobNameVars(md *v1.JobMetadata, jobSpecName string) map[string]string {
+	res := interpolationVars("", md)
+	delete(res, "job.name")
+	res["job.spec"] = jobSpecName
+	if repo := md.Repository; repo != nil {
+		ref := strings.TrimPrefix(repo.Ref, "refs/heads/")
+		ref = strings.TrimPrefix(ref, "refs/tags/")
+		res["repo.shortRef"] = ref
+
+		rev := repo.Revision
+		if len(rev) > 7 {
+			rev = rev[:7]
+		}
+		res["repo.shortRevision"] = rev
+	}
+	return res
+}
+
+// renderJobName renders a job name template. It returns the name without the job number, and the separator
+// between name and number, e.g. - for "${repo.repo}-${job.counter}". Names of templates which don't end in
+// ${job.counter} are separated from their number by a dot.
+func renderJobName(tpl string, md *v1.JobMetadata, jobSpecName string) (name, sep string, err error) {
+	err = validateJobNameTemplate(tpl)
+	if err != nil {
+		return "", "", err
+	}
+
+	sep = "."
+	if strings.HasSuffix(tpl, jobCounterVar) {
+		tpl = strings.TrimSuffix(tpl, jobCounterVar)
+		sep, tpl = tpl[len(tpl)-1:], tpl[:len(tpl)-1]
+	}
+
+	res, err := interpolate([]byte(tpl), jobNameVars(md, jobSpecName))
+	if err != nil {
+		return "", "", xerrors.Errorf("cannot render job name template %q: %w", tpl, err)
+	}
+	return sanitizeJobName(string(res)), sep, nil
+}
+
+// sanitizeJobName turns a name into a valid DNS-1123 subdomain of at most maxJobNameLength characters.
+// Characters which are not allowed become dashes.
+func sanitizeJobName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.Map(func(r rune) rune {
+		if ('a' <= r && r <= 'z') || ('0' <= r && r <= '9') || r == '.' || r == '-' {
+			return r
+		}
+		return '-'
+	}, name)
+	name = trimJobNameSegments(name)
+	if len(name) > maxJobNameLength {
+		name = trimJobNameSegments(name[:maxJobNameLength])
+	}
+	if name == "" {
+		return "unknown"
+	}
+	return name
+}
+
+// trimJobNameSegments removes leading and trailing dashes and empty segments from the dot-separated segments of a name
+func trimJobNameSegments(name string) string {
+	var segs []string
+	for _, seg := range strings.Split(name, ".") {
+		seg = strings.Trim(seg, "-")
+		if seg == "" {
+			continue
+		}
+		segs = append(segs, seg)
+	}
+	return strings.Join(segs, ".")
+}
+
+// validateJobName returns an error if a job name cannot be used for the job's pod and labels
+func validateJobName(name string) error {
+	errs := append(validation.IsDNS1123Subdomain(name), validation.IsValidLabelValue(name)...)
+	if len(errs) > 0 {
+		return xerrors.Errorf("invalid job name %q: %s", name, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// newTemplatedJobName builds the name of a new job from the job name template of its repository
+func (srv *Service) newTemplatedJobName(tpl string, md *v1.JobMetadata, jobSpecName, nameSuffix string) (string, error) {
+	name, sep, err := renderJobName(tpl, md, jobSpecName)
+	if err != nil {
+		return "", err
+	}
+	if nameSuffix != "" {
+		name = sanitizeJobName(name + "-" + nameSuffix)
+	}
+
+	t, err := srv.Groups.Next(name)
+	if err != nil {
+		return "", err
+	}
+	name = fmt.Sprintf("%s%s%d", name, sep, t)
+
+	err = validateJobName(name)
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
@@ -0,0 +1,117 @@
+package werft
+
+import (
+	"strings"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/store"
+)
+
+type testNumberGroup map[string]int
+
+func (g testNumberGroup) Latest(group string) (int, error) {
+	nr, ok := g[group]
+	if !ok {
+		return 0, store.ErrNotFound
+	}
+	return nr, nil
+}
+
+func (g testNumberGroup) Next(group string) (int, error) {
+	g[group]++
+	return g[group], nil
+}
+
+func TestRenderJobName(t *testing.T) {
+	md := &v1.JobMetadata{
+		Owner:       "Codertocat",
+		Trigger:     v1.JobTrigger_TRIGGER_PUSH,
+		Repository:  &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: "refs/heads/feature/Fancy_Stuff", Revision: "c0ffee1234567890"},
+		Annotations: []*v1.Annotation{{Key: "team", Value: "payments"}},
+	}
+	tests := []struct {
+		Name      string
+		Template  string
+		Expected  string
+		Separator string
+		Error     string
+	}{
+		{Name: "counter", Template: "${repo.repo}-${repo.shortRef}-${repo.shortRevision}-${job.counter}", Expected: "werft-feature-fancy-stuff-c0ffee1", Separator: "-"},
+		{Name: "dotted counter", Template: "${repo.repo}-${job.spec}.${job.counter}", Expected: "werft-build", Separator: "."},
+		{Name: "no counter", Template: "${annotations.team}-${trigger}", Expected: "payments-push", Separator: "."},
+		{Name: "full ref", Template: "${repo.ref}", Expected: "refs-heads-feature-fancy-stuff", Separator: "."},
+		{Name: "default", Template: "${owner}-${annotations.version:-latest}", Expected: "codertocat-latest", Separator: "."},
+		{Name: "undefined variable", Template: "${annotations.version}", Error: "undefined variable ${annotations.version}"},
+		{Name: "counter not last", Template: "${job.counter}-${repo.repo}", Error: "${job.counter} must come last"},
+		{Name: "counter without separator", Template: "${repo.repo}${job.counter}", Error: "${job.counter} must follow - or ."},
+		{Name: "empty", Template: " ", Error: "job name template is empty"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			name, sep, err := renderJobName(test.Template, md, "build")
+			if test.Error != "" {
+				if err == nil || !strings.Contains(err.Error(), test.Error) {
+					t.Fatalf("expected error containing %q, got %v", test.Error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != test.Expected || sep != test.Separator {
+				t.Errorf("unexpected name: %q (separator %q), expected %q (separator %q)", name, sep, test.Expected, test.Separator)
+			}
+		})
+	}
+}
+
+func TestSanitizeJobName(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Expected string
+	}{
+		{"werft-main", "werft-main"},
+		{"Werft_Feature/Foo@Bar", "werft-feature-foo-bar"},
+		{"-werft-", "werft"},
+		{"werft-.main.-1", "werft.main.1"},
+		{"werft..main", "werft.main"},
+		{"ünïcödé", "n-c-d"},
+		{"", "unknown"},
+		{"---", "unknown"},
+		// cut off at maxJobNameLength, which must not leave a trailing dash
+		{strings.Repeat("a", 57) + "-bcd", strings.Repeat("a", 57)},
+	}
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			act := sanitizeJobName(test.Input)
+			if act != test.Expected {
+				t.Errorf("unexpected name: %q, expected %q", act, test.Expected)
+			}
+			if err := validateJobName(act + ".9999"); err != nil {
+				t.Errorf("sanitized name is invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewTemplatedJobName(t *testing.T) {
+	srv := &Service{Groups: make(testNumberGroup)}
+	md := &v1.JobMetadata{Repository: &v1.Repository{Repo: "werft", Ref: "refs/heads/main", Revision: "c0ffee1234567890"}}
+
+	var names []string
+	for _, suffix := range []string{"", "", "nightly"} {
+		name, err := srv.newTemplatedJobName("${repo.repo}-${repo.shortRef}-${job.counter}", md, "build", suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	expected := []string{"werft-main-1", "werft-main-2", "werft-main-nightly-1"}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("unexpected names: %v, expected %v", names, expected)
+			break
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package werft
+
+import (
+	"strconv"
+	"sync"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+)
+
+// defaultLogBufferSize is the number of bytes of log slices we buffer per listener unless configured otherwise
+const defaultLogBufferSize = 1 << 20
+
+// LogBufferConfig configures the buffer between reading the log of a job and sending it to a client listening to it
+type LogBufferConfig struct {
+	// Size is the number of bytes of log slices buffered per listener. Defaults to 1MiB.
+	Size int `yaml:"size,omitempty"`
+
+	// DropWhenFull drops log content while a listener's buffer is full, rather than waiting for the listener
+	// to catch up. Listeners receive a SLICE_DROPPED marker which says how many bytes they missed.
+	DropWhenFull bool `yaml:"dropWhenFull,omitempty"`
+}
+
+// logBuffer decouples reading a job's log from a listener receiving it. Each listener reads the log on its own,
+// hence a slow listener never holds up writing the log or other listeners. If the buffer is full, we either wait
+// for the listener to catch up (backpressure), or drop content and tell the listener how much it missed.
+type logBuffer struct {
+	size int
+	drop bool
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	slices []*v1.LogSliceEvent
+	bytes  int
+	closed bool
+	abort  bool
+
+	// dropped counts the bytes dropped per slice since we last told the listener
+	dropped      map[string]int
+	droppedOrder []string
+}
+
+func newLogBuffer(cfg LogBufferConfig) *logBuffer {
+	size := cfg.Size
+	if size <= 0 {
+		size = defaultLogBufferSize
+	}
+	b := &logBuffer{
+		size:    size,
+		drop:    cfg.DropWhenFull,
+		dropped: make(map[string]int),
+	}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+func sliceSize(evt *v1.LogSliceEvent) int {
+	return len(evt.Name) + len(evt.Parent) + len(evt.Payload)
+}
+
+// Put adds a slice to the buffer. Content which doesn't fit is dropped if we drop content, all other slices wait
+// until the listener caught up. Returns false if the listener is gone.
+func (b *logBuffer) Put(evt *v1.LogSliceEvent) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for !b.abort && !b.fits(evt) {
+		if b.drop && evt.Type == v1.LogSliceType_SLICE_CONTENT {
+			if _, exists := b.dropped[evt.Name]; !exists {
+				b.droppedOrder = append(b.droppedOrder, evt.Name)
+			}
+			b.dropped[evt.Name] += len(evt.Payload)
+			return true
+		}
+		b.cond.Wait()
+	}
+	if b.abort {
+		return false
+	}
+
+	b.flushDropped()
+	b.append(evt)
+	return true
+}
+
+// fits returns true if the slice fits into the buffer. An empty buffer takes any slice, no matter its size.
+func (b *logBuffer) fits(evt *v1.LogSliceEvent) bool {
+	return len(b.slices) == 0 || b.bytes+sliceSize(evt) <= b.size
+}
+
+// flushDropped adds the markers for the content we dropped. Callers must hold the lock.
+func (b *logBuffer) flushDropped() {
+	for _, name := range b.droppedOrder {
+		b.append(&v1.LogSliceEvent{
+			Name:    name,
+			Type:    v1.LogSliceType_SLICE_DROPPED,
+			Payload: strconv.Itoa(b.dropped[name]),
+		})
+		delete(b.dropped, name)
+	}
+	b.droppedOrder = nil
+}
+
+func (b *logBuffer) append(evt *v1.LogSliceEvent) {
+	b.slices = append(b.slices, evt)
+	b.bytes += sliceSize(evt)
+	b.cond.Broadcast()
+}
+
+// Get returns the next slice, waiting for one if the buffer is empty.
+// Returns nil once the buffer is closed and empty, or if the listener is gone.
+func (b *logBuffer) Get() *v1.LogSliceEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for !b.abort && !b.closed && len(b.slices) == 0 {
+		b.cond.Wait()
+	}
+	if b.abort || len(b.slices) == 0 {
+		return nil
+	}
+
+	evt := b.slices[0]
+	b.slices[0] = nil
+	b.slices = b.slices[1:]
+	b.bytes -= sliceSize(evt)
+	b.cond.Broadcast()
+	return evt
+}
+
+// Close marks the end of the log. The listener receives what's left in the buffer, including markers for
+// the content we dropped last.
+func (b *logBuffer) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.flushDropped()
+	b.closed = true
+	b.cond.Broadcast()
+}
+
+// Abort stops passing slices to the listener, e.g. because it's gone
+func (b *logBuffer) Abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.abort = true
+	b.cond.Broadcast()
+}
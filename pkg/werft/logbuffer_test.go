@@ -0,0 +1,204 @@
+package werft
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/store"
+	"google.golang.org/grpc"
+)
+
+func contentSlice(name string, i int) *v1.LogSliceEvent {
+	return &v1.LogSliceEvent{Name: name, Type: v1.LogSliceType_SLICE_CONTENT, Payload: fmt.Sprintf("line %03d", i)}
+}
+
+func TestLogBufferBackpressure(t *testing.T) {
+	const lines = 50
+	buf := newLogBuffer(LogBufferConfig{Size: 100})
+
+	put := make(chan int, lines)
+	go func() {
+		defer buf.Close()
+		for i := 0; i < lines; i++ {
+			buf.Put(contentSlice("build", i))
+			put <- i
+		}
+	}()
+
+	// a slow consumer holds up the producer once the buffer is full
+	time.Sleep(50 * time.Millisecond)
+	if n := len(put); n >= lines/2 {
+		t.Errorf("producer must wait for the consumer, but put %d slices", n)
+	}
+
+	var i int
+	for evt := buf.Get(); evt != nil; evt = buf.Get() {
+		if evt.Payload != fmt.Sprintf("line %03d", i) {
+			t.Fatalf("unexpected slice %d: %v", i, evt)
+		}
+		i++
+	}
+	if i != lines {
+		t.Errorf("expected %d slices, got %d", lines, i)
+	}
+}
+
+func TestLogBufferDropWhenFull(t *testing.T) {
+	buf := newLogBuffer(LogBufferConfig{Size: 100, DropWhenFull: true})
+
+	// nobody reads while the content is written, yet the producer is never held up by content
+	content := make(chan struct{})
+	go func() {
+		buf.Put(&v1.LogSliceEvent{Name: "build", Type: v1.LogSliceType_SLICE_START})
+		for i := 0; i < 50; i++ {
+			buf.Put(contentSlice("build", i))
+		}
+		close(content)
+		buf.Put(&v1.LogSliceEvent{Name: "build", Type: v1.LogSliceType_SLICE_DONE})
+		buf.Close()
+	}()
+	select {
+	case <-content:
+	case <-time.After(time.Second):
+		t.Fatal("producer was held up by the consumer")
+	}
+
+	var (
+		received int
+		dropped  int
+		types    []v1.LogSliceType
+	)
+	for evt := buf.Get(); evt != nil; evt = buf.Get() {
+		switch evt.Type {
+		case v1.LogSliceType_SLICE_CONTENT:
+			received += len(evt.Payload)
+		case v1.LogSliceType_SLICE_DROPPED:
+			n, err := strconv.Atoi(evt.Payload)
+			if err != nil {
+				t.Fatalf("invalid dropped marker: %v", evt)
+			}
+			if evt.Name != "build" {
+				t.Errorf("dropped marker must name the slice whose content was dropped: %v", evt)
+			}
+			dropped += n
+		default:
+			types = append(types, evt.Type)
+		}
+	}
+	if dropped == 0 {
+		t.Errorf("expected content to be dropped")
+	}
+	if total := 50 * len("line 000"); received+dropped != total {
+		t.Errorf("received and dropped bytes must add up to the log: %d + %d != %d", received, dropped, total)
+	}
+	if len(types) != 2 || types[0] != v1.LogSliceType_SLICE_START || types[1] != v1.LogSliceType_SLICE_DONE {
+		t.Errorf("slices other than content must never be dropped: %v", types)
+	}
+}
+
+func TestLogBufferAbort(t *testing.T) {
+	buf := newLogBuffer(LogBufferConfig{Size: 10})
+	buf.Put(contentSlice("build", 0))
+
+	res := make(chan bool)
+	go func() {
+		res <- buf.Put(contentSlice("build", 1))
+	}()
+	buf.Abort()
+	select {
+	case ok := <-res:
+		if ok {
+			t.Errorf("put must fail once the listener is gone")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("abort did not release the producer")
+	}
+	if evt := buf.Get(); evt != nil {
+		t.Errorf("expected no slice once the listener is gone, got %v", evt)
+	}
+}
+
+type slowListenServer struct {
+	grpc.ServerStream
+	Release chan struct{}
+	Slices  []*v1.LogSliceEvent
+}
+
+func (s *slowListenServer) Context() context.Context {
+	return context.Background()
+}
+
+func (s *slowListenServer) Send(resp *v1.ListenResponse) error {
+	<-s.Release
+	if slice := resp.GetSlice(); slice != nil {
+		s.Slices = append(s.Slices, slice)
+	}
+	return nil
+}
+
+func TestListenSlowConsumer(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), "tlsc")
+	if err != nil {
+		t.Fatalf("cannot create test folder: %v", err)
+	}
+	defer os.RemoveAll(base)
+	logs, err := store.NewFileLogStore(base)
+	if err != nil {
+		t.Fatalf("cannot create log store: %v", err)
+	}
+	w, err := logs.Open("foo")
+	if err != nil {
+		t.Fatalf("cannot place log: %v", err)
+	}
+	w.Write([]byte(strings.Repeat("[build] compiling a rather chatty package\n", 1000)))
+	w.Close()
+
+	jobs := store.NewInMemoryJobStore()
+	err = jobs.Store(context.Background(), v1.JobStatus{Name: "foo", Phase: v1.JobPhase_PHASE_DONE})
+	if err != nil {
+		t.Fatalf("cannot store job: %v", err)
+	}
+	srv := &Service{Logs: logs, Jobs: jobs, Config: Config{LogBuffer: LogBufferConfig{Size: 1024, DropWhenFull: true}}}
+
+	// the listener is stuck while the log is read
+	resp := &slowListenServer{Release: make(chan struct{})}
+	errchan := make(chan error, 1)
+	go func() {
+		errchan <- srv.Listen(&v1.ListenRequest{Name: "foo", Logs: v1.ListenRequestLogs_LOGS_RAW}, resp)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	close(resp.Release)
+
+	select {
+	case err := <-errchan:
+		if err != nil {
+			t.Fatalf("Listen failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Listen did not return")
+	}
+
+	var received, dropped int
+	for _, s := range resp.Slices {
+		switch s.Type {
+		case v1.LogSliceType_SLICE_CONTENT:
+			received++
+		case v1.LogSliceType_SLICE_DROPPED:
+			n, _ := strconv.Atoi(s.Payload)
+			dropped += n
+		}
+	}
+	if dropped == 0 || received == 0 || received >= 1000 {
+		t.Errorf("expected the stuck listener to miss some content: received %d lines, dropped %d bytes", received, dropped)
+	}
+	if total := 1000 * len("compiling a rather chatty package"); received*len("compiling a rather chatty package")+dropped != total {
+		t.Errorf("received and dropped bytes must add up to the log: %d lines + %d bytes != %d bytes", received, dropped, total)
+	}
+}
@@ -0,0 +1,411 @@
+package werft
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/csweichel/werft/pkg/api/repoconfig"
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/store"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// AnnotationMatrixParent is set on the jobs a matrix expands into and names the matrix job they belong to
+	AnnotationMatrixParent = "werft.matrixParent"
+	// AnnotationMatrixJobs is set on matrix jobs and lists the jobs the matrix expanded into, separated by comma
+	AnnotationMatrixJobs = "werft.matrixJobs"
+	// AnnotationMatrixPrefix prefixes the annotations which carry the matrix values of a job, e.g. werft.matrix.go=1.17
+	AnnotationMatrixPrefix = "werft.matrix."
+
+	// matrixEnvPrefix prefixes the environment variables which carry the matrix values, e.g. WERFT_MATRIX_GO=1.17
+	matrixEnvPrefix = "WERFT_MATRIX_"
+)
+
+// runMatrix starts one job per combination of the matrix values. The matrix job itself has no pod,
+// but reflects the status of its jobs.
+func (srv *Service) runMatrix(ctx context.Context, name string, metadata v1.JobMetadata, cp ContentProvider, jobYAML []byte, jobspec *repoconfig.JobSpec, canReplay bool, waitUntil time.Time) (*v1.JobStatus, error) {
+	ctx = srv.startJobTrace(ctx, name, &metadata)
+	if metadata.Created == nil {
+		metadata.Created = ptypes.TimestampNow()
+	}
+	status := &v1.JobStatus{
+		Name:       name,
+		Metadata:   &metadata,
+		Phase:      v1.JobPhase_PHASE_RUNNING,
+		Conditions: &v1.JobConditions{CanReplay: canReplay},
+	}
+
+	if canReplay {
+		err := srv.Jobs.StoreJobSpec(name, jobYAML)
+		if err != nil {
+			log.WithError(err).Warn("cannot store job YAML - job will not be replayable")
+		}
+	}
+
+	combinations, err := jobspec.ExpandMatrix()
+	if err == nil {
+		switch cp.(type) {
+		case *LocalContentProvider, *SideloadingContentProvider:
+			// local content is uploaded once, hence can't serve more than one job
+			err = xerrors.Errorf("matrix jobs cannot run on local content")
+		}
+	}
+	if err != nil {
+		err = xerrors.Errorf("cannot handle job for %s: %w", name, err)
+		status.Phase = v1.JobPhase_PHASE_DONE
+		status.Conditions.FailureCount = 1
+		status.Details = err.Error()
+		metadata.Finished = ptypes.TimestampNow()
+		srv.storeMatrixJob(status)
+		return nil, err
+	}
+
+	jobs := make([]string, len(combinations))
+	for i := range combinations {
+		jobs[i] = fmt.Sprintf("%s-%d", name, i+1)
+	}
+	metadata.Annotations = setAnnotation(metadata.Annotations, AnnotationMatrixJobs, strings.Join(jobs, ","))
+	aggregateMatrixStatus(status, make([]*v1.JobStatus, len(jobs)))
+	srv.storeMatrixJob(status)
+
+	logs, err := srv.Logs.Open(name)
+	if err == nil {
+		fmt.Fprintf(logs, "[werft] starting %d jobs\n", len(jobs))
+		for i, job := range jobs {
+			fmt.Fprintf(logs, "[werft] %s: %s\n", job, formatMatrixValues(combinations[i]))
+		}
+		logs.Close()
+	} else {
+		log.WithError(err).WithField("name", name).Warn("cannot write matrix job log")
+	}
+
+	for i, job := range jobs {
+		md := proto.Clone(&metadata).(*v1.JobMetadata)
+		md.Created = nil
+		md.Started = nil
+		md.Finished = nil
+		var annotations []*v1.Annotation
+		for _, a := range md.Annotations {
+			if a.Key != AnnotationMatrixJobs {
+				annotations = append(annotations, a)
+			}
+		}
+		annotations = setAnnotation(annotations, AnnotationMatrixParent, name)
+		for k, v := range combinations[i] {
+			annotations = setAnnotation(annotations, AnnotationMatrixPrefix+k, v)
+		}
+		md.Annotations = annotations
+
+		// a job which fails to start is stored as failed, which the matrix job reflects
+		_, err := srv.RunJob(ctx, job, *md, cp, jobYAML, canReplay, waitUntil)
+		if err != nil {
+			log.WithError(err).WithField("name", job).WithField("matrix", name).Warn("cannot start matrix job")
+		}
+	}
+
+	return srv.updateMatrixJob(name)
+}
+
+// storeMatrixJob saves the matrix job and tells our Listen subscribers about it
+func (srv *Service) storeMatrixJob(status *v1.JobStatus) {
+	err := srv.Jobs.Store(context.Background(), *status)
+	if err != nil {
+		log.WithError(err).WithField("name", status.Name).Warn("cannot store job")
+	}
+	srv.traceJobUpdate(status)
+	<-srv.events.Emit("job", status)
+}
+
+// updateMatrixJob recomputes the status of a matrix job from the status of its jobs
+func (srv *Service) updateMatrixJob(name string) (*v1.JobStatus, error) {
+	srv.matrixMu.Lock()
+	ctx := context.Background()
+	status, err := srv.Jobs.Get(ctx, name)
+	if err != nil {
+		srv.matrixMu.Unlock()
+		return nil, xerrors.Errorf("cannot update matrix job %s: %w", name, err)
+	}
+
+	jobs := matrixJobs(status)
+	children := make([]*v1.JobStatus, len(jobs))
+	for i, job := range jobs {
+		child, err := srv.Jobs.Get(ctx, job)
+		if err == store.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			srv.matrixMu.Unlock()
+			return nil, xerrors.Errorf("cannot update matrix job %s: %w", name, err)
+		}
+		children[i] = child
+	}
+	aggregateMatrixStatus(status, children)
+
+	err = srv.Jobs.Store(ctx, *status)
+	srv.matrixMu.Unlock()
+	if err != nil {
+		return nil, xerrors.Errorf("cannot update matrix job %s: %w", name, err)
+	}
+	srv.traceJobUpdate(status)
+	<-srv.events.Emit("job", status)
+	srv.jobDone(status)
+
+	return status, nil
+}
+
+// aggregateMatrixStatus computes the status of a matrix job from the status of its jobs, which are nil if we
+// don't know them yet. The matrix job is done once all of its jobs are, and succeeds only if all of them succeed.
+func aggregateMatrixStatus(status *v1.JobStatus, children []*v1.JobStatus) {
+	var (
+		done, failed, skipped int
+		started, finished     time.Time
+	)
+	for _, c := range children {
+		if c != nil && c.Metadata != nil && c.Metadata.Started != nil {
+			// the matrix job started once the first of its jobs did
+			if t, err := ptypes.Timestamp(c.Metadata.Started); err == nil && (started.IsZero() || t.Before(started)) {
+				started = t
+			}
+		}
+		if c == nil || c.Phase != v1.JobPhase_PHASE_DONE {
+			continue
+		}
+		done++
+		if c.Conditions == nil || !c.Conditions.Success {
+			failed++
+		}
+		if c.Conditions != nil && c.Conditions.Skipped {
+			skipped++
+		}
+		if c.Metadata == nil {
+			continue
+		}
+		if t, err := ptypes.Timestamp(c.Metadata.Finished); err == nil && t.After(finished) {
+			finished = t
+		}
+	}
+
+	if status.Conditions == nil {
+		status.Conditions = &v1.JobConditions{}
+	}
+	if status.Metadata != nil && !started.IsZero() {
+		status.Metadata.Started, _ = ptypes.TimestampProto(started)
+	}
+	status.Conditions.FailureCount = int32(failed)
+	if done < len(children) {
+		status.Phase = v1.JobPhase_PHASE_RUNNING
+		status.Conditions.Success = false
+		status.Details = fmt.Sprintf("%d of %d jobs done, %d failed", done, len(children), failed)
+		if status.Metadata != nil {
+			// retried jobs make a matrix job which was done run again
+			status.Metadata.Finished = nil
+		}
+		return
+	}
+
+	status.Phase = v1.JobPhase_PHASE_DONE
+	status.Conditions.Success = failed == 0
+	status.Conditions.Skipped = skipped == len(children)
+	status.Details = fmt.Sprintf("%d of %d jobs succeeded", done-failed, len(children))
+	if finished.IsZero() {
+		finished = time.Now()
+	}
+	if status.Metadata != nil {
+		status.Metadata.Finished, _ = ptypes.TimestampProto(finished)
+	}
+}
+
+// matrixRetry is a failed job of a matrix job which is about to be restarted
+type matrixRetry struct {
+	Name        string
+	PreviousJob string
+	Metadata    *v1.JobMetadata
+	JobYAML     []byte
+}
+
+// retryMatrixJobs replaces the failed jobs of a matrix job with new ones, which the caller must start. The matrix job
+// reflects the new jobs right away, hence is running until they're done.
+func (srv *Service) retryMatrixJobs(ctx context.Context, name string) ([]matrixRetry, error) {
+	srv.matrixMu.Lock()
+	defer srv.matrixMu.Unlock()
+
+	parent, err := srv.Jobs.Get(ctx, name)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if parent.Phase != v1.JobPhase_PHASE_DONE {
+		return nil, status.Errorf(codes.FailedPrecondition, "job %s is still running - wait for it to finish before retrying its failed jobs", name)
+	}
+
+	var (
+		jobs     = matrixJobs(parent)
+		children = make([]*v1.JobStatus, len(jobs))
+		retries  []matrixRetry
+	)
+	for i, job := range jobs {
+		child, err := srv.Jobs.Get(ctx, job)
+		if err == store.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if child.Conditions != nil && child.Conditions.Success {
+			children[i] = child
+			continue
+		}
+
+		jobYAML, err := srv.Jobs.GetJobSpec(job)
+		if err == store.ErrNotFound {
+			return nil, status.Errorf(codes.FailedPrecondition, "job %s cannot be retried: its job spec was not retained", job)
+		}
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		md := proto.Clone(child.Metadata).(*v1.JobMetadata)
+		md.Created = nil
+		md.Started = nil
+		md.Finished = nil
+		md.Annotations = setAnnotation(md.Annotations, AnnotationRestartedFrom, job)
+		md.Annotations = removeAnnotation(md.Annotations, AnnotationNeeds)
+
+		retry := matrixRetryName(name, i, job)
+		jobs[i] = retry
+		retries = append(retries, matrixRetry{Name: retry, PreviousJob: job, Metadata: md, JobYAML: jobYAML})
+	}
+	if len(retries) == 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "job %s has no failed jobs", name)
+	}
+
+	parent.Metadata.Annotations = setAnnotation(parent.Metadata.Annotations, AnnotationMatrixJobs, strings.Join(jobs, ","))
+	aggregateMatrixStatus(parent, children)
+	err = srv.Jobs.Store(ctx, *parent)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return retries, nil
+}
+
+// matrixRetryName names the job which replaces the i-th job of a matrix job, e.g. werft-build-main.1-2.1 for the
+// first retry of werft-build-main.1-2 and werft-build-main.1-2.2 for the retry of that one.
+func matrixRetryName(parent string, i int, previous string) string {
+	job := fmt.Sprintf("%s-%d", parent, i+1)
+	attempt, err := strconv.Atoi(strings.TrimPrefix(previous, job+"."))
+	if err != nil {
+		attempt = 0
+	}
+	return fmt.Sprintf("%s.%d", job, attempt+1)
+}
+
+// stopMatrix stops all jobs of a matrix job which haven't finished yet
+func (srv *Service) stopMatrix(ctx context.Context, jobs []string, reason string) error {
+	for _, job := range jobs {
+		child, err := srv.Jobs.Get(ctx, job)
+		if err == store.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if child.Phase == v1.JobPhase_PHASE_DONE || child.Phase == v1.JobPhase_PHASE_CLEANUP {
+			continue
+		}
+
+		err = srv.Executor.Stop(job, reason)
+		if err != nil {
+			return xerrors.Errorf("cannot stop %s: %w", job, err)
+		}
+	}
+	return nil
+}
+
+// matrixJobs returns the jobs a matrix job expanded into, or nil if the job isn't a matrix job
+func matrixJobs(job *v1.JobStatus) []string {
+	if job == nil || job.Metadata == nil {
+		return nil
+	}
+	for _, a := range job.Metadata.Annotations {
+		if a.Key == AnnotationMatrixJobs && a.Value != "" {
+			return strings.Split(a.Value, ",")
+		}
+	}
+	return nil
+}
+
+// matrixParent returns the matrix job a job belongs to
+func matrixParent(md *v1.JobMetadata) (name string, ok bool) {
+	if md == nil {
+		return "", false
+	}
+	for _, a := range md.Annotations {
+		if a.Key == AnnotationMatrixParent {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// matrixValues returns the matrix values of a job, which is empty for jobs which don't belong to a matrix
+func matrixValues(md *v1.JobMetadata) map[string]string {
+	res := make(map[string]string)
+	for _, a := range md.Annotations {
+		if strings.HasPrefix(a.Key, AnnotationMatrixPrefix) {
+			res[strings.TrimPrefix(a.Key, AnnotationMatrixPrefix)] = a.Value
+		}
+	}
+	return res
+}
+
+func formatMatrixValues(values map[string]string) string {
+	vars := make([]string, 0, len(values))
+	for k := range values {
+		vars = append(vars, k)
+	}
+	sort.Strings(vars)
+
+	res := make([]string, len(vars))
+	for i, k := range vars {
+		res[i] = k + "=" + values[k]
+	}
+	return strings.Join(res, " ")
+}
+
+// applyMatrixValues exposes the matrix values as environment variables to all containers but the sidecars
+func applyMatrixValues(podspec *corev1.PodSpec, values map[string]string, sidecars []string) {
+	if len(values) == 0 {
+		return
+	}
+
+	vars := make([]string, 0, len(values))
+	for k := range values {
+		vars = append(vars, k)
+	}
+	sort.Strings(vars)
+	env := make([]corev1.EnvVar, len(vars))
+	for i, k := range vars {
+		env[i] = corev1.EnvVar{Name: matrixEnvPrefix + strings.ToUpper(k), Value: values[k]}
+	}
+
+	isSidecar := make(map[string]struct{}, len(sidecars))
+	for _, s := range sidecars {
+		isSidecar[s] = struct{}{}
+	}
+	for i, c := range podspec.Containers {
+		if _, ok := isSidecar[c.Name]; ok {
+			continue
+		}
+		podspec.Containers[i].Env = append(c.Env, env...)
+	}
+}
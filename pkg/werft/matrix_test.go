@@ -0,0 +1,338 @@
+package werft
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/store"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestAggregateMatrixStatus(t *testing.T) {
+	var (
+		succeeded = &v1.JobStatus{Phase: v1.JobPhase_PHASE_DONE, Conditions: &v1.JobConditions{Success: true}}
+		failed    = &v1.JobStatus{Phase: v1.JobPhase_PHASE_DONE, Conditions: &v1.JobConditions{Success: false}}
+		running   = &v1.JobStatus{Phase: v1.JobPhase_PHASE_RUNNING, Conditions: &v1.JobConditions{}}
+	)
+	tests := []struct {
+		Name         string
+		Children     []*v1.JobStatus
+		Phase        v1.JobPhase
+		Success      bool
+		FailureCount int32
+	}{
+		{Name: "all succeeded", Children: []*v1.JobStatus{succeeded, succeeded, succeeded}, Phase: v1.JobPhase_PHASE_DONE, Success: true},
+		{Name: "one failed", Children: []*v1.JobStatus{succeeded, failed, succeeded}, Phase: v1.JobPhase_PHASE_DONE, FailureCount: 1},
+		{Name: "all failed", Children: []*v1.JobStatus{failed, failed}, Phase: v1.JobPhase_PHASE_DONE, FailureCount: 2},
+		{Name: "still running", Children: []*v1.JobStatus{succeeded, running}, Phase: v1.JobPhase_PHASE_RUNNING},
+		{Name: "failed while running", Children: []*v1.JobStatus{failed, running}, Phase: v1.JobPhase_PHASE_RUNNING, FailureCount: 1},
+		{Name: "not started yet", Children: []*v1.JobStatus{succeeded, nil}, Phase: v1.JobPhase_PHASE_RUNNING},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			// the matrix job might have been done before some of its jobs were retried
+			status := &v1.JobStatus{Name: "matrix", Metadata: &v1.JobMetadata{Finished: ptypes.TimestampNow()}}
+			aggregateMatrixStatus(status, test.Children)
+
+			if status.Phase != test.Phase {
+				t.Errorf("unexpected phase: expected %v, got %v", test.Phase, status.Phase)
+			}
+			if status.Conditions.Success != test.Success {
+				t.Errorf("unexpected success: expected %v, got %v", test.Success, status.Conditions.Success)
+			}
+			if status.Conditions.FailureCount != test.FailureCount {
+				t.Errorf("unexpected failure count: expected %d, got %d", test.FailureCount, status.Conditions.FailureCount)
+			}
+			if done := status.Phase == v1.JobPhase_PHASE_DONE; done != (status.Metadata.Finished != nil) {
+				t.Errorf("finished must be set only once the matrix job is done: %v", status.Metadata.Finished)
+			}
+		})
+	}
+}
+
+func TestAggregateMatrixStarted(t *testing.T) {
+	var (
+		first   = &timestamp.Timestamp{Seconds: 1000}
+		second  = &timestamp.Timestamp{Seconds: 2000}
+		running = &v1.JobStatus{Phase: v1.JobPhase_PHASE_RUNNING, Metadata: &v1.JobMetadata{Started: second}, Conditions: &v1.JobConditions{}}
+		done    = &v1.JobStatus{Phase: v1.JobPhase_PHASE_DONE, Metadata: &v1.JobMetadata{Started: first, Finished: second}, Conditions: &v1.JobConditions{Success: true}}
+		waiting = &v1.JobStatus{Phase: v1.JobPhase_PHASE_WAITING, Metadata: &v1.JobMetadata{}, Conditions: &v1.JobConditions{}}
+	)
+
+	status := &v1.JobStatus{Name: "matrix", Metadata: &v1.JobMetadata{}}
+	aggregateMatrixStatus(status, []*v1.JobStatus{waiting, nil})
+	if status.Metadata.Started != nil {
+		t.Errorf("matrix job must not start before any of its jobs did: %v", status.Metadata.Started)
+	}
+
+	aggregateMatrixStatus(status, []*v1.JobStatus{running, done})
+	if !proto.Equal(status.Metadata.Started, first) {
+		t.Errorf("matrix job must start with its first job: expected %v, got %v", first, status.Metadata.Started)
+	}
+	if status.Metadata.Finished != nil {
+		t.Errorf("running matrix job must not be completed: %v", status.Metadata.Finished)
+	}
+}
+
+func TestUpdateMatrixJob(t *testing.T) {
+	ctx := context.Background()
+	jobs := store.NewInMemoryJobStore()
+	srv := &Service{Jobs: jobs}
+
+	parent := v1.JobStatus{
+		Name:  "werft-build-main.1",
+		Phase: v1.JobPhase_PHASE_RUNNING,
+		Metadata: &v1.JobMetadata{
+			Created:     ptypes.TimestampNow(),
+			Annotations: []*v1.Annotation{{Key: AnnotationMatrixJobs, Value: "werft-build-main.1-1,werft-build-main.1-2"}},
+		},
+		Conditions: &v1.JobConditions{CanReplay: true},
+	}
+	child := func(name string, phase v1.JobPhase, success bool) v1.JobStatus {
+		return v1.JobStatus{
+			Name:  name,
+			Phase: phase,
+			Metadata: &v1.JobMetadata{
+				Finished:    ptypes.TimestampNow(),
+				Annotations: []*v1.Annotation{{Key: AnnotationMatrixParent, Value: parent.Name}},
+			},
+			Conditions: &v1.JobConditions{Success: success},
+		}
+	}
+	for _, j := range []v1.JobStatus{
+		parent,
+		child("werft-build-main.1-1", v1.JobPhase_PHASE_DONE, true),
+		child("werft-build-main.1-2", v1.JobPhase_PHASE_RUNNING, false),
+	} {
+		if err := jobs.Store(ctx, j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	status, err := srv.updateMatrixJob(parent.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Phase != v1.JobPhase_PHASE_RUNNING {
+		t.Errorf("matrix job must run while one of its jobs does, but is %v", status.Phase)
+	}
+
+	if err := jobs.Store(ctx, child("werft-build-main.1-2", v1.JobPhase_PHASE_DONE, true)); err != nil {
+		t.Fatal(err)
+	}
+	_, err = srv.updateMatrixJob(parent.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := jobs.Get(ctx, parent.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Phase != v1.JobPhase_PHASE_DONE || !stored.Conditions.Success {
+		t.Errorf("matrix job must succeed once all of its jobs have, but is %v: %s", stored.Phase, stored.Details)
+	}
+	if !stored.Conditions.CanReplay {
+		t.Errorf("matrix job lost its replay condition")
+	}
+}
+
+// matrixRepositoryProvider provides content for jobs which never need it
+type matrixRepositoryProvider struct {
+	NoopRepositoryProvider
+}
+
+func (matrixRepositoryProvider) ContentProvider(ctx context.Context, repo *v1.Repository) (ContentProvider, error) {
+	return nil, nil
+}
+
+func TestRetryPipeline(t *testing.T) {
+	const (
+		// the jobs have no pod, hence they fail to start unless they're skipped
+		failingSpec = "when: 'trigger == \"push\"'\n"
+		skippedSpec = "when: 'trigger == \"manual\"'\n"
+	)
+	var (
+		ctx  = context.Background()
+		jobs = store.NewInMemoryJobStore()
+		srv  = &Service{Jobs: jobs, Logs: store.NewInMemoryLogStore(), RepositoryProvider: matrixRepositoryProvider{}}
+		repo = &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: "refs/heads/main"}
+	)
+	parent := v1.JobStatus{
+		Name:  "werft-build-main.1",
+		Phase: v1.JobPhase_PHASE_DONE,
+		Metadata: &v1.JobMetadata{
+			Repository:  repo,
+			Trigger:     v1.JobTrigger_TRIGGER_PUSH,
+			Created:     ptypes.TimestampNow(),
+			Finished:    ptypes.TimestampNow(),
+			Annotations: []*v1.Annotation{{Key: AnnotationMatrixJobs, Value: "werft-build-main.1-1,werft-build-main.1-2,werft-build-main.1-3"}},
+		},
+		Conditions: &v1.JobConditions{CanReplay: true, FailureCount: 2},
+	}
+	child := func(name string, success bool, spec string) {
+		err := jobs.Store(ctx, v1.JobStatus{
+			Name:  name,
+			Phase: v1.JobPhase_PHASE_DONE,
+			Metadata: &v1.JobMetadata{
+				Repository: repo,
+				Trigger:    v1.JobTrigger_TRIGGER_PUSH,
+				Finished:   ptypes.TimestampNow(),
+				Annotations: []*v1.Annotation{
+					{Key: AnnotationMatrixParent, Value: parent.Name},
+					{Key: AnnotationMatrixPrefix + "go", Value: name},
+				},
+			},
+			Conditions: &v1.JobConditions{Success: success, CanReplay: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = jobs.StoreJobSpec(name, []byte(spec))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := jobs.Store(ctx, parent); err != nil {
+		t.Fatal(err)
+	}
+	child("werft-build-main.1-1", true, skippedSpec)
+	child("werft-build-main.1-2", false, skippedSpec)
+	child("werft-build-main.1-3", false, failingSpec)
+
+	resp, err := srv.RetryPipeline(ctx, &v1.RetryPipelineRequest{Parent: parent.Name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*v1.RetriedJob{
+		{PreviousJob: "werft-build-main.1-2", Name: "werft-build-main.1-2.1"},
+		{PreviousJob: "werft-build-main.1-3", Name: "werft-build-main.1-3.1"},
+	}
+	if len(resp.Retried) != len(expected) {
+		t.Fatalf("unexpected retried jobs: %v", resp.Retried)
+	}
+	for i := range expected {
+		if !proto.Equal(resp.Retried[i], expected[i]) {
+			t.Errorf("unexpected retried job: expected %v, got %v", expected[i], resp.Retried[i])
+		}
+	}
+	if exp := "werft-build-main.1-1,werft-build-main.1-2.1,werft-build-main.1-3.1"; strings.Join(matrixJobs(resp.Status), ",") != exp {
+		t.Errorf("unexpected matrix jobs: expected %s, got %v", exp, matrixJobs(resp.Status))
+	}
+	if resp.Status.Phase != v1.JobPhase_PHASE_DONE || resp.Status.Conditions.Success || resp.Status.Conditions.FailureCount != 1 {
+		t.Errorf("matrix job must fail while one of its jobs does, but is %v: %s", resp.Status.Phase, resp.Status.Details)
+	}
+
+	retried, err := jobs.Get(ctx, "werft-build-main.1-2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := matrixParent(retried.Metadata); p != parent.Name {
+		t.Errorf("retried job must belong to the matrix job, but belongs to %q", p)
+	}
+	if v := matrixValues(retried.Metadata)["go"]; v != "werft-build-main.1-2" {
+		t.Errorf("retried job must keep its matrix values, got %q", v)
+	}
+	var restartedFrom string
+	for _, a := range retried.Metadata.Annotations {
+		if a.Key == AnnotationRestartedFrom {
+			restartedFrom = a.Value
+		}
+	}
+	if restartedFrom != "werft-build-main.1-2" {
+		t.Errorf("retried job must be restarted from its previous job, got %q", restartedFrom)
+	}
+
+	// retrying again restarts only the job which failed once more
+	err = jobs.StoreJobSpec("werft-build-main.1-3.1", []byte(skippedSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = srv.RetryPipeline(ctx, &v1.RetryPipelineRequest{Parent: parent.Name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Retried) != 1 || resp.Retried[0].Name != "werft-build-main.1-3.2" {
+		t.Errorf("unexpected retried jobs: %v", resp.Retried)
+	}
+	stored, err := jobs.Get(ctx, parent.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Phase != v1.JobPhase_PHASE_DONE || !stored.Conditions.Success || stored.Conditions.FailureCount != 0 {
+		t.Errorf("matrix job must succeed once all of its jobs have, but is %v: %s", stored.Phase, stored.Details)
+	}
+
+	_, err = srv.RetryPipeline(ctx, &v1.RetryPipelineRequest{Parent: parent.Name})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("retrying a matrix job without failed jobs must fail, got %v", err)
+	}
+}
+
+func TestRetryPipelineInvalid(t *testing.T) {
+	ctx := context.Background()
+	jobs := store.NewInMemoryJobStore()
+	srv := &Service{Jobs: jobs, Logs: store.NewInMemoryLogStore(), RepositoryProvider: matrixRepositoryProvider{}}
+	for _, j := range []v1.JobStatus{
+		{
+			Name:       "werft-build-main.1",
+			Phase:      v1.JobPhase_PHASE_DONE,
+			Metadata:   &v1.JobMetadata{},
+			Conditions: &v1.JobConditions{},
+		},
+		{
+			Name:       "werft-build-main.2",
+			Phase:      v1.JobPhase_PHASE_RUNNING,
+			Metadata:   &v1.JobMetadata{Annotations: []*v1.Annotation{{Key: AnnotationMatrixJobs, Value: "werft-build-main.2-1"}}},
+			Conditions: &v1.JobConditions{},
+		},
+	} {
+		if err := jobs.Store(ctx, j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		Name   string
+		Parent string
+		Code   codes.Code
+	}{
+		{Name: "unknown job", Parent: "werft-build-main.3", Code: codes.NotFound},
+		{Name: "no matrix", Parent: "werft-build-main.1", Code: codes.FailedPrecondition},
+		{Name: "running", Parent: "werft-build-main.2", Code: codes.FailedPrecondition},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			_, err := srv.RetryPipeline(ctx, &v1.RetryPipelineRequest{Parent: test.Parent})
+			if status.Code(err) != test.Code {
+				t.Errorf("expected %v, got %v", test.Code, err)
+			}
+		})
+	}
+}
+
+func TestApplyMatrixValues(t *testing.T) {
+	podspec := &corev1.PodSpec{
+		Containers: []corev1.Container{{Name: "build"}, {Name: "db"}},
+	}
+	md := &v1.JobMetadata{Annotations: []*v1.Annotation{
+		{Key: AnnotationMatrixParent, Value: "werft-build-main.1"},
+		{Key: AnnotationMatrixPrefix + "go", Value: "1.17"},
+		{Key: AnnotationMatrixPrefix + "os", Value: "linux"},
+	}}
+	applyMatrixValues(podspec, matrixValues(md), []string{"db"})
+
+	env := podspec.Containers[0].Env
+	if len(env) != 2 || env[0].Name != "WERFT_MATRIX_GO" || env[0].Value != "1.17" || env[1].Name != "WERFT_MATRIX_OS" || env[1].Value != "linux" {
+		t.Errorf("unexpected environment: %v", env)
+	}
+	if len(podspec.Containers[1].Env) != 0 {
+		t.Errorf("sidecars must not get the matrix values: %v", podspec.Containers[1].Env)
+	}
+}
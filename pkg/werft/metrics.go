@@ -0,0 +1,170 @@
+package werft
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/filterexpr"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// serviceMetrics are the Prometheus metrics of the werft service. We never label by job name
+// to keep the cardinality of the metrics bounded.
+type serviceMetrics struct {
+	GithubJobPreparationSeconds    prometheus.Histogram
+	ExecutorJobPreperationSeconds  prometheus.Histogram
+	ExecutorJobStartsCounter       prometheus.Counter
+	ExecutorJobFailedStartsCounter prometheus.Counter
+	JobsStartedTotal               *prometheus.CounterVec
+	Jobs                           *prometheus.GaugeVec
+	JobDurationSeconds             *prometheus.HistogramVec
+	GRPCRequestSeconds             *prometheus.HistogramVec
+
+	// jobPhases is the last phase we've seen for all jobs which aren't done yet
+	jobPhases map[string]v1.JobPhase
+	mu        sync.Mutex
+}
+
+func (srv *Service) setupMetrics() {
+	srv.metrics.GithubJobPreparationSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "github_job_preparation_seconds",
+		Help:    "Time it took to retrieve all required data from GitHub prior to starting a job",
+		Buckets: []float64{0, 0.25, 0.5, 0.75, 1},
+	})
+	srv.metrics.ExecutorJobPreperationSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "werft",
+		Subsystem: "executor",
+		Name:      "job_preparation_seconds",
+		Help:      "Time it took to start executing the job",
+		Buckets:   []float64{0, 0.25, 0.5, 0.75, 1},
+	})
+	srv.metrics.ExecutorJobStartsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "werft",
+		Subsystem: "executor",
+		Name:      "job_starts_total",
+		Help:      "Total amount of jobs executor tried to start.",
+	})
+	srv.metrics.ExecutorJobFailedStartsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "werft",
+		Subsystem: "executor",
+		Name:      "job_starts_failed_total",
+		Help:      "Total amount of jobs executor failed to start.",
+	})
+	srv.metrics.JobsStartedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "werft",
+		Name:      "jobs_started_total",
+		Help:      "Total amount of jobs started, including those which wait or are queued.",
+	}, []string{"repo", "trigger"})
+	srv.metrics.Jobs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "werft",
+		Name:      "jobs",
+		Help:      "Number of jobs which aren't done yet by phase.",
+	}, []string{"phase"})
+	srv.metrics.JobDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "werft",
+		Name:      "job_duration_seconds",
+		Help:      "Time from creating a job until it was done.",
+		Buckets:   []float64{30, 60, 120, 300, 600, 1200, 1800, 3600, 7200},
+	}, []string{"repo", "trigger", "success"})
+	srv.metrics.GRPCRequestSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "werft",
+		Subsystem: "grpc",
+		Name:      "request_duration_seconds",
+		Help:      "Time it took to handle a gRPC request. For streams that's the time the stream was open.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "type", "code"})
+	srv.metrics.jobPhases = make(map[string]v1.JobPhase)
+}
+
+// RegisterPrometheusMetrics registers the service metrics on the registerer with MustRegister
+func (srv *Service) RegisterPrometheusMetrics(reg prometheus.Registerer) {
+	reg.MustRegister(srv.metrics.GithubJobPreparationSeconds)
+	reg.MustRegister(srv.metrics.ExecutorJobPreperationSeconds)
+	reg.MustRegister(srv.metrics.ExecutorJobFailedStartsCounter)
+	reg.MustRegister(srv.metrics.ExecutorJobStartsCounter)
+	reg.MustRegister(srv.metrics.JobsStartedTotal)
+	reg.MustRegister(srv.metrics.Jobs)
+	reg.MustRegister(srv.metrics.JobDurationSeconds)
+	reg.MustRegister(srv.metrics.GRPCRequestSeconds)
+}
+
+// observeJobUpdate maintains the job phase gauges and observes the duration of jobs which just finished.
+// The executor can report the same status several times, hence we only act on phase changes.
+func (srv *Service) observeJobUpdate(s *v1.JobStatus) {
+	m := &srv.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prev, known := m.jobPhases[s.Name]
+	if known && prev == s.Phase {
+		return
+	}
+	if known {
+		m.Jobs.WithLabelValues(phaseLabel(prev)).Dec()
+	}
+
+	if s.Phase == v1.JobPhase_PHASE_DONE || s.Phase == v1.JobPhase_PHASE_CLEANUP {
+		delete(m.jobPhases, s.Name)
+
+		// we only observe jobs we've seen running, e.g. not the ones which finished while werft was down
+		if !known || s.Phase != v1.JobPhase_PHASE_DONE || s.Metadata == nil {
+			return
+		}
+		created, err := ptypes.Timestamp(s.Metadata.Created)
+		if err != nil {
+			return
+		}
+		finished, err := ptypes.Timestamp(s.Metadata.Finished)
+		if err != nil {
+			return
+		}
+		var success bool
+		if s.Conditions != nil {
+			success = s.Conditions.Success
+		}
+		m.JobDurationSeconds.WithLabelValues(repoLabel(s.Metadata), triggerLabel(s.Metadata), fmt.Sprint(success)).Observe(finished.Sub(created).Seconds())
+		return
+	}
+
+	m.jobPhases[s.Name] = s.Phase
+	m.Jobs.WithLabelValues(phaseLabel(s.Phase)).Inc()
+}
+
+// UnaryServerInterceptor observes the duration of unary gRPC requests
+func (srv *Service) UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	srv.metrics.GRPCRequestSeconds.WithLabelValues(info.FullMethod, "unary", status.Code(err).String()).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// StreamServerInterceptor observes the duration of streaming gRPC requests
+func (srv *Service) StreamServerInterceptor(s interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(s, ss)
+	srv.metrics.GRPCRequestSeconds.WithLabelValues(info.FullMethod, "stream", status.Code(err).String()).Observe(time.Since(start).Seconds())
+	return err
+}
+
+func phaseLabel(phase v1.JobPhase) string {
+	return filterexpr.NormalizePhase(phase.String())
+}
+
+// repoLabel identifies the repository of a job, e.g. github.com/csweichel/werft
+func repoLabel(md *v1.JobMetadata) string {
+	if md.Repository == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/%s", md.Repository.Host, md.Repository.Owner, md.Repository.Repo)
+}
+
+func triggerLabel(md *v1.JobMetadata) string {
+	return strings.ToLower(strings.TrimPrefix(md.Trigger.String(), "TRIGGER_"))
+}
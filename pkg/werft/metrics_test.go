@@ -0,0 +1,94 @@
+package werft
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/store"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMetrics(t *testing.T) {
+	srv := &Service{
+		Logs:        store.NewInMemoryLogStore(),
+		Jobs:        store.NewInMemoryJobStore(),
+		logListener: make(map[string]*jobLog),
+	}
+	srv.setupMetrics()
+	reg := prometheus.NewRegistry()
+	srv.RegisterPrometheusMetrics(reg)
+
+	job := func(name string, phase v1.JobPhase, success bool) *v1.JobStatus {
+		return &v1.JobStatus{
+			Name:  name,
+			Phase: phase,
+			Metadata: &v1.JobMetadata{
+				Repository: &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft"},
+				Trigger:    v1.JobTrigger_TRIGGER_PUSH,
+				Created:    &timestamp.Timestamp{Seconds: 1000},
+				Finished:   &timestamp.Timestamp{Seconds: 1090},
+			},
+			Conditions: &v1.JobConditions{Success: success},
+		}
+	}
+	for _, s := range []*v1.JobStatus{
+		job("a", v1.JobPhase_PHASE_PREPARING, false),
+		job("b", v1.JobPhase_PHASE_WAITING, false),
+		job("a", v1.JobPhase_PHASE_RUNNING, false),
+		// the executor can report the same status several times
+		job("a", v1.JobPhase_PHASE_RUNNING, false),
+		job("c", v1.JobPhase_PHASE_RUNNING, false),
+		job("a", v1.JobPhase_PHASE_DONE, true),
+		job("a", v1.JobPhase_PHASE_DONE, true),
+		job("a", v1.JobPhase_PHASE_CLEANUP, true),
+		job("b", v1.JobPhase_PHASE_CLEANUP, false),
+		// we've never seen this job running, hence don't know its duration
+		job("d", v1.JobPhase_PHASE_DONE, false),
+	} {
+		srv.handleJobUpdate(nil, s)
+	}
+
+	_, err := srv.UnaryServerInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/v1.WerftService/GetJob"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "not found")
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("interceptor did not pass through the error: %v", err)
+	}
+
+	const expected = `
+# HELP werft_jobs Number of jobs which aren't done yet by phase.
+# TYPE werft_jobs gauge
+werft_jobs{phase="preparing"} 0
+werft_jobs{phase="running"} 1
+werft_jobs{phase="waiting"} 0
+# HELP werft_job_duration_seconds Time from creating a job until it was done.
+# TYPE werft_job_duration_seconds histogram
+werft_job_duration_seconds_bucket{repo="github.com/csweichel/werft",success="true",trigger="push",le="30"} 0
+werft_job_duration_seconds_bucket{repo="github.com/csweichel/werft",success="true",trigger="push",le="60"} 0
+werft_job_duration_seconds_bucket{repo="github.com/csweichel/werft",success="true",trigger="push",le="120"} 1
+werft_job_duration_seconds_bucket{repo="github.com/csweichel/werft",success="true",trigger="push",le="300"} 1
+werft_job_duration_seconds_bucket{repo="github.com/csweichel/werft",success="true",trigger="push",le="600"} 1
+werft_job_duration_seconds_bucket{repo="github.com/csweichel/werft",success="true",trigger="push",le="1200"} 1
+werft_job_duration_seconds_bucket{repo="github.com/csweichel/werft",success="true",trigger="push",le="1800"} 1
+werft_job_duration_seconds_bucket{repo="github.com/csweichel/werft",success="true",trigger="push",le="3600"} 1
+werft_job_duration_seconds_bucket{repo="github.com/csweichel/werft",success="true",trigger="push",le="7200"} 1
+werft_job_duration_seconds_bucket{repo="github.com/csweichel/werft",success="true",trigger="push",le="+Inf"} 1
+werft_job_duration_seconds_sum{repo="github.com/csweichel/werft",success="true",trigger="push"} 90
+werft_job_duration_seconds_count{repo="github.com/csweichel/werft",success="true",trigger="push"} 1
+`
+	err = testutil.GatherAndCompare(reg, strings.NewReader(expected), "werft_jobs", "werft_job_duration_seconds")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if n := testutil.CollectAndCount(srv.metrics.GRPCRequestSeconds); n != 1 {
+		t.Errorf("expected one gRPC request series, got %d", n)
+	}
+}
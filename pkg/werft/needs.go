@@ -0,0 +1,152 @@
+package werft
+
+import (
+	"context"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/csweichel/werft/pkg/api/repoconfig"
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/golang/protobuf/proto"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/xerrors"
+)
+
+// AnnotationNeeds is set on jobs which wait for other jobs of the same trigger and lists them, separated by comma
+const AnnotationNeeds = "werft.needs"
+
+// neededJob is a job spec started together with the jobs which need it
+type neededJob struct {
+	// Path is the path of the job spec in the repository
+	Path        string
+	JobSpecName string
+	JobYAML     []byte
+	// Needs lists the paths of the jobs this job needs
+	Needs []string
+}
+
+// resolveNeeds loads the jobs a job needs, directly or through other jobs, using load. It returns all jobs
+// including the one passed in, ordered such that every job comes after the jobs it needs.
+func resolveNeeds(job *neededJob, md *v1.JobMetadata, load func(path string) ([]byte, error)) ([]*neededJob, error) {
+	var (
+		res      []*neededJob
+		done     = make(map[string]bool)
+		visiting []string
+		visit    func(j *neededJob) error
+	)
+	visit = func(j *neededJob) error {
+		visiting = append(visiting, j.Path)
+		jobspec, err := renderJobSpec(j.JobSpecName, md, j.JobYAML)
+		if err != nil {
+			return xerrors.Errorf("cannot handle job spec %s: %w", j.Path, err)
+		}
+
+	needs:
+		for _, n := range jobspec.Needs {
+			p := path.Clean(strings.TrimPrefix(n, "/"))
+			for _, known := range j.Needs {
+				if known == p {
+					continue needs
+				}
+			}
+			j.Needs = append(j.Needs, p)
+			if done[p] {
+				continue
+			}
+			for i, v := range visiting {
+				if v == p {
+					return xerrors.Errorf("jobs need each other: %s -> %s", strings.Join(visiting[i:], " -> "), p)
+				}
+			}
+			if len(done)+len(visiting) > repoconfig.MaxNeededJobs {
+				return xerrors.Errorf("a job must not need more than %d jobs", repoconfig.MaxNeededJobs)
+			}
+
+			jobYAML, err := load(p)
+			if err != nil {
+				return xerrors.Errorf("cannot load needed job %s: %w", p, err)
+			}
+			err = visit(&neededJob{Path: p, JobSpecName: specNameFromPath(p), JobYAML: jobYAML})
+			if err != nil {
+				return err
+			}
+		}
+
+		visiting = visiting[:len(visiting)-1]
+		done[j.Path] = true
+		res = append(res, j)
+		return nil
+	}
+
+	err := visit(job)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// runWithNeeds starts the last of the jobs under the given name together with the jobs it needs, as returned by
+// resolveNeeds. The executor holds back every job until the jobs it needs have succeeded, and must know about it
+// before they finish. Hence we start the jobs which need others first.
+func (srv *Service) runWithNeeds(ctx context.Context, name string, jobs []*neededJob, metadata v1.JobMetadata, cp ContentProvider, canReplay bool, waitUntil time.Time, newJobName func(jobSpecName string) (string, error)) (*v1.JobStatus, error) {
+	job := jobs[len(jobs)-1]
+	names := map[string]string{job.Path: name}
+	for _, j := range jobs[:len(jobs)-1] {
+		n, err := newJobName(j.JobSpecName)
+		if err != nil {
+			return nil, err
+		}
+		names[j.Path] = n
+	}
+
+	var (
+		status *v1.JobStatus
+		err    error
+	)
+	for i := len(jobs) - 1; i >= 0; i-- {
+		j := jobs[i]
+		md := proto.Clone(&metadata).(*v1.JobMetadata)
+		md.JobSpecName = j.JobSpecName
+		md.Annotations = setAnnotation(md.Annotations, AnnotationPipeline, name)
+		if len(j.Needs) > 0 {
+			needs := make([]string, len(j.Needs))
+			for k, p := range j.Needs {
+				needs[k] = names[p]
+			}
+			md.Annotations = setAnnotation(md.Annotations, AnnotationNeeds, strings.Join(needs, ","))
+		}
+
+		s, jerr := srv.RunJob(ctx, names[j.Path], *md, cp, j.JobYAML, canReplay, waitUntil)
+		if j == job {
+			status, err = s, jerr
+			continue
+		}
+		if jerr != nil {
+			// a job which fails to start is stored as failed, which skips the jobs which need it
+			log.WithError(jerr).WithField("name", names[j.Path]).WithField("neededBy", name).Warn("cannot start needed job")
+		}
+	}
+	return status, err
+}
+
+// jobNeeds returns the jobs a job waits for before it can start
+func jobNeeds(md *v1.JobMetadata) []string {
+	if md == nil {
+		return nil
+	}
+	for _, a := range md.Annotations {
+		if a.Key == AnnotationNeeds && a.Value != "" {
+			return strings.Split(a.Value, ",")
+		}
+	}
+	return nil
+}
+
+// jobDone lets the executor start or skip the jobs which need this one
+func (srv *Service) jobDone(status *v1.JobStatus) {
+	if srv.Executor == nil {
+		return
+	}
+	srv.Executor.JobDone(status)
+}
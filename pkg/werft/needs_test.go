@@ -0,0 +1,98 @@
+package werft
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+)
+
+func TestResolveNeeds(t *testing.T) {
+	files := map[string]string{
+		".werft/build.yaml":  "pod: {}\n",
+		".werft/test-a.yaml": "needs: [.werft/build.yaml]\n",
+		".werft/test-b.yaml": "needs: [/.werft/build.yaml, .werft/build.yaml]\n",
+		".werft/loop-a.yaml": "needs: [.werft/loop-b.yaml]\n",
+		".werft/loop-b.yaml": "needs: [.werft/build.yaml, .werft/loop-a.yaml]\n",
+		".werft/broken.yaml": "needs: [.werft/missing.yaml]\n",
+	}
+	load := func(path string) ([]byte, error) {
+		c, ok := files[path]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(c), nil
+	}
+	md := &v1.JobMetadata{Repository: &v1.Repository{Owner: "csweichel", Repo: "werft", Ref: "main"}}
+
+	type job struct {
+		Path  string
+		Needs []string
+	}
+	tests := []struct {
+		Name        string
+		Spec        string
+		Expectation []job
+		Error       string
+	}{
+		{
+			Name:        "no needs",
+			Spec:        "pod: {}\n",
+			Expectation: []job{{Path: ".werft/deploy.yaml"}},
+		},
+		{
+			Name: "diamond",
+			Spec: "needs:\n- .werft/test-a.yaml\n- .werft/test-b.yaml\n",
+			Expectation: []job{
+				{Path: ".werft/build.yaml"},
+				{Path: ".werft/test-a.yaml", Needs: []string{".werft/build.yaml"}},
+				{Path: ".werft/test-b.yaml", Needs: []string{".werft/build.yaml"}},
+				{Path: ".werft/deploy.yaml", Needs: []string{".werft/test-a.yaml", ".werft/test-b.yaml"}},
+			},
+		},
+		{
+			Name:  "templated",
+			Spec:  "needs: [.werft/{{ .Repository.Repo }}.yaml]\n",
+			Error: "cannot load needed job .werft/werft.yaml: file does not exist",
+		},
+		{Name: "cycle", Spec: "needs: [.werft/loop-a.yaml]\n", Error: "jobs need each other: .werft/loop-a.yaml -> .werft/loop-b.yaml -> .werft/loop-a.yaml"},
+		{Name: "self", Spec: "needs: [.werft/deploy.yaml]\n", Error: "jobs need each other: .werft/deploy.yaml -> .werft/deploy.yaml"},
+		{Name: "missing", Spec: "needs: [.werft/broken.yaml]\n", Error: "cannot load needed job .werft/missing.yaml: file does not exist"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			jobs, err := resolveNeeds(&neededJob{Path: ".werft/deploy.yaml", JobSpecName: "deploy", JobYAML: []byte(test.Spec)}, md, load)
+			if test.Error != "" {
+				if err == nil || err.Error() != test.Error {
+					t.Fatalf("expected error %q, got %v", test.Error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			act := make([]job, len(jobs))
+			for i, j := range jobs {
+				act[i] = job{Path: j.Path, Needs: j.Needs}
+				if j.Path != ".werft/deploy.yaml" && string(j.JobYAML) != files[j.Path] {
+					t.Errorf("unexpected job spec for %s: %q", j.Path, j.JobYAML)
+				}
+			}
+			if !reflect.DeepEqual(act, test.Expectation) {
+				t.Errorf("unexpected jobs:\n\texpected %v\n\tgot      %v", test.Expectation, act)
+			}
+		})
+	}
+}
+
+func TestJobNeeds(t *testing.T) {
+	md := &v1.JobMetadata{Annotations: []*v1.Annotation{{Key: AnnotationNeeds, Value: "werft-build-main.1,werft-test-main.1"}}}
+	if act := jobNeeds(md); !reflect.DeepEqual(act, []string{"werft-build-main.1", "werft-test-main.1"}) {
+		t.Errorf("unexpected needs: %v", act)
+	}
+	if act := jobNeeds(&v1.JobMetadata{}); act != nil {
+		t.Errorf("expected no needs, got %v", act)
+	}
+}
@@ -0,0 +1,156 @@
+package werft
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// redactedSecret replaces secret values in the job log
+	redactedSecret = "***"
+
+	// minRedactedSecretLength is the length below which we don't redact secret values. Redacting values such as
+	// "1" or "yes" would mangle the log without protecting anything.
+	minRedactedSecretLength = 4
+)
+
+// secretValues returns the values of all secrets the pod references, i.e. the values we redact from the job log.
+// Multi-line values are also redacted line by line, because the log might prefix each line, e.g. for sidecars.
+func secretValues(ctx context.Context, client kubernetes.Interface, namespace string, podspec *corev1.PodSpec) ([]string, error) {
+	names := make(map[string]struct{})
+	var containers []corev1.Container
+	containers = append(containers, podspec.InitContainers...)
+	containers = append(containers, podspec.Containers...)
+	for _, c := range containers {
+		for _, e := range c.Env {
+			if e.ValueFrom != nil && e.ValueFrom.SecretKeyRef != nil {
+				names[e.ValueFrom.SecretKeyRef.Name] = struct{}{}
+			}
+		}
+		for _, e := range c.EnvFrom {
+			if e.SecretRef != nil {
+				names[e.SecretRef.Name] = struct{}{}
+			}
+		}
+	}
+	for _, v := range podspec.Volumes {
+		if v.Secret != nil {
+			names[v.Secret.SecretName] = struct{}{}
+		}
+	}
+
+	var (
+		res  = make([]string, 0)
+		seen = make(map[string]struct{})
+	)
+	add := func(val string) {
+		if len(val) < minRedactedSecretLength {
+			return
+		}
+		if _, ok := seen[val]; ok {
+			return
+		}
+		seen[val] = struct{}{}
+		res = append(res, val)
+	}
+	for name := range names {
+		secret, err := client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			// the pod cannot start without the secret, hence there's nothing that could leak
+			continue
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("cannot read secret %s: %w", name, err)
+		}
+
+		for _, v := range secret.Data {
+			val := string(v)
+			add(val)
+			add(strings.TrimSpace(val))
+			if !strings.Contains(val, "\n") {
+				continue
+			}
+			for _, l := range strings.Split(val, "\n") {
+				add(strings.TrimSpace(l))
+			}
+		}
+	}
+	return res, nil
+}
+
+// redactingWriter replaces secret values with *** before writing to the underlying writer. Secrets which are split
+// across several writes are redacted, too: we hold back output which could be the beginning of a secret until
+// we know whether it is one.
+type redactingWriter struct {
+	out     io.Writer
+	secrets [][]byte
+	buf     []byte
+}
+
+func newRedactingWriter(out io.Writer, secrets []string) *redactingWriter {
+	res := &redactingWriter{out: out}
+	for _, s := range secrets {
+		res.secrets = append(res.secrets, []byte(s))
+	}
+	// we match the longest secret first so that a secret which contains another one is redacted as a whole
+	sort.Slice(res.secrets, func(i, j int) bool { return len(res.secrets[i]) > len(res.secrets[j]) })
+	return res
+}
+
+func (w *redactingWriter) Write(p []byte) (n int, err error) {
+	if len(w.secrets) == 0 {
+		return w.out.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	err = w.redact(false)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes the output we've held back
+func (w *redactingWriter) Flush() error {
+	return w.redact(true)
+}
+
+func (w *redactingWriter) redact(flush bool) error {
+	var (
+		res = make([]byte, 0, len(w.buf))
+		i   int
+	)
+scan:
+	for i < len(w.buf) {
+		rest := w.buf[i:]
+		for _, s := range w.secrets {
+			if bytes.HasPrefix(rest, s) {
+				res = append(res, redactedSecret...)
+				i += len(s)
+				continue scan
+			}
+			if !flush && len(rest) < len(s) && bytes.HasPrefix(s, rest) {
+				// this might be the beginning of a secret - wait for more output
+				break scan
+			}
+		}
+		res = append(res, w.buf[i])
+		i++
+	}
+	w.buf = append(w.buf[:0], w.buf[i:]...)
+
+	if len(res) == 0 {
+		return nil
+	}
+	_, err := w.out.Write(res)
+	return err
+}
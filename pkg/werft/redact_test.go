@@ -0,0 +1,165 @@
+package werft
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRedactingWriter(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Secrets []string
+		Writes  []string
+		// Written is the output before the writer is flushed
+		Written string
+		Output  string
+	}{
+		{
+			Name:    "no secrets",
+			Writes:  []string{"hello ", "world\n"},
+			Written: "hello world\n",
+			Output:  "hello world\n",
+		},
+		{
+			Name:    "single write",
+			Secrets: []string{"s3cr3t"},
+			Writes:  []string{"token: s3cr3t\n"},
+			Written: "token: ***\n",
+			Output:  "token: ***\n",
+		},
+		{
+			Name:    "split token",
+			Secrets: []string{"s3cr3t"},
+			Writes:  []string{"token: s3c", "r3t\n"},
+			Written: "token: ***\n",
+			Output:  "token: ***\n",
+		},
+		{
+			Name:    "token split across many writes",
+			Secrets: []string{"s3cr3t"},
+			Writes:  []string{"token: s", "3", "cr", "3", "t and s3cr3t", "s3cr3t\n"},
+			Written: "token: *** and ******\n",
+			Output:  "token: *** and ******\n",
+		},
+		{
+			Name:    "prefix of a token is held back",
+			Secrets: []string{"s3cr3t"},
+			Writes:  []string{"token: s3c"},
+			Written: "token: ",
+			Output:  "token: s3c",
+		},
+		{
+			Name:    "prefix of a token which isn't one",
+			Secrets: []string{"s3cr3t"},
+			Writes:  []string{"token: s3c", "ret\n"},
+			Written: "token: s3cret\n",
+			Output:  "token: s3cret\n",
+		},
+		{
+			Name:    "longest secret wins",
+			Secrets: []string{"abcd", "abcdefgh"},
+			Writes:  []string{"abcd", "efgh abcd\n"},
+			Written: "*** ***\n",
+			Output:  "*** ***\n",
+		},
+		{
+			Name:    "overlapping secrets",
+			Secrets: []string{"abcd", "cdef"},
+			Writes:  []string{"abcdef\n"},
+			Written: "***ef\n",
+			Output:  "***ef\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := newRedactingWriter(&out, test.Secrets)
+			for _, p := range test.Writes {
+				n, err := w.Write([]byte(p))
+				if err != nil {
+					t.Fatalf("cannot write: %v", err)
+				}
+				if n != len(p) {
+					t.Fatalf("short write: %d instead of %d", n, len(p))
+				}
+			}
+			if act := out.String(); act != test.Written {
+				t.Errorf("unexpected output before flush: %q, expected %q", act, test.Written)
+			}
+
+			err := w.Flush()
+			if err != nil {
+				t.Fatalf("cannot flush: %v", err)
+			}
+			if act := out.String(); act != test.Output {
+				t.Errorf("unexpected output: %q, expected %q", act, test.Output)
+			}
+		})
+	}
+}
+
+func TestSecretValues(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "deploy", Namespace: "werft"},
+			Data: map[string][]byte{
+				"token": []byte("deploy-token\n"),
+				"short": []byte("1"),
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "keys", Namespace: "werft"},
+			Data: map[string][]byte{
+				"key": []byte("first line\nsecond line"),
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "unused", Namespace: "werft"},
+			Data: map[string][]byte{
+				"token": []byte("unused-token"),
+			},
+		},
+	)
+	podspec := &corev1.PodSpec{
+		InitContainers: []corev1.Container{{
+			Name: "init",
+			EnvFrom: []corev1.EnvFromSource{{
+				SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "missing"}},
+			}},
+		}},
+		Containers: []corev1.Container{{
+			Name: "build",
+			Env: []corev1.EnvVar{{
+				Name: "TOKEN",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "deploy"},
+						Key:                  "token",
+					},
+				},
+			}},
+		}},
+		Volumes: []corev1.Volume{{
+			Name:         "keys",
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "keys"}},
+		}},
+	}
+
+	act, err := secretValues(context.Background(), client, "werft", podspec)
+	if err != nil {
+		t.Fatalf("cannot get secret values: %v", err)
+	}
+	sort.Strings(act)
+
+	expected := []string{"deploy-token", "deploy-token\n", "first line", "first line\nsecond line", "second line"}
+	if !reflect.DeepEqual(act, expected) {
+		t.Errorf("unexpected secret values: %q, expected %q", act, expected)
+	}
+}
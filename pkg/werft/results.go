@@ -0,0 +1,51 @@
+package werft
+
+import (
+	"encoding/json"
+	"strings"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"golang.org/x/xerrors"
+)
+
+// parseResult turns a result marker the log cutter found into a job result. The marker's payload is either
+// a JSON object, e.g. {"payload":"...","description":"...","channels":["github"]}, or the result's payload
+// followed by its description.
+func parseResult(evt *v1.LogSliceEvent) (*v1.JobResult, error) {
+	tpe := strings.TrimSpace(evt.Name)
+	if tpe == "" {
+		return nil, xerrors.Errorf("result has no type")
+	}
+
+	payload := strings.TrimSpace(evt.Payload)
+	if strings.HasPrefix(payload, "{") {
+		var body struct {
+			P string   `json:"payload"`
+			C []string `json:"channels"`
+			D string   `json:"description"`
+		}
+		err := json.Unmarshal([]byte(payload), &body)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid %s result: %w", tpe, err)
+		}
+		if body.P == "" {
+			return nil, xerrors.Errorf("%s result has no payload", tpe)
+		}
+		return &v1.JobResult{
+			Type:        tpe,
+			Payload:     body.P,
+			Description: body.D,
+			Channels:    body.C,
+		}, nil
+	}
+
+	segs := strings.Fields(payload)
+	if len(segs) == 0 {
+		return nil, xerrors.Errorf("%s result has no payload", tpe)
+	}
+	return &v1.JobResult{
+		Type:        tpe,
+		Payload:     segs[0],
+		Description: strings.Join(segs[1:], " "),
+	}, nil
+}
@@ -0,0 +1,65 @@
+package werft
+
+import (
+	"strings"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/logcutter"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestParseResult(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Line        string
+		Expectation *v1.JobResult
+		Error       string
+	}{
+		{Name: "payload only", Line: "[url|RESULT] https://preview.example.com", Expectation: &v1.JobResult{Type: "url", Payload: "https://preview.example.com"}},
+		{Name: "description", Line: "[image|RESULT] eu.gcr.io/werft/werft:main built from main", Expectation: &v1.JobResult{Type: "image", Payload: "eu.gcr.io/werft/werft:main", Description: "built from main"}},
+		{
+			Name:        "json",
+			Line:        `[tests|RESULT] {"payload":"42 passed, 1 failed","description":"unit tests","channels":["github"]}`,
+			Expectation: &v1.JobResult{Type: "tests", Payload: "42 passed, 1 failed", Description: "unit tests", Channels: []string{"github"}},
+		},
+		{Name: "nested slice", Line: "[build|coverage|RESULT] 87%", Expectation: &v1.JobResult{Type: "build|coverage", Payload: "87%"}},
+		{Name: "no payload", Line: "[url|RESULT]", Error: "url result has no payload"},
+		{Name: "blank payload", Line: "[url|RESULT]    ", Error: "url result has no payload"},
+		{Name: "invalid json", Line: `[tests|RESULT] {"payload":"42 passed"`, Error: "invalid tests result: unexpected end of JSON input"},
+		{Name: "json without payload", Line: `[tests|RESULT] {"description":"unit tests"}`, Error: "tests result has no payload"},
+		{Name: "json with wrong types", Line: `[tests|RESULT] {"payload":42}`, Error: "invalid tests result: json: cannot unmarshal number"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			evts, errs := logcutter.DefaultCutter.Slice(strings.NewReader(test.Line + "\n"))
+			var evt *v1.LogSliceEvent
+			for e := range evts {
+				if e.Type == v1.LogSliceType_SLICE_RESULT {
+					evt = e
+				}
+			}
+			if err := <-errs; err != nil {
+				t.Fatalf("cannot cut log: %v", err)
+			}
+			if evt == nil {
+				t.Fatalf("log cutter found no result in %q", test.Line)
+			}
+
+			act, err := parseResult(evt)
+			if test.Error != "" {
+				// the JSON decoder's messages vary in their details
+				if err == nil || !strings.HasPrefix(err.Error(), test.Error) {
+					t.Fatalf("expected error %q, got %v", test.Error, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !proto.Equal(act, test.Expectation) {
+				t.Errorf("unexpected result: %v; expected %v", act, test.Expectation)
+			}
+		})
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -15,6 +17,8 @@ import (
 	termtohtml "github.com/buildkite/terminal-to-html"
 	"github.com/csweichel/werft/pkg/api/repoconfig"
 	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/audit"
+	"github.com/csweichel/werft/pkg/auth"
 	"github.com/csweichel/werft/pkg/filterexpr"
 	"github.com/csweichel/werft/pkg/logcutter"
 	"github.com/csweichel/werft/pkg/store"
@@ -29,7 +33,7 @@ import (
 )
 
 // StartLocalJob starts a job whoose content is uploaded
-func (srv *Service) StartLocalJob(inc v1.WerftService_StartLocalJobServer) error {
+func (srv *Service) StartLocalJob(inc v1.WerftService_StartLocalJobServer) (err error) {
 	req, err := inc.Recv()
 	if err != nil {
 		return err
@@ -39,6 +43,14 @@ func (srv *Service) StartLocalJob(inc v1.WerftService_StartLocalJobServer) error
 	}
 	md := *req.GetMetadata()
 	log.WithField("name", md).Debug("StartLocalJob - received metadata")
+	var name string
+	defer func() {
+		srv.auditLog(inc.Context(), audit.Entry{Action: audit.ActionStart, Job: name}, &md, err)
+	}()
+	err = srv.authorize(inc.Context(), auth.ActionStart, md.Repository)
+	if err != nil {
+		return err
+	}
 
 	dfs, err := ioutil.TempFile(os.TempDir(), "werft-lcp")
 	if err != nil {
@@ -124,7 +136,7 @@ func (srv *Service) StartLocalJob(inc v1.WerftService_StartLocalJobServer) error
 	//       The context upload is a one time thing and hence prevent job replay.
 
 	flatOwner := strings.ReplaceAll(strings.ToLower(md.Owner), " ", "")
-	name := cleanupPodName(fmt.Sprintf("local-%s-%s", flatOwner, moniker.New().NameSep("-")))
+	name = cleanupPodName(fmt.Sprintf("local-%s-%s", flatOwner, moniker.New().NameSep("-")))
 
 	jobStatus, err := srv.RunJob(inc.Context(), name, md, cp, jobYAML, false, time.Time{})
 
@@ -149,12 +161,13 @@ func (srv *Service) StartGitHubJob(ctx context.Context, req *v1.StartGitHubJobRe
 	}
 
 	return srv.StartJob(ctx, &v1.StartJobRequest{
-		JobPath:    req.JobPath,
-		JobYaml:    req.JobYaml,
-		Metadata:   req.Metadata,
-		Sideload:   req.Sideload,
-		WaitUntil:  req.WaitUntil,
-		NameSuffix: req.NameSuffix,
+		JobPath:      req.JobPath,
+		JobYaml:      req.JobYaml,
+		Metadata:     req.Metadata,
+		Sideload:     req.Sideload,
+		WaitUntil:    req.WaitUntil,
+		NameSuffix:   req.NameSuffix,
+		ChangedFiles: req.ChangedFiles,
 	})
 }
 
@@ -163,6 +176,13 @@ func (srv *Service) StartJob(ctx context.Context, req *v1.StartJobRequest) (resp
 	log.WithField("req", proto.MarshalTextString(req)).Info("StartJob request")
 
 	md := req.Metadata
+	defer func() {
+		srv.auditLog(ctx, audit.Entry{Action: audit.ActionStart, Job: startedJob(resp)}, md, err)
+	}()
+	err = srv.authorize(ctx, auth.ActionStart, md.Repository)
+	if err != nil {
+		return nil, err
+	}
 	err = srv.RepositoryProvider.Resolve(ctx, md.Repository)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "cannot resolve request: %q", err)
@@ -206,17 +226,18 @@ func (srv *Service) StartJob(ctx context.Context, req *v1.StartJobRequest) (resp
 		jobYAML     = req.JobYaml
 		tplpath     = req.JobPath
 		jobSpecName = "custom"
+		repoCfg     *repoconfig.C
 	)
 	if jobYAML == nil {
 		if tplpath == "" {
-			repoCfg, err := getRepoCfg(ctx, fp)
+			repoCfg, err = getRepoCfg(ctx, fp)
 			if err != nil {
 				return nil, status.Error(codes.Internal, err.Error())
 			}
-			tplpath = repoCfg.TemplatePath(req.Metadata)
+			tplpath = repoCfg.TemplatePathForChanges(req.Metadata, req.ChangedFiles)
 		}
 		if tplpath == "" {
-			return nil, status.Errorf(codes.NotFound, "no jobspec found in repo config")
+			return nil, status.Errorf(codes.NotFound, "no jobspec found in repo config - no rule matches this ref or the changed files")
 		}
 
 		in, err := fp.Download(ctx, tplpath)
@@ -229,40 +250,100 @@ func (srv *Service) StartJob(ctx context.Context, req *v1.StartJobRequest) (resp
 			return nil, status.Errorf(codes.Internal, "cannot download jobspec from %s: %s", tplpath, err.Error())
 		}
 	}
+	if repoCfg == nil {
+		// repositories without werft config can still run jobs whose spec is part of the request
+		cfg, err := getRepoCfg(ctx, fp)
+		if err == nil {
+			repoCfg = cfg
+		} else {
+			log.WithError(err).WithField("repo", md.Repository).Debug("cannot get repo config - using the defaults")
+		}
+	}
+	readFile := func(path string) ([]byte, error) {
+		in, err := fp.Download(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		defer in.Close()
+		return ioutil.ReadAll(in)
+	}
+	// bundling the templates, env file and repository defaults makes the job replayable without access to the repository
+	bundle := func(jobYAML []byte) ([]byte, error) {
+		if repoconfig.NeedsBundle(jobYAML) {
+			if repoCfg == nil {
+				var err error
+				repoCfg, err = getRepoCfg(ctx, fp)
+				if err != nil {
+					return nil, err
+				}
+			}
+			var err error
+			jobYAML, err = repoconfig.BundleTemplates(jobYAML, repoCfg.Templates, readFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if repoCfg != nil && repoCfg.DefaultImage != "" {
+			jobYAML = repoconfig.BundleDefaultImage(jobYAML, repoCfg.DefaultImage)
+		}
+		return jobYAML, nil
+	}
+	loadJobSpec := func(path string) ([]byte, error) {
+		jobYAML, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return bundle(jobYAML)
+	}
+	jobYAML, err = bundle(jobYAML)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if repoCfg != nil {
+		md.Annotations, err = withDefaultAnnotations(md.Annotations, repoCfg.Annotations)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%s: %s", PathWerftConfig, status.Convert(err).Message())
+		}
+	}
 	if tplpath != "" {
-		jobSpecName = strings.TrimSpace(strings.TrimSuffix(filepath.Base(tplpath), filepath.Ext(tplpath)))
+		jobSpecName = specNameFromPath(tplpath)
 	}
 	md.JobSpecName = jobSpecName
 
-	// build job name
-	refname := md.Repository.Ref
-	refname = strings.TrimPrefix(refname, "refs/heads/")
-	refname = strings.TrimPrefix(refname, "refs/tags/")
-	refname = strings.ReplaceAll(refname, "/", "-")
-	refname = strings.ReplaceAll(refname, "_", "-")
-	refname = strings.ReplaceAll(refname, "@", "-")
-	refname = strings.ToLower(refname)
-	if refname == "" {
-		// we did not compute a sensible refname - use moniker
-		refname = moniker.New().NameSep("-")
+	if len(req.NameSuffix) > 20 {
+		return nil, status.Error(codes.InvalidArgument, "name suffix must be less than 20 characters")
 	}
-	name := cleanupPodName(fmt.Sprintf("%s-%s-%s", md.Repository.Repo, jobSpecName, refname))
-	if req.NameSuffix != "" {
-		if len(req.NameSuffix) > 20 {
-			return nil, status.Error(codes.InvalidArgument, "name suffix must be less than 20 characters")
+	var nameTemplate string
+	if repoCfg != nil && repoCfg.JobName != "" {
+		nameTemplate = repoCfg.JobName
+		err = validateJobNameTemplate(nameTemplate)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
 		}
-
-		name += "-" + req.NameSuffix
 	}
-
-	if refname != "" {
-		// we have a valid refname, hence need to acquire job number
-		t, err := srv.Groups.Next(name)
+	if srv.Config.DeduplicateJobs && len(req.Sideload) == 0 {
+		fingerprint := jobFingerprint(md, jobYAML)
+		existing, release, err := srv.claimFingerprint(ctx, md, fingerprint)
 		if err != nil {
 			return nil, status.Error(codes.Internal, err.Error())
 		}
+		if existing != nil {
+			log.WithField("name", existing.Name).WithField("fingerprint", fingerprint).Info("same job has not finished yet - not starting it again")
+			return &v1.StartJobResponse{Status: existing}, nil
+		}
+		defer release()
+		md.Annotations = setAnnotation(md.Annotations, AnnotationFingerprint, fingerprint)
+	}
 
-		name = fmt.Sprintf("%s.%d", name, t)
+	newJobName := func(jobSpecName string) (string, error) {
+		if nameTemplate != "" {
+			return srv.newTemplatedJobName(nameTemplate, md, jobSpecName, req.NameSuffix)
+		}
+		return srv.newJobName(md, jobSpecName, req.NameSuffix)
+	}
+	name, err := newJobName(jobSpecName)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
 	}
 
 	canReplay := len(req.Sideload) == 0
@@ -279,9 +360,25 @@ func (srv *Service) StartJob(ctx context.Context, req *v1.StartJobRequest) (resp
 		}
 	}
 
-	jobStatus, err := srv.RunJob(ctx, name, *md, cp, jobYAML, canReplay, waitUntil)
-	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+	var jobStatus *v1.JobStatus
+	if jobspec, err := renderJobSpec(name, md, jobYAML); err == nil && len(jobspec.Needs) > 0 {
+		// errors in the job spec itself surface when we run it
+		if len(req.Sideload) > 0 {
+			return nil, status.Error(codes.InvalidArgument, "jobs which need other jobs cannot run on sideloaded content")
+		}
+		jobs, err := resolveNeeds(&neededJob{Path: tplpath, JobSpecName: jobSpecName, JobYAML: jobYAML}, md, loadJobSpec)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		jobStatus, err = srv.runWithNeeds(ctx, name, jobs, *md, cp, canReplay, waitUntil, newJobName)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	} else {
+		jobStatus, err = srv.RunJob(ctx, name, *md, cp, jobYAML, canReplay, waitUntil)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
 	}
 
 	log.WithField("status", jobStatus).Info(("started new GitHub job"))
@@ -290,6 +387,41 @@ func (srv *Service) StartJob(ctx context.Context, req *v1.StartJobRequest) (resp
 	}, nil
 }
 
+// specNameFromPath derives the name of a job spec from its path
+func specNameFromPath(path string) string {
+	return strings.TrimSpace(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+}
+
+// newJobName builds the name of a new job from its repository, job spec and ref
+func (srv *Service) newJobName(md *v1.JobMetadata, jobSpecName, nameSuffix string) (string, error) {
+	refname := md.Repository.Ref
+	refname = strings.TrimPrefix(refname, "refs/heads/")
+	refname = strings.TrimPrefix(refname, "refs/tags/")
+	refname = strings.ReplaceAll(refname, "/", "-")
+	refname = strings.ReplaceAll(refname, "_", "-")
+	refname = strings.ReplaceAll(refname, "@", "-")
+	refname = strings.ToLower(refname)
+	if refname == "" {
+		// we did not compute a sensible refname - use moniker
+		refname = moniker.New().NameSep("-")
+	}
+	name := cleanupPodName(fmt.Sprintf("%s-%s-%s", md.Repository.Repo, jobSpecName, refname))
+	if nameSuffix != "" {
+		name += "-" + nameSuffix
+	}
+
+	if refname != "" {
+		// we have a valid refname, hence need to acquire job number
+		t, err := srv.Groups.Next(name)
+		if err != nil {
+			return "", err
+		}
+
+		name = fmt.Sprintf("%s.%d", name, t)
+	}
+	return name, nil
+}
+
 func getRepoCfg(ctx context.Context, fp FileProvider) (*repoconfig.C, error) {
 	// download werft config from branch
 	werftYAML, err := fp.Download(ctx, PathWerftConfig)
@@ -338,18 +470,81 @@ func cleanupPodName(name string) string {
 	return name
 }
 
+// AnnotationRestartedFrom is set on jobs started from a previous one and points to that previous job
+const AnnotationRestartedFrom = "werft.restartedFrom"
+
+// setAnnotation sets an annotation, replacing an existing one with the same key
+func setAnnotation(annotations []*v1.Annotation, key, value string) []*v1.Annotation {
+	for _, a := range annotations {
+		if a.Key == key {
+			a.Value = value
+			return annotations
+		}
+	}
+	return append(annotations, &v1.Annotation{Key: key, Value: value})
+}
+
+// withDefaultAnnotations adds the default annotations of a repository which the job wasn't started with
+func withDefaultAnnotations(annotations []*v1.Annotation, defaults map[string]string) ([]*v1.Annotation, error) {
+	keys := make([]string, 0, len(defaults))
+	for k := range defaults {
+		err := validateAnnotationKey(k)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		var exists bool
+		for _, a := range annotations {
+			if a.Key == k {
+				exists = true
+				break
+			}
+		}
+		if exists {
+			continue
+		}
+		annotations = append(annotations, &v1.Annotation{Key: k, Value: defaults[k]})
+	}
+	return annotations, nil
+}
+
+// removeAnnotation removes all annotations with the key
+func removeAnnotation(annotations []*v1.Annotation, key string) []*v1.Annotation {
+	var res []*v1.Annotation
+	for _, a := range annotations {
+		if a.Key != key {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
 // StartFromPreviousJob starts a new job based on an old one
-func (srv *Service) StartFromPreviousJob(ctx context.Context, req *v1.StartFromPreviousJobRequest) (*v1.StartJobResponse, error) {
+func (srv *Service) StartFromPreviousJob(ctx context.Context, req *v1.StartFromPreviousJobRequest) (resp *v1.StartJobResponse, err error) {
 	oldJobStatus, err := srv.Jobs.Get(ctx, req.PreviousJob)
+	defer func() {
+		srv.auditLog(ctx, audit.Entry{Action: audit.ActionRestart, Job: startedJob(resp), PreviousJob: req.PreviousJob}, jobMetadata(oldJobStatus), err)
+	}()
 	if err == store.ErrNotFound {
-		return nil, status.Error(codes.NotFound, "job spec not found")
+		return nil, status.Errorf(codes.NotFound, "job %s not found", req.PreviousJob)
 	}
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
+	err = srv.authorizeJob(ctx, auth.ActionStart, oldJobStatus)
+	if err != nil {
+		return nil, err
+	}
+	if oldJobStatus.Conditions != nil && !oldJobStatus.Conditions.CanReplay {
+		return nil, status.Errorf(codes.FailedPrecondition, "job %s cannot be replayed: it was started from local or sideloaded content which was not retained", req.PreviousJob)
+	}
 	jobYAML, err := srv.Jobs.GetJobSpec(req.PreviousJob)
 	if err == store.ErrNotFound {
-		return nil, status.Error(codes.NotFound, "job spec not found")
+		return nil, status.Errorf(codes.FailedPrecondition, "job %s cannot be replayed: its job spec was not retained", req.PreviousJob)
 	}
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
@@ -367,7 +562,11 @@ func (srv *Service) StartFromPreviousJob(ctx context.Context, req *v1.StartFromP
 	name = fmt.Sprintf("%s.%d", name, nr)
 
 	md := oldJobStatus.Metadata
+	md.Started = nil
 	md.Finished = nil
+	md.Annotations = setAnnotation(md.Annotations, AnnotationRestartedFrom, req.PreviousJob)
+	// the jobs the previous job needed are done, hence the new job starts right away
+	md.Annotations = removeAnnotation(md.Annotations, AnnotationNeeds)
 	cp, err := srv.RepositoryProvider.ContentProvider(ctx, md.Repository)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
@@ -395,6 +594,52 @@ func (srv *Service) StartFromPreviousJob(ctx context.Context, req *v1.StartFromP
 	}, nil
 }
 
+// RetryPipeline restarts the failed jobs of a matrix job
+func (srv *Service) RetryPipeline(ctx context.Context, req *v1.RetryPipelineRequest) (resp *v1.RetryPipelineResponse, err error) {
+	parent, err := srv.Jobs.Get(ctx, req.Parent)
+	if err == store.ErrNotFound {
+		return nil, status.Errorf(codes.NotFound, "job %s not found", req.Parent)
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	err = srv.authorizeJob(ctx, auth.ActionStart, parent)
+	if err != nil {
+		return nil, err
+	}
+	if matrixJobs(parent) == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "job %s is not a pipeline: it has no matrix", req.Parent)
+	}
+	cp, err := srv.RepositoryProvider.ContentProvider(ctx, parent.Metadata.Repository)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	retries, err := srv.retryMatrixJobs(ctx, req.Parent)
+	if err != nil {
+		return nil, err
+	}
+
+	resp = &v1.RetryPipelineResponse{}
+	for _, r := range retries {
+		// a job which fails to start is stored as failed, which the matrix job reflects
+		_, err := srv.RunJob(ctx, r.Name, *r.Metadata, cp, r.JobYAML, true, time.Time{})
+		srv.auditLog(ctx, audit.Entry{Action: audit.ActionRestart, Job: r.Name, PreviousJob: r.PreviousJob}, r.Metadata, err)
+		if err != nil {
+			log.WithError(err).WithField("name", r.Name).WithField("matrix", req.Parent).Warn("cannot restart matrix job")
+		}
+		resp.Retried = append(resp.Retried, &v1.RetriedJob{PreviousJob: r.PreviousJob, Name: r.Name})
+	}
+
+	resp.Status, err = srv.updateMatrixJob(req.Parent)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	log.WithField("name", req.Parent).WithField("retried", len(retries)).Info("retried failed matrix jobs")
+	return resp, nil
+}
+
 // newTarStreamAdapter creates a reader from an incoming workspace tar stream
 func newTarStreamAdapter(inc v1.WerftService_StartLocalJobServer, initial []byte) io.Reader {
 	return &tarStreamAdapter{
@@ -436,19 +681,67 @@ func (tsa *tarStreamAdapter) Read(p []byte) (n int, err error) {
 
 // ListJobs lists jobs
 func (srv *Service) ListJobs(ctx context.Context, req *v1.ListJobsRequest) (resp *v1.ListJobsResponse, err error) {
-	result, total, err := srv.Jobs.Find(ctx, req.Filter, req.Order, int(req.Start), int(req.Limit))
+	err = filterexpr.ValidateGroup(req.LatestPerGroup)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var (
+		filter, order = req.Filter, req.Order
+		// pageOrder is nil if we cannot page through the jobs using a cursor
+		pageOrder, cursorErr = filterexpr.PaginationOrder(req.Order)
+	)
+	if len(req.LatestPerGroup) > 0 {
+		// jobs after the cursor would form groups of their own
+		pageOrder, cursorErr = nil, xerrors.Errorf("cannot page through the latest jobs per group using a cursor")
+	}
+	if pageOrder != nil {
+		order = pageOrder
+	}
+	if req.Cursor != "" {
+		if cursorErr != nil {
+			return nil, status.Error(codes.InvalidArgument, cursorErr.Error())
+		}
+		if req.Start != 0 {
+			return nil, status.Error(codes.InvalidArgument, "cannot combine cursor and start")
+		}
+		after, err := filterexpr.CursorFilter(req.Cursor, pageOrder)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		filter = append(append([]*v1.FilterExpression{}, filter...), after...)
+	}
+
+	// the store must only page through and count the jobs the caller may read
+	readable, none := srv.readFilter(ctx)
+	if none {
+		return &v1.ListJobsResponse{}, nil
+	}
+	if readable != nil {
+		filter = append(append([]*v1.FilterExpression{}, filter...), readable)
+	}
+
+	result, total, err := srv.Jobs.Find(ctx, filter, order, req.LatestPerGroup, int(req.Start), int(req.Limit))
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	res := make([]*v1.JobStatus, len(result))
+	var nextCursor string
+	if pageOrder != nil && req.Limit > 0 && len(result) == int(req.Limit) {
+		nextCursor = filterexpr.NewCursor(&result[len(result)-1], pageOrder)
+	}
+
+	res := make([]*v1.JobStatus, 0, len(result))
 	for i := range result {
-		res[i] = &result[i]
+		// the effective spec is large and only of interest for a single job, see GetJob
+		result[i].EffectiveSpec = ""
+		res = append(res, &result[i])
 	}
 
 	return &v1.ListJobsResponse{
-		Total:  int32(total),
-		Result: res,
+		Total:      int32(total),
+		Result:     res,
+		NextCursor: nextCursor,
 	}, nil
 }
 
@@ -457,9 +750,13 @@ func (srv *Service) Subscribe(req *v1.SubscribeRequest, resp v1.WerftService_Sub
 	evts := srv.events.On("job")
 	for evt := range evts {
 		job := evt.Args[0].(*v1.JobStatus)
-		if !filterexpr.MatchesFilter(job, req.Filter) {
+		if !filterexpr.MatchesFilter(job, req.Filter) || !srv.canRead(resp.Context(), job) {
 			continue
 		}
+		if job.EffectiveSpec != "" {
+			job = proto.Clone(job).(*v1.JobStatus)
+			job.EffectiveSpec = ""
+		}
 
 		resp.Send(&v1.SubscribeResponse{
 			Result: job,
@@ -471,15 +768,26 @@ func (srv *Service) Subscribe(req *v1.SubscribeRequest, resp v1.WerftService_Sub
 // GetJob returns the information about a particular job
 func (srv *Service) GetJob(ctx context.Context, req *v1.GetJobRequest) (resp *v1.GetJobResponse, err error) {
 	job, err := srv.Jobs.Get(ctx, req.Name)
+	if err == store.ErrNotFound {
+		return nil, status.Errorf(codes.NotFound, "%s not found", req.Name)
+	}
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 	if job == nil {
 		return nil, status.Error(codes.NotFound, "not found")
 	}
+	err = srv.authorizeJob(ctx, auth.ActionRead, job)
+	if err != nil {
+		return nil, err
+	}
+
+	// not all jobs have their spec stored (e.g. if they can't be replayed), hence we ignore errors here
+	spec, _ := srv.Jobs.GetJobSpec(req.Name)
 
 	return &v1.GetJobResponse{
-		Result: job,
+		Result:  job,
+		JobSpec: string(spec),
 	}, nil
 }
 
@@ -490,6 +798,16 @@ func (srv *Service) Listen(req *v1.ListenRequest, ls v1.WerftService_ListenServe
 	if err == store.ErrNotFound {
 		return status.Errorf(codes.NotFound, "%s not found", req.Name)
 	}
+	if err == nil {
+		err = srv.authorizeJob(ls.Context(), auth.ActionRead, job)
+		if err != nil {
+			return err
+		}
+	}
+
+	if req.Section != "" && (req.Logs == v1.ListenRequestLogs_LOGS_DISABLED || req.Logs == v1.ListenRequestLogs_LOGS_UNSLICED) {
+		return status.Error(codes.InvalidArgument, "section requires sliced logs")
+	}
 
 	var (
 		wg      sync.WaitGroup
@@ -500,21 +818,22 @@ func (srv *Service) Listen(req *v1.ListenRequest, ls v1.WerftService_ListenServe
 		wg.Add(1)
 		logwg.Add(1)
 
-		rd, err := srv.Logs.Read(req.Name)
+		rd, cutter, err := srv.readLogs(req.Name, req.Section)
 		if err != nil {
 			if err == store.ErrNotFound {
-				return status.Error(codes.NotFound, "not found")
+				// the job exists, but its logs may have been deleted by the log retention
+				return status.Errorf(codes.NotFound, "logs of %s are not available", req.Name)
 			}
 
 			return status.Error(codes.Internal, err.Error())
 		}
 
+		// a slow listener must not hold up reading the log, hence we buffer what it hasn't received yet
+		buf := newLogBuffer(srv.Config.LogBuffer)
 		go func() {
 			defer rd.Close()
-			defer wg.Done()
-			defer logwg.Done()
+			defer buf.Close()
 
-			cutter := logcutter.DefaultCutter
 			if req.Logs == v1.ListenRequestLogs_LOGS_UNSLICED {
 				cutter = logcutter.NoCutter
 			}
@@ -526,28 +845,47 @@ func (srv *Service) Listen(req *v1.ListenRequest, ls v1.WerftService_ListenServe
 					if evt == nil {
 						return
 					}
+					if req.Section != "" && !logcutter.InSection(evt.Name, req.Section) {
+						continue
+					}
 					if req.Logs == v1.ListenRequestLogs_LOGS_HTML {
 						evt.Payload = string(termtohtml.Render([]byte(evt.Payload)))
 					}
 
-					err = ls.Send(&v1.ListenResponse{
-						Content: &v1.ListenResponse_Slice{
-							Slice: evt,
-						},
-					})
-				case err = <-echan:
+					if !buf.Put(evt) {
+						return
+					}
+				case err := <-echan:
 					if err == nil {
 						return
 					}
 
+					buf.Abort()
 					errchan <- status.Error(codes.Internal, err.Error())
 					return
 				case <-ls.Context().Done():
+					buf.Abort()
 					errchan <- status.Error(codes.Aborted, ls.Context().Err().Error())
 					return
 				}
 			}
 		}()
+		go func() {
+			defer wg.Done()
+			defer logwg.Done()
+
+			for evt := buf.Get(); evt != nil; evt = buf.Get() {
+				err := ls.Send(&v1.ListenResponse{
+					Content: &v1.ListenResponse_Slice{
+						Slice: evt,
+					},
+				})
+				if err != nil {
+					buf.Abort()
+					return
+				}
+			}
+		}()
 	}
 
 	if req.Updates {
@@ -600,24 +938,307 @@ func (srv *Service) Listen(req *v1.ListenRequest, ls v1.WerftService_ListenServe
 	return err
 }
 
+// downloadChunkSize is the maximum size of a single log chunk sent by DownloadLogs
+const downloadChunkSize = 32 * 1024
+
+// readLogs opens the logs of a job for cutting. If section isn't empty and the log store indexes sections,
+// we skip the log up to where the section starts.
+func (srv *Service) readLogs(name, section string) (io.ReadCloser, logcutter.Cutter, error) {
+	if sl, ok := srv.Logs.(store.SectionLogs); ok && section != "" {
+		rd, phase, err := sl.ReadSection(name, section)
+		if err != nil {
+			return nil, nil, err
+		}
+		return rd, logcutter.PhaseCutter(phase), nil
+	}
+
+	rd, err := srv.Logs.Read(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rd, logcutter.DefaultCutter, nil
+}
+
+// DownloadLogs sends the complete log of a job
+func (srv *Service) DownloadLogs(req *v1.DownloadLogsRequest, resp v1.WerftService_DownloadLogsServer) error {
+	job, err := srv.Jobs.Get(resp.Context(), req.Name)
+	if err == store.ErrNotFound {
+		return status.Errorf(codes.NotFound, "%s not found", req.Name)
+	}
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	err = srv.authorizeJob(resp.Context(), auth.ActionRead, job)
+	if err != nil {
+		return err
+	}
+
+	rd, cutter, err := srv.readLogs(req.Name, req.Section)
+	if err == store.ErrNotFound {
+		return status.Errorf(codes.NotFound, "logs of %s are not available", req.Name)
+	}
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	defer rd.Close()
+
+	if req.Section != "" {
+		return downloadLogSection(rd, cutter, req, resp)
+	}
+
+	buf := make([]byte, downloadChunkSize)
+	for {
+		n, err := rd.Read(buf)
+		if n > 0 {
+			serr := resp.Send(&v1.DownloadLogsResponse{Data: buf[:n]})
+			if serr != nil {
+				return serr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+}
+
+// downloadLogSection sends the content of a single log section and its nested sections
+func downloadLogSection(rd io.Reader, cutter logcutter.Cutter, req *v1.DownloadLogsRequest, resp v1.WerftService_DownloadLogsServer) error {
+	var (
+		buf   bytes.Buffer
+		found bool
+	)
+	flush := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+		err := resp.Send(&v1.DownloadLogsResponse{Data: buf.Bytes()})
+		buf.Reset()
+		return err
+	}
+
+	evts, errs := cutter.Slice(rd)
+	for {
+		select {
+		case evt := <-evts:
+			if evt == nil {
+				if !found {
+					return status.Errorf(codes.NotFound, "section %s not found in logs of %s", req.Section, req.Name)
+				}
+				return flush()
+			}
+			if !logcutter.InSection(evt.Name, req.Section) {
+				continue
+			}
+			found = true
+			if evt.Type != v1.LogSliceType_SLICE_CONTENT {
+				continue
+			}
+
+			buf.WriteString(evt.Payload)
+			buf.WriteString("\n")
+			if buf.Len() >= downloadChunkSize {
+				err := flush()
+				if err != nil {
+					return err
+				}
+			}
+		case err := <-errs:
+			if err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+		case <-resp.Context().Done():
+			return status.Error(codes.Aborted, resp.Context().Err().Error())
+		}
+	}
+}
+
 // StopJob stops a running job
-func (srv *Service) StopJob(ctx context.Context, req *v1.StopJobRequest) (*v1.StopJobResponse, error) {
+func (srv *Service) StopJob(ctx context.Context, req *v1.StopJobRequest) (resp *v1.StopJobResponse, err error) {
 	job, err := srv.Jobs.Get(ctx, req.Name)
+	defer func() {
+		srv.auditLog(ctx, audit.Entry{Action: audit.ActionStop, Job: req.Name}, jobMetadata(job), err)
+	}()
+	if err == store.ErrNotFound {
+		return nil, status.Errorf(codes.NotFound, "%s not found", req.Name)
+	}
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 	if job == nil {
 		return nil, status.Error(codes.NotFound, "not found")
 	}
+	err = srv.authorizeJob(ctx, auth.ActionStop, job)
+	if err != nil {
+		return nil, err
+	}
 
-	if job.Phase != v1.JobPhase_PHASE_WAITING && job.Phase != v1.JobPhase_PHASE_PREPARING && job.Phase != v1.JobPhase_PHASE_STARTING && job.Phase != v1.JobPhase_PHASE_RUNNING {
+	if !isStoppable(job.Phase) {
 		return nil, status.Error(codes.FailedPrecondition, "job is in unstoppable phase")
 	}
 
-	err = srv.Executor.Stop(req.Name, "job was stopped manually")
+	reason := "job was stopped manually"
+	if req.Reason != "" {
+		reason += ": " + req.Reason
+	}
+	if jobs := matrixJobs(job); jobs != nil {
+		err = srv.stopMatrix(ctx, jobs, reason)
+	} else {
+		err = srv.Executor.Stop(req.Name, reason)
+	}
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
 	return &v1.StopJobResponse{}, nil
 }
+
+// stoppablePhases are the phases of jobs which have not finished yet
+var stoppablePhases = []v1.JobPhase{
+	v1.JobPhase_PHASE_QUEUED,
+	v1.JobPhase_PHASE_WAITING,
+	v1.JobPhase_PHASE_PREPARING,
+	v1.JobPhase_PHASE_STARTING,
+	v1.JobPhase_PHASE_RUNNING,
+}
+
+func isStoppable(phase v1.JobPhase) bool {
+	for _, p := range stoppablePhases {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
+
+// StopJobs stops all running jobs which match a filter
+func (srv *Service) StopJobs(ctx context.Context, req *v1.StopJobsRequest) (resp *v1.StopJobsResponse, err error) {
+	if len(req.Filter) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "filter must not be empty - refusing to stop all jobs")
+	}
+
+	running := &v1.FilterExpression{}
+	for _, p := range stoppablePhases {
+		running.Terms = append(running.Terms, &v1.FilterTerm{Field: "phase", Value: filterexpr.NormalizePhase(p.String()), Operation: v1.FilterOp_OP_EQUALS})
+	}
+	filter := append(append([]*v1.FilterExpression{}, req.Filter...), running)
+	jobs, _, err := srv.Jobs.Find(ctx, filter, []*v1.OrderExpression{{Field: "created", Ascending: true}}, nil, 0, 0)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp = &v1.StopJobsResponse{}
+	for i := range jobs {
+		job := &jobs[i]
+		if !srv.canRead(ctx, job) {
+			continue
+		}
+
+		if req.DryRun {
+			err = srv.authorizeJob(ctx, auth.ActionStop, job)
+		} else {
+			// StopJob authorizes and audits each job on its own
+			_, err = srv.StopJob(ctx, &v1.StopJobRequest{Name: job.Name, Reason: req.Reason})
+		}
+		switch status.Code(err) {
+		case codes.OK:
+			resp.Stopped = append(resp.Stopped, job.Name)
+		case codes.FailedPrecondition, codes.NotFound:
+			// the job finished since we found it
+			resp.Skipped = append(resp.Skipped, job.Name)
+		default:
+			resp.Failed = append(resp.Failed, &v1.StopJobsFailure{Name: job.Name, Error: status.Convert(err).Message()})
+		}
+	}
+	return resp, nil
+}
+
+// ReservedAnnotationPrefix prefixes the annotations werft sets itself, which cannot be changed through SetJobAnnotations
+const ReservedAnnotationPrefix = "werft."
+
+// annotationKeyPattern describes valid annotation keys, e.g. release or deploy.target
+var annotationKeyPattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9_./-]{0,251}[a-zA-Z0-9])?$`)
+
+// validateAnnotationKey returns an InvalidArgument error if the key is malformed or reserved
+func validateAnnotationKey(key string) error {
+	if !annotationKeyPattern.MatchString(key) {
+		return status.Errorf(codes.InvalidArgument, "invalid annotation key %q: must consist of alphanumeric characters, '-', '_', '.' or '/', and start and end with an alphanumeric character", key)
+	}
+	if strings.HasPrefix(key, ReservedAnnotationPrefix) {
+		return status.Errorf(codes.InvalidArgument, "annotation %s is reserved for werft", key)
+	}
+	return nil
+}
+
+// SetJobAnnotations adds, changes or removes annotations of an existing job
+func (srv *Service) SetJobAnnotations(ctx context.Context, req *v1.SetJobAnnotationsRequest) (resp *v1.SetJobAnnotationsResponse, err error) {
+	srv.annotationsMu.Lock()
+	defer srv.annotationsMu.Unlock()
+
+	job, err := srv.Jobs.Get(ctx, req.Name)
+	defer func() {
+		srv.auditLog(ctx, audit.Entry{Action: audit.ActionAnnotate, Job: req.Name}, jobMetadata(job), err)
+	}()
+	if err == store.ErrNotFound {
+		return nil, status.Errorf(codes.NotFound, "%s not found", req.Name)
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if job == nil || job.Metadata == nil {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	err = srv.authorizeJob(ctx, auth.ActionAnnotate, job)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Annotations) == 0 && len(req.Remove) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no annotations to set or remove")
+	}
+	for _, a := range req.Annotations {
+		err = validateAnnotationKey(a.Key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	remove := make(map[string]struct{}, len(req.Remove))
+	for _, k := range req.Remove {
+		err = validateAnnotationKey(k)
+		if err != nil {
+			return nil, err
+		}
+		remove[k] = struct{}{}
+	}
+
+	annotations := make([]*v1.Annotation, 0, len(job.Metadata.Annotations)+len(req.Annotations))
+	for _, a := range job.Metadata.Annotations {
+		if _, ok := remove[a.Key]; ok {
+			continue
+		}
+		annotations = append(annotations, &v1.Annotation{Key: a.Key, Value: a.Value})
+	}
+	for _, a := range req.Annotations {
+		annotations = setAnnotation(annotations, a.Key, a.Value)
+	}
+
+	// Jobs which haven't finished yet get their metadata from their pod. Were we to change the store only,
+	// the next status update would undo the change.
+	if srv.Executor != nil {
+		err = srv.Executor.SetAnnotations(job.Name, annotations)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	job.Metadata.Annotations = annotations
+	err = srv.Jobs.Store(ctx, *job)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	<-srv.events.Emit("job", job)
+
+	return &v1.SetJobAnnotationsResponse{Status: job}, nil
+}
@@ -1,6 +1,29 @@
 package werft
 
-import "testing"
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/executor"
+	"github.com/csweichel/werft/pkg/filterexpr"
+	"github.com/csweichel/werft/pkg/logcutter"
+	"github.com/csweichel/werft/pkg/store"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
 
 func TestCleanupPodName(t *testing.T) {
 	tests := []struct {
@@ -23,3 +46,608 @@ func TestCleanupPodName(t *testing.T) {
 		})
 	}
 }
+
+type downloadLogsServer struct {
+	grpc.ServerStream
+	Data []byte
+}
+
+func (s *downloadLogsServer) Context() context.Context {
+	return context.Background()
+}
+
+func (s *downloadLogsServer) Send(resp *v1.DownloadLogsResponse) error {
+	s.Data = append(s.Data, resp.Data...)
+	return nil
+}
+
+func TestDownloadLogsSection(t *testing.T) {
+	const joblog = `[build|PHASE] building
+[build] preparing
+[build|compile] compiling foo
+[test] testing foo
+[build|compile|lib] compiling lib
+[build|DONE]
+`
+	base, err := ioutil.TempDir(os.TempDir(), "tdls")
+	if err != nil {
+		t.Fatalf("cannot create test folder: %v", err)
+	}
+	defer os.RemoveAll(base)
+	logs, err := store.NewFileLogStore(base)
+	if err != nil {
+		t.Fatalf("cannot create log store: %v", err)
+	}
+	w, err := logs.Open("foo")
+	if err != nil {
+		t.Fatalf("cannot place log: %v", err)
+	}
+	w.Write([]byte(joblog))
+	w.Close()
+
+	jobs := store.NewInMemoryJobStore()
+	err = jobs.Store(context.Background(), v1.JobStatus{Name: "foo"})
+	if err != nil {
+		t.Fatalf("cannot store job: %v", err)
+	}
+	srv := &Service{Logs: logs, Jobs: jobs}
+
+	tests := []struct {
+		Section     string
+		Expectation string
+		Code        codes.Code
+	}{
+		{"", joblog, codes.OK},
+		{"build", "preparing\ncompiling foo\ncompiling lib\n", codes.OK},
+		{"build|compile", "compiling foo\ncompiling lib\n", codes.OK},
+		{"test", "testing foo\n", codes.OK},
+		{"bui", "", codes.NotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.Section, func(t *testing.T) {
+			resp := &downloadLogsServer{}
+			err := srv.DownloadLogs(&v1.DownloadLogsRequest{Name: "foo", Section: test.Section}, resp)
+			if status.Code(err) != test.Code {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if act := string(resp.Data); act != test.Expectation {
+				t.Errorf("unexpected log: %q, expected %q", act, test.Expectation)
+			}
+		})
+	}
+}
+
+func TestSetJobAnnotations(t *testing.T) {
+	annotations := func(kv ...string) []*v1.Annotation {
+		var res []*v1.Annotation
+		for i := 0; i < len(kv); i += 2 {
+			res = append(res, &v1.Annotation{Key: kv[i], Value: kv[i+1]})
+		}
+		return res
+	}
+
+	tests := []struct {
+		Name        string
+		Req         *v1.SetJobAnnotationsRequest
+		Expectation []*v1.Annotation
+		Code        codes.Code
+	}{
+		{
+			Name:        "add",
+			Req:         &v1.SetJobAnnotationsRequest{Annotations: annotations("release", "v1.0.0", "notify", "")},
+			Expectation: annotations("werft.restartedFrom", "foo.0", "env", "staging", "release", "v1.0.0", "notify", ""),
+		},
+		{
+			Name:        "overwrite",
+			Req:         &v1.SetJobAnnotationsRequest{Annotations: annotations("env", "production")},
+			Expectation: annotations("werft.restartedFrom", "foo.0", "env", "production"),
+		},
+		{
+			Name:        "remove",
+			Req:         &v1.SetJobAnnotationsRequest{Remove: []string{"env", "does-not-exist"}},
+			Expectation: annotations("werft.restartedFrom", "foo.0"),
+		},
+		{
+			Name:        "add and remove",
+			Req:         &v1.SetJobAnnotationsRequest{Annotations: annotations("deploy.target", "eu-west/1"), Remove: []string{"env"}},
+			Expectation: annotations("werft.restartedFrom", "foo.0", "deploy.target", "eu-west/1"),
+		},
+		{Name: "unknown job", Req: &v1.SetJobAnnotationsRequest{Name: "bar", Remove: []string{"env"}}, Code: codes.NotFound},
+		{Name: "nothing to do", Req: &v1.SetJobAnnotationsRequest{}, Code: codes.InvalidArgument},
+		{Name: "reserved key", Req: &v1.SetJobAnnotationsRequest{Annotations: annotations("werft.traceID", "1234")}, Code: codes.InvalidArgument},
+		{Name: "remove reserved key", Req: &v1.SetJobAnnotationsRequest{Remove: []string{"werft.restartedFrom"}}, Code: codes.InvalidArgument},
+		{Name: "empty key", Req: &v1.SetJobAnnotationsRequest{Annotations: annotations("", "foo")}, Code: codes.InvalidArgument},
+		{Name: "invalid key", Req: &v1.SetJobAnnotationsRequest{Annotations: annotations("foo bar", "")}, Code: codes.InvalidArgument},
+		{Name: "key ends with dot", Req: &v1.SetJobAnnotationsRequest{Annotations: annotations("foo.", "")}, Code: codes.InvalidArgument},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			jobs := store.NewInMemoryJobStore()
+			err := jobs.Store(context.Background(), v1.JobStatus{
+				Name:     "foo",
+				Phase:    v1.JobPhase_PHASE_DONE,
+				Metadata: &v1.JobMetadata{Annotations: annotations("werft.restartedFrom", "foo.0", "env", "staging")},
+			})
+			if err != nil {
+				t.Fatalf("cannot store job: %v", err)
+			}
+			srv := &Service{Jobs: jobs}
+
+			updates := make(chan *v1.JobStatus, 1)
+			evts := srv.events.On("job")
+			defer srv.events.Off("job", evts)
+			go func() {
+				for evt := range evts {
+					updates <- evt.Args[0].(*v1.JobStatus)
+				}
+			}()
+
+			if test.Req.Name == "" {
+				test.Req.Name = "foo"
+			}
+			resp, err := srv.SetJobAnnotations(context.Background(), test.Req)
+			if status.Code(err) != test.Code {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.Code != codes.OK {
+				return
+			}
+
+			if !reflect.DeepEqual(resp.Status.Metadata.Annotations, test.Expectation) {
+				t.Errorf("unexpected annotations: %v, expected %v", resp.Status.Metadata.Annotations, test.Expectation)
+			}
+			stored, err := jobs.Get(context.Background(), "foo")
+			if err != nil {
+				t.Fatalf("cannot get job: %v", err)
+			}
+			if !reflect.DeepEqual(stored.Metadata.Annotations, test.Expectation) {
+				t.Errorf("unexpected stored annotations: %v, expected %v", stored.Metadata.Annotations, test.Expectation)
+			}
+			select {
+			case update := <-updates:
+				if !reflect.DeepEqual(update.Metadata.Annotations, test.Expectation) {
+					t.Errorf("unexpected annotations in status update: %v, expected %v", update.Metadata.Annotations, test.Expectation)
+				}
+			case <-time.After(time.Second):
+				t.Errorf("no status update")
+			}
+		})
+	}
+}
+
+func TestWithDefaultAnnotations(t *testing.T) {
+	annotations := func(kv ...string) []*v1.Annotation {
+		var res []*v1.Annotation
+		for i := 0; i < len(kv); i += 2 {
+			res = append(res, &v1.Annotation{Key: kv[i], Value: kv[i+1]})
+		}
+		return res
+	}
+
+	tests := []struct {
+		Name        string
+		Annotations []*v1.Annotation
+		Defaults    map[string]string
+		Expectation []*v1.Annotation
+		Code        codes.Code
+	}{
+		{Name: "no defaults", Annotations: annotations("env", "staging"), Expectation: annotations("env", "staging")},
+		{
+			Name:        "defaults only",
+			Defaults:    map[string]string{"team": "platform", "owner": "csweichel"},
+			Expectation: annotations("owner", "csweichel", "team", "platform"),
+		},
+		{
+			Name:        "job annotations take precedence",
+			Annotations: annotations("team", "release", "env", "staging"),
+			Defaults:    map[string]string{"team": "platform", "owner": "csweichel"},
+			Expectation: annotations("team", "release", "env", "staging", "owner", "csweichel"),
+		},
+		{
+			Name:        "empty job annotation takes precedence",
+			Annotations: annotations("team", ""),
+			Defaults:    map[string]string{"team": "platform"},
+			Expectation: annotations("team", ""),
+		},
+		{
+			Name:        "trigger annotations take precedence",
+			Annotations: annotations("updateGitHubStatus", "csweichel/werft", "github.author", "octocat"),
+			Defaults:    map[string]string{"github.author": "nobody", "team": "platform"},
+			Expectation: annotations("updateGitHubStatus", "csweichel/werft", "github.author", "octocat", "team", "platform"),
+		},
+		{Name: "reserved key", Defaults: map[string]string{"werft.fingerprint": "foo"}, Code: codes.InvalidArgument},
+		{Name: "invalid key", Defaults: map[string]string{"foo bar": ""}, Code: codes.InvalidArgument},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			act, err := withDefaultAnnotations(test.Annotations, test.Defaults)
+			if status.Code(err) != test.Code {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.Code != codes.OK {
+				return
+			}
+			if !reflect.DeepEqual(act, test.Expectation) {
+				t.Errorf("unexpected annotations: %v, expected %v", act, test.Expectation)
+			}
+		})
+	}
+}
+
+type listenServer struct {
+	grpc.ServerStream
+	Slices []*v1.LogSliceEvent
+}
+
+func (s *listenServer) Context() context.Context {
+	return context.Background()
+}
+
+func (s *listenServer) Send(resp *v1.ListenResponse) error {
+	if slice := resp.GetSlice(); slice != nil {
+		s.Slices = append(s.Slices, slice)
+	}
+	return nil
+}
+
+func TestListenSection(t *testing.T) {
+	const joblog = `[build|PHASE] building
+preparing
+[build|compile] compiling foo
+[lint] linting foo
+[test|PHASE] testing
+go test ./...
+[test|unit] ok foo
+[build|compile] compiling bar
+`
+	base, err := ioutil.TempDir(os.TempDir(), "tls")
+	if err != nil {
+		t.Fatalf("cannot create test folder: %v", err)
+	}
+	defer os.RemoveAll(base)
+	logs, err := store.NewFileLogStore(base)
+	if err != nil {
+		t.Fatalf("cannot create log store: %v", err)
+	}
+	w, err := logs.Open("foo")
+	if err != nil {
+		t.Fatalf("cannot place log: %v", err)
+	}
+	w.Write([]byte(joblog))
+	w.Close()
+
+	jobs := store.NewInMemoryJobStore()
+	err = jobs.Store(context.Background(), v1.JobStatus{Name: "foo", Phase: v1.JobPhase_PHASE_DONE})
+	if err != nil {
+		t.Fatalf("cannot store job: %v", err)
+	}
+
+	tests := []struct {
+		Name        string
+		Section     string
+		Logs        v1.ListenRequestLogs
+		Expectation []string
+		Code        codes.Code
+	}{
+		{Name: "build", Section: "build", Logs: v1.ListenRequestLogs_LOGS_RAW, Expectation: []string{"build:preparing", "build|compile:compiling foo", "build|compile:compiling bar"}},
+		{Name: "nested", Section: "build|compile", Logs: v1.ListenRequestLogs_LOGS_RAW, Expectation: []string{"build|compile:compiling foo", "build|compile:compiling bar"}},
+		{Name: "phase", Section: "test", Logs: v1.ListenRequestLogs_LOGS_RAW, Expectation: []string{"test:go test ./...", "test|unit:ok foo"}},
+		{Name: "not started", Section: "deploy", Logs: v1.ListenRequestLogs_LOGS_RAW},
+		{Name: "unsliced", Section: "build", Logs: v1.ListenRequestLogs_LOGS_UNSLICED, Code: codes.InvalidArgument},
+	}
+	// log stores which don't index sections are read from the start
+	for name, logs := range map[string]store.Logs{"indexed": logs, "unindexed": unindexedLogs{logs}} {
+		srv := &Service{Logs: logs, Jobs: jobs}
+		for _, test := range tests {
+			t.Run(name+"/"+test.Name, func(t *testing.T) {
+				resp := &listenServer{}
+				err := srv.Listen(&v1.ListenRequest{Name: "foo", Logs: test.Logs, Section: test.Section}, resp)
+				if status.Code(err) != test.Code {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				var content []string
+				for _, s := range resp.Slices {
+					if !logcutter.InSection(s.Name, test.Section) {
+						t.Errorf("received slice outside of section: %v", s)
+					}
+					if s.Type == v1.LogSliceType_SLICE_CONTENT {
+						content = append(content, s.Name+":"+s.Payload)
+					}
+				}
+				if !reflect.DeepEqual(content, test.Expectation) {
+					t.Errorf("unexpected log: %v, expected %v", content, test.Expectation)
+				}
+			})
+		}
+	}
+}
+
+type unindexedLogs struct {
+	store.Logs
+}
+
+func TestShutdownDrainsListen(t *testing.T) {
+	base, err := ioutil.TempDir(os.TempDir(), "tsdl")
+	if err != nil {
+		t.Fatalf("cannot create test folder: %v", err)
+	}
+	defer os.RemoveAll(base)
+	logs, err := store.NewFileLogStore(base)
+	if err != nil {
+		t.Fatalf("cannot create log store: %v", err)
+	}
+	w, err := logs.Open("foo")
+	if err != nil {
+		t.Fatalf("cannot place log: %v", err)
+	}
+	w.Write([]byte("[build] compiling foo\n"))
+
+	jobs := store.NewInMemoryJobStore()
+	err = jobs.Store(context.Background(), v1.JobStatus{Name: "foo", Phase: v1.JobPhase_PHASE_RUNNING})
+	if err != nil {
+		t.Fatalf("cannot store job: %v", err)
+	}
+	srv := &Service{Logs: logs, Jobs: jobs, logListener: map[string]*jobLog{"foo": {LogStore: w}}}
+
+	resp := &listenServer{}
+	errchan := make(chan error, 1)
+	go func() {
+		errchan <- srv.Listen(&v1.ListenRequest{Name: "foo", Logs: v1.ListenRequestLogs_LOGS_RAW, Updates: true}, resp)
+	}()
+	for i := 0; len(srv.events.Listeners("job")) == 0; i++ {
+		if i > 100 {
+			t.Fatal("Listen does not listen for updates")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the job writes more logs right before we shut down - the stream must receive them nonetheless
+	w.Write([]byte("[build] compiling bar\n"))
+	err = srv.Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("cannot shut down: %v", err)
+	}
+
+	select {
+	case err := <-errchan:
+		if err != nil {
+			t.Fatalf("Listen failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Listen did not return after shutdown")
+	}
+
+	var payloads []string
+	for _, s := range resp.Slices {
+		if s.Type != v1.LogSliceType_SLICE_CONTENT {
+			continue
+		}
+		payloads = append(payloads, s.Payload)
+	}
+	expected := []string{"compiling foo", "compiling bar"}
+	if !reflect.DeepEqual(payloads, expected) {
+		t.Errorf("unexpected log slices: %v, expected %v", payloads, expected)
+	}
+}
+
+func TestListJobsCursor(t *testing.T) {
+	jobs := store.NewInMemoryJobStore()
+	storeJob := func(name string, created int64) {
+		err := jobs.Store(context.Background(), v1.JobStatus{
+			Name:     name,
+			Metadata: &v1.JobMetadata{Owner: "foo", Created: &timestamp.Timestamp{Seconds: created}},
+		})
+		if err != nil {
+			t.Fatalf("cannot store job: %v", err)
+		}
+	}
+	storeJob("a", 1000)
+	storeJob("b", 2000)
+	storeJob("c", 2000)
+	storeJob("d", 3000)
+	storeJob("e", 4000)
+	srv := &Service{Jobs: jobs}
+
+	order := []*v1.OrderExpression{{Field: "created"}}
+	var (
+		names  []string
+		cursor string
+	)
+	for i := 0; ; i++ {
+		if i > 5 {
+			t.Fatal("paging did not terminate")
+		}
+		resp, err := srv.ListJobs(context.Background(), &v1.ListJobsRequest{Order: order, Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("cannot list jobs: %v", err)
+		}
+		for _, j := range resp.Result {
+			names = append(names, j.Name)
+		}
+		if i == 0 {
+			// new jobs must neither shift the following pages nor show up on them
+			storeJob("f", 5000)
+			storeJob("g", 2500)
+		}
+		cursor = resp.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+	expected := []string{"e", "d", "g", "b", "c", "a"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("unexpected jobs: %v, expected %v", names, expected)
+	}
+}
+
+func TestEffectiveSpecOnlyInGetJob(t *testing.T) {
+	const spec = "pod:\n  containers:\n  - image: golang:1.17\n    name: build\n"
+	jobs := store.NewInMemoryJobStore()
+	err := jobs.Store(context.Background(), v1.JobStatus{
+		Name:          "werft-build-main.1",
+		Metadata:      &v1.JobMetadata{Owner: "foo", Created: &timestamp.Timestamp{Seconds: 1000}},
+		EffectiveSpec: spec,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &Service{Jobs: jobs}
+
+	get, err := srv.GetJob(context.Background(), &v1.GetJobRequest{Name: "werft-build-main.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if get.Result.EffectiveSpec != spec {
+		t.Errorf("GetJob must return the effective spec, got %q", get.Result.EffectiveSpec)
+	}
+
+	list, err := srv.ListJobs(context.Background(), &v1.ListJobsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Result) != 1 || list.Result[0].EffectiveSpec != "" {
+		t.Errorf("ListJobs must not return the effective spec: %v", list.Result)
+	}
+}
+
+func TestListJobsCursorInvalid(t *testing.T) {
+	srv := &Service{Jobs: store.NewInMemoryJobStore()}
+	resp, err := srv.ListJobs(context.Background(), &v1.ListJobsRequest{Limit: 1})
+	if err != nil {
+		t.Fatalf("cannot list jobs: %v", err)
+	}
+	if resp.NextCursor != "" {
+		t.Errorf("unexpected cursor for an incomplete page: %q", resp.NextCursor)
+	}
+	cursor := "eyJvIjpbIm5hbWU6YXNjIl0sInYiOlsiYSJdfQ" // {"o":["name:asc"],"v":["a"]}
+
+	tests := []struct {
+		Name string
+		Req  *v1.ListJobsRequest
+	}{
+		{Name: "garbage", Req: &v1.ListJobsRequest{Cursor: "foobar"}},
+		{Name: "start", Req: &v1.ListJobsRequest{Cursor: cursor, Start: 10}},
+		{Name: "latest per group", Req: &v1.ListJobsRequest{Cursor: cursor, LatestPerGroup: []string{"repo.repo"}}},
+		{Name: "duration", Req: &v1.ListJobsRequest{Cursor: cursor, Order: []*v1.OrderExpression{{Field: "duration"}}}},
+		{Name: "other order", Req: &v1.ListJobsRequest{Cursor: cursor, Order: []*v1.OrderExpression{{Field: "created"}}}},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			_, err := srv.ListJobs(context.Background(), test.Req)
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("expected InvalidArgument, got %v", err)
+			}
+		})
+	}
+}
+
+func TestStopJobs(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Filter   []string
+		DryRun   bool
+		Expected *v1.StopJobsResponse
+		Stopped  []string
+	}{
+		{
+			Name:     "stops running jobs",
+			Filter:   []string{"repo.repo==werft"},
+			Expected: &v1.StopJobsResponse{Stopped: []string{"werft.1", "werft.2"}, Failed: []*v1.StopJobsFailure{{Name: "werft.4", Error: "unknown job: werft.4: unknown job"}}},
+			Stopped:  []string{"werft.1", "werft.2"},
+		},
+		{
+			Name:     "dry run",
+			Filter:   []string{"repo.repo==werft"},
+			DryRun:   true,
+			Expected: &v1.StopJobsResponse{Stopped: []string{"werft.1", "werft.2", "werft.4"}},
+		},
+		{
+			Name:     "done jobs are skipped",
+			Filter:   []string{"name==werft.3"},
+			Expected: &v1.StopJobsResponse{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			exec, err := executor.NewExecutor(executor.Config{
+				LabelPrefix:     "werft.test",
+				JobPrepTimeout:  &executor.Duration{Duration: 10 * time.Minute},
+				JobTotalTimeout: &executor.Duration{Duration: 60 * time.Minute},
+			}, &rest.Config{Host: "localhost"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			exec.Client = fake.NewSimpleClientset()
+
+			jobs := store.NewInMemoryJobStore()
+			for i, j := range []struct {
+				Name  string
+				Repo  string
+				Phase v1.JobPhase
+				Pod   bool
+			}{
+				{"werft.1", "werft", v1.JobPhase_PHASE_RUNNING, true},
+				{"werft.2", "werft", v1.JobPhase_PHASE_STARTING, true},
+				{"werft.3", "werft", v1.JobPhase_PHASE_DONE, true},
+				// the job's pod is gone, hence it cannot be stopped
+				{"werft.4", "werft", v1.JobPhase_PHASE_RUNNING, false},
+				{"other.1", "other", v1.JobPhase_PHASE_RUNNING, true},
+			} {
+				err := jobs.Store(context.Background(), v1.JobStatus{
+					Name:     j.Name,
+					Phase:    j.Phase,
+					Metadata: &v1.JobMetadata{Repository: &v1.Repository{Repo: j.Repo}, Created: &timestamp.Timestamp{Seconds: int64(i)}},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !j.Pod {
+					continue
+				}
+				_, err = exec.Client.CoreV1().Pods("").Create(context.Background(), &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: j.Name, Labels: map[string]string{"werft.test/jobName": j.Name}, Annotations: map[string]string{}},
+				}, metav1.CreateOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			srv := &Service{Jobs: jobs, Executor: exec}
+
+			filter, err := filterexpr.ParseExpressions(test.Filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := srv.StopJobs(context.Background(), &v1.StopJobsRequest{Filter: filter, Reason: "bad commit", DryRun: test.DryRun})
+			if err != nil {
+				t.Fatalf("cannot stop jobs: %v", err)
+			}
+			if !proto.Equal(resp, test.Expected) {
+				t.Errorf("unexpected response: %v, expected %v", resp, test.Expected)
+			}
+
+			pods, err := exec.Client.CoreV1().Pods("").List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var stopped []string
+			for _, pod := range pods.Items {
+				if _, ok := pod.Annotations["werft.test/failed"]; ok {
+					stopped = append(stopped, pod.Name)
+				}
+			}
+			sort.Strings(stopped)
+			if !reflect.DeepEqual(stopped, test.Stopped) {
+				t.Errorf("unexpected stopped pods: %v, expected %v", stopped, test.Stopped)
+			}
+		})
+	}
+}
+
+func TestStopJobsEmptyFilter(t *testing.T) {
+	srv := &Service{Jobs: store.NewInMemoryJobStore()}
+	_, err := srv.StopJobs(context.Background(), &v1.StopJobsRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+}
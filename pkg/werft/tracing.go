@@ -0,0 +1,135 @@
+package werft
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AnnotationTraceID is the ID of the trace which follows the job from its creation until it's done
+const AnnotationTraceID = "werft.traceID"
+
+// jobTrace follows a job from its creation until it's done. The job span has child spans for the
+// preparation of the job and the time from starting the job until its pod runs.
+// Job traces don't survive a werft restart.
+type jobTrace struct {
+	Job      trace.Span
+	Schedule trace.Span
+
+	firstLog sync.Once
+}
+
+// startJobTrace starts the job span and stores its trace ID as job annotation
+func (srv *Service) startJobTrace(ctx context.Context, name string, md *v1.JobMetadata) context.Context {
+	attrs := []attribute.KeyValue{
+		attribute.String("werft.job.name", name),
+		attribute.String("werft.job.trigger", triggerLabel(md)),
+	}
+	if md.Repository != nil {
+		attrs = append(attrs,
+			attribute.String("werft.job.repo", repoLabel(md)),
+			attribute.String("werft.job.ref", md.Repository.Ref),
+		)
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "job", trace.WithAttributes(attrs...))
+	if sc := span.SpanContext(); sc.IsValid() {
+		md.Annotations = setAnnotation(md.Annotations, AnnotationTraceID, sc.TraceID().String())
+	} else {
+		// jobs started from a previous one inherit its annotations, but not its trace
+		var annotations []*v1.Annotation
+		for _, a := range md.Annotations {
+			if a.Key != AnnotationTraceID {
+				annotations = append(annotations, a)
+			}
+		}
+		md.Annotations = annotations
+	}
+
+	jt := &jobTrace{Job: span}
+	srv.tracesMu.Lock()
+	if srv.traces == nil {
+		srv.traces = make(map[string]*jobTrace)
+	}
+	srv.traces[name] = jt
+	srv.tracesMu.Unlock()
+
+	return ctx
+}
+
+// startScheduleTrace starts the span which ends once the job runs
+func (srv *Service) startScheduleTrace(ctx context.Context, name string) {
+	srv.tracesMu.Lock()
+	defer srv.tracesMu.Unlock()
+
+	jt, ok := srv.traces[name]
+	if !ok {
+		return
+	}
+	_, jt.Schedule = tracing.Tracer().Start(ctx, "schedule")
+}
+
+// traceJobUpdate ends the schedule span once the job runs and the job span once it's done
+func (srv *Service) traceJobUpdate(s *v1.JobStatus) {
+	var (
+		done    = s.Phase == v1.JobPhase_PHASE_DONE || s.Phase == v1.JobPhase_PHASE_CLEANUP
+		running = done || s.Phase == v1.JobPhase_PHASE_RUNNING
+	)
+
+	srv.tracesMu.Lock()
+	jt, ok := srv.traces[s.Name]
+	var schedule trace.Span
+	if ok {
+		schedule = jt.Schedule
+	}
+	if ok && done {
+		delete(srv.traces, s.Name)
+	}
+	srv.tracesMu.Unlock()
+	if !ok {
+		return
+	}
+
+	if running && schedule != nil {
+		schedule.End()
+	}
+	if !done {
+		return
+	}
+
+	success := s.Conditions != nil && s.Conditions.Success
+	jt.Job.SetAttributes(attribute.Bool("werft.job.success", success))
+	if !success {
+		jt.Job.SetStatus(codes.Error, s.Details)
+	}
+	jt.Job.End()
+}
+
+// traceFirstLog returns a reader which records an event on the job span once the job produced its first log output
+func (srv *Service) traceFirstLog(name string, r io.Reader) io.Reader {
+	srv.tracesMu.Lock()
+	jt, ok := srv.traces[name]
+	srv.tracesMu.Unlock()
+	if !ok {
+		return r
+	}
+	return &firstLogReader{Reader: r, Trace: jt}
+}
+
+type firstLogReader struct {
+	io.Reader
+	Trace *jobTrace
+}
+
+func (r *firstLogReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	if n > 0 {
+		r.Trace.firstLog.Do(func() { r.Trace.Job.AddEvent("first log") })
+	}
+	return
+}
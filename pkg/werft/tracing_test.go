@@ -0,0 +1,79 @@
+package werft
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/store"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestJobTrace(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+
+	srv := &Service{
+		Logs:        store.NewInMemoryLogStore(),
+		Jobs:        store.NewInMemoryJobStore(),
+		logListener: make(map[string]*jobLog),
+	}
+	srv.setupMetrics()
+
+	md := &v1.JobMetadata{
+		Repository: &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft"},
+		Trigger:    v1.JobTrigger_TRIGGER_PUSH,
+		// jobs started from a previous one inherit its annotations
+		Annotations: []*v1.Annotation{{Key: AnnotationTraceID, Value: "previous"}},
+	}
+	ctx := srv.startJobTrace(context.Background(), "foo", md)
+	srv.startScheduleTrace(ctx, "foo")
+
+	status := func(phase v1.JobPhase) *v1.JobStatus {
+		return &v1.JobStatus{Name: "foo", Phase: phase, Metadata: md, Conditions: &v1.JobConditions{}, Details: "tests failed"}
+	}
+	srv.handleJobUpdate(nil, status(v1.JobPhase_PHASE_STARTING))
+	if n := len(sr.Ended()); n != 0 {
+		t.Fatalf("expected no span to end before the job runs, got %d", n)
+	}
+	srv.handleJobUpdate(nil, status(v1.JobPhase_PHASE_RUNNING))
+	_, err := ioutil.ReadAll(srv.traceFirstLog("foo", strings.NewReader("hello world\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.handleJobUpdate(nil, status(v1.JobPhase_PHASE_DONE))
+	srv.handleJobUpdate(nil, status(v1.JobPhase_PHASE_CLEANUP))
+
+	spans := sr.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected two spans, got %d", len(spans))
+	}
+	schedule, job := spans[0], spans[1]
+	if schedule.Name() != "schedule" || job.Name() != "job" {
+		t.Fatalf("unexpected spans: %s, %s", schedule.Name(), job.Name())
+	}
+	if schedule.Parent().SpanID() != job.SpanContext().SpanID() {
+		t.Errorf("schedule span is not a child of the job span")
+	}
+	if evts := job.Events(); len(evts) != 1 || evts[0].Name != "first log" {
+		t.Errorf("expected a single first log event, got %v", evts)
+	}
+	if st := job.Status(); st.Code != codes.Error || st.Description != "tests failed" {
+		t.Errorf("unexpected job span status: %v", st)
+	}
+
+	var traceIDs []string
+	for _, a := range md.Annotations {
+		if a.Key == AnnotationTraceID {
+			traceIDs = append(traceIDs, a.Value)
+		}
+	}
+	if len(traceIDs) != 1 || traceIDs[0] != job.SpanContext().TraceID().String() {
+		t.Errorf("expected trace ID annotation %s, got %v", job.SpanContext().TraceID(), traceIDs)
+	}
+}
@@ -0,0 +1,210 @@
+package werft
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/audit"
+	"github.com/csweichel/werft/pkg/auth"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TriggerSignatureHeader carries the HMAC-SHA256 signature of a trigger request's body, e.g. "sha256=<hex>"
+const TriggerSignatureHeader = "X-Werft-Signature"
+
+// maxTriggerRequestSize limits the body of trigger requests
+const maxTriggerRequestSize = 1 << 20
+
+// TriggerConfig configures the HTTP endpoint which lets systems other than the Git hoster start jobs, e.g. Jenkins
+type TriggerConfig struct {
+	// Secrets sign the requests of the systems which may start jobs, keyed by the principal they authenticate
+	Secrets map[string]string `yaml:"secrets"`
+}
+
+// TriggerRequest is the JSON body of a trigger request
+type TriggerRequest struct {
+	// Host defaults to github.com
+	Host     string `json:"host,omitempty"`
+	Owner    string `json:"owner"`
+	Repo     string `json:"repo"`
+	Ref      string `json:"ref,omitempty"`
+	Revision string `json:"revision,omitempty"`
+	// JobPath is the job spec to start. Defaults to the job the repo config selects for the ref.
+	JobPath     string            `json:"jobPath,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// TriggerResponse is the JSON body of a successful trigger request
+type TriggerResponse struct {
+	Name string `json:"name"`
+}
+
+// TriggerHandler starts jobs for signed requests, e.g. from Jenkins or a deploy tool.
+// POST /trigger expects a TriggerRequest signed using one of the configured secrets: the TriggerSignatureHeader
+// carries "sha256=" followed by the hex-encoded HMAC-SHA256 of the body. The job is started on behalf of the principal
+// whose secret signed the request, hence the auth rules for that principal apply.
+type TriggerHandler struct {
+	Service v1.WerftServiceServer
+	Secrets map[string]string
+}
+
+// NewTriggerHandler creates a new trigger handler starting jobs using the service
+func NewTriggerHandler(srv v1.WerftServiceServer, cfg TriggerConfig) *TriggerHandler {
+	return &TriggerHandler{
+		Service: srv,
+		Secrets: cfg.Secrets,
+	}
+}
+
+// ServeHTTP starts a job for a signed trigger request
+func (h *TriggerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "triggers must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxTriggerRequestSize))
+	if err != nil {
+		http.Error(w, "cannot read request", http.StatusBadRequest)
+		return
+	}
+	principal, ok := h.verify(body, r.Header.Get(TriggerSignatureHeader))
+	if !ok {
+		http.Error(w, "missing or invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var req TriggerRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	startReq, err := req.startJobRequest(principal)
+	if err != nil {
+		http.Error(w, status.Convert(err).Message(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := audit.WithActor(auth.WithPrincipal(r.Context(), principal), "trigger:"+principal)
+	resp, err := h.Service.StartJob(ctx, startReq)
+	if err != nil {
+		log.WithError(err).WithField("principal", principal).WithField("repo", req.Owner+"/"+req.Repo).Warn("cannot start triggered job")
+		http.Error(w, status.Convert(err).Message(), httpStatus(status.Code(err)))
+		return
+	}
+
+	var name string
+	if resp.Status != nil {
+		name = resp.Status.Name
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TriggerResponse{Name: name})
+}
+
+// verify returns the principal whose secret signed the body
+func (h *TriggerHandler) verify(body []byte, signature string) (principal string, ok bool) {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return "", false
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return "", false
+	}
+
+	principals := make([]string, 0, len(h.Secrets))
+	for p := range h.Secrets {
+		principals = append(principals, p)
+	}
+	sort.Strings(principals)
+	for _, p := range principals {
+		if h.Secrets[p] == "" {
+			continue
+		}
+		if hmac.Equal(sig, signTrigger(body, h.Secrets[p])) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// signTrigger computes the HMAC-SHA256 of a trigger request's body
+func signTrigger(body []byte, secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// startJobRequest validates the trigger request and turns it into the request to start the job
+func (req *TriggerRequest) startJobRequest(principal string) (*v1.StartJobRequest, error) {
+	if req.Owner == "" || req.Repo == "" {
+		return nil, status.Error(codes.InvalidArgument, "owner and repo are required")
+	}
+	if req.Ref == "" && req.Revision == "" {
+		return nil, status.Error(codes.InvalidArgument, "ref or revision is required")
+	}
+	host := req.Host
+	if host == "" {
+		host = "github.com"
+	}
+
+	keys := make([]string, 0, len(req.Annotations))
+	for k := range req.Annotations {
+		err := validateAnnotationKey(k)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	annotations := make([]*v1.Annotation, len(keys))
+	for i, k := range keys {
+		annotations[i] = &v1.Annotation{Key: k, Value: req.Annotations[k]}
+	}
+
+	return &v1.StartJobRequest{
+		JobPath: req.JobPath,
+		Metadata: &v1.JobMetadata{
+			Owner: principal,
+			Repository: &v1.Repository{
+				Host:     host,
+				Owner:    req.Owner,
+				Repo:     req.Repo,
+				Ref:      req.Ref,
+				Revision: req.Revision,
+			},
+			Trigger:     v1.JobTrigger_TRIGGER_MANUAL,
+			Annotations: annotations,
+		},
+	}, nil
+}
+
+// httpStatus maps the gRPC status codes the service responds with to HTTP status codes
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,112 @@
+package werft
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/auth"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type triggerServer struct {
+	v1.UnimplementedWerftServiceServer
+	Err        error
+	Reqs       []*v1.StartJobRequest
+	Principals []string
+}
+
+func (s *triggerServer) StartJob(ctx context.Context, req *v1.StartJobRequest) (*v1.StartJobResponse, error) {
+	principal, _ := auth.PrincipalFromContext(ctx)
+	s.Reqs = append(s.Reqs, req)
+	s.Principals = append(s.Principals, principal)
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return &v1.StartJobResponse{Status: &v1.JobStatus{Name: "werft-build-main.1"}}, nil
+}
+
+func TestTrigger(t *testing.T) {
+	const body = `{"owner":"csweichel","repo":"werft","ref":"refs/heads/main","jobPath":".werft/build.yaml","annotations":{"version":"1.0.0"}}`
+	sign := func(body, secret string) string {
+		return "sha256=" + hex.EncodeToString(signTrigger([]byte(body), secret))
+	}
+
+	tests := []struct {
+		Name      string
+		Method    string
+		Body      string
+		Signature string
+		Err       error
+		Status    int
+		Started   bool
+	}{
+		{Name: "signed", Body: body, Signature: sign(body, "jenkins-secret"), Status: http.StatusOK, Started: true},
+		{Name: "unsigned", Body: body, Status: http.StatusUnauthorized},
+		{Name: "wrong secret", Body: body, Signature: sign(body, "guessed"), Status: http.StatusUnauthorized},
+		{Name: "tampered body", Body: strings.Replace(body, "main", "release", 1), Signature: sign(body, "jenkins-secret"), Status: http.StatusUnauthorized},
+		{Name: "malformed signature", Body: body, Signature: "jenkins-secret", Status: http.StatusUnauthorized},
+		{Name: "GET", Method: http.MethodGet, Status: http.StatusMethodNotAllowed},
+		{Name: "no ref", Body: `{"owner":"csweichel","repo":"werft"}`, Signature: sign(`{"owner":"csweichel","repo":"werft"}`, "jenkins-secret"), Status: http.StatusBadRequest},
+		{Name: "reserved annotation", Body: `{"owner":"csweichel","repo":"werft","ref":"main","annotations":{"werft.needs":"a"}}`, Signature: sign(`{"owner":"csweichel","repo":"werft","ref":"main","annotations":{"werft.needs":"a"}}`, "jenkins-secret"), Status: http.StatusBadRequest},
+		{Name: "permission denied", Body: body, Signature: sign(body, "jenkins-secret"), Err: status.Error(codes.PermissionDenied, "jenkins may not start jobs"), Status: http.StatusForbidden, Started: true},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			srv := &triggerServer{Err: test.Err}
+			h := NewTriggerHandler(srv, TriggerConfig{Secrets: map[string]string{"jenkins": "jenkins-secret", "deployer": "deployer-secret"}})
+
+			method := test.Method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, "/trigger", strings.NewReader(test.Body))
+			if test.Signature != "" {
+				req.Header.Set(TriggerSignatureHeader, test.Signature)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != test.Status {
+				t.Errorf("unexpected status: expected %d, got %d: %s", test.Status, rec.Code, rec.Body.String())
+			}
+			if started := len(srv.Reqs) > 0; started != test.Started {
+				t.Fatalf("expected job start: %v, got %v", test.Started, started)
+			}
+			if !test.Started || test.Err != nil {
+				return
+			}
+
+			expected := &v1.StartJobRequest{
+				JobPath: ".werft/build.yaml",
+				Metadata: &v1.JobMetadata{
+					Owner:       "jenkins",
+					Repository:  &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: "refs/heads/main"},
+					Trigger:     v1.JobTrigger_TRIGGER_MANUAL,
+					Annotations: []*v1.Annotation{{Key: "version", Value: "1.0.0"}},
+				},
+			}
+			if !proto.Equal(srv.Reqs[0], expected) {
+				t.Errorf("unexpected request: expected %v, got %v", expected, srv.Reqs[0])
+			}
+			if srv.Principals[0] != "jenkins" {
+				t.Errorf("job must be started on behalf of the principal whose secret signed the request, got %q", srv.Principals[0])
+			}
+			var resp TriggerResponse
+			err := json.NewDecoder(rec.Body).Decode(&resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Name != "werft-build-main.1" {
+				t.Errorf("unexpected job name: %q", resp.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package werft
+
+import (
+	"strings"
+
+	"github.com/csweichel/werft/pkg/api/repoconfig"
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/filterexpr"
+	"golang.org/x/xerrors"
+)
+
+// ValidateJobSpec renders a job spec for a job with the metadata and checks it as werft would when starting the job,
+// without starting anything. It returns all problems it finds, or nil if the job spec is valid. In strict mode keys the
+// job spec does not know, which are ignored otherwise, are problems as well.
+//
+// The job spec must have its templates and env file bundled already, see repoconfig.BundleTemplates.
+func ValidateJobSpec(jobYAML []byte, md *v1.JobMetadata, strict bool) []error {
+	const name = "validate"
+
+	doc, err := renderJobSpecDoc(name, md, jobYAML)
+	if err != nil {
+		return []error{xerrors.Errorf("cannot render job spec: %w", err)}
+	}
+
+	var errs []error
+	if strict {
+		spec, err := decodeYAMLMap(doc)
+		if err != nil {
+			return []error{xerrors.Errorf("cannot decode job spec: %w", err)}
+		}
+		if unknown := repoconfig.UnknownFields(spec); len(unknown) > 0 {
+			errs = append(errs, xerrors.Errorf("unknown fields: %s", strings.Join(unknown, ", ")))
+		}
+	}
+
+	jobspec, err := decodeJobSpec(doc)
+	if err != nil {
+		return append(errs, xerrors.Errorf("cannot decode job spec: %w", err))
+	}
+	err = loadEnvFile(jobspec, jobYAML)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if jobspec.When != "" {
+		_, err = filterexpr.ParseCondition(jobspec.When)
+		if err != nil {
+			errs = append(errs, xerrors.Errorf("invalid condition: %w", err))
+		}
+	}
+	if jobspec.Pod == nil {
+		errs = append(errs, xerrors.Errorf("no podspec present"))
+	} else if len(jobspec.Pod.Containers) == 0 {
+		errs = append(errs, xerrors.Errorf("pod has no containers"))
+	} else if err = validateSidecars(jobspec); err != nil {
+		errs = append(errs, err)
+	}
+	if len(jobspec.Matrix) > 0 {
+		_, err = jobspec.ExpandMatrix()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	_, err = jobspec.ParseTimeout()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	_, _, err = jobspec.ParsePodTTL()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	_, err = jobspec.ParseResources()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	_, err = jobPriority(jobspec, md)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	_, err = jobspec.ParsePreStop()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for _, validate := range []func() error{
+		jobspec.ValidateServices,
+		jobspec.ValidateArtifacts,
+		jobspec.ValidateScheduling,
+		jobspec.ValidateSecrets,
+		jobspec.ValidateEnv,
+		jobspec.ValidateCache,
+	} {
+		err = validate()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// validateSidecars returns an error if the job lists sidecars its pod does not have
+func validateSidecars(jobspec *repoconfig.JobSpec) error {
+	for _, s := range jobspec.Sidecars {
+		var found bool
+		for _, p := range jobspec.Pod.Containers {
+			if p.Name == s {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return xerrors.Errorf("pod has no container \"%s\", but the job lists it as sidecar", s)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package werft
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+)
+
+func TestValidateJobSpec(t *testing.T) {
+	tests := []struct {
+		File   string
+		Strict bool
+		Errors []string
+	}{
+		{File: "valid.yaml"},
+		{File: "valid.yaml", Strict: true},
+		{File: "invalid-quantity.yaml", Errors: []string{`cpu quantity "lots"`}},
+		{File: "invalid-condition.yaml", Errors: []string{"invalid condition"}},
+		{File: "invalid-schema.yaml", Errors: []string{"cannot decode job spec"}},
+		{File: "invalid-template.yaml", Errors: []string{"undefined variable ${annotations.go}"}},
+		{File: "invalid-multiple.yaml", Errors: []string{`pod has no container "database"`, `invalid timeout "forever"`}},
+		{File: "no-pod.yaml", Errors: []string{"no podspec present"}},
+		{File: "unknown-fields.yaml"},
+		{File: "unknown-fields.yaml", Strict: true, Errors: []string{"unknown fields: pod.containers[0].imagee, timout"}},
+	}
+	for _, test := range tests {
+		name := test.File
+		if test.Strict {
+			name += " strict"
+		}
+		t.Run(name, func(t *testing.T) {
+			jobYAML, err := ioutil.ReadFile(filepath.Join("testdata", "jobspecs", test.File))
+			if err != nil {
+				t.Fatal(err)
+			}
+			md := &v1.JobMetadata{
+				Owner:      "csweichel",
+				Trigger:    v1.JobTrigger_TRIGGER_PUSH,
+				Repository: &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: "refs/heads/main"},
+			}
+
+			errs := ValidateJobSpec(jobYAML, md, test.Strict)
+			if len(errs) != len(test.Errors) {
+				t.Fatalf("expected %d errors, got %v", len(test.Errors), errs)
+			}
+			for i, err := range errs {
+				if !strings.Contains(err.Error(), test.Errors[i]) {
+					t.Errorf("expected error containing %q, got %v", test.Errors[i], err)
+				}
+			}
+		})
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"text/template"
@@ -16,17 +19,21 @@ import (
 	sprig "github.com/Masterminds/sprig/v3"
 	"github.com/csweichel/werft/pkg/api/repoconfig"
 	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/audit"
+	"github.com/csweichel/werft/pkg/auth"
 	"github.com/csweichel/werft/pkg/executor"
+	"github.com/csweichel/werft/pkg/filterexpr"
 	"github.com/csweichel/werft/pkg/logcutter"
 	"github.com/csweichel/werft/pkg/store"
+	"github.com/csweichel/werft/pkg/tracing"
 	"github.com/golang/protobuf/ptypes"
 	"github.com/olebedev/emitter"
-	"github.com/prometheus/client_golang/prometheus"
 	"github.com/segmentio/textio"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/xerrors"
 	"gopkg.in/yaml.v3"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	k8sjson "k8s.io/apimachinery/pkg/runtime/serializer/json"
 	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -50,6 +57,14 @@ type Config struct {
 	// Can be empty, in which clean up jobs will use a default.
 	CleanupJobSpec *configPodSpec `yaml:"cleanupJobSpec,omitempty"`
 
+	// DeduplicateJobs makes werft attach to a job which has not finished yet rather than starting the same job again,
+	// e.g. when two webhooks arrive for the same commit. Jobs are the same if their repository, revision, job spec
+	// and annotations are.
+	DeduplicateJobs bool `yaml:"deduplicateJobs,omitempty"`
+
+	// LogBuffer configures how we cope with clients which receive logs slower than jobs produce them
+	LogBuffer LogBufferConfig `yaml:"logBuffer,omitempty"`
+
 	// Enables the webui debug proxy pointing to this address
 	DebugProxy string
 }
@@ -73,6 +88,10 @@ func (spec *configPodSpec) UnmarshalYAML(value *yaml.Node) error {
 type jobLog struct {
 	CancelExecutorListener context.CancelFunc
 	LogStore               io.Closer
+
+	// Secrets are the secret values injected into the job, which we redact from its log.
+	// Nil if we don't know them yet, e.g. after a restart.
+	Secrets []string
 }
 
 // Service ties everything together
@@ -83,19 +102,37 @@ type Service struct {
 	Executor           *executor.Executor
 	Cutter             logcutter.Cutter
 	RepositoryProvider RepositoryProvider
+	// Policy restricts what callers of the API may do. If nil, callers may do everything.
+	Policy auth.Policy
+	// Audit records who started and stopped which jobs. If nil, nothing is recorded.
+	Audit audit.Logger
+	// Artifacts stores the artifacts jobs pass to each other. If nil, jobs with artifacts fail.
+	Artifacts store.BlobStore
+	// ArtifactsPrefix is prepended to the key of all artifacts
+	ArtifactsPrefix string
 
 	Config Config
 
 	mu          sync.RWMutex
 	logListener map[string]*jobLog
+	// shuttingDown is true once Shutdown was called. We don't (re-)establish the logging of jobs then.
+	shuttingDown bool
+
+	tracesMu sync.Mutex
+	traces   map[string]*jobTrace
+
+	// matrixMu serializes the updates of matrix jobs, which happen whenever one of their jobs changes
+	matrixMu sync.Mutex
+
+	// annotationsMu serializes changes to the annotations of existing jobs
+	annotationsMu sync.Mutex
+
+	// fingerprintsMu guards fingerprints, the jobs which are being started if jobs are deduplicated
+	fingerprintsMu sync.Mutex
+	fingerprints   map[string]chan struct{}
 
 	events  emitter.Emitter
-	metrics struct {
-		GithubJobPreparationSeconds    prometheus.Histogram
-		ExecutorJobPreperationSeconds  prometheus.Histogram
-		ExecutorJobStartsCounter       prometheus.Counter
-		ExecutorJobFailedStartsCounter prometheus.Counter
-	}
+	metrics serviceMetrics
 }
 
 // Start sets up everything to run this werft instance, including executor config
@@ -103,35 +140,11 @@ func (srv *Service) Start() error {
 	if srv.logListener == nil {
 		srv.logListener = make(map[string]*jobLog)
 	}
+	srv.setupMetrics()
 	srv.Executor.OnUpdate = srv.handleJobUpdate
 
-	// set up prometheus gauges
-	srv.metrics.GithubJobPreparationSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
-		Name:    "github_job_preparation_seconds",
-		Help:    "Time it took to retrieve all required data from GitHub prior to starting a job",
-		Buckets: []float64{0, 0.25, 0.5, 0.75, 1},
-	})
-	srv.metrics.ExecutorJobPreperationSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
-		Namespace: "werft",
-		Subsystem: "executor",
-		Name:      "job_preparation_seconds",
-		Help:      "Time it took to start executing the job",
-		Buckets:   []float64{0, 0.25, 0.5, 0.75, 1},
-	})
-	srv.metrics.ExecutorJobStartsCounter = prometheus.NewCounter(prometheus.CounterOpts{
-		Namespace: "werft",
-		Subsystem: "executor",
-		Name:      "job_starts_total",
-		Help:      "Total amount of jobs executor tried to start.",
-	})
-	srv.metrics.ExecutorJobFailedStartsCounter = prometheus.NewCounter(prometheus.CounterOpts{
-		Namespace: "werft",
-		Subsystem: "executor",
-		Name:      "job_starts_failed_total",
-		Help:      "Total amount of jobs executor failed to start.",
-	})
-
-	// we might still have waiting jobs which we must load back into the executor
+	// we might still have waiting or queued jobs which we must load back into the executor.
+	// Restoring them in the order they were created keeps the queue order intact.
 	waitingJobs, _, err := srv.Jobs.Find(context.Background(), []*v1.FilterExpression{
 		{Terms: []*v1.FilterTerm{
 			{
@@ -139,8 +152,13 @@ func (srv *Service) Start() error {
 				Value:     "waiting",
 				Operation: v1.FilterOp_OP_EQUALS,
 			},
+			{
+				Field:     "phase",
+				Value:     "queued",
+				Operation: v1.FilterOp_OP_EQUALS,
+			},
 		}},
-	}, []*v1.OrderExpression{}, 0, 0)
+	}, []*v1.OrderExpression{{Field: "created", Ascending: true}}, nil, 0, 0)
 	if err != nil {
 		return xerrors.Errorf("cannot restore waiting jobs: %w", err)
 	}
@@ -160,10 +178,13 @@ func (srv *Service) Start() error {
 			cancelJob(err)
 			continue
 		}
-		waitUntil, err := ptypes.Timestamp(j.Conditions.WaitUntil)
-		if err != nil {
-			cancelJob(err)
-			continue
+		var waitUntil time.Time
+		if j.Phase == v1.JobPhase_PHASE_WAITING && j.Conditions.WaitUntil != nil {
+			waitUntil, err = ptypes.Timestamp(j.Conditions.WaitUntil)
+			if err != nil {
+				cancelJob(err)
+				continue
+			}
 		}
 
 		md := j.Metadata
@@ -178,27 +199,30 @@ func (srv *Service) Start() error {
 			continue
 		}
 	}
+	// jobs which wait for others might have missed them finishing while werft was down
+	for _, j := range waitingJobs {
+		for _, need := range jobNeeds(j.Metadata) {
+			needed, err := srv.Jobs.Get(ctx, need)
+			if err != nil {
+				log.WithError(err).WithField("name", j.Name).Warnf("cannot get needed job %s", need)
+				continue
+			}
+			srv.jobDone(needed)
+		}
+	}
 
 	go srv.doHousekeeping()
 
 	return nil
 }
 
-// RegisterPrometheusMetrics registers the service metrics on the registerer with MustRegister
-func (srv *Service) RegisterPrometheusMetrics(reg prometheus.Registerer) {
-	reg.MustRegister(srv.metrics.GithubJobPreparationSeconds)
-	reg.MustRegister(srv.metrics.ExecutorJobPreperationSeconds)
-	reg.MustRegister(srv.metrics.ExecutorJobFailedStartsCounter)
-	reg.MustRegister(srv.metrics.ExecutorJobStartsCounter)
-}
-
 func (srv *Service) doHousekeeping() {
 	tick := time.NewTicker(5 * time.Minute)
 	for {
 		log.Debug("performing werft service housekeeping")
 
 		ctx := context.Background()
-		expectedJobs, _, err := srv.Jobs.Find(ctx, []*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "phase", Value: "done", Operation: v1.FilterOp_OP_EQUALS, Negate: true}}}}, []*v1.OrderExpression{}, 0, 0)
+		expectedJobs, _, err := srv.Jobs.Find(ctx, []*v1.FilterExpression{{Terms: []*v1.FilterTerm{{Field: "phase", Value: "done", Operation: v1.FilterOp_OP_EQUALS, Negate: true}}}}, []*v1.OrderExpression{}, nil, 0, 0)
 		if err != nil {
 			log.WithError(err).Warn("cannot perform housekeeping")
 			<-tick.C
@@ -218,6 +242,11 @@ func (srv *Service) doHousekeeping() {
 		}
 
 		for _, job := range expectedJobs {
+			if matrixJobs(&job) != nil {
+				// matrix jobs have no pod, but follow their jobs
+				continue
+			}
+
 			knownStatus, exists := knownJobsIdx[job.Name]
 			if !exists {
 				log.WithField("name", job.Name).Warn("executor does not know about this job - we have missed an event. Marking as failed.")
@@ -238,6 +267,49 @@ func (srv *Service) doHousekeeping() {
 	}
 }
 
+// Shutdown stops this werft instance cleanly. The job pods keep running and werft picks them up again once
+// it starts anew. Until then jobs which wait or are queued stay in the job store, and the logs of running
+// jobs are flushed to the log store. Once their logs are flushed, all Listen and Subscribe streams end.
+func (srv *Service) Shutdown(ctx context.Context) error {
+	srv.mu.Lock()
+	srv.shuttingDown = true
+	listener := srv.logListener
+	srv.logListener = make(map[string]*jobLog)
+	srv.mu.Unlock()
+
+	var errs []string
+	if srv.Executor != nil {
+		for _, s := range srv.Executor.Drain() {
+			err := srv.Jobs.Store(ctx, s)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("cannot store %s: %v", s.Name, err))
+			}
+		}
+	}
+
+	for name, jl := range listener {
+		if jl.CancelExecutorListener != nil {
+			jl.CancelExecutorListener()
+		}
+		if jl.LogStore == nil {
+			continue
+		}
+		// closing the log ends the streams of everyone reading it once they've read everything
+		err := jl.LogStore.Close()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("cannot flush logs of %s: %v", name, err))
+		}
+	}
+
+	// closing the event channels ends all Listen and Subscribe streams waiting for job updates
+	srv.events.Off("*")
+
+	if len(errs) > 0 {
+		return xerrors.Errorf("cannot shut down cleanly: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
 func redactContainerEnv(c corev1.Container) corev1.Container {
 	for j, e := range c.Env {
 		nme := strings.ToLower(e.Name)
@@ -264,8 +336,11 @@ func (srv *Service) handleJobUpdate(pod *corev1.Pod, s *v1.JobStatus) {
 		return
 	}
 
+	srv.observeJobUpdate(s)
+	srv.traceJobUpdate(s)
+
 	// ensure we have logging, e.g. reestablish joblog for unknown jobs (i.e. after restart)
-	srv.ensureLogging(s)
+	srv.ensureLogging(pod, s)
 
 	out, err := srv.Logs.Write(s.Name)
 	if err == nil && pod != nil {
@@ -309,6 +384,13 @@ func (srv *Service) handleJobUpdate(pod *corev1.Pod, s *v1.JobStatus) {
 
 		return
 	}
+	if s.EffectiveSpec == "" {
+		// the executor does not know the effective job spec - we keep the one RunJob stored
+		prev, err := srv.Jobs.Get(context.Background(), s.Name)
+		if err == nil {
+			s.EffectiveSpec = prev.EffectiveSpec
+		}
+	}
 	err = srv.Jobs.Store(context.Background(), *s)
 	if err != nil {
 		log.WithError(err).WithField("name", s.Name).Warn("cannot store job")
@@ -316,9 +398,17 @@ func (srv *Service) handleJobUpdate(pod *corev1.Pod, s *v1.JobStatus) {
 
 	// tell our Listen subscribers about this change
 	<-srv.events.Emit("job", s)
+	srv.jobDone(s)
+
+	if parent, ok := matrixParent(s.Metadata); ok {
+		_, err = srv.updateMatrixJob(parent)
+		if err != nil {
+			log.WithError(err).WithField("name", s.Name).Warn("cannot update matrix job")
+		}
+	}
 }
 
-func (srv *Service) ensureLogging(s *v1.JobStatus) {
+func (srv *Service) ensureLogging(pod *corev1.Pod, s *v1.JobStatus) {
 	if s.Phase > v1.JobPhase_PHASE_DONE {
 		return
 	}
@@ -344,7 +434,7 @@ func (srv *Service) ensureLogging(s *v1.JobStatus) {
 
 	srv.mu.Lock()
 	defer srv.mu.Unlock()
-	if allOk() {
+	if allOk() || srv.shuttingDown {
 		return
 	}
 
@@ -362,12 +452,29 @@ func (srv *Service) ensureLogging(s *v1.JobStatus) {
 		srv.logListener[s.Name] = jl
 	}
 
+	// we must not listen to the executor log before we know which secrets to redact from it
+	if jl.Secrets == nil {
+		if pod == nil {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		secrets, err := secretValues(ctx, srv.Executor.Client, srv.Executor.Config.Namespace, &pod.Spec)
+		cancel()
+		if err != nil {
+			log.WithError(err).WithField("name", s.Name).Error("cannot read the secrets to redact from the job log")
+			return
+		}
+		jl.Secrets = secrets
+	}
+
 	// if we should be listening to the executor log, make sure we are
 	if jl.CancelExecutorListener == nil {
 		ctx, cancel := context.WithCancel(context.Background())
 		jl.CancelExecutorListener = cancel
+		secrets := jl.Secrets
 		go func() {
-			err := srv.listenToLogs(ctx, s.Name, srv.Executor.Logs(s.Name))
+			err := srv.listenToLogs(ctx, s.Name, srv.traceFirstLog(s.Name, srv.Executor.Logs(s.Name)), secrets)
 			if err != nil && err != context.Canceled {
 				log.WithError(err).WithField("name", s.Name).Error("cannot listen to job logs")
 				jl.CancelExecutorListener = nil
@@ -376,7 +483,7 @@ func (srv *Service) ensureLogging(s *v1.JobStatus) {
 	}
 }
 
-func (srv *Service) listenToLogs(ctx context.Context, name string, inc io.Reader) error {
+func (srv *Service) listenToLogs(ctx context.Context, name string, inc io.Reader, secrets []string) error {
 	out, err := srv.Logs.Write(name)
 	if err != nil {
 		return err
@@ -384,13 +491,16 @@ func (srv *Service) listenToLogs(ctx context.Context, name string, inc io.Reader
 
 	// we pipe the content to the log cutter to find results
 	pr, pw := io.Pipe()
-	tr := io.TeeReader(inc, pw)
 	evtchan, cerrchan := srv.Cutter.Slice(pr)
 
-	// then forward the logs we read from the executor to the log store
+	// then forward the logs we read from the executor to the log store, without the secret values
+	rw := newRedactingWriter(io.MultiWriter(pw, out), secrets)
 	errchan := make(chan error, 1)
 	go func() {
-		_, err := io.Copy(out, tr)
+		_, err := io.Copy(rw, inc)
+		if err == nil {
+			err = rw.Flush()
+		}
 		if err != nil && err != io.EOF {
 			errchan <- err
 		}
@@ -407,30 +517,13 @@ func (srv *Service) listenToLogs(ctx context.Context, name string, inc io.Reader
 				continue
 			}
 
-			var res *v1.JobResult
-			var body struct {
-				P string   `json:"payload"`
-				C []string `json:"channels"`
-				D string   `json:"description"`
-			}
-			if err := json.Unmarshal([]byte(evt.Payload), &body); err == nil {
-				res = &v1.JobResult{
-					Type:        strings.TrimSpace(evt.Name),
-					Payload:     body.P,
-					Description: body.D,
-					Channels:    body.C,
-				}
-			} else {
-				segs := strings.Fields(evt.Payload)
-				payload, desc := segs[0], strings.Join(segs[1:], " ")
-				res = &v1.JobResult{
-					Type:        strings.TrimSpace(evt.Name),
-					Payload:     payload,
-					Description: desc,
-				}
+			res, err := parseResult(evt)
+			if err != nil {
+				log.WithError(err).WithField("name", name).Warn("ignoring malformed job result")
+				continue
 			}
 
-			err := srv.Executor.RegisterResult(name, res)
+			err = srv.Executor.RegisterResult(name, res)
 			if err != nil {
 				log.WithError(err).WithField("name", name).WithField("res", res).Warn("cannot record job result")
 			}
@@ -447,6 +540,17 @@ func (srv *Service) listenToLogs(ctx context.Context, name string, inc io.Reader
 
 // RunJob starts a build job from some context
 func (srv *Service) RunJob(ctx context.Context, name string, metadata v1.JobMetadata, cp ContentProvider, jobYAML []byte, canReplay bool, waitUntil time.Time) (status *v1.JobStatus, err error) {
+	if _, isMatrixJob := matrixParent(&metadata); !isMatrixJob {
+		// errors surface when we handle the job spec below
+		if jobspec, err := renderJobSpec(name, &metadata, jobYAML); err == nil && len(jobspec.Matrix) > 0 {
+			return srv.runMatrix(ctx, name, metadata, cp, jobYAML, jobspec, canReplay, waitUntil)
+		}
+	}
+
+	ctx = srv.startJobTrace(ctx, name, &metadata)
+	_, prepare := tracing.Tracer().Start(ctx, "prepare")
+	defer prepare.End()
+
 	var logs io.WriteCloser
 	defer func(perr *error) {
 		if *perr != nil {
@@ -465,6 +569,7 @@ func (srv *Service) RunJob(ctx context.Context, name string, metadata v1.JobMeta
 			if logs != nil {
 				logs.Write([]byte("\n[werft] FAILURE " + status.Details))
 			}
+			srv.traceJobUpdate(status)
 		}
 
 		// either way, at the end of this function we must save the job
@@ -473,51 +578,114 @@ func (srv *Service) RunJob(ctx context.Context, name string, metadata v1.JobMeta
 			log.WithError(serr).WithField("name", name).Warn("cannot save job - this will break things")
 		}
 		<-srv.events.Emit("job", status)
+		srv.jobDone(status)
 	}(&err)
 
 	if canReplay {
-		// save job yaml
+		// save job yaml - we also need it to restore waiting and queued jobs when werft restarts
 		err = srv.Jobs.StoreJobSpec(name, jobYAML)
 		if err != nil {
 			log.WithError(err).Warn("cannot store job YAML - job will not be replayable")
 		}
 	}
 
-	jobTpl, err := template.New("job").Funcs(sprig.TxtFuncMap()).Parse(string(jobYAML))
-	if err != nil {
-		return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
-	}
-
-	buf := bytes.NewBuffer(nil)
-	err = jobTpl.Execute(buf, newTemplateObj(name, &metadata))
+	jobspec, err := renderJobSpec(name, &metadata, jobYAML)
 	if err != nil {
 		return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
 	}
 
-	// we have to use the Kubernetes YAML decoder to decode the podspec
-	var jobspec repoconfig.JobSpec
-	err = k8syaml.NewYAMLOrJSONDecoder(bytes.NewReader(buf.Bytes()), 4096).Decode(&jobspec)
-	if err != nil {
-		return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
+	if jobspec.When != "" {
+		cond, err := filterexpr.ParseCondition(jobspec.When)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
+		}
+		if !cond.Matches(&v1.JobStatus{Name: name, Metadata: &metadata}) {
+			return srv.skipJob(name, metadata, canReplay, jobspec.When), nil
+		}
 	}
 
 	podspec := jobspec.Pod
 	if podspec == nil {
 		return nil, xerrors.Errorf("cannot handle job for %s: no podspec present", name)
 	}
+	applyDefaultImage(podspec, repoconfig.DefaultImage(jobYAML))
 
-	for _, s := range jobspec.Sidecars {
-		var found bool
-		for _, p := range podspec.Containers {
-			if p.Name == s {
-				found = true
-				break
-			}
-		}
+	err = validateSidecars(jobspec)
+	if err != nil {
+		return nil, err
+	}
 
-		if !found {
-			return nil, xerrors.Errorf("pod has no container \"%s\", but the job lists it as sidecar", s)
-		}
+	err = jobspec.ValidateServices()
+	if err != nil {
+		return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
+	}
+	err = applyServices(podspec, jobspec)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
+	}
+	err = jobspec.ValidateArtifacts()
+	if err != nil {
+		return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
+	}
+	artifacts := srv.jobArtifacts(name, &metadata, jobspec)
+	if artifacts != nil && srv.Artifacts == nil {
+		return nil, xerrors.Errorf("cannot handle job for %s: job has artifacts but werft has no artifact store configured", name)
+	}
+	if artifacts != nil && len(artifacts.Outputs) > 0 {
+		// the artifacts sidecar outlives the job's containers, so that we can collect the outputs from it
+		podspec.Containers = append(podspec.Containers, executor.ArtifactsSidecar())
+		jobspec.Sidecars = append(jobspec.Sidecars, executor.ArtifactsContainer)
+	}
+
+	timeout, err := jobspec.ParseTimeout()
+	if err != nil {
+		return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
+	}
+	podTTL, hasPodTTL, err := jobspec.ParsePodTTL()
+	if err != nil {
+		return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
+	}
+	resources, err := jobspec.ParseResources()
+	if err != nil {
+		return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
+	}
+	applyResources(podspec, resources, jobspec.Sidecars)
+	err = jobspec.ValidateScheduling()
+	if err != nil {
+		return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
+	}
+	err = applyScheduling(podspec, jobspec)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
+	}
+	err = jobspec.ValidateSecrets()
+	if err != nil {
+		return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
+	}
+	applySecrets(podspec, jobspec.Secrets, jobspec.Sidecars)
+	err = jobspec.ValidateEnv()
+	if err != nil {
+		return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
+	}
+	applyEnv(podspec, jobspec.Env, jobspec.Sidecars)
+	gracePeriod, err := jobspec.ParsePreStop()
+	if err != nil {
+		return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
+	}
+	applyPreStop(podspec, jobspec.PreStop, jobspec.Sidecars)
+	applyMatrixValues(podspec, matrixValues(&metadata), jobspec.Sidecars)
+	err = jobspec.ValidateCache()
+	if err != nil {
+		return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
+	}
+	jobspec.Priority, err = jobPriority(jobspec, &metadata)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
+	}
+	// the effective spec leaves out the workspace and content init containers werft adds below
+	effectiveSpec, err := srv.effectiveJobSpec(jobspec)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
 	}
 
 	wsVolume := "werft-workspace"
@@ -546,14 +714,11 @@ func (srv *Service) RunJob(ctx context.Context, name string, metadata v1.JobMeta
 	if err != nil {
 		return nil, xerrors.Errorf("cannot produce init container: %w", err)
 	}
-	for i, ic := range ics {
-		ics[i].VolumeMounts = append(ic.VolumeMounts, corev1.VolumeMount{
-			Name:      wsVolume,
-			ReadOnly:  false,
-			MountPath: "/workspace",
-		})
+	if artifacts != nil && len(artifacts.Inputs) > 0 {
+		// inputs are restored once the content is in place, before the pod's own init containers run
+		ics = append(ics, executor.ArtifactsInit())
 	}
-	podspec.InitContainers = append(podspec.InitContainers, ics...)
+	applyInitContainers(podspec, ics, wsVolume)
 	for i, c := range podspec.Containers {
 		podspec.Containers[i].VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
 			Name:      wsVolume,
@@ -562,12 +727,17 @@ func (srv *Service) RunJob(ctx context.Context, name string, metadata v1.JobMeta
 		})
 	}
 
+	secrets, err := secretValues(ctx, srv.Executor.Client, srv.Executor.Config.Namespace, podspec)
+	if err != nil {
+		return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
+	}
+
 	logs, err = srv.Logs.Open(name)
 	if err != nil {
 		return nil, xerrors.Errorf("cannot start logging for %s: %w", name, err)
 	}
 	srv.mu.Lock()
-	srv.logListener[name] = &jobLog{LogStore: logs}
+	srv.logListener[name] = &jobLog{LogStore: logs, Secrets: secrets}
 	srv.mu.Unlock()
 	fmt.Fprintln(logs, "[preparing|PHASE] job preparation")
 
@@ -591,20 +761,44 @@ func (srv *Service) RunJob(ctx context.Context, name string, metadata v1.JobMeta
 
 	// schedule/start job
 	tExecutorPrepStart := time.Now()
-	status, err = srv.Executor.Start(*podspec, metadata,
+	opts := []executor.StartOpt{
 		executor.WithName(name),
 		executor.WithCanReplay(canReplay),
 		executor.WithWaitUntil(waitUntil),
-		executor.WithMutex(jobspec.Mutex),
+		executor.WithMutex(jobMutex(jobspec, &metadata)),
 		executor.WithSidecars(jobspec.Sidecars),
-	)
+		executor.WithTimeout(timeout),
+		executor.WithPriority(jobspec.Priority),
+		executor.WithGracePeriod(gracePeriod),
+	}
+	if hasPodTTL {
+		opts = append(opts, executor.WithPodTTL(podTTL))
+	}
+	if needs := jobNeeds(&metadata); len(needs) > 0 {
+		opts = append(opts, executor.WithNeeds(needs))
+	}
+	if artifacts != nil {
+		opts = append(opts, executor.WithArtifacts(*artifacts))
+	}
+	if jobspec.Cache != nil {
+		cache, err := cacheOptions(jobspec.Cache, &metadata)
+		if err != nil {
+			return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
+		}
+		opts = append(opts, executor.WithCache(*cache))
+	}
+	prepare.End()
+	srv.startScheduleTrace(ctx, name)
+	status, err = srv.Executor.Start(*podspec, metadata, opts...)
 	srv.metrics.ExecutorJobStartsCounter.Inc()
 	if err != nil {
 		srv.metrics.ExecutorJobFailedStartsCounter.Inc()
 		return nil, xerrors.Errorf("cannot handle job for %s: %w", name, err)
 	}
 	name = status.Name
+	status.EffectiveSpec = effectiveSpec
 	srv.metrics.ExecutorJobPreperationSeconds.Observe(time.Since(tExecutorPrepStart).Seconds())
+	srv.metrics.JobsStartedTotal.WithLabelValues(repoLabel(&metadata), triggerLabel(&metadata)).Inc()
 
 	err = cp.Serve(name)
 	if err != nil {
@@ -614,7 +808,517 @@ func (srv *Service) RunJob(ctx context.Context, name string, metadata v1.JobMeta
 	return status, nil
 }
 
+// effectiveJobSpec encodes the job spec once all defaults were applied to it, with secret environment variables redacted
+func (srv *Service) effectiveJobSpec(jobspec *repoconfig.JobSpec) (string, error) {
+	effective := *jobspec
+	if effective.Pod != nil {
+		effective.Pod = jobspec.Pod.DeepCopy()
+		for i, c := range effective.Pod.Containers {
+			effective.Pod.Containers[i] = redactContainerEnv(c)
+		}
+		for i, c := range effective.Pod.InitContainers {
+			effective.Pod.InitContainers[i] = redactContainerEnv(c)
+		}
+	}
+	effective.Services = make([]corev1.Container, len(jobspec.Services))
+	for i, c := range jobspec.Services {
+		effective.Services[i] = redactContainerEnv(*c.DeepCopy())
+	}
+	if effective.Timeout == "" && srv.Executor != nil && srv.Executor.Config.JobTotalTimeout != nil {
+		effective.Timeout = srv.Executor.Config.JobTotalTimeout.Duration.String()
+	}
+
+	res, err := repoconfig.MarshalJobSpec(&effective)
+	if err != nil {
+		return "", xerrors.Errorf("cannot encode effective job spec: %w", err)
+	}
+	return string(res), nil
+}
+
+// skipJob produces the status of a job whose condition is false. Skipped jobs are done and successful.
+func (srv *Service) skipJob(name string, metadata v1.JobMetadata, canReplay bool, when string) *v1.JobStatus {
+	now := ptypes.TimestampNow()
+	if metadata.Created == nil {
+		metadata.Created = now
+	}
+	metadata.Finished = now
+	status := &v1.JobStatus{
+		Name:     name,
+		Metadata: &metadata,
+		Phase:    v1.JobPhase_PHASE_DONE,
+		Conditions: &v1.JobConditions{
+			Success:   true,
+			Skipped:   true,
+			CanReplay: canReplay,
+		},
+		Details: fmt.Sprintf("skipped: condition %q is false", when),
+	}
+
+	logs, err := srv.Logs.Open(name)
+	if err == nil {
+		fmt.Fprintf(logs, "[werft] %s\n", status.Details)
+		logs.Close()
+	} else {
+		log.WithError(err).WithField("name", name).Warn("cannot write log of skipped job")
+	}
+	srv.traceJobUpdate(status)
+
+	return status
+}
+
+// renderJobSpec executes the job template, interpolates the ${...} variables and decodes the job spec it produces.
+// If the job spec extends templates, these are rendered likewise and merged with the job spec.
+func renderJobSpec(name string, md *v1.JobMetadata, jobYAML []byte) (*repoconfig.JobSpec, error) {
+	spec, err := renderJobSpecDoc(name, md, jobYAML)
+	if err != nil {
+		return nil, err
+	}
+	jobspec, err := decodeJobSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	err = loadEnvFile(jobspec, jobYAML)
+	if err != nil {
+		return nil, err
+	}
+	return jobspec, nil
+}
+
+// loadEnvFile adds the variables of the env file bundled with the job spec to its env. Variables the job spec
+// declares itself take precedence.
+func loadEnvFile(jobspec *repoconfig.JobSpec, jobYAML []byte) error {
+	if jobspec.EnvFile == "" {
+		return nil
+	}
+	_, path, content := repoconfig.SplitEnvFile(jobYAML)
+	if path == "" {
+		return xerrors.Errorf("env file %s was not read from the repository - only jobs started from a repository can use env files", jobspec.EnvFile)
+	}
+	if path != jobspec.EnvFile {
+		return xerrors.Errorf("env file %s must be a plain path without templates or variables", path)
+	}
+
+	vars, err := repoconfig.ParseEnvFile(content)
+	if err != nil {
+		return xerrors.Errorf("invalid env file %s: %w", path, err)
+	}
+	if jobspec.Env == nil {
+		jobspec.Env = make(map[string]string, len(vars))
+	}
+	for k, v := range vars {
+		if _, exists := jobspec.Env[k]; exists {
+			continue
+		}
+		jobspec.Env[k] = v
+	}
+	return nil
+}
+
+// renderJobSpecDoc renders a job spec like renderJobSpec, but returns the YAML or JSON document it produces
+func renderJobSpecDoc(name string, md *v1.JobMetadata, jobYAML []byte) ([]byte, error) {
+	jobYAML, templates := repoconfig.SplitBundle(jobYAML)
+	spec, err := renderJobTemplate(name, md, jobYAML)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(templates) > 0 || repoconfig.Extends(jobYAML) != "" {
+		base, err := decodeYAMLMap(spec)
+		if err != nil {
+			return nil, err
+		}
+		tpls := make(map[string]map[string]interface{}, len(templates))
+		for ref, tpl := range templates {
+			rendered, err := renderJobTemplate(name, md, tpl)
+			if err != nil {
+				return nil, xerrors.Errorf("template %s: %w", ref, err)
+			}
+			tpls[ref], err = decodeYAMLMap(rendered)
+			if err != nil {
+				return nil, xerrors.Errorf("template %s: %w", ref, err)
+			}
+		}
+		merged, err := repoconfig.MergeTemplates(base, tpls)
+		if err != nil {
+			return nil, err
+		}
+		spec, err = json.Marshal(merged)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return spec, nil
+}
+
+// decodeJobSpec decodes a rendered job spec
+func decodeJobSpec(spec []byte) (*repoconfig.JobSpec, error) {
+	// we have to use the Kubernetes YAML decoder to decode the podspec
+	var jobspec repoconfig.JobSpec
+	err := k8syaml.NewYAMLOrJSONDecoder(bytes.NewReader(spec), 4096).Decode(&jobspec)
+	if err != nil {
+		return nil, err
+	}
+	return &jobspec, nil
+}
+
+// renderJobTemplate executes a job spec or template as Go template and interpolates its ${...} variables
+func renderJobTemplate(name string, md *v1.JobMetadata, tpl []byte) ([]byte, error) {
+	jobTpl, err := template.New("job").Funcs(sprig.TxtFuncMap()).Parse(string(tpl))
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err = jobTpl.Execute(buf, newTemplateObj(name, md))
+	if err != nil {
+		return nil, err
+	}
+	return interpolate(buf.Bytes(), interpolationVars(name, md))
+}
+
+// decodeYAMLMap decodes a YAML document into a map
+func decodeYAMLMap(doc []byte) (map[string]interface{}, error) {
+	js, err := k8syaml.ToJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+	// numbers stay as they are, e.g. large integers must not become floats
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+	var res map[string]interface{}
+	err = dec.Decode(&res)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		res = make(map[string]interface{})
+	}
+	return res, nil
+}
+
 // cleanupWorkspace starts a cleanup job for a previously run job
+// applyInitContainers adds the init containers which produce the job content to the pod. They run before the
+// init containers of the pod, so that those find the workspace ready to use. All init containers mount the workspace.
+func applyInitContainers(podspec *corev1.PodSpec, contentInit []corev1.Container, wsVolume string) {
+	podspec.InitContainers = append(contentInit, podspec.InitContainers...)
+	for i, ic := range podspec.InitContainers {
+		podspec.InitContainers[i].VolumeMounts = append(ic.VolumeMounts, corev1.VolumeMount{
+			Name:      wsVolume,
+			ReadOnly:  false,
+			MountPath: "/workspace",
+		})
+	}
+}
+
+// serviceReadinessScript repeats the readiness probe command passed as arguments until it succeeds
+const serviceReadinessScript = `until "$@"; do sleep 1; done`
+
+// applyServices adds the services of the job spec to the pod and marks them as sidecars. Services come first,
+// because Kubernetes starts containers in order and waits for a container's postStart hook to complete before
+// starting the next one. Services with a readiness probe get a postStart hook which waits until they're ready,
+// hence the job's containers start only once all services are ready.
+func applyServices(podspec *corev1.PodSpec, jobspec *repoconfig.JobSpec) error {
+	if len(jobspec.Services) == 0 {
+		return nil
+	}
+
+	services := make([]corev1.Container, 0, len(jobspec.Services))
+	for _, s := range jobspec.Services {
+		svc := s.DeepCopy()
+		for _, c := range podspec.Containers {
+			if c.Name == svc.Name {
+				return xerrors.Errorf("service %s has the same name as a container of the pod", svc.Name)
+			}
+		}
+
+		if probe := svc.ReadinessProbe; probe != nil && probe.Exec != nil {
+			if svc.Lifecycle == nil {
+				svc.Lifecycle = &corev1.Lifecycle{}
+			}
+			svc.Lifecycle.PostStart = &corev1.Handler{
+				Exec: &corev1.ExecAction{
+					Command: append([]string{"sh", "-c", serviceReadinessScript, "wait-for-readiness"}, probe.Exec.Command...),
+				},
+			}
+		}
+
+		services = append(services, *svc)
+		jobspec.Sidecars = append(jobspec.Sidecars, svc.Name)
+	}
+	podspec.Containers = append(services, podspec.Containers...)
+
+	return nil
+}
+
+// applyDefaultImage sets the default image of the repository on all containers of the job spec which do not name an image themselves
+func applyDefaultImage(podspec *corev1.PodSpec, image string) {
+	if image == "" {
+		return
+	}
+
+	for i, c := range podspec.InitContainers {
+		if c.Image == "" {
+			podspec.InitContainers[i].Image = image
+		}
+	}
+	for i, c := range podspec.Containers {
+		if c.Image == "" {
+			podspec.Containers[i].Image = image
+		}
+	}
+}
+
+// applyResources sets the resources of all non-sidecar containers which do not specify these resources themselves
+func applyResources(podspec *corev1.PodSpec, resources *corev1.ResourceRequirements, sidecars []string) {
+	if resources == nil {
+		return
+	}
+
+	isSidecar := make(map[string]struct{}, len(sidecars))
+	for _, s := range sidecars {
+		isSidecar[s] = struct{}{}
+	}
+	merge := func(dst *corev1.ResourceList, src corev1.ResourceList) {
+		for name, q := range src {
+			if _, exists := (*dst)[name]; exists {
+				continue
+			}
+			if *dst == nil {
+				*dst = make(corev1.ResourceList)
+			}
+			(*dst)[name] = q.DeepCopy()
+		}
+	}
+	for i, c := range podspec.Containers {
+		if _, ok := isSidecar[c.Name]; ok {
+			continue
+		}
+		merge(&podspec.Containers[i].Resources.Requests, resources.Requests)
+		merge(&podspec.Containers[i].Resources.Limits, resources.Limits)
+	}
+}
+
+// applyEnv adds the environment variables of the job spec to all containers except the sidecars.
+// Variables a container sets itself take precedence.
+func applyEnv(podspec *corev1.PodSpec, env map[string]string, sidecars []string) {
+	if len(env) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(env))
+	for k := range env {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	isSidecar := make(map[string]struct{}, len(sidecars))
+	for _, s := range sidecars {
+		isSidecar[s] = struct{}{}
+	}
+	for i, c := range podspec.Containers {
+		if _, ok := isSidecar[c.Name]; ok {
+			continue
+		}
+
+		exists := make(map[string]struct{}, len(c.Env))
+		for _, e := range c.Env {
+			exists[e.Name] = struct{}{}
+		}
+		for _, k := range names {
+			if _, ok := exists[k]; ok {
+				continue
+			}
+			podspec.Containers[i].Env = append(podspec.Containers[i].Env, corev1.EnvVar{Name: k, Value: env[k]})
+		}
+	}
+}
+
+// applyPreStop adds the pre-stop command of the job spec as preStop hook to all containers of the pod except the sidecars.
+// Containers with a preStop hook of their own keep theirs.
+func applyPreStop(podspec *corev1.PodSpec, preStop *repoconfig.PreStopSpec, sidecars []string) {
+	if preStop == nil || len(preStop.Command) == 0 {
+		return
+	}
+
+	isSidecar := make(map[string]struct{}, len(sidecars))
+	for _, s := range sidecars {
+		isSidecar[s] = struct{}{}
+	}
+	for i, c := range podspec.Containers {
+		if _, ok := isSidecar[c.Name]; ok {
+			continue
+		}
+		if c.Lifecycle != nil && c.Lifecycle.PreStop != nil {
+			continue
+		}
+
+		if c.Lifecycle == nil {
+			podspec.Containers[i].Lifecycle = &corev1.Lifecycle{}
+		}
+		podspec.Containers[i].Lifecycle.PreStop = &corev1.Handler{
+			Exec: &corev1.ExecAction{Command: append([]string(nil), preStop.Command...)},
+		}
+	}
+}
+
+// applySecrets mounts secrets into, or exposes them as environment variables of all init and non-sidecar containers.
+// The pod references the secrets only. Werft reads their values solely to redact them from the job log.
+func applySecrets(podspec *corev1.PodSpec, secrets []repoconfig.SecretSpec, sidecars []string) {
+	if len(secrets) == 0 {
+		return
+	}
+
+	var (
+		env    []corev1.EnvVar
+		mounts []corev1.VolumeMount
+	)
+	for i, s := range secrets {
+		for _, e := range s.Env {
+			env = append(env, corev1.EnvVar{
+				Name: e.Name,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: s.Name},
+						Key:                  e.Key,
+					},
+				},
+			})
+		}
+
+		if s.MountPath == "" {
+			continue
+		}
+		volName := fmt.Sprintf("werft-secret-%d", i)
+		var items []corev1.KeyToPath
+		for _, k := range s.Keys {
+			items = append(items, corev1.KeyToPath{Key: k, Path: k})
+		}
+		podspec.Volumes = append(podspec.Volumes, corev1.Volume{
+			Name: volName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: s.Name,
+					Items:      items,
+				},
+			},
+		})
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      volName,
+			ReadOnly:  true,
+			MountPath: s.MountPath,
+		})
+	}
+
+	isSidecar := make(map[string]struct{}, len(sidecars))
+	for _, s := range sidecars {
+		isSidecar[s] = struct{}{}
+	}
+	for i, c := range podspec.Containers {
+		if _, ok := isSidecar[c.Name]; ok {
+			continue
+		}
+		podspec.Containers[i].Env = append(c.Env, env...)
+		podspec.Containers[i].VolumeMounts = append(c.VolumeMounts, mounts...)
+	}
+	for i, c := range podspec.InitContainers {
+		podspec.InitContainers[i].Env = append(c.Env, env...)
+		podspec.InitContainers[i].VolumeMounts = append(c.VolumeMounts, mounts...)
+	}
+}
+
+// jobMutex returns the mutex of a job, or an empty string if the job has none
+func jobMutex(jobspec *repoconfig.JobSpec, md *v1.JobMetadata) string {
+	if jobspec.Mutex != repoconfig.MutexAuto {
+		return jobspec.Mutex
+	}
+	if md.Repository == nil {
+		return ""
+	}
+
+	repo := md.Repository
+	return fmt.Sprintf("%s/%s/%s@%s:%s", repo.Host, repo.Owner, repo.Repo, repo.Ref, md.JobSpecName)
+}
+
+// AnnotationPriority overrides the priority of a job in the queue, e.g. to let an urgent job jump ahead
+const AnnotationPriority = "priority"
+
+// jobPriority is the priority of a job in the queue. The priority annotation takes precedence over the job spec.
+func jobPriority(jobspec *repoconfig.JobSpec, md *v1.JobMetadata) (int, error) {
+	priority := int64(jobspec.Priority)
+	for _, a := range md.Annotations {
+		if a.Key != AnnotationPriority {
+			continue
+		}
+
+		var err error
+		priority, err = strconv.ParseInt(a.Value, 10, 64)
+		if err != nil {
+			return 0, xerrors.Errorf("invalid %s annotation %q: must be an integer", AnnotationPriority, a.Value)
+		}
+	}
+	if priority < math.MinInt32 || priority > math.MaxInt32 {
+		return 0, xerrors.Errorf("priority %d is out of range", priority)
+	}
+	return int(priority), nil
+}
+
+// cacheOptions applies the defaults to the cache of a job. Unless the job spec names a key,
+// all jobs of a repository share the same cache.
+func cacheOptions(spec *repoconfig.CacheSpec, md *v1.JobMetadata) (*executor.Cache, error) {
+	key := spec.Key
+	if key == "" && md.Repository != nil {
+		repo := md.Repository
+		key = fmt.Sprintf("%s/%s/%s", repo.Host, repo.Owner, repo.Repo)
+	}
+	if key == "" {
+		return nil, xerrors.Errorf("cache has no key and the job has no repository")
+	}
+
+	mountPath := spec.Path
+	if mountPath == "" {
+		mountPath = repoconfig.DefaultCachePath
+	}
+	size := spec.Size
+	if size == "" {
+		size = repoconfig.DefaultCacheSize
+	}
+	q, err := resource.ParseQuantity(string(size))
+	if err != nil {
+		return nil, xerrors.Errorf("invalid cache size %q: %w", size, err)
+	}
+
+	return &executor.Cache{
+		Key:          key,
+		MountPath:    mountPath,
+		Size:         q,
+		StorageClass: spec.StorageClass,
+	}, nil
+}
+
+// applyScheduling adds the node selector, tolerations and affinity of the job spec to the pod
+func applyScheduling(podspec *corev1.PodSpec, jobspec *repoconfig.JobSpec) error {
+	for k, v := range jobspec.NodeSelector {
+		if pv, exists := podspec.NodeSelector[k]; exists && pv != v {
+			return xerrors.Errorf("node selector %s is \"%s\" in the job spec, but \"%s\" in the pod", k, v, pv)
+		}
+		if podspec.NodeSelector == nil {
+			podspec.NodeSelector = make(map[string]string, len(jobspec.NodeSelector))
+		}
+		podspec.NodeSelector[k] = v
+	}
+
+	podspec.Tolerations = append(podspec.Tolerations, jobspec.Tolerations...)
+
+	if jobspec.Affinity != nil {
+		if podspec.Affinity != nil {
+			return xerrors.Errorf("affinity is set in both the job spec and the pod")
+		}
+		podspec.Affinity = jobspec.Affinity.DeepCopy()
+	}
+
+	return nil
+}
+
 func (srv *Service) cleanupJobWorkspace(s *v1.JobStatus) {
 	if srv.Config.WorkspaceNodePathPrefix == "" {
 		// we don't have a workspace node path prefix, hence used an emptydir volume,
@@ -678,6 +1382,7 @@ type templateObj struct {
 	Repository  v1.Repository
 	Trigger     string
 	Annotations map[string]string
+	Matrix      map[string]string
 }
 
 func newTemplateObj(name string, md *v1.JobMetadata) templateObj {
@@ -692,5 +1397,6 @@ func newTemplateObj(name string, md *v1.JobMetadata) templateObj {
 		Repository:  *md.Repository,
 		Trigger:     strings.ToLower(strings.TrimPrefix(md.Trigger.String(), "TRIGGER_")),
 		Annotations: annotations,
+		Matrix:      matrixValues(md),
 	}
 }
@@ -0,0 +1,801 @@
+package werft
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/csweichel/werft/pkg/api/repoconfig"
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/executor"
+	"github.com/csweichel/werft/pkg/store"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+func TestApplyResources(t *testing.T) {
+	const jobYAML = `
+resources:
+  requests:
+    cpu: 1
+    memory: 2Gi
+  limits:
+    cpu: 1.5
+    memory: 4Gi
+sidecars:
+- proxy
+pod:
+  containers:
+  - name: build
+    image: alpine
+  - name: test
+    image: alpine
+    resources:
+      requests:
+        memory: 1Gi
+  - name: proxy
+    image: envoy
+`
+	var jobspec repoconfig.JobSpec
+	err := k8syaml.NewYAMLOrJSONDecoder(strings.NewReader(jobYAML), 4096).Decode(&jobspec)
+	if err != nil {
+		t.Fatalf("cannot decode job spec: %v", err)
+	}
+	resources, err := jobspec.ParseResources()
+	if err != nil {
+		t.Fatalf("cannot parse resources: %v", err)
+	}
+
+	podspec := jobspec.Pod
+	applyResources(podspec, resources, jobspec.Sidecars)
+
+	expectations := map[string]corev1.ResourceRequirements{
+		"build": {
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("1"),
+				corev1.ResourceMemory: resource.MustParse("2Gi"),
+			},
+			Limits: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("1500m"),
+				corev1.ResourceMemory: resource.MustParse("4Gi"),
+			},
+		},
+		"test": {
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("1"),
+				corev1.ResourceMemory: resource.MustParse("1Gi"),
+			},
+			Limits: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("1500m"),
+				corev1.ResourceMemory: resource.MustParse("4Gi"),
+			},
+		},
+		"proxy": {},
+	}
+	for _, c := range podspec.Containers {
+		t.Run(c.Name, func(t *testing.T) {
+			exp := expectations[c.Name]
+			if !equality.Semantic.DeepEqual(c.Resources, exp) {
+				t.Errorf("unexpected resources: %v; expected %v", c.Resources, exp)
+			}
+		})
+	}
+}
+
+func TestParseMalformedResources(t *testing.T) {
+	const jobYAML = `
+resources:
+  requests:
+    memory: 2 gigs
+pod:
+  containers:
+  - name: build
+    image: alpine
+`
+	var jobspec repoconfig.JobSpec
+	err := k8syaml.NewYAMLOrJSONDecoder(strings.NewReader(jobYAML), 4096).Decode(&jobspec)
+	if err != nil {
+		t.Fatalf("cannot decode job spec: %v", err)
+	}
+	_, err = jobspec.ParseResources()
+	if err == nil {
+		t.Fatal("expected an error for malformed quantity")
+	}
+	if !strings.Contains(err.Error(), "2 gigs") {
+		t.Errorf("error should name the malformed quantity: %v", err)
+	}
+}
+
+func TestApplyScheduling(t *testing.T) {
+	const jobYAML = `
+nodeSelector:
+  pool: ci
+tolerations:
+- key: dedicated
+  operator: Equal
+  value: ci
+  effect: NoSchedule
+affinity:
+  nodeAffinity:
+    requiredDuringSchedulingIgnoredDuringExecution:
+      nodeSelectorTerms:
+      - matchExpressions:
+        - key: disk
+          operator: In
+          values: [ssd]
+pod:
+  nodeSelector:
+    zone: europe-west1-b
+  tolerations:
+  - key: preemptible
+    operator: Exists
+  containers:
+  - name: build
+    image: alpine
+`
+	var jobspec repoconfig.JobSpec
+	err := k8syaml.NewYAMLOrJSONDecoder(strings.NewReader(jobYAML), 4096).Decode(&jobspec)
+	if err != nil {
+		t.Fatalf("cannot decode job spec: %v", err)
+	}
+	err = jobspec.ValidateScheduling()
+	if err != nil {
+		t.Fatalf("cannot validate job spec: %v", err)
+	}
+
+	podspec := jobspec.Pod
+	err = applyScheduling(podspec, &jobspec)
+	if err != nil {
+		t.Fatalf("cannot apply scheduling: %v", err)
+	}
+
+	expectation := &corev1.PodSpec{
+		NodeSelector: map[string]string{"pool": "ci", "zone": "europe-west1-b"},
+		Tolerations: []corev1.Toleration{
+			{Key: "preemptible", Operator: corev1.TolerationOpExists},
+			{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "ci", Effect: corev1.TaintEffectNoSchedule},
+		},
+		Affinity: &corev1.Affinity{
+			NodeAffinity: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+						MatchExpressions: []corev1.NodeSelectorRequirement{
+							{Key: "disk", Operator: corev1.NodeSelectorOpIn, Values: []string{"ssd"}},
+						},
+					}},
+				},
+			},
+		},
+		Containers: []corev1.Container{{Name: "build", Image: "alpine"}},
+	}
+	if !equality.Semantic.DeepEqual(podspec, expectation) {
+		t.Errorf("unexpected pod spec: %v; expected %v", podspec, expectation)
+	}
+}
+
+func TestApplySchedulingConflicts(t *testing.T) {
+	tests := []struct {
+		Name    string
+		JobSpec repoconfig.JobSpec
+		PodSpec corev1.PodSpec
+	}{
+		{
+			Name:    "node selector",
+			JobSpec: repoconfig.JobSpec{NodeSelector: map[string]string{"pool": "ci"}},
+			PodSpec: corev1.PodSpec{NodeSelector: map[string]string{"pool": "default"}},
+		},
+		{
+			Name:    "affinity",
+			JobSpec: repoconfig.JobSpec{Affinity: &corev1.Affinity{}},
+			PodSpec: corev1.PodSpec{Affinity: &corev1.Affinity{}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			err := applyScheduling(&test.PodSpec, &test.JobSpec)
+			if err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestJobMutex(t *testing.T) {
+	md := &v1.JobMetadata{
+		Repository:  &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: "refs/heads/main"},
+		JobSpecName: "build",
+	}
+	tests := []struct {
+		Name        string
+		JobSpec     repoconfig.JobSpec
+		Expectation string
+	}{
+		{Name: "no mutex"},
+		{Name: "explicit mutex", JobSpec: repoconfig.JobSpec{Mutex: "deploy"}, Expectation: "deploy"},
+		{Name: "auto", JobSpec: repoconfig.JobSpec{Mutex: repoconfig.MutexAuto}, Expectation: "github.com/csweichel/werft@refs/heads/main:build"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			act := jobMutex(&test.JobSpec, md)
+			if act != test.Expectation {
+				t.Errorf("unexpected mutex: \"%s\"; expected \"%s\"", act, test.Expectation)
+			}
+		})
+	}
+}
+
+func TestJobPriority(t *testing.T) {
+	tests := []struct {
+		Name        string
+		JobSpec     repoconfig.JobSpec
+		Annotations []*v1.Annotation
+		Expectation int
+		Err         bool
+	}{
+		{Name: "default"},
+		{Name: "spec", JobSpec: repoconfig.JobSpec{Priority: 10}, Expectation: 10},
+		{Name: "annotation", Annotations: []*v1.Annotation{{Key: "priority", Value: "5"}}, Expectation: 5},
+		{Name: "annotation wins", JobSpec: repoconfig.JobSpec{Priority: 10}, Annotations: []*v1.Annotation{{Key: "priority", Value: "-1"}}, Expectation: -1},
+		{Name: "invalid annotation", Annotations: []*v1.Annotation{{Key: "priority", Value: "high"}}, Err: true},
+		{Name: "out of range", Annotations: []*v1.Annotation{{Key: "priority", Value: "3000000000"}}, Err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			act, err := jobPriority(&test.JobSpec, &v1.JobMetadata{Annotations: test.Annotations})
+			if (err != nil) != test.Err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if act != test.Expectation {
+				t.Errorf("unexpected priority: %d; expected %d", act, test.Expectation)
+			}
+		})
+	}
+}
+
+func TestApplyPreStop(t *testing.T) {
+	own := &corev1.Lifecycle{PreStop: &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"own"}}}}
+	podspec := &corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: "build", Image: "alpine"},
+			{Name: "deploy", Image: "alpine", Lifecycle: own},
+			{Name: "proxy", Image: "envoy"},
+		},
+	}
+	applyPreStop(podspec, &repoconfig.PreStopSpec{Command: []string{"sh", "-c", "make clean"}}, []string{"proxy"})
+
+	expected := []*corev1.Lifecycle{
+		{PreStop: &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"sh", "-c", "make clean"}}}},
+		own,
+		nil,
+	}
+	for i, c := range podspec.Containers {
+		if !reflect.DeepEqual(c.Lifecycle, expected[i]) {
+			t.Errorf("unexpected lifecycle of %s: %v; expected %v", c.Name, c.Lifecycle, expected[i])
+		}
+	}
+}
+
+func TestApplySecrets(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Secrets []repoconfig.SecretSpec
+		Env     []corev1.EnvVar
+		Mounts  []corev1.VolumeMount
+		Volumes []corev1.Volume
+	}{
+		{
+			Name: "env",
+			Secrets: []repoconfig.SecretSpec{
+				{Name: "deploy", Env: []repoconfig.SecretEnvSpec{{Name: "DEPLOY_TOKEN", Key: "token"}}},
+			},
+			Env: []corev1.EnvVar{
+				{Name: "EXISTING", Value: "foo"},
+				{Name: "DEPLOY_TOKEN", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "deploy"},
+					Key:                  "token",
+				}}},
+			},
+		},
+		{
+			Name: "volume",
+			Secrets: []repoconfig.SecretSpec{
+				{Name: "registry", MountPath: "/mnt/secrets/registry"},
+				{Name: "gcloud", MountPath: "/mnt/secrets/gcloud", Keys: []string{"key.json"}},
+			},
+			Env: []corev1.EnvVar{{Name: "EXISTING", Value: "foo"}},
+			Mounts: []corev1.VolumeMount{
+				{Name: "werft-secret-0", ReadOnly: true, MountPath: "/mnt/secrets/registry"},
+				{Name: "werft-secret-1", ReadOnly: true, MountPath: "/mnt/secrets/gcloud"},
+			},
+			Volumes: []corev1.Volume{
+				{Name: "werft-secret-0", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "registry"}}},
+				{Name: "werft-secret-1", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+					SecretName: "gcloud",
+					Items:      []corev1.KeyToPath{{Key: "key.json", Path: "key.json"}},
+				}}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			podspec := &corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "build", Image: "alpine", Env: []corev1.EnvVar{{Name: "EXISTING", Value: "foo"}}},
+					{Name: "proxy", Image: "envoy"},
+				},
+			}
+			applySecrets(podspec, test.Secrets, []string{"proxy"})
+
+			build := podspec.Containers[0]
+			if !equality.Semantic.DeepEqual(build.Env, test.Env) {
+				t.Errorf("unexpected env: %v; expected %v", build.Env, test.Env)
+			}
+			if !equality.Semantic.DeepEqual(build.VolumeMounts, test.Mounts) {
+				t.Errorf("unexpected volume mounts: %v; expected %v", build.VolumeMounts, test.Mounts)
+			}
+			if !equality.Semantic.DeepEqual(podspec.Volumes, test.Volumes) {
+				t.Errorf("unexpected volumes: %v; expected %v", podspec.Volumes, test.Volumes)
+			}
+
+			proxy := podspec.Containers[1]
+			if len(proxy.Env) > 0 || len(proxy.VolumeMounts) > 0 {
+				t.Errorf("sidecar should not receive secrets: %v", proxy)
+			}
+		})
+	}
+}
+
+func TestCacheOptions(t *testing.T) {
+	md := &v1.JobMetadata{
+		Repository: &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: "refs/heads/main"},
+	}
+	tests := []struct {
+		Name        string
+		Spec        repoconfig.CacheSpec
+		Expectation executor.Cache
+	}{
+		{
+			Name:        "defaults",
+			Expectation: executor.Cache{Key: "github.com/csweichel/werft", MountPath: "/cache", Size: resource.MustParse("5Gi")},
+		},
+		{
+			Name:        "explicit",
+			Spec:        repoconfig.CacheSpec{Key: "go-mod", Path: "/go/pkg/mod", Size: "10Gi", StorageClass: "ssd"},
+			Expectation: executor.Cache{Key: "go-mod", MountPath: "/go/pkg/mod", Size: resource.MustParse("10Gi"), StorageClass: "ssd"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			act, err := cacheOptions(&test.Spec, md)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !equality.Semantic.DeepEqual(*act, test.Expectation) {
+				t.Errorf("unexpected cache: %v; expected %v", *act, test.Expectation)
+			}
+		})
+	}
+}
+
+func TestApplyServices(t *testing.T) {
+	const jobYAML = `
+sidecars:
+- proxy
+services:
+- name: postgres
+  image: postgres:12
+  readinessProbe:
+    exec:
+      command: ["pg_isready", "-h", "localhost"]
+- name: redis
+  image: redis
+pod:
+  containers:
+  - name: build
+    image: alpine
+  - name: proxy
+    image: envoy
+`
+	var jobspec repoconfig.JobSpec
+	err := k8syaml.NewYAMLOrJSONDecoder(strings.NewReader(jobYAML), 4096).Decode(&jobspec)
+	if err != nil {
+		t.Fatalf("cannot decode job spec: %v", err)
+	}
+	err = jobspec.ValidateServices()
+	if err != nil {
+		t.Fatalf("cannot validate job spec: %v", err)
+	}
+
+	podspec := jobspec.Pod
+	err = applyServices(podspec, &jobspec)
+	if err != nil {
+		t.Fatalf("cannot apply services: %v", err)
+	}
+
+	var names []string
+	for _, c := range podspec.Containers {
+		names = append(names, c.Name)
+	}
+	if exp := []string{"postgres", "redis", "build", "proxy"}; !equality.Semantic.DeepEqual(names, exp) {
+		t.Errorf("unexpected containers: %v; expected %v", names, exp)
+	}
+	if exp := []string{"proxy", "postgres", "redis"}; !equality.Semantic.DeepEqual(jobspec.Sidecars, exp) {
+		t.Errorf("unexpected sidecars: %v; expected %v", jobspec.Sidecars, exp)
+	}
+
+	expectedHook := &corev1.Lifecycle{PostStart: &corev1.Handler{Exec: &corev1.ExecAction{
+		Command: []string{"sh", "-c", serviceReadinessScript, "wait-for-readiness", "pg_isready", "-h", "localhost"},
+	}}}
+	if !equality.Semantic.DeepEqual(podspec.Containers[0].Lifecycle, expectedHook) {
+		t.Errorf("unexpected postgres lifecycle: %v; expected %v", podspec.Containers[0].Lifecycle, expectedHook)
+	}
+	if podspec.Containers[1].Lifecycle != nil {
+		t.Errorf("service without readiness probe should not wait: %v", podspec.Containers[1].Lifecycle)
+	}
+}
+
+func TestApplyServicesNameClash(t *testing.T) {
+	jobspec := repoconfig.JobSpec{Services: []corev1.Container{{Name: "build", Image: "postgres"}}}
+	podspec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "build", Image: "alpine"}}}
+	err := applyServices(podspec, &jobspec)
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestApplyInitContainers(t *testing.T) {
+	podspec := &corev1.PodSpec{
+		InitContainers: []corev1.Container{
+			{Name: "fetch-artifacts", Image: "curlimages/curl"},
+			{Name: "warm-cache", Image: "golang"},
+		},
+		Containers: []corev1.Container{{Name: "build", Image: "golang"}},
+	}
+	applyInitContainers(podspec, []corev1.Container{{Name: "werft-checkout", Image: "alpine/git"}}, "werft-workspace")
+
+	var names []string
+	for _, c := range podspec.InitContainers {
+		names = append(names, c.Name)
+		exp := []corev1.VolumeMount{{Name: "werft-workspace", MountPath: "/workspace"}}
+		if !equality.Semantic.DeepEqual(c.VolumeMounts, exp) {
+			t.Errorf("unexpected volume mounts of %s: %v; expected %v", c.Name, c.VolumeMounts, exp)
+		}
+	}
+	if exp := []string{"werft-checkout", "fetch-artifacts", "warm-cache"}; !equality.Semantic.DeepEqual(names, exp) {
+		t.Errorf("unexpected init containers: %v; expected %v", names, exp)
+	}
+}
+
+func TestRunJobCondition(t *testing.T) {
+	md := v1.JobMetadata{
+		Owner:      "alice",
+		Repository: &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: "refs/heads/main"},
+		Trigger:    v1.JobTrigger_TRIGGER_PUSH,
+	}
+	tests := []struct {
+		Name    string
+		When    string
+		Skipped bool
+		Err     string
+	}{
+		{Name: "true", When: `trigger == "push" && repo.ref == "refs/heads/main"`, Err: "no podspec present"},
+		{Name: "false", When: `trigger == "push" && repo.ref == "refs/heads/dev"`, Skipped: true},
+		{Name: "malformed", When: `trigger == "push" &&`, Err: "invalid condition"},
+		{Name: "unknown field", When: `branch == main`, Err: "unknown field branch"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			jobs := store.NewInMemoryJobStore()
+			srv := &Service{Jobs: jobs, Logs: store.NewInMemoryLogStore()}
+
+			// the job has no pod, hence fails to start unless it's skipped
+			jobYAML := []byte("when: '" + test.When + "'\n")
+			status, err := srv.RunJob(context.Background(), "werft-build-main.1", md, nil, jobYAML, false, time.Time{})
+			if test.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.Err) {
+					t.Fatalf("expected error containing %q, got %v", test.Err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			stored, err := jobs.Get(context.Background(), "werft-build-main.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range []*v1.JobStatus{status, stored} {
+				if s.Phase != v1.JobPhase_PHASE_DONE || !s.Conditions.Skipped || !s.Conditions.Success {
+					t.Errorf("expected skipped job, got %v: %+v", s.Phase, s.Conditions)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderJobSpecTemplates(t *testing.T) {
+	md := &v1.JobMetadata{
+		Owner:       "alice",
+		Repository:  &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: "refs/heads/main"},
+		Trigger:     v1.JobTrigger_TRIGGER_PUSH,
+		Annotations: []*v1.Annotation{{Key: "version", Value: "1.2.3"}},
+	}
+	const bundle = `extends: go
+timeout: 1h
+pod:
+  containers:
+  - name: build
+    image: golang:1.17
+    command: ["go", "build", "-ldflags", "-X main.version={{ .Annotations.version }}"]
+# werft:template go
+extends: base
+mutex: ${repo.ref}
+pod:
+  serviceAccountName: werft
+  securityContext:
+    runAsUser: 1000
+  containers:
+  - name: template
+    image: alpine
+# werft:template base
+timeout: 30m
+nodeSelector:
+  pool: build-{{ .Repository.Repo }}
+`
+	jobspec, err := renderJobSpec("werft-build-main.1", md, []byte(bundle))
+	if err != nil {
+		t.Fatalf("cannot render job spec: %v", err)
+	}
+
+	if jobspec.Timeout != "1h" {
+		t.Errorf("job spec should override the template's timeout, got %q", jobspec.Timeout)
+	}
+	if jobspec.Mutex != "refs/heads/main" {
+		t.Errorf("unexpected mutex: %q", jobspec.Mutex)
+	}
+	if jobspec.NodeSelector["pool"] != "build-werft" {
+		t.Errorf("unexpected node selector: %v", jobspec.NodeSelector)
+	}
+	if jobspec.Pod == nil {
+		t.Fatal("job spec has no pod")
+	}
+	if jobspec.Pod.ServiceAccountName != "werft" || jobspec.Pod.SecurityContext == nil || *jobspec.Pod.SecurityContext.RunAsUser != 1000 {
+		t.Errorf("pod lacks the template's fields: %+v", jobspec.Pod)
+	}
+	expectedContainers := []corev1.Container{{
+		Name:    "build",
+		Image:   "golang:1.17",
+		Command: []string{"go", "build", "-ldflags", "-X main.version=1.2.3"},
+	}}
+	if !equality.Semantic.DeepEqual(jobspec.Pod.Containers, expectedContainers) {
+		t.Errorf("job spec should replace the template's containers, got %+v", jobspec.Pod.Containers)
+	}
+
+	_, err = renderJobSpec("werft-build-main.1", md, []byte("extends: go\npod: {}\n"))
+	if err == nil || err.Error() != "unknown template go" {
+		t.Errorf("expected unknown template error, got %v", err)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	md := &v1.JobMetadata{
+		Owner:      "alice",
+		Repository: &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: "refs/heads/main"},
+		Trigger:    v1.JobTrigger_TRIGGER_PUSH,
+	}
+	files := map[string]string{
+		".werft/build.env": "# shared build config\nGOFLAGS=-mod=readonly\nexport REGISTRY=\"eu.gcr.io/werft\"\nLOG_LEVEL=info\n",
+	}
+	read := func(path string) ([]byte, error) {
+		c, ok := files[path]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(c), nil
+	}
+	spec := []byte(`envFile: .werft/build.env
+env:
+  LOG_LEVEL: debug
+  REF: ${repo.ref}
+  OWNER: "{{ .Owner }}"
+pod:
+  containers:
+  - name: build
+    image: golang:1.17
+    env:
+    - name: GOFLAGS
+      value: -mod=mod
+  - name: proxy
+    image: envoy
+sidecars: ["proxy"]
+`)
+	bundle, err := repoconfig.BundleTemplates(spec, nil, read)
+	if err != nil {
+		t.Fatalf("cannot bundle job spec: %v", err)
+	}
+	jobspec, err := renderJobSpec("werft-build-main.1", md, bundle)
+	if err != nil {
+		t.Fatalf("cannot render job spec: %v", err)
+	}
+	err = jobspec.ValidateEnv()
+	if err != nil {
+		t.Fatalf("invalid env: %v", err)
+	}
+	applyEnv(jobspec.Pod, jobspec.Env, jobspec.Sidecars)
+
+	expected := []corev1.EnvVar{
+		// the container's own variables take precedence over those of the job spec and its env file
+		{Name: "GOFLAGS", Value: "-mod=mod"},
+		{Name: "LOG_LEVEL", Value: "debug"},
+		{Name: "OWNER", Value: "alice"},
+		{Name: "REF", Value: "refs/heads/main"},
+		{Name: "REGISTRY", Value: "eu.gcr.io/werft"},
+	}
+	if env := jobspec.Pod.Containers[0].Env; !equality.Semantic.DeepEqual(env, expected) {
+		t.Errorf("unexpected env: %v; expected %v", env, expected)
+	}
+	if env := jobspec.Pod.Containers[1].Env; len(env) != 0 {
+		t.Errorf("sidecar should not get the env, got %v", env)
+	}
+
+	_, err = renderJobSpec("werft-build-main.1", md, spec)
+	if err == nil || !strings.Contains(err.Error(), "env file .werft/build.env was not read from the repository") {
+		t.Errorf("expected error for unbundled env file, got %v", err)
+	}
+}
+
+func TestApplyDefaultImage(t *testing.T) {
+	md := &v1.JobMetadata{
+		Owner:      "alice",
+		Repository: &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: "refs/heads/main"},
+		Trigger:    v1.JobTrigger_TRIGGER_PUSH,
+	}
+	files := map[string]string{
+		".werft/templates/base.yaml": "pod:\n  containers:\n  - name: build\n",
+	}
+	read := func(path string) ([]byte, error) {
+		c, ok := files[path]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(c), nil
+	}
+
+	tests := []struct {
+		Name         string
+		Spec         string
+		DefaultImage string
+		Expectation  []string
+	}{
+		{
+			Name:         "no image",
+			Spec:         "pod:\n  containers:\n  - name: build\n",
+			DefaultImage: "eu.gcr.io/werft/build:latest",
+			Expectation:  []string{"eu.gcr.io/werft/build:latest"},
+		},
+		{
+			Name:         "explicit image",
+			Spec:         "pod:\n  containers:\n  - name: build\n    image: golang:1.17\n",
+			DefaultImage: "eu.gcr.io/werft/build:latest",
+			Expectation:  []string{"golang:1.17"},
+		},
+		{
+			Name:         "some containers",
+			Spec:         "pod:\n  containers:\n  - name: build\n  - name: proxy\n    image: envoy\n",
+			DefaultImage: "eu.gcr.io/werft/build:latest",
+			Expectation:  []string{"eu.gcr.io/werft/build:latest", "envoy"},
+		},
+		{
+			Name:         "template",
+			Spec:         "extends: .werft/templates/base.yaml\n",
+			DefaultImage: "eu.gcr.io/werft/build:latest",
+			Expectation:  []string{"eu.gcr.io/werft/build:latest"},
+		},
+		{
+			Name:        "no default image",
+			Spec:        "pod:\n  containers:\n  - name: build\n",
+			Expectation: []string{""},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			bundle, err := repoconfig.BundleTemplates([]byte(test.Spec), nil, read)
+			if err != nil {
+				t.Fatalf("cannot bundle job spec: %v", err)
+			}
+			if test.DefaultImage != "" {
+				bundle = repoconfig.BundleDefaultImage(bundle, test.DefaultImage)
+			}
+			jobspec, err := renderJobSpec("werft-build-main.1", md, bundle)
+			if err != nil {
+				t.Fatalf("cannot render job spec: %v", err)
+			}
+			applyDefaultImage(jobspec.Pod, repoconfig.DefaultImage(bundle))
+
+			var act []string
+			for _, c := range jobspec.Pod.Containers {
+				act = append(act, c.Image)
+			}
+			if !reflect.DeepEqual(act, test.Expectation) {
+				t.Errorf("unexpected images: %v; expected %v", act, test.Expectation)
+			}
+		})
+	}
+}
+
+func TestEffectiveJobSpec(t *testing.T) {
+	md := &v1.JobMetadata{
+		Owner:      "alice",
+		Repository: &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: "refs/heads/main"},
+		Trigger:    v1.JobTrigger_TRIGGER_PUSH,
+	}
+	spec := []byte(`env:
+  REF: ${repo.ref}
+resources:
+  requests:
+    cpu: 500m
+pod:
+  containers:
+  - name: build
+    command: ["make", "{{ .Owner }}"]
+    env:
+    - name: NPM_TOKEN
+      value: s3cr3t
+`)
+	bundle := repoconfig.BundleDefaultImage(spec, "eu.gcr.io/werft/build:latest")
+	jobspec, err := renderJobSpec("werft-build-main.1", md, bundle)
+	if err != nil {
+		t.Fatalf("cannot render job spec: %v", err)
+	}
+	resources, err := jobspec.ParseResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// apply the defaults like RunJob does
+	applyDefaultImage(jobspec.Pod, repoconfig.DefaultImage(bundle))
+	applyResources(jobspec.Pod, resources, jobspec.Sidecars)
+	applyEnv(jobspec.Pod, jobspec.Env, jobspec.Sidecars)
+
+	srv := &Service{Executor: &executor.Executor{Config: executor.Config{JobTotalTimeout: &executor.Duration{Duration: time.Hour}}}}
+	act, err := srv.effectiveJobSpec(jobspec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(act, "${") || strings.Contains(act, "{{") {
+		t.Errorf("effective job spec must have its variables replaced:\n%s", act)
+	}
+	if strings.Contains(act, "s3cr3t") {
+		t.Errorf("effective job spec must not contain secrets:\n%s", act)
+	}
+	if jobspec.Pod.Containers[0].Env[0].Value != "s3cr3t" {
+		t.Errorf("encoding the effective job spec must not change the job spec")
+	}
+
+	effective, err := decodeJobSpec([]byte(act))
+	if err != nil {
+		t.Fatalf("cannot decode effective job spec: %v\n%s", err, act)
+	}
+	c := effective.Pod.Containers[0]
+	if c.Image != "eu.gcr.io/werft/build:latest" {
+		t.Errorf("effective job spec must have the default image, got %q", c.Image)
+	}
+	if cpu := c.Resources.Requests[corev1.ResourceCPU]; cpu.String() != "500m" {
+		t.Errorf("effective job spec must have the default resources, got %v", c.Resources)
+	}
+	if !reflect.DeepEqual(c.Command, []string{"make", "alice"}) {
+		t.Errorf("effective job spec must have its template executed, got %v", c.Command)
+	}
+	expectedEnv := []corev1.EnvVar{{Name: "NPM_TOKEN", Value: "[redacted]"}, {Name: "REF", Value: "refs/heads/main"}}
+	if !reflect.DeepEqual(c.Env, expectedEnv) {
+		t.Errorf("unexpected env: %v; expected %v", c.Env, expectedEnv)
+	}
+	if effective.Timeout != "1h0m0s" {
+		t.Errorf("effective job spec must have the default timeout, got %q", effective.Timeout)
+	}
+}
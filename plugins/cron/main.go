@@ -2,9 +2,15 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
+	"sync"
+	"time"
 
 	v1 "github.com/csweichel/werft/pkg/api/v1"
 	plugin "github.com/csweichel/werft/pkg/plugin/client"
@@ -22,6 +28,10 @@ type Config struct {
 		Trigger     string            `yaml:"trigger,omitempty"`
 		Annotations map[string]string `yaml:"annotations,omitempty"`
 	} `yaml:"tasks"`
+
+	// StateFile is where we persist the last time each task ran. If set, a restarted plugin
+	// catches up on runs it missed while it was down and won't run tasks twice.
+	StateFile string `yaml:"stateFile,omitempty"`
 }
 
 func main() {
@@ -38,6 +48,11 @@ func (*cronPlugin) Run(ctx context.Context, config interface{}, srv v1.WerftServ
 		return fmt.Errorf("config has wrong type %s", reflect.TypeOf(config))
 	}
 
+	state, err := loadRunState(cfg.StateFile)
+	if err != nil {
+		return err
+	}
+
 	c := cron.New()
 	for idx, task := range cfg.Tasks {
 		repo, err := reporef.Parse(task.Repo)
@@ -45,7 +60,7 @@ func (*cronPlugin) Run(ctx context.Context, config interface{}, srv v1.WerftServ
 			return err
 		}
 
-		var trigger v1.JobTrigger
+		trigger := v1.JobTrigger_TRIGGER_SCHEDULE
 		if trg, ok := v1.JobTrigger_value[fmt.Sprintf("TRIGGER_%s", strings.ToUpper(task.Trigger))]; ok {
 			trigger = v1.JobTrigger(trg)
 		} else if task.Trigger != "" {
@@ -69,19 +84,153 @@ func (*cronPlugin) Run(ctx context.Context, config interface{}, srv v1.WerftServ
 			},
 			JobPath: task.JobPath,
 		}
-		_, err = c.AddFunc(task.Spec, func() {
-			_, err := srv.StartGitHubJob(ctx, request)
-			if err != nil {
-				log.WithError(err).WithField("idx", idx).WithField("spec", task.Spec).Error("cannot start job")
-			}
-		})
+		sched, err := cron.ParseStandard(task.Spec)
 		if err != nil {
 			return err
 		}
 
+		var (
+			idx  = idx
+			spec = task.Spec
+		)
+		job := &scheduledTask{
+			Key:      strings.Join([]string{task.Spec, task.Repo, task.JobPath}, "|"),
+			Schedule: sched,
+			State:    state,
+			Start: func() error {
+				_, err := srv.StartGitHubJob(ctx, request)
+				if err != nil {
+					log.WithError(err).WithField("idx", idx).WithField("spec", spec).Error("cannot start job")
+				}
+				return err
+			},
+		}
+		c.Schedule(sched, job)
+
+		if job.Missed(time.Now()) {
+			log.WithField("spec", task.Spec).Info("catching up on missed run")
+			go job.Run()
+		}
+
 		log.WithField("spec", task.Spec).Info("scheduled job")
 	}
-	c.Run()
+	c.Start()
+	<-ctx.Done()
+	<-c.Stop().Done()
 
 	return nil
 }
+
+// scheduledTask starts a job on schedule
+type scheduledTask struct {
+	Key      string
+	Schedule cron.Schedule
+	State    *runState
+	Start    func() error
+
+	// mu prevents a catch-up run and a scheduled run from starting the job concurrently
+	mu sync.Mutex
+}
+
+// Run implements cron.Job
+func (t *scheduledTask) Run() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if !t.Due(now) {
+		log.WithField("task", t.Key).Debug("task already ran - not running it again")
+		return
+	}
+
+	err := t.Start()
+	if err != nil {
+		return
+	}
+	err = t.State.MarkRun(t.Key, now)
+	if err != nil {
+		log.WithError(err).WithField("task", t.Key).Warn("cannot persist last run")
+	}
+}
+
+// Due returns true if the task has not run since its last scheduled time
+func (t *scheduledTask) Due(now time.Time) bool {
+	last := t.State.LastRun(t.Key)
+	if last.IsZero() {
+		return true
+	}
+	return !t.Schedule.Next(last).After(now)
+}
+
+// Missed returns true if the task ran before, but missed a scheduled run since then
+func (t *scheduledTask) Missed(now time.Time) bool {
+	return !t.State.LastRun(t.Key).IsZero() && t.Due(now)
+}
+
+// runState persists the last time each task ran
+type runState struct {
+	fn string
+
+	mu      sync.Mutex
+	lastRun map[string]time.Time
+}
+
+// loadRunState loads the run state from a file. If fn is empty, the state is not persisted.
+func loadRunState(fn string) (*runState, error) {
+	res := &runState{fn: fn, lastRun: make(map[string]time.Time)}
+	if fn == "" {
+		return res, nil
+	}
+
+	fc, err := ioutil.ReadFile(fn)
+	if os.IsNotExist(err) {
+		return res, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read state file: %w", err)
+	}
+	err = json.Unmarshal(fc, &res.lastRun)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal state file: %w", err)
+	}
+	return res, nil
+}
+
+// LastRun returns the last time a task ran or the zero time if it never ran
+func (s *runState) LastRun(key string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastRun[key]
+}
+
+// MarkRun records a task run and persists the state
+func (s *runState) MarkRun(key string, t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastRun[key] = t
+	if s.fn == "" {
+		return nil
+	}
+
+	fc, err := json.Marshal(s.lastRun)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so that we never leave a partial state file behind
+	tmp, err := ioutil.TempFile(filepath.Dir(s.fn), filepath.Base(s.fn)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(fc)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.fn)
+}
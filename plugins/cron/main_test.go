@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cron "github.com/robfig/cron/v3"
+)
+
+func TestScheduledTaskDue(t *testing.T) {
+	var (
+		nightly, _ = cron.ParseStandard("0 2 * * *")
+		day        = time.Date(2021, 6, 1, 0, 0, 0, 0, time.Local)
+	)
+
+	tests := []struct {
+		Name    string
+		LastRun time.Time
+		Now     time.Time
+		Due     bool
+		Missed  bool
+	}{
+		{Name: "never ran", Now: day.Add(2 * time.Hour), Due: true},
+		{Name: "scheduled run", LastRun: day.Add(-22 * time.Hour), Now: day.Add(2 * time.Hour), Due: true, Missed: true},
+		{Name: "already ran", LastRun: day.Add(2 * time.Hour), Now: day.Add(2*time.Hour + time.Second)},
+		{Name: "restart after run", LastRun: day.Add(2 * time.Hour), Now: day.Add(10 * time.Hour)},
+		{Name: "missed while down", LastRun: day.Add(-22 * time.Hour), Now: day.Add(10 * time.Hour), Due: true, Missed: true},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			state, _ := loadRunState("")
+			if !test.LastRun.IsZero() {
+				state.MarkRun("task", test.LastRun)
+			}
+			task := &scheduledTask{Key: "task", Schedule: nightly, State: state}
+
+			if due := task.Due(test.Now); due != test.Due {
+				t.Errorf("unexpected due: want %v, got %v", test.Due, due)
+			}
+			if missed := task.Missed(test.Now); missed != test.Missed {
+				t.Errorf("unexpected missed: want %v, got %v", test.Missed, missed)
+			}
+		})
+	}
+}
+
+func TestScheduledTaskRun(t *testing.T) {
+	every, _ := cron.ParseStandard("@every 1h")
+	state, _ := loadRunState("")
+
+	var starts int
+	task := &scheduledTask{
+		Key:      "task",
+		Schedule: every,
+		State:    state,
+		Start: func() error {
+			starts++
+			return nil
+		},
+	}
+
+	task.Run()
+	task.Run()
+	if starts != 1 {
+		t.Errorf("expected the job to start once, started %d times", starts)
+	}
+	if state.LastRun("task").IsZero() {
+		t.Error("expected last run to be recorded")
+	}
+}
+
+func TestRunStatePersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "werft-cron")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "state.json")
+
+	state, err := loadRunState(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.LastRun("task").IsZero() {
+		t.Fatal("expected empty state")
+	}
+
+	lastRun := time.Date(2021, 6, 1, 2, 0, 0, 0, time.UTC)
+	err = state.MarkRun("task", lastRun)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := loadRunState(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if act := restored.LastRun("task"); !act.Equal(lastRun) {
+		t.Errorf("unexpected last run: want %v, got %v", lastRun, act)
+	}
+
+	err = ioutil.WriteFile(fn, []byte("not json"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = loadRunState(fn)
+	if err == nil {
+		t.Error("expected error for corrupt state file")
+	}
+}
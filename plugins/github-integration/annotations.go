@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/google/go-github/v35/github"
+)
+
+const (
+	// annotationEvent names the GitHub event which started the job, e.g. push or issue_comment
+	annotationEvent = "github.event"
+	// annotationPR is the number of the pull request the job was started for
+	annotationPR = "github.pr"
+	// annotationAuthor is the login of the pull request's author, of whoever pushed, or of the release's author
+	annotationAuthor = "github.author"
+	// annotationLabels is the comma-separated list of labels on the pull request
+	annotationLabels = "github.labels"
+	// annotationRelease is the name of the release the job was started for
+	annotationRelease = "github.release"
+)
+
+// eventAnnotations produces the annotations describing the GitHub event a job is started for.
+// Values GitHub doesn't provide for an event are left out.
+func eventAnnotations(event interface{}) map[string]string {
+	res := make(map[string]string)
+	switch event := event.(type) {
+	case *github.PushEvent:
+		res[annotationEvent] = "push"
+		res[annotationAuthor] = event.GetSender().GetLogin()
+	case *github.PullRequestEvent:
+		pr := event.GetPullRequest()
+		res[annotationEvent] = "pull_request"
+		res[annotationPR] = prNumber(pr.GetNumber())
+		res[annotationAuthor] = pr.GetUser().GetLogin()
+		res[annotationLabels] = joinLabels(pr.Labels)
+	case *github.ReleaseEvent:
+		res[annotationEvent] = "release"
+		res[annotationAuthor] = event.GetRelease().GetAuthor().GetLogin()
+		res[annotationRelease] = event.GetRelease().GetName()
+	case *github.IssueCommentEvent:
+		// comments on pull requests are issue comments, where the issue describes the pull request
+		issue := event.GetIssue()
+		res[annotationEvent] = "issue_comment"
+		res[annotationPR] = prNumber(issue.GetNumber())
+		res[annotationAuthor] = issue.GetUser().GetLogin()
+		res[annotationLabels] = joinLabels(issue.Labels)
+	}
+
+	for k, v := range res {
+		if v == "" {
+			delete(res, k)
+		}
+	}
+	return res
+}
+
+// prNumber formats the number of a pull request, which is empty if GitHub didn't send one
+func prNumber(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprint(n)
+}
+
+// joinLabels produces the comma-separated list of label names
+func joinLabels(labels []*github.Label) string {
+	names := make([]string, 0, len(labels))
+	for _, l := range labels {
+		names = append(names, l.GetName())
+	}
+	return strings.Join(names, ",")
+}
+
+// withEventAnnotations adds the annotations of a GitHub event to the list of annotations.
+// The event annotations replace annotations with the same key so that they cannot be forged.
+func withEventAnnotations(annotations []*v1.Annotation, event interface{}) []*v1.Annotation {
+	evtAnnotations := eventAnnotations(event)
+
+	res := make([]*v1.Annotation, 0, len(annotations)+len(evtAnnotations))
+	for _, a := range annotations {
+		if _, exists := evtAnnotations[a.Key]; exists {
+			continue
+		}
+		res = append(res, a)
+	}
+
+	keys := make([]string, 0, len(evtAnnotations))
+	for k := range evtAnnotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		res = append(res, &v1.Annotation{Key: k, Value: evtAnnotations[k]})
+	}
+	return res
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"io/ioutil"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v35/github"
+)
+
+const testIssueCommentEvent = `{
+  "action": "created",
+  "issue": {
+    "number": 7,
+    "user": {"login": "octocat"},
+    "labels": [{"name": "enhancement"}],
+    "pull_request": {"url": "https://api.github.com/repos/Codertocat/Hello-World/pulls/7"}
+  },
+  "comment": {"id": 492700400, "body": "/werft run", "user": {"login": "Codertocat"}},
+  "repository": {"name": "Hello-World", "full_name": "Codertocat/Hello-World"},
+  "sender": {"login": "Codertocat"}
+}`
+
+func TestEventAnnotations(t *testing.T) {
+	prPayload, err := ioutil.ReadFile("testdata/pull_request.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Name        string
+		Type        string
+		Payload     []byte
+		Expectation map[string]string
+	}{
+		{
+			Name:    "pull request",
+			Type:    "pull_request",
+			Payload: prPayload,
+			Expectation: map[string]string{
+				"github.event":  "pull_request",
+				"github.pr":     "2",
+				"github.author": "Codertocat",
+				"github.labels": "bug,documentation",
+			},
+		},
+		{
+			Name:    "issue comment",
+			Type:    "issue_comment",
+			Payload: []byte(testIssueCommentEvent),
+			Expectation: map[string]string{
+				"github.event":  "issue_comment",
+				"github.pr":     "7",
+				"github.author": "octocat",
+				"github.labels": "enhancement",
+			},
+		},
+		{
+			Name:    "push",
+			Type:    "push",
+			Payload: []byte(`{"ref": "refs/heads/main", "sender": {"login": "Codertocat"}}`),
+			Expectation: map[string]string{
+				"github.event":  "push",
+				"github.author": "Codertocat",
+			},
+		},
+		{
+			Name:    "release",
+			Type:    "release",
+			Payload: []byte(`{"action": "published", "release": {"tag_name": "v1.0.0", "name": "Hello-World 1.0", "author": {"login": "octocat"}}, "sender": {"login": "Codertocat"}}`),
+			Expectation: map[string]string{
+				"github.event":   "release",
+				"github.author":  "octocat",
+				"github.release": "Hello-World 1.0",
+			},
+		},
+		{
+			Name:        "unsupported event",
+			Type:        "ping",
+			Payload:     []byte(`{"zen": "Keep it logically awesome.", "hook_id": 1}`),
+			Expectation: map[string]string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			event, err := github.ParseWebHook(test.Type, test.Payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			act := eventAnnotations(event)
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("eventAnnotations() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWithEventAnnotations(t *testing.T) {
+	login := "Codertocat"
+	event := &github.PushEvent{Sender: &github.User{Login: &login}}
+
+	act := withEventAnnotations([]*v1.Annotation{
+		{Key: annotationStatusUpdate, Value: "Codertocat/Hello-World"},
+		{Key: "github.author", Value: "someone-else"},
+	}, event)
+
+	expectation := []*v1.Annotation{
+		{Key: annotationStatusUpdate, Value: "Codertocat/Hello-World"},
+		{Key: "github.author", Value: "Codertocat"},
+		{Key: "github.event", Value: "push"},
+	}
+	if len(act) != len(expectation) {
+		t.Fatalf("unexpected number of annotations: want %d, got %d", len(expectation), len(act))
+	}
+	for i := range expectation {
+		if act[i].Key != expectation[i].Key || act[i].Value != expectation[i].Value {
+			t.Errorf("unexpected annotation %d: want %s=%s, got %s=%s", i, expectation[i].Key, expectation[i].Value, act[i].Key, act[i].Value)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/csweichel/werft/pkg/api/repoconfig"
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/google/go-github/v35/github"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// pathWerftConfig is the path of the werft config in a repository, mirrors werft.PathWerftConfig
+	pathWerftConfig = ".werft/config.yaml"
+
+	// errorMarkerPrefix starts a log line which marks an error, e.g. "werft error main.go:12: something broke"
+	errorMarkerPrefix = "werft error "
+
+	// maxCheckAnnotations is the number of annotations GitHub accepts per check run update
+	maxCheckAnnotations = 50
+
+	// maxRepoConfigCacheSize limits the number of repo configs we keep around
+	maxRepoConfigCacheSize = 1000
+
+	// reasonSuperseded starts the details of jobs canceled by a newer job with the same mutex, mirrors executor.ReasonSuperseded
+	reasonSuperseded = "superseded"
+)
+
+// errorMarkerLocation matches the location of an error marker
+var errorMarkerLocation = regexp.MustCompile(`^(\S+):(\d+): (.*)$`)
+
+// logError is an error marker found in a job's log
+type logError struct {
+	Slice   string
+	Path    string
+	Line    int
+	Message string
+}
+
+// parseErrorMarker extracts a logError from a single log line. Returns false if the line is no error marker.
+func parseErrorMarker(slice, line string) (res logError, ok bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, errorMarkerPrefix) {
+		return logError{}, false
+	}
+	line = strings.TrimSpace(strings.TrimPrefix(line, errorMarkerPrefix))
+	if line == "" {
+		return logError{}, false
+	}
+
+	res = logError{Slice: slice, Message: line}
+	if m := errorMarkerLocation.FindStringSubmatch(line); m != nil {
+		ln, err := strconv.Atoi(m[2])
+		if err == nil && ln > 0 {
+			res.Path, res.Line, res.Message = m[1], ln, m[3]
+		}
+	}
+	return res, true
+}
+
+// checksConfig returns the GitHub config of a repository at a particular revision.
+// Repositories without config or without GitHub section produce a nil config.
+func (p *githubTriggerPlugin) checksConfig(ctx context.Context, owner, repo, sha string) (*repoconfig.GitHubConfig, error) {
+	key := fmt.Sprintf("%s/%s@%s", owner, repo, sha)
+
+	p.mu.Lock()
+	cfg, ok := p.repoConfigs[key]
+	p.mu.Unlock()
+	if ok {
+		return cfg, nil
+	}
+
+	var content *github.RepositoryContent
+	err := retryOnRateLimit(ctx, func() (err error) {
+		content, _, _, err = p.Github.Repositories.GetContents(ctx, owner, repo, pathWerftConfig, &github.RepositoryContentGetOptions{Ref: sha})
+		return err
+	})
+	if rerr, ok := err.(*github.ErrorResponse); ok && rerr.Response != nil && rerr.Response.StatusCode == http.StatusNotFound {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if content != nil {
+		raw, err := content.GetContent()
+		if err != nil {
+			return nil, err
+		}
+		var rc repoconfig.C
+		err = yaml.Unmarshal([]byte(raw), &rc)
+		if err != nil {
+			return nil, fmt.Errorf("cannot unmarshal %s: %w", pathWerftConfig, err)
+		}
+		cfg = rc.GitHub
+	}
+
+	p.mu.Lock()
+	if p.repoConfigs == nil || len(p.repoConfigs) >= maxRepoConfigCacheSize {
+		p.repoConfigs = make(map[string]*repoconfig.GitHubConfig)
+	}
+	p.repoConfigs[key] = cfg
+	p.mu.Unlock()
+
+	return cfg, nil
+}
+
+// updateGitHubCheck reports the job as check run if the repository opted in to do so
+func (p *githubTriggerPlugin) updateGitHubCheck(ctx context.Context, job *v1.JobStatus, owner, repo, sha, name, url string) error {
+	cfg, err := p.checksConfig(ctx, owner, repo, sha)
+	if err != nil {
+		return err
+	}
+	if cfg == nil || !cfg.Checks {
+		return nil
+	}
+
+	p.mu.Lock()
+	runID, exists := p.checkRuns[job.Name]
+	p.mu.Unlock()
+
+	if job.Phase != v1.JobPhase_PHASE_DONE {
+		if exists {
+			return nil
+		}
+
+		status := "in_progress"
+		var run *github.CheckRun
+		err = retryOnRateLimit(ctx, func() (err error) {
+			run, _, err = p.Github.Checks.CreateCheckRun(ctx, owner, repo, github.CreateCheckRunOptions{
+				Name:       name,
+				HeadSHA:    sha,
+				DetailsURL: &url,
+				ExternalID: &job.Name,
+				Status:     &status,
+				StartedAt:  checkTimestamp(jobTime(job.Metadata.Created)),
+			})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		p.mu.Lock()
+		if p.checkRuns == nil {
+			p.checkRuns = make(map[string]int64)
+		}
+		p.checkRuns[job.Name] = run.GetID()
+		p.mu.Unlock()
+		return nil
+	}
+
+	var errs []logError
+	if cfg.CheckAnnotations {
+		errs, err = p.collectLogErrors(ctx, job.Name)
+		if err != nil {
+			log.WithError(err).WithField("job", job.Name).Warn("cannot collect errors from job log")
+		}
+	}
+
+	var (
+		status      = "completed"
+		conclusion  = checkConclusion(job)
+		completedAt = time.Now()
+		output      = checkRunOutput(job, errs)
+	)
+	if finished := jobTime(job.Metadata.Finished); !finished.IsZero() {
+		completedAt = finished
+	}
+	if exists {
+		err = retryOnRateLimit(ctx, func() error {
+			_, _, err := p.Github.Checks.UpdateCheckRun(ctx, owner, repo, runID, github.UpdateCheckRunOptions{
+				Name:        name,
+				DetailsURL:  &url,
+				ExternalID:  &job.Name,
+				Status:      &status,
+				Conclusion:  &conclusion,
+				CompletedAt: checkTimestamp(completedAt),
+				Output:      output,
+			})
+			return err
+		})
+	} else {
+		err = retryOnRateLimit(ctx, func() error {
+			_, _, err := p.Github.Checks.CreateCheckRun(ctx, owner, repo, github.CreateCheckRunOptions{
+				Name:        name,
+				HeadSHA:     sha,
+				DetailsURL:  &url,
+				ExternalID:  &job.Name,
+				Status:      &status,
+				Conclusion:  &conclusion,
+				StartedAt:   checkTimestamp(jobTime(job.Metadata.Created)),
+				CompletedAt: checkTimestamp(completedAt),
+				Output:      output,
+			})
+			return err
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	delete(p.checkRuns, job.Name)
+	p.mu.Unlock()
+
+	return nil
+}
+
+// collectLogErrors reads the log of a finished job and returns all error markers therein
+func (p *githubTriggerPlugin) collectLogErrors(ctx context.Context, name string) ([]logError, error) {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	logs, err := p.Werft.Listen(ctx, &v1.ListenRequest{
+		Name: name,
+		Logs: v1.ListenRequestLogs_LOGS_RAW,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var res []logError
+	for {
+		msg, err := logs.Recv()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return res, err
+		}
+
+		slice := msg.GetSlice()
+		if slice == nil || slice.Type != v1.LogSliceType_SLICE_CONTENT {
+			continue
+		}
+		if e, ok := parseErrorMarker(slice.Name, slice.Payload); ok {
+			res = append(res, e)
+		}
+	}
+}
+
+// checkConclusion maps the outcome of a job to a check run conclusion
+func checkConclusion(job *v1.JobStatus) string {
+	if job.Conditions == nil {
+		return "failure"
+	}
+	if job.Conditions.Skipped {
+		return "skipped"
+	}
+	if job.Conditions.Success {
+		return "success"
+	}
+	if !job.Conditions.DidExecute || strings.HasPrefix(job.Details, "job was stopped manually") || strings.HasPrefix(job.Details, reasonSuperseded) {
+		return "cancelled"
+	}
+	return "failure"
+}
+
+// checkRunOutput produces the summary of a finished job. Errors with a location become annotations,
+// all others are listed in the summary.
+func checkRunOutput(job *v1.JobStatus, errs []logError) *github.CheckRunOutput {
+	var (
+		title       string
+		summary     strings.Builder
+		annotations []*github.CheckRunAnnotation
+	)
+	switch checkConclusion(job) {
+	case "success":
+		title = "The build succeeded!"
+	case "cancelled":
+		title = "The build was cancelled"
+	case "skipped":
+		title = "The build was skipped"
+	default:
+		title = "The build failed!"
+	}
+
+	fmt.Fprintf(&summary, "Job `%s` finished", job.Name)
+	if job.Metadata != nil {
+		created, finished := jobTime(job.Metadata.Created), jobTime(job.Metadata.Finished)
+		if !created.IsZero() && !finished.IsZero() {
+			fmt.Fprintf(&summary, " after %s", finished.Sub(created).Round(time.Second))
+		}
+	}
+	summary.WriteString(".\n")
+	if job.Details != "" {
+		fmt.Fprintf(&summary, "\n%s\n", job.Details)
+	}
+	writeCheckRunResults(&summary, job.Results)
+
+	var unlocated []logError
+	for _, e := range errs {
+		if e.Path == "" {
+			unlocated = append(unlocated, e)
+			continue
+		}
+		if len(annotations) >= maxCheckAnnotations {
+			continue
+		}
+
+		var (
+			e     = e
+			level = "failure"
+		)
+		annotations = append(annotations, &github.CheckRunAnnotation{
+			Path:            &e.Path,
+			StartLine:       &e.Line,
+			EndLine:         &e.Line,
+			AnnotationLevel: &level,
+			Message:         &e.Message,
+			Title:           &e.Slice,
+		})
+	}
+	if len(errs) > 0 {
+		fmt.Fprintf(&summary, "\nThe job reported %d error(s)", len(errs))
+		if n := len(errs) - len(unlocated); n > len(annotations) {
+			fmt.Fprintf(&summary, ", only the first %d are shown inline", len(annotations))
+		}
+		summary.WriteString(".\n")
+	}
+	for _, e := range unlocated {
+		fmt.Fprintf(&summary, "- **%s**: %s\n", e.Slice, e.Message)
+	}
+
+	sum := summary.String()
+	return &github.CheckRunOutput{
+		Title:       &title,
+		Summary:     &sum,
+		Annotations: annotations,
+	}
+}
+
+// writeCheckRunResults lists the results a job published to GitHub as markdown table
+func writeCheckRunResults(summary *strings.Builder, results []*v1.JobResult) {
+	var published []*v1.JobResult
+	for _, r := range results {
+		if publishesToGitHub(r) {
+			published = append(published, r)
+		}
+	}
+	if len(published) == 0 {
+		return
+	}
+
+	// pipes would end the table cell
+	cell := strings.NewReplacer("|", "\\|", "\n", " ")
+	summary.WriteString("\n| Result | Value | Description |\n| --- | --- | --- |\n")
+	for _, r := range published {
+		value := cell.Replace(r.Payload)
+		if r.Type == "url" {
+			value = fmt.Sprintf("[%s](%s)", value, value)
+		}
+		fmt.Fprintf(summary, "| %s | %s | %s |\n", cell.Replace(r.Type), value, cell.Replace(r.Description))
+	}
+}
+
+// publishesToGitHub returns true if a result was posted to the github channel or a github-check- channel
+func publishesToGitHub(r *v1.JobResult) bool {
+	for _, c := range r.Channels {
+		if c == "github" || strings.HasPrefix(c, werftResultChannelPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkTimestamp(t time.Time) *github.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return &github.Timestamp{Time: t}
+}
+
+// jobTime converts a job timestamp, producing the zero time if the timestamp is absent or invalid
+func jobTime(ts *timestamp.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	t, err := ptypes.Timestamp(ts)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseErrorMarker(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Line        string
+		Expectation *logError
+	}{
+		{Name: "no marker", Line: "hello world"},
+		{Name: "empty marker", Line: "werft error "},
+		{Name: "message only", Line: "werft error something broke", Expectation: &logError{Slice: "build", Message: "something broke"}},
+		{Name: "location", Line: "werft error pkg/foo/foo.go:42: undefined: bar\n", Expectation: &logError{Slice: "build", Path: "pkg/foo/foo.go", Line: 42, Message: "undefined: bar"}},
+		{Name: "invalid line", Line: "werft error foo.go:0: broken", Expectation: &logError{Slice: "build", Message: "foo.go:0: broken"}},
+		{Name: "colon in message", Line: "werft error tests failed: 3 of 10", Expectation: &logError{Slice: "build", Message: "tests failed: 3 of 10"}},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var act *logError
+			if e, ok := parseErrorMarker("build", test.Line); ok {
+				act = &e
+			}
+
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("parseErrorMarker() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckConclusion(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Job         *v1.JobStatus
+		Expectation string
+	}{
+		{Name: "no conditions", Job: &v1.JobStatus{}, Expectation: "failure"},
+		{Name: "success", Job: &v1.JobStatus{Conditions: &v1.JobConditions{Success: true, DidExecute: true}}, Expectation: "success"},
+		{Name: "failure", Job: &v1.JobStatus{Conditions: &v1.JobConditions{DidExecute: true}}, Expectation: "failure"},
+		{Name: "skipped", Job: &v1.JobStatus{Conditions: &v1.JobConditions{Success: true, Skipped: true}}, Expectation: "skipped"},
+		{Name: "never ran", Job: &v1.JobStatus{Conditions: &v1.JobConditions{}}, Expectation: "cancelled"},
+		{Name: "stopped", Job: &v1.JobStatus{Details: "job was stopped manually", Conditions: &v1.JobConditions{DidExecute: true}}, Expectation: "cancelled"},
+		{Name: "superseded", Job: &v1.JobStatus{Details: "superseded: a newer job (werft-main.2) with the same mutex (main) started", Conditions: &v1.JobConditions{DidExecute: true}}, Expectation: "cancelled"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			if act := checkConclusion(test.Job); act != test.Expectation {
+				t.Errorf("unexpected conclusion: want %s, got %s", test.Expectation, act)
+			}
+		})
+	}
+}
+
+func TestCheckRunOutput(t *testing.T) {
+	job := &v1.JobStatus{
+		Name:       "werft-build.1",
+		Conditions: &v1.JobConditions{DidExecute: true},
+	}
+	errs := []logError{{Slice: "test", Message: "tests failed"}}
+	for i := 0; i < maxCheckAnnotations+10; i++ {
+		errs = append(errs, logError{Slice: "build", Path: "main.go", Line: i + 1, Message: fmt.Sprintf("error %d", i)})
+	}
+
+	out := checkRunOutput(job, errs)
+	if out.GetTitle() != "The build failed!" {
+		t.Errorf("unexpected title: %s", out.GetTitle())
+	}
+	if len(out.Annotations) != maxCheckAnnotations {
+		t.Errorf("expected %d annotations, got %d", maxCheckAnnotations, len(out.Annotations))
+	}
+	if a := out.Annotations[0]; a.GetPath() != "main.go" || a.GetStartLine() != 1 || a.GetMessage() != "error 0" || a.GetTitle() != "build" {
+		t.Errorf("unexpected annotation: %v", a)
+	}
+	for _, s := range []string{"werft-build.1", "61 error(s)", "first 50", "**test**: tests failed"} {
+		if !strings.Contains(out.GetSummary(), s) {
+			t.Errorf("summary does not contain %q:\n%s", s, out.GetSummary())
+		}
+	}
+}
+
+func TestCheckRunResults(t *testing.T) {
+	job := &v1.JobStatus{
+		Name:       "werft-build.1",
+		Conditions: &v1.JobConditions{Success: true, DidExecute: true},
+		Results: []*v1.JobResult{
+			{Type: "url", Payload: "https://preview.example.com", Description: "preview environment", Channels: []string{"github"}},
+			{Type: "tests", Payload: "42 passed | 1 failed", Channels: []string{"github-check-tests"}},
+			{Type: "image", Payload: "werft:main", Channels: []string{"slack"}},
+			{Type: "docs", Payload: "https://docs.example.com"},
+		},
+	}
+
+	summary := checkRunOutput(job, nil).GetSummary()
+	for _, s := range []string{
+		"| Result | Value | Description |",
+		"| url | [https://preview.example.com](https://preview.example.com) | preview environment |",
+		"| tests | 42 passed \\| 1 failed |  |",
+	} {
+		if !strings.Contains(summary, s) {
+			t.Errorf("summary does not contain %q:\n%s", s, summary)
+		}
+	}
+	for _, s := range []string{"werft:main", "docs.example.com"} {
+		if strings.Contains(summary, s) {
+			t.Errorf("summary contains result not published to GitHub %q:\n%s", s, summary)
+		}
+	}
+
+	job.Results = job.Results[2:]
+	if summary := checkRunOutput(job, nil).GetSummary(); strings.Contains(summary, "| Result |") {
+		t.Errorf("summary should not list results if none was published to GitHub:\n%s", summary)
+	}
+}
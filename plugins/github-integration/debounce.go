@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	log "github.com/sirupsen/logrus"
+)
+
+// DebounceConfig configures how the plugin copes with bursts of push events, e.g. from a force-push or a rebase
+type DebounceConfig struct {
+	// Window is the time we wait for further pushes to a ref before starting a job for the latest one.
+	// Pushes which are superseded within the window don't start a job. Zero disables debouncing.
+	Window time.Duration `yaml:"window,omitempty"`
+
+	// MaxJobsPerMinute limits the number of jobs pushes start across all repositories.
+	// Pushes beyond that limit are dropped. Zero disables the limit.
+	MaxJobsPerMinute int `yaml:"maxJobsPerMinute,omitempty"`
+}
+
+// pushDebouncer starts a job for the latest push to a ref once no further push to that ref arrived within the window
+type pushDebouncer struct {
+	Window  time.Duration
+	Limiter *jobLimiter
+	Start   func(ctx context.Context, req *v1.StartGitHubJobRequest)
+
+	mu      sync.Mutex
+	pending map[string]*pendingPush
+}
+
+type pendingPush struct {
+	ctx   context.Context
+	req   *v1.StartGitHubJobRequest
+	timer *time.Timer
+}
+
+func newPushDebouncer(cfg DebounceConfig, start func(ctx context.Context, req *v1.StartGitHubJobRequest)) *pushDebouncer {
+	var limiter *jobLimiter
+	if cfg.MaxJobsPerMinute > 0 {
+		limiter = newJobLimiter(cfg.MaxJobsPerMinute, time.Now())
+	}
+	return &pushDebouncer{
+		Window:  cfg.Window,
+		Limiter: limiter,
+		Start:   start,
+		pending: make(map[string]*pendingPush),
+	}
+}
+
+// Push starts the job for a push once the window has passed without another push to the same ref.
+// A push supersedes a pending push to the same ref, which is dropped.
+func (d *pushDebouncer) Push(ctx context.Context, req *v1.StartGitHubJobRequest) {
+	if d.Window <= 0 {
+		d.start(ctx, req)
+		return
+	}
+
+	key := debounceKey(req.Metadata.Repository)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if prev, ok := d.pending[key]; ok {
+		prev.timer.Stop()
+		// the superseded push may have changed files the latest one didn't touch
+		req.ChangedFiles = mergeChangedFiles(prev.req.ChangedFiles, req.ChangedFiles)
+		log.WithField("ref", key).WithField("revision", prev.req.Metadata.Repository.Revision).Debug("dropping superseded push")
+	}
+
+	p := &pendingPush{ctx: ctx, req: req}
+	p.timer = time.AfterFunc(d.Window, func() { d.fire(key, p) })
+	d.pending[key] = p
+}
+
+func (d *pushDebouncer) fire(key string, p *pendingPush) {
+	d.mu.Lock()
+	if d.pending[key] != p {
+		// superseded after the timer fired
+		d.mu.Unlock()
+		return
+	}
+	delete(d.pending, key)
+	d.mu.Unlock()
+
+	d.start(p.ctx, p.req)
+}
+
+func (d *pushDebouncer) start(ctx context.Context, req *v1.StartGitHubJobRequest) {
+	if d.Limiter != nil && !d.Limiter.Allow(time.Now()) {
+		log.WithField("ref", debounceKey(req.Metadata.Repository)).WithField("revision", req.Metadata.Repository.Revision).Warn("too many jobs started by pushes - dropping push")
+		return
+	}
+	d.Start(ctx, req)
+}
+
+// debounceKey identifies the ref a push went to
+func debounceKey(repo *v1.Repository) string {
+	return repo.Host + "/" + repo.Owner + "/" + repo.Repo + ":" + repo.Ref
+}
+
+// mergeChangedFiles combines the files changed by two pushes. Nil means we don't know which files changed.
+func mergeChangedFiles(a, b []string) []string {
+	if a == nil || b == nil {
+		return nil
+	}
+
+	var (
+		res = make([]string, 0, len(a)+len(b))
+		idx = make(map[string]struct{})
+	)
+	for _, files := range [][]string{a, b} {
+		for _, f := range files {
+			if _, exists := idx[f]; exists {
+				continue
+			}
+			idx[f] = struct{}{}
+			res = append(res, f)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// jobLimiter is a token bucket which holds up to a minute's worth of jobs
+type jobLimiter struct {
+	perMinute float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newJobLimiter(perMinute int, now time.Time) *jobLimiter {
+	return &jobLimiter{
+		perMinute: float64(perMinute),
+		tokens:    float64(perMinute),
+		last:      now,
+	}
+}
+
+// Allow consumes a token if there is one
+func (l *jobLimiter) Allow(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.After(l.last) {
+		l.tokens += now.Sub(l.last).Minutes() * l.perMinute
+		if l.tokens > l.perMinute {
+			l.tokens = l.perMinute
+		}
+		l.last = now
+	}
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v35/github"
+)
+
+type startedJobs struct {
+	mu   sync.Mutex
+	reqs []*v1.StartGitHubJobRequest
+}
+
+func (s *startedJobs) Start(ctx context.Context, req *v1.StartGitHubJobRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reqs = append(s.reqs, req)
+}
+
+func (s *startedJobs) Revisions() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make(map[string]string)
+	for _, r := range s.reqs {
+		res[r.Metadata.Repository.Ref] = r.Metadata.Repository.Revision
+	}
+	return res
+}
+
+func TestDebouncePushBurst(t *testing.T) {
+	const window = 50 * time.Millisecond
+	var (
+		started startedJobs
+		p       = &githubTriggerPlugin{}
+	)
+	p.pushes = newPushDebouncer(DebounceConfig{Window: window}, started.Start)
+
+	push := func(ref, rev string, files ...string) {
+		p.processPushEvent(context.Background(), &github.PushEvent{
+			Ref:    github.String(ref),
+			After:  github.String(rev),
+			Pusher: &github.User{Name: github.String("csweichel")},
+			Repo: &github.PushEventRepository{
+				Name:  github.String("werft"),
+				Owner: &github.User{Name: github.String("csweichel")},
+			},
+			Size:    github.Int(1),
+			Commits: []*github.HeadCommit{{Modified: files}},
+		})
+	}
+	for i := 0; i < 5; i++ {
+		push("refs/heads/main", fmt.Sprintf("rev-%d", i), fmt.Sprintf("file-%d", i))
+	}
+	push("refs/heads/feature", "feature-rev", "feature-file")
+
+	time.Sleep(window / 2)
+	if n := len(started.Revisions()); n != 0 {
+		t.Fatalf("expected no job within the window, got %d", n)
+	}
+
+	time.Sleep(4 * window)
+	expected := map[string]string{
+		"refs/heads/main":    "rev-4",
+		"refs/heads/feature": "feature-rev",
+	}
+	if diff := cmp.Diff(expected, started.Revisions()); diff != "" {
+		t.Errorf("unexpected jobs (-want +got):\n%s", diff)
+	}
+	if n := len(started.reqs); n != 2 {
+		t.Errorf("expected a single job per ref, got %d jobs", n)
+	}
+	for _, r := range started.reqs {
+		if r.Metadata.Repository.Ref != "refs/heads/main" {
+			continue
+		}
+		if diff := cmp.Diff([]string{"file-0", "file-1", "file-2", "file-3", "file-4"}, r.ChangedFiles); diff != "" {
+			t.Errorf("changed files of superseded pushes must be kept (-want +got):\n%s", diff)
+		}
+	}
+
+	// once the window has passed, the next push starts a new job
+	push("refs/heads/main", "rev-5")
+	time.Sleep(4 * window)
+	if n := len(started.Revisions()); n != 2 || started.Revisions()["refs/heads/main"] != "rev-5" {
+		t.Errorf("expected a job for rev-5, got %v", started.Revisions())
+	}
+}
+
+func TestMergeChangedFiles(t *testing.T) {
+	tests := []struct {
+		Name        string
+		A, B        []string
+		Expectation []string
+	}{
+		{Name: "union", A: []string{"b", "a"}, B: []string{"c", "a"}, Expectation: []string{"a", "b", "c"}},
+		{Name: "empty", A: []string{}, B: []string{}, Expectation: []string{}},
+		{Name: "first unknown", B: []string{"a"}},
+		{Name: "second unknown", A: []string{"a"}},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			act := mergeChangedFiles(test.A, test.B)
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("unexpected files (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestJobLimiter(t *testing.T) {
+	var (
+		now = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+		l   = newJobLimiter(2, now)
+	)
+	steps := []struct {
+		After   time.Duration
+		Allowed bool
+	}{
+		{0, true},
+		{0, true},
+		{0, false},
+		{10 * time.Second, false},
+		{20 * time.Second, true},
+		{0, false},
+		{10 * time.Minute, true},
+		{0, true},
+		{0, false},
+	}
+	for i, s := range steps {
+		now = now.Add(s.After)
+		if act := l.Allow(now); act != s.Allowed {
+			t.Errorf("step %d: expected allowed=%v, got %v", i, s.Allowed, act)
+		}
+	}
+}
+
+func TestDebounceRateLimit(t *testing.T) {
+	var started startedJobs
+	d := newPushDebouncer(DebounceConfig{MaxJobsPerMinute: 3}, started.Start)
+	for i := 0; i < 10; i++ {
+		d.Push(context.Background(), &v1.StartGitHubJobRequest{
+			Metadata: &v1.JobMetadata{Repository: &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: fmt.Sprintf("refs/heads/branch-%d", i)}},
+		})
+	}
+	if n := len(started.reqs); n != 3 {
+		t.Errorf("expected 3 jobs, got %d", n)
+	}
+}
@@ -7,9 +7,11 @@ replace github.com/csweichel/werft => ../..
 require (
 	github.com/bradleyfalzon/ghinstallation v1.1.1
 	github.com/csweichel/werft v0.0.0-00010101000000-000000000000
-	github.com/google/go-cmp v0.5.2 // indirect
+	github.com/golang/protobuf v1.5.2
+	github.com/google/go-cmp v0.5.6
 	github.com/google/go-github/v35 v35.2.0
 	github.com/sirupsen/logrus v1.8.1
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
 
 replace k8s.io/api => k8s.io/api v0.20.4
@@ -1,22 +1,34 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"text/template"
 
 	"github.com/bradleyfalzon/ghinstallation"
+	"github.com/csweichel/werft/pkg/api/repoconfig"
 	v1 "github.com/csweichel/werft/pkg/api/v1"
 	plugin "github.com/csweichel/werft/pkg/plugin/client"
+	"github.com/csweichel/werft/pkg/tracing"
 	"github.com/google/go-github/v35/github"
 	log "github.com/sirupsen/logrus"
 )
 
 var (
 	werftGithubContextPrefix = "ci/werft"
+	defaultStatusContext     = werftGithubContextPrefix + "/{{ .Metadata.JobSpecName }}"
 	werftResultChannelPrefix = "github-check-"
 
 	// annotationStatusUpdate is set on jobs whoose status needs to be updated on GitHub.
@@ -33,15 +45,34 @@ Available commands are:
 `
 )
 
+const (
+	// signatureHeader carries the HMAC-SHA256 signature of the webhook payload
+	signatureHeader = "X-Hub-Signature-256"
+	// maxWebhookPayloadSize is the maximum size of webhook payloads we accept. GitHub caps payloads at 25MB.
+	maxWebhookPayloadSize = 25 * 1024 * 1024
+)
+
 // Config configures this plugin
 type Config struct {
 	BaseURL string `yaml:"baseURL"`
 
-	WebhookSecret  string `yaml:"webhookSecret"`
+	WebhookSecret string `yaml:"webhookSecret"`
+	// WebhookSecretPath points to a file containing the webhook secret, e.g. a mounted Kubernetes secret.
+	// If set, this takes precedence over WebhookSecret.
+	WebhookSecretPath string `yaml:"webhookSecretPath,omitempty"`
+
 	PrivateKeyPath string `yaml:"privateKeyPath"`
 	InstallationID int64  `yaml:"installationID,omitempty"`
 	AppID          int64  `yaml:"appID"`
 
+	// StatusContext is a Go template producing the context of the commit status we set for a job,
+	// e.g. "werft/{{ .Name }}". The template is executed against the job status. Defaults to
+	// "ci/werft/{{ .Metadata.JobSpecName }}".
+	StatusContext string `yaml:"statusContext,omitempty"`
+
+	// Debounce coalesces bursts of pushes to the same ref and limits the rate at which pushes start jobs
+	Debounce DebounceConfig `yaml:"debounce,omitempty"`
+
 	PRComments struct {
 		Enabled bool `yaml:"enabled"`
 
@@ -54,6 +85,22 @@ type Config struct {
 	} `yaml:"pullRequestComments"`
 }
 
+// loadWebhookSecret produces the secret used to verify webhook payloads
+func (cfg *Config) loadWebhookSecret() ([]byte, error) {
+	secret := []byte(cfg.WebhookSecret)
+	if cfg.WebhookSecretPath != "" {
+		fc, err := ioutil.ReadFile(cfg.WebhookSecretPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read webhook secret: %w", err)
+		}
+		secret = bytes.TrimSpace(fc)
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("no webhook secret configured - set webhookSecret or webhookSecretPath")
+	}
+	return secret, nil
+}
+
 func main() {
 	plg := &githubTriggerPlugin{}
 	plugin.Serve(&Config{},
@@ -66,6 +113,14 @@ type githubTriggerPlugin struct {
 	Config *Config
 	Werft  v1.WerftServiceClient
 	Github *github.Client
+
+	statusContext *template.Template
+	webhookSecret []byte
+	pushes        *pushDebouncer
+
+	mu          sync.Mutex
+	checkRuns   map[string]int64
+	repoConfigs map[string]*repoconfig.GitHubConfig
 }
 
 func (p *githubTriggerPlugin) Run(ctx context.Context, config interface{}, srv v1.WerftServiceClient) error {
@@ -74,15 +129,31 @@ func (p *githubTriggerPlugin) Run(ctx context.Context, config interface{}, srv v
 		return fmt.Errorf("config has wrong type %s", reflect.TypeOf(config))
 	}
 
+	webhookSecret, err := cfg.loadWebhookSecret()
+	if err != nil {
+		return err
+	}
+
 	ghtr, err := ghinstallation.NewKeyFromFile(http.DefaultTransport, cfg.AppID, cfg.InstallationID, cfg.PrivateKeyPath)
 	if err != nil {
 		return err
 	}
 	ghClient := github.NewClient(&http.Client{Transport: ghtr})
 
+	statusCtx := cfg.StatusContext
+	if statusCtx == "" {
+		statusCtx = defaultStatusContext
+	}
+	p.statusContext, err = template.New("statusContext").Parse(statusCtx)
+	if err != nil {
+		return fmt.Errorf("cannot parse statusContext: %w", err)
+	}
+
 	p.Config = cfg
+	p.webhookSecret = webhookSecret
 	p.Werft = srv
 	p.Github = ghClient
+	p.pushes = newPushDebouncer(cfg.Debounce, p.startPushJob)
 
 	errchan := make(chan error)
 	sub, err := srv.Subscribe(ctx, &v1.SubscribeRequest{})
@@ -98,7 +169,7 @@ func (p *githubTriggerPlugin) Run(ctx context.Context, config interface{}, srv v
 				return
 			}
 
-			err = p.updateGitHubStatus(inc.Result)
+			err = p.updateGitHubStatus(ctx, inc.Result)
 			if err != nil {
 				log.WithError(err).Error("cannot update GitHub status")
 			}
@@ -113,7 +184,7 @@ func (p *githubTriggerPlugin) Run(ctx context.Context, config interface{}, srv v
 	}
 }
 
-func (p *githubTriggerPlugin) updateGitHubStatus(job *v1.JobStatus) error {
+func (p *githubTriggerPlugin) updateGitHubStatus(ctx context.Context, job *v1.JobStatus) error {
 	var (
 		wantsUpdate   bool
 		statusDstRepo string
@@ -134,20 +205,26 @@ func (p *githubTriggerPlugin) updateGitHubStatus(job *v1.JobStatus) error {
 		desc  string
 	)
 	switch job.Phase {
-	case v1.JobPhase_PHASE_PREPARING, v1.JobPhase_PHASE_STARTING, v1.JobPhase_PHASE_RUNNING:
+	case v1.JobPhase_PHASE_QUEUED, v1.JobPhase_PHASE_WAITING, v1.JobPhase_PHASE_PREPARING, v1.JobPhase_PHASE_STARTING, v1.JobPhase_PHASE_RUNNING:
 		state = "pending"
 		desc = "build is " + strings.TrimPrefix(strings.ToLower(job.Phase.String()), "phase_")
 	default:
 		if job.Conditions.Success {
 			state = "success"
 			desc = "The build succeeded!"
+		} else if strings.HasPrefix(job.Details, reasonSuperseded) {
+			state = "failure"
+			desc = "The build was superseded by a newer one"
 		} else {
 			state = "failure"
 			desc = "The build failed!"
 		}
 	}
 	url := fmt.Sprintf("%s/job/%s", p.Config.BaseURL, job.Name)
-	jobGHctx := werftGithubContextPrefix + "/" + job.Metadata.JobSpecName
+	jobGHctx, err := p.renderStatusContext(job)
+	if err != nil {
+		return err
+	}
 	ghstatus := &github.RepoStatus{
 		State:       &state,
 		Description: &desc,
@@ -166,9 +243,23 @@ func (p *githubTriggerPlugin) updateGitHubStatus(job *v1.JobStatus) error {
 		owner, repo = job.Metadata.Owner, job.Metadata.Repository.Repo
 	}
 
+	sha := job.Metadata.Repository.Revision
+	if sha == "" {
+		// jobs started from a ref only don't carry the revision they ran against
+		err = retryOnRateLimit(ctx, func() (err error) {
+			sha, _, err = p.Github.Repositories.GetCommitSHA1(ctx, owner, repo, job.Metadata.Repository.Ref, "")
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("cannot resolve commit for %s: %w", job.Metadata.Repository.Ref, err)
+		}
+	}
+
 	log.WithField("status", ghstatus).Debugf("updating GitHub status for %s", job.Name)
-	ctx := context.Background()
-	_, _, err := p.Github.Repositories.CreateStatus(ctx, owner, repo, job.Metadata.Repository.Revision, ghstatus)
+	err = retryOnRateLimit(ctx, func() error {
+		_, _, err := p.Github.Repositories.CreateStatus(ctx, owner, repo, sha, ghstatus)
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -205,26 +296,49 @@ func (p *githubTriggerPlugin) updateGitHubStatus(job *v1.JobStatus) error {
 		if r.Type == "conclusion" {
 			success = r.Payload
 		}
-		_, _, err := p.Github.Repositories.CreateStatus(ctx,
-			owner,
-			repo,
-			job.Metadata.Repository.Revision,
-			&github.RepoStatus{
-				State:       &success,
-				TargetURL:   &resultURL,
-				Description: &r.Description,
-				Context:     &ghctx,
-			},
-		)
+		err := retryOnRateLimit(ctx, func() error {
+			_, _, err := p.Github.Repositories.CreateStatus(ctx,
+				owner,
+				repo,
+				sha,
+				&github.RepoStatus{
+					State:       &success,
+					TargetURL:   &resultURL,
+					Description: &r.Description,
+					Context:     &ghctx,
+				},
+			)
+			return err
+		})
 		if err != nil {
 			log.WithError(err).WithField("job", job.Name).Warn("cannot update result status")
 		}
 
 	}
 
+	err = p.updateGitHubCheck(ctx, job, owner, repo, sha, jobGHctx, url)
+	if err != nil {
+		log.WithError(err).WithField("job", job.Name).Warn("cannot update check run")
+	}
+
 	return nil
 }
 
+// renderStatusContext produces the GitHub status context for a job
+func (p *githubTriggerPlugin) renderStatusContext(job *v1.JobStatus) (string, error) {
+	tpl := p.statusContext
+	if tpl == nil {
+		tpl = template.Must(template.New("statusContext").Parse(defaultStatusContext))
+	}
+
+	var buf strings.Builder
+	err := tpl.Execute(&buf, job)
+	if err != nil {
+		return "", fmt.Errorf("cannot render statusContext: %w", err)
+	}
+	return buf.String(), nil
+}
+
 func (p *githubTriggerPlugin) Serve(ctx context.Context, l net.Listener) error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", http.HandlerFunc(p.HandleGithubWebhook))
@@ -251,7 +365,20 @@ func (p *githubTriggerPlugin) HandleGithubWebhook(w http.ResponseWriter, r *http
 		return
 	}
 
-	payload, err := github.ValidatePayload(r, []byte(p.Config.WebhookSecret))
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxWebhookPayloadSize))
+	if err != nil {
+		return
+	}
+	if serr := verifyWebhookSignature(body, r.Header.Get(signatureHeader), p.webhookSecret); serr != nil {
+		log.WithError(serr).WithField("remoteAddr", r.RemoteAddr).Warn("rejecting GitHub webhook")
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	// The signature is verified at this point. We restore the body so that ValidatePayload
+	// can extract the payload, which might be form-encoded.
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	payload, err := github.ValidatePayload(r, nil)
 	if err != nil && strings.Contains(err.Error(), "unknown X-Github-Event") {
 		err = nil
 		return
@@ -264,53 +391,159 @@ func (p *githubTriggerPlugin) HandleGithubWebhook(w http.ResponseWriter, r *http
 		return
 	}
 	switch event := event.(type) {
+	case *github.PingEvent:
+		log.WithField("hookID", event.GetHookID()).Info("received GitHub ping")
 	case *github.PushEvent:
-		p.processPushEvent(event)
+		// the job outlives the webhook request, hence we must not use the request's context
+		p.processPushEvent(tracing.Extract(context.Background(), r.Header), event)
+	case *github.ReleaseEvent:
+		p.processReleaseEvent(tracing.Extract(context.Background(), r.Header), event)
 	case *github.InstallationEvent:
 		p.processInstallationEvent(event)
 	case *github.IssueCommentEvent:
-		p.processIssueCommentEvent(r.Context(), event)
+		p.processIssueCommentEvent(tracing.Extract(r.Context(), r.Header), event)
 	default:
 		log.WithField("event", event).Debug("unhandled GitHub event")
 		http.Error(w, "unhandled event", http.StatusInternalServerError)
 	}
 }
 
-func (p *githubTriggerPlugin) processPushEvent(event *github.PushEvent) {
-	ctx := context.Background()
-	rev := *event.After
+// verifyWebhookSignature checks the HMAC-SHA256 signature GitHub computed over the webhook body
+func verifyWebhookSignature(body []byte, signature string, secret []byte) error {
+	if len(secret) == 0 {
+		return fmt.Errorf("no webhook secret configured")
+	}
+
+	const prefix = "sha256="
+	if !strings.HasPrefix(signature, prefix) {
+		return fmt.Errorf("missing or malformed %s header", signatureHeader)
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil {
+		return fmt.Errorf("malformed %s header: %w", signatureHeader, err)
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+func (p *githubTriggerPlugin) processPushEvent(ctx context.Context, event *github.PushEvent) {
+	req := pushJobRequest(event)
+	if p.pushes == nil {
+		p.startPushJob(ctx, req)
+		return
+	}
+	p.pushes.Push(ctx, req)
+}
 
+// pushJobRequest produces the request starting the job for a push. Pushing a tag starts a job
+// with the tag trigger, so that jobs can tell releases from branch builds.
+func pushJobRequest(event *github.PushEvent) *v1.StartGitHubJobRequest {
 	trigger := v1.JobTrigger_TRIGGER_PUSH
+	if strings.HasPrefix(event.GetRef(), "refs/tags/") {
+		trigger = v1.JobTrigger_TRIGGER_TAG
+	}
 	if event.Deleted != nil && *event.Deleted {
 		trigger = v1.JobTrigger_TRIGGER_DELETED
 	}
 
-	metadata := v1.JobMetadata{
-		Owner: *event.Pusher.Name,
-		Repository: &v1.Repository{
-			Host:     defaultGitHubHost,
-			Owner:    event.Repo.Owner.GetName(),
-			Repo:     event.Repo.GetName(),
-			Ref:      event.GetRef(),
-			Revision: rev,
-		},
-		Trigger: trigger,
-		Annotations: []*v1.Annotation{
-			{
-				Key:   annotationStatusUpdate,
-				Value: event.Repo.Owner.GetName() + "/" + event.Repo.GetName(),
+	return &v1.StartGitHubJobRequest{
+		Metadata: &v1.JobMetadata{
+			Owner: event.GetPusher().GetName(),
+			Repository: &v1.Repository{
+				Host:     defaultGitHubHost,
+				Owner:    event.Repo.Owner.GetName(),
+				Repo:     event.Repo.GetName(),
+				Ref:      event.GetRef(),
+				Revision: event.GetAfter(),
 			},
+			Trigger: trigger,
+			Annotations: withEventAnnotations([]*v1.Annotation{
+				{
+					Key:   annotationStatusUpdate,
+					Value: event.Repo.Owner.GetName() + "/" + event.Repo.GetName(),
+				},
+			}, event),
 		},
+		ChangedFiles: pushChangedFiles(event),
 	}
+}
 
-	_, err := p.Werft.StartGitHubJob(ctx, &v1.StartGitHubJobRequest{
-		Metadata: &metadata,
-	})
+func (p *githubTriggerPlugin) processReleaseEvent(ctx context.Context, event *github.ReleaseEvent) {
+	req, ok := releaseJobRequest(event)
+	if !ok {
+		return
+	}
+	p.startPushJob(ctx, req)
+}
+
+// releaseJobRequest produces the request starting the job for a published release. The job runs on the release's tag,
+// which werft resolves to a revision when starting the job. Returns false if the event doesn't publish a release.
+func releaseJobRequest(event *github.ReleaseEvent) (req *v1.StartGitHubJobRequest, ok bool) {
+	if event.GetAction() != "published" || event.GetRelease().GetTagName() == "" {
+		return nil, false
+	}
+
+	// unlike pushes, release events carry the owner's login rather than their name
+	owner := event.GetRepo().GetOwner().GetLogin()
+	return &v1.StartGitHubJobRequest{
+		Metadata: &v1.JobMetadata{
+			Owner: event.GetRelease().GetAuthor().GetLogin(),
+			Repository: &v1.Repository{
+				Host:  defaultGitHubHost,
+				Owner: owner,
+				Repo:  event.GetRepo().GetName(),
+				Ref:   "refs/tags/" + event.GetRelease().GetTagName(),
+			},
+			Trigger: v1.JobTrigger_TRIGGER_RELEASE,
+			Annotations: withEventAnnotations([]*v1.Annotation{
+				{
+					Key:   annotationStatusUpdate,
+					Value: owner + "/" + event.GetRepo().GetName(),
+				},
+			}, event),
+		},
+	}, true
+}
+
+// startPushJob starts the job for a push or a release
+func (p *githubTriggerPlugin) startPushJob(ctx context.Context, req *v1.StartGitHubJobRequest) {
+	_, err := p.Werft.StartGitHubJob(ctx, req)
 	if err != nil {
 		log.WithError(err).Warn("GitHub webhook error")
 	}
 }
 
+// pushChangedFiles lists the files changed by a push. GitHub includes at most 20 commits in the event,
+// if there were more we don't know all changes and return nil.
+func pushChangedFiles(event *github.PushEvent) []string {
+	if len(event.Commits) == 0 || event.GetSize() > len(event.Commits) {
+		return nil
+	}
+
+	var (
+		res = make([]string, 0)
+		idx = make(map[string]struct{})
+	)
+	for _, c := range event.Commits {
+		for _, files := range [][]string{c.Added, c.Modified, c.Removed} {
+			for _, f := range files {
+				if _, exists := idx[f]; exists {
+					continue
+				}
+				idx[f] = struct{}{}
+				res = append(res, f)
+			}
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
 func (p *githubTriggerPlugin) processInstallationEvent(event *github.InstallationEvent) {
 	if *event.Action != "created" {
 		return
@@ -491,7 +724,7 @@ func (p *githubTriggerPlugin) handleCommandRun(ctx context.Context, event *githu
 			Revision: pr.GetHead().GetSHA(),
 		},
 		Trigger:     v1.JobTrigger_TRIGGER_MANUAL,
-		Annotations: annotations,
+		Annotations: withEventAnnotations(annotations, event),
 	}
 	var nameSuffix string
 	if prDstOwner != prSrcOwner {
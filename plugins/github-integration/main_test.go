@@ -1,9 +1,13 @@
 package main
 
 import (
+	"strings"
 	"testing"
+	"text/template"
 
+	v1 "github.com/csweichel/werft/pkg/api/v1"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v35/github"
 )
 
 func TestParseCommand(t *testing.T) {
@@ -41,3 +45,201 @@ func TestParseCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderStatusContext(t *testing.T) {
+	job := &v1.JobStatus{
+		Name:     "werft-build-main.42",
+		Metadata: &v1.JobMetadata{JobSpecName: "build"},
+	}
+	tests := []struct {
+		Name     string
+		Template string
+		Expected string
+	}{
+		{Name: "default", Expected: "ci/werft/build"},
+		{Name: "job name", Template: "werft/{{ .Name }}", Expected: "werft/werft-build-main.42"},
+		{Name: "static", Template: "werft", Expected: "werft"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var p githubTriggerPlugin
+			if test.Template != "" {
+				p.statusContext = template.Must(template.New("statusContext").Parse(test.Template))
+			}
+
+			act, err := p.renderStatusContext(job)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if act != test.Expected {
+				t.Errorf("unexpected status context: want %q, got %q", test.Expected, act)
+			}
+		})
+	}
+}
+
+func TestPushChangedFiles(t *testing.T) {
+	size := func(n int) *int { return &n }
+	tests := []struct {
+		Name        string
+		Event       *github.PushEvent
+		Expectation []string
+	}{
+		{Name: "no commits", Event: &github.PushEvent{}},
+		{
+			Name: "all commits",
+			Event: &github.PushEvent{
+				Size: size(2),
+				Commits: []*github.HeadCommit{
+					{Added: []string{"docs/intro.md"}, Modified: []string{"main.go"}},
+					{Modified: []string{"main.go"}, Removed: []string{"old.go"}},
+				},
+			},
+			Expectation: []string{"docs/intro.md", "main.go", "old.go"},
+		},
+		{
+			Name: "truncated commits",
+			Event: &github.PushEvent{
+				Size:    size(21),
+				Commits: []*github.HeadCommit{{Modified: []string{"main.go"}}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			act := pushChangedFiles(test.Event)
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("pushChangedFiles() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+const (
+	testTagPushEvent = `{
+  "ref": "refs/tags/v1.0.0",
+  "after": "6113728f27ae82c7b1a177c8d03f9e96e0adf246",
+  "created": true,
+  "deleted": false,
+  "repository": {"name": "Hello-World", "owner": {"name": "Codertocat", "login": "Codertocat"}},
+  "pusher": {"name": "Codertocat"},
+  "sender": {"login": "Codertocat"}
+}`
+	testReleaseEvent = `{
+  "action": "published",
+  "release": {"tag_name": "v1.0.0", "name": "Hello-World 1.0", "target_commitish": "main", "author": {"login": "octocat"}},
+  "repository": {"name": "Hello-World", "owner": {"login": "Codertocat"}},
+  "sender": {"login": "octocat"}
+}`
+)
+
+func TestPushJobRequest(t *testing.T) {
+	type Expectation struct {
+		Owner    string
+		Ref      string
+		Revision string
+		Trigger  v1.JobTrigger
+	}
+	tests := []struct {
+		Name        string
+		Payload     string
+		Expectation Expectation
+	}{
+		{
+			Name:        "branch",
+			Payload:     `{"ref": "refs/heads/main", "after": "abc", "repository": {"name": "Hello-World", "owner": {"name": "Codertocat"}}, "pusher": {"name": "Codertocat"}}`,
+			Expectation: Expectation{Owner: "Codertocat", Ref: "refs/heads/main", Revision: "abc", Trigger: v1.JobTrigger_TRIGGER_PUSH},
+		},
+		{
+			Name:        "tag",
+			Payload:     testTagPushEvent,
+			Expectation: Expectation{Owner: "Codertocat", Ref: "refs/tags/v1.0.0", Revision: "6113728f27ae82c7b1a177c8d03f9e96e0adf246", Trigger: v1.JobTrigger_TRIGGER_TAG},
+		},
+		{
+			Name:        "deleted tag",
+			Payload:     `{"ref": "refs/tags/v1.0.0", "after": "0000000000000000000000000000000000000000", "deleted": true, "repository": {"name": "Hello-World", "owner": {"name": "Codertocat"}}, "pusher": {"name": "Codertocat"}}`,
+			Expectation: Expectation{Owner: "Codertocat", Ref: "refs/tags/v1.0.0", Revision: "0000000000000000000000000000000000000000", Trigger: v1.JobTrigger_TRIGGER_DELETED},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			event, err := github.ParseWebHook("push", []byte(test.Payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			md := pushJobRequest(event.(*github.PushEvent)).Metadata
+			act := Expectation{Owner: md.Owner, Ref: md.Repository.Ref, Revision: md.Repository.Revision, Trigger: md.Trigger}
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("pushJobRequest() mismatch (-want +got):\n%s", diff)
+			}
+			if md.Repository.Owner != "Codertocat" || md.Repository.Repo != "Hello-World" {
+				t.Errorf("unexpected repository: %v", md.Repository)
+			}
+		})
+	}
+}
+
+func TestReleaseJobRequest(t *testing.T) {
+	type Expectation struct {
+		Owner       string
+		Repository  string
+		Ref         string
+		Revision    string
+		Trigger     v1.JobTrigger
+		Annotations map[string]string
+	}
+	tests := []struct {
+		Name        string
+		Payload     string
+		Expectation *Expectation
+	}{
+		{
+			Name:    "published",
+			Payload: testReleaseEvent,
+			Expectation: &Expectation{
+				Owner:      "octocat",
+				Repository: "github.com/Codertocat/Hello-World",
+				Ref:        "refs/tags/v1.0.0",
+				// the release event names no revision, werft resolves the tag when starting the job
+				Revision: "",
+				Trigger:  v1.JobTrigger_TRIGGER_RELEASE,
+				Annotations: map[string]string{
+					annotationStatusUpdate: "Codertocat/Hello-World",
+					"github.author":        "octocat",
+					"github.event":         "release",
+					"github.release":       "Hello-World 1.0",
+				},
+			},
+		},
+		{Name: "created", Payload: strings.Replace(testReleaseEvent, `"published"`, `"created"`, 1)},
+		{Name: "deleted", Payload: strings.Replace(testReleaseEvent, `"published"`, `"deleted"`, 1)},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			event, err := github.ParseWebHook("release", []byte(test.Payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var act *Expectation
+			if req, ok := releaseJobRequest(event.(*github.ReleaseEvent)); ok {
+				md := req.Metadata
+				act = &Expectation{
+					Owner:       md.Owner,
+					Repository:  md.Repository.Host + "/" + md.Repository.Owner + "/" + md.Repository.Repo,
+					Ref:         md.Repository.Ref,
+					Revision:    md.Repository.Revision,
+					Trigger:     md.Trigger,
+					Annotations: make(map[string]string),
+				}
+				for _, a := range md.Annotations {
+					act.Annotations[a.Key] = a.Value
+				}
+			}
+			if diff := cmp.Diff(test.Expectation, act); diff != "" {
+				t.Errorf("releaseJobRequest() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v35/github"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// rateLimitMaxAttempts is the number of times we try a GitHub API call before giving up
+	rateLimitMaxAttempts = 5
+	// rateLimitMaxDelay caps the time we're willing to wait before retrying a GitHub API call
+	rateLimitMaxDelay = 5 * time.Minute
+	// rateLimitBaseDelay is the initial backoff for errors which carry no hint as to when to retry
+	rateLimitBaseDelay = 2 * time.Second
+)
+
+// retryOnRateLimit calls f until it succeeds, fails with an error that is not worth retrying,
+// or we have exhausted rateLimitMaxAttempts.
+func retryOnRateLimit(ctx context.Context, f func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil {
+			return nil
+		}
+
+		delay, retry := retryDelay(err, attempt, time.Now())
+		if !retry || attempt >= rateLimitMaxAttempts {
+			return err
+		}
+		log.WithError(err).WithField("attempt", attempt).Debugf("GitHub API call failed - retrying in %s", delay)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// retryDelay determines if a failed GitHub API call is worth retrying and how long we should wait before doing so.
+func retryDelay(err error, attempt int, now time.Time) (delay time.Duration, retry bool) {
+	var (
+		rateLimitErr  *github.RateLimitError
+		abuseLimitErr *github.AbuseRateLimitError
+		responseErr   *github.ErrorResponse
+	)
+	switch {
+	case errors.As(err, &rateLimitErr):
+		delay = rateLimitErr.Rate.Reset.Time.Sub(now)
+		if delay < rateLimitBaseDelay {
+			delay = rateLimitBaseDelay
+		}
+	case errors.As(err, &abuseLimitErr):
+		delay = abuseLimitErr.GetRetryAfter()
+		if delay <= 0 {
+			delay = backoff(attempt)
+		}
+	case errors.As(err, &responseErr):
+		if responseErr.Response == nil || responseErr.Response.StatusCode < http.StatusInternalServerError {
+			return 0, false
+		}
+		delay = backoff(attempt)
+	default:
+		return 0, false
+	}
+
+	if delay > rateLimitMaxDelay {
+		delay = rateLimitMaxDelay
+	}
+	return delay, true
+}
+
+// backoff computes an exponential backoff for the n-th attempt
+func backoff(attempt int) time.Duration {
+	return rateLimitBaseDelay * time.Duration(1<<uint(attempt-1))
+}
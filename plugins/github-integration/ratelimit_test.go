@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v35/github"
+)
+
+func TestRetryDelay(t *testing.T) {
+	var (
+		now        = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+		retryAfter = 42 * time.Second
+	)
+	tests := []struct {
+		Name    string
+		Err     error
+		Attempt int
+		Delay   time.Duration
+		Retry   bool
+	}{
+		{Name: "unrelated error", Err: fmt.Errorf("foo"), Attempt: 1},
+		{Name: "not found", Err: &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}}, Attempt: 1},
+		{Name: "server error", Err: &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusBadGateway}}, Attempt: 3, Delay: 8 * time.Second, Retry: true},
+		{Name: "rate limit", Err: &github.RateLimitError{Rate: github.Rate{Reset: github.Timestamp{Time: now.Add(time.Minute)}}}, Attempt: 1, Delay: time.Minute, Retry: true},
+		{Name: "rate limit reset passed", Err: &github.RateLimitError{Rate: github.Rate{Reset: github.Timestamp{Time: now.Add(-time.Minute)}}}, Attempt: 1, Delay: rateLimitBaseDelay, Retry: true},
+		{Name: "rate limit capped", Err: &github.RateLimitError{Rate: github.Rate{Reset: github.Timestamp{Time: now.Add(time.Hour)}}}, Attempt: 1, Delay: rateLimitMaxDelay, Retry: true},
+		{Name: "abuse limit", Err: &github.AbuseRateLimitError{RetryAfter: &retryAfter}, Attempt: 1, Delay: retryAfter, Retry: true},
+		{Name: "abuse limit without hint", Err: &github.AbuseRateLimitError{}, Attempt: 2, Delay: 4 * time.Second, Retry: true},
+		{Name: "wrapped", Err: fmt.Errorf("cannot update: %w", &github.AbuseRateLimitError{RetryAfter: &retryAfter}), Attempt: 1, Delay: retryAfter, Retry: true},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			delay, retry := retryDelay(test.Err, test.Attempt, now)
+			if retry != test.Retry {
+				t.Errorf("unexpected retry: want %v, got %v", test.Retry, retry)
+			}
+			if delay != test.Delay {
+				t.Errorf("unexpected delay: want %v, got %v", test.Delay, delay)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// known payload/secret/signature triple from the GitHub docs on securing webhooks
+const (
+	testWebhookSecret    = "It's a Secret to Everybody"
+	testWebhookPayload   = "Hello, World!"
+	testWebhookSignature = "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	tests := []struct {
+		Name      string
+		Body      string
+		Signature string
+		Secret    string
+		Err       string
+	}{
+		{Name: "valid", Body: testWebhookPayload, Signature: testWebhookSignature, Secret: testWebhookSecret},
+		{Name: "tampered payload", Body: testWebhookPayload + "!", Signature: testWebhookSignature, Secret: testWebhookSecret, Err: "signature mismatch"},
+		{Name: "wrong secret", Body: testWebhookPayload, Signature: testWebhookSignature, Secret: "foobar", Err: "signature mismatch"},
+		{Name: "missing signature", Body: testWebhookPayload, Secret: testWebhookSecret, Err: "missing or malformed X-Hub-Signature-256 header"},
+		{Name: "sha1 signature", Body: testWebhookPayload, Signature: "sha1=01dc10d0c83e72ed246219cdd91669667fe2ca59", Secret: testWebhookSecret, Err: "missing or malformed X-Hub-Signature-256 header"},
+		{Name: "malformed signature", Body: testWebhookPayload, Signature: "sha256=xyz", Secret: testWebhookSecret, Err: "malformed X-Hub-Signature-256 header: encoding/hex: invalid byte: U+0078 'x'"},
+		{Name: "no secret", Body: testWebhookPayload, Signature: testWebhookSignature, Err: "no webhook secret configured"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var act string
+			err := verifyWebhookSignature([]byte(test.Body), test.Signature, []byte(test.Secret))
+			if err != nil {
+				act = err.Error()
+			}
+			if act != test.Err {
+				t.Errorf("unexpected error: want %q, got %q", test.Err, act)
+			}
+		})
+	}
+}
+
+func TestHandleGithubWebhookSignature(t *testing.T) {
+	const pingPayload = `{"zen":"Keep it logically awesome.","hook_id":1}`
+	var (
+		secret    = []byte("secret")
+		signature = "sha256=" + hmacSHA256Hex([]byte(pingPayload), secret)
+	)
+
+	tests := []struct {
+		Name      string
+		Signature string
+		Status    int
+	}{
+		{Name: "valid", Signature: signature, Status: http.StatusOK},
+		{Name: "missing", Status: http.StatusUnauthorized},
+		{Name: "mismatch", Signature: testWebhookSignature, Status: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			p := &githubTriggerPlugin{Config: &Config{}, webhookSecret: secret}
+
+			req := httptest.NewRequest("POST", "/", strings.NewReader(pingPayload))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-GitHub-Event", "ping")
+			if test.Signature != "" {
+				req.Header.Set(signatureHeader, test.Signature)
+			}
+			rec := httptest.NewRecorder()
+			p.HandleGithubWebhook(rec, req)
+
+			if rec.Code != test.Status {
+				t.Errorf("unexpected status: want %d, got %d: %s", test.Status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestLoadWebhookSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "github-integration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fn := filepath.Join(dir, "webhook-secret")
+	err = ioutil.WriteFile(fn, []byte("from-file\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Name   string
+		Config Config
+		Secret string
+		Err    bool
+	}{
+		{Name: "inline", Config: Config{WebhookSecret: "inline"}, Secret: "inline"},
+		{Name: "path", Config: Config{WebhookSecretPath: fn}, Secret: "from-file"},
+		{Name: "path takes precedence", Config: Config{WebhookSecret: "inline", WebhookSecretPath: fn}, Secret: "from-file"},
+		{Name: "missing file", Config: Config{WebhookSecretPath: filepath.Join(dir, "does-not-exist")}, Err: true},
+		{Name: "none", Err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			secret, err := test.Config.loadWebhookSecret()
+			if (err != nil) != test.Err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(secret) != test.Secret {
+				t.Errorf("unexpected secret: want %q, got %q", test.Secret, string(secret))
+			}
+		})
+	}
+}
+
+func hmacSHA256Hex(body, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -24,6 +24,14 @@ const (
 	defaultContainerImage = "alpine/git:latest"
 )
 
+const (
+	// AnnotationCommitSubject is the first line of the message of the commit a job runs on
+	AnnotationCommitSubject = "commit.subject"
+	// AnnotationCommitAuthor is the GitHub login of the author of the commit a job runs on,
+	// or their Git name if the commit isn't linked to a GitHub user
+	AnnotationCommitAuthor = "commit.author"
+)
+
 // GitCredentialHelper can authenticate provide authentication credentials for a repository
 type GitCredentialHelper func(ctx context.Context) (user string, pass string, err error)
 
@@ -47,7 +55,7 @@ func (s *GithubRepoServer) RepoHost(context.Context, *common.RepoHostRequest) (*
 	}, nil
 }
 
-// Resolve resolves the repo's revision based on its ref(erence)
+// Resolve resolves the repo's revision based on its ref(erence), which can be a branch, tag or commit SHA
 func (s *GithubRepoServer) Resolve(ctx context.Context, req *common.ResolveRequest) (*common.ResolveResponse, error) {
 	repo := req.Repository
 	if repo.Revision != "" {
@@ -57,17 +65,15 @@ func (s *GithubRepoServer) Resolve(ctx context.Context, req *common.ResolveReque
 		return nil, status.Errorf(codes.InvalidArgument, "ref is empty")
 	}
 
-	branch, _, err := s.Client.Repositories.GetBranch(ctx, repo.Owner, repo.Repo, repo.Ref)
+	// the commits API resolves branches, tags and (abbreviated) commit SHAs alike
+	sha, _, err := s.Client.Repositories.GetCommitSHA1(ctx, repo.Owner, repo.Repo, repo.Ref, "")
 	if err != nil {
 		return nil, translateGitHubToGRPCError(err, repo.Revision, repo.Ref)
 	}
-	if branch == nil {
-		return nil, status.Error(codes.NotFound, "did not find ref")
-	}
-	if branch.Commit == nil || branch.Commit.SHA == nil {
+	if sha == "" {
 		return nil, status.Error(codes.NotFound, "ref did not point to a commit")
 	}
-	repo.Revision = *branch.Commit.SHA
+	repo.Revision = sha
 	log.WithField("ref", repo.Ref).WithField("rev", repo.Revision).Debug("resolved reference to revision")
 
 	return &common.ResolveResponse{
@@ -112,11 +118,39 @@ func (s *GithubRepoServer) GetRemoteAnnotations(ctx context.Context, req *common
 			res[k] = v
 		}
 	}
+
+	// the commit's metadata must not be overwritten by annotations in its message or PR
+	for k, v := range commitAnnotations(commit) {
+		res[k] = v
+	}
 	return &common.GetRemoteAnnotationsResponse{
 		Annotations: res,
 	}, nil
 }
 
+// commitAnnotations describes the commit a job runs on, so that jobs can be found by their commit's subject or author
+func commitAnnotations(commit *github.RepositoryCommit) map[string]string {
+	res := make(map[string]string)
+	if commit.Commit != nil {
+		subject := commit.Commit.GetMessage()
+		if idx := strings.IndexRune(subject, '\n'); idx >= 0 {
+			subject = subject[:idx]
+		}
+		if subject = strings.TrimSpace(subject); subject != "" {
+			res[AnnotationCommitSubject] = subject
+		}
+	}
+
+	author := commit.GetAuthor().GetLogin()
+	if author == "" && commit.Commit != nil {
+		author = commit.Commit.GetAuthor().GetName()
+	}
+	if author != "" {
+		res[AnnotationCommitAuthor] = author
+	}
+	return res
+}
+
 // pareseAnnotations parses one annotation per line in the form of "/werft <key>(=<value>)?".
 // Any line not matching this format is silently ignored.
 func parseAnnotations(message string) (res map[string]string) {
@@ -245,7 +279,7 @@ func (s *GithubRepoServer) ListFiles(ctx context.Context, req *common.ListFilesR
 }
 
 func translateGitHubToGRPCError(err error, rev, ref string) error {
-	if gherr, ok := err.(*github.ErrorResponse); ok && gherr.Response.StatusCode == 422 {
+	if gherr, ok := err.(*github.ErrorResponse); ok && (gherr.Response.StatusCode == 422 || gherr.Response.StatusCode == 404) {
 		msg := fmt.Sprintf("revision %s", rev)
 		if ref != "" {
 			msg = fmt.Sprintf("ref %s (revision %s)", ref, rev)
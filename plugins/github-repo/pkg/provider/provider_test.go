@@ -1,11 +1,194 @@
 package provider
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/csweichel/werft/pkg/plugin/common"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v31/github"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+func TestResolve(t *testing.T) {
+	const sha = "c0ffee7a0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e"
+	refs := map[string]string{
+		"main":            sha,
+		"refs/heads/main": sha,
+		"feature/foo":     sha,
+		"v1.0.0":          sha,
+		"refs/tags/v1.0":  sha,
+		"c0ffee7":         sha,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ref, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/repos/csweichel/werft/commits/"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rev, ok := refs[ref]
+		if !ok {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprintf(w, `{"message": "No commit found for SHA: %s"}`, ref)
+			return
+		}
+		fmt.Fprint(w, rev)
+	}))
+	defer srv.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+	provider := &GithubRepoServer{Client: client}
+
+	tests := []struct {
+		Name     string
+		Ref      string
+		Revision string
+		Expected string
+		Code     codes.Code
+	}{
+		{Name: "branch", Ref: "main", Expected: sha},
+		{Name: "fully qualified branch", Ref: "refs/heads/main", Expected: sha},
+		{Name: "branch with slash", Ref: "feature/foo", Expected: sha},
+		{Name: "tag", Ref: "v1.0.0", Expected: sha},
+		{Name: "fully qualified tag", Ref: "refs/tags/v1.0", Expected: sha},
+		{Name: "abbreviated SHA", Ref: "c0ffee7", Expected: sha},
+		{Name: "revision is kept", Ref: "main", Revision: "1234567", Expected: "1234567"},
+		{Name: "unknown ref", Ref: "does-not-exist", Code: codes.NotFound},
+		{Name: "empty ref", Code: codes.InvalidArgument},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			resp, err := provider.Resolve(context.Background(), &common.ResolveRequest{
+				Repository: &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: test.Ref, Revision: test.Revision},
+			})
+			if test.Code != codes.OK {
+				if status.Code(err) != test.Code {
+					t.Fatalf("expected %v, got %v", test.Code, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Repository.Revision != test.Expected {
+				t.Errorf("expected revision %s, got %s", test.Expected, resp.Repository.Revision)
+			}
+			if resp.Repository.Ref != test.Ref {
+				t.Errorf("ref changed from %s to %s", test.Ref, resp.Repository.Ref)
+			}
+		})
+	}
+}
+
+func TestResolveCommitMetadata(t *testing.T) {
+	const sha = "c0ffee7a0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e"
+	commits := map[string]string{
+		sha: `{"sha": "` + sha + `", "author": {"login": "csweichel"}, "commit": {"message": "Fix the build\n\nThe details.\n/werft commit.author=someone-else\n/werft deploy", "author": {"name": "Christian Weichel"}}}`,
+		"a11ce": `{"sha": "a11ce", "commit": {"message": "Update README", "author": {"name": "Alice"}}}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.EscapedPath(), "/repos/csweichel/werft/commits/")
+		if strings.HasSuffix(path, "/pulls") {
+			fmt.Fprint(w, `[]`)
+			return
+		}
+		ref, err := url.PathUnescape(path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(r.Header.Get("Accept"), "sha") {
+			// resolving a ref to its SHA
+			if ref == "refs/tags/v1.0.0" || ref == "main" {
+				fmt.Fprint(w, sha)
+				return
+			}
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprintf(w, `{"message": "No commit found for SHA: %s"}`, ref)
+			return
+		}
+		commit, ok := commits[ref]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+			return
+		}
+		fmt.Fprint(w, commit)
+	}))
+	defer srv.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+	provider := &GithubRepoServer{Client: client}
+
+	tests := []struct {
+		Name        string
+		Ref         string
+		Revision    string
+		Expected    string
+		Annotations map[string]string
+	}{
+		{
+			Name:     "branch",
+			Ref:      "main",
+			Expected: sha,
+			Annotations: map[string]string{
+				AnnotationCommitSubject: "Fix the build",
+				AnnotationCommitAuthor:  "csweichel",
+				"deploy":                "",
+			},
+		},
+		{
+			Name:     "tag",
+			Ref:      "refs/tags/v1.0.0",
+			Expected: sha,
+			Annotations: map[string]string{
+				AnnotationCommitSubject: "Fix the build",
+				AnnotationCommitAuthor:  "csweichel",
+				"deploy":                "",
+			},
+		},
+		{
+			Name:     "author without GitHub user",
+			Ref:      "refs/heads/docs",
+			Revision: "a11ce",
+			Expected: "a11ce",
+			Annotations: map[string]string{
+				AnnotationCommitSubject: "Update README",
+				AnnotationCommitAuthor:  "Alice",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			repo := &v1.Repository{Host: "github.com", Owner: "csweichel", Repo: "werft", Ref: test.Ref, Revision: test.Revision}
+			resp, err := provider.Resolve(context.Background(), &common.ResolveRequest{Repository: repo})
+			if err != nil {
+				t.Fatalf("cannot resolve: %v", err)
+			}
+			if resp.Repository.Revision != test.Expected {
+				t.Errorf("expected revision %s, got %s", test.Expected, resp.Repository.Revision)
+			}
+
+			atns, err := provider.GetRemoteAnnotations(context.Background(), &common.GetRemoteAnnotationsRequest{Repository: resp.Repository})
+			if err != nil {
+				t.Fatalf("cannot get annotations: %v", err)
+			}
+			if diff := cmp.Diff(test.Annotations, atns.Annotations); diff != "" {
+				t.Errorf("unexpected annotations (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestParseAnnotations(t *testing.T) {
 	tests := []struct {
 		Name     string
@@ -0,0 +1,12 @@
+module github.com/csweichel/werft/plugins/gitlab-integration
+
+go 1.13
+
+replace github.com/csweichel/werft => ../..
+
+require (
+	github.com/csweichel/werft v0.0.0-00010101000000-000000000000
+	github.com/google/go-cmp v0.5.6
+	github.com/sirupsen/logrus v1.8.1
+	google.golang.org/grpc v1.40.0
+)
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	plugin "github.com/csweichel/werft/pkg/plugin/client"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	werftGitLabContextPrefix = "ci/werft"
+
+	// annotationStatusUpdate is set on jobs whoose status needs to be updated on GitLab.
+	// This is set only on jobs created through GitLab events.
+	annotationStatusUpdate = "updateGitLabStatus"
+
+	defaultGitLabHost = "gitlab.com"
+)
+
+// Config configures this plugin
+type Config struct {
+	BaseURL string `yaml:"baseURL"`
+
+	// Host is the GitLab host, e.g. gitlab.com or gitlab.your-company.com
+	Host string `yaml:"host,omitempty"`
+	// APIURL is the base URL of the GitLab API. Defaults to https://<host>/api/v4
+	APIURL string `yaml:"apiURL,omitempty"`
+
+	// WebhookToken is the secret token GitLab sends in the X-Gitlab-Token header
+	WebhookToken string `yaml:"webhookToken"`
+	// WebhookTokenPath points to a file containing the webhook token, e.g. a mounted Kubernetes secret.
+	// If set, this takes precedence over WebhookToken.
+	WebhookTokenPath string `yaml:"webhookTokenPath,omitempty"`
+
+	// APITokenPath points to a file containing a GitLab access token with api scope.
+	// If set, werft reports job outcomes as commit status.
+	APITokenPath string `yaml:"apiTokenPath,omitempty"`
+}
+
+func (cfg *Config) host() string {
+	if cfg.Host == "" {
+		return defaultGitLabHost
+	}
+	return cfg.Host
+}
+
+func (cfg *Config) apiURL() string {
+	if cfg.APIURL == "" {
+		return fmt.Sprintf("https://%s/api/v4", cfg.host())
+	}
+	return strings.TrimSuffix(cfg.APIURL, "/")
+}
+
+// readSecret produces a secret either from its inline value or the file it's stored in
+func readSecret(value, path string) (string, error) {
+	if path == "" {
+		return value, nil
+	}
+	fc, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(fc)), nil
+}
+
+func main() {
+	plg := &gitlabTriggerPlugin{}
+	plugin.Serve(&Config{},
+		plugin.WithIntegrationPlugin(plg),
+		plugin.WithProxyPass(plg),
+	)
+}
+
+type gitlabTriggerPlugin struct {
+	Config *Config
+	Werft  v1.WerftServiceClient
+	GitLab *gitlabClient
+
+	webhookToken string
+}
+
+func (p *gitlabTriggerPlugin) Run(ctx context.Context, config interface{}, srv v1.WerftServiceClient) error {
+	cfg, ok := config.(*Config)
+	if !ok {
+		return fmt.Errorf("config has wrong type %s", reflect.TypeOf(config))
+	}
+
+	webhookToken, err := readSecret(cfg.WebhookToken, cfg.WebhookTokenPath)
+	if err != nil {
+		return fmt.Errorf("cannot read webhook token: %w", err)
+	}
+	if webhookToken == "" {
+		return fmt.Errorf("no webhook token configured - set webhookToken or webhookTokenPath")
+	}
+
+	p.Config = cfg
+	p.Werft = srv
+	p.webhookToken = webhookToken
+
+	if cfg.APITokenPath == "" {
+		log.Info("no GitLab API token configured - not updating commit status")
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	apiToken, err := readSecret("", cfg.APITokenPath)
+	if err != nil {
+		return fmt.Errorf("cannot read API token: %w", err)
+	}
+	p.GitLab = &gitlabClient{
+		BaseURL: cfg.apiURL(),
+		Token:   apiToken,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+
+	errchan := make(chan error)
+	sub, err := srv.Subscribe(ctx, &v1.SubscribeRequest{})
+	if err != nil {
+		return fmt.Errorf("cannot subscribe for notification: %w", err)
+	}
+	log.Infof("status updates for GitLab set up")
+	go func() {
+		for {
+			inc, err := sub.Recv()
+			if err != nil {
+				errchan <- err
+				return
+			}
+
+			err = p.updateGitLabStatus(ctx, inc.Result)
+			if err != nil {
+				log.WithError(err).Error("cannot update GitLab status")
+			}
+		}
+	}()
+
+	select {
+	case err := <-errchan:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *gitlabTriggerPlugin) Serve(ctx context.Context, l net.Listener) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", http.HandlerFunc(p.HandleGitLabWebhook))
+	http.Serve(l, mux)
+	<-ctx.Done()
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	log "github.com/sirupsen/logrus"
+)
+
+// gitlabClient talks to the parts of the GitLab API we need
+type gitlabClient struct {
+	BaseURL string
+	Token   string
+	Client  *http.Client
+}
+
+// commitStatus is a GitLab commit status
+type commitStatus struct {
+	State       string
+	Name        string
+	TargetURL   string
+	Description string
+}
+
+// SetCommitStatus sets the status of a commit. See https://docs.gitlab.com/ee/api/commits.html#post-the-build-status-to-a-commit
+func (c *gitlabClient) SetCommitStatus(ctx context.Context, project, sha string, status commitStatus) error {
+	form := url.Values{}
+	form.Set("state", status.State)
+	form.Set("name", status.Name)
+	if status.TargetURL != "" {
+		form.Set("target_url", status.TargetURL)
+	}
+	if status.Description != "" {
+		form.Set("description", status.Description)
+	}
+
+	u := fmt.Sprintf("%s/projects/%s/statuses/%s", c.BaseURL, url.PathEscape(project), url.PathEscape(sha))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("PRIVATE-TOKEN", c.Token)
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("cannot set commit status: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// gitlabState maps a job to the state of its GitLab commit status
+func gitlabState(job *v1.JobStatus) (state, desc string) {
+	switch job.Phase {
+	case v1.JobPhase_PHASE_QUEUED, v1.JobPhase_PHASE_WAITING, v1.JobPhase_PHASE_PREPARING, v1.JobPhase_PHASE_STARTING:
+		return "pending", "build is " + strings.TrimPrefix(strings.ToLower(job.Phase.String()), "phase_")
+	case v1.JobPhase_PHASE_RUNNING:
+		return "running", "build is running"
+	case v1.JobPhase_PHASE_DONE:
+	default:
+		return "", ""
+	}
+
+	switch {
+	case job.Conditions != nil && job.Conditions.Success:
+		return "success", "The build succeeded!"
+	case job.Conditions != nil && !job.Conditions.DidExecute, strings.HasPrefix(job.Details, "job was stopped manually"), strings.HasPrefix(job.Details, "superseded"):
+		return "canceled", "The build was canceled"
+	default:
+		return "failed", "The build failed!"
+	}
+}
+
+func (p *gitlabTriggerPlugin) updateGitLabStatus(ctx context.Context, job *v1.JobStatus) error {
+	var project string
+	for _, a := range job.Metadata.Annotations {
+		if a.Key == annotationStatusUpdate {
+			project = a.Value
+			break
+		}
+	}
+	if project == "" {
+		return nil
+	}
+
+	state, desc := gitlabState(job)
+	if state == "" {
+		return nil
+	}
+
+	status := commitStatus{
+		State:       state,
+		Name:        werftGitLabContextPrefix + "/" + job.Metadata.JobSpecName,
+		TargetURL:   fmt.Sprintf("%s/job/%s", p.Config.BaseURL, job.Name),
+		Description: desc,
+	}
+
+	log.WithField("status", status).Debugf("updating GitLab status for %s", job.Name)
+	return p.GitLab.SetCommitStatus(ctx, project, job.Metadata.Repository.Revision, status)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+)
+
+func TestGitLabState(t *testing.T) {
+	tests := []struct {
+		Name  string
+		Job   *v1.JobStatus
+		State string
+	}{
+		{Name: "preparing", Job: &v1.JobStatus{Phase: v1.JobPhase_PHASE_PREPARING}, State: "pending"},
+		{Name: "running", Job: &v1.JobStatus{Phase: v1.JobPhase_PHASE_RUNNING}, State: "running"},
+		{Name: "cleanup", Job: &v1.JobStatus{Phase: v1.JobPhase_PHASE_CLEANUP}},
+		{Name: "success", Job: &v1.JobStatus{Phase: v1.JobPhase_PHASE_DONE, Conditions: &v1.JobConditions{Success: true, DidExecute: true}}, State: "success"},
+		{Name: "failed", Job: &v1.JobStatus{Phase: v1.JobPhase_PHASE_DONE, Conditions: &v1.JobConditions{DidExecute: true}}, State: "failed"},
+		{Name: "stopped", Job: &v1.JobStatus{Phase: v1.JobPhase_PHASE_DONE, Details: "job was stopped manually", Conditions: &v1.JobConditions{DidExecute: true}}, State: "canceled"},
+		{Name: "superseded", Job: &v1.JobStatus{Phase: v1.JobPhase_PHASE_DONE, Details: "superseded: a newer job started", Conditions: &v1.JobConditions{DidExecute: true}}, State: "canceled"},
+		{Name: "queued", Job: &v1.JobStatus{Phase: v1.JobPhase_PHASE_QUEUED}, State: "pending"},
+		{Name: "never ran", Job: &v1.JobStatus{Phase: v1.JobPhase_PHASE_DONE, Conditions: &v1.JobConditions{}}, State: "canceled"},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			state, _ := gitlabState(test.Job)
+			if state != test.State {
+				t.Errorf("unexpected state: want %q, got %q", test.State, state)
+			}
+		})
+	}
+}
+
+func TestSetCommitStatus(t *testing.T) {
+	var (
+		path, token string
+		form        map[string]string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+		token = r.Header.Get("PRIVATE-TOKEN")
+		r.ParseForm()
+		form = map[string]string{
+			"state":      r.PostForm.Get("state"),
+			"name":       r.PostForm.Get("name"),
+			"target_url": r.PostForm.Get("target_url"),
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	client := &gitlabClient{BaseURL: srv.URL, Token: "tkn", Client: srv.Client()}
+	err := client.SetCommitStatus(context.Background(), "group/project", "abc", commitStatus{
+		State:     "success",
+		Name:      "ci/werft/build",
+		TargetURL: "https://werft.example.com/job/foo",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path != "/projects/group%2Fproject/statuses/abc" {
+		t.Errorf("unexpected path: %s", path)
+	}
+	if token != "tkn" {
+		t.Errorf("unexpected token: %s", token)
+	}
+	if form["state"] != "success" || form["name"] != "ci/werft/build" || form["target_url"] != "https://werft.example.com/job/foo" {
+		t.Errorf("unexpected form: %v", form)
+	}
+}
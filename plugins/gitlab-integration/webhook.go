@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// tokenHeader carries the secret token configured on the GitLab webhook
+	tokenHeader = "X-Gitlab-Token"
+	// eventHeader names the kind of event GitLab sent
+	eventHeader = "X-Gitlab-Event"
+
+	eventPush         = "Push Hook"
+	eventMergeRequest = "Merge Request Hook"
+
+	// maxWebhookPayloadSize is the maximum size of webhook payloads we accept
+	maxWebhookPayloadSize = 25 * 1024 * 1024
+
+	// nullRevision is the revision GitLab reports for the "after" state of a deleted branch
+	nullRevision = "0000000000000000000000000000000000000000"
+)
+
+// gitlabProject is the project information contained in GitLab webhook events
+type gitlabProject struct {
+	ID                int64  `json:"id"`
+	PathWithNamespace string `json:"path_with_namespace"`
+}
+
+// pushEvent is sent by GitLab when someone pushes to a repository
+type pushEvent struct {
+	ObjectKind   string        `json:"object_kind"`
+	Before       string        `json:"before"`
+	After        string        `json:"after"`
+	Ref          string        `json:"ref"`
+	CheckoutSHA  string        `json:"checkout_sha"`
+	UserUsername string        `json:"user_username"`
+	Project      gitlabProject `json:"project"`
+	Commits      []struct {
+		Added    []string `json:"added"`
+		Modified []string `json:"modified"`
+		Removed  []string `json:"removed"`
+	} `json:"commits"`
+	TotalCommitsCount int `json:"total_commits_count"`
+}
+
+// mergeRequestEvent is sent by GitLab when a merge request is created or changes
+type mergeRequestEvent struct {
+	ObjectKind string `json:"object_kind"`
+	User       struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Project          gitlabProject `json:"project"`
+	ObjectAttributes struct {
+		IID             int64         `json:"iid"`
+		Action          string        `json:"action"`
+		OldRev          string        `json:"oldrev"`
+		SourceBranch    string        `json:"source_branch"`
+		SourceProjectID int64         `json:"source_project_id"`
+		TargetProjectID int64         `json:"target_project_id"`
+		Source          gitlabProject `json:"source"`
+		LastCommit      struct {
+			ID string `json:"id"`
+		} `json:"last_commit"`
+	} `json:"object_attributes"`
+}
+
+// HandleGitLabWebhook handles incoming GitLab events
+func (p *gitlabTriggerPlugin) HandleGitLabWebhook(w http.ResponseWriter, r *http.Request) {
+	var err error
+	defer func(err *error) {
+		if *err == nil {
+			return
+		}
+
+		log.WithError(*err).Warn("GitLab webhook error")
+		http.Error(w, (*err).Error(), http.StatusInternalServerError)
+	}(&err)
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !validToken(r.Header.Get(tokenHeader), p.webhookToken) {
+		log.WithField("remoteAddr", r.RemoteAddr).Warn("rejecting GitLab webhook with invalid token")
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	payload, err := ioutil.ReadAll(io.LimitReader(r.Body, maxWebhookPayloadSize))
+	if err != nil {
+		return
+	}
+
+	var req *v1.StartGitHubJobRequest
+	switch evt := r.Header.Get(eventHeader); evt {
+	case eventPush:
+		var event pushEvent
+		err = json.Unmarshal(payload, &event)
+		if err != nil {
+			return
+		}
+		req = p.processPushEvent(&event)
+	case eventMergeRequest:
+		var event mergeRequestEvent
+		err = json.Unmarshal(payload, &event)
+		if err != nil {
+			return
+		}
+		req = p.processMergeRequestEvent(&event)
+	default:
+		log.WithField("event", evt).Debug("unhandled GitLab event")
+		return
+	}
+	if req == nil {
+		return
+	}
+
+	_, err = p.Werft.StartGitHubJob(context.Background(), req)
+	if err != nil {
+		return
+	}
+}
+
+// validToken compares the token GitLab sent with the one we expect in constant time
+func validToken(actual, expected string) bool {
+	if expected == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(actual), []byte(expected)) == 1
+}
+
+// processPushEvent produces the request for the job a push should start
+func (p *gitlabTriggerPlugin) processPushEvent(event *pushEvent) *v1.StartGitHubJobRequest {
+	owner, repo := splitProjectPath(event.Project.PathWithNamespace)
+
+	var (
+		trigger = v1.JobTrigger_TRIGGER_PUSH
+		rev     = event.After
+	)
+	if event.After == nullRevision {
+		trigger = v1.JobTrigger_TRIGGER_DELETED
+		rev = event.Before
+	}
+
+	md := &v1.JobMetadata{
+		Owner: event.UserUsername,
+		Repository: &v1.Repository{
+			Host:     p.Config.host(),
+			Owner:    owner,
+			Repo:     repo,
+			Ref:      event.Ref,
+			Revision: rev,
+		},
+		Trigger: trigger,
+		Annotations: []*v1.Annotation{
+			{
+				Key:   annotationStatusUpdate,
+				Value: event.Project.PathWithNamespace,
+			},
+		},
+	}
+	return &v1.StartGitHubJobRequest{
+		Metadata:     md,
+		ChangedFiles: event.changedFiles(),
+	}
+}
+
+// changedFiles lists the files changed by the push. GitLab includes at most 20 commits in the event,
+// if there were more we don't know all changes and return nil.
+func (event *pushEvent) changedFiles() []string {
+	if len(event.Commits) == 0 || event.TotalCommitsCount > len(event.Commits) {
+		return nil
+	}
+
+	var (
+		res = make([]string, 0)
+		idx = make(map[string]struct{})
+	)
+	for _, c := range event.Commits {
+		for _, files := range [][]string{c.Added, c.Modified, c.Removed} {
+			for _, f := range files {
+				if _, exists := idx[f]; exists {
+					continue
+				}
+				idx[f] = struct{}{}
+				res = append(res, f)
+			}
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// processMergeRequestEvent produces the request of the job a merge request should start.
+// Merge requests within the same project don't start a job, because the push to their
+// source branch already did. Hence we only build merge requests coming from forks.
+func (p *gitlabTriggerPlugin) processMergeRequestEvent(event *mergeRequestEvent) *v1.StartGitHubJobRequest {
+	attrs := event.ObjectAttributes
+	switch {
+	case attrs.Action == "open", attrs.Action == "reopen":
+	case attrs.Action == "update" && attrs.OldRev != "":
+		// an update with an oldrev means new commits were pushed
+	default:
+		return nil
+	}
+	if attrs.SourceProjectID == attrs.TargetProjectID {
+		return nil
+	}
+
+	owner, repo := splitProjectPath(attrs.Source.PathWithNamespace)
+	md := &v1.JobMetadata{
+		Owner: event.User.Username,
+		Repository: &v1.Repository{
+			Host:     p.Config.host(),
+			Owner:    owner,
+			Repo:     repo,
+			Ref:      "refs/heads/" + attrs.SourceBranch,
+			Revision: attrs.LastCommit.ID,
+		},
+		Trigger: v1.JobTrigger_TRIGGER_PUSH,
+		Annotations: []*v1.Annotation{
+			{
+				Key:   annotationStatusUpdate,
+				Value: event.Project.PathWithNamespace,
+			},
+			{
+				Key:   "gitlabMergeRequest",
+				Value: fmt.Sprint(attrs.IID),
+			},
+		},
+	}
+	return &v1.StartGitHubJobRequest{
+		Metadata:   md,
+		NameSuffix: "fork",
+	}
+}
+
+// splitProjectPath splits a GitLab project path into owner and repo. GitLab supports
+// nested groups, hence everything but the last segment is considered the owner.
+func splitProjectPath(path string) (owner, repo string) {
+	idx := strings.LastIndex(path, "/")
+	if idx < 0 {
+		return "", path
+	}
+	return path[:idx], path[idx+1:]
+}
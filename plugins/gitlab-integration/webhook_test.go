@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	v1 "github.com/csweichel/werft/pkg/api/v1"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+)
+
+const testPushEvent = `{
+  "object_kind": "push",
+  "before": "95790bf891e76fee5e1747ab589903a6a1f80f22",
+  "after": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+  "ref": "refs/heads/master",
+  "checkout_sha": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+  "user_username": "jsmith",
+  "project": {"id": 15, "path_with_namespace": "mike/diaspora"},
+  "commits": [
+    {"added": ["CHANGELOG"], "modified": ["app/controller/application.rb"], "removed": []},
+    {"added": [], "modified": ["app/controller/application.rb", "README.md"], "removed": ["old.txt"]}
+  ],
+  "total_commits_count": 2
+}`
+
+const testMergeRequestEvent = `{
+  "object_kind": "merge_request",
+  "user": {"username": "root"},
+  "project": {"id": 1, "path_with_namespace": "gitlabhq/gitlab-test"},
+  "object_attributes": {
+    "iid": 1,
+    "action": "open",
+    "source_branch": "ms-viewport",
+    "source_project_id": 14,
+    "target_project_id": 1,
+    "source": {"id": 14, "path_with_namespace": "awesome_space/awesome_project"},
+    "last_commit": {"id": "da1560886d4f094c3e6c9ef40349f7d38b5d27d7"}
+  }
+}`
+
+type fakeWerftClient struct {
+	v1.WerftServiceClient
+
+	Started []*v1.StartGitHubJobRequest
+}
+
+func (c *fakeWerftClient) StartGitHubJob(ctx context.Context, in *v1.StartGitHubJobRequest, opts ...grpc.CallOption) (*v1.StartJobResponse, error) {
+	c.Started = append(c.Started, in)
+	return &v1.StartJobResponse{Status: &v1.JobStatus{Name: "foo"}}, nil
+}
+
+func TestHandleGitLabWebhook(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Token   string
+		Event   string
+		Payload string
+		Status  int
+		Started []*v1.StartGitHubJobRequest
+	}{
+		{Name: "missing token", Event: eventPush, Payload: testPushEvent, Status: http.StatusUnauthorized},
+		{Name: "wrong token", Token: "wrong", Event: eventPush, Payload: testPushEvent, Status: http.StatusUnauthorized},
+		{Name: "unhandled event", Token: "secret", Event: "Tag Push Hook", Payload: `{}`, Status: http.StatusOK},
+		{Name: "invalid payload", Token: "secret", Event: eventPush, Payload: `{`, Status: http.StatusInternalServerError},
+		{
+			Name:    "push",
+			Token:   "secret",
+			Event:   eventPush,
+			Payload: testPushEvent,
+			Status:  http.StatusOK,
+			Started: []*v1.StartGitHubJobRequest{
+				{
+					Metadata: &v1.JobMetadata{
+						Owner: "jsmith",
+						Repository: &v1.Repository{
+							Host:     "gitlab.example.com",
+							Owner:    "mike",
+							Repo:     "diaspora",
+							Ref:      "refs/heads/master",
+							Revision: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+						},
+						Trigger:     v1.JobTrigger_TRIGGER_PUSH,
+						Annotations: []*v1.Annotation{{Key: annotationStatusUpdate, Value: "mike/diaspora"}},
+					},
+					ChangedFiles: []string{"CHANGELOG", "README.md", "app/controller/application.rb", "old.txt"},
+				},
+			},
+		},
+		{
+			Name:    "push with truncated commits",
+			Token:   "secret",
+			Event:   eventPush,
+			Payload: strings.Replace(testPushEvent, `"total_commits_count": 2`, `"total_commits_count": 42`, 1),
+			Status:  http.StatusOK,
+			Started: []*v1.StartGitHubJobRequest{
+				{
+					Metadata: &v1.JobMetadata{
+						Owner: "jsmith",
+						Repository: &v1.Repository{
+							Host:     "gitlab.example.com",
+							Owner:    "mike",
+							Repo:     "diaspora",
+							Ref:      "refs/heads/master",
+							Revision: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+						},
+						Trigger:     v1.JobTrigger_TRIGGER_PUSH,
+						Annotations: []*v1.Annotation{{Key: annotationStatusUpdate, Value: "mike/diaspora"}},
+					},
+				},
+			},
+		},
+		{
+			Name:    "merge request from fork",
+			Token:   "secret",
+			Event:   eventMergeRequest,
+			Payload: testMergeRequestEvent,
+			Status:  http.StatusOK,
+			Started: []*v1.StartGitHubJobRequest{
+				{
+					Metadata: &v1.JobMetadata{
+						Owner: "root",
+						Repository: &v1.Repository{
+							Host:     "gitlab.example.com",
+							Owner:    "awesome_space",
+							Repo:     "awesome_project",
+							Ref:      "refs/heads/ms-viewport",
+							Revision: "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+						},
+						Trigger: v1.JobTrigger_TRIGGER_PUSH,
+						Annotations: []*v1.Annotation{
+							{Key: annotationStatusUpdate, Value: "gitlabhq/gitlab-test"},
+							{Key: "gitlabMergeRequest", Value: "1"},
+						},
+					},
+					NameSuffix: "fork",
+				},
+			},
+		},
+		{
+			Name:    "merge request within project",
+			Token:   "secret",
+			Event:   eventMergeRequest,
+			Payload: strings.Replace(testMergeRequestEvent, `"source_project_id": 14`, `"source_project_id": 1`, 1),
+			Status:  http.StatusOK,
+		},
+		{
+			Name:    "merge request closed",
+			Token:   "secret",
+			Event:   eventMergeRequest,
+			Payload: strings.Replace(testMergeRequestEvent, `"action": "open"`, `"action": "close"`, 1),
+			Status:  http.StatusOK,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			werft := &fakeWerftClient{}
+			p := &gitlabTriggerPlugin{
+				Config:       &Config{Host: "gitlab.example.com"},
+				Werft:        werft,
+				webhookToken: "secret",
+			}
+
+			req := httptest.NewRequest("POST", "/", strings.NewReader(test.Payload))
+			req.Header.Set(eventHeader, test.Event)
+			if test.Token != "" {
+				req.Header.Set(tokenHeader, test.Token)
+			}
+			rec := httptest.NewRecorder()
+			p.HandleGitLabWebhook(rec, req)
+
+			if rec.Code != test.Status {
+				t.Errorf("unexpected status: want %d, got %d: %s", test.Status, rec.Code, rec.Body.String())
+			}
+			if diff := cmp.Diff(test.Started, werft.Started, cmp.Comparer(func(a, b *v1.StartGitHubJobRequest) bool { return a.String() == b.String() })); diff != "" {
+				t.Errorf("started jobs mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestProcessPushEventDeleted(t *testing.T) {
+	p := &gitlabTriggerPlugin{Config: &Config{}}
+	md := p.processPushEvent(&pushEvent{
+		Before:  "95790bf891e76fee5e1747ab589903a6a1f80f22",
+		After:   nullRevision,
+		Ref:     "refs/heads/feature",
+		Project: gitlabProject{PathWithNamespace: "group/subgroup/project"},
+	}).Metadata
+
+	if md.Trigger != v1.JobTrigger_TRIGGER_DELETED {
+		t.Errorf("unexpected trigger: %v", md.Trigger)
+	}
+	if md.Repository.Revision != "95790bf891e76fee5e1747ab589903a6a1f80f22" {
+		t.Errorf("unexpected revision: %s", md.Repository.Revision)
+	}
+	if md.Repository.Host != defaultGitLabHost || md.Repository.Owner != "group/subgroup" || md.Repository.Repo != "project" {
+		t.Errorf("unexpected repository: %v", md.Repository)
+	}
+}